@@ -0,0 +1,44 @@
+// Package blu tracks Shelly BLU sensors across several Gen2+ BLE gateways.
+//
+// Gen2+ devices with Bluetooth act as gateways for BLU devices through the
+// BTHome, BTHomeDevice and BTHomeSensor components. A Fleet enumerates
+// those components on every registered gateway and builds one Sensor per
+// BLU device MAC address with its latest readings, battery level and the
+// gateway with the strongest signal.
+//
+// # Usage
+//
+//	fleet := blu.New(blu.WithEventBus(bus))
+//	fleet.AddGateway("living-room", livingRoom.Client())
+//	fleet.AddGateway("garage", garage.Client())
+//
+//	if err := fleet.Refresh(ctx); err != nil {
+//	    log.Printf("some gateways failed: %v", err)
+//	}
+//	for _, s := range fleet.Sensors() {
+//	    t, _ := s.Float(bthome.NameTemperature)
+//	    fmt.Printf("%s via %s (%d dBm): %.1f °C\n", s.Name, s.Gateway, *s.RSSI, t)
+//	}
+//
+// # Events
+//
+// With a WebSocket or MQTT transport, gateways push NotifyStatus and
+// NotifyEvent notifications and the Fleet keeps sensors current between
+// refreshes. It publishes typed events to the EventBus:
+//   - events.BLUButtonEvent for button presses. A press relayed by several
+//     gateways is published once.
+//   - events.BLUMotionEvent and events.BLUWindowEvent when the state changes
+//   - events.BLUBatteryLowEvent when the battery drops below the threshold
+//
+// # Pairing
+//
+// Discover runs BTHome.StartDeviceDiscovery on a gateway and collects the
+// devices it finds; Pair adds one with BTHome.AddDevice and optionally
+// creates BTHomeSensor components for all of its objects:
+//
+//	found, err := fleet.Discover(ctx, "garage", 30*time.Second)
+//	sensor, err := fleet.Pair(ctx, "garage", found[0].Addr, &blu.PairOptions{
+//	    Name:       "Garage door",
+//	    AddSensors: true,
+//	})
+package blu
@@ -0,0 +1,738 @@
+package blu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/bthome"
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+// Defaults for Fleet options.
+const (
+	// DefaultBatteryLowThreshold is the battery percentage below which a
+	// battery low event is published.
+	DefaultBatteryLowThreshold = 20
+
+	// DefaultStaleAfter is how long a gateway sighting counts when
+	// selecting the gateway with the best signal.
+	DefaultStaleAfter = 15 * time.Minute
+
+	// DefaultButtonDedupWindow is how long a button press relayed by a
+	// second gateway is treated as the same press.
+	DefaultButtonDedupWindow = 2 * time.Second
+)
+
+// Component types on the gateway.
+const (
+	componentBTHomeDevice = "bthomedevice"
+	componentBTHomeSensor = "bthomesensor"
+	componentBTHome       = "bthome"
+)
+
+// ErrUnknownGateway is returned for a gateway ID that was not added.
+var ErrUnknownGateway = errors.New("blu: unknown gateway")
+
+// Option configures a Fleet.
+type Option func(*Fleet)
+
+// WithEventBus publishes sensor events to bus instead of a bus created
+// by New.
+func WithEventBus(bus *events.EventBus) Option {
+	return func(f *Fleet) {
+		if bus != nil {
+			f.bus = bus
+		}
+	}
+}
+
+// WithBatteryLowThreshold sets the battery percentage below which a
+// battery low event is published.
+func WithBatteryLowThreshold(percent int) Option {
+	return func(f *Fleet) {
+		f.batteryLow = percent
+	}
+}
+
+// WithStaleAfter sets how long a sighting counts when selecting the
+// gateway with the best signal. Zero disables the age check.
+func WithStaleAfter(d time.Duration) Option {
+	return func(f *Fleet) {
+		f.staleAfter = d
+	}
+}
+
+// WithButtonDedupWindow sets how long a press relayed by another gateway
+// is treated as the same press. Zero publishes every relayed press.
+func WithButtonDedupWindow(d time.Duration) Option {
+	return func(f *Fleet) {
+		f.dedupWindow = d
+	}
+}
+
+// componentRef maps a gateway component to the BLU device it belongs to.
+type componentRef struct {
+	key    string
+	mac    string
+	name   string
+	objID  int
+	idx    int
+	device bool
+}
+
+// gateway is a registered BLE gateway.
+type gateway struct {
+	client    *rpc.Client
+	refs      map[string]componentRef
+	discovery *discoverySession
+	id        string
+}
+
+// lastPress records the last published button press of a device.
+type lastPress struct {
+	at      time.Time
+	event   string
+	gateway string
+	button  int
+}
+
+// Fleet tracks BLU sensors across several gateways.
+//
+// A Fleet is safe for concurrent use.
+type Fleet struct {
+	bus         *events.EventBus
+	gateways    map[string]*gateway
+	sensors     map[string]*Sensor
+	presses     map[string]lastPress
+	now         func() time.Time
+	batteryLow  int
+	staleAfter  time.Duration
+	dedupWindow time.Duration
+	mu          sync.Mutex
+}
+
+// New creates an empty Fleet.
+func New(opts ...Option) *Fleet {
+	f := &Fleet{
+		gateways:    make(map[string]*gateway),
+		sensors:     make(map[string]*Sensor),
+		presses:     make(map[string]lastPress),
+		now:         time.Now,
+		batteryLow:  DefaultBatteryLowThreshold,
+		staleAfter:  DefaultStaleAfter,
+		dedupWindow: DefaultButtonDedupWindow,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.bus == nil {
+		f.bus = events.NewEventBus()
+	}
+	return f
+}
+
+// EventBus returns the bus sensor events are published to.
+func (f *Fleet) EventBus() *events.EventBus {
+	return f.bus
+}
+
+// AddGateway registers a gateway and subscribes to its notifications.
+//
+// Components are not read until the next Refresh. Adding an ID again
+// replaces the previous gateway.
+func (f *Fleet) AddGateway(id string, client *rpc.Client) {
+	gw := &gateway{
+		id:     id,
+		client: client,
+		refs:   make(map[string]componentRef),
+	}
+
+	f.mu.Lock()
+	if old, ok := f.gateways[id]; ok {
+		f.dropGatewayLocked(old)
+	}
+	f.gateways[id] = gw
+	f.mu.Unlock()
+
+	client.OnNotificationMethod("NotifyStatus", func(params json.RawMessage) {
+		f.handleNotifyStatus(gw, params)
+	})
+	client.OnNotificationMethod("NotifyEvent", func(params json.RawMessage) {
+		f.handleNotifyEvent(gw, params)
+	})
+}
+
+// RemoveGateway unregisters a gateway and forgets its sightings.
+//
+// Notification handlers stay registered on the client but become no-ops.
+func (f *Fleet) RemoveGateway(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if gw, ok := f.gateways[id]; ok {
+		f.dropGatewayLocked(gw)
+		delete(f.gateways, id)
+	}
+}
+
+// Gateways returns the registered gateway IDs in sorted order.
+func (f *Fleet) Gateways() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := make([]string, 0, len(f.gateways))
+	for id := range f.gateways {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Sensors returns copies of all known sensors sorted by MAC address.
+func (f *Fleet) Sensors() []*Sensor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sensors := make([]*Sensor, 0, len(f.sensors))
+	for _, s := range f.sensors {
+		sensors = append(sensors, s.clone())
+	}
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].MAC < sensors[j].MAC })
+	return sensors
+}
+
+// Sensor returns a copy of the sensor with the given MAC address.
+func (f *Fleet) Sensor(mac string) (*Sensor, bool) {
+	norm, err := bthome.NormalizeMAC(mac)
+	if err != nil {
+		return nil, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sensors[norm]
+	if !ok {
+		return nil, false
+	}
+	return s.clone(), true
+}
+
+// Refresh reads the BTHomeDevice and BTHomeSensor components of every
+// gateway. Gateways that fail are skipped and their errors joined.
+func (f *Fleet) Refresh(ctx context.Context) error {
+	f.mu.Lock()
+	gateways := make([]*gateway, 0, len(f.gateways))
+	for _, gw := range f.gateways {
+		gateways = append(gateways, gw)
+	}
+	f.mu.Unlock()
+	sort.Slice(gateways, func(i, j int) bool { return gateways[i].id < gateways[j].id })
+
+	var errs []error
+	for _, gw := range gateways {
+		if err := f.refreshGateway(ctx, gw); err != nil {
+			errs = append(errs, fmt.Errorf("gateway %s: %w", gw.id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RefreshGateway reads the components of a single gateway.
+func (f *Fleet) RefreshGateway(ctx context.Context, id string) error {
+	gw, err := f.gateway(id)
+	if err != nil {
+		return err
+	}
+	return f.refreshGateway(ctx, gw)
+}
+
+func (f *Fleet) gateway(id string) (*gateway, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	gw, ok := f.gateways[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGateway, id)
+	}
+	return gw, nil
+}
+
+// componentEntry is one entry of a Shelly.GetComponents response.
+type componentEntry struct {
+	Key    string          `json:"key"`
+	Status json.RawMessage `json:"status,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// componentConfig holds the BTHomeDevice and BTHomeSensor config fields.
+type componentConfig struct {
+	Name  *string `json:"name"`
+	Addr  string  `json:"addr"`
+	ObjID int     `json:"obj_id"`
+	Idx   int     `json:"idx"`
+}
+
+// deviceStatus holds BTHomeDevice status fields. All fields are optional
+// since NotifyStatus only carries changed fields.
+type deviceStatus struct {
+	RSSI         *int     `json:"rssi"`
+	Battery      *int     `json:"battery"`
+	LastUpdateTS *float64 `json:"last_updated_ts"`
+}
+
+// sensorStatus holds BTHomeSensor status fields.
+type sensorStatus struct {
+	Value        json.RawMessage `json:"value"`
+	LastUpdateTS *float64        `json:"last_updated_ts"`
+}
+
+// listComponents pages through the dynamic components of a gateway.
+func listComponents(ctx context.Context, client *rpc.Client) ([]componentEntry, error) {
+	var all []componentEntry
+	for {
+		params := map[string]any{
+			"dynamic_only": true,
+			"include":      []string{"status", "config"},
+			"offset":       len(all),
+		}
+		result, err := client.Call(ctx, "Shelly.GetComponents", params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list components: %w", err)
+		}
+
+		var page struct {
+			Components []componentEntry `json:"components"`
+			Total      int              `json:"total"`
+		}
+		if err := json.Unmarshal(result, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse components: %w", err)
+		}
+
+		all = append(all, page.Components...)
+		if len(page.Components) == 0 || len(all) >= page.Total {
+			return all, nil
+		}
+	}
+}
+
+func (f *Fleet) refreshGateway(ctx context.Context, gw *gateway) error {
+	entries, err := listComponents(ctx, gw.client)
+	if err != nil {
+		return err
+	}
+
+	refs := make(map[string]componentRef)
+	for _, entry := range entries {
+		ref, ok := parseComponentRef(entry.Key, entry.Config)
+		if ok {
+			refs[entry.Key] = ref
+		}
+	}
+
+	f.mu.Lock()
+	if f.gateways[gw.id] != gw {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownGateway, gw.id)
+	}
+	f.pruneLocked(gw, refs)
+	gw.refs = refs
+
+	var pending []events.Event
+	for _, entry := range entries {
+		ref, ok := refs[entry.Key]
+		if !ok || len(entry.Status) == 0 {
+			continue
+		}
+		pending = append(pending, f.applyStatusLocked(gw, ref, entry.Status, events.EventSourceLocal)...)
+	}
+	f.mu.Unlock()
+
+	f.publish(pending)
+	return nil
+}
+
+// parseComponentRef reads a component's config into a reference.
+func parseComponentRef(key string, config json.RawMessage) (componentRef, bool) {
+	kind, _, ok := strings.Cut(key, ":")
+	if !ok || (kind != componentBTHomeDevice && kind != componentBTHomeSensor) {
+		return componentRef{}, false
+	}
+
+	var cfg componentConfig
+	if json.Unmarshal(config, &cfg) != nil {
+		return componentRef{}, false
+	}
+	mac, err := bthome.NormalizeMAC(cfg.Addr)
+	if err != nil {
+		return componentRef{}, false
+	}
+
+	ref := componentRef{
+		key:    key,
+		mac:    mac,
+		objID:  cfg.ObjID,
+		idx:    cfg.Idx,
+		device: kind == componentBTHomeDevice,
+	}
+	if cfg.Name != nil {
+		ref.name = *cfg.Name
+	}
+	return ref, true
+}
+
+// pruneLocked forgets what gw reported for components it no longer has.
+func (f *Fleet) pruneLocked(gw *gateway, refs map[string]componentRef) {
+	for key, old := range gw.refs {
+		if ref, ok := refs[key]; ok && ref.mac == old.mac {
+			continue
+		}
+		s, ok := f.sensors[old.mac]
+		if !ok {
+			continue
+		}
+		if old.device {
+			delete(s.Sightings, gw.id)
+		}
+		for rk, r := range s.Readings {
+			if r.Gateway == gw.id && r.Component == key {
+				delete(s.Readings, rk)
+			}
+		}
+		f.settleLocked(s)
+	}
+}
+
+// dropGatewayLocked forgets everything gw reported.
+func (f *Fleet) dropGatewayLocked(gw *gateway) {
+	f.pruneLocked(gw, nil)
+	gw.refs = make(map[string]componentRef)
+}
+
+// settleLocked reselects the gateway of s, or drops s if no gateway
+// reports it anymore.
+func (f *Fleet) settleLocked(s *Sensor) {
+	if len(s.Sightings) == 0 && len(s.Readings) == 0 {
+		delete(f.sensors, s.MAC)
+		return
+	}
+	s.selectGateway(f.now(), f.staleAfter)
+}
+
+// sensorLocked returns the sensor for ref, creating it if needed.
+func (f *Fleet) sensorLocked(ref componentRef) *Sensor {
+	s, ok := f.sensors[ref.mac]
+	if !ok {
+		s = &Sensor{
+			MAC:       ref.mac,
+			Readings:  make(map[string]Reading),
+			Sightings: make(map[string]Sighting),
+		}
+		f.sensors[ref.mac] = s
+	}
+	if ref.device && ref.name != "" {
+		s.Name = ref.name
+	}
+	return s
+}
+
+// applyStatusLocked merges a component status (or status delta) and
+// returns the events it triggers.
+func (f *Fleet) applyStatusLocked(
+	gw *gateway, ref componentRef, raw json.RawMessage, source events.EventSource,
+) []events.Event {
+	s := f.sensorLocked(ref)
+	if ref.device {
+		var status deviceStatus
+		if json.Unmarshal(raw, &status) != nil {
+			return nil
+		}
+		return f.applyDeviceStatusLocked(gw, ref, s, &status, source)
+	}
+
+	var status sensorStatus
+	if json.Unmarshal(raw, &status) != nil {
+		return nil
+	}
+	return f.applySensorStatusLocked(gw, ref, s, &status, source)
+}
+
+func (f *Fleet) applyDeviceStatusLocked(
+	gw *gateway, ref componentRef, s *Sensor, status *deviceStatus, source events.EventSource,
+) []events.Event {
+	sighting, ok := s.Sightings[gw.id]
+	if !ok {
+		sighting = Sighting{Gateway: gw.id, Component: ref.key}
+	}
+	if status.RSSI != nil {
+		rssi := *status.RSSI
+		sighting.RSSI = &rssi
+	}
+	if status.LastUpdateTS != nil {
+		sighting.Updated = unixTime(*status.LastUpdateTS)
+	} else if sighting.Updated.IsZero() || status.RSSI != nil {
+		sighting.Updated = f.now()
+	}
+	s.Sightings[gw.id] = sighting
+	if sighting.Updated.After(s.Updated) {
+		s.Updated = sighting.Updated
+	}
+	s.selectGateway(f.now(), f.staleAfter)
+
+	if status.Battery == nil {
+		return nil
+	}
+	return f.setBatteryLocked(gw, s, *status.Battery, source)
+}
+
+func (f *Fleet) applySensorStatusLocked(
+	gw *gateway, ref componentRef, s *Sensor, status *sensorStatus, source events.EventSource,
+) []events.Event {
+	key := ReadingKey(objectName(ref.objID), ref.idx)
+	prev, hadPrev := s.Readings[key]
+
+	reading := prev
+	if !hadPrev || prev.Gateway != gw.id {
+		info, _ := lookupObject(ref.objID)
+		unit := info.Unit
+		reading = Reading{
+			Name:      objectName(ref.objID),
+			Unit:      unit,
+			ObjID:     ref.objID,
+			Idx:       ref.idx,
+			Value:     prev.Value,
+			Updated:   prev.Updated,
+			Gateway:   gw.id,
+			Component: ref.key,
+		}
+	}
+
+	updated := f.now()
+	if status.LastUpdateTS != nil {
+		updated = unixTime(*status.LastUpdateTS)
+	}
+	// Another gateway already delivered a newer value.
+	if hadPrev && prev.Gateway != gw.id && updated.Before(prev.Updated) {
+		return nil
+	}
+	reading.Updated = updated
+
+	if len(status.Value) > 0 {
+		var value any
+		if json.Unmarshal(status.Value, &value) == nil && value != nil {
+			reading.Value = value
+		}
+	}
+	s.Readings[key] = reading
+	if updated.After(s.Updated) {
+		s.Updated = updated
+	}
+
+	if ref.idx != 0 {
+		return nil
+	}
+	return f.sensorEventsLocked(gw, s, prev, hadPrev, reading, source)
+}
+
+// sensorEventsLocked returns events for state changes of well-known objects.
+func (f *Fleet) sensorEventsLocked(
+	gw *gateway, s *Sensor, prev Reading, hadPrev bool, cur Reading, source events.EventSource,
+) []events.Event {
+	switch cur.Name {
+	case bthome.NameBattery:
+		if v, ok := cur.Float(); ok {
+			return f.setBatteryLocked(gw, s, int(math.Round(v)), source)
+		}
+	case bthome.NameBatteryLow:
+		low, ok := cur.Bool()
+		if !ok {
+			return nil
+		}
+		if !low {
+			s.batteryLow = false
+			return nil
+		}
+		if s.batteryLow {
+			return nil
+		}
+		s.batteryLow = true
+		battery := -1
+		if s.Battery != nil {
+			battery = *s.Battery
+		}
+		return []events.Event{events.NewBLUBatteryLowEvent(s.MAC, gw.id, battery).WithName(s.Name).WithSource(source)}
+	case bthome.NameMotion, bthome.NameWindow:
+		state, ok := cur.Bool()
+		if !ok || !hadPrev {
+			return nil
+		}
+		if was, ok := prev.Bool(); ok && was == state {
+			return nil
+		}
+		if cur.Name == bthome.NameMotion {
+			return []events.Event{events.NewBLUMotionEvent(s.MAC, gw.id, state).WithName(s.Name).WithSource(source)}
+		}
+		return []events.Event{events.NewBLUWindowEvent(s.MAC, gw.id, state).WithName(s.Name).WithSource(source)}
+	}
+	return nil
+}
+
+// setBatteryLocked records a battery level and returns a battery low
+// event when it first drops below the threshold.
+func (f *Fleet) setBatteryLocked(gw *gateway, s *Sensor, battery int, source events.EventSource) []events.Event {
+	s.Battery = &battery
+	if battery >= f.batteryLow {
+		s.batteryLow = false
+		return nil
+	}
+	if s.batteryLow {
+		return nil
+	}
+	s.batteryLow = true
+	return []events.Event{events.NewBLUBatteryLowEvent(s.MAC, gw.id, battery).WithName(s.Name).WithSource(source)}
+}
+
+// handleNotifyStatus merges status deltas pushed by a gateway.
+func (f *Fleet) handleNotifyStatus(gw *gateway, params json.RawMessage) {
+	var p map[string]json.RawMessage
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+
+	f.mu.Lock()
+	if f.gateways[gw.id] != gw {
+		f.mu.Unlock()
+		return
+	}
+	var pending []events.Event
+	for key, raw := range p {
+		if ref, ok := gw.refs[key]; ok {
+			pending = append(pending, f.applyStatusLocked(gw, ref, raw, events.EventSourceWebSocket)...)
+		}
+	}
+	f.mu.Unlock()
+
+	f.publish(pending)
+}
+
+// notifyEvent is one entry of a NotifyEvent notification.
+type notifyEvent struct {
+	Component string          `json:"component"`
+	Event     string          `json:"event"`
+	Device    json.RawMessage `json:"device,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Idx       *int            `json:"idx,omitempty"`
+}
+
+// handleNotifyEvent publishes button presses and forwards discovery
+// results to a running Discover call.
+func (f *Fleet) handleNotifyEvent(gw *gateway, params json.RawMessage) {
+	var p struct {
+		Events []notifyEvent `json:"events"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+
+	f.mu.Lock()
+	if f.gateways[gw.id] != gw {
+		f.mu.Unlock()
+		return
+	}
+	var pending []events.Event
+	for _, e := range p.Events {
+		if e.Component == componentBTHome {
+			f.discoveryEventLocked(gw, &e)
+			continue
+		}
+		ref, ok := gw.refs[e.Component]
+		if !ok {
+			continue
+		}
+		if evt := f.buttonEventLocked(gw, ref, &e); evt != nil {
+			pending = append(pending, evt)
+		}
+	}
+	f.mu.Unlock()
+
+	f.publish(pending)
+}
+
+// buttonEvents maps gateway event names to BTHome button event names.
+// Gateways report presses with the input event names.
+var buttonEvents = map[string]string{
+	"single_push":                bthome.ButtonPress,
+	"double_push":                bthome.ButtonDoublePress,
+	"triple_push":                bthome.ButtonTriplePress,
+	"long_push":                  bthome.ButtonLongPress,
+	bthome.ButtonPress:           bthome.ButtonPress,
+	bthome.ButtonDoublePress:     bthome.ButtonDoublePress,
+	bthome.ButtonTriplePress:     bthome.ButtonTriplePress,
+	bthome.ButtonLongPress:       bthome.ButtonLongPress,
+	bthome.ButtonLongDoublePress: bthome.ButtonLongDoublePress,
+	bthome.ButtonLongTriplePress: bthome.ButtonLongTriplePress,
+	bthome.ButtonHoldPress:       bthome.ButtonHoldPress,
+}
+
+// buttonEventLocked converts a gateway event into a button event,
+// suppressing copies of the same press relayed by other gateways.
+func (f *Fleet) buttonEventLocked(gw *gateway, ref componentRef, e *notifyEvent) events.Event {
+	name, ok := buttonEvents[e.Event]
+	if !ok {
+		return nil
+	}
+	button := ref.idx
+	if e.Idx != nil {
+		button = *e.Idx
+	}
+
+	now := f.now()
+	if last, ok := f.presses[ref.mac]; ok && f.dedupWindow > 0 &&
+		last.gateway != gw.id && last.event == name && last.button == button &&
+		now.Sub(last.at) < f.dedupWindow {
+		return nil
+	}
+	f.presses[ref.mac] = lastPress{at: now, event: name, gateway: gw.id, button: button}
+
+	s := f.sensorLocked(ref)
+	return events.NewBLUButtonEvent(ref.mac, gw.id, name, button).WithName(s.Name).WithSource(events.EventSourceWebSocket)
+}
+
+// publish sends events outside the lock so handlers may call back into
+// the Fleet.
+func (f *Fleet) publish(pending []events.Event) {
+	for _, evt := range pending {
+		f.bus.Publish(evt)
+	}
+}
+
+// lookupObject returns the BTHome description of an object ID.
+func lookupObject(objID int) (bthome.ObjectInfo, bool) {
+	if objID < 0 || objID > math.MaxUint8 {
+		return bthome.ObjectInfo{}, false
+	}
+	return bthome.Lookup(uint8(objID))
+}
+
+// objectName returns the BTHome object name for an object ID.
+func objectName(objID int) string {
+	if info, ok := lookupObject(objID); ok {
+		return info.Name
+	}
+	return "obj_" + strconv.Itoa(objID)
+}
+
+// unixTime converts a fractional Unix timestamp.
+func unixTime(ts float64) time.Time {
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
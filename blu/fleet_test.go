@@ -0,0 +1,409 @@
+package blu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/bthome"
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result string) (json.RawMessage, error) {
+	return json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`), nil
+}
+
+// fakeGateway serves Shelly.GetComponents from a component list.
+type fakeGateway struct {
+	components string
+	calls      []string
+	params     []json.RawMessage
+	mu         sync.Mutex
+}
+
+func (g *fakeGateway) client(handler func(method string, params json.RawMessage) (string, error)) *rpc.Client {
+	return rpc.NewClient(&mockTransport{
+		callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+			params, _ := json.Marshal(req.GetParams())
+			g.mu.Lock()
+			g.calls = append(g.calls, req.GetMethod())
+			g.params = append(g.params, params)
+			components := g.components
+			g.mu.Unlock()
+
+			if handler != nil {
+				result, err := handler(req.GetMethod(), params)
+				if err != nil || result != "" {
+					if err != nil {
+						return nil, err
+					}
+					return jsonrpcResponse(result)
+				}
+			}
+			if req.GetMethod() == "Shelly.GetComponents" {
+				return jsonrpcResponse(components)
+			}
+			return jsonrpcResponse("null")
+		},
+	})
+}
+
+func deviceComponent(id int, mac, name string, rssi, battery int, ts float64) string {
+	return fmt.Sprintf(`{"key":"bthomedevice:%d","status":{"id":%d,"rssi":%d,"battery":%d,"packet_id":1,"last_updated_ts":%g},
+		"config":{"id":%d,"addr":%q,"name":%q,"key":null}}`, id, id, rssi, battery, ts, id, mac, name)
+}
+
+func sensorComponent(id int, mac string, objID, idx int, value string, ts float64) string {
+	return fmt.Sprintf(`{"key":"bthomesensor:%d","status":{"id":%d,"value":%s,"last_updated_ts":%g},
+		"config":{"id":%d,"addr":%q,"name":null,"obj_id":%d,"idx":%d}}`, id, id, value, ts, id, mac, objID, idx)
+}
+
+func componentList(entries ...string) string {
+	list := "["
+	for i, e := range entries {
+		if i > 0 {
+			list += ","
+		}
+		list += e
+	}
+	list += "]"
+	return fmt.Sprintf(`{"components":%s,"cfg_rev":1,"offset":0,"total":%d}`, list, len(entries))
+}
+
+const (
+	macHT   = "7c:c6:b6:00:00:01"
+	macDoor = "7c:c6:b6:00:00:02"
+	macBtn  = "7c:c6:b6:00:00:03"
+)
+
+// recorder collects events published on a bus.
+type recorder struct {
+	events []events.Event
+	mu     sync.Mutex
+}
+
+func newRecorder(bus *events.EventBus) *recorder {
+	r := &recorder{}
+	bus.Subscribe(func(e events.Event) {
+		r.mu.Lock()
+		r.events = append(r.events, e)
+		r.mu.Unlock()
+	})
+	return r
+}
+
+func (r *recorder) take() []events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.events
+	r.events = nil
+	return out
+}
+
+func route(t *testing.T, client *rpc.Client, data string) {
+	t.Helper()
+	if err := client.NotificationRouter().RouteRaw([]byte(data)); err != nil {
+		t.Fatalf("RouteRaw() error = %v", err)
+	}
+}
+
+func TestFleet_RefreshCorrelatesGateways(t *testing.T) {
+	kitchen := &fakeGateway{components: componentList(
+		deviceComponent(200, macHT, "H&T", -80, 90, 1700000000),
+		sensorComponent(201, macHT, 0x02, 0, "21.5", 1700000000),
+		sensorComponent(202, macHT, 0x03, 0, "48", 1700000000),
+		deviceComponent(203, macDoor, "Front door", -60, 100, 1700000000),
+		sensorComponent(204, macDoor, 0x2D, 0, "false", 1700000000),
+	)}
+	hall := &fakeGateway{components: componentList(
+		deviceComponent(200, macHT, "H&T", -55, 90, 1700000010),
+		sensorComponent(201, macHT, 0x02, 0, "21.7", 1700000010),
+		sensorComponent(202, macHT, 0x02, 1, "5.2", 1700000010),
+		// Plain switch component must be ignored.
+		`{"key":"switch:0","status":{"output":true},"config":{"name":null}}`,
+	)}
+
+	fleet := New(WithStaleAfter(0))
+	fleet.AddGateway("kitchen", kitchen.client(nil))
+	fleet.AddGateway("hall", hall.client(nil))
+
+	if err := fleet.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	sensors := fleet.Sensors()
+	if len(sensors) != 2 {
+		t.Fatalf("got %d sensors, want 2", len(sensors))
+	}
+
+	ht, ok := fleet.Sensor(macHT)
+	if !ok {
+		t.Fatal("H&T sensor not found")
+	}
+	if ht.Name != "H&T" || ht.MAC != "7C:C6:B6:00:00:01" {
+		t.Errorf("sensor = %+v", ht)
+	}
+	if ht.Gateway != "hall" || ht.RSSI == nil || *ht.RSSI != -55 {
+		t.Errorf("Gateway = %s RSSI = %v, want hall -55", ht.Gateway, ht.RSSI)
+	}
+	if len(ht.Sightings) != 2 {
+		t.Errorf("Sightings = %+v, want 2", ht.Sightings)
+	}
+	if v, _ := ht.Float(bthome.NameTemperature); v != 21.7 {
+		t.Errorf("temperature = %v, want newest value 21.7", v)
+	}
+	if v, _ := ht.Float(bthome.NameHumidity); v != 48 {
+		t.Errorf("humidity = %v, want 48", v)
+	}
+	if r, ok := ht.Readings[ReadingKey(bthome.NameTemperature, 1)]; !ok || r.Value != 5.2 {
+		t.Errorf("second temperature = %+v", r)
+	}
+	if r, _ := ht.Reading(bthome.NameTemperature); r.Unit != "°C" || r.Gateway != "hall" || r.Component != "bthomesensor:201" {
+		t.Errorf("reading = %+v", r)
+	}
+
+	door, _ := fleet.Sensor(macDoor)
+	if open, ok := door.Bool(bthome.NameWindow); !ok || open {
+		t.Errorf("window = %v, %v; want closed", open, ok)
+	}
+	if door.Battery == nil || *door.Battery != 100 {
+		t.Errorf("Battery = %v, want 100", door.Battery)
+	}
+
+	// The component list request asks for dynamic components with status
+	// and config.
+	var params struct {
+		Include     []string `json:"include"`
+		DynamicOnly bool     `json:"dynamic_only"`
+	}
+	if err := json.Unmarshal(kitchen.params[0], &params); err != nil || !params.DynamicOnly || len(params.Include) != 2 {
+		t.Errorf("GetComponents params = %s", kitchen.params[0])
+	}
+
+	// Removing the gateway with the best signal moves the sensor.
+	fleet.RemoveGateway("hall")
+	ht, _ = fleet.Sensor(macHT)
+	if ht.Gateway != "kitchen" || len(ht.Sightings) != 1 {
+		t.Errorf("after RemoveGateway: Gateway = %s, Sightings = %d", ht.Gateway, len(ht.Sightings))
+	}
+	if _, ok := ht.Readings[ReadingKey(bthome.NameTemperature, 1)]; ok {
+		t.Error("readings from removed gateway should be dropped")
+	}
+}
+
+func TestFleet_StaleSightings(t *testing.T) {
+	now := time.Unix(1700001000, 0)
+	a := &fakeGateway{components: componentList(deviceComponent(200, macHT, "H&T", -50, 90, 1700000000))}
+	b := &fakeGateway{components: componentList(deviceComponent(200, macHT, "H&T", -70, 90, 1700000990))}
+
+	fleet := New(WithStaleAfter(time.Minute))
+	fleet.now = func() time.Time { return now }
+	fleet.AddGateway("a", a.client(nil))
+	fleet.AddGateway("b", b.client(nil))
+	if err := fleet.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	s, _ := fleet.Sensor(macHT)
+	if s.Gateway != "b" {
+		t.Errorf("Gateway = %s, want b (a's sighting is stale)", s.Gateway)
+	}
+}
+
+func TestFleet_RefreshPagination(t *testing.T) {
+	gw := &fakeGateway{}
+	client := gw.client(func(method string, params json.RawMessage) (string, error) {
+		var p struct {
+			Offset int `json:"offset"`
+		}
+		_ = json.Unmarshal(params, &p)
+		entry := deviceComponent(200+p.Offset, fmt.Sprintf("7c:c6:b6:00:00:%02x", p.Offset+1), "", -60, 80, 1)
+		return fmt.Sprintf(`{"components":[%s],"offset":%d,"total":3}`, entry, p.Offset), nil
+	})
+
+	fleet := New()
+	fleet.AddGateway("gw", client)
+	if err := fleet.RefreshGateway(context.Background(), "gw"); err != nil {
+		t.Fatalf("RefreshGateway() error = %v", err)
+	}
+	if n := len(fleet.Sensors()); n != 3 {
+		t.Errorf("got %d sensors, want 3", n)
+	}
+}
+
+func TestFleet_RefreshErrors(t *testing.T) {
+	good := &fakeGateway{components: componentList(deviceComponent(200, macHT, "H&T", -60, 90, 1))}
+	bad := &fakeGateway{}
+	badClient := bad.client(func(string, json.RawMessage) (string, error) {
+		return "", errors.New("connection refused")
+	})
+
+	fleet := New()
+	fleet.AddGateway("good", good.client(nil))
+	fleet.AddGateway("bad", badClient)
+
+	err := fleet.Refresh(context.Background())
+	if err == nil {
+		t.Fatal("expected error from bad gateway")
+	}
+	if len(fleet.Sensors()) != 1 {
+		t.Error("good gateway should still be refreshed")
+	}
+	if err := fleet.RefreshGateway(context.Background(), "missing"); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("RefreshGateway(missing) error = %v, want ErrUnknownGateway", err)
+	}
+}
+
+func TestFleet_NotificationEvents(t *testing.T) {
+	components := componentList(
+		deviceComponent(200, macDoor, "Front door", -60, 30, 1700000000),
+		sensorComponent(201, macDoor, 0x2D, 0, "false", 1700000000),
+		deviceComponent(202, macBtn, "Button", -70, 80, 1700000000),
+		sensorComponent(203, macBtn, 0x3A, 0, "0", 1700000000),
+		deviceComponent(204, macHT, "Motion", -70, 80, 1700000000),
+		sensorComponent(205, macHT, 0x21, 0, "false", 1700000000),
+	)
+	a := &fakeGateway{components: components}
+	b := &fakeGateway{components: components}
+	clientA, clientB := a.client(nil), b.client(nil)
+
+	bus := events.NewEventBus()
+	defer bus.Close()
+	rec := newRecorder(bus)
+
+	fleet := New(WithEventBus(bus), WithBatteryLowThreshold(20))
+	fleet.AddGateway("a", clientA)
+	fleet.AddGateway("b", clientB)
+	if err := fleet.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("initial refresh published %d events, want 0", len(got))
+	}
+
+	// Window opens, reported by both gateways: one event.
+	route(t, clientA, `{"method":"NotifyStatus","params":{"ts":1700000100,"bthomesensor:201":{"id":201,"value":true,"last_updated_ts":1700000100}}}`)
+	route(t, clientB, `{"method":"NotifyStatus","params":{"ts":1700000100,"bthomesensor:201":{"id":201,"value":true,"last_updated_ts":1700000100}}}`)
+	got := rec.take()
+	if len(got) != 1 {
+		t.Fatalf("window: got %d events, want 1", len(got))
+	}
+	window, ok := got[0].(*events.BLUWindowEvent)
+	if !ok || !window.Open || window.DeviceID() != "7C:C6:B6:00:00:02" || window.Name != "Front door" || window.Gateway != "a" {
+		t.Errorf("window event = %+v", got[0])
+	}
+
+	// Motion detected.
+	route(t, clientB, `{"method":"NotifyStatus","params":{"bthomesensor:205":{"id":205,"value":true}}}`)
+	got = rec.take()
+	if len(got) != 1 || got[0].Type() != events.EventTypeBLUMotion {
+		t.Fatalf("motion: events = %+v", got)
+	}
+	if m := got[0].(*events.BLUMotionEvent); !m.Motion {
+		t.Error("Motion should be true")
+	}
+
+	// Battery drops below the threshold once; further drops are silent.
+	route(t, clientA, `{"method":"NotifyStatus","params":{"bthomedevice:200":{"id":200,"battery":15}}}`)
+	route(t, clientA, `{"method":"NotifyStatus","params":{"bthomedevice:200":{"id":200,"battery":14}}}`)
+	got = rec.take()
+	if len(got) != 1 || got[0].Type() != events.EventTypeBLUBatteryLow {
+		t.Fatalf("battery: events = %+v", got)
+	}
+	if low := got[0].(*events.BLUBatteryLowEvent); low.Battery != 15 {
+		t.Errorf("Battery = %d, want 15", low.Battery)
+	}
+
+	// Button press relayed by both gateways is published once.
+	press := `{"method":"NotifyEvent","params":{"ts":1700000200,"events":[{"component":"bthomesensor:203","id":203,"event":"single_push","ts":1700000200}]}}`
+	route(t, clientA, press)
+	route(t, clientB, press)
+	got = rec.take()
+	if len(got) != 1 {
+		t.Fatalf("button: got %d events, want 1", len(got))
+	}
+	button, ok := got[0].(*events.BLUButtonEvent)
+	if !ok || button.Event != bthome.ButtonPress || button.Name != "Button" || button.Button != 0 {
+		t.Errorf("button event = %+v", got[0])
+	}
+
+	// A different press type is a new press.
+	route(t, clientB, `{"method":"NotifyEvent","params":{"events":[{"component":"bthomesensor:203","id":203,"event":"long_push"}]}}`)
+	got = rec.take()
+	if len(got) != 1 || got[0].(*events.BLUButtonEvent).Event != bthome.ButtonLongPress {
+		t.Errorf("long press events = %+v", got)
+	}
+
+	// Unrelated events and unknown components are ignored.
+	route(t, clientA, `{"method":"NotifyEvent","params":{"events":[{"component":"input:0","id":0,"event":"single_push"},{"component":"bthomesensor:203","id":203,"event":"config_changed"}]}}`)
+	if got := rec.take(); len(got) != 0 {
+		t.Errorf("unexpected events: %+v", got)
+	}
+
+	// Removed gateways stop publishing.
+	fleet.RemoveGateway("a")
+	route(t, clientA, `{"method":"NotifyStatus","params":{"bthomesensor:201":{"id":201,"value":false}}}`)
+	if got := rec.take(); len(got) != 0 {
+		t.Errorf("removed gateway published: %+v", got)
+	}
+}
+
+func TestSensor_SelectGatewayTie(t *testing.T) {
+	rssi := -60
+	s := &Sensor{Sightings: map[string]Sighting{
+		"b": {Gateway: "b", RSSI: &rssi},
+		"a": {Gateway: "a", RSSI: &rssi},
+	}}
+	s.selectGateway(time.Now(), 0)
+	if s.Gateway != "a" {
+		t.Errorf("Gateway = %s, want a", s.Gateway)
+	}
+}
+
+func TestReading_Conversions(t *testing.T) {
+	tests := []struct {
+		value   any
+		name    string
+		wantF   float64
+		wantB   bool
+		wantOkF bool
+		wantOkB bool
+	}{
+		{name: "number", value: 21.5, wantF: 21.5, wantB: true, wantOkF: true, wantOkB: true},
+		{name: "zero", value: 0.0, wantOkF: true, wantOkB: true},
+		{name: "true", value: true, wantF: 1, wantB: true, wantOkF: true, wantOkB: true},
+		{name: "string", value: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Reading{Value: tt.value}
+			f, okF := r.Float()
+			b, okB := r.Bool()
+			if f != tt.wantF || okF != tt.wantOkF || b != tt.wantB || okB != tt.wantOkB {
+				t.Errorf("Float() = %v, %v; Bool() = %v, %v", f, okF, b, okB)
+			}
+		})
+	}
+}
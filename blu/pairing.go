@@ -0,0 +1,273 @@
+package blu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/bthome"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// discoveryGrace is how long Discover waits past the scan duration for
+// the discovery_done event.
+const discoveryGrace = 5 * time.Second
+
+// ErrDiscoveryInProgress is returned when Discover is called for a gateway
+// that is already scanning.
+var ErrDiscoveryInProgress = errors.New("blu: discovery already in progress")
+
+// DiscoveredDevice is a BTHome device found by a gateway scan.
+type DiscoveredDevice struct {
+	// ModelID is the Shelly BLU model ID, if advertised.
+	ModelID *int `json:"model_id,omitempty"`
+
+	// Addr is the device MAC address.
+	Addr string `json:"addr"`
+
+	// LocalName is the advertised name.
+	LocalName string `json:"local_name,omitempty"`
+
+	// Raw is the device object from the device_discovered event.
+	Raw json.RawMessage `json:"-"`
+
+	// RSSI is the signal strength at the scanning gateway in dBm.
+	RSSI int `json:"rssi"`
+
+	// Paired reports whether the device is already added on the gateway.
+	Paired bool `json:"paired"`
+}
+
+// discoverySession collects device_discovered events for one scan.
+type discoverySession struct {
+	devices map[string]DiscoveredDevice
+	done    chan struct{}
+	closed  bool
+}
+
+// Discover scans for BTHome devices through a gateway for the given
+// duration (rounded up to whole seconds, gateway default if zero) and
+// returns the devices found, strongest signal first.
+//
+// The gateway's client must deliver notifications since results arrive as
+// device_discovered events. Discover returns early on discovery_done or
+// when ctx is canceled, with the devices seen so far.
+func (f *Fleet) Discover(ctx context.Context, gatewayID string, duration time.Duration) ([]DiscoveredDevice, error) {
+	gw, err := f.gateway(gatewayID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &discoverySession{
+		devices: make(map[string]DiscoveredDevice),
+		done:    make(chan struct{}),
+	}
+	f.mu.Lock()
+	if gw.discovery != nil {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrDiscoveryInProgress, gatewayID)
+	}
+	gw.discovery = session
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		gw.discovery = nil
+		f.mu.Unlock()
+	}()
+
+	var seconds *int
+	wait := 30 * time.Second
+	if duration > 0 {
+		s := int((duration + time.Second - 1) / time.Second)
+		seconds = &s
+		wait = time.Duration(s) * time.Second
+	}
+	if err := components.NewBTHome(gw.client).StartDeviceDiscovery(ctx, seconds); err != nil {
+		return nil, fmt.Errorf("failed to start discovery: %w", err)
+	}
+
+	timer := time.NewTimer(wait + discoveryGrace)
+	defer timer.Stop()
+	select {
+	case <-session.done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	f.mu.Lock()
+	found := make([]DiscoveredDevice, 0, len(session.devices))
+	for _, d := range session.devices {
+		_, d.Paired = gw.deviceRef(d.Addr)
+		found = append(found, d)
+	}
+	f.mu.Unlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].RSSI != found[j].RSSI {
+			return found[i].RSSI > found[j].RSSI
+		}
+		return found[i].Addr < found[j].Addr
+	})
+	return found, nil
+}
+
+// discoveryEventLocked records discovery events for a running scan.
+func (f *Fleet) discoveryEventLocked(gw *gateway, e *notifyEvent) {
+	session := gw.discovery
+	if session == nil || session.closed {
+		return
+	}
+
+	switch e.Event {
+	case "discovery_done":
+		session.closed = true
+		close(session.done)
+	case "device_discovered":
+		d, ok := parseDiscoveredDevice(e)
+		if !ok {
+			return
+		}
+		if prev, seen := session.devices[d.Addr]; !seen || d.RSSI > prev.RSSI {
+			session.devices[d.Addr] = d
+		}
+	}
+}
+
+// parseDiscoveredDevice reads the device object from a device_discovered
+// event, which gateways send either at the top level or inside data.
+func parseDiscoveredDevice(e *notifyEvent) (DiscoveredDevice, bool) {
+	raw := e.Device
+	if len(raw) == 0 && len(e.Data) > 0 {
+		var data struct {
+			Device json.RawMessage `json:"device"`
+		}
+		if json.Unmarshal(e.Data, &data) == nil && len(data.Device) > 0 {
+			raw = data.Device
+		} else {
+			raw = e.Data
+		}
+	}
+
+	var d DiscoveredDevice
+	if json.Unmarshal(raw, &d) != nil {
+		return DiscoveredDevice{}, false
+	}
+	mac, err := bthome.NormalizeMAC(d.Addr)
+	if err != nil {
+		return DiscoveredDevice{}, false
+	}
+	d.Addr = mac
+	d.Raw = raw
+	return d, true
+}
+
+// deviceRef returns the BTHomeDevice component for a MAC address.
+func (gw *gateway) deviceRef(mac string) (componentRef, bool) {
+	for _, ref := range gw.refs {
+		if ref.device && ref.mac == mac {
+			return ref, true
+		}
+	}
+	return componentRef{}, false
+}
+
+// PairOptions configures Pair.
+type PairOptions struct {
+	// Name is the device name stored on the gateway.
+	Name string
+
+	// Key is the hex bindkey for encrypted devices.
+	Key string
+
+	// AddSensors creates a BTHomeSensor component for every object the
+	// gateway has received from the device and no component manages yet.
+	AddSensors bool
+}
+
+// Pair adds a BLU device to a gateway with BTHome.AddDevice, optionally
+// adds its sensors, and refreshes the gateway. It returns the resulting
+// sensor.
+//
+// With AddSensors the gateway must already have received an
+// advertisement from the device, e.g. during Discover; otherwise only the
+// device component is created.
+func (f *Fleet) Pair(ctx context.Context, gatewayID, mac string, opts *PairOptions) (*Sensor, error) {
+	gw, err := f.gateway(gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := bthome.NormalizeMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &PairOptions{}
+	}
+
+	bt := components.NewBTHome(gw.client)
+	config := &components.BTHomeAddDeviceConfig{Addr: strings.ToLower(addr)}
+	if opts.Name != "" {
+		config.Name = &opts.Name
+	}
+	if opts.Key != "" {
+		config.Key = &opts.Key
+	}
+	resp, err := bt.AddDevice(ctx, config, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add device: %w", err)
+	}
+
+	if opts.AddSensors {
+		if err := addSensors(ctx, gw, bt, resp.Key, config.Addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.refreshGateway(ctx, gw); err != nil {
+		return nil, err
+	}
+	s, ok := f.Sensor(addr)
+	if !ok {
+		return nil, fmt.Errorf("device %s not listed by gateway after pairing", addr)
+	}
+	return s, nil
+}
+
+// addSensors creates sensor components for the unmanaged known objects of
+// the device component with the given key.
+func addSensors(ctx context.Context, gw *gateway, bt *components.BTHome, key, addr string) error {
+	_, idText, _ := strings.Cut(key, ":")
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		return fmt.Errorf("unexpected component key %q: %w", key, err)
+	}
+
+	known, err := components.NewBTHomeDevice(gw.client, id).GetKnownObjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get known objects: %w", err)
+	}
+	for _, obj := range known.Objects {
+		if obj.Component != nil {
+			continue
+		}
+		// Packet IDs and firmware versions are not sensor values.
+		if info, ok := lookupObject(obj.ObjID); ok && info.Kind == bthome.KindDevice {
+			continue
+		}
+		_, err := bt.AddSensor(ctx, &components.BTHomeAddSensorConfig{
+			Addr:  addr,
+			ObjID: obj.ObjID,
+			Idx:   obj.Idx,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to add sensor for object %d: %w", obj.ObjID, err)
+		}
+	}
+	return nil
+}
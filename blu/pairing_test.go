@@ -0,0 +1,181 @@
+package blu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+func TestFleet_Discover(t *testing.T) {
+	gw := &fakeGateway{components: componentList(deviceComponent(200, macHT, "H&T", -60, 90, 1))}
+
+	var client *rpc.Client
+	started := make(chan json.RawMessage, 1)
+	client = gw.client(func(method string, params json.RawMessage) (string, error) {
+		if method == "BTHome.StartDeviceDiscovery" {
+			started <- params
+		}
+		return "", nil
+	})
+
+	fleet := New()
+	fleet.AddGateway("gw", client)
+	if err := fleet.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-started
+		route(t, client, `{"method":"NotifyEvent","params":{"events":[
+			{"component":"bthome","event":"device_discovered","device":{"addr":"7c:c6:b6:00:00:09","rssi":-70,"local_name":"SBBT-002C","model_id":1}},
+			{"component":"bthome","event":"device_discovered","device":{"addr":"7c:c6:b6:00:00:09","rssi":-65,"local_name":"SBBT-002C","model_id":1}},
+			{"component":"bthome","event":"device_discovered","data":{"device":{"addr":"7c:c6:b6:00:00:01","rssi":-50}}},
+			{"component":"bthome","event":"device_discovered","device":{"addr":"bogus","rssi":-40}}
+		]}}`)
+		route(t, client, `{"method":"NotifyEvent","params":{"events":[{"component":"bthome","event":"discovery_done","data":{"device_count":2}}]}}`)
+	}()
+
+	found, err := fleet.Discover(context.Background(), "gw", 1500*time.Millisecond)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("found %d devices, want 2: %+v", len(found), found)
+	}
+	if found[0].Addr != "7C:C6:B6:00:00:01" || !found[0].Paired {
+		t.Errorf("found[0] = %+v, want paired H&T first", found[0])
+	}
+	if found[1].RSSI != -65 || found[1].LocalName != "SBBT-002C" || found[1].ModelID == nil || found[1].Paired {
+		t.Errorf("found[1] = %+v", found[1])
+	}
+
+	// Duration is rounded up to whole seconds.
+	var params struct {
+		Duration int `json:"duration"`
+	}
+	for i, m := range gw.calls {
+		if m == "BTHome.StartDeviceDiscovery" {
+			_ = json.Unmarshal(gw.params[i], &params)
+		}
+	}
+	if params.Duration != 2 {
+		t.Errorf("duration = %d, want 2", params.Duration)
+	}
+}
+
+func TestFleet_DiscoverCanceled(t *testing.T) {
+	gw := &fakeGateway{components: componentList()}
+	fleet := New()
+	fleet.AddGateway("gw", gw.client(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	found, err := fleet.Discover(ctx, "gw", time.Minute)
+	if err != nil || len(found) != 0 {
+		t.Errorf("Discover() = %v, %v", found, err)
+	}
+
+	if _, err := fleet.Discover(context.Background(), "missing", 0); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("Discover(missing) error = %v, want ErrUnknownGateway", err)
+	}
+}
+
+func TestFleet_Pair(t *testing.T) {
+	gw := &fakeGateway{components: componentList()}
+	var sensorsAdded []map[string]any
+	client := gw.client(func(method string, params json.RawMessage) (string, error) {
+		switch method {
+		case "BTHome.AddDevice":
+			gw.components = componentList(
+				deviceComponent(200, "7c:c6:b6:00:00:09", "Garage", -62, 100, 1),
+				sensorComponent(201, "7c:c6:b6:00:00:09", 0x2D, 0, "false", 1),
+			)
+			return `{"key":"bthomedevice:200"}`, nil
+		case "BTHomeDevice.GetKnownObjects":
+			return `{"id":200,"objects":[
+				{"obj_id":0,"idx":0,"component":null},
+				{"obj_id":1,"idx":0,"component":null},
+				{"obj_id":45,"idx":0,"component":null},
+				{"obj_id":58,"idx":0,"component":"bthomesensor:205"}
+			]}`, nil
+		case "BTHome.AddSensor":
+			var p struct {
+				Config map[string]any `json:"config"`
+			}
+			_ = json.Unmarshal(params, &p)
+			sensorsAdded = append(sensorsAdded, p.Config)
+			return `{"key":"bthomesensor:201"}`, nil
+		}
+		return "", nil
+	})
+
+	fleet := New()
+	fleet.AddGateway("garage", client)
+
+	sensor, err := fleet.Pair(context.Background(), "garage", "7C-C6-B6-00-00-09", &PairOptions{
+		Name:       "Garage",
+		Key:        "231d39c1d7cc1ab1aee224cd096db932",
+		AddSensors: true,
+	})
+	if err != nil {
+		t.Fatalf("Pair() error = %v", err)
+	}
+	if sensor.Name != "Garage" || sensor.Gateway != "garage" {
+		t.Errorf("sensor = %+v", sensor)
+	}
+
+	var add struct {
+		Config struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+			Addr string `json:"addr"`
+		} `json:"config"`
+	}
+	for i, m := range gw.calls {
+		if m == "BTHome.AddDevice" {
+			_ = json.Unmarshal(gw.params[i], &add)
+		}
+	}
+	if add.Config.Addr != "7c:c6:b6:00:00:09" || add.Config.Name != "Garage" || add.Config.Key == "" {
+		t.Errorf("AddDevice config = %+v", add.Config)
+	}
+
+	// Packet ID is skipped, the managed button object too.
+	if len(sensorsAdded) != 2 {
+		t.Fatalf("added %d sensors, want 2: %+v", len(sensorsAdded), sensorsAdded)
+	}
+	if sensorsAdded[0]["obj_id"] != 1.0 || sensorsAdded[1]["obj_id"] != 45.0 {
+		t.Errorf("sensors added = %+v", sensorsAdded)
+	}
+}
+
+func TestFleet_PairErrors(t *testing.T) {
+	gw := &fakeGateway{}
+	client := gw.client(func(method string, _ json.RawMessage) (string, error) {
+		if method == "BTHome.AddDevice" {
+			return "", errors.New("device exists")
+		}
+		return "", nil
+	})
+	fleet := New()
+	fleet.AddGateway("gw", client)
+
+	if _, err := fleet.Pair(context.Background(), "gw", "not-a-mac", nil); err == nil {
+		t.Error("expected error for invalid MAC")
+	}
+	if _, err := fleet.Pair(context.Background(), "gw", macHT, nil); err == nil {
+		t.Error("expected AddDevice error")
+	}
+	if _, err := fleet.Pair(context.Background(), "missing", macHT, nil); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("error = %v, want ErrUnknownGateway", err)
+	}
+}
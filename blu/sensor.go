@@ -0,0 +1,198 @@
+package blu
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reading is the latest value of one BTHomeSensor component.
+type Reading struct {
+	// Updated is when the gateway last received the value.
+	Updated time.Time `json:"updated"`
+
+	// Value is the value as reported by the gateway: float64 for sensor
+	// values, bool for binary sensors.
+	Value any `json:"value"`
+
+	// Name is the BTHome object name (see the bthome package Name
+	// constants), or "obj_<id>" for objects unknown to this library.
+	Name string `json:"name"`
+
+	// Unit is the measurement unit, if any.
+	Unit string `json:"unit,omitempty"`
+
+	// Gateway is the ID of the gateway that reported the value.
+	Gateway string `json:"gateway"`
+
+	// Component is the BTHomeSensor component key on the gateway.
+	Component string `json:"component"`
+
+	// ObjID is the BTHome object ID.
+	ObjID int `json:"obj_id"`
+
+	// Idx is the object instance for devices with several objects of the
+	// same type.
+	Idx int `json:"idx"`
+}
+
+// Float returns the value as a number. Binary values convert to 0 or 1.
+func (r Reading) Float() (float64, bool) {
+	switch v := r.Value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Bool returns the value as a boolean. Numbers are true when non-zero.
+func (r Reading) Bool() (bool, bool) {
+	switch v := r.Value.(type) {
+	case bool:
+		return v, true
+	case float64:
+		return v != 0, true
+	default:
+		return false, false
+	}
+}
+
+// Sighting records one gateway's view of a BLU device.
+type Sighting struct {
+	// Updated is when the gateway last received an advertisement.
+	Updated time.Time `json:"updated"`
+
+	// RSSI is the signal strength at the gateway in dBm.
+	RSSI *int `json:"rssi,omitempty"`
+
+	// Gateway is the gateway ID.
+	Gateway string `json:"gateway"`
+
+	// Component is the BTHomeDevice component key on the gateway.
+	Component string `json:"component"`
+}
+
+// Sensor is one BLU device with its readings merged across gateways.
+type Sensor struct {
+	// Updated is the most recent update from any gateway.
+	Updated time.Time `json:"updated"`
+
+	// Readings maps reading keys (see ReadingKey) to the latest value.
+	Readings map[string]Reading `json:"readings"`
+
+	// Sightings maps gateway IDs to that gateway's view of the device.
+	Sightings map[string]Sighting `json:"sightings"`
+
+	// Battery is the battery level in percent, if reported.
+	Battery *int `json:"battery,omitempty"`
+
+	// RSSI is the signal strength at the selected gateway.
+	RSSI *int `json:"rssi,omitempty"`
+
+	// MAC is the device address in upper-case colon-separated form.
+	MAC string `json:"mac"`
+
+	// Name is the device name configured on a gateway.
+	Name string `json:"name,omitempty"`
+
+	// Gateway is the selected gateway: the one with the strongest signal
+	// among recent sightings.
+	Gateway string `json:"gateway"`
+
+	// batteryLow is set once a battery low event was published, and
+	// cleared when the battery recovers.
+	batteryLow bool
+}
+
+// ReadingKey returns the Readings key for an object: its name for the
+// first instance and "name:idx" for further instances.
+func ReadingKey(name string, idx int) string {
+	if idx == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s:%d", name, idx)
+}
+
+// Reading returns the first instance of the named object.
+func (s *Sensor) Reading(name string) (Reading, bool) {
+	r, ok := s.Readings[name]
+	return r, ok
+}
+
+// Float returns the numeric value of the first instance of the named object.
+func (s *Sensor) Float(name string) (float64, bool) {
+	r, ok := s.Readings[name]
+	if !ok {
+		return 0, false
+	}
+	return r.Float()
+}
+
+// Bool returns the boolean value of the first instance of the named object.
+func (s *Sensor) Bool(name string) (bool, bool) {
+	r, ok := s.Readings[name]
+	if !ok {
+		return false, false
+	}
+	return r.Bool()
+}
+
+// clone returns a deep copy safe to hand out to callers.
+func (s *Sensor) clone() *Sensor {
+	c := *s
+	c.Readings = make(map[string]Reading, len(s.Readings))
+	for k, v := range s.Readings {
+		c.Readings[k] = v
+	}
+	c.Sightings = make(map[string]Sighting, len(s.Sightings))
+	for k, v := range s.Sightings {
+		if v.RSSI != nil {
+			rssi := *v.RSSI
+			v.RSSI = &rssi
+		}
+		c.Sightings[k] = v
+	}
+	if s.Battery != nil {
+		battery := *s.Battery
+		c.Battery = &battery
+	}
+	if s.RSSI != nil {
+		rssi := *s.RSSI
+		c.RSSI = &rssi
+	}
+	return &c
+}
+
+// selectGateway picks the gateway with the strongest signal among
+// sightings newer than staleAfter, falling back to the most recent one.
+func (s *Sensor) selectGateway(now time.Time, staleAfter time.Duration) {
+	var best, newest *Sighting
+	for gw := range s.Sightings {
+		sighting := s.Sightings[gw]
+		if newest == nil || sighting.Updated.After(newest.Updated) ||
+			(sighting.Updated.Equal(newest.Updated) && sighting.Gateway < newest.Gateway) {
+			newest = &sighting
+		}
+		if sighting.RSSI == nil || (staleAfter > 0 && now.Sub(sighting.Updated) > staleAfter) {
+			continue
+		}
+		if best == nil || *sighting.RSSI > *best.RSSI ||
+			(*sighting.RSSI == *best.RSSI && sighting.Gateway < best.Gateway) {
+			best = &sighting
+		}
+	}
+
+	if best == nil {
+		best = newest
+	}
+	if best == nil {
+		s.Gateway, s.RSSI = "", nil
+		return
+	}
+	s.Gateway, s.RSSI = best.Gateway, best.RSSI
+}
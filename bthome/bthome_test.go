@@ -0,0 +1,153 @@
+package bthome
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestParse_Objects(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		obj   string
+		unit  string
+		kind  Kind
+		value float64
+	}{
+		{name: "battery", data: "40 01 61", obj: NameBattery, unit: "%", value: 97},
+		{name: "temperature 0.01", data: "40 02 ca 09", obj: NameTemperature, unit: "°C", value: 25.06},
+		{name: "negative temperature", data: "40 02 18 fc", obj: NameTemperature, unit: "°C", value: -10},
+		{name: "temperature 0.1", data: "40 45 11 01", obj: NameTemperature, unit: "°C", value: 27.3},
+		{name: "temperature sint8 0.35", data: "40 58 c8", obj: NameTemperature, unit: "°C", value: -19.6},
+		{name: "humidity", data: "40 03 bf 13", obj: NameHumidity, unit: "%", value: 50.55},
+		{name: "pressure", data: "40 04 13 8a 01", obj: NamePressure, unit: "hPa", value: 1008.83},
+		{name: "illuminance", data: "40 05 13 8a 14", obj: NameIlluminance, unit: "lx", value: 13460.67},
+		{name: "power sint32", data: "40 5c 02 fe ff ff", obj: NamePower, unit: "W", value: -5.1},
+		{name: "energy", data: "40 4d 12 13 8a 14", obj: NameEnergy, unit: "kWh", value: 344593.17},
+		{name: "voltage", data: "40 0c 02 0c", obj: NameVoltage, unit: "V", value: 3.074},
+		{name: "uv index", data: "40 46 32", obj: NameUVIndex, value: 5},
+		{name: "count sint16", data: "40 5a 0c fc", obj: NameCount, value: -1012},
+		{name: "rotation", data: "40 3f 02 0c", obj: NameRotation, unit: "°", value: 307.4},
+		{name: "window open", data: "40 2d 01", obj: NameWindow, kind: KindBinary, value: 1},
+		{name: "motion clear", data: "40 21 00", obj: NameMotion, kind: KindBinary, value: 0},
+		{name: "door", data: "40 1a 01", obj: NameDoor, kind: KindBinary, value: 1},
+		{name: "device type", data: "40 f0 01 00", obj: NameDeviceTypeID, kind: KindDevice, value: 1},
+		{name: "firmware 24 bit", data: "40 f2 00 01 01", obj: NameFirmwareVersion, kind: KindDevice, value: 65792},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(mustHex(t, tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			o, ok := p.Get(tt.obj)
+			if !ok {
+				t.Fatalf("object %s not found in %+v", tt.obj, p.Objects)
+			}
+			if o.Value != tt.value || o.Unit != tt.unit || o.Kind != tt.kind {
+				t.Errorf("object = %+v, want value=%v unit=%q kind=%s", o, tt.value, tt.unit, tt.kind)
+			}
+		})
+	}
+}
+
+func TestParse_EventsTextAndInstances(t *testing.T) {
+	// packet id, two temperatures, button press, button none, dimmer right 3
+	// steps, text "Hi", raw 0xbeef
+	data := mustHex(t, "44 00 07 02 ca 09 02 e8 03 3a 01 3a 00 3c 02 03 53 02 48 69 54 02 be ef")
+	p, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !p.TriggerBased || p.Encrypted || p.Version != 2 {
+		t.Errorf("header = %+v", p)
+	}
+	if p.PacketID == nil || *p.PacketID != 7 {
+		t.Errorf("PacketID = %v, want 7", p.PacketID)
+	}
+
+	temps := p.All(NameTemperature)
+	if len(temps) != 2 || temps[0].Value != 25.06 || temps[1].Value != 10 || temps[1].Index != 1 {
+		t.Errorf("temperatures = %+v", temps)
+	}
+
+	buttons := p.All(NameButton)
+	if len(buttons) != 2 || buttons[0].Event != ButtonPress || buttons[1].Event != ButtonNone {
+		t.Errorf("buttons = %+v", buttons)
+	}
+
+	events := p.Events()
+	if len(events) != 2 || events[1].Event != DimmerRotateRight || events[1].Value != 3 {
+		t.Errorf("events = %+v", events)
+	}
+
+	if text, _ := p.Get(NameText); text.Text != "Hi" {
+		t.Errorf("text = %+v", text)
+	}
+	if raw, _ := p.Get(NameRaw); hex.EncodeToString(raw.Bytes) != "beef" {
+		t.Errorf("raw = %+v", raw)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		want error
+		name string
+		data string
+		objs int
+	}{
+		{name: "empty", data: "", want: ErrEmpty},
+		{name: "version 1", data: "20 01 64", want: ErrUnsupportedVersion},
+		{name: "encrypted", data: "41 a4 72 66", want: ErrEncrypted},
+		{name: "unknown object stops", data: "40 01 64 ff 00 01 64", want: ErrUnknownObject, objs: 1},
+		{name: "truncated", data: "40 01 64 02 e8", want: ErrTruncated, objs: 1},
+		{name: "truncated text length", data: "40 53", want: ErrTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(mustHex(t, tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.want)
+			}
+			if tt.objs > 0 && len(p.Objects) != tt.objs {
+				t.Errorf("got %d objects, want %d", len(p.Objects), tt.objs)
+			}
+		})
+	}
+}
+
+func TestKind_String(t *testing.T) {
+	if KindBinary.String() != "binary" || Kind(99).String() != "unknown" {
+		t.Error("unexpected Kind strings")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	info, ok := Lookup(0x2D)
+	if !ok || info.Name != NameWindow || info.Kind != KindBinary || info.ID != 0x2D {
+		t.Errorf("Lookup(0x2D) = %+v, %v", info, ok)
+	}
+	if info, _ := Lookup(0x02); info.Unit != "°C" {
+		t.Errorf("Lookup(0x02).Unit = %q", info.Unit)
+	}
+	if _, ok := Lookup(0xFF); ok {
+		t.Error("Lookup(0xFF) should fail")
+	}
+	if name, ok := ButtonEventName(0x04); !ok || name != ButtonLongPress {
+		t.Errorf("ButtonEventName(4) = %q, %v", name, ok)
+	}
+}
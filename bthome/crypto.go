@@ -0,0 +1,183 @@
+package bthome
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encryption parameters fixed by the BTHome v2 format.
+const (
+	// KeySize is the bindkey length in bytes (AES-128).
+	KeySize = 16
+
+	counterSize = 4
+	micSize     = 4
+	nonceSize   = 13
+)
+
+// serviceUUID is the BTHome service UUID in little-endian byte order,
+// as it appears in the advertisement and the nonce.
+var serviceUUID = []byte{0xD2, 0xFC}
+
+// Encryption errors.
+var (
+	// ErrInvalidKey indicates a bindkey that is not 16 bytes.
+	ErrInvalidKey = errors.New("bthome: bindkey must be 16 bytes")
+
+	// ErrInvalidMAC indicates an address that is not six hex octets.
+	ErrInvalidMAC = errors.New("bthome: invalid MAC address")
+
+	// ErrAuthFailed indicates a payload that fails the AES-CCM integrity
+	// check: a wrong bindkey or a corrupted or forged packet.
+	ErrAuthFailed = errors.New("bthome: message authentication failed")
+)
+
+// ParseMAC converts "AA:BB:CC:DD:EE:FF", "aa-bb-..." or "aabbccddeeff"
+// into six bytes in display order.
+func ParseMAC(mac string) ([]byte, error) {
+	clean := strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac)
+	b, err := hex.DecodeString(clean)
+	if err != nil || len(b) != 6 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMAC, mac)
+	}
+	return b, nil
+}
+
+// NormalizeMAC formats a MAC address as upper-case colon-separated octets.
+func NormalizeMAC(mac string) (string, error) {
+	b, err := ParseMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(b))
+	for i, octet := range b {
+		parts[i] = fmt.Sprintf("%02X", octet)
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// Decrypt decrypts encrypted BTHome v2 service data.
+//
+// data is the full service data (device information byte, ciphertext,
+// 4-byte counter, 4-byte MIC). The returned plaintext is the object list
+// without the device information byte.
+func Decrypt(data []byte, mac string, key []byte) (plaintext []byte, counter uint32, err error) {
+	if len(data) < 1+counterSize+micSize {
+		return nil, 0, fmt.Errorf("%w: encrypted payload too short", ErrTruncated)
+	}
+	macBytes, err := ParseMAC(mac)
+	if err != nil {
+		return nil, 0, err
+	}
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n := len(data)
+	ciphertext := data[1 : n-counterSize-micSize]
+	counterBytes := data[n-counterSize-micSize : n-micSize]
+	mic := data[n-micSize:]
+	nonce := buildNonce(macBytes, data[0], counterBytes)
+
+	plaintext = ccmCrypt(block, nonce, ciphertext)
+	expected := ccmTag(block, nonce, plaintext)
+	if subtle.ConstantTimeCompare(expected, mic) != 1 {
+		return nil, 0, ErrAuthFailed
+	}
+	return plaintext, binary.LittleEndian.Uint32(counterBytes), nil
+}
+
+// Encrypt builds encrypted BTHome v2 service data from a plaintext object
+// list. The encryption flag is set on info. It is the inverse of Decrypt
+// and is useful for emulating devices and for tests.
+func Encrypt(objects []byte, info byte, mac string, key []byte, counter uint32) ([]byte, error) {
+	macBytes, err := ParseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info |= flagEncrypted
+	counterBytes := make([]byte, counterSize)
+	binary.LittleEndian.PutUint32(counterBytes, counter)
+	nonce := buildNonce(macBytes, info, counterBytes)
+
+	out := make([]byte, 0, 1+len(objects)+counterSize+micSize)
+	out = append(out, info)
+	out = append(out, ccmCrypt(block, nonce, objects)...)
+	out = append(out, counterBytes...)
+	out = append(out, ccmTag(block, nonce, objects)...)
+	return out, nil
+}
+
+func newCipher(key []byte) (cipher.Block, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return aes.NewCipher(key)
+}
+
+// buildNonce assembles MAC (6) + UUID (2) + device info (1) + counter (4).
+func buildNonce(mac []byte, info byte, counter []byte) []byte {
+	nonce := make([]byte, 0, nonceSize)
+	nonce = append(nonce, mac...)
+	nonce = append(nonce, serviceUUID...)
+	nonce = append(nonce, info)
+	nonce = append(nonce, counter...)
+	return nonce
+}
+
+// AES-CCM (RFC 3610) with a 13-byte nonce, 2-byte length field, 4-byte
+// tag and no associated data. The standard library has no CCM mode, and
+// BTHome only needs this fixed parameter set.
+
+// ccmCrypt encrypts or decrypts with CTR mode starting at counter 1.
+func ccmCrypt(block cipher.Block, nonce, in []byte) []byte {
+	out := make([]byte, len(in))
+	ctr := ccmCounterBlock(nonce, 1)
+	cipher.NewCTR(block, ctr).XORKeyStream(out, in)
+	return out
+}
+
+// ccmTag computes the CBC-MAC over the plaintext, encrypted with counter 0.
+func ccmTag(block cipher.Block, nonce, plaintext []byte) []byte {
+	const lengthSize = 2
+	var b0 [aes.BlockSize]byte
+	b0[0] = byte(((micSize-2)/2)<<3 | (lengthSize - 1))
+	copy(b0[1:], nonce)
+	binary.BigEndian.PutUint16(b0[aes.BlockSize-lengthSize:], uint16(len(plaintext)))
+
+	mac := make([]byte, aes.BlockSize)
+	block.Encrypt(mac, b0[:])
+	for i := 0; i < len(plaintext); i += aes.BlockSize {
+		end := min(i+aes.BlockSize, len(plaintext))
+		subtle.XORBytes(mac, mac, append(plaintext[i:end:end], make([]byte, aes.BlockSize-(end-i))...))
+		block.Encrypt(mac, mac)
+	}
+
+	s0 := make([]byte, aes.BlockSize)
+	block.Encrypt(s0, ccmCounterBlock(nonce, 0))
+	tag := make([]byte, micSize)
+	subtle.XORBytes(tag, mac[:micSize], s0[:micSize])
+	return tag
+}
+
+// ccmCounterBlock returns the CTR block A_i for the nonce.
+func ccmCounterBlock(nonce []byte, i uint16) []byte {
+	const lengthSize = 2
+	a := make([]byte, aes.BlockSize)
+	a[0] = lengthSize - 1
+	copy(a[1:], nonce)
+	binary.BigEndian.PutUint16(a[aes.BlockSize-lengthSize:], i)
+	return a
+}
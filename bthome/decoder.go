@@ -0,0 +1,271 @@
+package bthome
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Replay errors. The decoded packet is returned alongside them so callers
+// can still inspect it.
+var (
+	// ErrReplay indicates an encrypted packet whose counter is lower than
+	// the last accepted counter for the device.
+	ErrReplay = errors.New("bthome: replayed packet")
+
+	// ErrDuplicate indicates a packet repeating the previous encryption
+	// counter or packet ID. BLU devices send each packet several times, so
+	// this is normal and usually just means "already handled".
+	ErrDuplicate = errors.New("bthome: duplicate packet")
+
+	// ErrNoKey indicates an encrypted packet from a device with no bindkey.
+	ErrNoKey = errors.New("bthome: no bindkey for device")
+)
+
+// KeyStore looks up bindkeys by MAC address.
+type KeyStore interface {
+	// Key returns the 16-byte bindkey for mac, in NormalizeMAC form.
+	Key(mac string) ([]byte, bool)
+}
+
+// MemoryKeyStore is an in-memory KeyStore safe for concurrent use.
+type MemoryKeyStore struct {
+	keys map[string][]byte
+	mu   sync.RWMutex
+}
+
+// NewMemoryKeyStore creates an empty key store.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string][]byte)}
+}
+
+// Key implements KeyStore.
+func (s *MemoryKeyStore) Key(mac string) ([]byte, bool) {
+	norm, err := NormalizeMAC(mac)
+	if err != nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[norm]
+	return key, ok
+}
+
+// Set stores the bindkey for mac.
+func (s *MemoryKeyStore) Set(mac string, key []byte) error {
+	norm, err := NormalizeMAC(mac)
+	if err != nil {
+		return err
+	}
+	if len(key) != KeySize {
+		return ErrInvalidKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[norm] = append([]byte(nil), key...)
+	return nil
+}
+
+// SetHex stores a bindkey given as 32 hex characters, the format shown in
+// the Shelly app and returned by BTHomeDevice.GetKnownObjects.
+func (s *MemoryKeyStore) SetHex(mac, key string) error {
+	b, err := hex.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	return s.Set(mac, b)
+}
+
+// Delete removes the bindkey for mac.
+func (s *MemoryKeyStore) Delete(mac string) {
+	norm, err := NormalizeMAC(mac)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, norm)
+}
+
+// Load reads a JSON object mapping MAC addresses to hex bindkeys:
+//
+//	{"7C:C6:B6:AA:BB:CC": "231d39c1d7cc1ab1aee224cd096db932"}
+func (s *MemoryKeyStore) Load(r io.Reader) error {
+	var keys map[string]string
+	if err := json.NewDecoder(r).Decode(&keys); err != nil {
+		return fmt.Errorf("failed to parse bindkeys: %w", err)
+	}
+	for mac, key := range keys {
+		if err := s.SetHex(mac, key); err != nil {
+			return fmt.Errorf("bindkey for %s: %w", mac, err)
+		}
+	}
+	return nil
+}
+
+// deviceState is the replay tracking state for one device.
+type deviceState struct {
+	counter    uint32
+	packetID   uint8
+	hasCounter bool
+	hasPID     bool
+}
+
+// Decoder decodes BTHome advertisements from many devices, decrypting
+// with bindkeys from a KeyStore and tracking packet counters per MAC.
+//
+// A Decoder is safe for concurrent use.
+type Decoder struct {
+	keys    KeyStore
+	devices map[string]*deviceState
+	mu      sync.Mutex
+}
+
+// NewDecoder creates a decoder. keys may be nil if no device is encrypted.
+func NewDecoder(keys KeyStore) *Decoder {
+	return &Decoder{
+		keys:    keys,
+		devices: make(map[string]*deviceState),
+	}
+}
+
+// Decode decodes service data from the device with the given MAC.
+//
+// Encrypted payloads are decrypted with the device's bindkey. Packets
+// with an older counter return ErrReplay and repeated counters or packet
+// IDs return ErrDuplicate; in both cases the decoded packet is also returned.
+// Replay state only advances for packets that decode and authenticate.
+func (d *Decoder) Decode(mac string, data []byte) (*Packet, error) {
+	norm, err := NormalizeMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	p.MAC = norm
+
+	objects := data[1:]
+	if p.Encrypted {
+		key, ok := d.key(norm)
+		if !ok {
+			return p, fmt.Errorf("%w: %s", ErrNoKey, norm)
+		}
+		plaintext, counter, err := Decrypt(data, norm, key)
+		if err != nil {
+			return p, err
+		}
+		objects, p.Counter = plaintext, counter
+	}
+
+	if err := p.decodeObjects(objects); err != nil {
+		return p, err
+	}
+	return p, d.track(p)
+}
+
+// DecodeServiceData finds BTHome data in a service data map keyed by
+// UUID ("fcd2", "FCD2" or the full 128-bit form) and decodes it.
+func (d *Decoder) DecodeServiceData(mac string, serviceData map[string][]byte) (*Packet, error) {
+	data, ok := FindServiceData(serviceData)
+	if !ok {
+		return nil, ErrEmpty
+	}
+	return d.Decode(mac, data)
+}
+
+// DecodeAdvertisement extracts BTHome service data from raw advertising
+// data and decodes it. Gateways relay raw advertisements this way, e.g.
+// the advData field of BLE.Scanner results.
+func (d *Decoder) DecodeAdvertisement(mac string, adv []byte) (*Packet, error) {
+	data, ok := ServiceDataFromAdvertisement(adv)
+	if !ok {
+		return nil, ErrEmpty
+	}
+	return d.Decode(mac, data)
+}
+
+// Forget clears the replay state for mac, e.g. after a device was reset
+// and restarted its counter.
+func (d *Decoder) Forget(mac string) {
+	norm, err := NormalizeMAC(mac)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.devices, norm)
+}
+
+func (d *Decoder) key(mac string) ([]byte, bool) {
+	if d.keys == nil {
+		return nil, false
+	}
+	return d.keys.Key(mac)
+}
+
+// track updates replay state and reports replays and duplicates.
+func (d *Decoder) track(p *Packet) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.devices[p.MAC]
+	if !ok {
+		state = &deviceState{}
+		d.devices[p.MAC] = state
+	}
+
+	if p.Encrypted {
+		switch {
+		case state.hasCounter && p.Counter == state.counter:
+			return fmt.Errorf("%w: counter %d", ErrDuplicate, p.Counter)
+		case state.hasCounter && p.Counter < state.counter:
+			return fmt.Errorf("%w: counter %d < %d", ErrReplay, p.Counter, state.counter)
+		}
+		state.counter, state.hasCounter = p.Counter, true
+	}
+
+	if p.PacketID != nil {
+		if state.hasPID && *p.PacketID == state.packetID {
+			return fmt.Errorf("%w: packet id %d", ErrDuplicate, *p.PacketID)
+		}
+		state.packetID, state.hasPID = *p.PacketID, true
+	}
+	return nil
+}
+
+// FindServiceData returns the BTHome entry of a service data map.
+func FindServiceData(serviceData map[string][]byte) ([]byte, bool) {
+	for uuid, data := range serviceData {
+		u := strings.ToLower(uuid)
+		if u == "fcd2" || u == "0xfcd2" || strings.HasPrefix(u, "0000fcd2-") {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// ServiceDataFromAdvertisement scans raw advertising data (length, type,
+// value structures) for 16-bit UUID service data with UUID 0xFCD2.
+func ServiceDataFromAdvertisement(adv []byte) ([]byte, bool) {
+	const adTypeServiceData16 = 0x16
+	for i := 0; i < len(adv); {
+		length := int(adv[i])
+		if length == 0 || i+1+length > len(adv) {
+			return nil, false
+		}
+		field := adv[i+1 : i+1+length]
+		if field[0] == adTypeServiceData16 && len(field) >= 3 &&
+			field[1] == serviceUUID[0] && field[2] == serviceUUID[1] {
+			return field[3:], true
+		}
+		i += 1 + length
+	}
+	return nil, false
+}
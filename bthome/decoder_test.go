@@ -0,0 +1,194 @@
+package bthome
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Example from the BTHome v2 format specification.
+const (
+	specMAC       = "54:48:E6:8F:80:A5"
+	specKey       = "231d39c1d7cc1ab1aee224cd096db932"
+	specEncrypted = "41 a4 72 66 c9 5f 73 00 11 22 33 78 23 72 14"
+	specPlaintext = "02 ca 09 03 bf 13"
+)
+
+func specKeyStore(t *testing.T) *MemoryKeyStore {
+	t.Helper()
+	keys := NewMemoryKeyStore()
+	if err := keys.SetHex(specMAC, specKey); err != nil {
+		t.Fatalf("SetHex() error = %v", err)
+	}
+	return keys
+}
+
+func TestDecrypt_SpecVector(t *testing.T) {
+	key := mustHex(t, specKey)
+
+	plaintext, counter, err := Decrypt(mustHex(t, specEncrypted), specMAC, key)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if hex.EncodeToString(plaintext) != strings.ReplaceAll(specPlaintext, " ", "") {
+		t.Errorf("plaintext = %x", plaintext)
+	}
+	if counter != 0x33221100 {
+		t.Errorf("counter = %#x, want 0x33221100", counter)
+	}
+
+	encrypted, err := Encrypt(mustHex(t, specPlaintext), 0x40, specMAC, key, 0x33221100)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if hex.EncodeToString(encrypted) != strings.ReplaceAll(specEncrypted, " ", "") {
+		t.Errorf("Encrypt() = %x", encrypted)
+	}
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	key := mustHex(t, specKey)
+	data := mustHex(t, specEncrypted)
+
+	tampered := append([]byte(nil), data...)
+	tampered[2] ^= 0xFF
+	if _, _, err := Decrypt(tampered, specMAC, key); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("tampered: error = %v, want ErrAuthFailed", err)
+	}
+	if _, _, err := Decrypt(data, "54:48:E6:8F:80:A6", key); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("wrong MAC: error = %v, want ErrAuthFailed", err)
+	}
+	if _, _, err := Decrypt(data, specMAC, key[:8]); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("short key: error = %v, want ErrInvalidKey", err)
+	}
+	if _, _, err := Decrypt(data, "not-a-mac", key); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("bad MAC: error = %v, want ErrInvalidMAC", err)
+	}
+	if _, _, err := Decrypt(data[:5], specMAC, key); !errors.Is(err, ErrTruncated) {
+		t.Errorf("short payload: error = %v, want ErrTruncated", err)
+	}
+}
+
+func TestDecoder_Encrypted(t *testing.T) {
+	dec := NewDecoder(specKeyStore(t))
+
+	p, err := dec.Decode("5448e68f80a5", mustHex(t, specEncrypted))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !p.Encrypted || p.MAC != specMAC || p.Counter != 0x33221100 {
+		t.Errorf("packet = %+v", p)
+	}
+	if v, _ := p.Value(NameTemperature); v != 25.06 {
+		t.Errorf("temperature = %v, want 25.06", v)
+	}
+	if v, _ := p.Value(NameHumidity); v != 50.55 {
+		t.Errorf("humidity = %v, want 50.55", v)
+	}
+
+	// The same advertisement received again is a duplicate.
+	if _, err := dec.Decode(specMAC, mustHex(t, specEncrypted)); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("repeat: error = %v, want ErrDuplicate", err)
+	}
+
+	// An older counter is a replay.
+	key := mustHex(t, specKey)
+	old, _ := Encrypt(mustHex(t, specPlaintext), 0x40, specMAC, key, 5)
+	if p, err := dec.Decode(specMAC, old); !errors.Is(err, ErrReplay) || p == nil {
+		t.Errorf("old counter: error = %v, want ErrReplay with packet", err)
+	}
+
+	// A newer counter is accepted.
+	newer, _ := Encrypt(mustHex(t, specPlaintext), 0x40, specMAC, key, 0x33221101)
+	if _, err := dec.Decode(specMAC, newer); err != nil {
+		t.Errorf("newer counter: error = %v", err)
+	}
+
+	// Forget resets the state, e.g. after a battery change.
+	dec.Forget(specMAC)
+	if _, err := dec.Decode(specMAC, old); err != nil {
+		t.Errorf("after Forget: error = %v", err)
+	}
+}
+
+func TestDecoder_NoKey(t *testing.T) {
+	dec := NewDecoder(nil)
+	p, err := dec.Decode(specMAC, mustHex(t, specEncrypted))
+	if !errors.Is(err, ErrNoKey) {
+		t.Errorf("error = %v, want ErrNoKey", err)
+	}
+	if p == nil || !p.Encrypted {
+		t.Errorf("expected header-only packet, got %+v", p)
+	}
+}
+
+func TestDecoder_DuplicatePacketID(t *testing.T) {
+	dec := NewDecoder(nil)
+	data := mustHex(t, "40 00 09 21 01")
+
+	if _, err := dec.Decode(specMAC, data); err != nil {
+		t.Fatalf("first: error = %v", err)
+	}
+	if _, err := dec.Decode(specMAC, data); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("second: error = %v, want ErrDuplicate", err)
+	}
+	// Other devices are tracked separately.
+	if _, err := dec.Decode("11:22:33:44:55:66", data); err != nil {
+		t.Errorf("other device: error = %v", err)
+	}
+}
+
+func TestDecoder_Sources(t *testing.T) {
+	dec := NewDecoder(specKeyStore(t))
+	serviceData := mustHex(t, specEncrypted)
+
+	p, err := dec.DecodeServiceData(specMAC, map[string][]byte{
+		"0000fcd2-0000-1000-8000-00805f9b34fb": serviceData,
+	})
+	if err != nil || p.Counter != 0x33221100 {
+		t.Fatalf("DecodeServiceData() = %+v, %v", p, err)
+	}
+
+	// Flags AD structure followed by service data (len, 0x16, d2 fc, payload).
+	adv := append([]byte{0x02, 0x01, 0x06, byte(3 + len(serviceData)), 0x16, 0xD2, 0xFC}, serviceData...)
+	dec.Forget(specMAC)
+	p, err = dec.DecodeAdvertisement(specMAC, adv)
+	if err != nil || p.Counter != 0x33221100 {
+		t.Fatalf("DecodeAdvertisement() = %+v, %v", p, err)
+	}
+
+	if _, err := dec.DecodeAdvertisement(specMAC, []byte{0x02, 0x01, 0x06}); !errors.Is(err, ErrEmpty) {
+		t.Errorf("no service data: error = %v, want ErrEmpty", err)
+	}
+	if _, err := dec.DecodeServiceData(specMAC, map[string][]byte{"180f": {1}}); !errors.Is(err, ErrEmpty) {
+		t.Errorf("no bthome uuid: error = %v, want ErrEmpty", err)
+	}
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	keys := NewMemoryKeyStore()
+
+	err := keys.Load(strings.NewReader(`{"aa:bb:cc:dd:ee:ff": "231d39c1d7cc1ab1aee224cd096db932"}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok := keys.Key("AA-BB-CC-DD-EE-FF"); !ok {
+		t.Error("key lookup should be format-insensitive")
+	}
+
+	keys.Delete("AABBCCDDEEFF")
+	if _, ok := keys.Key("AA:BB:CC:DD:EE:FF"); ok {
+		t.Error("key should be deleted")
+	}
+
+	if err := keys.SetHex(specMAC, "abcd"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("short key: error = %v, want ErrInvalidKey", err)
+	}
+	if err := keys.SetHex(specMAC, "zz"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("bad hex: error = %v, want ErrInvalidKey", err)
+	}
+	if err := keys.Load(strings.NewReader(`{"bad": "231d39c1d7cc1ab1aee224cd096db932"}`)); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("bad MAC: error = %v, want ErrInvalidMAC", err)
+	}
+}
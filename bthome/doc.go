@@ -0,0 +1,49 @@
+// Package bthome decodes BTHome v2 Bluetooth advertisements, including
+// AES-CCM encrypted payloads.
+//
+// Shelly BLU devices (Button, Door/Window, Motion, H&T, ...) broadcast
+// their readings as BTHome v2 service data under UUID 0xFCD2. This package
+// decodes the complete BTHome object table: sensor values with their scale
+// factors and units, binary sensors, button and dimmer events, text and
+// raw objects, and device information. Repeated objects (for example two
+// temperature probes) are kept in order and numbered with Object.Index.
+//
+// # Unencrypted Payloads
+//
+//	packet, err := bthome.Parse(serviceData)
+//	if err != nil {
+//	    return err
+//	}
+//	if t, ok := packet.Value(bthome.NameTemperature); ok {
+//	    fmt.Printf("%.1f °C\n", t)
+//	}
+//
+// # Encrypted Payloads
+//
+// Devices with encryption enabled need their bindkey, shown in the Shelly
+// app. A Decoder looks keys up per MAC address and tracks the encryption
+// counter and packet ID of every device to flag replayed and repeated
+// packets:
+//
+//	keys := bthome.NewMemoryKeyStore()
+//	keys.SetHex("7C:C6:B6:AA:BB:CC", "231d39c1d7cc1ab1aee224cd096db932")
+//	dec := bthome.NewDecoder(keys)
+//
+//	packet, err := dec.Decode("7C:C6:B6:AA:BB:CC", serviceData)
+//	switch {
+//	case errors.Is(err, bthome.ErrDuplicate):
+//	    // Same packet received again; already handled.
+//	case errors.Is(err, bthome.ErrReplay):
+//	    // Old counter: ignore.
+//	case err != nil:
+//	    return err
+//	}
+//
+// # Sources
+//
+// The decoder works with any source of advertisement bytes:
+//   - discovery.BLEScanner: pass BLEAdvertisement.ServiceData to
+//     Decoder.DecodeServiceData, or set BLEDiscoverer.BTHomeDecoder
+//   - Gateway-relayed advertisements (BLE.Scanner in a script): pass the
+//     raw advertising data to Decoder.DecodeAdvertisement
+package bthome
@@ -0,0 +1,296 @@
+package bthome
+
+// Kind classifies BTHome objects.
+type Kind int
+
+const (
+	// KindSensor is a numeric measurement.
+	KindSensor Kind = iota
+
+	// KindBinary is an on/off state.
+	KindBinary
+
+	// KindEvent is a button or dimmer event.
+	KindEvent
+
+	// KindText is a UTF-8 string.
+	KindText
+
+	// KindRaw is an opaque byte string.
+	KindRaw
+
+	// KindDevice is device information (packet ID, firmware, device type).
+	KindDevice
+)
+
+// String returns the kind name.
+func (k Kind) String() string {
+	switch k {
+	case KindSensor:
+		return "sensor"
+	case KindBinary:
+		return "binary"
+	case KindEvent:
+		return "event"
+	case KindText:
+		return "text"
+	case KindRaw:
+		return "raw"
+	case KindDevice:
+		return "device"
+	default:
+		return "unknown"
+	}
+}
+
+// Object names used in Object.Name.
+const (
+	NamePacketID         = "packet_id"
+	NameBattery          = "battery"
+	NameTemperature      = "temperature"
+	NameHumidity         = "humidity"
+	NamePressure         = "pressure"
+	NameIlluminance      = "illuminance"
+	NameMassKg           = "mass_kg"
+	NameMassLb           = "mass_lb"
+	NameDewPoint         = "dew_point"
+	NameCount            = "count"
+	NameEnergy           = "energy"
+	NamePower            = "power"
+	NameVoltage          = "voltage"
+	NamePM25             = "pm2_5"
+	NamePM10             = "pm10"
+	NameCO2              = "co2"
+	NameTVOC             = "tvoc"
+	NameMoisture         = "moisture"
+	NameCurrent          = "current"
+	NameDistanceMM       = "distance_mm"
+	NameDistanceM        = "distance_m"
+	NameDuration         = "duration"
+	NameSpeed            = "speed"
+	NameRotation         = "rotation"
+	NameUVIndex          = "uv_index"
+	NameVolume           = "volume"
+	NameVolumeMl         = "volume_ml"
+	NameVolumeFlowRate   = "volume_flow_rate"
+	NameGas              = "gas"
+	NameWater            = "water"
+	NameTimestamp        = "timestamp"
+	NameAcceleration     = "acceleration"
+	NameGyroscope        = "gyroscope"
+	NameVolumeStorage    = "volume_storage"
+	NameConductivity     = "conductivity"
+	NameDirection        = "direction"
+	NamePrecipitation    = "precipitation"
+	NameChannel          = "channel"
+	NameRotationalSpeed  = "rotational_speed"
+	NameText             = "text"
+	NameRaw              = "raw"
+	NameDeviceTypeID     = "device_type_id"
+	NameFirmwareVersion  = "firmware_version"
+	NameButton           = "button"
+	NameDimmer           = "dimmer"
+	NameBatteryLow       = "battery_low"
+	NameBatteryCharging  = "battery_charging"
+	NameCarbonMonoxide   = "carbon_monoxide"
+	NameCold             = "cold"
+	NameConnectivity     = "connectivity"
+	NameDoor             = "door"
+	NameGarageDoor       = "garage_door"
+	NameGasDetected      = "gas_detected"
+	NameGenericBoolean   = "generic_boolean"
+	NameHeat             = "heat"
+	NameLight            = "light"
+	NameLock             = "lock"
+	NameMoistureDetected = "moisture_detected"
+	NameMotion           = "motion"
+	NameMoving           = "moving"
+	NameOccupancy        = "occupancy"
+	NameOpening          = "opening"
+	NamePlug             = "plug"
+	NamePowerOn          = "power_on"
+	NamePresence         = "presence"
+	NameProblem          = "problem"
+	NameRunning          = "running"
+	NameSafety           = "safety"
+	NameSmoke            = "smoke"
+	NameSound            = "sound"
+	NameTamper           = "tamper"
+	NameVibration        = "vibration"
+	NameWindow           = "window"
+)
+
+// objectDef describes how to decode one object ID.
+type objectDef struct {
+	name   string
+	unit   string
+	factor float64
+	kind   Kind
+	// size is the payload length in bytes; 0 means the first byte holds
+	// the length (text and raw objects).
+	size   int
+	signed bool
+}
+
+// objects is the BTHome v2 object ID table (https://bthome.io/format/).
+var objects = map[uint8]objectDef{
+	// Device information
+	0x00: {name: NamePacketID, kind: KindDevice, size: 1, factor: 1},
+	0xF0: {name: NameDeviceTypeID, kind: KindDevice, size: 2, factor: 1},
+	0xF1: {name: NameFirmwareVersion, kind: KindDevice, size: 4, factor: 1},
+	0xF2: {name: NameFirmwareVersion, kind: KindDevice, size: 3, factor: 1},
+
+	// Sensor data
+	0x01: {name: NameBattery, unit: "%", size: 1, factor: 1},
+	0x02: {name: NameTemperature, unit: "°C", size: 2, signed: true, factor: 0.01},
+	0x03: {name: NameHumidity, unit: "%", size: 2, factor: 0.01},
+	0x04: {name: NamePressure, unit: "hPa", size: 3, factor: 0.01},
+	0x05: {name: NameIlluminance, unit: "lx", size: 3, factor: 0.01},
+	0x06: {name: NameMassKg, unit: "kg", size: 2, factor: 0.01},
+	0x07: {name: NameMassLb, unit: "lb", size: 2, factor: 0.01},
+	0x08: {name: NameDewPoint, unit: "°C", size: 2, signed: true, factor: 0.01},
+	0x09: {name: NameCount, size: 1, factor: 1},
+	0x0A: {name: NameEnergy, unit: "kWh", size: 3, factor: 0.001},
+	0x0B: {name: NamePower, unit: "W", size: 3, factor: 0.01},
+	0x0C: {name: NameVoltage, unit: "V", size: 2, factor: 0.001},
+	0x0D: {name: NamePM25, unit: "µg/m³", size: 2, factor: 1},
+	0x0E: {name: NamePM10, unit: "µg/m³", size: 2, factor: 1},
+	0x12: {name: NameCO2, unit: "ppm", size: 2, factor: 1},
+	0x13: {name: NameTVOC, unit: "µg/m³", size: 2, factor: 1},
+	0x14: {name: NameMoisture, unit: "%", size: 2, factor: 0.01},
+	0x2E: {name: NameHumidity, unit: "%", size: 1, factor: 1},
+	0x2F: {name: NameMoisture, unit: "%", size: 1, factor: 1},
+	0x3D: {name: NameCount, size: 2, factor: 1},
+	0x3E: {name: NameCount, size: 4, factor: 1},
+	0x3F: {name: NameRotation, unit: "°", size: 2, signed: true, factor: 0.1},
+	0x40: {name: NameDistanceMM, unit: "mm", size: 2, factor: 1},
+	0x41: {name: NameDistanceM, unit: "m", size: 2, factor: 0.1},
+	0x42: {name: NameDuration, unit: "s", size: 3, factor: 0.001},
+	0x43: {name: NameCurrent, unit: "A", size: 2, factor: 0.001},
+	0x44: {name: NameSpeed, unit: "m/s", size: 2, factor: 0.01},
+	0x45: {name: NameTemperature, unit: "°C", size: 2, signed: true, factor: 0.1},
+	0x46: {name: NameUVIndex, size: 1, factor: 0.1},
+	0x47: {name: NameVolume, unit: "L", size: 2, factor: 0.1},
+	0x48: {name: NameVolumeMl, unit: "mL", size: 2, factor: 1},
+	0x49: {name: NameVolumeFlowRate, unit: "m³/h", size: 2, factor: 0.001},
+	0x4A: {name: NameVoltage, unit: "V", size: 2, factor: 0.1},
+	0x4B: {name: NameGas, unit: "m³", size: 3, factor: 0.001},
+	0x4C: {name: NameGas, unit: "m³", size: 4, factor: 0.001},
+	0x4D: {name: NameEnergy, unit: "kWh", size: 4, factor: 0.001},
+	0x4E: {name: NameVolume, unit: "L", size: 4, factor: 0.001},
+	0x4F: {name: NameWater, unit: "L", size: 4, factor: 0.001},
+	0x50: {name: NameTimestamp, unit: "s", size: 4, factor: 1},
+	0x51: {name: NameAcceleration, unit: "m/s²", size: 2, factor: 0.001},
+	0x52: {name: NameGyroscope, unit: "°/s", size: 2, factor: 0.001},
+	0x55: {name: NameVolumeStorage, unit: "L", size: 4, factor: 0.001},
+	0x56: {name: NameConductivity, unit: "µS/cm", size: 2, factor: 1},
+	0x57: {name: NameTemperature, unit: "°C", size: 1, signed: true, factor: 1},
+	0x58: {name: NameTemperature, unit: "°C", size: 1, signed: true, factor: 0.35},
+	0x59: {name: NameCount, size: 1, signed: true, factor: 1},
+	0x5A: {name: NameCount, size: 2, signed: true, factor: 1},
+	0x5B: {name: NameCount, size: 4, signed: true, factor: 1},
+	0x5C: {name: NamePower, unit: "W", size: 4, signed: true, factor: 0.01},
+	0x5D: {name: NameCurrent, unit: "A", size: 2, signed: true, factor: 0.001},
+	0x5E: {name: NameDirection, unit: "°", size: 2, factor: 0.01},
+	0x5F: {name: NamePrecipitation, unit: "mm", size: 2, factor: 0.1},
+	0x60: {name: NameChannel, size: 1, factor: 1},
+	0x61: {name: NameRotationalSpeed, unit: "rpm", size: 2, factor: 1},
+
+	// Text and raw
+	0x53: {name: NameText, kind: KindText},
+	0x54: {name: NameRaw, kind: KindRaw},
+
+	// Binary sensors
+	0x0F: {name: NameGenericBoolean, kind: KindBinary, size: 1, factor: 1},
+	0x10: {name: NamePowerOn, kind: KindBinary, size: 1, factor: 1},
+	0x11: {name: NameOpening, kind: KindBinary, size: 1, factor: 1},
+	0x15: {name: NameBatteryLow, kind: KindBinary, size: 1, factor: 1},
+	0x16: {name: NameBatteryCharging, kind: KindBinary, size: 1, factor: 1},
+	0x17: {name: NameCarbonMonoxide, kind: KindBinary, size: 1, factor: 1},
+	0x18: {name: NameCold, kind: KindBinary, size: 1, factor: 1},
+	0x19: {name: NameConnectivity, kind: KindBinary, size: 1, factor: 1},
+	0x1A: {name: NameDoor, kind: KindBinary, size: 1, factor: 1},
+	0x1B: {name: NameGarageDoor, kind: KindBinary, size: 1, factor: 1},
+	0x1C: {name: NameGasDetected, kind: KindBinary, size: 1, factor: 1},
+	0x1D: {name: NameHeat, kind: KindBinary, size: 1, factor: 1},
+	0x1E: {name: NameLight, kind: KindBinary, size: 1, factor: 1},
+	0x1F: {name: NameLock, kind: KindBinary, size: 1, factor: 1},
+	0x20: {name: NameMoistureDetected, kind: KindBinary, size: 1, factor: 1},
+	0x21: {name: NameMotion, kind: KindBinary, size: 1, factor: 1},
+	0x22: {name: NameMoving, kind: KindBinary, size: 1, factor: 1},
+	0x23: {name: NameOccupancy, kind: KindBinary, size: 1, factor: 1},
+	0x24: {name: NamePlug, kind: KindBinary, size: 1, factor: 1},
+	0x25: {name: NamePresence, kind: KindBinary, size: 1, factor: 1},
+	0x26: {name: NameProblem, kind: KindBinary, size: 1, factor: 1},
+	0x27: {name: NameRunning, kind: KindBinary, size: 1, factor: 1},
+	0x28: {name: NameSafety, kind: KindBinary, size: 1, factor: 1},
+	0x29: {name: NameSmoke, kind: KindBinary, size: 1, factor: 1},
+	0x2A: {name: NameSound, kind: KindBinary, size: 1, factor: 1},
+	0x2B: {name: NameTamper, kind: KindBinary, size: 1, factor: 1},
+	0x2C: {name: NameVibration, kind: KindBinary, size: 1, factor: 1},
+	0x2D: {name: NameWindow, kind: KindBinary, size: 1, factor: 1},
+
+	// Events
+	0x3A: {name: NameButton, kind: KindEvent, size: 1, factor: 1},
+	0x3C: {name: NameDimmer, kind: KindEvent, size: 2, factor: 1},
+}
+
+// Button event names.
+const (
+	ButtonNone            = ""
+	ButtonPress           = "press"
+	ButtonDoublePress     = "double_press"
+	ButtonTriplePress     = "triple_press"
+	ButtonLongPress       = "long_press"
+	ButtonLongDoublePress = "long_double_press"
+	ButtonLongTriplePress = "long_triple_press"
+	ButtonHoldPress       = "hold_press"
+)
+
+var buttonEvents = map[uint8]string{
+	0x00: ButtonNone,
+	0x01: ButtonPress,
+	0x02: ButtonDoublePress,
+	0x03: ButtonTriplePress,
+	0x04: ButtonLongPress,
+	0x05: ButtonLongDoublePress,
+	0x06: ButtonLongTriplePress,
+	0x80: ButtonHoldPress,
+}
+
+// Dimmer event names.
+const (
+	DimmerNone        = ""
+	DimmerRotateLeft  = "rotate_left"
+	DimmerRotateRight = "rotate_right"
+)
+
+var dimmerEvents = map[uint8]string{
+	0x00: DimmerNone,
+	0x01: DimmerRotateLeft,
+	0x02: DimmerRotateRight,
+}
+
+// ObjectInfo describes a BTHome object ID.
+type ObjectInfo struct {
+	Name string
+	Unit string
+	Kind Kind
+	ID   uint8
+}
+
+// Lookup returns the description of an object ID, as used by the obj_id
+// field of BTHomeSensor components.
+func Lookup(id uint8) (ObjectInfo, bool) {
+	def, ok := objects[id]
+	if !ok {
+		return ObjectInfo{}, false
+	}
+	return ObjectInfo{ID: id, Name: def.name, Unit: def.unit, Kind: def.kind}, true
+}
+
+// ButtonEventName returns the event name for a button event code.
+func ButtonEventName(code uint8) (string, bool) {
+	name, ok := buttonEvents[code]
+	return name, ok
+}
@@ -0,0 +1,279 @@
+package bthome
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// Device information byte flags.
+const (
+	flagEncrypted    = 0x01
+	flagTriggerBased = 0x04
+	versionShift     = 5
+	versionMask      = 0x07
+
+	// Version is the supported BTHome format version.
+	Version = 2
+)
+
+// Common errors.
+var (
+	// ErrEmpty indicates service data with no device information byte.
+	ErrEmpty = errors.New("bthome: empty service data")
+
+	// ErrUnsupportedVersion indicates a BTHome version other than 2.
+	ErrUnsupportedVersion = errors.New("bthome: unsupported version")
+
+	// ErrEncrypted indicates an encrypted payload passed to Parse.
+	// Use a Decoder with a KeyStore instead.
+	ErrEncrypted = errors.New("bthome: payload is encrypted")
+
+	// ErrUnknownObject indicates an object ID not in the BTHome table.
+	// Parsing stops there because the object length is unknown; the
+	// objects before it are still returned.
+	ErrUnknownObject = errors.New("bthome: unknown object id")
+
+	// ErrTruncated indicates the payload ends inside an object.
+	ErrTruncated = errors.New("bthome: truncated payload")
+)
+
+// Object is a single decoded BTHome object.
+type Object struct {
+	// Name identifies the measurement (one of the Name* constants).
+	Name string `json:"name"`
+
+	// Unit is the measurement unit, empty for unitless values.
+	Unit string `json:"unit,omitempty"`
+
+	// Event is the button or dimmer event name for KindEvent objects.
+	Event string `json:"event,omitempty"`
+
+	// Text is the string for KindText objects.
+	Text string `json:"text,omitempty"`
+
+	// Bytes holds the payload of KindRaw objects.
+	Bytes []byte `json:"bytes,omitempty"`
+
+	// Value is the scaled value. Binary objects are 0 or 1; dimmer events
+	// carry the step count.
+	Value float64 `json:"value"`
+
+	// Raw is the unscaled integer as transmitted.
+	Raw int64 `json:"raw"`
+
+	// Kind classifies the object.
+	Kind Kind `json:"kind"`
+
+	// Index numbers repeated objects of the same name in payload order,
+	// e.g. the second temperature of a two-probe sensor has Index 1.
+	Index int `json:"index"`
+
+	// ID is the BTHome object ID.
+	ID uint8 `json:"id"`
+}
+
+// Bool returns the state of a binary object.
+func (o Object) Bool() bool {
+	return o.Raw != 0
+}
+
+// Packet is a decoded BTHome advertisement.
+type Packet struct {
+	// PacketID is the value of the packet ID object, if present.
+	PacketID *uint8 `json:"packet_id,omitempty"`
+
+	// MAC is the sender address, set by Decoder.
+	MAC string `json:"mac,omitempty"`
+
+	// Objects are the decoded objects in payload order.
+	Objects []Object `json:"objects"`
+
+	// Counter is the encryption counter of encrypted payloads.
+	Counter uint32 `json:"counter,omitempty"`
+
+	// Version is the BTHome format version.
+	Version uint8 `json:"version"`
+
+	// Encrypted is true if the payload was encrypted.
+	Encrypted bool `json:"encrypted"`
+
+	// TriggerBased is true if the device sends on events rather than at
+	// a fixed interval.
+	TriggerBased bool `json:"trigger_based"`
+}
+
+// Get returns the first object with the given name.
+func (p *Packet) Get(name string) (Object, bool) {
+	for _, o := range p.Objects {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return Object{}, false
+}
+
+// All returns every object with the given name in payload order.
+func (p *Packet) All(name string) []Object {
+	var out []Object
+	for _, o := range p.Objects {
+		if o.Name == name {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// Value returns the scaled value of the first object with the given name.
+func (p *Packet) Value(name string) (float64, bool) {
+	o, ok := p.Get(name)
+	return o.Value, ok
+}
+
+// Bool returns the state of the first binary object with the given name.
+func (p *Packet) Bool(name string) (bool, bool) {
+	o, ok := p.Get(name)
+	return o.Bool(), ok
+}
+
+// Events returns the button and dimmer events in the packet, skipping
+// "none" events that only report the button count.
+func (p *Packet) Events() []Object {
+	var out []Object
+	for _, o := range p.Objects {
+		if o.Kind == KindEvent && o.Event != "" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// Parse decodes unencrypted BTHome v2 service data (the bytes of the
+// 0xFCD2 service data, starting with the device information byte).
+//
+// Encrypted payloads return ErrEncrypted. If an unknown object or a
+// truncated object is found, the packet decoded so far is returned along
+// with ErrUnknownObject or ErrTruncated.
+func Parse(data []byte) (*Packet, error) {
+	p, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.Encrypted {
+		return p, ErrEncrypted
+	}
+	return p, p.decodeObjects(data[1:])
+}
+
+// parseHeader decodes the device information byte.
+func parseHeader(data []byte) (*Packet, error) {
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	info := data[0]
+	p := &Packet{
+		Version:      (info >> versionShift) & versionMask,
+		Encrypted:    info&flagEncrypted != 0,
+		TriggerBased: info&flagTriggerBased != 0,
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, p.Version)
+	}
+	return p, nil
+}
+
+// decodeObjects decodes the object list into p.
+func (p *Packet) decodeObjects(data []byte) error {
+	counts := make(map[string]int)
+
+	for offset := 0; offset < len(data); {
+		id := data[offset]
+		offset++
+
+		def, ok := objects[id]
+		if !ok {
+			return fmt.Errorf("%w: 0x%02X at offset %d", ErrUnknownObject, id, offset)
+		}
+
+		size := def.size
+		if size == 0 {
+			if offset >= len(data) {
+				return fmt.Errorf("%w: object 0x%02X", ErrTruncated, id)
+			}
+			size = int(data[offset])
+			offset++
+		}
+		if offset+size > len(data) {
+			return fmt.Errorf("%w: object 0x%02X", ErrTruncated, id)
+		}
+
+		obj := decodeObject(id, def, data[offset:offset+size])
+		obj.Index = counts[obj.Name]
+		counts[obj.Name]++
+
+		if id == 0x00 {
+			pid := uint8(obj.Raw)
+			p.PacketID = &pid
+		}
+		p.Objects = append(p.Objects, obj)
+		offset += size
+	}
+	return nil
+}
+
+// decodeObject decodes a single object payload.
+func decodeObject(id uint8, def objectDef, payload []byte) Object {
+	obj := Object{ID: id, Name: def.name, Unit: def.unit, Kind: def.kind}
+
+	switch def.kind {
+	case KindText:
+		if utf8.Valid(payload) {
+			obj.Text = string(payload)
+		}
+		obj.Bytes = append([]byte(nil), payload...)
+		return obj
+	case KindRaw:
+		obj.Bytes = append([]byte(nil), payload...)
+		return obj
+	case KindEvent:
+		obj.Raw = int64(payload[0])
+		if id == 0x3C {
+			obj.Event = dimmerEvents[payload[0]]
+			obj.Value = float64(payload[1])
+			return obj
+		}
+		obj.Event = buttonEvents[payload[0]]
+		obj.Value = float64(payload[0])
+		return obj
+	}
+
+	obj.Raw = littleEndian(payload, def.signed)
+	obj.Value = scale(obj.Raw, def.factor)
+	return obj
+}
+
+// littleEndian decodes an unsigned or two's-complement signed integer.
+func littleEndian(b []byte, signed bool) int64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	if signed {
+		bits := uint(len(b) * 8)
+		if v&(1<<(bits-1)) != 0 {
+			return int64(v) - int64(1)<<bits
+		}
+	}
+	return int64(v)
+}
+
+// scale applies the factor and rounds away binary floating point noise
+// (2506 * 0.01 = 25.06, not 25.060000000000002).
+func scale(raw int64, factor float64) float64 {
+	if factor == 1 {
+		return float64(raw)
+	}
+	const precision = 1e6
+	return math.Round(float64(raw)*factor*precision) / precision
+}
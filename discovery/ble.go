@@ -2,6 +2,8 @@ package discovery
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net"
 	"strings"
 	"sync"
@@ -9,6 +11,7 @@ import (
 
 	"tinygo.org/x/bluetooth"
 
+	"github.com/tj-smith47/shelly-go/bthome"
 	"github.com/tj-smith47/shelly-go/types"
 )
 
@@ -32,9 +35,15 @@ const (
 
 // BLEDiscoveredDevice represents a Shelly device discovered via BLE.
 type BLEDiscoveredDevice struct {
-	BTHomeData  *BTHomeData `json:"bthome_data,omitempty"`
-	ServiceUUID string      `json:"service_uuid,omitempty"`
-	LocalName   string      `json:"local_name,omitempty"`
+	BTHomeData *BTHomeData `json:"bthome_data,omitempty"`
+	// BTHome is the full decoded BTHome packet, including objects that
+	// BTHomeData has no field for. Nil if decoding failed.
+	BTHome      *bthome.Packet `json:"bthome,omitempty"`
+	ServiceUUID string         `json:"service_uuid,omitempty"`
+	LocalName   string         `json:"local_name,omitempty"`
+	// Gateway is the ID of the remote gateway that received the
+	// advertisement; empty for a local adapter.
+	Gateway string `json:"gateway,omitempty"`
 	DiscoveredDevice
 	RSSI        int  `json:"rssi"`
 	Connectable bool `json:"connectable"`
@@ -64,7 +73,10 @@ type BTHomeData struct {
 // Actual BLE scanning requires platform-specific implementations or
 // external BLE libraries (e.g., tinygo-org/bluetooth, go-ble/ble).
 type BLEDiscoverer struct {
-	Scanner       BLEScanner
+	Scanner BLEScanner
+	// BTHomeDecoder decodes BTHome service data, decrypting payloads from
+	// devices whose bindkey it knows. If nil, only unencrypted data is decoded.
+	BTHomeDecoder *bthome.Decoder
 	devices       map[string]*BLEDiscoveredDevice
 	devicesCh     chan DiscoveredDevice
 	stopCh        chan struct{}
@@ -191,9 +203,11 @@ func (s *tinyGoBLEScanner) convertAdvertisement(device bluetooth.ScanResult) *BL
 
 // BLEAdvertisement represents a raw BLE advertisement.
 type BLEAdvertisement struct {
-	ServiceData      map[string][]byte
-	Address          string
-	LocalName        string
+	ServiceData map[string][]byte
+	Address     string
+	LocalName   string
+	// Gateway is set by RemoteBLEScanner to the receiving gateway's ID.
+	Gateway          string
 	ServiceUUIDs     []string
 	ManufacturerData []byte
 	RSSI             int
@@ -357,6 +371,7 @@ func (b *BLEDiscoverer) parseAdvertisement(adv *BLEAdvertisement) *BLEDiscovered
 		},
 		RSSI:        adv.RSSI,
 		LocalName:   adv.LocalName,
+		Gateway:     adv.Gateway,
 		Connectable: adv.Connectable,
 	}
 
@@ -376,13 +391,73 @@ func (b *BLEDiscoverer) parseAdvertisement(adv *BLEAdvertisement) *BLEDiscovered
 
 	// Parse BTHome data if present
 	if bthomeData, ok := adv.ServiceData[BTHomeServiceUUID]; ok {
-		device.BTHomeData = parseBTHomeData(bthomeData)
+		if packet := b.decodeBTHome(adv.Address, bthomeData); packet != nil {
+			device.BTHome = packet
+			device.BTHomeData = bthomeDataFromPacket(packet)
+		} else {
+			device.BTHomeData = parseBTHomeData(bthomeData)
+		}
 		device.Generation = types.Gen2 // BLU devices are considered Gen2+
 	}
 
 	return device
 }
 
+// decodeBTHome decodes service data with the configured decoder, or
+// without decryption if none is set. Repeated packets are still returned
+// since discovery reports every advertisement.
+func (b *BLEDiscoverer) decodeBTHome(address string, data []byte) *bthome.Packet {
+	var (
+		packet *bthome.Packet
+		err    error
+	)
+	if b.BTHomeDecoder != nil {
+		packet, err = b.BTHomeDecoder.Decode(address, data)
+	} else {
+		packet, err = bthome.Parse(data)
+	}
+	if err != nil && !errors.Is(err, bthome.ErrDuplicate) {
+		return nil
+	}
+	return packet
+}
+
+// bthomeDataFromPacket fills the BTHomeData summary from a decoded packet.
+func bthomeDataFromPacket(p *bthome.Packet) *BTHomeData {
+	result := &BTHomeData{}
+	if p.PacketID != nil {
+		result.PacketID = *p.PacketID
+	}
+	if v, ok := p.Value(bthome.NameTemperature); ok {
+		result.Temperature = &v
+	}
+	if v, ok := p.Value(bthome.NameHumidity); ok {
+		result.Humidity = &v
+	}
+	if v, ok := p.Value(bthome.NameBattery); ok {
+		battery := uint8(v)
+		result.Battery = &battery
+	}
+	if v, ok := p.Value(bthome.NameIlluminance); ok {
+		lux := uint32(math.Round(v * 100)) // 0.01 lux units
+		result.Illuminance = &lux
+	}
+	if v, ok := p.Bool(bthome.NameMotion); ok {
+		result.Motion = &v
+	}
+	if v, ok := p.Bool(bthome.NameWindow); ok {
+		result.WindowOpen = &v
+	}
+	if o, ok := p.Get(bthome.NameButton); ok {
+		button := uint8(o.Raw)
+		result.Button = &button
+	}
+	if v, ok := p.Value(bthome.NameRotation); ok {
+		result.Rotation = &v
+	}
+	return result
+}
+
 // BTHome object type sizes.
 var bthomeObjectSizes = map[uint8]int{
 	0x00: 1, // Packet ID
@@ -497,6 +572,11 @@ func (b *BLEDiscoverer) continuousDiscovery() {
 		ctx, cancel := context.WithTimeout(context.Background(), b.ScanDuration)
 		//nolint:errcheck // Errors are handled per-scan, don't stop continuous discovery
 		b.Scanner.Start(ctx, b.handleAdvertisement)
+		// Don't restart a scan that returned early before its window ends.
+		select {
+		case <-ctx.Done():
+		case <-b.stopCh:
+		}
 		cancel()
 	}
 }
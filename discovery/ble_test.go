@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tj-smith47/shelly-go/bthome"
 	"github.com/tj-smith47/shelly-go/types"
 )
 
@@ -802,6 +803,83 @@ func TestBLEDiscoverer_ParseAdvertisement(t *testing.T) {
 	}
 }
 
+func TestBLEDiscoverer_ParseAdvertisement_EncryptedBTHome(t *testing.T) {
+	const mac = "54:48:E6:8F:80:A5"
+	// BTHome v2 specification example: temperature 25.06, humidity 50.55
+	payload := []byte{
+		0x41, 0xa4, 0x72, 0x66, 0xc9, 0x5f, 0x73,
+		0x00, 0x11, 0x22, 0x33, 0x78, 0x23, 0x72, 0x14,
+	}
+	adv := &BLEAdvertisement{
+		Address:     mac,
+		ServiceData: map[string][]byte{BTHomeServiceUUID: payload},
+	}
+
+	d := NewBLEDiscovererWithScanner(newMockBLEScanner())
+	device := d.parseAdvertisement(adv)
+	if device.BTHome != nil {
+		t.Error("BTHome should be nil without a bindkey")
+	}
+	if device.BTHomeData != nil {
+		t.Error("BTHomeData should be nil without a bindkey")
+	}
+
+	keys := bthome.NewMemoryKeyStore()
+	if err := keys.SetHex(mac, "231d39c1d7cc1ab1aee224cd096db932"); err != nil {
+		t.Fatalf("SetHex() error = %v", err)
+	}
+	d.BTHomeDecoder = bthome.NewDecoder(keys)
+
+	// Parse twice: repeated advertisements are still reported.
+	for i := 0; i < 2; i++ {
+		device = d.parseAdvertisement(adv)
+		if device.BTHome == nil || !device.BTHome.Encrypted {
+			t.Fatalf("BTHome = %+v, want decrypted packet", device.BTHome)
+		}
+		if device.BTHomeData == nil || device.BTHomeData.Temperature == nil || *device.BTHomeData.Temperature != 25.06 {
+			t.Errorf("Temperature = %v, want 25.06", device.BTHomeData)
+		}
+		if device.BTHomeData.Humidity == nil || *device.BTHomeData.Humidity != 50.55 {
+			t.Errorf("Humidity = %v, want 50.55", device.BTHomeData.Humidity)
+		}
+	}
+}
+
+func TestBthomeDataFromPacket(t *testing.T) {
+	// packet id 9, battery 80, illuminance 13460.67, motion, window open,
+	// button long press, rotation 307.4
+	packet, err := bthome.Parse([]byte{
+		0x40, 0x00, 0x09, 0x01, 0x50, 0x05, 0x13, 0x8a, 0x14, 0x21, 0x01,
+		0x2d, 0x01, 0x3a, 0x04, 0x3f, 0x02, 0x0c,
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	data := bthomeDataFromPacket(packet)
+	if data.PacketID != 9 {
+		t.Errorf("PacketID = %d, want 9", data.PacketID)
+	}
+	if data.Battery == nil || *data.Battery != 80 {
+		t.Errorf("Battery = %v, want 80", data.Battery)
+	}
+	if data.Illuminance == nil || *data.Illuminance != 1346067 {
+		t.Errorf("Illuminance = %v, want 1346067", data.Illuminance)
+	}
+	if data.Motion == nil || !*data.Motion {
+		t.Error("Motion should be true")
+	}
+	if data.WindowOpen == nil || !*data.WindowOpen {
+		t.Error("WindowOpen should be true")
+	}
+	if data.Button == nil || *data.Button != 4 {
+		t.Errorf("Button = %v, want 4", data.Button)
+	}
+	if data.Rotation == nil || *data.Rotation != 307.4 {
+		t.Errorf("Rotation = %v, want 307.4", data.Rotation)
+	}
+}
+
 // Test Discover and DiscoverWithContext
 
 func TestBLEDiscoverer_Discover(t *testing.T) {
@@ -7,6 +7,7 @@
 //     the _shelly._tcp.local service
 //   - CoIoT: CoAP-based multicast discovery for Gen1 devices
 //   - BLE: Bluetooth Low Energy discovery for device provisioning
+//   - HTTP probing: sweeps of address ranges for networks without multicast
 //
 // # Quick Start
 //
@@ -49,6 +50,71 @@
 //	    log.Fatal(err)
 //	}
 //
+// # Remote BLE Scanning
+//
+// Gen2+ devices with Bluetooth can scan on behalf of the host. A
+// RemoteBLEScanner deploys a small scanning script to each gateway and
+// receives advertisements as NotifyEvent, so the gateway clients must use a
+// transport that delivers notifications (WebSocket or MQTT):
+//
+//	ble := discovery.NewRemoteBLEDiscoverer(
+//	    discovery.RemoteBLEGateway{Client: kitchen, ID: "kitchen"},
+//	    discovery.RemoteBLEGateway{Client: garage, ID: "garage"},
+//	)
+//	scanner := discovery.NewScanner(discovery.WithDiscoverer(ble))
+//
+// BLEDiscoveredDevice.Gateway records which gateway heard the device.
+//
+// # HTTP Probing
+//
+// An HTTPProber probes every address of a set of CIDRs and ranges and
+// streams devices as they answer. It can probe the hosts in the Linux ARP
+// table first and cap the probe rate on sensitive networks:
+//
+//	p := discovery.NewHTTPProber(
+//	    discovery.WithTargets("192.168.1.0/24", "192.168.2.10-50"),
+//	    discovery.WithARPSeed(true),
+//	    discovery.WithRateLimit(100))
+//
+//	devices, err := p.Probe(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for d := range devices {
+//	    fmt.Printf("Found: %s at %s\n", d.Model, d.Address)
+//	}
+//
+// Canceling ctx stops the sweep and closes the channel. HTTPProber is a
+// Discoverer, so it can also run in a Scanner via WithDiscoverer.
+//
+// # Continuous Discovery
+//
+// A Watcher keeps scanning and tracks which devices are present. It keys
+// devices by MAC address, so a device that moves to a new IP address stays
+// one inventory entry, and publishes presence changes to an event bus:
+//
+//	bus := events.NewEventBus()
+//	bus.Subscribe(func(e events.Event) {
+//	    switch e := e.(type) {
+//	    case *events.DeviceOnlineEvent:
+//	        fmt.Printf("%s online at %s\n", e.DeviceID(), e.Address)
+//	    case *events.DeviceAddressChangedEvent:
+//	        fmt.Printf("%s moved to %s\n", e.DeviceID(), e.Address)
+//	    case *events.DeviceOfflineEvent:
+//	        fmt.Printf("%s offline\n", e.DeviceID())
+//	    }
+//	})
+//
+//	w := discovery.NewWatcher(
+//	    discovery.WithEventBus(bus),
+//	    discovery.WatchHTTP("192.168.1.0/24"), // for networks that drop multicast
+//	    discovery.WithLivenessTimeout(2*time.Minute))
+//	go w.Run(ctx)
+//
+//	for _, d := range w.Devices() {
+//	    fmt.Printf("%s %s fw %s, first seen %s\n", d.Key, d.Model, d.Firmware, d.FirstSeen)
+//	}
+//
 // # Device Identification
 //
 // The identify subpackage provides device fingerprinting:
@@ -299,28 +299,10 @@ func ProbeAddressesWithProgress(
 				return
 			}
 
-			info, err := IdentifyWithTimeout(ctx, address, 2*time.Second)
-
-			var device *DiscoveredDevice
+			device, err := probeAddress(ctx, address, DefaultProbeTimeout)
 			if err == nil {
-				d := DiscoveredDevice{
-					ID:           info.ID,
-					Name:         info.Name,
-					Model:        info.Model,
-					Generation:   info.Generation,
-					Address:      parseIP(address),
-					Port:         80,
-					MACAddress:   info.MACAddress,
-					Firmware:     info.Firmware,
-					AuthRequired: info.AuthRequired,
-					Protocol:     ProtocolManual,
-					LastSeen:     time.Now(),
-					Raw:          info.Raw,
-				}
-				device = &d
-
 				mu.Lock()
-				devices = append(devices, d)
+				devices = append(devices, *device)
 				mu.Unlock()
 			}
 
@@ -0,0 +1,459 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP prober defaults.
+const (
+	// DefaultProbeConcurrency is the default number of addresses probed at
+	// once.
+	DefaultProbeConcurrency = 20
+
+	// DefaultProbeTimeout is the default timeout for probing one address.
+	DefaultProbeTimeout = 2 * time.Second
+
+	// MaxProbeAddresses is the largest number of addresses a prober
+	// sweeps, which keeps a mistyped /8 from running for days.
+	MaxProbeAddresses = 1 << 16
+)
+
+// linuxARPTable is the Linux kernel's IPv4 neighbour table.
+const linuxARPTable = "/proc/net/arp"
+
+// HTTP prober errors.
+var (
+	// ErrNoProbeTargets is returned when a prober has no addresses to probe.
+	ErrNoProbeTargets = errors.New("no probe targets")
+
+	// ErrTooManyAddresses is returned when the targets expand to more than
+	// MaxProbeAddresses addresses.
+	ErrTooManyAddresses = errors.New("too many probe addresses")
+)
+
+// ProberOption configures an HTTPProber.
+type ProberOption func(*HTTPProber)
+
+// WithTargets adds addresses to probe. Each target is a CIDR
+// ("192.168.1.0/24"), a range ("192.168.1.10-192.168.1.50" or
+// "192.168.1.10-50"), or a single address with an optional port.
+func WithTargets(targets ...string) ProberOption {
+	return func(p *HTTPProber) {
+		p.targets = append(p.targets, targets...)
+	}
+}
+
+// WithARPSeed probes the hosts in the Linux neighbour table first. With
+// no other targets only those hosts are probed; otherwise only those
+// within the targets are. Ignored on systems without /proc/net/arp.
+func WithARPSeed(enable bool) ProberOption {
+	return func(p *HTTPProber) {
+		p.arp = enable
+	}
+}
+
+// WithConcurrency sets how many addresses are probed at once.
+func WithConcurrency(n int) ProberOption {
+	return func(p *HTTPProber) {
+		if n > 0 {
+			p.concurrency = n
+		}
+	}
+}
+
+// WithRateLimit caps how many probes start per second. Zero, the default,
+// leaves only the concurrency limit.
+func WithRateLimit(perSecond float64) ProberOption {
+	return func(p *HTTPProber) {
+		if perSecond >= 0 {
+			p.rate = perSecond
+		}
+	}
+}
+
+// WithProbeTimeout sets the timeout for probing one address.
+func WithProbeTimeout(d time.Duration) ProberOption {
+	return func(p *HTTPProber) {
+		if d > 0 {
+			p.timeout = d
+		}
+	}
+}
+
+// HTTPProber discovers devices by probing each address of a set of
+// networks over HTTP.
+//
+// Probing is slower than mDNS or CoIoT but finds devices of every
+// generation, including on networks that drop multicast traffic.
+// HTTPProber implements Discoverer, so it can be added to a Scanner with
+// WithDiscoverer.
+type HTTPProber struct {
+	cancel      context.CancelFunc
+	devicesCh   chan DiscoveredDevice
+	arpTable    string
+	targets     []string
+	rate        float64
+	timeout     time.Duration
+	concurrency int
+	mu          sync.Mutex
+	arp         bool
+}
+
+// NewHTTPProber creates an HTTP prober.
+//
+// Example:
+//
+//	p := discovery.NewHTTPProber(
+//	    discovery.WithTargets("192.168.1.0/24", "10.0.0.20-10.0.0.40"),
+//	    discovery.WithARPSeed(true),
+//	    discovery.WithRateLimit(50))
+//
+//	devices, err := p.Probe(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for d := range devices {
+//	    fmt.Printf("%s at %s\n", d.Model, d.Address)
+//	}
+func NewHTTPProber(opts ...ProberOption) *HTTPProber {
+	p := &HTTPProber{
+		arpTable:    linuxARPTable,
+		concurrency: DefaultProbeConcurrency,
+		timeout:     DefaultProbeTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Addresses returns the addresses a sweep probes, in probe order.
+func (p *HTTPProber) Addresses() ([]string, error) {
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, target := range p.targets {
+		expanded, err := expandTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range expanded {
+			if !seen[addr] {
+				seen[addr] = true
+				addresses = append(addresses, addr)
+			}
+		}
+		if len(addresses) > MaxProbeAddresses {
+			return nil, fmt.Errorf("%w: more than %d", ErrTooManyAddresses, MaxProbeAddresses)
+		}
+	}
+
+	if p.arp {
+		neighbors, err := readARPTable(p.arpTable)
+		if err != nil && len(addresses) == 0 {
+			return nil, fmt.Errorf("failed to read neighbour table: %w", err)
+		}
+		addresses = seedAddresses(addresses, neighbors)
+	}
+
+	if len(addresses) == 0 {
+		return nil, ErrNoProbeTargets
+	}
+	return addresses, nil
+}
+
+// seedAddresses moves neighbors to the front of addresses. With no
+// addresses the neighbors are used as they are.
+func seedAddresses(addresses, neighbors []string) []string {
+	if len(addresses) == 0 {
+		return neighbors
+	}
+	known := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		known[addr] = true
+	}
+	seeded := make(map[string]bool)
+	ordered := make([]string, 0, len(addresses))
+	for _, n := range neighbors {
+		if known[n] && !seeded[n] {
+			seeded[n] = true
+			ordered = append(ordered, n)
+		}
+	}
+	for _, addr := range addresses {
+		if !seeded[addr] {
+			ordered = append(ordered, addr)
+		}
+	}
+	return ordered
+}
+
+// Probe starts a sweep and returns a channel of the devices found, closed
+// when the sweep finishes or ctx is canceled.
+func (p *HTTPProber) Probe(ctx context.Context) (<-chan DiscoveredDevice, error) {
+	addresses, err := p.Addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan DiscoveredDevice, p.concurrency)
+	go p.sweep(ctx, addresses, ch)
+	return ch, nil
+}
+
+// sweep probes addresses and sends the devices found to ch, closing it
+// when done.
+func (p *HTTPProber) sweep(ctx context.Context, addresses []string, ch chan<- DiscoveredDevice) {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	var tick <-chan time.Time
+	if p.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / p.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	sem := make(chan struct{}, p.concurrency)
+	for i, addr := range addresses {
+		if tick != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			device, err := probeAddress(ctx, addr, p.timeout)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- *device:
+			case <-ctx.Done():
+			}
+		}()
+	}
+}
+
+// DiscoverWithContext sweeps the targets and returns the devices found.
+// When ctx is canceled the devices found so far are returned.
+func (p *HTTPProber) DiscoverWithContext(ctx context.Context) ([]DiscoveredDevice, error) {
+	ch, err := p.Probe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []DiscoveredDevice
+	for d := range ch {
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// Discover sweeps the targets, stopping after timeout.
+func (p *HTTPProber) Discover(timeout time.Duration) ([]DiscoveredDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return p.DiscoverWithContext(ctx)
+}
+
+// StartDiscovery starts a sweep in the background. The channel is closed
+// when the sweep finishes or StopDiscovery is called.
+func (p *HTTPProber) StartDiscovery() (<-chan DiscoveredDevice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		return p.devicesCh, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	addresses, err := p.Addresses()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	ch := make(chan DiscoveredDevice, p.concurrency)
+	p.cancel = cancel
+	p.devicesCh = ch
+	go func() {
+		p.sweep(ctx, addresses, ch)
+		cancel()
+
+		// Clear the state unless StopDiscovery already did or another
+		// sweep has started since.
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.devicesCh == ch {
+			p.cancel = nil
+			p.devicesCh = nil
+		}
+	}()
+
+	return p.devicesCh, nil
+}
+
+// StopDiscovery stops a sweep started by StartDiscovery.
+func (p *HTTPProber) StopDiscovery() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+		p.devicesCh = nil
+	}
+	return nil
+}
+
+// Stop stops the prober and releases resources.
+func (p *HTTPProber) Stop() error {
+	return p.StopDiscovery()
+}
+
+// probeAddress identifies the device at address.
+func probeAddress(ctx context.Context, address string, timeout time.Duration) (*DiscoveredDevice, error) {
+	info, err := IdentifyWithTimeout(ctx, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	port := 80
+	if _, p, err := net.SplitHostPort(address); err == nil {
+		if n, err := strconv.Atoi(p); err == nil {
+			port = n
+		}
+	}
+	return &DiscoveredDevice{
+		ID:           info.ID,
+		Name:         info.Name,
+		Model:        info.Model,
+		Generation:   info.Generation,
+		Address:      parseIP(address),
+		Port:         port,
+		MACAddress:   info.MACAddress,
+		Firmware:     info.Firmware,
+		AuthRequired: info.AuthRequired,
+		Protocol:     ProtocolManual,
+		LastSeen:     time.Now(),
+		Raw:          info.Raw,
+	}, nil
+}
+
+// expandTarget returns the addresses of a CIDR, range or single address.
+func expandTarget(target string) ([]string, error) {
+	target = strings.TrimSpace(target)
+	switch {
+	case strings.Contains(target, "/"):
+		_, ipnet, err := net.ParseCIDR(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", target, err)
+		}
+		if ones, bits := ipnet.Mask.Size(); bits-ones > 16 {
+			return nil, fmt.Errorf("%w: %s", ErrTooManyAddresses, target)
+		}
+		return GenerateSubnetAddresses(target), nil
+	case strings.Contains(target, "-"):
+		return expandRange(target)
+	default:
+		host := target
+		if h, _, err := net.SplitHostPort(target); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid address %q", target)
+		}
+		return []string{target}, nil
+	}
+}
+
+// expandRange returns the addresses of an IPv4 range such as
+// "192.168.1.10-192.168.1.50" or "192.168.1.10-50".
+func expandRange(target string) ([]string, error) {
+	from, to, _ := strings.Cut(target, "-")
+	start := net.ParseIP(strings.TrimSpace(from)).To4()
+	if start == nil {
+		return nil, fmt.Errorf("invalid range %q: bad start address", target)
+	}
+	to = strings.TrimSpace(to)
+	if !strings.Contains(to, ".") {
+		to = fmt.Sprintf("%d.%d.%d.%s", start[0], start[1], start[2], to)
+	}
+	end := net.ParseIP(to).To4()
+	if end == nil {
+		return nil, fmt.Errorf("invalid range %q: bad end address", target)
+	}
+
+	first, last := ipv4ToUint(start), ipv4ToUint(end)
+	if first > last {
+		return nil, fmt.Errorf("invalid range %q: start after end", target)
+	}
+	if last-first >= MaxProbeAddresses {
+		return nil, fmt.Errorf("%w: %s", ErrTooManyAddresses, target)
+	}
+
+	addresses := make([]string, 0, last-first+1)
+	// Stop on last rather than n > last, which never holds at 255.255.255.255.
+	for n := first; ; n++ {
+		addresses = append(addresses, net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).String())
+		if n == last {
+			break
+		}
+	}
+	return addresses, nil
+}
+
+func ipv4ToUint(ip net.IP) uint32 {
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+// readARPTable returns the IPv4 addresses of the complete entries in a
+// Linux /proc/net/arp table.
+func readARPTable(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var addresses []string
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		// Flags 0x0 marks an incomplete entry without a hardware address.
+		if fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		if ip := net.ParseIP(fields[0]).To4(); ip != nil {
+			addresses = append(addresses, ip.String())
+		}
+	}
+	return addresses, scanner.Err()
+}
@@ -0,0 +1,250 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newShellyServer(t *testing.T, id string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/shelly" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gen2ShellyResponse{ID: id, Model: "SNSW-001X16EU", Gen: 2})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPProber_Addresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "cidr",
+			targets: []string{"192.168.1.0/30"},
+			want:    []string{"192.168.1.1", "192.168.1.2"},
+		},
+		{
+			name:    "full range",
+			targets: []string{"10.0.0.254-10.0.1.1"},
+			want:    []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"},
+		},
+		{
+			name:    "short range and duplicates",
+			targets: []string{"192.168.1.10-12", "192.168.1.11", "192.168.1.20:8080"},
+			want:    []string{"192.168.1.10", "192.168.1.11", "192.168.1.12", "192.168.1.20:8080"},
+		},
+		{
+			name:    "end of address space",
+			targets: []string{"255.255.255.254-255"},
+			want:    []string{"255.255.255.254", "255.255.255.255"},
+		},
+		{
+			name:    "too large",
+			targets: []string{"10.0.0.0/8"},
+			wantErr: ErrTooManyAddresses,
+		},
+		{
+			name:    "no targets",
+			wantErr: ErrNoProbeTargets,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewHTTPProber(WithTargets(tt.targets...)).Addresses()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Addresses() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Addresses() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Addresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"192.168.1.0/33", "192.168.1.20-10", "192.168.1.1-x", "shelly.local"} {
+		if _, err := NewHTTPProber(WithTargets(bad)).Addresses(); err == nil {
+			t.Errorf("Addresses(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestHTTPProber_ARPSeed(t *testing.T) {
+	table := filepath.Join(t.TempDir(), "arp")
+	err := os.WriteFile(table, []byte(strings.Join([]string{
+		"IP address       HW type     Flags       HW address            Mask     Device",
+		"192.168.1.2      0x1         0x2         a8:03:2a:b1:23:45     *        eth0",
+		"192.168.1.9      0x1         0x0         00:00:00:00:00:00     *        eth0",
+		"10.0.0.5         0x1         0x2         c4:5b:be:6c:4d:dc     *        wlan0",
+	}, "\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewHTTPProber(WithARPSeed(true))
+	p.arpTable = table
+	got, err := p.Addresses()
+	if err != nil || !slices.Equal(got, []string{"192.168.1.2", "10.0.0.5"}) {
+		t.Errorf("Addresses() = %v, %v; want the complete neighbours", got, err)
+	}
+
+	p = NewHTTPProber(WithARPSeed(true), WithTargets("192.168.1.0/29"))
+	p.arpTable = table
+	got, err = p.Addresses()
+	want := []string{"192.168.1.2", "192.168.1.1", "192.168.1.3", "192.168.1.4", "192.168.1.5", "192.168.1.6"}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("Addresses() = %v, %v; want %v", got, err, want)
+	}
+
+	p = NewHTTPProber(WithARPSeed(true))
+	p.arpTable = filepath.Join(t.TempDir(), "missing")
+	if _, err := p.Addresses(); err == nil {
+		t.Error("Addresses() without a neighbour table or targets: error = nil")
+	}
+}
+
+func TestHTTPProber_Probe(t *testing.T) {
+	a := newShellyServer(t, "shellyplus1-a8032ab12345")
+	b := newShellyServer(t, "shellyplus1-a8032ab12346")
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+
+	p := NewHTTPProber(
+		WithTargets(a.Listener.Addr().String(), b.Listener.Addr().String(), empty.Listener.Addr().String()),
+		WithConcurrency(2),
+		WithProbeTimeout(time.Second))
+	ch, err := p.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+
+	var ids []string
+	for d := range ch {
+		if d.Protocol != ProtocolManual || d.Address.String() != "127.0.0.1" || d.Port == 80 {
+			t.Errorf("device = %+v", d)
+		}
+		ids = append(ids, d.ID)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"shellyplus1-a8032ab12345", "shellyplus1-a8032ab12346"}) {
+		t.Errorf("found %v", ids)
+	}
+}
+
+func TestHTTPProber_RateLimit(t *testing.T) {
+	a := newShellyServer(t, "shellyplus1-a8032ab12345")
+	targets := []string{a.Listener.Addr().String(), "127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3"}
+
+	start := time.Now()
+	devices, err := NewHTTPProber(WithTargets(targets...), WithRateLimit(20)).Discover(5 * time.Second)
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover() = %d devices, %v", len(devices), err)
+	}
+	// Four probes at 20/s start over at least 150ms.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("sweep took %v, want rate limited", elapsed)
+	}
+}
+
+func TestHTTPProber_Cancel(t *testing.T) {
+	p := NewHTTPProber(WithTargets("127.0.0.1:1-200"), WithRateLimit(10))
+	if _, err := p.Addresses(); err == nil {
+		t.Fatal("Addresses() accepted a port in a range")
+	}
+
+	p = NewHTTPProber(WithTargets("192.0.2.1-254"), WithRateLimit(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := p.Probe(ctx)
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("received a device from a canceled sweep")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
+
+func TestHTTPProber_StartDiscovery(t *testing.T) {
+	a := newShellyServer(t, "shellyplus1-a8032ab12345")
+	p := NewHTTPProber(WithTargets(a.Listener.Addr().String()))
+
+	ch, err := p.StartDiscovery()
+	if err != nil {
+		t.Fatalf("StartDiscovery() error = %v", err)
+	}
+	d, ok := <-ch
+	if !ok || d.ID != "shellyplus1-a8032ab12345" {
+		t.Errorf("StartDiscovery() sent %+v, %v", d, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after the sweep")
+	}
+	if err := p.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestHTTPProber_StopDiscovery(t *testing.T) {
+	p := NewHTTPProber(WithTargets("192.0.2.0/24"), WithRateLimit(10))
+
+	ch, err := p.StartDiscovery()
+	if err != nil {
+		t.Fatalf("StartDiscovery() error = %v", err)
+	}
+	if err := p.StopDiscovery(); err != nil {
+		t.Fatalf("StopDiscovery() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range ch {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after StopDiscovery")
+	}
+
+	// A new sweep can start once the previous one is stopped.
+	ch2, err := p.StartDiscovery()
+	if err != nil {
+		t.Fatalf("StartDiscovery() after stop error = %v", err)
+	}
+	if ch2 == ch {
+		t.Error("StartDiscovery() returned the stopped sweep's channel")
+	}
+	if err := p.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	for range ch2 {
+	}
+}
@@ -0,0 +1,419 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/scripting"
+)
+
+// Remote BLE scanning defaults.
+const (
+	// RemoteBLEScriptName is the name of the scanning script on gateways.
+	RemoteBLEScriptName = "shelly-go-ble-scan"
+
+	// RemoteBLEEvent is the event name the scanning script emits for each
+	// forwarded advertisement.
+	RemoteBLEEvent = "ble_adv"
+)
+
+// remoteBLEScript forwards BLE advertisements as NotifyEvent. The raw
+// advertising data is base64-encoded since script strings are binary.
+const remoteBLEScript = `// Managed by shelly-go: forwards BLE advertisements as "%EVENT%" events.
+let ACTIVE = %ACTIVE%;
+let FORWARD_ALL = %FORWARD_ALL%;
+let SHELLY_UUID = "5f6d4f53-5f52-5043-5f53-56435f49445f";
+
+function hasShellyUUID(uuids) {
+  if (typeof uuids !== "object" || uuids === null) return false;
+  for (let i = 0; i < uuids.length; i++) {
+    if (uuids[i] === SHELLY_UUID) return true;
+  }
+  return false;
+}
+
+function wanted(res) {
+  if (FORWARD_ALL) return true;
+  if (typeof res.service_data === "object" && res.service_data !== null &&
+      typeof res.service_data["fcd2"] === "string") return true;
+  if (typeof res.local_name === "string" &&
+      (res.local_name.indexOf("Shelly") === 0 || res.local_name.indexOf("SHELLY") === 0)) return true;
+  return hasShellyUUID(res.service_uuids);
+}
+
+function onScan(ev, res) {
+  if (ev !== BLE.Scanner.SCAN_RESULT || !wanted(res)) return;
+  Shelly.emitEvent("%EVENT%", {
+    addr: res.addr,
+    rssi: res.rssi,
+    adv: btoa(res.advData),
+    rsp: typeof res.scanRsp === "string" ? btoa(res.scanRsp) : ""
+  });
+}
+
+BLE.Scanner.Subscribe(onScan);
+if (!BLE.Scanner.isRunning()) {
+  BLE.Scanner.Start({ duration_ms: BLE.Scanner.INFINITE_SCAN, active: ACTIVE });
+}
+`
+
+// RemoteBLEScript returns the scanning script deployed by
+// RemoteBLEScanner. active requests scan responses (more power, more
+// data); forwardAll forwards every advertisement instead of only Shelly
+// and BTHome ones.
+func RemoteBLEScript(active, forwardAll bool) string {
+	return strings.NewReplacer(
+		"%EVENT%", RemoteBLEEvent,
+		"%ACTIVE%", strconv.FormatBool(active),
+		"%FORWARD_ALL%", strconv.FormatBool(forwardAll),
+	).Replace(remoteBLEScript)
+}
+
+// RemoteBLEGateway is a Gen2+ device used as a remote BLE scanner.
+type RemoteBLEGateway struct {
+	// Client is the RPC client for the device. Its transport must deliver
+	// notifications (WebSocket or MQTT).
+	Client *rpc.Client
+
+	// ID identifies the gateway in BLEDiscoveredDevice.Gateway.
+	ID string
+}
+
+// remoteBLEGateway is the scanning state of one gateway.
+type remoteBLEGateway struct {
+	client     *rpc.Client
+	id         string
+	scriptID   int
+	deployed   bool
+	subscribed bool
+}
+
+// RemoteBLEScanner is a BLEScanner that scans through the Bluetooth radio
+// of Gen2+ devices instead of a local adapter.
+//
+// Start deploys a small BLE.Scanner script to every gateway (through
+// scripting.Manager, so an unchanged script is not re-uploaded) and
+// converts the advertisements it emits as NotifyEvent into
+// BLEAdvertisement values. Stop stops the scripts again unless
+// KeepScript is set.
+type RemoteBLEScanner struct {
+	// OnGatewayError is called when a gateway cannot be set up or stopped.
+	// Scanning continues on the remaining gateways.
+	OnGatewayError func(gatewayID string, err error)
+	callback       func(*BLEAdvertisement)
+	stopCh         chan struct{}
+	gateways       []*remoteBLEGateway
+	mu             sync.Mutex
+	running        bool
+
+	// Active requests scan responses from advertisers.
+	Active bool
+
+	// ForwardAll forwards every advertisement, not only those from Shelly
+	// and BTHome devices.
+	ForwardAll bool
+
+	// KeepScript leaves the scanning script running after Stop.
+	KeepScript bool
+}
+
+// NewRemoteBLEScanner creates a scanner using the given gateways.
+func NewRemoteBLEScanner(gateways ...RemoteBLEGateway) *RemoteBLEScanner {
+	s := &RemoteBLEScanner{}
+	for _, gw := range gateways {
+		s.gateways = append(s.gateways, &remoteBLEGateway{client: gw.Client, id: gw.ID})
+	}
+	return s
+}
+
+// NewRemoteBLEDiscoverer creates a BLEDiscoverer that scans through the
+// given gateways. It can be added to a Scanner with WithDiscoverer.
+func NewRemoteBLEDiscoverer(gateways ...RemoteBLEGateway) *BLEDiscoverer {
+	return NewBLEDiscovererWithScanner(NewRemoteBLEScanner(gateways...))
+}
+
+// Start deploys and starts the scanning script on every gateway and
+// delivers advertisements to callback until ctx is done or Stop is called.
+// When ctx is done it stops the scan as Stop does, so the next Start
+// begins a new one.
+//
+// Start fails only if no gateway could be set up.
+func (s *RemoteBLEScanner) Start(ctx context.Context, callback func(*BLEAdvertisement)) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.callback = callback
+	s.stopCh = make(chan struct{})
+	s.running = true
+	stopCh := s.stopCh
+	gateways := append([]*remoteBLEGateway(nil), s.gateways...)
+	s.mu.Unlock()
+
+	var errs []error
+	ready := 0
+	for _, gw := range gateways {
+		if err := s.setup(ctx, gw); err != nil {
+			errs = append(errs, fmt.Errorf("gateway %s: %w", gw.id, err))
+			s.gatewayError(gw.id, err)
+			continue
+		}
+		ready++
+	}
+	if ready == 0 && len(gateways) > 0 {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+		return errors.Join(errs...)
+	}
+
+	select {
+	case <-ctx.Done():
+		// Errors are reported through OnGatewayError.
+		s.Stop() //nolint:errcheck // Best effort
+	case <-stopCh:
+	}
+	return nil
+}
+
+// Stop stops delivering advertisements and, unless KeepScript is set,
+// stops the scanning script on every gateway.
+func (s *RemoteBLEScanner) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	s.callback = nil
+	close(s.stopCh)
+	gateways := append([]*remoteBLEGateway(nil), s.gateways...)
+	s.mu.Unlock()
+
+	if s.KeepScript {
+		return nil
+	}
+
+	var errs []error
+	for _, gw := range gateways {
+		s.mu.Lock()
+		deployed, scriptID := gw.deployed, gw.scriptID
+		gw.deployed = false
+		s.mu.Unlock()
+		if !deployed {
+			continue
+		}
+		// Use a fresh context: Stop usually runs after the scan context expired.
+		if err := components.NewScript(gw.client).Stop(context.Background(), scriptID); err != nil {
+			errs = append(errs, fmt.Errorf("gateway %s: %w", gw.id, err))
+			s.gatewayError(gw.id, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setup deploys the script if needed and subscribes to its events.
+func (s *RemoteBLEScanner) setup(ctx context.Context, gw *remoteBLEGateway) error {
+	s.mu.Lock()
+	subscribe := !gw.subscribed
+	gw.subscribed = true
+	deployed := gw.deployed
+	s.mu.Unlock()
+
+	if subscribe {
+		gw.client.OnNotificationMethod("NotifyEvent", func(params json.RawMessage) {
+			s.handleNotifyEvent(gw, params)
+		})
+	}
+	if deployed {
+		return nil
+	}
+
+	// Only run the script on boot if it is meant to outlive the scan.
+	result, err := scripting.New(gw.client).Sync(ctx, []scripting.Source{{
+		Name: RemoteBLEScriptName,
+		Code: RemoteBLEScript(s.Active, s.ForwardAll),
+	}}, &scripting.SyncOptions{Start: true, Enable: s.KeepScript})
+	if err != nil {
+		return fmt.Errorf("failed to deploy scanning script: %w", err)
+	}
+	res := result.Scripts[0]
+	if res.Action == scripting.ActionFailed {
+		return fmt.Errorf("failed to deploy scanning script: %w", res.Error)
+	}
+	s.mu.Lock()
+	gw.scriptID = res.ID
+	gw.deployed = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RemoteBLEScanner) gatewayError(id string, err error) {
+	if s.OnGatewayError != nil {
+		s.OnGatewayError(id, err)
+	}
+}
+
+// remoteBLEEventData is the payload emitted by the scanning script.
+type remoteBLEEventData struct {
+	Addr string `json:"addr"`
+	Adv  string `json:"adv"`
+	Rsp  string `json:"rsp"`
+	RSSI int    `json:"rssi"`
+}
+
+// handleNotifyEvent converts script events into advertisements.
+func (s *RemoteBLEScanner) handleNotifyEvent(gw *remoteBLEGateway, params json.RawMessage) {
+	var p struct {
+		Events []struct {
+			Component string             `json:"component"`
+			Event     string             `json:"event"`
+			Data      remoteBLEEventData `json:"data"`
+		} `json:"events"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+
+	s.mu.Lock()
+	callback := s.callback
+	scriptKey := "script:" + strconv.Itoa(gw.scriptID)
+	deployed := gw.deployed
+	s.mu.Unlock()
+	if callback == nil || !deployed {
+		return
+	}
+
+	for _, e := range p.Events {
+		if e.Event != RemoteBLEEvent || e.Component != scriptKey {
+			continue
+		}
+		adv, err := remoteAdvertisement(gw.id, &e.Data)
+		if err != nil {
+			continue
+		}
+		callback(adv)
+	}
+}
+
+// remoteAdvertisement decodes a forwarded advertisement.
+func remoteAdvertisement(gatewayID string, data *remoteBLEEventData) (*BLEAdvertisement, error) {
+	advData, err := base64.StdEncoding.DecodeString(data.Adv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode advertising data: %w", err)
+	}
+	rsp, err := base64.StdEncoding.DecodeString(data.Rsp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scan response: %w", err)
+	}
+
+	adv := &BLEAdvertisement{
+		Address:     strings.ToUpper(data.Addr),
+		RSSI:        data.RSSI,
+		ServiceData: make(map[string][]byte),
+		Gateway:     gatewayID,
+	}
+	parseADStructures(adv, advData)
+	parseADStructures(adv, rsp)
+	return adv, nil
+}
+
+// AD structure types (Bluetooth Core Specification Supplement, part A).
+const (
+	adFlags              = 0x01
+	adIncomplete16       = 0x02
+	adComplete16         = 0x03
+	adIncomplete128      = 0x06
+	adComplete128        = 0x07
+	adShortName          = 0x08
+	adCompleteName       = 0x09
+	adServiceData16      = 0x16
+	adServiceData128     = 0x21
+	adManufacturerData   = 0xFF
+	adFlagLimitedDisc    = 0x01
+	adFlagGeneralDisc    = 0x02
+	adFlagsDiscoverables = adFlagLimitedDisc | adFlagGeneralDisc
+)
+
+// parseADStructures decodes raw advertising data (length, type, value
+// structures) into adv. Malformed trailing data is ignored.
+func parseADStructures(adv *BLEAdvertisement, data []byte) {
+	for i := 0; i < len(data); {
+		length := int(data[i])
+		if length == 0 || i+1+length > len(data) {
+			return
+		}
+		adType, value := data[i+1], data[i+2:i+1+length]
+		i += 1 + length
+
+		switch adType {
+		case adFlags:
+			// Discoverable advertisers are connectable in practice; the
+			// connectable bit itself is in the PDU type, not the AD data.
+			if len(value) > 0 && value[0]&adFlagsDiscoverables != 0 {
+				adv.Connectable = true
+			}
+		case adIncomplete16, adComplete16:
+			for j := 0; j+2 <= len(value); j += 2 {
+				adv.ServiceUUIDs = appendUnique(adv.ServiceUUIDs, uuid16(value[j:j+2]))
+			}
+		case adIncomplete128, adComplete128:
+			for j := 0; j+16 <= len(value); j += 16 {
+				adv.ServiceUUIDs = appendUnique(adv.ServiceUUIDs, uuid128(value[j:j+16]))
+			}
+		case adShortName:
+			if adv.LocalName == "" {
+				adv.LocalName = string(value)
+			}
+		case adCompleteName:
+			adv.LocalName = string(value)
+		case adServiceData16:
+			if len(value) >= 2 {
+				adv.ServiceData[uuid16(value[:2])] = append([]byte(nil), value[2:]...)
+			}
+		case adServiceData128:
+			if len(value) >= 16 {
+				adv.ServiceData[uuid128(value[:16])] = append([]byte(nil), value[16:]...)
+			}
+		case adManufacturerData:
+			if len(value) >= 2 {
+				adv.ManufacturerID = uint16(value[0]) | uint16(value[1])<<8
+				adv.ManufacturerData = append([]byte(nil), value[2:]...)
+			}
+		}
+	}
+}
+
+// uuid16 formats a little-endian 16-bit UUID as four lower-case hex digits.
+func uuid16(b []byte) string {
+	return hex.EncodeToString([]byte{b[1], b[0]})
+}
+
+// uuid128 formats a little-endian 128-bit UUID in canonical form.
+func uuid128(b []byte) string {
+	r := make([]byte, 16)
+	for i := range r {
+		r[i] = b[15-i]
+	}
+	h := hex.EncodeToString(r)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
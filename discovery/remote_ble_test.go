@@ -0,0 +1,317 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/scripting/lint"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+// fakeScriptHost simulates the Script and KVS methods used to deploy the
+// scanning script.
+type fakeScriptHost struct {
+	started chan struct{}
+	failOn  string
+	calls   []string
+	mu      sync.Mutex
+}
+
+func (h *fakeScriptHost) client() *rpc.Client {
+	return rpc.NewClient(&mockTransport{callFunc: h.call})
+}
+
+func (h *fakeScriptHost) count(method string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, c := range h.calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+func (h *fakeScriptHost) call(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	method := req.GetMethod()
+	h.calls = append(h.calls, method)
+	if method == h.failOn {
+		return nil, errors.New("rpc failed")
+	}
+
+	switch method {
+	case "Script.List":
+		return jsonrpcResponse(map[string]any{"scripts": []any{}})
+	case "Script.Create":
+		return jsonrpcResponse(map[string]any{"id": 3})
+	case "Script.PutCode":
+		return jsonrpcResponse(map[string]any{"len": 10})
+	case "Script.Start":
+		if h.started != nil {
+			close(h.started)
+			h.started = nil
+		}
+		return jsonrpcResponse(map[string]any{"was_running": false})
+	case "Script.Stop":
+		return jsonrpcResponse(map[string]any{"was_running": true})
+	case "Script.GetStatus":
+		return jsonrpcResponse(map[string]any{"id": 3, "running": true})
+	case "KVS.Get":
+		return json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"error":   map[string]any{"code": -105, "message": "not found"},
+		})
+	case "KVS.Set":
+		return jsonrpcResponse(map[string]any{"etag": "e", "rev": 1})
+	}
+	return jsonrpcResponse(nil)
+}
+
+func TestRemoteBLEScript(t *testing.T) {
+	for _, active := range []bool{false, true} {
+		code := RemoteBLEScript(active, !active)
+		if result := lint.Check(code, nil); result.HasErrors() {
+			t.Errorf("RemoteBLEScript(%v) lint: %s", active, result.Summary())
+		}
+	}
+}
+
+func TestRemoteBLEScanner_Discover(t *testing.T) {
+	host := &fakeScriptHost{started: make(chan struct{})}
+	started := host.started
+	client := host.client()
+
+	discoverer := NewRemoteBLEDiscoverer(RemoteBLEGateway{Client: client, ID: "living-room"})
+	found := make(chan *BLEDiscoveredDevice, 1)
+	discoverer.OnDeviceFound = func(d *BLEDiscoveredDevice) {
+		found <- d
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		err     error
+		devices []DiscoveredDevice
+	}
+	done := make(chan result, 1)
+	go func() {
+		devices, err := discoverer.DiscoverWithContext(ctx)
+		done <- result{devices: devices, err: err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("script was not started")
+	}
+
+	// Flags, complete name "SBHT-003C" and BTHome service data with a
+	// temperature of 25.00 °C.
+	adv := []byte{
+		0x02, 0x01, 0x06,
+		0x0A, 0x09, 'S', 'B', 'H', 'T', '-', '0', '0', '3', 'C',
+		0x07, 0x16, 0xD2, 0xFC, 0x40, 0x02, 0xC4, 0x09,
+	}
+	event := func(component string) []byte {
+		data, _ := json.Marshal(map[string]any{
+			"method": "NotifyEvent",
+			"params": map[string]any{"events": []any{map[string]any{
+				"component": component,
+				"event":     RemoteBLEEvent,
+				"data": map[string]any{
+					"addr": "7c:c6:b6:00:00:01",
+					"rssi": -67,
+					"adv":  base64.StdEncoding.EncodeToString(adv),
+					"rsp":  "",
+				},
+			}}},
+		})
+		return data
+	}
+	// Events from other scripts are ignored.
+	if err := client.NotificationRouter().RouteRaw(event("script:1")); err != nil {
+		t.Fatalf("RouteRaw() error = %v", err)
+	}
+	if err := client.NotificationRouter().RouteRaw(event("script:3")); err != nil {
+		t.Fatalf("RouteRaw() error = %v", err)
+	}
+
+	var device *BLEDiscoveredDevice
+	select {
+	case device = <-found:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no device found")
+	}
+	cancel()
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("DiscoverWithContext() error = %v", res.err)
+	}
+	if len(res.devices) != 1 {
+		t.Fatalf("found %d devices, want 1", len(res.devices))
+	}
+
+	if device.Gateway != "living-room" || device.MACAddress != "7C:C6:B6:00:00:01" {
+		t.Errorf("device = %+v", device)
+	}
+	if device.LocalName != "SBHT-003C" || device.RSSI != -67 || !device.Connectable {
+		t.Errorf("device = %+v", device)
+	}
+	if device.BTHomeData == nil || device.BTHomeData.Temperature == nil || *device.BTHomeData.Temperature != 25 {
+		t.Errorf("BTHomeData = %+v", device.BTHomeData)
+	}
+	if n := host.count("Script.Stop"); n != 1 {
+		t.Errorf("Script.Stop called %d times, want 1", n)
+	}
+}
+
+func TestRemoteBLEScanner_GatewayErrors(t *testing.T) {
+	bad := &fakeScriptHost{failOn: "Script.List"}
+	scanner := NewRemoteBLEScanner(RemoteBLEGateway{Client: bad.client(), ID: "bad"})
+
+	var failed []string
+	scanner.OnGatewayError = func(id string, _ error) {
+		failed = append(failed, id)
+	}
+	err := scanner.Start(context.Background(), func(*BLEAdvertisement) {})
+	if err == nil {
+		t.Fatal("Start() should fail when no gateway can be set up")
+	}
+	if len(failed) != 1 || failed[0] != "bad" {
+		t.Errorf("OnGatewayError ids = %v", failed)
+	}
+	if err := scanner.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	// One working gateway is enough; KeepScript leaves it running.
+	good := &fakeScriptHost{}
+	scanner = NewRemoteBLEScanner(
+		RemoteBLEGateway{Client: bad.client(), ID: "bad"},
+		RemoteBLEGateway{Client: good.client(), ID: "good"},
+	)
+	scanner.KeepScript = true
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := scanner.Start(ctx, func(*BLEAdvertisement) {}); err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+	if err := scanner.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if good.count("Script.Stop") != 0 {
+		t.Error("Script.Stop should not be called with KeepScript")
+	}
+}
+
+func TestRemoteBLEScanner_ContinuousDiscovery(t *testing.T) {
+	host := &fakeScriptHost{}
+	discoverer := NewRemoteBLEDiscoverer(RemoteBLEGateway{Client: host.client(), ID: "living-room"})
+	discoverer.ScanDuration = 20 * time.Millisecond
+
+	if _, err := discoverer.StartDiscovery(); err != nil {
+		t.Fatalf("StartDiscovery() error = %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if err := discoverer.StopDiscovery(); err != nil {
+		t.Fatalf("StopDiscovery() error = %v", err)
+	}
+
+	// Each expired scan window stops the scan and the next starts it again.
+	if n := host.count("Script.Start"); n < 2 || n > 10 {
+		t.Errorf("Script.Start called %d times, want one per scan window", n)
+	}
+	if n := host.count("Script.Stop"); n < 1 {
+		t.Errorf("Script.Stop called %d times after expired windows", n)
+	}
+	// A window started as discovery stopped ends with its context.
+	scanner := discoverer.Scanner.(*RemoteBLEScanner)
+	running := func() bool {
+		scanner.mu.Lock()
+		defer scanner.mu.Unlock()
+		return scanner.running || scanner.callback != nil
+	}
+	deadline := time.Now().Add(time.Second)
+	for running() {
+		if time.Now().After(deadline) {
+			t.Fatal("scanner still running after StopDiscovery")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestParseADStructures(t *testing.T) {
+	// 128-bit UUID list with the Shelly RPC service, short name, manufacturer
+	// data and a truncated trailing structure.
+	uuid := []byte{
+		0x5F, 0x44, 0x49, 0x5F, 0x43, 0x56, 0x53, 0x5F,
+		0x43, 0x50, 0x52, 0x5F, 0x53, 0x4F, 0x6D, 0x5F,
+	}
+	data := append([]byte{0x11, 0x07}, uuid...)
+	data = append(data, 0x04, 0x08, 'S', 'h', 'y', 0x05, 0xFF, 0xA9, 0x0B, 0x01, 0x02, 0x09, 0x09)
+
+	adv := &BLEAdvertisement{ServiceData: make(map[string][]byte)}
+	parseADStructures(adv, data)
+
+	if len(adv.ServiceUUIDs) != 1 || adv.ServiceUUIDs[0] != "5f6d4f53-5f52-5043-5f53-56435f49445f" {
+		t.Errorf("ServiceUUIDs = %v", adv.ServiceUUIDs)
+	}
+	if adv.LocalName != "Shy" {
+		t.Errorf("LocalName = %q", adv.LocalName)
+	}
+	if adv.ManufacturerID != 0x0BA9 || len(adv.ManufacturerData) != 2 {
+		t.Errorf("manufacturer = %#x %v", adv.ManufacturerID, adv.ManufacturerData)
+	}
+	if adv.Connectable {
+		t.Error("Connectable should be false without flags")
+	}
+}
+
+func TestScanner_WithDiscoverer(t *testing.T) {
+	ble := NewBLEDiscovererWithScanner(newMockBLEScanner())
+	scanner := NewScanner(WithDiscoverer(ble), WithDiscoverer(nil))
+	if len(scanner.extra) != 1 {
+		t.Fatalf("extra discoverers = %d, want 1", len(scanner.extra))
+	}
+}
@@ -81,6 +81,7 @@ type Scanner struct {
 	ble         *BLEDiscoverer
 	wifi        *WiFiDiscoverer
 	devices     map[string]*DiscoveredDevice
+	extra       []Discoverer
 	enableMDNS  bool
 	enableCoIoT bool
 	enableBLE   bool
@@ -138,6 +139,16 @@ func WithWiFi(scanner WiFiScanner) ScannerOption {
 	}
 }
 
+// WithDiscoverer adds a discoverer that runs alongside the built-in
+// protocols, such as a remote BLE discoverer from NewRemoteBLEDiscoverer.
+func WithDiscoverer(d Discoverer) ScannerOption {
+	return func(s *Scanner) {
+		if d != nil {
+			s.extra = append(s.extra, d)
+		}
+	}
+}
+
 // Scan scans for devices using all enabled protocols.
 // Returns a deduplicated list of discovered devices.
 // Partial results are returned even if some discovery methods fail.
@@ -184,6 +195,14 @@ func (s *Scanner) Scan(timeout time.Duration) ([]DiscoveredDevice, error) {
 		}
 	}
 
+	// Additional discoverers
+	for _, d := range s.extra {
+		devices, err := d.Discover(timeout)
+		if err == nil {
+			allDevices = append(allDevices, devices...)
+		}
+	}
+
 	result := s.deduplicateDevices(allDevices)
 	return result, nil
 }
@@ -239,6 +258,11 @@ func (s *Scanner) Stop() error {
 			errs = append(errs, err)
 		}
 	}
+	for _, d := range s.extra {
+		if err := d.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if len(errs) > 0 {
 		return errs[0]
 	}
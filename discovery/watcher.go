@@ -0,0 +1,554 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/types"
+)
+
+// Watcher defaults.
+const (
+	// DefaultWatchInterval is how often mDNS and CoIoT scans run.
+	DefaultWatchInterval = 30 * time.Second
+
+	// DefaultScanWindow is how long each mDNS, CoIoT or added discoverer
+	// scan listens for responses.
+	DefaultScanWindow = 5 * time.Second
+
+	// DefaultSweepInterval is how often HTTP sweeps run.
+	DefaultSweepInterval = 5 * time.Minute
+
+	// DefaultLivenessTimeout is how long a device may go unseen before it
+	// is considered offline. Devices found only by HTTP sweeps get three
+	// sweep intervals instead.
+	DefaultLivenessTimeout = 2 * time.Minute
+)
+
+// TrackedDevice is a device in a Watcher's inventory.
+type TrackedDevice struct {
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// Key is the stable identity of the device: its normalized MAC address
+	// if known, else its ID.
+	Key string `json:"key"`
+
+	ID         string           `json:"id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	MACAddress string           `json:"mac_address,omitempty"`
+	Firmware   string           `json:"firmware,omitempty"`
+	Address    net.IP           `json:"address"`
+	Protocols  []Protocol       `json:"protocols"`
+	Generation types.Generation `json:"generation"`
+	Port       int              `json:"port"`
+
+	// Online reports whether the device was seen within its liveness
+	// timeout.
+	Online bool `json:"online"`
+
+	AuthRequired bool `json:"auth_required,omitempty"`
+}
+
+// WatcherOption configures a Watcher.
+type WatcherOption func(*Watcher)
+
+// WatchMDNS enables or disables periodic mDNS scans. Enabled by default.
+func WatchMDNS(enable bool) WatcherOption {
+	return func(w *Watcher) {
+		w.mdns = enable
+	}
+}
+
+// WatchCoIoT enables or disables periodic CoIoT scans. Enabled by default.
+func WatchCoIoT(enable bool) WatcherOption {
+	return func(w *Watcher) {
+		w.coiot = enable
+	}
+}
+
+// WatchHTTP enables HTTP sweeps of the given targets, which find devices
+// on networks that block multicast. Targets take the forms accepted by
+// WithTargets.
+func WatchHTTP(cidrs ...string) WatcherOption {
+	return func(w *Watcher) {
+		w.cidrs = append(w.cidrs, cidrs...)
+	}
+}
+
+// WatchDiscoverer adds a discoverer scanned every watch interval, such as
+// a BLE discoverer.
+func WatchDiscoverer(d Discoverer) WatcherOption {
+	return func(w *Watcher) {
+		if d != nil {
+			w.extra = append(w.extra, d)
+		}
+	}
+}
+
+// WithEventBus publishes DeviceOnlineEvent, DeviceOfflineEvent and
+// DeviceAddressChangedEvent to bus.
+func WithEventBus(bus *events.EventBus) WatcherOption {
+	return func(w *Watcher) {
+		w.bus = bus
+	}
+}
+
+// WithWatchInterval sets how often mDNS, CoIoT and added discoverers scan.
+func WithWatchInterval(d time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.interval = d
+		}
+	}
+}
+
+// WithScanWindow sets how long each scan listens for responses.
+func WithScanWindow(d time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.window = d
+		}
+	}
+}
+
+// WithSweepInterval sets how often HTTP sweeps run.
+func WithSweepInterval(d time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.sweepInterval = d
+		}
+	}
+}
+
+// WithLivenessTimeout sets how long a device may go unseen before it is
+// considered offline, for all protocols without their own timeout.
+func WithLivenessTimeout(d time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.liveness = d
+		}
+	}
+}
+
+// WithProtocolLivenessTimeout sets the liveness timeout of devices seen by
+// one protocol. A device seen by several protocols stays online until the
+// latest of their deadlines.
+func WithProtocolLivenessTimeout(p Protocol, d time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.protocolLiveness[p] = d
+		}
+	}
+}
+
+// WithCheckInterval sets how often devices are checked against their
+// liveness timeout.
+func WithCheckInterval(d time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.checkInterval = d
+		}
+	}
+}
+
+// WithWatchErrorHandler sets a callback for scan errors. Errors from
+// discoverers added with WatchDiscoverer have an empty protocol.
+func WithWatchErrorHandler(fn func(Protocol, error)) WatcherOption {
+	return func(w *Watcher) {
+		w.onError = fn
+	}
+}
+
+type trackedEntry struct {
+	device  TrackedDevice
+	expires time.Time
+}
+
+// Watcher runs discovery continuously and tracks device presence.
+//
+// Devices are tracked by MAC address, or by ID when the MAC isn't known,
+// so a device that changes IP address stays the same inventory entry. A
+// device goes offline when it hasn't been seen within its liveness
+// timeout and comes back online at its next sighting.
+type Watcher struct {
+	bus              *events.EventBus
+	onError          func(Protocol, error)
+	devices          map[string]*trackedEntry
+	aliases          map[string]string
+	protocolLiveness map[Protocol]time.Duration
+	cidrs            []string
+	extra            []Discoverer
+	interval         time.Duration
+	window           time.Duration
+	sweepInterval    time.Duration
+	liveness         time.Duration
+	checkInterval    time.Duration
+	mu               sync.Mutex
+	mdns             bool
+	coiot            bool
+}
+
+// NewWatcher creates a watcher. Call Run to start it.
+//
+// Example:
+//
+//	bus := events.NewEventBus()
+//	bus.SubscribeFiltered(events.WithEventTypes(events.EventTypeDeviceOffline), func(e events.Event) {
+//	    log.Printf("%s went offline", e.DeviceID())
+//	})
+//
+//	w := discovery.NewWatcher(
+//	    discovery.WithEventBus(bus),
+//	    discovery.WatchHTTP("192.168.1.0/24"))
+//	go w.Run(ctx)
+func NewWatcher(opts ...WatcherOption) *Watcher {
+	w := &Watcher{
+		devices:          make(map[string]*trackedEntry),
+		aliases:          make(map[string]string),
+		protocolLiveness: make(map[Protocol]time.Duration),
+		interval:         DefaultWatchInterval,
+		window:           DefaultScanWindow,
+		sweepInterval:    DefaultSweepInterval,
+		liveness:         DefaultLivenessTimeout,
+		checkInterval:    time.Second,
+		mdns:             true,
+		coiot:            true,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if _, ok := w.protocolLiveness[ProtocolManual]; !ok && len(w.cidrs) > 0 {
+		w.protocolLiveness[ProtocolManual] = max(3*w.sweepInterval, w.liveness)
+	}
+	return w
+}
+
+// Run scans until ctx is canceled and returns ctx.Err(). The first scans
+// start immediately.
+func (w *Watcher) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	start := func(p Protocol, interval time.Duration, scan func(context.Context) ([]DiscoveredDevice, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, p, interval, scan)
+		}()
+	}
+
+	if w.mdns {
+		start(ProtocolMDNS, w.interval, func(ctx context.Context) ([]DiscoveredDevice, error) {
+			ctx, cancel := context.WithTimeout(ctx, w.window)
+			defer cancel()
+			return NewMDNSDiscoverer().DiscoverWithContext(ctx)
+		})
+	}
+	if w.coiot {
+		start(ProtocolCoIoT, w.interval, func(ctx context.Context) ([]DiscoveredDevice, error) {
+			ctx, cancel := context.WithTimeout(ctx, w.window)
+			defer cancel()
+			return NewCoIoTDiscoverer().DiscoverWithContext(ctx)
+		})
+	}
+	if len(w.cidrs) > 0 {
+		start(ProtocolManual, w.sweepInterval, func(ctx context.Context) ([]DiscoveredDevice, error) {
+			return NewHTTPProber(WithTargets(w.cidrs...)).DiscoverWithContext(ctx)
+		})
+	}
+	for _, d := range w.extra {
+		start("", w.interval, func(context.Context) ([]DiscoveredDevice, error) {
+			return d.Discover(w.window)
+		})
+	}
+
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case now := <-ticker.C:
+			w.Check(now)
+		}
+	}
+}
+
+// loop runs scan every interval and records what it finds.
+func (w *Watcher) loop(ctx context.Context, p Protocol, interval time.Duration,
+	scan func(context.Context) ([]DiscoveredDevice, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		devices, err := scan(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && w.onError != nil {
+			w.onError(p, err)
+		}
+		for i := range devices {
+			w.Observe(&devices[i])
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Observe records a sighting of a device, publishing an online event for
+// new or returning devices and an address changed event when its address
+// differs from the last sighting. The watcher's scans call Observe; other
+// sources can too.
+func (w *Watcher) Observe(d *DiscoveredDevice) {
+	mac := normalizeMAC(d.MACAddress)
+	if mac == "" {
+		mac = normalizeMAC(d.ID)
+	}
+	key := mac
+	if key == "" {
+		key = d.ID
+	}
+	if key == "" {
+		return
+	}
+
+	now := d.LastSeen
+	if now.IsZero() {
+		now = time.Now()
+	}
+	expires := now.Add(w.livenessTimeout(d.Protocol))
+
+	var pending []events.Event
+	w.mu.Lock()
+	e := w.lookupLocked(key, mac, d.ID)
+	if e == nil {
+		e = &trackedEntry{device: TrackedDevice{Key: key, FirstSeen: now}}
+		w.devices[key] = e
+	}
+	dev := &e.device
+	if dev.MACAddress == "" && mac != "" {
+		dev.MACAddress = mac
+	}
+	w.aliases[dev.Key] = dev.Key
+	if mac != "" {
+		w.aliases[mac] = dev.Key
+	}
+	if d.ID != "" {
+		w.aliases[d.ID] = dev.Key
+	}
+
+	id := d.ID
+	if id == "" {
+		id = dev.ID
+	}
+	if id == "" {
+		id = dev.Key
+	}
+	if d.Address != nil && dev.Address != nil && !d.Address.Equal(dev.Address) {
+		pending = append(pending, events.NewDeviceAddressChangedEvent(id, dev.Address.String(), d.Address.String()))
+	}
+	if !dev.Online {
+		online := events.NewDeviceOnlineEvent(id).WithSource(events.EventSourceDiscovery)
+		if d.Address != nil {
+			online.WithAddress(d.Address.String())
+		} else if dev.Address != nil {
+			online.WithAddress(dev.Address.String())
+		}
+		pending = append(pending, online)
+	}
+
+	mergeTracked(dev, d)
+	dev.Online = true
+	if now.After(dev.LastSeen) {
+		dev.LastSeen = now
+	}
+	if expires.After(e.expires) {
+		e.expires = expires
+	}
+	w.mu.Unlock()
+
+	w.publish(pending)
+}
+
+// lookupLocked finds the entry for a sighting by any of its identities.
+func (w *Watcher) lookupLocked(key, mac, id string) *trackedEntry {
+	for _, alias := range []string{key, mac, id} {
+		if alias == "" {
+			continue
+		}
+		if k, ok := w.aliases[alias]; ok {
+			return w.devices[k]
+		}
+	}
+	return nil
+}
+
+// mergeTracked copies the non-empty fields of a sighting.
+func mergeTracked(dev *TrackedDevice, d *DiscoveredDevice) {
+	if d.ID != "" {
+		dev.ID = d.ID
+	}
+	if d.Name != "" {
+		dev.Name = d.Name
+	}
+	if d.Model != "" {
+		dev.Model = d.Model
+	}
+	if d.Firmware != "" {
+		dev.Firmware = d.Firmware
+	}
+	if d.Generation != 0 {
+		dev.Generation = d.Generation
+	}
+	if d.Address != nil {
+		dev.Address = d.Address
+	}
+	if d.Port != 0 {
+		dev.Port = d.Port
+	}
+	if d.AuthRequired {
+		dev.AuthRequired = true
+	}
+	if d.Protocol != "" && !containsProtocol(dev.Protocols, d.Protocol) {
+		dev.Protocols = append(dev.Protocols, d.Protocol)
+	}
+}
+
+func containsProtocol(list []Protocol, p Protocol) bool {
+	for _, q := range list {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Check marks devices not seen within their liveness timeout as offline
+// and publishes offline events. Run calls it periodically.
+func (w *Watcher) Check(now time.Time) {
+	var pending []events.Event
+	w.mu.Lock()
+	for _, e := range w.devices {
+		if e.device.Online && now.After(e.expires) {
+			e.device.Online = false
+			id := e.device.ID
+			if id == "" {
+				id = e.device.Key
+			}
+			pending = append(pending, events.NewDeviceOfflineEvent(id).
+				WithReason("not seen since "+e.device.LastSeen.Format(time.RFC3339)).
+				WithSource(events.EventSourceDiscovery))
+		}
+	}
+	w.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].DeviceID() < pending[j].DeviceID() })
+	w.publish(pending)
+}
+
+func (w *Watcher) livenessTimeout(p Protocol) time.Duration {
+	if d, ok := w.protocolLiveness[p]; ok {
+		return d
+	}
+	return w.liveness
+}
+
+func (w *Watcher) publish(pending []events.Event) {
+	if w.bus == nil {
+		return
+	}
+	for _, e := range pending {
+		w.bus.Publish(e)
+	}
+}
+
+// Devices returns the inventory sorted by key.
+func (w *Watcher) Devices() []TrackedDevice {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	devices := make([]TrackedDevice, 0, len(w.devices))
+	for _, e := range w.devices {
+		devices = append(devices, e.device.clone())
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Key < devices[j].Key })
+	return devices
+}
+
+// Online returns the devices currently online, sorted by key.
+func (w *Watcher) Online() []TrackedDevice {
+	var online []TrackedDevice
+	for _, d := range w.Devices() {
+		if d.Online {
+			online = append(online, d)
+		}
+	}
+	return online
+}
+
+// Device returns the device with the given MAC address or ID.
+func (w *Watcher) Device(idOrMAC string) (TrackedDevice, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e := w.lookupLocked(idOrMAC, normalizeMAC(idOrMAC), "")
+	if e == nil {
+		return TrackedDevice{}, false
+	}
+	return e.device.clone(), true
+}
+
+// Forget removes a device from the inventory without publishing events.
+func (w *Watcher) Forget(idOrMAC string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e := w.lookupLocked(idOrMAC, normalizeMAC(idOrMAC), "")
+	if e == nil {
+		return false
+	}
+	delete(w.devices, e.device.Key)
+	for alias, key := range w.aliases {
+		if key == e.device.Key {
+			delete(w.aliases, alias)
+		}
+	}
+	return true
+}
+
+func (d *TrackedDevice) clone() TrackedDevice {
+	c := *d
+	c.Protocols = append([]Protocol(nil), d.Protocols...)
+	if d.Address != nil {
+		c.Address = append(net.IP(nil), d.Address...)
+	}
+	return c
+}
+
+// normalizeMAC returns the MAC address in s as 12 uppercase hex digits, or
+// "". Besides the usual notations it accepts device IDs ending in the MAC,
+// such as "shellyplus1-a8032ab12345".
+func normalizeMAC(s string) string {
+	if i := strings.LastIndexByte(s, '-'); i >= 0 && len(s)-i-1 == 12 {
+		s = s[i+1:]
+	}
+	s = strings.NewReplacer(":", "", "-", "", ".", "").Replace(s)
+	if len(s) != 12 {
+		return ""
+	}
+	for i := range len(s) {
+		if !isHexDigit(s[i]) {
+			return ""
+		}
+	}
+	return strings.ToUpper(s)
+}
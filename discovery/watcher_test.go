@@ -0,0 +1,286 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/types"
+)
+
+// fakeDiscoverer returns the devices it holds from each scan.
+type fakeDiscoverer struct {
+	err     error
+	devices []DiscoveredDevice
+	mu      sync.Mutex
+}
+
+func (f *fakeDiscoverer) set(devices ...DiscoveredDevice) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.devices = devices
+}
+
+func (f *fakeDiscoverer) Discover(time.Duration) ([]DiscoveredDevice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]DiscoveredDevice(nil), f.devices...), f.err
+}
+
+func (f *fakeDiscoverer) StartDiscovery() (<-chan DiscoveredDevice, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeDiscoverer) StopDiscovery() error { return nil }
+func (f *fakeDiscoverer) Stop() error          { return nil }
+
+func recordEvents(bus *events.EventBus) func() []events.Event {
+	var mu sync.Mutex
+	var got []events.Event
+	bus.Subscribe(func(e events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e)
+	})
+	return func() []events.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]events.Event(nil), got...)
+	}
+}
+
+func eventTypes(evs []events.Event) []events.EventType {
+	names := make([]events.EventType, len(evs))
+	for i, e := range evs {
+		names[i] = e.Type()
+	}
+	return names
+}
+
+func TestWatcher_Observe(t *testing.T) {
+	bus := events.NewEventBus()
+	recorded := recordEvents(bus)
+	w := NewWatcher(WithEventBus(bus), WithLivenessTimeout(time.Minute))
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	w.Observe(&DiscoveredDevice{
+		ID:       "shellyplus1-a8032ab12345",
+		Model:    "SNSW-001X16EU",
+		Address:  net.ParseIP("192.168.1.20"),
+		Protocol: ProtocolMDNS,
+		LastSeen: t0,
+	})
+	w.Observe(&DiscoveredDevice{
+		ID:         "shellyplus1-a8032ab12345",
+		MACAddress: "A8:03:2A:B1:23:45",
+		Firmware:   "1.4.4",
+		Generation: types.Gen2,
+		Address:    net.ParseIP("192.168.1.20"),
+		Protocol:   ProtocolManual,
+		LastSeen:   t0.Add(10 * time.Second),
+	})
+
+	if got := eventTypes(recorded()); len(got) != 1 || got[0] != events.EventTypeDeviceOnline {
+		t.Fatalf("events = %v, want one online event", got)
+	}
+	online := recorded()[0].(*events.DeviceOnlineEvent)
+	if online.DeviceID() != "shellyplus1-a8032ab12345" || online.Address != "192.168.1.20" ||
+		online.Source() != events.EventSourceDiscovery {
+		t.Errorf("online event = %+v", online)
+	}
+
+	d, ok := w.Device("a8:03:2a:b1:23:45")
+	if !ok {
+		t.Fatal("Device() by MAC not found")
+	}
+	if d.Key != "A8032AB12345" || d.Model != "SNSW-001X16EU" || d.Firmware != "1.4.4" ||
+		d.Generation != types.Gen2 || !d.Online {
+		t.Errorf("device = %+v", d)
+	}
+	if !d.FirstSeen.Equal(t0) || !d.LastSeen.Equal(t0.Add(10*time.Second)) {
+		t.Errorf("seen = %v..%v", d.FirstSeen, d.LastSeen)
+	}
+	if len(d.Protocols) != 2 {
+		t.Errorf("protocols = %v", d.Protocols)
+	}
+
+	// The device moves to a new address.
+	w.Observe(&DiscoveredDevice{
+		ID:       "shellyplus1-a8032ab12345",
+		Address:  net.ParseIP("192.168.1.31"),
+		Protocol: ProtocolMDNS,
+		LastSeen: t0.Add(20 * time.Second),
+	})
+	evs := recorded()
+	if len(evs) != 2 {
+		t.Fatalf("events = %v, want address changed", eventTypes(evs))
+	}
+	moved, ok := evs[1].(*events.DeviceAddressChangedEvent)
+	if !ok || moved.PreviousAddress != "192.168.1.20" || moved.Address != "192.168.1.31" {
+		t.Errorf("address changed event = %+v", evs[1])
+	}
+	if devices := w.Devices(); len(devices) != 1 || !devices[0].Address.Equal(net.ParseIP("192.168.1.31")) {
+		t.Errorf("Devices() = %+v", devices)
+	}
+
+	// Still within the liveness timeout.
+	w.Check(t0.Add(time.Minute))
+	if len(recorded()) != 2 {
+		t.Fatalf("events = %v, want no offline event yet", eventTypes(recorded()))
+	}
+
+	w.Check(t0.Add(2 * time.Minute))
+	evs = recorded()
+	if len(evs) != 3 || evs[2].Type() != events.EventTypeDeviceOffline {
+		t.Fatalf("events = %v, want offline", eventTypes(evs))
+	}
+	if len(w.Online()) != 0 {
+		t.Error("Online() not empty after timeout")
+	}
+	w.Check(t0.Add(3 * time.Minute))
+	if len(recorded()) != 3 {
+		t.Error("offline event published twice")
+	}
+
+	w.Observe(&DiscoveredDevice{
+		ID:       "shellyplus1-a8032ab12345",
+		Address:  net.ParseIP("192.168.1.31"),
+		Protocol: ProtocolMDNS,
+		LastSeen: t0.Add(4 * time.Minute),
+	})
+	evs = recorded()
+	if len(evs) != 4 || evs[3].Type() != events.EventTypeDeviceOnline {
+		t.Fatalf("events = %v, want back online", eventTypes(evs))
+	}
+
+	if !w.Forget("shellyplus1-a8032ab12345") || len(w.Devices()) != 0 {
+		t.Error("Forget() did not remove the device")
+	}
+	if _, ok := w.Device("A8032AB12345"); ok {
+		t.Error("Device() found a forgotten device")
+	}
+}
+
+func TestWatcher_ProtocolLiveness(t *testing.T) {
+	w := NewWatcher(WatchHTTP("192.168.1.0/30"), WithSweepInterval(time.Minute),
+		WithLivenessTimeout(30*time.Second))
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	w.Observe(&DiscoveredDevice{ID: "shelly1-aabbccddeeff", Protocol: ProtocolManual, LastSeen: t0})
+	w.Observe(&DiscoveredDevice{ID: "shelly1-112233445566", Protocol: ProtocolCoIoT, LastSeen: t0})
+
+	w.Check(t0.Add(time.Minute))
+	online := w.Online()
+	if len(online) != 1 || online[0].Key != "AABBCCDDEEFF" {
+		t.Fatalf("Online() = %+v, want only the swept device", online)
+	}
+	w.Check(t0.Add(3*time.Minute + time.Second))
+	if len(w.Online()) != 0 {
+		t.Error("swept device still online after three sweep intervals")
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	bus := events.NewEventBus()
+	recorded := recordEvents(bus)
+	fake := &fakeDiscoverer{}
+	fake.set(DiscoveredDevice{
+		ID:       "shellyplus1-a8032ab12345",
+		Address:  net.ParseIP("192.168.1.20"),
+		Protocol: ProtocolManual,
+	})
+
+	var errMu sync.Mutex
+	var scanErrs int
+	w := NewWatcher(
+		WatchMDNS(false),
+		WatchCoIoT(false),
+		WatchDiscoverer(fake),
+		WithEventBus(bus),
+		WithWatchInterval(10*time.Millisecond),
+		WithLivenessTimeout(50*time.Millisecond),
+		WithCheckInterval(5*time.Millisecond),
+		WithWatchErrorHandler(func(Protocol, error) {
+			errMu.Lock()
+			scanErrs++
+			errMu.Unlock()
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s; events = %v", what, eventTypes(recorded()))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	hasEvent := func(typ events.EventType) func() bool {
+		return func() bool {
+			for _, e := range recorded() {
+				if e.Type() == typ {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	waitFor("online", hasEvent(events.EventTypeDeviceOnline))
+	fake.set(DiscoveredDevice{
+		ID:       "shellyplus1-a8032ab12345",
+		Address:  net.ParseIP("192.168.1.31"),
+		Protocol: ProtocolManual,
+	})
+	waitFor("address change", hasEvent(events.EventTypeDeviceAddressChanged))
+
+	fake.mu.Lock()
+	fake.devices = nil
+	fake.err = errors.New("scan failed")
+	fake.mu.Unlock()
+	waitFor("offline", hasEvent(events.EventTypeDeviceOffline))
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+	errMu.Lock()
+	defer errMu.Unlock()
+	if scanErrs == 0 {
+		t.Error("error handler not called")
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"A8:03:2A:B1:23:45", "A8032AB12345"},
+		{"a8-03-2a-b1-23-45", "A8032AB12345"},
+		{"a803.2ab1.2345", "A8032AB12345"},
+		{"a8032ab12345", "A8032AB12345"},
+		{"shellyplus1-a8032ab12345", "A8032AB12345"},
+		{"shellyplus1-abc", ""},
+		{"shelly1", ""},
+		{"", ""},
+		{"zz032ab12345", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalizeMAC(tt.input); got != tt.want {
+				t.Errorf("normalizeMAC(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
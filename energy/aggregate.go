@@ -0,0 +1,232 @@
+package energy
+
+import (
+	"sort"
+	"time"
+)
+
+// Resolution is an aggregation bucket size.
+type Resolution string
+
+// Aggregation resolutions.
+const (
+	Hourly  Resolution = "hour"
+	Daily   Resolution = "day"
+	Monthly Resolution = "month"
+)
+
+// bucketStart returns the start of the bucket containing t in loc.
+func (r Resolution) bucketStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch r {
+	case Hourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// next returns the start of the following bucket. Days and months follow
+// the calendar, so DST days are 23 or 25 hours long.
+func (r Resolution) next(start time.Time) time.Time {
+	switch r {
+	case Hourly:
+		return start.Add(time.Hour)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Bucket is the energy of one phase over one aggregation period.
+type Bucket struct {
+	// Start is the beginning of the period in the aggregation location.
+	Start time.Time `json:"start"`
+
+	// End is the beginning of the next period.
+	End time.Time `json:"end"`
+
+	// Phase is the measured channel.
+	Phase Phase `json:"phase"`
+
+	// Import is the energy drawn from the grid in watt-hours.
+	Import float64 `json:"import"`
+
+	// Export is the energy returned to the grid in watt-hours.
+	Export float64 `json:"export"`
+
+	// Net is Import minus Export.
+	Net float64 `json:"net"`
+
+	// Samples is the number of intervals summed.
+	Samples int `json:"samples"`
+
+	// Filled is how many of those intervals were synthesized by FillGaps.
+	Filled int `json:"filled,omitempty"`
+}
+
+// Aggregate sums samples into per-phase buckets at the given resolution.
+// Bucket boundaries are computed in loc (UTC if nil). The result is
+// ordered by start time, then phase.
+func Aggregate(samples []Sample, res Resolution, loc *time.Location) []Bucket {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	type key struct {
+		start int64
+		phase Phase
+	}
+	buckets := make(map[key]*Bucket)
+	for i := range samples {
+		s := &samples[i]
+		start := res.bucketStart(s.Time, loc)
+		k := key{start: start.Unix(), phase: s.Phase}
+		b, ok := buckets[k]
+		if !ok {
+			b = &Bucket{Start: start, End: res.next(start), Phase: s.Phase}
+			buckets[k] = b
+		}
+		b.Import += s.Import
+		b.Export += s.Export
+		b.Samples++
+		if s.Filled {
+			b.Filled++
+		}
+	}
+
+	out := make([]Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		b.Net = b.Import - b.Export
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Start.Equal(out[j].Start) {
+			return out[i].Start.Before(out[j].Start)
+		}
+		return phaseOrder(out[i].Phase) < phaseOrder(out[j].Phase)
+	})
+	return out
+}
+
+// Gap is a span without samples on a phase.
+type Gap struct {
+	// Start is the end of the last interval before the gap.
+	Start time.Time `json:"start"`
+
+	// End is the start of the first interval after the gap.
+	End time.Time `json:"end"`
+
+	// Phase is the channel with missing data.
+	Phase Phase `json:"phase"`
+}
+
+// Gaps lists the holes between consecutive samples of each phase.
+func Gaps(samples []Sample) []Gap {
+	var gaps []Gap
+	for _, series := range byPhase(samples) {
+		for i := 1; i < len(series); i++ {
+			prevEnd := series[i-1].End()
+			if series[i].Time.After(prevEnd) {
+				gaps = append(gaps, Gap{Start: prevEnd, End: series[i].Time, Phase: series[i].Phase})
+			}
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if !gaps[i].Start.Equal(gaps[j].Start) {
+			return gaps[i].Start.Before(gaps[j].Start)
+		}
+		return phaseOrder(gaps[i].Phase) < phaseOrder(gaps[j].Phase)
+	})
+	return gaps
+}
+
+// FillMode selects how FillGaps synthesizes missing intervals.
+type FillMode int
+
+const (
+	// FillZero fills gaps with zero energy, e.g. when the meter was off.
+	FillZero FillMode = iota
+
+	// FillLinear interpolates energy per interval between the samples on
+	// either side of the gap.
+	FillLinear
+)
+
+// FillGaps returns samples with the gaps between consecutive samples of
+// each phase filled by synthesized samples at the period of the sample
+// before the gap. Synthesized samples have Filled set. Gaps longer than
+// maxGap (if positive) are left open.
+func FillGaps(samples []Sample, mode FillMode, maxGap time.Duration) []Sample {
+	out := make([]Sample, 0, len(samples))
+	for _, series := range byPhase(samples) {
+		for i := range series {
+			if i > 0 {
+				out = append(out, fill(&series[i-1], &series[i], mode, maxGap)...)
+			}
+			out = append(out, series[i])
+		}
+	}
+	sortSamples(out)
+	return out
+}
+
+// fill synthesizes the intervals between prev and next.
+func fill(prev, next *Sample, mode FillMode, maxGap time.Duration) []Sample {
+	start := prev.End()
+	gap := next.Time.Sub(start)
+	if gap <= 0 || prev.Period <= 0 || (maxGap > 0 && gap > maxGap) {
+		return nil
+	}
+
+	step := time.Duration(prev.Period) * time.Second
+	n := int(gap / step)
+	var out []Sample
+	for k := 0; k < n; k++ {
+		s := Sample{
+			Time:   start.Add(time.Duration(k) * step),
+			Phase:  prev.Phase,
+			Period: prev.Period,
+			Filled: true,
+		}
+		if mode == FillLinear {
+			f := float64(k+1) / float64(n+1)
+			s.Import = perSecond(prev.Import, prev.Period, next.Import, next.Period, f) * float64(prev.Period)
+			s.Export = perSecond(prev.Export, prev.Period, next.Export, next.Period, f) * float64(prev.Period)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// perSecond interpolates the energy rate between two samples.
+func perSecond(a float64, aPeriod int, b float64, bPeriod int, f float64) float64 {
+	ra := a / float64(aPeriod)
+	rb := ra
+	if bPeriod > 0 {
+		rb = b / float64(bPeriod)
+	}
+	return ra + (rb-ra)*f
+}
+
+// byPhase splits samples into time-ordered series per phase.
+func byPhase(samples []Sample) [][]Sample {
+	index := make(map[Phase]int)
+	var out [][]Sample
+	for _, s := range samples {
+		i, ok := index[s.Phase]
+		if !ok {
+			i = len(out)
+			index[s.Phase] = i
+			out = append(out, nil)
+		}
+		out[i] = append(out[i], s)
+	}
+	for _, series := range out {
+		sort.SliceStable(series, func(i, j int) bool { return series[i].Time.Before(series[j].Time) })
+	}
+	return out
+}
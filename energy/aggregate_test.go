@@ -0,0 +1,118 @@
+package energy
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	// Hourly samples across the 2024-03-31 DST change and into April.
+	start := time.Date(2024, 3, 30, 22, 0, 0, 0, loc)
+	var samples []Sample
+	for i := 0; i < 50; i++ {
+		ts := start.Add(time.Duration(i) * time.Hour)
+		samples = append(samples,
+			Sample{Time: ts, Phase: PhaseA, Import: 100, Period: 3600},
+			Sample{Time: ts, Phase: PhaseTotal, Import: 100, Export: 40, Period: 3600},
+		)
+	}
+
+	daily := Aggregate(samples, Daily, loc)
+	// Mar 30 (2h), Mar 31 (23h), Apr 1 (24h), Apr 2 (1h) for two phases.
+	if len(daily) != 8 {
+		t.Fatalf("got %d daily buckets, want 8", len(daily))
+	}
+	mar31 := daily[2]
+	if mar31.Phase != PhaseA || mar31.Samples != 23 || mar31.Import != 2300 {
+		t.Errorf("Mar 31 = %+v", mar31)
+	}
+	if mar31.End.Sub(mar31.Start) != 23*time.Hour {
+		t.Errorf("Mar 31 length = %v, want 23h", mar31.End.Sub(mar31.Start))
+	}
+	if total := daily[3]; total.Phase != PhaseTotal || total.Net != 23*60 {
+		t.Errorf("Mar 31 total = %+v", total)
+	}
+
+	monthly := Aggregate(samples, Monthly, loc)
+	if len(monthly) != 4 || monthly[0].Samples != 25 || monthly[2].Samples != 25 {
+		t.Errorf("monthly = %+v", monthly)
+	}
+
+	hourly := Aggregate(samples[:2], Hourly, nil)
+	if len(hourly) != 2 || hourly[0].Start.Location() != time.UTC {
+		t.Errorf("hourly = %+v", hourly)
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	samples := []Sample{
+		{Time: start, Phase: PhaseTotal, Import: 1, Period: 60},
+		{Time: start.Add(4 * time.Minute), Phase: PhaseTotal, Import: 5, Period: 60},
+		{Time: start.Add(5 * time.Minute), Phase: PhaseTotal, Import: 5, Period: 60},
+	}
+
+	gaps := Gaps(samples)
+	if len(gaps) != 1 || !gaps[0].Start.Equal(start.Add(time.Minute)) || !gaps[0].End.Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("Gaps() = %+v", gaps)
+	}
+
+	zero := FillGaps(samples, FillZero, 0)
+	if len(zero) != 6 || !zero[1].Filled || zero[1].Import != 0 {
+		t.Errorf("FillZero = %+v", zero)
+	}
+	linear := FillGaps(samples, FillLinear, 0)
+	want := []float64{1, 2, 3, 4, 5, 5}
+	for i, s := range linear {
+		if !approx(s.Import, want[i]) {
+			t.Errorf("FillLinear[%d] = %v, want %v", i, s.Import, want[i])
+		}
+	}
+	if got := FillGaps(samples, FillZero, 2*time.Minute); len(got) != 3 {
+		t.Errorf("maxGap fill = %d samples, want 3", len(got))
+	}
+	if Gaps(linear) != nil {
+		t.Error("filled samples should have no gaps")
+	}
+
+	buckets := Aggregate(linear, Hourly, nil)
+	if len(buckets) != 1 || buckets[0].Filled != 3 || buckets[0].Samples != 6 {
+		t.Errorf("buckets = %+v", buckets)
+	}
+}
+
+func TestExport(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	buckets := []Bucket{{Start: start, End: start.Add(time.Hour), Phase: PhaseA, Import: 1.5, Export: 0.25, Net: 1.25, Samples: 60}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, buckets); err != nil {
+		t.Fatal(err)
+	}
+	want := "start,end,phase,import_wh,export_wh,net_wh,samples,filled\n" +
+		"2025-01-01T00:00:00Z,2025-01-01T01:00:00Z,a,1.5,0.25,1.25,60,0\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("WriteJSON(nil) = %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := WriteJSON(&buf, buckets); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Bucket
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 1 || decoded[0].Net != 1.25 {
+		t.Errorf("WriteJSON() round trip = %+v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,55 @@
+// Package energy downloads, stores and aggregates energy history from
+// Shelly meters.
+//
+// EMData (3-phase) and EM1Data (single-phase) components keep per-interval
+// measurements on the device. A Syncer pages through their GetData results
+// and appends them to a Store as Samples, one per phase and interval, with
+// imported and exported energy in watt-hours. Each series keeps a cursor,
+// so later syncs only fetch new intervals and an interrupted sync resumes
+// where it stopped.
+//
+// # Syncing
+//
+//	store, err := energy.NewFileStore("/var/lib/shelly/energy")
+//	if err != nil {
+//	    return err
+//	}
+//	syncer := energy.NewSyncer(store)
+//
+//	series := energy.SeriesKey("shellypro3em-a0b1c2", "emdata:0")
+//	result, err := syncer.SyncEMData(ctx, series, components.NewEMData(client, 0))
+//
+// Both the keyed-object and the array ("keys" + values) GetData formats are
+// understood. The CSV download from GetDataCSVURL (with add_keys=true) is
+// read with ParseCSV, and typed results, including Gen1 history converted
+// by EMeterHistoryToEMData, with FromEMData.
+//
+// # Minute Timeline
+//
+// Switch, PM1 and Light components without EMData storage report the
+// energy of the last three minutes in aenergy.by_minute. A MinuteCollector
+// stitches these windows into per-minute samples in the same Store:
+//
+//	collector := energy.NewMinuteCollector(store)
+//	src := energy.MinuteSource{
+//	    Client:    client,
+//	    Series:    energy.SeriesKey("shellyplus1pm-a0b1c2", "switch:0"),
+//	    Component: "switch:0",
+//	}
+//	collector.Subscribe(src, nil)                     // NotifyStatus updates, or
+//	err := collector.Poll(ctx, time.Minute, nil, src) // polling
+//
+// Minutes reported by overlapping windows are stored once. Minutes missed
+// between observations are filled from the running total, except across a
+// counter reset.
+//
+// # Aggregation
+//
+//	samples, _ := store.Samples(series, from, to)
+//	samples = energy.FillGaps(samples, energy.FillZero, 0)
+//	daily := energy.Aggregate(samples, energy.Daily, loc)
+//	err = energy.WriteCSV(os.Stdout, daily)
+//
+// Buckets are computed in the given location, so days and months follow
+// the local calendar across DST changes. Gaps reports missing intervals.
+package energy
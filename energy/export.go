@@ -0,0 +1,53 @@
+package energy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvHeader is the column layout written by WriteCSV.
+var csvHeader = []string{"start", "end", "phase", "import_wh", "export_wh", "net_wh", "samples", "filled"}
+
+// WriteCSV writes buckets as CSV with a header row. Times are RFC 3339 in
+// the bucket's location.
+func WriteCSV(w io.Writer, buckets []Bucket) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for i := range buckets {
+		b := &buckets[i]
+		err := cw.Write([]string{
+			b.Start.Format(time.RFC3339),
+			b.End.Format(time.RFC3339),
+			string(b.Phase),
+			formatWh(b.Import),
+			formatWh(b.Export),
+			formatWh(b.Net),
+			strconv.Itoa(b.Samples),
+			strconv.Itoa(b.Filled),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes buckets as an indented JSON array.
+func WriteJSON(w io.Writer, buckets []Bucket) error {
+	if buckets == nil {
+		buckets = []Bucket{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buckets)
+}
+
+func formatWh(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,41 @@
+// Package loadshed keeps an installation below its main-breaker limit by
+// switching off loads when metered power gets too high.
+//
+// A Controller evaluates total and per-phase active power from an EM
+// (Pro 3EM) or EM1 meter. When a limit is exceeded it sheds loads in
+// priority order, lowest first, until their expected draw covers the
+// overload. Shed loads are restored one per reading, highest priority
+// first, once the reading plus the load's draw stays a hysteresis margin
+// below the limits. Minimum on and off times prevent flapping.
+//
+//	ctl := loadshed.NewController(
+//	    loadshed.Limits{Total: 11000, Phase: 5500, Hysteresis: 500},
+//	    loadshed.WithActionHandler(func(a loadshed.Action) { log.Print(a) }),
+//	)
+//	err := ctl.AddLoad(loadshed.Load{
+//	    Name:     "water heater",
+//	    Switcher: loadshed.SwitchComponent(components.NewSwitch(heater.Client(), 0)),
+//	    Phase:    energy.PhaseB,
+//	    Priority: 1,
+//	    Power:    3000,
+//	    MinOff:   5 * time.Minute,
+//	})
+//
+// Loads switch through a Gen2 Switch component (SwitchComponent), a
+// helpers.Group (GroupSwitcher) or any Switcher.
+//
+// # Readings
+//
+// Power comes from EM/EM1 status changes on an events.EventBus
+// (Subscribe), from polling (Run with EMSource, EM1Source or a Source
+// reading a status cache), or from explicit Update calls:
+//
+//	ctl.Subscribe(bus, "shellypro3em-a0b1c2", nil)
+//	err = ctl.Run(ctx, 5*time.Second, loadshed.EMSource(components.NewEM(meter.Client(), 0)), nil)
+//
+// # Dry Run
+//
+// WithDryRun(true) logs every decision through the action handler and
+// Actions without switching anything, to tune limits and priorities
+// before going live.
+package loadshed
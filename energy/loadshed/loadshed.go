@@ -0,0 +1,487 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+)
+
+// defaultHistorySize is the number of actions kept by Actions.
+const defaultHistorySize = 100
+
+// ErrInvalidLoad is returned by AddLoad for a load without a name or
+// switcher, or with a duplicate name.
+var ErrInvalidLoad = errors.New("loadshed: invalid load")
+
+// Limits are the power limits enforced by a Controller.
+type Limits struct {
+	// Total is the limit on total active power in watts; zero disables it.
+	Total float64
+
+	// Phase is the limit on each phase's active power in watts; zero
+	// disables it.
+	Phase float64
+
+	// Hysteresis is the headroom in watts that must remain below a limit,
+	// after adding a load's expected power, before the load is restored.
+	Hysteresis float64
+}
+
+// Load is a switchable load the controller may shed.
+type Load struct {
+	// Switcher turns the load on and off.
+	Switcher Switcher
+
+	// Name identifies the load in actions.
+	Name string
+
+	// Phase is the phase the load is connected to. Empty or
+	// energy.PhaseTotal means unknown or all phases; such a load is shed
+	// for any overloaded phase.
+	Phase energy.Phase
+
+	// Priority orders shedding: loads with lower priority are shed first
+	// and restored last.
+	Priority int
+
+	// Power is the load's expected draw in watts. It decides how many
+	// loads are shed for an overload and whether restoring fits below the
+	// limits. Zero sheds one load per overloaded reading and restores as
+	// soon as the hysteresis headroom is free.
+	Power float64
+
+	// MinOn is the minimum time a restored load stays on before it may be
+	// shed again.
+	MinOn time.Duration
+
+	// MinOff is the minimum time a shed load stays off before it is
+	// restored.
+	MinOff time.Duration
+}
+
+// Reading is a power measurement evaluated by the controller.
+type Reading struct {
+	// Time is when the power was measured; zero means now. The controller
+	// measures minimum on/off times against reading times.
+	Time time.Time
+
+	// Phases holds per-phase active power in watts, keyed by
+	// energy.PhaseA, PhaseB and PhaseC.
+	Phases map[energy.Phase]float64
+
+	// Total is the total active power in watts.
+	Total float64
+}
+
+// Action is a decision taken by the controller.
+type Action struct {
+	// Time is the time of the reading that caused the action.
+	Time time.Time
+
+	// Err is the switching error; the load keeps its previous state.
+	Err error
+
+	// Load is the load name.
+	Load string
+
+	// Reason describes the limit or headroom that caused the action.
+	Reason string
+
+	// Total is the measured total power in watts.
+	Total float64
+
+	// Shed is true when the load was turned off and false when it was
+	// restored.
+	Shed bool
+
+	// DryRun is true when the controller only logged the decision.
+	DryRun bool
+}
+
+// String formats the action for logs.
+func (a Action) String() string {
+	verb := "restore"
+	if a.Shed {
+		verb = "shed"
+	}
+	s := fmt.Sprintf("%s %s: %s", verb, a.Load, a.Reason)
+	if a.DryRun {
+		s += " (dry run)"
+	}
+	if a.Err != nil {
+		s += fmt.Sprintf(": %v", a.Err)
+	}
+	return s
+}
+
+// Option configures a Controller.
+type Option func(*Controller)
+
+// WithDryRun makes the controller log decisions without switching loads.
+// Loads are still tracked as shed and restored, so the log shows what the
+// controller would have done.
+func WithDryRun(dryRun bool) Option {
+	return func(c *Controller) {
+		c.dryRun = dryRun
+	}
+}
+
+// WithActionHandler sets a function called for every action, e.g. to
+// write it to a log.
+func WithActionHandler(fn func(Action)) Option {
+	return func(c *Controller) {
+		c.onAction = fn
+	}
+}
+
+// WithHistorySize sets the number of actions kept by Actions.
+func WithHistorySize(n int) Option {
+	return func(c *Controller) {
+		if n > 0 {
+			c.historySize = n
+		}
+	}
+}
+
+// loadState is a load and its switching state.
+type loadState struct {
+	changed time.Time
+	Load
+	shed bool
+	// off is set when shedding found the load already off; it is skipped
+	// until the overload clears.
+	off bool
+}
+
+// Controller sheds loads in priority order when measured power exceeds
+// the limits and restores them once there is room again.
+//
+// Only loads the controller shed are restored: shedding a load that was
+// already off (as reported by its Switcher) does not mark it for
+// restoring, and the load isn't tried again until the overload clears.
+type Controller struct {
+	onAction    func(Action)
+	em1         map[int]float64
+	em          emPower
+	loads       []*loadState
+	history     []Action
+	limits      Limits
+	historySize int
+	mu          sync.Mutex
+	dryRun      bool
+}
+
+// NewController creates a controller enforcing limits.
+func NewController(limits Limits, opts ...Option) *Controller {
+	c := &Controller{
+		limits:      limits,
+		historySize: defaultHistorySize,
+		em1:         make(map[int]float64),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// AddLoad adds a load to the controller.
+func (c *Controller) AddLoad(load Load) error {
+	if load.Name == "" || load.Switcher == nil {
+		return ErrInvalidLoad
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, l := range c.loads {
+		if l.Name == load.Name {
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalidLoad, load.Name)
+		}
+	}
+	c.loads = append(c.loads, &loadState{Load: load})
+	sort.SliceStable(c.loads, func(i, j int) bool { return c.loads[i].Priority < c.loads[j].Priority })
+	return nil
+}
+
+// Shed returns the names of the loads currently shed, lowest priority
+// first.
+func (c *Controller) Shed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for _, l := range c.loads {
+		if l.shed {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+// Actions returns the most recent actions, oldest first.
+func (c *Controller) Actions() []Action {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Action(nil), c.history...)
+}
+
+// decision is a switching step planned under the lock.
+type decision struct {
+	load   *loadState
+	action Action
+}
+
+// Update evaluates a reading and sheds or restores loads. It sheds as
+// many loads as needed to cover the overload, by their expected power,
+// and restores at most one load per reading so that the next reading
+// includes its draw. A load found already off doesn't cover any of the
+// overload, so the next load is shed in its place. The actions taken are
+// returned.
+func (c *Controller) Update(ctx context.Context, r Reading) []Action {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	c.mu.Lock()
+	plan := c.planShed(&r, nil)
+	if len(plan) == 0 {
+		plan = c.planRestore(&r)
+	}
+
+	var actions []Action
+	var shed []*loadState
+	for len(plan) > 0 {
+		// Mark the plan before switching so concurrent readings don't repeat it.
+		for _, d := range plan {
+			d.load.shed = d.action.Shed
+			d.load.changed = r.Time
+		}
+		c.mu.Unlock()
+
+		replan := false
+		for _, d := range plan {
+			a := d.action
+			alreadyOff := false
+			if !c.dryRun {
+				wasOn, err := d.load.Switcher.Set(ctx, !a.Shed)
+				a.Err = err
+				c.mu.Lock()
+				switch {
+				case err != nil:
+					d.load.shed = !a.Shed
+				case a.Shed && !wasOn:
+					// Already off: not ours to restore, and no help.
+					d.load.shed = false
+					d.load.off = true
+					d.load.changed = time.Time{}
+					alreadyOff = true
+					replan = true
+				}
+				c.mu.Unlock()
+			}
+			if a.Shed && !alreadyOff {
+				shed = append(shed, d.load)
+			}
+			actions = append(actions, a)
+			c.record(a)
+		}
+
+		c.mu.Lock()
+		plan = nil
+		if replan {
+			plan = c.planShed(&r, shed)
+		}
+	}
+	c.mu.Unlock()
+	return actions
+}
+
+// RestoreAll restores every shed load regardless of limits and minimum
+// off times, e.g. when the controller shuts down.
+func (c *Controller) RestoreAll(ctx context.Context) []Action {
+	now := time.Now()
+	c.mu.Lock()
+	var plan []decision
+	for i := len(c.loads) - 1; i >= 0; i-- {
+		if l := c.loads[i]; l.shed {
+			l.shed = false
+			l.changed = now
+			plan = append(plan, decision{load: l, action: Action{Time: now, Load: l.Name, Reason: "restore all", DryRun: c.dryRun}})
+		}
+	}
+	c.mu.Unlock()
+
+	actions := make([]Action, 0, len(plan))
+	for _, d := range plan {
+		a := d.action
+		if !c.dryRun {
+			if _, err := d.load.Switcher.Set(ctx, true); err != nil {
+				a.Err = err
+				c.mu.Lock()
+				d.load.shed = true
+				c.mu.Unlock()
+			}
+		}
+		actions = append(actions, a)
+		c.record(a)
+	}
+	return actions
+}
+
+// planShed picks loads to shed for the overload in r, after the loads in
+// shed that were already shed for it. Loads found already off are
+// skipped until a reading without overload.
+func (c *Controller) planShed(r *Reading, shed []*loadState) []decision {
+	totalExcess := 0.0
+	if c.limits.Total > 0 {
+		totalExcess = r.Total - c.limits.Total
+	}
+	phaseExcess := make(map[energy.Phase]float64)
+	if c.limits.Phase > 0 {
+		for phase, p := range r.Phases {
+			if p > c.limits.Phase {
+				phaseExcess[phase] = p - c.limits.Phase
+			}
+		}
+	}
+	if totalExcess <= 0 && len(phaseExcess) == 0 {
+		for _, l := range c.loads {
+			l.off = false
+		}
+		return nil
+	}
+
+	// cover subtracts l's expected draw from the excess; it reports false
+	// for an unknown draw.
+	cover := func(l *loadState) bool {
+		if l.Power <= 0 {
+			return false
+		}
+		totalExcess -= l.Power
+		for phase := range phaseExcess {
+			if l.onPhase(phase) {
+				if phaseExcess[phase] -= l.Power; phaseExcess[phase] <= 0 {
+					delete(phaseExcess, phase)
+				}
+			}
+		}
+		return true
+	}
+	for _, l := range shed {
+		if !cover(l) {
+			return nil
+		}
+	}
+
+	var plan []decision
+	for _, l := range c.loads {
+		if totalExcess <= 0 && len(phaseExcess) == 0 {
+			break
+		}
+		if l.shed || l.off || (!l.changed.IsZero() && r.Time.Sub(l.changed) < l.MinOn) {
+			continue
+		}
+
+		var reason string
+		switch {
+		case totalExcess > 0:
+			reason = fmt.Sprintf("total %.0f W > %.0f W", r.Total, c.limits.Total)
+		default:
+			phase, ok := l.overloadedPhase(phaseExcess)
+			if !ok {
+				continue
+			}
+			reason = fmt.Sprintf("phase %s %.0f W > %.0f W", phase, r.Phases[phase], c.limits.Phase)
+		}
+		plan = append(plan, decision{load: l, action: Action{
+			Time: r.Time, Load: l.Name, Reason: reason, Total: r.Total, Shed: true, DryRun: c.dryRun,
+		}})
+
+		if !cover(l) {
+			// Unknown draw: shed one load and measure again.
+			break
+		}
+	}
+	return plan
+}
+
+// planRestore picks the highest-priority shed load that fits below the
+// limits. A load that must stay off longer or doesn't fit blocks the
+// loads after it, so loads are restored in priority order.
+func (c *Controller) planRestore(r *Reading) []decision {
+	for i := len(c.loads) - 1; i >= 0; i-- {
+		l := c.loads[i]
+		if !l.shed {
+			continue
+		}
+		if r.Time.Sub(l.changed) < l.MinOff || !c.fits(r, l) {
+			return nil
+		}
+		return []decision{{load: l, action: Action{
+			Time: r.Time, Load: l.Name, Reason: c.headroom(r), Total: r.Total, DryRun: c.dryRun,
+		}}}
+	}
+	return nil
+}
+
+// fits reports whether l's expected draw stays below the limits minus
+// the hysteresis.
+func (c *Controller) fits(r *Reading, l *loadState) bool {
+	h := c.limits.Hysteresis
+	if c.limits.Total > 0 && r.Total+l.Power > c.limits.Total-h {
+		return false
+	}
+	if c.limits.Phase > 0 {
+		for phase, p := range r.Phases {
+			if l.onPhase(phase) && p+l.Power > c.limits.Phase-h {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// headroom describes the remaining headroom for a restore action.
+func (c *Controller) headroom(r *Reading) string {
+	if c.limits.Total > 0 {
+		return fmt.Sprintf("total %.0f W, limit %.0f W", r.Total, c.limits.Total)
+	}
+	var worst energy.Phase
+	for phase, p := range r.Phases {
+		if worst == "" || p > r.Phases[worst] || (p == r.Phases[worst] && phase < worst) {
+			worst = phase
+		}
+	}
+	return fmt.Sprintf("phase %s %.0f W, limit %.0f W", worst, r.Phases[worst], c.limits.Phase)
+}
+
+// record adds an action to the history and calls the action handler.
+func (c *Controller) record(a Action) {
+	c.mu.Lock()
+	c.history = append(c.history, a)
+	if over := len(c.history) - c.historySize; over > 0 {
+		c.history = append(c.history[:0:0], c.history[over:]...)
+	}
+	fn := c.onAction
+	c.mu.Unlock()
+	if fn != nil {
+		fn(a)
+	}
+}
+
+// onPhase reports whether shedding l relieves phase.
+func (l *loadState) onPhase(phase energy.Phase) bool {
+	return l.Phase == "" || l.Phase == energy.PhaseTotal || l.Phase == phase
+}
+
+// overloadedPhase returns the first overloaded phase l is connected to.
+func (l *loadState) overloadedPhase(excess map[energy.Phase]float64) (energy.Phase, bool) {
+	for _, phase := range []energy.Phase{energy.PhaseA, energy.PhaseB, energy.PhaseC} {
+		if _, ok := excess[phase]; ok && l.onPhase(phase) {
+			return phase, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,247 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+)
+
+// fakeSwitch records switching calls.
+type fakeSwitch struct {
+	err   error
+	calls []bool
+	mu    sync.Mutex
+	on    bool
+}
+
+func newFakeSwitch() *fakeSwitch {
+	return &fakeSwitch{on: true}
+}
+
+func (s *fakeSwitch) Set(_ context.Context, on bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, on)
+	if s.err != nil {
+		return false, s.err
+	}
+	was := s.on
+	s.on = on
+	return was, nil
+}
+
+func names(actions []Action) []string {
+	var out []string
+	for _, a := range actions {
+		verb := "+"
+		if a.Shed {
+			verb = "-"
+		}
+		out = append(out, verb+a.Load)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func TestController_TotalLimit(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	heater, charger, oven := newFakeSwitch(), newFakeSwitch(), newFakeSwitch()
+
+	c := NewController(Limits{Total: 10000, Hysteresis: 500})
+	for _, l := range []Load{
+		{Name: "oven", Switcher: oven, Priority: 9, Power: 3000},
+		{Name: "heater", Switcher: heater, Priority: 1, Power: 2000, MinOff: time.Minute},
+		{Name: "charger", Switcher: charger, Priority: 2, Power: 7000, MinOff: time.Minute},
+	} {
+		if err := c.AddLoad(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Duration
+		total float64
+		want  []string
+		shed  []string
+	}{
+		{name: "below limit", at: 0, total: 9000},
+		// 2 kW over: the heater alone covers it.
+		{name: "small overload", at: time.Second, total: 12000, want: []string{"-heater"}, shed: []string{"heater"}},
+		// 5 kW over: the charger covers what's left.
+		{name: "large overload", at: 2 * time.Second, total: 15000, want: []string{"-charger"}, shed: []string{"heater", "charger"}},
+		{name: "min off", at: 30 * time.Second, total: 1000, shed: []string{"heater", "charger"}},
+		// Charger first (higher priority), once it fits with hysteresis.
+		{name: "restore highest priority", at: 70 * time.Second, total: 2000, want: []string{"+charger"}, shed: []string{"heater"}},
+		{name: "no headroom", at: 80 * time.Second, total: 7600, shed: []string{"heater"}},
+		{name: "restore last", at: 90 * time.Second, total: 7500, want: []string{"+heater"}},
+	}
+	for _, tt := range tests {
+		got := c.Update(context.Background(), Reading{Time: t0.Add(tt.at), Total: tt.total})
+		if !equal(names(got), tt.want) {
+			t.Errorf("%s: actions = %v, want %v", tt.name, names(got), tt.want)
+		}
+		if !equal(c.Shed(), tt.shed) {
+			t.Errorf("%s: shed = %v, want %v", tt.name, c.Shed(), tt.shed)
+		}
+	}
+
+	if len(oven.calls) != 0 || len(heater.calls) != 2 || !heater.on || !charger.on {
+		t.Errorf("switch calls: oven=%v heater=%v charger=%v", oven.calls, heater.calls, charger.calls)
+	}
+	if got := len(c.Actions()); got != 4 {
+		t.Errorf("Actions() = %d, want 4", got)
+	}
+}
+
+func TestController_PhaseLimit(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewController(Limits{Phase: 5000})
+	_ = c.AddLoad(Load{Name: "a-load", Switcher: newFakeSwitch(), Phase: energy.PhaseA, Power: 1000})
+	_ = c.AddLoad(Load{Name: "b-load", Switcher: newFakeSwitch(), Phase: energy.PhaseB, Power: 2000})
+	_ = c.AddLoad(Load{Name: "3-phase", Switcher: newFakeSwitch(), Priority: 5, Power: 3000})
+
+	got := c.Update(context.Background(), Reading{Time: t0, Phases: map[energy.Phase]float64{
+		energy.PhaseA: 3000, energy.PhaseB: 6500, energy.PhaseC: 1000,
+	}})
+	// Phase B is 1.5 kW over: the A load doesn't help, the B load does.
+	if !equal(names(got), []string{"-b-load"}) {
+		t.Fatalf("actions = %v", names(got))
+	}
+	if !strings.Contains(got[0].Reason, "phase b 6500 W > 5000 W") {
+		t.Errorf("reason = %q", got[0].Reason)
+	}
+
+	got = c.Update(context.Background(), Reading{Time: t0.Add(time.Second), Phases: map[energy.Phase]float64{
+		energy.PhaseA: 3000, energy.PhaseB: 9000,
+	}})
+	if !equal(names(got), []string{"-3-phase"}) {
+		t.Errorf("actions = %v", names(got))
+	}
+
+	// B has 2 kW headroom: the 3-phase load (3 kW) doesn't fit yet.
+	got = c.Update(context.Background(), Reading{Time: t0.Add(2 * time.Second), Phases: map[energy.Phase]float64{
+		energy.PhaseA: 1000, energy.PhaseB: 3000,
+	}})
+	if len(got) != 0 {
+		t.Errorf("actions = %v, want none", names(got))
+	}
+}
+
+func TestController_MinOnAndUnknownPower(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewController(Limits{Total: 1000})
+	_ = c.AddLoad(Load{Name: "first", Switcher: newFakeSwitch(), MinOn: time.Minute})
+	_ = c.AddLoad(Load{Name: "second", Switcher: newFakeSwitch(), Priority: 1})
+
+	// Unknown power: one load per reading.
+	if got := c.Update(context.Background(), Reading{Time: t0, Total: 5000}); !equal(names(got), []string{"-first"}) {
+		t.Fatalf("actions = %v", names(got))
+	}
+	if got := c.Update(context.Background(), Reading{Time: t0.Add(time.Second), Total: 500}); !equal(names(got), []string{"+first"}) {
+		t.Fatalf("actions = %v", names(got))
+	}
+	// "first" was just restored and must stay on for MinOn.
+	if got := c.Update(context.Background(), Reading{Time: t0.Add(2 * time.Second), Total: 5000}); !equal(names(got), []string{"-second"}) {
+		t.Errorf("actions = %v", names(got))
+	}
+}
+
+func TestController_AlreadyOffAndErrors(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	off := newFakeSwitch()
+	off.on = false
+	broken := newFakeSwitch()
+	broken.err = errors.New("offline")
+
+	var logged []string
+	c := NewController(Limits{Total: 1000}, WithActionHandler(func(a Action) { logged = append(logged, a.String()) }))
+	_ = c.AddLoad(Load{Name: "off", Switcher: off, Power: 100})
+	_ = c.AddLoad(Load{Name: "broken", Switcher: broken, Priority: 1, Power: 5000})
+
+	got := c.Update(context.Background(), Reading{Time: t0, Total: 2000})
+	if len(got) != 2 || got[1].Err == nil {
+		t.Fatalf("actions = %+v", got)
+	}
+	// The off load isn't ours to restore; the broken one kept its state.
+	if shed := c.Shed(); len(shed) != 0 {
+		t.Errorf("shed = %v", shed)
+	}
+	if len(logged) != 2 || !strings.HasSuffix(logged[1], ": offline") {
+		t.Errorf("logged = %q", logged)
+	}
+
+	if err := c.AddLoad(Load{Name: "off", Switcher: off}); !errors.Is(err, ErrInvalidLoad) {
+		t.Errorf("AddLoad(duplicate) = %v", err)
+	}
+	if err := c.AddLoad(Load{Name: "nil"}); !errors.Is(err, ErrInvalidLoad) {
+		t.Errorf("AddLoad(no switcher) = %v", err)
+	}
+}
+
+func TestController_AlreadyOffSkipped(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cold := newFakeSwitch()
+	cold.on = false
+	heater := newFakeSwitch()
+
+	c := NewController(Limits{Total: 1000})
+	_ = c.AddLoad(Load{Name: "cold", Switcher: cold})
+	_ = c.AddLoad(Load{Name: "heater", Switcher: heater, Priority: 1, Power: 2500})
+
+	// The off load covers nothing, so the heater is shed in the same update.
+	got := c.Update(context.Background(), Reading{Time: t0, Total: 3000})
+	if !equal(names(got), []string{"-cold", "-heater"}) {
+		t.Fatalf("actions = %v", names(got))
+	}
+	if !equal(c.Shed(), []string{"heater"}) || heater.on {
+		t.Errorf("shed = %v, heater on = %v", c.Shed(), heater.on)
+	}
+
+	// The off load isn't tried again while the overload lasts.
+	for i := 1; i < 5; i++ {
+		if got := c.Update(context.Background(), Reading{Time: t0.Add(time.Duration(i) * time.Second), Total: 3000}); len(got) != 0 {
+			t.Errorf("reading %d: actions = %v, want none", i, names(got))
+		}
+	}
+	if len(cold.calls) != 1 {
+		t.Errorf("cold calls = %v", cold.calls)
+	}
+
+	// Once the overload clears, the load is considered again.
+	cold.on = true
+	c.Update(context.Background(), Reading{Time: t0.Add(10 * time.Second), Total: 500})
+	got = c.Update(context.Background(), Reading{Time: t0.Add(11 * time.Second), Total: 1500})
+	if !equal(names(got), []string{"-cold"}) {
+		t.Errorf("actions = %v, want cold shed again", names(got))
+	}
+}
+
+func TestController_DryRun(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sw := newFakeSwitch()
+	c := NewController(Limits{Total: 1000}, WithDryRun(true), WithHistorySize(1))
+	_ = c.AddLoad(Load{Name: "heater", Switcher: sw, Power: 2000})
+
+	got := c.Update(context.Background(), Reading{Time: t0, Total: 2500})
+	if len(got) != 1 || !got[0].DryRun || got[0].String() != "shed heater: total 2500 W > 1000 W (dry run)" {
+		t.Fatalf("actions = %+v", got)
+	}
+	if restored := c.RestoreAll(context.Background()); len(restored) != 1 || restored[0].Shed {
+		t.Errorf("RestoreAll() = %+v", restored)
+	}
+	if len(sw.calls) != 0 {
+		t.Errorf("dry run switched: %v", sw.calls)
+	}
+	if a := c.Actions(); len(a) != 1 || a[0].Reason != "restore all" {
+		t.Errorf("Actions() = %+v", a)
+	}
+}
@@ -0,0 +1,196 @@
+package loadshed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// DefaultPollInterval is the interval used by Run when none is given.
+const DefaultPollInterval = 5 * time.Second
+
+// em1Phases maps EM1 channel IDs to phases.
+var em1Phases = []energy.Phase{energy.PhaseA, energy.PhaseB, energy.PhaseC}
+
+// emPower is the power subset of an EM status. Fields are pointers so
+// partial NotifyStatus updates merge into the last known values.
+type emPower struct {
+	A     *float64 `json:"a_act_power"`
+	B     *float64 `json:"b_act_power"`
+	C     *float64 `json:"c_act_power"`
+	Total *float64 `json:"total_act_power"`
+}
+
+func (p *emPower) merge(update *emPower) {
+	for _, f := range []struct{ dst, src **float64 }{
+		{&p.A, &update.A}, {&p.B, &update.B}, {&p.C, &update.C}, {&p.Total, &update.Total},
+	} {
+		if *f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+}
+
+func (p *emPower) reading(ts time.Time) Reading {
+	r := Reading{Time: ts, Phases: make(map[energy.Phase]float64)}
+	for phase, v := range map[energy.Phase]*float64{energy.PhaseA: p.A, energy.PhaseB: p.B, energy.PhaseC: p.C} {
+		if v != nil {
+			r.Phases[phase] = *v
+			r.Total += *v
+		}
+	}
+	if p.Total != nil {
+		r.Total = *p.Total
+	}
+	return r
+}
+
+// EMReading converts an EM status into a reading.
+func EMReading(ts time.Time, status *components.EMStatus) Reading {
+	return Reading{
+		Time:  ts,
+		Total: status.TotalActivePower,
+		Phases: map[energy.Phase]float64{
+			energy.PhaseA: status.AActivePower,
+			energy.PhaseB: status.BActivePower,
+			energy.PhaseC: status.CActivePower,
+		},
+	}
+}
+
+// EM1Reading converts the statuses of EM1 channels into a reading. The
+// total is the sum of the channels; channels 0, 1 and 2 are phases A, B
+// and C, as on a Pro 3EM in monophase profile.
+func EM1Reading(ts time.Time, statuses ...*components.EM1Status) Reading {
+	r := Reading{Time: ts, Phases: make(map[energy.Phase]float64)}
+	for _, s := range statuses {
+		r.Total += s.ActPower
+		if s.ID >= 0 && s.ID < len(em1Phases) {
+			r.Phases[em1Phases[s.ID]] += s.ActPower
+		}
+	}
+	return r
+}
+
+// ObserveStatus evaluates a status update of an "em:N" or "em1:N"
+// component, such as the Status of an events.StatusChangeEvent. Partial
+// updates are merged into the last known power, and EM1 channels are
+// summed as in EM1Reading. Other components are ignored.
+func (c *Controller) ObserveStatus(ctx context.Context, ts time.Time, component string, status json.RawMessage) ([]Action, error) {
+	kind, idStr, _ := strings.Cut(component, ":")
+	switch kind {
+	case "em":
+		var update emPower
+		if err := json.Unmarshal(status, &update); err != nil {
+			return nil, fmt.Errorf("failed to parse %s status: %w", component, err)
+		}
+		c.mu.Lock()
+		c.em.merge(&update)
+		r := c.em.reading(ts)
+		c.mu.Unlock()
+		return c.Update(ctx, r), nil
+
+	case "em1":
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid component %q", component)
+		}
+		var update struct {
+			ActPower *float64 `json:"act_power"`
+		}
+		if err := json.Unmarshal(status, &update); err != nil {
+			return nil, fmt.Errorf("failed to parse %s status: %w", component, err)
+		}
+		c.mu.Lock()
+		if update.ActPower != nil {
+			c.em1[id] = *update.ActPower
+		}
+		statuses := make([]*components.EM1Status, 0, len(c.em1))
+		for ch, p := range c.em1 {
+			statuses = append(statuses, &components.EM1Status{ID: ch, ActPower: p})
+		}
+		c.mu.Unlock()
+		r := EM1Reading(ts, statuses...)
+		return c.Update(ctx, r), nil
+	}
+	return nil, nil
+}
+
+// Subscribe evaluates the EM and EM1 status changes of a device published
+// on bus, e.g. by a notifications parser. Parse errors are passed to
+// onError, which may be nil. It returns the subscription ID for
+// bus.Unsubscribe.
+func (c *Controller) Subscribe(bus *events.EventBus, deviceID string, onError func(error)) uint64 {
+	filter := events.And(events.StatusChange(), events.WithDeviceID(deviceID))
+	return bus.SubscribeFiltered(filter, func(e events.Event) {
+		sc, ok := e.(*events.StatusChangeEvent)
+		if !ok {
+			return
+		}
+		if _, err := c.ObserveStatus(context.Background(), sc.Timestamp(), sc.Component, sc.Status); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// Source reads the current power, e.g. from a device or a status cache.
+type Source func(ctx context.Context) (Reading, error)
+
+// EMSource reads an EM component.
+func EMSource(em *components.EM) Source {
+	return func(ctx context.Context) (Reading, error) {
+		status, err := em.GetStatus(ctx)
+		if err != nil {
+			return Reading{}, err
+		}
+		return EMReading(time.Now(), status), nil
+	}
+}
+
+// EM1Source reads EM1 channels and combines them as in EM1Reading.
+func EM1Source(channels ...*components.EM1) Source {
+	return func(ctx context.Context) (Reading, error) {
+		statuses := make([]*components.EM1Status, 0, len(channels))
+		for _, ch := range channels {
+			status, err := ch.GetStatus(ctx)
+			if err != nil {
+				return Reading{}, err
+			}
+			statuses = append(statuses, status)
+		}
+		return EM1Reading(time.Now(), statuses...), nil
+	}
+}
+
+// Run polls src every interval and evaluates each reading until ctx is
+// canceled. Read errors are passed to onError, which may be nil; the
+// controller takes no action on a failed read.
+func (c *Controller) Run(ctx context.Context, interval time.Duration, src Source, onError func(error)) error {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r, err := src(ctx)
+		switch {
+		case err == nil:
+			c.Update(ctx, r)
+		case onError != nil && ctx.Err() == nil:
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
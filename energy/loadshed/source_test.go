@@ -0,0 +1,158 @@
+package loadshed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+func TestObserveStatus(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sw := newFakeSwitch()
+	c := NewController(Limits{Total: 10000, Phase: 4000})
+	_ = c.AddLoad(Load{Name: "heater", Switcher: sw, Phase: energy.PhaseC, Power: 2000})
+
+	tests := []struct {
+		name      string
+		component string
+		status    string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "full", component: "em:0", status: `{"a_act_power":1000,"b_act_power":1000,"c_act_power":3000,"total_act_power":5000}`},
+		// Only phase C changes; the other values are kept.
+		{name: "partial", component: "em:0", status: `{"c_act_power":4500}`, want: []string{"-heater"}},
+		{name: "other component", component: "switch:0", status: `{"output":true}`},
+		{name: "bad json", component: "em:0", status: `[`, wantErr: true},
+	}
+	for i, tt := range tests {
+		got, err := c.ObserveStatus(context.Background(), t0.Add(time.Duration(i)*time.Second), tt.component, json.RawMessage(tt.status))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+		if !equal(names(got), tt.want) {
+			t.Errorf("%s: actions = %v, want %v", tt.name, names(got), tt.want)
+		}
+	}
+}
+
+func TestObserveStatus_EM1(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewController(Limits{Total: 5000})
+	_ = c.AddLoad(Load{Name: "heater", Switcher: newFakeSwitch(), Power: 2000})
+
+	if got, _ := c.ObserveStatus(context.Background(), t0, "em1:0", json.RawMessage(`{"id":0,"act_power":3000}`)); len(got) != 0 {
+		t.Errorf("actions = %v", names(got))
+	}
+	// The channels add up to 6 kW.
+	got, _ := c.ObserveStatus(context.Background(), t0.Add(time.Second), "em1:1", json.RawMessage(`{"id":1,"act_power":3000}`))
+	if len(got) != 1 || got[0].Total != 6000 {
+		t.Errorf("actions = %+v", got)
+	}
+	if _, err := c.ObserveStatus(context.Background(), t0, "em1:x", json.RawMessage(`{}`)); err == nil {
+		t.Error("ObserveStatus(em1:x) should fail")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := events.NewEventBus()
+	defer bus.Close()
+
+	sw := newFakeSwitch()
+	c := NewController(Limits{Total: 1000})
+	_ = c.AddLoad(Load{Name: "heater", Switcher: sw})
+
+	var errs []error
+	id := c.Subscribe(bus, "shellypro3em-1", func(err error) { errs = append(errs, err) })
+	bus.Publish(events.NewStatusChangeEvent("other", "em:0", json.RawMessage(`{"total_act_power":5000}`)))
+	bus.Publish(events.NewStatusChangeEvent("shellypro3em-1", "em:0", json.RawMessage(`{"total_act_power":"x"}`)))
+	if len(sw.calls) != 0 || len(errs) != 1 {
+		t.Fatalf("calls = %v, errs = %v", sw.calls, errs)
+	}
+	bus.Publish(events.NewStatusChangeEvent("shellypro3em-1", "em:0", json.RawMessage(`{"total_act_power":5000}`)))
+	if len(sw.calls) != 1 || sw.calls[0] {
+		t.Errorf("calls = %v", sw.calls)
+	}
+	if !bus.Unsubscribe(id) {
+		t.Error("Unsubscribe() = false")
+	}
+}
+
+func TestSwitchComponent(t *testing.T) {
+	var params map[string]any
+	client := rpc.NewClient(&mockTransport{callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+		if req.GetMethod() != "Switch.Set" {
+			return nil, errors.New("unexpected method " + req.GetMethod())
+		}
+		data, _ := json.Marshal(req.GetParams())
+		_ = json.Unmarshal(data, &params)
+		return jsonrpcResponse(map[string]any{"was_on": true})
+	}})
+
+	wasOn, err := SwitchComponent(components.NewSwitch(client, 2)).Set(context.Background(), false)
+	if err != nil || !wasOn {
+		t.Fatalf("Set() = %v, %v", wasOn, err)
+	}
+	if params["id"] != float64(2) || params["on"] != false {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestRun(t *testing.T) {
+	client := rpc.NewClient(&mockTransport{callFunc: func(_ context.Context, _ transport.RPCRequest) (json.RawMessage, error) {
+		return jsonrpcResponse(map[string]any{
+			"id": 0, "a_act_power": 2000.0, "b_act_power": 2000.0, "c_act_power": 2000.0, "total_act_power": 6000.0,
+		})
+	}})
+
+	sw := newFakeSwitch()
+	c := NewController(Limits{Total: 5000})
+	_ = c.AddLoad(Load{Name: "heater", Switcher: sw})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.onAction = func(Action) { cancel() }
+	err := c.Run(ctx, time.Millisecond, EMSource(components.NewEM(client, 0)), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v", err)
+	}
+	if len(sw.calls) != 1 {
+		t.Errorf("calls = %v", sw.calls)
+	}
+}
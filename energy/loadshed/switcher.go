@@ -0,0 +1,54 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/helpers"
+)
+
+// Switcher turns a load on or off.
+type Switcher interface {
+	// Set switches the load and reports whether it was on before. A
+	// switcher that can't tell reports true.
+	Set(ctx context.Context, on bool) (wasOn bool, err error)
+}
+
+// SwitcherFunc adapts a function to a Switcher.
+type SwitcherFunc func(ctx context.Context, on bool) (bool, error)
+
+// Set calls f.
+func (f SwitcherFunc) Set(ctx context.Context, on bool) (bool, error) {
+	return f(ctx, on)
+}
+
+// SwitchComponent returns a Switcher for a Gen2 Switch component. It
+// reports the previous output from Switch.Set, so a load that was
+// already off when shed is left off afterwards.
+func SwitchComponent(sw *components.Switch) Switcher {
+	return SwitcherFunc(func(ctx context.Context, on bool) (bool, error) {
+		result, err := sw.Set(ctx, &components.SwitchSetParams{ID: sw.ID(), On: &on})
+		if err != nil {
+			return false, err
+		}
+		return result.WasOn, nil
+	})
+}
+
+// GroupSwitcher returns a Switcher for a helpers.Group, switching every
+// device in the group. It fails if any device fails.
+func GroupSwitcher(g *helpers.Group) Switcher {
+	return SwitcherFunc(func(ctx context.Context, on bool) (bool, error) {
+		failures := g.Set(ctx, on).Failures()
+		if len(failures) == 0 {
+			return true, nil
+		}
+		errs := make([]error, 0, len(failures))
+		for _, f := range failures {
+			errs = append(errs, f.Error)
+		}
+		return true, fmt.Errorf("failed to switch %d of %d devices in group %s: %w", len(failures), g.Len(), g.Name(), errors.Join(errs...))
+	})
+}
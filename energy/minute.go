@@ -0,0 +1,363 @@
+package energy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+// DefaultPollInterval is the poll interval used by Poll when none is
+// given. by_minute covers three minutes, so polls up to two minutes apart
+// lose nothing.
+const DefaultPollInterval = time.Minute
+
+// ErrNoCounters is returned when a status has no aenergy counters.
+var ErrNoCounters = errors.New("energy: status has no aenergy counters")
+
+// Counters is an aenergy or ret_aenergy object from a Switch, PM1 or
+// Light status.
+type Counters struct {
+	// ByMinute is the energy of the last complete minutes in milliwatt
+	// hours, most recent first. ByMinute[0] is the minute starting at
+	// MinuteTS.
+	ByMinute []float64 `json:"by_minute"`
+
+	// MinuteTS is the Unix start of the most recent complete minute.
+	MinuteTS int64 `json:"minute_ts"`
+
+	// Total is the running counter in watt-hours.
+	Total float64 `json:"total"`
+}
+
+// minuteStatus is the subset of a component status read by the collector.
+type minuteStatus struct {
+	AEnergy    *Counters `json:"aenergy"`
+	RetAEnergy *Counters `json:"ret_aenergy"`
+}
+
+// minuteState tracks one series between observations.
+type minuteState struct {
+	// last is the start of the newest stored minute, 0 if none.
+	last int64
+
+	// impTotal and retTotal are the counters at the previous observation.
+	impTotal, retTotal float64
+	haveTotal          bool
+}
+
+// MinuteCollector reconstructs per-minute energy from the rolling
+// aenergy.by_minute windows of devices without EMData storage.
+//
+// Each observation carries the last three complete minutes. The collector
+// stores every minute once, resuming after the series cursor, and fills
+// minutes missed between observations from the difference of the running
+// totals. A total that goes backwards is a counter reset (ResetCounters or
+// a reboot); the minutes are still stored, but no gap is filled across it.
+type MinuteCollector struct {
+	store   Store
+	onReset func(series string)
+	state   map[string]*minuteState
+	mu      sync.Mutex
+}
+
+// MinuteOption configures a MinuteCollector.
+type MinuteOption func(*MinuteCollector)
+
+// WithResetHandler sets a callback invoked when a series' counters reset.
+func WithResetHandler(fn func(series string)) MinuteOption {
+	return func(c *MinuteCollector) {
+		c.onReset = fn
+	}
+}
+
+// NewMinuteCollector creates a collector writing to store.
+func NewMinuteCollector(store Store, opts ...MinuteOption) *MinuteCollector {
+	c := &MinuteCollector{store: store, state: make(map[string]*minuteState)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Store returns the collector's store.
+func (c *MinuteCollector) Store() Store {
+	return c.store
+}
+
+// Observe records the aenergy and ret_aenergy counters of a raw Switch,
+// PM1 or Light status and returns the samples added to the store.
+func (c *MinuteCollector) Observe(series string, status json.RawMessage) ([]Sample, error) {
+	var s minuteStatus
+	if err := json.Unmarshal(status, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse status: %w", err)
+	}
+	if s.AEnergy == nil || s.AEnergy.MinuteTS == 0 {
+		return nil, ErrNoCounters
+	}
+	if s.RetAEnergy != nil && s.RetAEnergy.MinuteTS == 0 {
+		s.RetAEnergy = nil
+	}
+	return c.ObserveCounters(series, s.AEnergy, s.RetAEnergy)
+}
+
+// ObserveSwitch records the counters of a Switch status.
+func (c *MinuteCollector) ObserveSwitch(series string, status *components.SwitchStatus) ([]Sample, error) {
+	if status.AEnergy == nil || status.AEnergy.MinuteTs == nil {
+		return nil, ErrNoCounters
+	}
+	return c.ObserveCounters(series, &Counters{
+		ByMinute: status.AEnergy.ByMinute,
+		MinuteTS: *status.AEnergy.MinuteTs,
+		Total:    status.AEnergy.Total,
+	}, nil)
+}
+
+// ObservePM1 records the counters of a PM1 status.
+func (c *MinuteCollector) ObservePM1(series string, status *components.PM1Status) ([]Sample, error) {
+	if status.AEnergy == nil || status.AEnergy.MinuteTs == nil {
+		return nil, ErrNoCounters
+	}
+	imp := &Counters{
+		ByMinute: status.AEnergy.ByMinute,
+		MinuteTS: *status.AEnergy.MinuteTs,
+		Total:    status.AEnergy.Total,
+	}
+	var ret *Counters
+	if status.RetAEnergy != nil && status.RetAEnergy.MinuteTs != nil {
+		ret = &Counters{
+			ByMinute: status.RetAEnergy.ByMinute,
+			MinuteTS: *status.RetAEnergy.MinuteTs,
+			Total:    status.RetAEnergy.Total,
+		}
+	}
+	return c.ObserveCounters(series, imp, ret)
+}
+
+// ObserveCounters records import and (optional) export counters and
+// returns the samples added to the store.
+func (c *MinuteCollector) ObserveCounters(series string, imp, ret *Counters) ([]Sample, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, err := c.stateLocked(series)
+	if err != nil {
+		return nil, err
+	}
+
+	// Minute start → energy in Wh for each counter.
+	minutes := make(map[int64]*Sample)
+	var newest int64
+	add := func(counters *Counters, export bool) {
+		if counters == nil {
+			return
+		}
+		for i, mwh := range counters.ByMinute {
+			ts := counters.MinuteTS - int64(i*60)
+			if ts <= state.last {
+				continue
+			}
+			s, ok := minutes[ts]
+			if !ok {
+				s = &Sample{Time: time.Unix(ts, 0).UTC(), Phase: PhaseTotal, Period: 60}
+				minutes[ts] = s
+			}
+			if export {
+				s.Export = mwh / 1000
+			} else {
+				s.Import = mwh / 1000
+			}
+			newest = max(newest, ts)
+		}
+	}
+	add(imp, false)
+	add(ret, true)
+
+	reset := state.haveTotal && (imp.Total < state.impTotal || (ret != nil && ret.Total < state.retTotal))
+	if reset && c.onReset != nil {
+		c.onReset(series)
+	}
+
+	var samples []Sample
+	if state.last > 0 && state.haveTotal && !reset && len(minutes) > 0 {
+		samples = c.fillLocked(state, minutes, imp, ret)
+	}
+	for _, s := range minutes {
+		samples = append(samples, *s)
+	}
+	sortSamples(samples)
+
+	state.impTotal = imp.Total
+	if ret != nil {
+		state.retTotal = ret.Total
+	}
+	state.haveTotal = true
+
+	if len(samples) == 0 {
+		return nil, nil
+	}
+	if err := c.store.Append(series, samples); err != nil {
+		return nil, fmt.Errorf("failed to store samples: %w", err)
+	}
+	if err := c.store.SetCursor(series, time.Unix(newest+60, 0).UTC()); err != nil {
+		return nil, fmt.Errorf("failed to store cursor: %w", err)
+	}
+	state.last = newest
+	return samples, nil
+}
+
+// fillLocked spreads the energy counted by the totals but not covered by
+// the reported minutes evenly over the minutes missed since the previous
+// observation.
+func (c *MinuteCollector) fillLocked(state *minuteState, minutes map[int64]*Sample, imp, ret *Counters) []Sample {
+	oldest := int64(0)
+	var reportedImp, reportedRet float64
+	for ts, s := range minutes {
+		if oldest == 0 || ts < oldest {
+			oldest = ts
+		}
+		reportedImp += s.Import
+		reportedRet += s.Export
+	}
+	missing := int((oldest-state.last)/60) - 1
+	if missing <= 0 {
+		return nil
+	}
+
+	gapImp := max(imp.Total-state.impTotal-reportedImp, 0) / float64(missing)
+	gapRet := 0.0
+	if ret != nil {
+		gapRet = max(ret.Total-state.retTotal-reportedRet, 0) / float64(missing)
+	}
+
+	samples := make([]Sample, 0, missing)
+	for ts := state.last + 60; ts < oldest; ts += 60 {
+		samples = append(samples, Sample{
+			Time:   time.Unix(ts, 0).UTC(),
+			Phase:  PhaseTotal,
+			Import: gapImp,
+			Export: gapRet,
+			Period: 60,
+			Filled: true,
+		})
+	}
+	return samples
+}
+
+// stateLocked returns the state of a series, resuming from the store
+// cursor on first use.
+func (c *MinuteCollector) stateLocked(series string) (*minuteState, error) {
+	if state, ok := c.state[series]; ok {
+		return state, nil
+	}
+	cursor, err := c.store.Cursor(series)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cursor: %w", err)
+	}
+	state := &minuteState{}
+	if !cursor.IsZero() {
+		state.last = cursor.Unix() - 60
+	}
+	c.state[series] = state
+	return state, nil
+}
+
+// MinuteSource is a metered component to collect from.
+type MinuteSource struct {
+	// Client is the RPC client of the device.
+	Client *rpc.Client
+
+	// Series is the store key, e.g. SeriesKey(deviceID, Component).
+	Series string
+
+	// Component is the component key: "switch:0", "pm1:0" or "light:0".
+	Component string
+}
+
+// componentMethods maps component types to their RPC prefix.
+var componentMethods = map[string]string{
+	"switch": "Switch",
+	"pm1":    "PM1",
+	"light":  "Light",
+	"cct":    "CCT",
+	"rgb":    "RGB",
+	"rgbw":   "RGBW",
+}
+
+// statusMethod returns the GetStatus method and ID of a component key.
+func (src *MinuteSource) statusMethod() (string, int, error) {
+	typ, idText, ok := strings.Cut(src.Component, ":")
+	prefix, known := componentMethods[typ]
+	if !ok || !known {
+		return "", 0, fmt.Errorf("unsupported component %q", src.Component)
+	}
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid component %q: %w", src.Component, err)
+	}
+	return prefix + ".GetStatus", id, nil
+}
+
+// Collect fetches the status of a source once and observes it.
+func (c *MinuteCollector) Collect(ctx context.Context, src MinuteSource) ([]Sample, error) {
+	method, id, err := src.statusMethod()
+	if err != nil {
+		return nil, err
+	}
+	status, err := src.Client.Call(ctx, method, map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	return c.Observe(src.Series, status)
+}
+
+// Poll collects from every source each interval (DefaultPollInterval if
+// zero) until ctx is done. Errors from individual sources are passed to
+// onError, which may be nil.
+func (c *MinuteCollector) Poll(ctx context.Context, interval time.Duration, onError func(MinuteSource, error), sources ...MinuteSource) error {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, src := range sources {
+			if _, err := c.Collect(ctx, src); err != nil && onError != nil && ctx.Err() == nil {
+				onError(src, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Subscribe observes the counters in NotifyStatus updates for the source
+// component. Devices send aenergy once a minute, so the client's transport
+// must deliver notifications (WebSocket or MQTT). Errors are passed to
+// onError, which may be nil.
+func (c *MinuteCollector) Subscribe(src MinuteSource, onError func(MinuteSource, error)) {
+	src.Client.OnNotificationMethod("NotifyStatus", func(params json.RawMessage) {
+		var delta map[string]json.RawMessage
+		if json.Unmarshal(params, &delta) != nil {
+			return
+		}
+		status, ok := delta[src.Component]
+		if !ok {
+			return
+		}
+		if _, err := c.Observe(src.Series, status); err != nil && !errors.Is(err, ErrNoCounters) && onError != nil {
+			onError(src, err)
+		}
+	})
+}
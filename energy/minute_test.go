@@ -0,0 +1,176 @@
+package energy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// minuteStatusJSON builds a status whose by_minute window ends at minute.
+func minuteStatusJSON(minute int64, total float64, byMinute ...float64) json.RawMessage {
+	data, _ := json.Marshal(map[string]any{
+		"id":     0,
+		"output": true,
+		"aenergy": map[string]any{
+			"total":     total,
+			"by_minute": byMinute,
+			"minute_ts": minute,
+		},
+	})
+	return data
+}
+
+func TestMinuteCollector_Stitch(t *testing.T) {
+	const t0 = 1700000040
+	store := NewMemoryStore()
+	resets := 0
+	c := NewMinuteCollector(store, WithResetHandler(func(string) { resets++ }))
+	series := "plug/switch:0"
+
+	steps := []struct {
+		status json.RawMessage
+		name   string
+		want   int
+	}{
+		{name: "first window", status: minuteStatusJSON(t0+120, 100, 3000, 2000, 1000), want: 3},
+		{name: "overlapping window", status: minuteStatusJSON(t0+180, 104, 4000, 3000, 2000), want: 1},
+		{name: "same window again", status: minuteStatusJSON(t0+180, 104.5, 4000, 3000, 2000), want: 0},
+		// Minutes t0+240 and t0+300 were missed: total grew by 20 Wh, the
+		// window reports 7 Wh, so 13 Wh are spread over the two minutes.
+		{name: "gap", status: minuteStatusJSON(t0+480, 124.5, 3000, 2000, 2000), want: 5},
+		{name: "reset", status: minuteStatusJSON(t0+660, 1, 1000, 0, 0), want: 3},
+	}
+	for _, step := range steps {
+		samples, err := c.Observe(series, step.status)
+		if err != nil {
+			t.Fatalf("%s: Observe() error = %v", step.name, err)
+		}
+		if len(samples) != step.want {
+			t.Errorf("%s: %d samples, want %d: %+v", step.name, len(samples), step.want, samples)
+		}
+	}
+	if resets != 1 {
+		t.Errorf("resets = %d, want 1", resets)
+	}
+
+	samples, _ := store.Samples(series, time.Time{}, time.Time{})
+	want := []struct {
+		imp    float64
+		filled bool
+	}{
+		{1, false}, {2, false}, {3, false}, {4, false},
+		{6.5, true}, {6.5, true},
+		{2, false}, {2, false}, {3, false},
+		{0, false}, {0, false}, {1, false},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("stored %d samples, want %d", len(samples), len(want))
+	}
+	for i, s := range samples {
+		if s.Time.Unix() != t0+int64(i*60) || !approx(s.Import, want[i].imp) || s.Filled != want[i].filled {
+			t.Errorf("sample %d = %+v, want %+v", i, s, want[i])
+		}
+	}
+
+	cursor, _ := store.Cursor(series)
+	if cursor.Unix() != t0+720 {
+		t.Errorf("cursor = %d, want %d", cursor.Unix(), t0+720)
+	}
+
+	// A new collector resumes at the cursor without double counting.
+	resumed := NewMinuteCollector(store)
+	got, err := resumed.Observe(series, minuteStatusJSON(t0+720, 2, 500, 1000, 0))
+	if err != nil || len(got) != 1 || got[0].Time.Unix() != t0+720 {
+		t.Errorf("resumed Observe() = %+v, %v", got, err)
+	}
+
+	if _, err := c.Observe(series, json.RawMessage(`{"id":0,"output":false}`)); !errors.Is(err, ErrNoCounters) {
+		t.Errorf("error = %v, want ErrNoCounters", err)
+	}
+}
+
+func TestMinuteCollector_PM1Export(t *testing.T) {
+	ts := int64(1700000040)
+	status := &components.PM1Status{
+		AEnergy:    &components.PM1EnergyCounters{MinuteTs: &ts, ByMinute: []float64{500}, Total: 10},
+		RetAEnergy: &components.PM1EnergyCounters{MinuteTs: &ts, ByMinute: []float64{1500}, Total: 20},
+	}
+	c := NewMinuteCollector(NewMemoryStore())
+	samples, err := c.ObservePM1("pm/pm1:0", status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0].Import != 0.5 || samples[0].Export != 1.5 || samples[0].Net() != -1 {
+		t.Errorf("samples = %+v", samples)
+	}
+	if _, err := c.ObserveSwitch("sw", &components.SwitchStatus{}); !errors.Is(err, ErrNoCounters) {
+		t.Errorf("error = %v, want ErrNoCounters", err)
+	}
+}
+
+func TestMinuteCollector_CollectAndSubscribe(t *testing.T) {
+	var method string
+	client := rpc.NewClient(&mockTransport{callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+		method = req.GetMethod()
+		var status map[string]any
+		_ = json.Unmarshal(minuteStatusJSON(1700000040, 5, 1000), &status)
+		return jsonrpcResponse(status)
+	}})
+
+	store := NewMemoryStore()
+	c := NewMinuteCollector(store)
+	src := MinuteSource{Client: client, Series: "dimmer/light:0", Component: "light:0"}
+	samples, err := c.Collect(context.Background(), src)
+	if err != nil || len(samples) != 1 || method != "Light.GetStatus" {
+		t.Fatalf("Collect() = %+v, %v (method %s)", samples, err, method)
+	}
+
+	if _, err := c.Collect(context.Background(), MinuteSource{Client: client, Component: "cover:0"}); err == nil {
+		t.Error("expected error for unsupported component")
+	}
+
+	var errs []error
+	c.Subscribe(src, func(_ MinuteSource, err error) { errs = append(errs, err) })
+	note := fmt.Sprintf(`{"method":"NotifyStatus","params":{"ts":1700000100.1,"light:0":{"id":0,"aenergy":%s}}}`,
+		`{"total":6,"by_minute":[2000,1000,0],"minute_ts":1700000100}`)
+	if err := client.NotificationRouter().RouteRaw([]byte(note)); err != nil {
+		t.Fatal(err)
+	}
+	// Deltas without counters are ignored.
+	_ = client.NotificationRouter().RouteRaw([]byte(`{"method":"NotifyStatus","params":{"light:0":{"id":0,"output":true}}}`))
+	if len(errs) != 0 {
+		t.Errorf("errors = %v", errs)
+	}
+	stored, _ := store.Samples(src.Series, time.Time{}, time.Time{})
+	if len(stored) != 2 || stored[1].Import != 2 {
+		t.Errorf("stored = %+v", stored)
+	}
+}
+
+func TestMinuteCollector_Poll(t *testing.T) {
+	calls := 0
+	client := rpc.NewClient(&mockTransport{callFunc: func(context.Context, transport.RPCRequest) (json.RawMessage, error) {
+		calls++
+		return nil, errors.New("offline")
+	}})
+	c := NewMinuteCollector(NewMemoryStore())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+	failures := 0
+	err := c.Poll(ctx, 10*time.Millisecond, func(MinuteSource, error) { failures++ },
+		MinuteSource{Client: client, Series: "s", Component: "switch:0"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Poll() error = %v", err)
+	}
+	if calls < 2 || failures == 0 {
+		t.Errorf("calls = %d, failures = %d", calls, failures)
+	}
+}
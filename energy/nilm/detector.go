@@ -0,0 +1,439 @@
+package nilm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	"github.com/tj-smith47/shelly-go/events"
+)
+
+// Detector defaults.
+const (
+	DefaultMinStep   = 30.0
+	DefaultSettle    = 2
+	DefaultTolerance = 0.1
+
+	// maxCandidates bounds the unrecognized step clusters kept.
+	maxCandidates = 50
+
+	// levelWeight is how quickly the steady level follows slow drift.
+	levelWeight = 0.2
+
+	// minDeltaCurrent is the current step below which no power factor is
+	// derived.
+	minDeltaCurrent = 0.05
+)
+
+// Sample is one power measurement of a phase.
+type Sample struct {
+	// Time is when the sample was measured.
+	Time time.Time
+
+	// Phase is the measured phase.
+	Phase energy.Phase
+
+	// Power is the active power in watts.
+	Power float64
+
+	// Current is the RMS current in amperes; zero if unknown.
+	Current float64
+
+	// Voltage is the RMS voltage in volts; zero if unknown.
+	Voltage float64
+}
+
+// Step is a change between two steady power levels.
+type Step struct {
+	// Time is when the change began.
+	Time time.Time
+
+	// Phase is the measured phase.
+	Phase energy.Phase
+
+	// Appliance is the name of the matched signature; empty if the step
+	// wasn't recognized.
+	Appliance string
+
+	// Power is the change in watts: positive when a load switched on.
+	Power float64
+
+	// PowerFactor is the switched load's power factor, derived from the
+	// power and current changes; zero without current and voltage.
+	PowerFactor float64
+
+	// Signature is the ID of the matched signature.
+	Signature int
+
+	// Confidence is the match quality from 0 to 1.
+	Confidence float64
+}
+
+// On reports whether the step switched a load on.
+func (s *Step) On() bool {
+	return s.Power > 0
+}
+
+// ApplianceState is the inferred state of a recognized appliance.
+type ApplianceState struct {
+	// Since is the time of the last switching.
+	Since time.Time
+
+	// Name is the appliance name.
+	Name string
+
+	// Phase is the phase it was last seen on.
+	Phase energy.Phase
+
+	// Power is the last step size in watts.
+	Power float64
+
+	// On is the inferred state.
+	On bool
+}
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithMinStep sets the smallest power change in watts that counts as a
+// step. Smaller changes are treated as noise.
+func WithMinStep(watts float64) Option {
+	return func(d *Detector) {
+		if watts > 0 {
+			d.minStep = watts
+		}
+	}
+}
+
+// WithSettle sets how many consecutive samples must agree on a new level
+// before a step is reported. Inrush spikes shorter than this are ignored.
+func WithSettle(samples int) Option {
+	return func(d *Detector) {
+		if samples > 0 {
+			d.settle = samples
+		}
+	}
+}
+
+// WithTolerance sets the default relative power deviation that still
+// matches a signature.
+func WithTolerance(tolerance float64) Option {
+	return func(d *Detector) {
+		if tolerance > 0 {
+			d.tolerance = tolerance
+		}
+	}
+}
+
+// WithStore sets where signatures are loaded from and saved to. The
+// default keeps them in memory.
+func WithStore(store Store) Option {
+	return func(d *Detector) {
+		d.store = store
+	}
+}
+
+// WithEventBus publishes appliance on/off events to bus.
+func WithEventBus(bus *events.EventBus) Option {
+	return func(d *Detector) {
+		d.bus = bus
+	}
+}
+
+// phaseState is the step detector state of one phase.
+type phaseState struct {
+	pending  []Sample
+	level    float64
+	current  float64
+	hasLevel bool
+}
+
+// Detector recognizes appliances switching on and off from the power
+// samples of one meter.
+//
+// Each phase keeps a steady power level. A change of at least the
+// minimum step that holds for the settle count of samples is a step; it
+// is matched against the labeled signatures by size and, when current
+// and voltage are known, power factor. Recognized steps update the
+// appliance state and are published as events. Unrecognized steps are
+// clustered into candidates that can be labeled as new signatures.
+type Detector struct {
+	store      Store
+	bus        *events.EventBus
+	phases     map[energy.Phase]*phaseState
+	appliances map[string]*ApplianceState
+	deviceID   string
+	signatures []Signature
+	candidates []*Candidate
+	minStep    float64
+	tolerance  float64
+	settle     int
+	nextCand   int
+	mu         sync.Mutex
+}
+
+// NewDetector creates a detector for the meter with the given device ID
+// and loads the stored signatures.
+func NewDetector(deviceID string, opts ...Option) (*Detector, error) {
+	d := &Detector{
+		deviceID:   deviceID,
+		store:      NewMemoryStore(),
+		phases:     make(map[energy.Phase]*phaseState),
+		appliances: make(map[string]*ApplianceState),
+		minStep:    DefaultMinStep,
+		settle:     DefaultSettle,
+		tolerance:  DefaultTolerance,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	signatures, err := d.store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signatures: %w", err)
+	}
+	d.signatures = signatures
+	return d, nil
+}
+
+// Observe feeds a sample and returns the steps it completes, at most one.
+func (d *Detector) Observe(s Sample) []Step {
+	d.mu.Lock()
+	step, ok := d.detect(s)
+	if !ok {
+		d.mu.Unlock()
+		return nil
+	}
+	d.match(&step)
+	d.mu.Unlock()
+
+	if d.bus != nil && step.Appliance != "" {
+		d.bus.Publish(events.NewApplianceEvent(d.deviceID, step.Appliance, step.On(), math.Abs(step.Power)).
+			WithPhase(string(step.Phase)).
+			WithConfidence(step.Confidence).
+			WithTimestamp(step.Time))
+	}
+	return []Step{step}
+}
+
+// detect runs the step detector of the sample's phase.
+func (d *Detector) detect(s Sample) (Step, bool) {
+	ps, ok := d.phases[s.Phase]
+	if !ok {
+		ps = &phaseState{}
+		d.phases[s.Phase] = ps
+	}
+	if !ps.hasLevel {
+		ps.level, ps.current, ps.hasLevel = s.Power, s.Current, true
+		return Step{}, false
+	}
+
+	if len(ps.pending) == 0 {
+		if math.Abs(s.Power-ps.level) < d.minStep {
+			ps.level += (s.Power - ps.level) * levelWeight
+			ps.current += (s.Current - ps.current) * levelWeight
+			return Step{}, false
+		}
+		ps.pending = append(ps.pending, s)
+	} else {
+		last := ps.pending[len(ps.pending)-1]
+		switch {
+		case math.Abs(s.Power-last.Power) < d.minStep/2:
+			ps.pending = append(ps.pending, s)
+		case math.Abs(s.Power-ps.level) < d.minStep:
+			// Back at the old level: a spike, not a step.
+			ps.pending = nil
+			return Step{}, false
+		default:
+			// Still moving: restart the transition here.
+			ps.pending = append(ps.pending[:0], s)
+		}
+	}
+	if len(ps.pending) < d.settle {
+		return Step{}, false
+	}
+
+	var power, current, voltage float64
+	for _, p := range ps.pending {
+		power += p.Power
+		current += p.Current
+		voltage += p.Voltage
+	}
+	n := float64(len(ps.pending))
+	power, current, voltage = power/n, current/n, voltage/n
+
+	step := Step{Time: ps.pending[0].Time, Phase: s.Phase, Power: power - ps.level}
+	if dI := math.Abs(current - ps.current); voltage > 0 && dI >= minDeltaCurrent {
+		step.PowerFactor = min(math.Abs(step.Power)/(voltage*dI), 1)
+	}
+	ps.level, ps.current, ps.pending = power, current, nil
+	if math.Abs(step.Power) < d.minStep {
+		return Step{}, false
+	}
+	return step, true
+}
+
+// match recognizes a step, updating appliance states or candidates.
+func (d *Detector) match(step *Step) {
+	best, bestDist := -1, math.Inf(1)
+	bestOn := false
+	for i := range d.signatures {
+		sig := &d.signatures[i]
+		dist := sig.distance(step, d.tolerance, d.minStep)
+		if dist > 1 {
+			continue
+		}
+		// A falling step prefers appliances that are on.
+		isOn := !step.On() && d.appliances[sig.Name] != nil && d.appliances[sig.Name].On
+		if best < 0 || (isOn && !bestOn) || (isOn == bestOn && dist < bestDist) {
+			best, bestDist, bestOn = i, dist, isOn
+		}
+	}
+
+	if best < 0 {
+		d.addCandidate(step)
+		return
+	}
+
+	sig := &d.signatures[best]
+	step.Appliance = sig.Name
+	step.Signature = sig.ID
+	step.Confidence = 1 - bestDist
+	state, ok := d.appliances[sig.Name]
+	if !ok {
+		state = &ApplianceState{Name: sig.Name}
+		d.appliances[sig.Name] = state
+	}
+	state.On = step.On()
+	state.Since = step.Time
+	state.Phase = step.Phase
+	state.Power = math.Abs(step.Power)
+}
+
+// addCandidate clusters an unrecognized step.
+func (d *Detector) addCandidate(step *Step) {
+	probe := Signature{Phase: step.Phase}
+	for _, c := range d.candidates {
+		probe.Power, probe.PowerFactor = c.Power, c.PowerFactor
+		if c.Phase == step.Phase && probe.distance(step, d.tolerance, d.minStep) <= 1 {
+			c.add(step)
+			return
+		}
+	}
+
+	if len(d.candidates) >= maxCandidates {
+		// Drop the least recently seen cluster.
+		oldest := 0
+		for i, c := range d.candidates {
+			if c.Last.Before(d.candidates[oldest].Last) {
+				oldest = i
+			}
+		}
+		d.candidates = append(d.candidates[:oldest], d.candidates[oldest+1:]...)
+	}
+	d.nextCand++
+	c := &Candidate{ID: d.nextCand, Phase: step.Phase, First: step.Time}
+	c.add(step)
+	d.candidates = append(d.candidates, c)
+}
+
+// Candidates returns the clusters of unrecognized steps, most frequent
+// first.
+func (d *Detector) Candidates() []Candidate {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Candidate, len(d.candidates))
+	for i, c := range d.candidates {
+		out[i] = *c
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].On+out[i].Off > out[j].On+out[j].Off })
+	return out
+}
+
+// Label turns a candidate into a signature named name, saves it and
+// returns it. The candidate is removed; later steps of its size are
+// recognized as the appliance.
+func (d *Detector) Label(candidateID int, name string) (Signature, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, c := range d.candidates {
+		if c.ID != candidateID {
+			continue
+		}
+		sig := Signature{Name: name, Phase: c.Phase, Power: c.Power, PowerFactor: c.PowerFactor}
+		if err := d.addLocked(&sig); err != nil {
+			return Signature{}, err
+		}
+		d.candidates = append(d.candidates[:i], d.candidates[i+1:]...)
+		return sig, nil
+	}
+	return Signature{}, fmt.Errorf("%w: candidate %d", ErrUnknownSignature, candidateID)
+}
+
+// AddSignature adds and saves a signature, e.g. one measured by hand. Its
+// ID is assigned and returned.
+func (d *Detector) AddSignature(sig Signature) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.addLocked(&sig); err != nil {
+		return 0, err
+	}
+	return sig.ID, nil
+}
+
+func (d *Detector) addLocked(sig *Signature) error {
+	if sig.Name == "" || sig.Power <= 0 {
+		return fmt.Errorf("%w: name and power are required", ErrInvalidSignature)
+	}
+	sig.ID = 0
+	for _, s := range d.signatures {
+		sig.ID = max(sig.ID, s.ID)
+	}
+	sig.ID++
+	signatures := append(append([]Signature(nil), d.signatures...), *sig)
+	if err := d.store.Save(signatures); err != nil {
+		return fmt.Errorf("failed to save signatures: %w", err)
+	}
+	d.signatures = signatures
+	return nil
+}
+
+// RemoveSignature removes and saves a signature.
+func (d *Detector) RemoveSignature(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, s := range d.signatures {
+		if s.ID != id {
+			continue
+		}
+		signatures := append(append([]Signature(nil), d.signatures[:i]...), d.signatures[i+1:]...)
+		if err := d.store.Save(signatures); err != nil {
+			return fmt.Errorf("failed to save signatures: %w", err)
+		}
+		d.signatures = signatures
+		return nil
+	}
+	return fmt.Errorf("%w: signature %d", ErrUnknownSignature, id)
+}
+
+// Signatures returns the labeled signatures.
+func (d *Detector) Signatures() []Signature {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Signature(nil), d.signatures...)
+}
+
+// Appliances returns the inferred states of recognized appliances, sorted
+// by name.
+func (d *Detector) Appliances() []ApplianceState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]ApplianceState, 0, len(d.appliances))
+	for _, a := range d.appliances {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,183 @@
+package nilm
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	"github.com/tj-smith47/shelly-go/events"
+)
+
+var t0 = time.Date(2025, 3, 4, 7, 0, 0, 0, time.UTC)
+
+// feed observes powers on phase A, one second apart from start, and
+// returns the steps.
+func feed(d *Detector, start time.Duration, powers ...float64) []Step {
+	var steps []Step
+	for i, p := range powers {
+		ts := t0.Add(start + time.Duration(i)*time.Second)
+		steps = append(steps, d.Observe(Sample{Time: ts, Phase: energy.PhaseA, Power: p})...)
+	}
+	return steps
+}
+
+func TestDetector_Steps(t *testing.T) {
+	tests := []struct {
+		name   string
+		powers []float64
+		want   []float64 // step powers
+	}{
+		{name: "noise", powers: []float64{100, 104, 97, 110, 95, 102}},
+		{name: "on and off", powers: []float64{100, 100, 2100, 2100, 2100, 100, 100}, want: []float64{2000, -2000}},
+		// The inrush peak doesn't count; the step settles at 600 W.
+		{name: "inrush", powers: []float64{100, 1500, 700, 700}, want: []float64{600}},
+		{name: "spike", powers: []float64{100, 900, 100, 100}},
+		{name: "slow drift", powers: []float64{100, 120, 140, 160, 180, 200, 220}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDetector("meter")
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps := feed(d, 0, tt.powers...)
+			if len(steps) != len(tt.want) {
+				t.Fatalf("steps = %+v, want %v", steps, tt.want)
+			}
+			for i, s := range steps {
+				if math.Abs(s.Power-tt.want[i]) > 1e-6 {
+					t.Errorf("step %d = %v, want %v", i, s.Power, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDetector_PowerFactor(t *testing.T) {
+	d, _ := NewDetector("meter")
+	samples := []Sample{
+		{Phase: energy.PhaseB, Power: 50, Current: 0.25, Voltage: 230},
+		{Phase: energy.PhaseB, Power: 150, Current: 1.25, Voltage: 230},
+		{Phase: energy.PhaseB, Power: 150, Current: 1.25, Voltage: 230},
+	}
+	var steps []Step
+	for i, s := range samples {
+		s.Time = t0.Add(time.Duration(i) * time.Second)
+		steps = append(steps, d.Observe(s)...)
+	}
+	// A 100 W motor drawing 1 A at 230 V.
+	if len(steps) != 1 || math.Abs(steps[0].PowerFactor-100.0/230) > 1e-6 {
+		t.Errorf("steps = %+v", steps)
+	}
+}
+
+func TestDetector_LearnAndRecognize(t *testing.T) {
+	bus := events.NewEventBus()
+	defer bus.Close()
+	var got []*events.ApplianceEvent
+	bus.SubscribeFiltered(events.ApplianceEvents(), func(e events.Event) {
+		got = append(got, e.(*events.ApplianceEvent))
+	})
+
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	d, err := NewDetector("meter", WithStore(NewFileStore(path)), WithEventBus(bus))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two kettle cycles and a fridge cycle, all unknown.
+	steps := feed(d, 0, 100, 100, 2100, 2100, 100, 100, 2080, 2080, 100, 100, 220, 220, 100, 100)
+	if len(steps) != 6 || steps[0].Appliance != "" {
+		t.Fatalf("steps = %+v", steps)
+	}
+	candidates := d.Candidates()
+	if len(candidates) != 2 || candidates[0].On != 2 || candidates[0].Off != 2 || math.Abs(candidates[0].Power-1990) > 1e-6 {
+		t.Fatalf("candidates = %+v", candidates)
+	}
+
+	sig, err := d.Label(candidates[0].ID, "kettle")
+	if err != nil || sig.ID != 1 || sig.Phase != energy.PhaseA {
+		t.Fatalf("Label() = %+v, %v", sig, err)
+	}
+	if _, err := d.Label(candidates[0].ID, "again"); !errors.Is(err, ErrUnknownSignature) {
+		t.Errorf("Label(removed) = %v", err)
+	}
+	if len(d.Candidates()) != 1 {
+		t.Errorf("candidates = %+v", d.Candidates())
+	}
+
+	// Signatures survive a restart.
+	d, err = NewDetector("meter", WithStore(NewFileStore(path)), WithEventBus(bus))
+	if err != nil || len(d.Signatures()) != 1 {
+		t.Fatalf("reloaded = %+v, %v", d.Signatures(), err)
+	}
+	steps = feed(d, time.Minute, 100, 100, 2050, 2050)
+	if len(steps) != 1 || steps[0].Appliance != "kettle" || steps[0].Confidence <= 0.5 {
+		t.Fatalf("steps = %+v", steps)
+	}
+	if states := d.Appliances(); len(states) != 1 || !states[0].On || !states[0].Since.Equal(t0.Add(time.Minute+2*time.Second)) {
+		t.Errorf("appliances = %+v", states)
+	}
+	feed(d, 2*time.Minute, 100, 100)
+	if states := d.Appliances(); states[0].On {
+		t.Errorf("appliances = %+v", states)
+	}
+
+	if len(got) != 2 || !got[0].On() || got[1].On() || got[0].Appliance != "kettle" || got[0].Phase != "a" {
+		t.Errorf("events = %+v", got)
+	}
+	if !got[0].Timestamp().Equal(t0.Add(time.Minute + 2*time.Second)) {
+		t.Errorf("event time = %v", got[0].Timestamp())
+	}
+}
+
+func TestDetector_PrefersRunningAppliance(t *testing.T) {
+	d, _ := NewDetector("meter")
+	_, _ = d.AddSignature(Signature{Name: "toaster", Power: 1000})
+	_, _ = d.AddSignature(Signature{Name: "iron", Power: 1040})
+
+	// 1030 W on: closest to the iron.
+	if steps := feed(d, 0, 0, 0, 1030, 1030); len(steps) != 1 || steps[0].Appliance != "iron" {
+		t.Fatalf("steps = %+v", steps)
+	}
+	// 1000 W off: closest to the toaster, but the iron is the one running.
+	if steps := feed(d, time.Minute, 30, 30); len(steps) != 1 || steps[0].Appliance != "iron" {
+		t.Errorf("steps = %+v", steps)
+	}
+}
+
+func TestDetector_Signatures(t *testing.T) {
+	store := NewMemoryStore()
+	d, _ := NewDetector("meter", WithStore(store), WithTolerance(0.2), WithMinStep(50), WithSettle(1))
+
+	id, err := d.AddSignature(Signature{ID: 42, Name: "dryer", Power: 2500, Phase: energy.PhaseC})
+	if err != nil || id != 1 {
+		t.Fatalf("AddSignature() = %v, %v", id, err)
+	}
+	if _, err := d.AddSignature(Signature{Name: "nothing"}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("AddSignature(no power) = %v", err)
+	}
+
+	// Settle 1 reports immediately; the phase must match.
+	if steps := feed(d, 0, 0, 2100); len(steps) != 1 || steps[0].Appliance != "" {
+		t.Errorf("phase A steps = %+v", steps)
+	}
+	step := d.Observe(Sample{Time: t0, Phase: energy.PhaseC})
+	step = append(step, d.Observe(Sample{Time: t0.Add(time.Second), Phase: energy.PhaseC, Power: 2100})...)
+	if len(step) != 1 || step[0].Appliance != "dryer" {
+		t.Errorf("phase C steps = %+v", step)
+	}
+
+	if err := d.RemoveSignature(id); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := store.Load(); len(saved) != 0 {
+		t.Errorf("saved = %+v", saved)
+	}
+	if err := d.RemoveSignature(id); !errors.Is(err, ErrUnknownSignature) {
+		t.Errorf("RemoveSignature(missing) = %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+// Package nilm recognizes appliances switching on and off from the power
+// steps they cause on a meter (non-intrusive load monitoring, "lite").
+//
+// A Detector tracks the power level of each phase. When the level moves
+// by at least the minimum step and stays there for a few samples, the
+// change is a step; short inrush peaks and spikes that return to the old
+// level are ignored. With current and voltage available the step's power
+// factor is derived too, which helps tell resistive and motor loads of
+// similar size apart.
+//
+//	d, err := nilm.NewDetector("shellypro3em-a0b1c2",
+//	    nilm.WithStore(nilm.NewFileStore("signatures.json")),
+//	    nilm.WithEventBus(bus),
+//	)
+//	d.Subscribe(bus, nil)
+//
+// Steps matching a known Signature are reported as events.ApplianceEvent
+// (appliance_on/appliance_off) on the event bus. A falling step prefers
+// appliances that are currently on.
+//
+// # Learning
+//
+// Unrecognized steps are clustered by size and phase into Candidates.
+// Labeling a candidate turns it into a signature, which is saved to the
+// Store and recognized from then on:
+//
+//	for _, c := range d.Candidates() {
+//	    fmt.Printf("%d: %.0f W on %s, seen %d times\n", c.ID, c.Power, c.Phase, c.On+c.Off)
+//	}
+//	sig, err := d.Label(3, "kettle")
+//
+// # Samples
+//
+// Samples come from EM, EM1 and PM1 status changes (Subscribe,
+// ObserveStatus), from polling an EM component (Poll) or from explicit
+// Observe calls. Detection needs samples one to a few seconds apart.
+package nilm
@@ -0,0 +1,197 @@
+package nilm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+)
+
+// ErrUnknownSignature is returned for a signature or candidate ID that
+// doesn't exist.
+var ErrUnknownSignature = errors.New("nilm: unknown signature")
+
+// ErrInvalidSignature is returned for a signature without a name or power.
+var ErrInvalidSignature = errors.New("nilm: invalid signature")
+
+// pfTolerance is the power factor difference that counts as a full
+// mismatch.
+const pfTolerance = 0.15
+
+// Signature is the step-change signature of an appliance.
+type Signature struct {
+	// Name is the appliance label, e.g. "kettle". Several signatures may
+	// share a name, e.g. for a dryer's heater and motor.
+	Name string `json:"name"`
+
+	// Phase restricts matches to one phase; empty matches any phase.
+	Phase energy.Phase `json:"phase,omitempty"`
+
+	// ID identifies the signature; it is assigned when the signature is
+	// added.
+	ID int `json:"id"`
+
+	// Power is the step size in watts.
+	Power float64 `json:"power"`
+
+	// PowerFactor is the appliance's power factor; zero if unknown.
+	PowerFactor float64 `json:"pf,omitempty"`
+
+	// Tolerance is the relative power deviation that still matches; zero
+	// uses the detector default.
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// distance scores how far a step is from the signature: 0 is an exact
+// match and values above 1 don't match.
+func (s *Signature) distance(step *Step, defaultTol, minStep float64) float64 {
+	if s.Phase != "" && s.Phase != step.Phase {
+		return math.Inf(1)
+	}
+	tol := s.Tolerance
+	if tol <= 0 {
+		tol = defaultTol
+	}
+	d := math.Abs(math.Abs(step.Power)-s.Power) / max(s.Power*tol, minStep/2)
+	if s.PowerFactor > 0 && step.PowerFactor > 0 {
+		d = max(d, math.Abs(step.PowerFactor-s.PowerFactor)/pfTolerance)
+	}
+	return d
+}
+
+// Candidate is a cluster of unrecognized steps of similar size that can
+// be labeled as a new signature.
+type Candidate struct {
+	// First and Last are the times of the first and last step.
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+
+	// Phase is the phase the steps were measured on.
+	Phase energy.Phase `json:"phase"`
+
+	// ID identifies the candidate for Label.
+	ID int `json:"id"`
+
+	// Power is the mean step size in watts.
+	Power float64 `json:"power"`
+
+	// PowerFactor is the mean appliance power factor; zero if unknown.
+	PowerFactor float64 `json:"pf,omitempty"`
+
+	// On and Off count the rising and falling steps.
+	On  int `json:"on"`
+	Off int `json:"off"`
+
+	pfCount int
+}
+
+// add merges a step into the cluster.
+func (c *Candidate) add(step *Step) {
+	n := float64(c.On + c.Off)
+	c.Power = (c.Power*n + math.Abs(step.Power)) / (n + 1)
+	if step.PowerFactor > 0 {
+		c.PowerFactor = (c.PowerFactor*float64(c.pfCount) + step.PowerFactor) / float64(c.pfCount+1)
+		c.pfCount++
+	}
+	if step.Power > 0 {
+		c.On++
+	} else {
+		c.Off++
+	}
+	c.Last = step.Time
+}
+
+// Store persists signatures.
+type Store interface {
+	// Load returns the saved signatures.
+	Load() ([]Signature, error)
+
+	// Save replaces the saved signatures.
+	Save(signatures []Signature) error
+}
+
+// MemoryStore keeps signatures in memory.
+type MemoryStore struct {
+	signatures []Signature
+	mu         sync.Mutex
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Load returns the saved signatures.
+func (s *MemoryStore) Load() ([]Signature, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Signature(nil), s.signatures...), nil
+}
+
+// Save replaces the saved signatures.
+func (s *MemoryStore) Save(signatures []Signature) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signatures = append([]Signature(nil), signatures...)
+	return nil
+}
+
+// FileStore keeps signatures in a JSON file. Saves write a temporary file
+// and rename it, so the file is never left half written.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore creates a store backed by the file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load returns the saved signatures; a missing file holds none.
+func (s *FileStore) Load() ([]Signature, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signatures: %w", err)
+	}
+	var signatures []Signature
+	if err := json.Unmarshal(data, &signatures); err != nil {
+		return nil, fmt.Errorf("failed to parse signatures: %w", err)
+	}
+	return signatures, nil
+}
+
+// Save replaces the saved signatures.
+func (s *FileStore) Save(signatures []Signature) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if signatures == nil {
+		signatures = []Signature{}
+	}
+	data, err := json.MarshalIndent(signatures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode signatures: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create signature directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write signatures: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write signatures: %w", err)
+	}
+	return nil
+}
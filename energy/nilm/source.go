@@ -0,0 +1,131 @@
+package nilm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// DefaultPollInterval is the interval used by Poll when none is given.
+const DefaultPollInterval = time.Second
+
+// em1Phases maps EM1 channel IDs to phases.
+var em1Phases = []energy.Phase{energy.PhaseA, energy.PhaseB, energy.PhaseC}
+
+// ObserveEM feeds the three phases of an EM status.
+func (d *Detector) ObserveEM(ts time.Time, status *components.EMStatus) []Step {
+	var steps []Step
+	steps = append(steps, d.Observe(Sample{Time: ts, Phase: energy.PhaseA, Power: status.AActivePower, Current: status.ACurrent, Voltage: status.AVoltage})...)
+	steps = append(steps, d.Observe(Sample{Time: ts, Phase: energy.PhaseB, Power: status.BActivePower, Current: status.BCurrent, Voltage: status.BVoltage})...)
+	steps = append(steps, d.Observe(Sample{Time: ts, Phase: energy.PhaseC, Power: status.CActivePower, Current: status.CCurrent, Voltage: status.CVoltage})...)
+	return steps
+}
+
+// ObserveEM1 feeds an EM1 status. Channels 0, 1 and 2 are phases A, B
+// and C; other channels are ignored.
+func (d *Detector) ObserveEM1(ts time.Time, status *components.EM1Status) []Step {
+	if status.ID < 0 || status.ID >= len(em1Phases) {
+		return nil
+	}
+	return d.Observe(Sample{Time: ts, Phase: em1Phases[status.ID], Power: status.ActPower, Current: status.Current, Voltage: status.Voltage})
+}
+
+// ObservePM1 feeds a PM1 status as the total phase.
+func (d *Detector) ObservePM1(ts time.Time, status *components.PM1Status) []Step {
+	return d.Observe(Sample{Time: ts, Phase: energy.PhaseTotal, Power: status.APower, Current: status.Current, Voltage: status.Voltage})
+}
+
+// ObserveStatus feeds the status of an "em:N", "em1:N" or "pm1:N"
+// component, such as the Status of an events.StatusChangeEvent. Other
+// components are ignored. Statuses without power values, such as partial
+// updates of other fields, are skipped.
+func (d *Detector) ObserveStatus(ts time.Time, component string, status json.RawMessage) ([]Step, error) {
+	kind, idStr, _ := strings.Cut(component, ":")
+	switch kind {
+	case "em":
+		var probe struct {
+			A *float64 `json:"a_act_power"`
+		}
+		var s components.EMStatus
+		if err := json.Unmarshal(status, &probe); err != nil {
+			return nil, fmt.Errorf("failed to parse %s status: %w", component, err)
+		}
+		if probe.A == nil {
+			return nil, nil
+		}
+		if err := json.Unmarshal(status, &s); err != nil {
+			return nil, fmt.Errorf("failed to parse %s status: %w", component, err)
+		}
+		return d.ObserveEM(ts, &s), nil
+
+	case "em1", "pm1":
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid component %q", component)
+		}
+		var s struct {
+			ActPower *float64 `json:"act_power"`
+			APower   *float64 `json:"apower"`
+			Current  float64  `json:"current"`
+			Voltage  float64  `json:"voltage"`
+		}
+		if err := json.Unmarshal(status, &s); err != nil {
+			return nil, fmt.Errorf("failed to parse %s status: %w", component, err)
+		}
+		if kind == "em1" && s.ActPower != nil {
+			return d.ObserveEM1(ts, &components.EM1Status{ID: id, ActPower: *s.ActPower, Current: s.Current, Voltage: s.Voltage}), nil
+		}
+		if kind == "pm1" && s.APower != nil {
+			return d.ObservePM1(ts, &components.PM1Status{ID: id, APower: *s.APower, Current: s.Current, Voltage: s.Voltage}), nil
+		}
+	}
+	return nil, nil
+}
+
+// Subscribe feeds the EM, EM1 and PM1 status changes of the detector's
+// device published on bus. Parse errors are passed to onError, which may
+// be nil. It returns the subscription ID for bus.Unsubscribe.
+func (d *Detector) Subscribe(bus *events.EventBus, onError func(error)) uint64 {
+	filter := events.And(events.StatusChange(), events.WithDeviceID(d.deviceID))
+	return bus.SubscribeFiltered(filter, func(e events.Event) {
+		sc, ok := e.(*events.StatusChangeEvent)
+		if !ok {
+			return
+		}
+		if _, err := d.ObserveStatus(sc.Timestamp(), sc.Component, sc.Status); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// Poll feeds an EM component's status every interval until ctx is
+// canceled. Read errors are passed to onError, which may be nil. Step
+// detection needs frequent samples: one to a few seconds apart.
+func (d *Detector) Poll(ctx context.Context, interval time.Duration, em *components.EM, onError func(error)) error {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := em.GetStatus(ctx)
+		switch {
+		case err == nil:
+			d.ObserveEM(time.Now(), status)
+		case onError != nil && ctx.Err() == nil:
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
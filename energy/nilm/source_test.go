@@ -0,0 +1,154 @@
+package nilm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+func TestObserveStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		component string
+		statuses  []string
+		phase     energy.Phase
+		wantSteps int
+		wantErr   bool
+	}{
+		{
+			name:      "em",
+			component: "em:0",
+			statuses:  []string{`{"a_act_power":100,"b_act_power":0,"c_act_power":0}`, `{"a_act_power":1100,"b_act_power":0,"c_act_power":0}`, `{"a_act_power":1100,"b_act_power":0,"c_act_power":0}`},
+			phase:     energy.PhaseA,
+			wantSteps: 1,
+		},
+		{
+			name:      "em partial update",
+			component: "em:0",
+			statuses:  []string{`{"a_current":1.2}`},
+		},
+		{
+			name:      "em1",
+			component: "em1:1",
+			statuses:  []string{`{"id":1,"act_power":0}`, `{"id":1,"act_power":500}`, `{"id":1,"act_power":500}`},
+			phase:     energy.PhaseB,
+			wantSteps: 1,
+		},
+		{
+			name:      "pm1",
+			component: "pm1:0",
+			statuses:  []string{`{"id":0,"apower":0}`, `{"id":0,"apower":60}`, `{"id":0,"apower":60}`},
+			phase:     energy.PhaseTotal,
+			wantSteps: 1,
+		},
+		{name: "other component", component: "switch:0", statuses: []string{`{"output":true}`}},
+		{name: "bad json", component: "em1:0", statuses: []string{`[`}, wantErr: true},
+		{name: "bad id", component: "pm1:x", statuses: []string{`{}`}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := NewDetector("meter")
+			var steps []Step
+			var err error
+			for i, status := range tt.statuses {
+				var got []Step
+				got, err = d.ObserveStatus(t0.Add(time.Duration(i)*time.Second), tt.component, json.RawMessage(status))
+				steps = append(steps, got...)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v", err)
+			}
+			if len(steps) != tt.wantSteps {
+				t.Fatalf("steps = %+v", steps)
+			}
+			if len(steps) > 0 && steps[0].Phase != tt.phase {
+				t.Errorf("phase = %q, want %q", steps[0].Phase, tt.phase)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := events.NewEventBus()
+	defer bus.Close()
+	var appliances []*events.ApplianceEvent
+	bus.SubscribeFiltered(events.ApplianceEvents(), func(e events.Event) {
+		appliances = append(appliances, e.(*events.ApplianceEvent))
+	})
+
+	d, _ := NewDetector("shellypmmini-1", WithEventBus(bus))
+	_, _ = d.AddSignature(Signature{Name: "lamp", Power: 60})
+	d.Subscribe(bus, nil)
+
+	for _, power := range []string{`0`, `60`, `60`} {
+		status := json.RawMessage(`{"id":0,"apower":` + power + `}`)
+		// Status changes of other devices are ignored.
+		bus.Publish(events.NewStatusChangeEvent("other", "pm1:0", json.RawMessage(`{"id":0,"apower":2000}`)))
+		bus.Publish(events.NewStatusChangeEvent("shellypmmini-1", "pm1:0", status))
+	}
+	if len(appliances) != 1 || appliances[0].Appliance != "lamp" || appliances[0].DeviceID() != "shellypmmini-1" {
+		t.Errorf("events = %+v", appliances)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	powers := []float64{0, 800, 800}
+	calls := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mock := &mockTransport{
+		callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+			p := powers[min(calls, len(powers)-1)]
+			calls++
+			if calls == len(powers) {
+				cancel()
+			}
+			return jsonrpcResponse(map[string]any{"id": 0, "c_act_power": p})
+		},
+	}
+
+	d, _ := NewDetector("meter")
+	err := d.Poll(ctx, time.Millisecond, components.NewEM(rpc.NewClient(mock), 0), nil)
+	if err != context.Canceled {
+		t.Errorf("Poll() = %v", err)
+	}
+	if c := d.Candidates(); len(c) != 1 || c[0].Phase != energy.PhaseC || c[0].Power != 800 {
+		t.Errorf("candidates = %+v", c)
+	}
+}
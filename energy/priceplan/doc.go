@@ -0,0 +1,42 @@
+// Package priceplan runs loads such as water heaters and EV chargers
+// during the cheapest hours of a dynamic (day-ahead) tariff.
+//
+// A Planner reads a price curve from a Source, chooses the cheapest
+// windows for each load within its earliest start and deadline, and
+// installs the plan on the load's device as schedules, so it runs even
+// when the planning server is offline.
+//
+//	planner := priceplan.NewPlanner(priceplan.FileSource("/var/lib/prices.json"),
+//	    priceplan.WithLocation(loc))
+//	err := planner.AddLoad(priceplan.Load{
+//	    Name:     "ev charger",
+//	    Target:   priceplan.Gen2Target(charger, 0),
+//	    Earliest: "18:00",
+//	    Deadline: "07:00",
+//	    Runtime:  4 * time.Hour,
+//	    Power:    11000,
+//	})
+//	// Plan and apply now, then daily after prices are published.
+//	err = planner.Run(ctx, helpers.NewScheduleTime(14, 0), nil)
+//
+// # Prices
+//
+// FileSource reads JSON ({"start", "end", "price"} objects, bare or under
+// "prices") or CSV (a header with start, price and optional end columns).
+// Any other feed plugs in through the Source interface or SourceFunc.
+//
+// # Planning
+//
+// A load's run time may be split over the cheapest intervals, or with
+// Contiguous kept in one run. Run time that doesn't fit before the
+// deadline or within the known prices is reported as Shortfall.
+//
+// # Targets
+//
+// Gen2Target compiles windows to Schedule jobs with helpers.CreateSchedule
+// and remembers their IDs in the device KVS, so each new plan replaces
+// only the previous plan's jobs. New jobs are created before old ones are
+// deleted when the device's 20 Schedule slots allow it, and after
+// otherwise; a failed install is rolled back either way. Gen1Target writes
+// the windows as the schedule rules of a relay in a single request.
+package priceplan
@@ -0,0 +1,403 @@
+package priceplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/helpers"
+)
+
+// ErrInvalidLoad is returned for a load without a name or run time, or
+// with an unparsable earliest start or deadline.
+var ErrInvalidLoad = errors.New("priceplan: invalid load")
+
+// Load is a load to run during the cheapest hours.
+type Load struct {
+	// Target installs the load's plan on its device; nil plans without
+	// applying.
+	Target Target
+
+	// Name identifies the load.
+	Name string
+
+	// Earliest is the local clock time ("HH:MM") the load may start;
+	// empty allows any time from now on.
+	Earliest string
+
+	// Deadline is the local clock time ("HH:MM") by which the run time
+	// must be complete, the first one after the window opens; empty
+	// allows the whole price curve.
+	Deadline string
+
+	// Runtime is the required run time per plan.
+	Runtime time.Duration
+
+	// Power is the load's draw in watts, used to estimate the plan cost.
+	Power float64
+
+	// Contiguous requires a single uninterrupted run, e.g. for appliances
+	// that can't be paused. Otherwise the run time may be split over the
+	// cheapest intervals.
+	Contiguous bool
+}
+
+func (l *Load) validate() error {
+	if l.Name == "" || l.Runtime <= 0 {
+		return fmt.Errorf("%w: name and run time are required", ErrInvalidLoad)
+	}
+	for _, s := range []string{l.Earliest, l.Deadline} {
+		if s == "" {
+			continue
+		}
+		if _, err := helpers.ParseScheduleTime(s); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidLoad, l.Name, err)
+		}
+	}
+	return nil
+}
+
+// Window is a period during which a load runs.
+type Window struct {
+	// Start and End delimit the run.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Price is the time-weighted average price of the window.
+	Price float64 `json:"price"`
+}
+
+// Duration returns the window length.
+func (w Window) Duration() time.Duration {
+	return w.End.Sub(w.Start)
+}
+
+// LoadPlan is the plan of one load.
+type LoadPlan struct {
+	// Load is the load name.
+	Load string `json:"load"`
+
+	// From and To is the allowed window the plan was chosen from.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// Windows are the planned runs in time order.
+	Windows []Window `json:"windows"`
+
+	// Runtime is the planned run time; Shortfall is the part of the
+	// required run time that didn't fit before the deadline or beyond the
+	// known prices.
+	Runtime   time.Duration `json:"runtime"`
+	Shortfall time.Duration `json:"shortfall,omitempty"`
+
+	// Price is the time-weighted average price of the plan.
+	Price float64 `json:"price"`
+
+	// Cost is the estimated cost at the load's power; zero without power.
+	Cost float64 `json:"cost"`
+}
+
+// Plan is a set of load plans computed from one price curve.
+type Plan struct {
+	// Created is the planning time.
+	Created time.Time `json:"created"`
+
+	// Loads has one plan per load, in the order they were added.
+	Loads []LoadPlan `json:"loads"`
+}
+
+// Option configures a Planner.
+type Option func(*Planner)
+
+// WithLocation sets the location of load clock times and schedule jobs;
+// it should match the devices' timezone. The default is time.Local.
+func WithLocation(loc *time.Location) Option {
+	return func(p *Planner) {
+		if loc != nil {
+			p.loc = loc
+		}
+	}
+}
+
+// Planner plans loads into the cheapest hours of a price curve and
+// installs the plans as device schedules.
+type Planner struct {
+	source Source
+	loc    *time.Location
+	loads  []Load
+	mu     sync.Mutex
+}
+
+// NewPlanner creates a planner reading prices from source.
+func NewPlanner(source Source, opts ...Option) *Planner {
+	p := &Planner{source: source, loc: time.Local}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// AddLoad adds a load to plan.
+func (p *Planner) AddLoad(load Load) error {
+	if err := load.validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.loads {
+		if p.loads[i].Name == load.Name {
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalidLoad, load.Name)
+		}
+	}
+	p.loads = append(p.loads, load)
+	return nil
+}
+
+// Plan fetches prices from now on and plans every load.
+func (p *Planner) Plan(ctx context.Context, now time.Time) (*Plan, error) {
+	curve, err := p.source.Prices(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prices: %w", err)
+	}
+	p.mu.Lock()
+	loads := append([]Load(nil), p.loads...)
+	p.mu.Unlock()
+
+	plan := &Plan{Created: now}
+	for i := range loads {
+		plan.Loads = append(plan.Loads, PlanLoad(curve, &loads[i], now, p.loc))
+	}
+	return plan, nil
+}
+
+// Apply installs each load plan on its load's target. Every target
+// replaces its previous plan; a failing target keeps its previous plan
+// and the others are still applied.
+func (p *Planner) Apply(ctx context.Context, plan *Plan) error {
+	p.mu.Lock()
+	targets := make(map[string]Target, len(p.loads))
+	for _, l := range p.loads {
+		targets[l.Name] = l.Target
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, lp := range plan.Loads {
+		target := targets[lp.Load]
+		if target == nil {
+			continue
+		}
+		if err := target.Apply(ctx, lp.Windows, p.loc); err != nil {
+			errs = append(errs, fmt.Errorf("failed to apply plan for %s: %w", lp.Load, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run plans and applies immediately and then daily at the given local
+// time, e.g. after day-ahead prices are published, until ctx is canceled.
+// Errors are passed to onError, which may be nil.
+func (p *Planner) Run(ctx context.Context, at helpers.ScheduleTime, onError func(error)) error {
+	for {
+		now := time.Now()
+		plan, err := p.Plan(ctx, now)
+		if err == nil {
+			err = p.Apply(ctx, plan)
+		}
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		next := nextClock(now, at, p.loc)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// PlanLoad plans one load on a price curve. The allowed window opens at
+// now, or at the next Earliest time if now is outside the load's window,
+// and closes at the Deadline; both are clock times in loc.
+func PlanLoad(curve Curve, load *Load, now time.Time, loc *time.Location) LoadPlan {
+	from, to := loadWindow(load, now, curve.End(), loc)
+	lp := LoadPlan{Load: load.Name, From: from, To: to}
+
+	slots := clip(curve, from, to)
+	var pieces []Price
+	if load.Contiguous {
+		pieces = cheapestRun(slots, load.Runtime)
+	} else {
+		pieces = cheapestSlots(slots, load.Runtime)
+	}
+
+	var weighted float64
+	for _, piece := range pieces {
+		d := piece.End.Sub(piece.Start)
+		lp.Runtime += d
+		weighted += piece.Price * d.Hours()
+		if n := len(lp.Windows); n > 0 && lp.Windows[n-1].End.Equal(piece.Start) {
+			w := &lp.Windows[n-1]
+			w.Price = (w.Price*w.Duration().Hours() + piece.Price*d.Hours()) / (w.Duration() + d).Hours()
+			w.End = piece.End
+			continue
+		}
+		lp.Windows = append(lp.Windows, Window(piece))
+	}
+	if lp.Runtime > 0 {
+		lp.Price = weighted / lp.Runtime.Hours()
+	}
+	lp.Cost = weighted * load.Power / 1000
+	lp.Shortfall = max(load.Runtime-lp.Runtime, 0)
+	return lp
+}
+
+// loadWindow resolves a load's clock times to the window open at now, or
+// the next one. Without a deadline the window opens at the next Earliest
+// time.
+func loadWindow(load *Load, now, curveEnd time.Time, loc *time.Location) (from, to time.Time) {
+	from = now
+	if load.Earliest != "" {
+		earliest, _ := helpers.ParseScheduleTime(load.Earliest) //nolint:errcheck // Validated by AddLoad
+		// The most recent opening at or before now.
+		opened := nextClock(now.Add(-24*time.Hour), earliest, loc)
+		if load.Deadline == "" || !deadlineAfter(load, opened, loc).After(now) {
+			from = nextClock(now, earliest, loc)
+		}
+	}
+	to = curveEnd
+	if load.Deadline != "" {
+		to = deadlineAfter(load, from, loc)
+	}
+	return from, to
+}
+
+func deadlineAfter(load *Load, ts time.Time, loc *time.Location) time.Time {
+	deadline, _ := helpers.ParseScheduleTime(load.Deadline) //nolint:errcheck // Validated by AddLoad
+	return nextClock(ts, deadline, loc)
+}
+
+// nextClock returns the first time after ts at the clock time in loc.
+func nextClock(ts time.Time, at helpers.ScheduleTime, loc *time.Location) time.Time {
+	local := ts.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), at.Hour, at.Minute, 0, 0, loc)
+	for !next.After(ts) {
+		next = time.Date(next.Year(), next.Month(), next.Day()+1, at.Hour, at.Minute, 0, 0, loc)
+	}
+	return next
+}
+
+// clip returns the parts of the curve within [from, to).
+func clip(curve Curve, from, to time.Time) []Price {
+	var out []Price
+	for _, p := range curve {
+		if !p.End.After(from) || !p.Start.Before(to) {
+			continue
+		}
+		if p.Start.Before(from) {
+			p.Start = from
+		}
+		if p.End.After(to) {
+			p.End = to
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// cheapestSlots picks the cheapest intervals covering runtime, in time
+// order. A partially used interval is used from the side adjoining
+// another picked interval, to avoid extra switching.
+func cheapestSlots(slots []Price, runtime time.Duration) []Price {
+	order := make([]int, len(slots))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return slots[order[a]].Price < slots[order[b]].Price })
+
+	picked := make([]bool, len(slots))
+	partial := -1
+	remaining := runtime
+	for _, i := range order {
+		if remaining <= 0 {
+			break
+		}
+		if d := slots[i].End.Sub(slots[i].Start); d <= remaining {
+			picked[i] = true
+			remaining -= d
+			continue
+		}
+		partial = i
+		break
+	}
+
+	var out []Price
+	for i, s := range slots {
+		switch {
+		case picked[i]:
+			out = append(out, s)
+		case i == partial:
+			adjoinsNext := i+1 < len(slots) && picked[i+1] && slots[i+1].Start.Equal(s.End)
+			adjoinsPrev := i > 0 && picked[i-1] && slots[i-1].End.Equal(s.Start)
+			if adjoinsNext && !adjoinsPrev {
+				s.Start = s.End.Add(-remaining)
+			} else {
+				s.End = s.Start.Add(remaining)
+			}
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// cheapestRun picks the cheapest uninterrupted run of runtime. For
+// piecewise-constant prices the optimum starts or ends at an interval
+// boundary, so only those candidates are priced. If no run is long
+// enough, the longest one is used.
+func cheapestRun(slots []Price, runtime time.Duration) []Price {
+	// Split into gapless runs.
+	var runs [][]Price
+	for i, s := range slots {
+		if i == 0 || !slots[i-1].End.Equal(s.Start) {
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], s)
+	}
+
+	longest := time.Duration(0)
+	for _, run := range runs {
+		longest = max(longest, run[len(run)-1].End.Sub(run[0].Start))
+	}
+	runtime = min(runtime, longest)
+	if runtime <= 0 {
+		return nil
+	}
+
+	var best []Price
+	bestCost := 0.0
+	for _, run := range runs {
+		end := run[len(run)-1].End
+		for _, s := range run {
+			for _, start := range []time.Time{s.Start, s.End.Add(-runtime)} {
+				if start.Before(run[0].Start) || start.Add(runtime).After(end) {
+					continue
+				}
+				pieces := clip(run, start, start.Add(runtime))
+				cost := 0.0
+				for _, p := range pieces {
+					cost += p.Price * p.End.Sub(p.Start).Hours()
+				}
+				if best == nil || cost < bestCost || (cost == bestCost && start.Before(best[0].Start)) {
+					best, bestCost = pieces, cost
+				}
+			}
+		}
+	}
+	return best
+}
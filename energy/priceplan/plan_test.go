@@ -0,0 +1,184 @@
+package priceplan
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// hourlyCurve returns hourly prices starting at start.
+func hourlyCurve(start time.Time, prices ...float64) Curve {
+	curve := make(Curve, len(prices))
+	for i, p := range prices {
+		curve[i] = Price{Start: start.Add(time.Duration(i) * time.Hour), End: start.Add(time.Duration(i+1) * time.Hour), Price: p}
+	}
+	return curve
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPlanLoad(t *testing.T) {
+	day := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	//                        00   01   02   03   04   05   06   07
+	curve := hourlyCurve(day, 0.30, 0.10, 0.25, 0.05, 0.20, 0.08, 0.40, 0.50)
+
+	type window struct{ start, end int } // in minutes since day
+	tests := []struct {
+		name      string
+		load      Load
+		now       time.Duration
+		want      []window
+		shortfall time.Duration
+		price     float64
+	}{
+		{
+			name:  "split over cheapest hours",
+			load:  Load{Name: "heater", Runtime: 3 * time.Hour},
+			want:  []window{{60, 120}, {180, 240}, {300, 360}},
+			price: (0.10 + 0.05 + 0.08) / 3,
+		},
+		{
+			// The half hour of the third cheapest interval starts it.
+			name:  "partial interval",
+			load:  Load{Name: "heater", Runtime: 150 * time.Minute, Deadline: "06:00"},
+			want:  []window{{60, 90}, {180, 240}, {300, 360}},
+			price: (0.5*0.10 + 0.05 + 0.08) / 2.5,
+		},
+		{
+			// 05:00 is picked; the end of 04:00 joins it.
+			name:  "partial interval adjoins picked one",
+			load:  Load{Name: "heater", Runtime: 90 * time.Minute, Earliest: "04:00", Deadline: "07:00"},
+			want:  []window{{270, 360}},
+			price: (0.5*0.20 + 0.08) / 1.5,
+		},
+		{
+			name:  "contiguous",
+			load:  Load{Name: "dishwasher", Runtime: 2 * time.Hour, Contiguous: true},
+			want:  []window{{180, 300}},
+			price: (0.05 + 0.20) / 2,
+		},
+		{
+			// Ending at the deadline beats starting at an interval boundary.
+			name:  "contiguous ending at boundary",
+			load:  Load{Name: "dishwasher", Runtime: 90 * time.Minute, Deadline: "04:00", Contiguous: true},
+			want:  []window{{150, 240}},
+			price: (0.5*0.25 + 0.05) / 1.5,
+		},
+		{
+			name: "earliest and deadline",
+			load: Load{Name: "ev", Runtime: 2 * time.Hour, Earliest: "04:00", Deadline: "07:00"},
+			want: []window{{240, 360}},
+		},
+		{
+			name:      "shortfall at deadline",
+			load:      Load{Name: "ev", Runtime: 3 * time.Hour, Deadline: "02:00"},
+			now:       30 * time.Minute,
+			want:      []window{{30, 120}},
+			shortfall: 90 * time.Minute,
+		},
+		{
+			name:      "no prices left",
+			load:      Load{Name: "ev", Runtime: time.Hour, Earliest: "09:00"},
+			shortfall: time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := PlanLoad(curve, &tt.load, day.Add(tt.now), time.UTC)
+			if len(lp.Windows) != len(tt.want) {
+				t.Fatalf("windows = %+v, want %v", lp.Windows, tt.want)
+			}
+			for i, w := range lp.Windows {
+				got := window{int(w.Start.Sub(day).Minutes()), int(w.End.Sub(day).Minutes())}
+				if got != tt.want[i] {
+					t.Errorf("window %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+			if lp.Shortfall != tt.shortfall {
+				t.Errorf("shortfall = %v, want %v", lp.Shortfall, tt.shortfall)
+			}
+			if tt.price != 0 && !approx(lp.Price, tt.price) {
+				t.Errorf("price = %v, want %v", lp.Price, tt.price)
+			}
+		})
+	}
+}
+
+func TestLoadWindow(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	day := time.Date(2025, 3, 4, 0, 0, 0, 0, loc)
+	ev := &Load{Earliest: "18:00", Deadline: "07:00"}
+
+	tests := []struct {
+		name     string
+		load     *Load
+		now      time.Time
+		from, to time.Time
+	}{
+		{name: "before window", load: ev, now: day.Add(14 * time.Hour), from: day.Add(18 * time.Hour), to: day.Add(31 * time.Hour)},
+		{name: "inside window", load: ev, now: day.Add(20 * time.Hour), from: day.Add(20 * time.Hour), to: day.Add(31 * time.Hour)},
+		{name: "inside after midnight", load: ev, now: day.Add(3 * time.Hour), from: day.Add(3 * time.Hour), to: day.Add(7 * time.Hour)},
+		{name: "no deadline", load: &Load{Earliest: "18:00"}, now: day.Add(20 * time.Hour), from: day.Add(42 * time.Hour), to: day.Add(48 * time.Hour)},
+	}
+	for _, tt := range tests {
+		from, to := loadWindow(tt.load, tt.now, day.Add(48*time.Hour), loc)
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("%s: window = %v - %v, want %v - %v", tt.name, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+// recordingTarget records applied windows.
+type recordingTarget struct {
+	err     error
+	applied [][]Window
+}
+
+func (r *recordingTarget) Apply(_ context.Context, windows []Window, _ *time.Location) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.applied = append(r.applied, windows)
+	return nil
+}
+
+func TestPlanner(t *testing.T) {
+	day := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	source := SourceFunc(func(_ context.Context, ts time.Time) (Curve, error) {
+		return hourlyCurve(day, 0.3, 0.1, 0.2).Since(ts), nil
+	})
+	p := NewPlanner(source, WithLocation(time.UTC))
+
+	heater, broken := &recordingTarget{}, &recordingTarget{err: errors.New("offline")}
+	for _, l := range []Load{
+		{Name: "heater", Runtime: time.Hour, Power: 2000, Target: heater},
+		{Name: "pump", Runtime: time.Hour, Target: broken},
+		{Name: "report only", Runtime: time.Hour},
+	} {
+		if err := p.AddLoad(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, l := range []Load{{Name: "heater", Runtime: time.Hour}, {Name: "x"}, {Name: "y", Runtime: time.Hour, Deadline: "25:00"}} {
+		if err := p.AddLoad(l); !errors.Is(err, ErrInvalidLoad) {
+			t.Errorf("AddLoad(%s) = %v", l.Name, err)
+		}
+	}
+
+	plan, err := p.Plan(context.Background(), day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Loads) != 3 || !approx(plan.Loads[0].Cost, 0.2) || plan.Loads[0].Windows[0].Start != day.Add(time.Hour) {
+		t.Errorf("plan = %+v", plan)
+	}
+
+	err = p.Apply(context.Background(), plan)
+	if err == nil || len(heater.applied) != 1 {
+		t.Errorf("Apply() = %v, applied = %v", err, heater.applied)
+	}
+}
@@ -0,0 +1,235 @@
+package priceplan
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPrices is returned for a price curve that can't be parsed or
+// has overlapping intervals.
+var ErrInvalidPrices = errors.New("priceplan: invalid prices")
+
+// Price is the energy price of one interval.
+type Price struct {
+	// Start and End delimit the interval.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Price is the price per kWh in the feed's currency.
+	Price float64 `json:"price"`
+}
+
+// Curve is a price curve ordered by start time.
+type Curve []Price
+
+// Start returns the start of the first interval.
+func (c Curve) Start() time.Time {
+	if len(c) == 0 {
+		return time.Time{}
+	}
+	return c[0].Start
+}
+
+// End returns the end of the last interval.
+func (c Curve) End() time.Time {
+	if len(c) == 0 {
+		return time.Time{}
+	}
+	return c[len(c)-1].End
+}
+
+// Since returns the intervals ending after ts.
+func (c Curve) Since(ts time.Time) Curve {
+	i := sort.Search(len(c), func(i int) bool { return c[i].End.After(ts) })
+	return c[i:]
+}
+
+// Source provides day-ahead prices, e.g. from a market API.
+type Source interface {
+	// Prices returns the known prices from ts on.
+	Prices(ctx context.Context, ts time.Time) (Curve, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context, ts time.Time) (Curve, error)
+
+// Prices calls f.
+func (f SourceFunc) Prices(ctx context.Context, ts time.Time) (Curve, error) {
+	return f(ctx, ts)
+}
+
+// FileSource reads prices from a JSON or CSV file on every call, so a
+// cron job or script can refresh the file independently.
+func FileSource(path string) Source {
+	return SourceFunc(func(_ context.Context, ts time.Time) (Curve, error) {
+		curve, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return curve.Since(ts), nil
+	})
+}
+
+// LoadFile reads a price curve from a .json or .csv file.
+func LoadFile(path string) (Curve, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".json" && ext != ".csv" {
+		return nil, fmt.Errorf("%w: unsupported file type %q", ErrInvalidPrices, filepath.Ext(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prices: %w", err)
+	}
+	if ext == ".json" {
+		return ParseJSON(data)
+	}
+	return ParseCSV(bytes.NewReader(data))
+}
+
+// jsonPrice accepts RFC 3339 strings or Unix seconds for times.
+type jsonPrice struct {
+	Start json.RawMessage `json:"start"`
+	End   json.RawMessage `json:"end"`
+	Price *float64        `json:"price"`
+}
+
+// ParseJSON parses a price curve from a JSON array of
+// {"start", "end", "price"} objects, or an object holding the array in
+// "prices". Times are RFC 3339 strings or Unix seconds; a missing end is
+// the next interval's start.
+func ParseJSON(data []byte) (Curve, error) {
+	var items []jsonPrice
+	if err := json.Unmarshal(data, &items); err != nil {
+		var wrapped struct {
+			Prices []jsonPrice `json:"prices"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPrices, err)
+		}
+		items = wrapped.Prices
+	}
+
+	curve := make(Curve, 0, len(items))
+	for i, item := range items {
+		if item.Price == nil {
+			return nil, fmt.Errorf("%w: entry %d has no price", ErrInvalidPrices, i)
+		}
+		start, err := jsonTime(item.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d start: %v", ErrInvalidPrices, i, err)
+		}
+		if start.IsZero() {
+			return nil, fmt.Errorf("%w: entry %d has no start", ErrInvalidPrices, i)
+		}
+		end, err := jsonTime(item.End)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d end: %v", ErrInvalidPrices, i, err)
+		}
+		curve = append(curve, Price{Start: start, End: end, Price: *item.Price})
+	}
+	return normalize(curve)
+}
+
+func jsonTime(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return parseTime(s)
+	}
+	var n int64
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(n, 0), nil
+}
+
+// ParseCSV parses a price curve from CSV with a header naming the
+// "start", "price" and optional "end" columns. Times are RFC 3339 or Unix
+// seconds; a missing end is the next interval's start.
+func ParseCSV(r io.Reader) (Curve, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPrices, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	cols := map[string]int{"end": -1}
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	startCol, ok1 := cols["start"]
+	priceCol, ok2 := cols["price"]
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%w: CSV header needs start and price columns", ErrInvalidPrices)
+	}
+
+	curve := make(Curve, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		start, err := parseTime(rec[startCol])
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d start: %v", ErrInvalidPrices, i+1, err)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(rec[priceCol]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d price: %v", ErrInvalidPrices, i+1, err)
+		}
+		p := Price{Start: start, Price: price}
+		if col := cols["end"]; col >= 0 && strings.TrimSpace(rec[col]) != "" {
+			if p.End, err = parseTime(rec[col]); err != nil {
+				return nil, fmt.Errorf("%w: row %d end: %v", ErrInvalidPrices, i+1, err)
+			}
+		}
+		curve = append(curve, p)
+	}
+	return normalize(curve)
+}
+
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// normalize sorts the curve, fills missing ends and rejects overlaps. The
+// last interval without an end gets the length of the one before it, or
+// one hour.
+func normalize(curve Curve) (Curve, error) {
+	sort.SliceStable(curve, func(i, j int) bool { return curve[i].Start.Before(curve[j].Start) })
+	for i := range curve {
+		p := &curve[i]
+		if p.End.IsZero() {
+			switch {
+			case i+1 < len(curve):
+				p.End = curve[i+1].Start
+			case i > 0:
+				p.End = p.Start.Add(curve[i-1].End.Sub(curve[i-1].Start))
+			default:
+				p.End = p.Start.Add(time.Hour)
+			}
+		}
+		if !p.End.After(p.Start) {
+			return nil, fmt.Errorf("%w: interval at %s ends before it starts", ErrInvalidPrices, p.Start.Format(time.RFC3339))
+		}
+		if i > 0 && p.Start.Before(curve[i-1].End) {
+			return nil, fmt.Errorf("%w: interval at %s overlaps the previous one", ErrInvalidPrices, p.Start.Format(time.RFC3339))
+		}
+	}
+	return curve, nil
+}
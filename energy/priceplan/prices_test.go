@@ -0,0 +1,112 @@
+package priceplan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJSON(t *testing.T) {
+	t0 := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Curve
+		wantErr bool
+	}{
+		{
+			name: "array with ends",
+			data: `[{"start":"2025-03-04T01:00:00Z","end":"2025-03-04T02:00:00Z","price":0.2},
+				{"start":"2025-03-04T00:00:00Z","end":"2025-03-04T01:00:00Z","price":0.1}]`,
+			want: Curve{
+				{Start: t0, End: t0.Add(time.Hour), Price: 0.1},
+				{Start: t0.Add(time.Hour), End: t0.Add(2 * time.Hour), Price: 0.2},
+			},
+		},
+		{
+			name: "wrapped, unix times, inferred ends",
+			data: `{"prices":[{"start":1741046400,"price":0.1},{"start":1741047300,"price":-0.02}]}`,
+			want: Curve{
+				{Start: t0, End: t0.Add(15 * time.Minute), Price: 0.1},
+				{Start: t0.Add(15 * time.Minute), End: t0.Add(30 * time.Minute), Price: -0.02},
+			},
+		},
+		{name: "no price", data: `[{"start":"2025-03-04T00:00:00Z"}]`, wantErr: true},
+		{name: "no start", data: `[{"price":1}]`, wantErr: true},
+		{
+			name: "overlap",
+			data: `[{"start":"2025-03-04T00:00:00Z","end":"2025-03-04T02:00:00Z","price":1},
+				{"start":"2025-03-04T01:00:00Z","price":1}]`,
+			wantErr: true,
+		},
+		{name: "not json", data: `prices`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJSON([]byte(tt.data))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPrices) {
+					t.Errorf("ParseJSON() error = %v, want ErrInvalidPrices", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJSON() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseJSON() = %+v", got)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) || got[i].Price != tt.want[i].Price {
+					t.Errorf("[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	curve, err := ParseCSV(strings.NewReader("Start,End,Price\n2025-03-04T00:00:00Z,,0.3\n2025-03-04T01:00:00Z,2025-03-04T01:30:00Z,0.25\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(curve) != 2 || curve[0].End != curve[1].Start || curve[1].End.Sub(curve[1].Start) != 30*time.Minute {
+		t.Errorf("ParseCSV() = %+v", curve)
+	}
+
+	for _, data := range []string{"time,price\n1,1\n", "start,price\nx,1\n", "start,price\n1,x\n"} {
+		if _, err := ParseCSV(strings.NewReader(data)); !errors.Is(err, ErrInvalidPrices) {
+			t.Errorf("ParseCSV(%q) error = %v", data, err)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prices.csv")
+	data := "start,price\n2025-03-04T00:00:00Z,0.3\n2025-03-04T01:00:00Z,0.2\n2025-03-04T02:00:00Z,0.1\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	curve, err := FileSource(path).Prices(context.Background(), time.Date(2025, 3, 4, 1, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The interval containing the time is kept.
+	if len(curve) != 2 || curve[0].Price != 0.2 || !curve.End().Equal(time.Date(2025, 3, 4, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("Prices() = %+v", curve)
+	}
+
+	if _, err := LoadFile(filepath.Join(dir, "prices.txt")); !errors.Is(err, ErrInvalidPrices) {
+		t.Errorf("LoadFile(.txt) error = %v", err)
+	}
+	if _, err := LoadFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadFile(missing) should fail")
+	}
+}
@@ -0,0 +1,259 @@
+package priceplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/factory"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/helpers"
+	"github.com/tj-smith47/shelly-go/types"
+)
+
+// Target installs load plans on a device.
+type Target interface {
+	// Apply replaces the previously applied plan with windows, whose
+	// clock times are in loc. On error the previous plan stays in place.
+	Apply(ctx context.Context, windows []Window, loc *time.Location) error
+}
+
+// Entries compiles windows into on/off schedule entries at local clock
+// times in loc. Starts are rounded down and ends up to the minute, and
+// windows that touch after rounding are merged.
+//
+// Each entry repeats weekly on the weekday of its window, so a plan that
+// is not replaced runs again a week later.
+func Entries(windows []Window, loc *time.Location, channel int) []helpers.ScheduleEntry {
+	var merged []Window
+	for _, w := range windows {
+		w.Start = w.Start.Truncate(time.Minute)
+		if end := w.End.Truncate(time.Minute); end.Before(w.End) {
+			w.End = end.Add(time.Minute)
+		}
+		if n := len(merged); n > 0 && !w.Start.After(merged[n-1].End) {
+			merged[n-1].End = w.End
+			continue
+		}
+		merged = append(merged, w)
+	}
+
+	entries := make([]helpers.ScheduleEntry, 0, 2*len(merged))
+	for _, w := range merged {
+		entries = append(entries, entry(w.Start.In(loc), true, channel), entry(w.End.In(loc), false, channel))
+	}
+	return entries
+}
+
+func entry(ts time.Time, on bool, channel int) helpers.ScheduleEntry {
+	return helpers.ScheduleEntry{
+		Time:    helpers.NewScheduleTime(ts.Hour(), ts.Minute()),
+		Days:    helpers.Weekdays{helpers.WeekdayFromTime(ts.Weekday())},
+		Action:  helpers.ActionSet(on),
+		Channel: channel,
+		Enabled: true,
+	}
+}
+
+// maxScheduleJobs is the number of Schedule jobs a Gen2 device holds.
+const maxScheduleJobs = 20
+
+// Gen2Target installs plans as Schedule jobs switching a Gen2 Switch
+// channel. The IDs of the plan's jobs are kept in the device KVS, so a
+// new plan replaces exactly the jobs of the previous one and leaves other
+// schedules alone, even across planner restarts.
+//
+// When the device has room for both plans, a new plan is installed by
+// creating its jobs, recording their IDs and then deleting the previous
+// jobs. Otherwise the previous jobs are deleted first. Either way, if the
+// install fails the new jobs are removed and the previous plan stays in
+// place, recreated from its job specs if it was already deleted.
+func Gen2Target(dev *factory.Gen2Device, channel int) Target {
+	return &gen2Target{dev: dev, channel: channel}
+}
+
+type gen2Target struct {
+	dev     *factory.Gen2Device
+	channel int
+}
+
+// kvsKey is the KVS key holding the plan's job IDs.
+func (t *gen2Target) kvsKey() string {
+	return fmt.Sprintf("priceplan.switch:%d", t.channel)
+}
+
+func (t *gen2Target) Apply(ctx context.Context, windows []Window, loc *time.Location) error {
+	if t.dev.Device == nil {
+		return types.ErrNilDevice
+	}
+	kvs := components.NewKVS(t.dev.Client())
+	schedule := components.NewSchedule(t.dev.Client())
+
+	previousIDs, err := t.jobIDs(ctx, kvs)
+	if err != nil {
+		return err
+	}
+	list, err := schedule.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list schedules: %w", err)
+	}
+	// Jobs recorded for the plan but already gone from the device are
+	// skipped.
+	var previous []components.ScheduleJob
+	for _, job := range list.Jobs {
+		if slices.Contains(previousIDs, job.ID) {
+			previous = append(previous, job)
+		}
+	}
+
+	entries := Entries(windows, loc, t.channel)
+	if len(list.Jobs)+len(entries) <= maxScheduleJobs {
+		return t.replaceAfter(ctx, kvs, entries, previous)
+	}
+	return t.replaceBefore(ctx, kvs, schedule, entries, previous)
+}
+
+// replaceAfter installs entries, then deletes the previous jobs.
+func (t *gen2Target) replaceAfter(ctx context.Context, kvs *components.KVS,
+	entries []helpers.ScheduleEntry, previous []components.ScheduleJob) error {
+	if err := t.install(ctx, kvs, entries); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, job := range previous {
+		if err := helpers.DeleteSchedule(ctx, t.dev, job.ID); err != nil && !errors.Is(err, types.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replaceBefore deletes the previous jobs to make room, then installs
+// entries. If that fails, the previous jobs are recreated.
+func (t *gen2Target) replaceBefore(ctx context.Context, kvs *components.KVS, schedule *components.Schedule,
+	entries []helpers.ScheduleEntry, previous []components.ScheduleJob) error {
+	var deleted []components.ScheduleJob
+	restore := func(cause error) error {
+		var ids []int
+		errs := []error{cause}
+		for _, job := range deleted {
+			resp, err := schedule.Create(ctx, &components.ScheduleCreateRequest{
+				Timespec: job.Timespec,
+				Calls:    job.Calls,
+				Enable:   job.Enable,
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore previous plan job: %w", err))
+				continue
+			}
+			ids = append(ids, resp.ID)
+		}
+		// Undeleted previous jobs keep their IDs.
+		for _, job := range previous[len(deleted):] {
+			ids = append(ids, job.ID)
+		}
+		if err := t.record(ctx, kvs, ids); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, job := range previous {
+		if err := helpers.DeleteSchedule(ctx, t.dev, job.ID); err != nil && !errors.Is(err, types.ErrNotFound) {
+			return restore(err)
+		}
+		deleted = append(deleted, job)
+	}
+	if err := t.install(ctx, kvs, entries); err != nil {
+		return restore(err)
+	}
+	return nil
+}
+
+// install creates jobs for entries and records their IDs. On error the
+// jobs it created are deleted.
+func (t *gen2Target) install(ctx context.Context, kvs *components.KVS, entries []helpers.ScheduleEntry) error {
+	var created []int
+	rollback := func(cause error) error {
+		for _, id := range created {
+			_ = helpers.DeleteSchedule(ctx, t.dev, id) //nolint:errcheck // Best effort, cause is returned
+		}
+		return cause
+	}
+	for i := range entries {
+		id, err := helpers.CreateSchedule(ctx, t.dev, &entries[i])
+		if err != nil {
+			return rollback(err)
+		}
+		created = append(created, id)
+	}
+	if err := t.record(ctx, kvs, created); err != nil {
+		return rollback(err)
+	}
+	return nil
+}
+
+// record stores the plan's job IDs.
+func (t *gen2Target) record(ctx context.Context, kvs *components.KVS, ids []int) error {
+	fields := make([]string, len(ids))
+	for i, id := range ids {
+		fields[i] = strconv.Itoa(id)
+	}
+	if _, err := kvs.Set(ctx, t.kvsKey(), strings.Join(fields, ",")); err != nil {
+		return fmt.Errorf("failed to record plan jobs: %w", err)
+	}
+	return nil
+}
+
+// jobIDs reads the job IDs of the current plan.
+func (t *gen2Target) jobIDs(ctx context.Context, kvs *components.KVS) ([]int, error) {
+	result, err := kvs.Get(ctx, t.kvsKey())
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan jobs: %w", err)
+	}
+	s, _ := result.Value.(string) //nolint:errcheck // Other values hold no IDs
+	var ids []int
+	for _, field := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Gen1Target installs plans as the schedule rules of a Gen1 relay and
+// enables its schedule. The rules are replaced in one request, so the
+// relay should be dedicated to the plan: its other rules are removed.
+func Gen1Target(dev *factory.Gen1Device, relay int) Target {
+	return &gen1Target{dev: dev, relay: relay}
+}
+
+type gen1Target struct {
+	dev   *factory.Gen1Device
+	relay int
+}
+
+func (t *gen1Target) Apply(ctx context.Context, windows []Window, loc *time.Location) error {
+	if t.dev.Device == nil {
+		return types.ErrNilDevice
+	}
+	entries := Entries(windows, loc, t.relay)
+	rules := make([]string, len(entries))
+	for i := range entries {
+		rules[i] = entries[i].ToGen1Rule()
+	}
+
+	relay := t.dev.Relay(t.relay)
+	if err := relay.SetScheduleRules(ctx, rules); err != nil {
+		return err
+	}
+	return relay.SetSchedule(ctx, len(rules) > 0)
+}
@@ -0,0 +1,317 @@
+package priceplan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/factory"
+	"github.com/tj-smith47/shelly-go/gen1"
+	"github.com/tj-smith47/shelly-go/gen2"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+// jsonrpcError returns a JSON-RPC error response.
+func jsonrpcError(code int, message string) (json.RawMessage, error) {
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"error":   map[string]any{"code": code, "message": message},
+	})
+}
+
+// fakeJob is a Schedule job of fakeScheduler.
+type fakeJob struct {
+	Timespec string           `json:"timespec"`
+	Calls    []map[string]any `json:"calls"`
+	ID       int              `json:"id"`
+	Enable   bool             `json:"enable"`
+}
+
+func (j fakeJob) String() string {
+	if len(j.Calls) == 0 {
+		return j.Timespec
+	}
+	params, _ := j.Calls[0]["params"].(map[string]any)
+	return fmt.Sprintf("%s on=%v id=%v", j.Timespec, params["on"], params["id"])
+}
+
+// fakeScheduler is a Gen2 device with Schedule and KVS state. Like a real
+// device it holds at most maxScheduleJobs jobs.
+type fakeScheduler struct {
+	jobs      map[int]fakeJob
+	kvs       map[string]string
+	failAfter int
+	nextID    int
+	creates   int
+}
+
+func newFakeScheduler() *fakeScheduler {
+	return &fakeScheduler{
+		jobs:      map[int]fakeJob{1: {ID: 1, Timespec: "user job"}},
+		kvs:       map[string]string{},
+		nextID:    10,
+		failAfter: -1,
+	}
+}
+
+func (f *fakeScheduler) device() *factory.Gen2Device {
+	client := rpc.NewClient(&mockTransport{callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+		data, _ := json.Marshal(req.GetParams())
+		var p struct {
+			Value    any              `json:"value"`
+			Key      string           `json:"key"`
+			Timespec string           `json:"timespec"`
+			Calls    []map[string]any `json:"calls"`
+			ID       int              `json:"id"`
+			Enable   bool             `json:"enable"`
+		}
+		_ = json.Unmarshal(data, &p)
+
+		switch req.GetMethod() {
+		case "KVS.Get":
+			v, ok := f.kvs[p.Key]
+			if !ok {
+				return jsonrpcError(-105, "Argument 'key', value '"+p.Key+"' not found!")
+			}
+			return jsonrpcResponse(map[string]any{"value": v, "etag": "x"})
+		case "KVS.Set":
+			f.kvs[p.Key] = p.Value.(string)
+			return jsonrpcResponse(map[string]any{"etag": "x", "rev": 1})
+		case "Schedule.List":
+			jobs := make([]fakeJob, 0, len(f.jobs))
+			for _, j := range f.jobs {
+				jobs = append(jobs, j)
+			}
+			return jsonrpcResponse(map[string]any{"jobs": jobs, "rev": 1})
+		case "Schedule.Create":
+			if f.creates == f.failAfter {
+				f.failAfter = -1 // fail once
+				return jsonrpcError(-1, "internal error")
+			}
+			if len(f.jobs) >= maxScheduleJobs {
+				return jsonrpcError(-108, "too many jobs")
+			}
+			f.creates++
+			f.nextID++
+			f.jobs[f.nextID] = fakeJob{ID: f.nextID, Timespec: p.Timespec, Calls: p.Calls, Enable: p.Enable}
+			return jsonrpcResponse(map[string]any{"id": f.nextID})
+		case "Schedule.Delete":
+			if _, ok := f.jobs[p.ID]; !ok {
+				return jsonrpcError(-105, "not found")
+			}
+			delete(f.jobs, p.ID)
+			return jsonrpcResponse(map[string]any{"rev": 1})
+		}
+		return nil, errors.New("unexpected method " + req.GetMethod())
+	}})
+	return &factory.Gen2Device{Device: gen2.NewDevice(client)}
+}
+
+func (f *fakeScheduler) jobList() []string {
+	var out []string
+	for _, j := range f.jobs {
+		out = append(out, j.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestEntries(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	day := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC) // a Tuesday
+	windows := []Window{
+		{Start: day.Add(22*time.Hour + 30*time.Second), End: day.Add(23*time.Hour + 20*time.Second)},
+		{Start: day.Add(23*time.Hour + 45*time.Second), End: day.Add(25 * time.Hour)},
+	}
+
+	var rules []string
+	for _, e := range Entries(windows, loc, 1) {
+		if e.Channel != 1 || !e.Enabled {
+			t.Errorf("entry = %+v", e)
+		}
+		rules = append(rules, e.ToGen1Rule())
+	}
+	// Rounding joins the windows; local time crosses into Wednesday.
+	want := []string{"2300-2-on", "0200-3-off"}
+	if strings.Join(rules, " ") != strings.Join(want, " ") {
+		t.Errorf("rules = %v, want %v", rules, want)
+	}
+}
+
+func TestGen2Target(t *testing.T) {
+	day := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	fake := newFakeScheduler()
+	target := Gen2Target(fake.device(), 1)
+	ctx := context.Background()
+
+	first := []Window{{Start: day.Add(2 * time.Hour), End: day.Add(3 * time.Hour)}}
+	if err := target.Apply(ctx, first, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0 0 2 * * 2 on=true id=1", "0 0 3 * * 2 on=false id=1", "user job"}
+	if got := fake.jobList(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("jobs = %v, want %v", got, want)
+	}
+	if fake.kvs["priceplan.switch:1"] != "11,12" {
+		t.Errorf("kvs = %v", fake.kvs)
+	}
+
+	// A failing create rolls back and keeps the previous plan.
+	fake.failAfter = fake.creates + 1
+	second := []Window{{Start: day.Add(28 * time.Hour), End: day.Add(29 * time.Hour)}}
+	if err := target.Apply(ctx, second, time.UTC); err == nil {
+		t.Fatal("Apply() should fail")
+	}
+	if got := fake.jobList(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("jobs after rollback = %v", got)
+	}
+
+	// The next plan replaces only the plan's jobs, even if one is gone.
+	fake.failAfter = -1
+	delete(fake.jobs, 12)
+	if err := target.Apply(ctx, second, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"0 0 4 * * 3 on=true id=1", "0 0 5 * * 3 on=false id=1", "user job"}
+	if got := fake.jobList(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("jobs = %v, want %v", got, want)
+	}
+
+	// An empty plan removes the plan's jobs.
+	if err := target.Apply(ctx, nil, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.jobList(); len(got) != 1 || fake.kvs["priceplan.switch:1"] != "" {
+		t.Errorf("jobs = %v, kvs = %v", got, fake.kvs)
+	}
+}
+
+func TestGen2Target_ScheduleLimit(t *testing.T) {
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC) // a Monday
+	fake := newFakeScheduler()
+	target := Gen2Target(fake.device(), 0)
+	ctx := context.Background()
+
+	// Nine windows fill 18 of the 19 free slots, so the second plan must
+	// replace the first in place.
+	plan := func(offset time.Duration) []Window {
+		var windows []Window
+		for i := range 9 {
+			start := day.Add(time.Duration(i)*24*time.Hour/2 + offset)
+			windows = append(windows, Window{Start: start, End: start.Add(time.Hour)})
+		}
+		return windows
+	}
+	if err := target.Apply(ctx, plan(0), time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	first := fake.jobList()
+	if len(first) != 19 {
+		t.Fatalf("jobs = %d, want 19", len(first))
+	}
+
+	if err := target.Apply(ctx, plan(2*time.Hour), time.UTC); err != nil {
+		t.Fatalf("Apply() replan error = %v", err)
+	}
+	second := fake.jobList()
+	if len(second) != 19 || slices.Equal(first, second) {
+		t.Fatalf("jobs after replan = %v", second)
+	}
+
+	// A failed replan restores the previous plan and records its new IDs.
+	fake.failAfter = fake.creates + 3
+	if err := target.Apply(ctx, plan(4*time.Hour), time.UTC); err == nil {
+		t.Fatal("Apply() should fail")
+	}
+	if got := fake.jobList(); !slices.Equal(got, second) {
+		t.Errorf("jobs after failed replan = %v, want %v", got, second)
+	}
+	recorded := strings.Split(fake.kvs["priceplan.switch:0"], ",")
+	if len(recorded) != 18 {
+		t.Fatalf("recorded IDs = %v", recorded)
+	}
+	for _, field := range recorded {
+		id, _ := strconv.Atoi(field)
+		if _, ok := fake.jobs[id]; !ok {
+			t.Errorf("recorded job %d does not exist", id)
+		}
+	}
+
+	// The restored plan is still replaced cleanly.
+	fake.failAfter = -1
+	if err := target.Apply(ctx, nil, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.jobList(); len(got) != 1 {
+		t.Errorf("jobs = %v, want only the user job", got)
+	}
+}
+
+func TestGen1Target(t *testing.T) {
+	var rules []string
+	var schedule string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/settings/relay/0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if v := r.URL.Query().Get("schedule_rules"); v != "" {
+			_ = json.Unmarshal([]byte(v), &rules)
+		}
+		if v := r.URL.Query().Get("schedule"); v != "" {
+			schedule = v
+		}
+		_, _ = w.Write([]byte(`{"ison":false}`))
+	}))
+	defer server.Close()
+
+	dev := &factory.Gen1Device{Device: gen1.NewDevice(transport.NewHTTP(server.URL))}
+	day := time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC) // a Sunday
+	windows := []Window{{Start: day.Add(90 * time.Minute), End: day.Add(4 * time.Hour)}}
+	if err := Gen1Target(dev, 0).Apply(context.Background(), windows, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rules, " ") != "0130-0-on 0400-0-off" || schedule != "true" {
+		t.Errorf("rules = %v, schedule = %q", rules, schedule)
+	}
+}
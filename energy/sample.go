@@ -0,0 +1,344 @@
+package energy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// DefaultPeriod is the interval length in seconds assumed when the data
+// does not state one.
+const DefaultPeriod = 60
+
+// ErrNoKeys is returned when array-form data or CSV comes without the
+// column keys needed to interpret it.
+var ErrNoKeys = errors.New("energy: data has no column keys")
+
+// Phase identifies a measurement channel.
+type Phase string
+
+// Phases reported by EMData. Single-phase meters (EM1Data, Switch, PM1)
+// only report PhaseTotal.
+const (
+	PhaseA     Phase = "a"
+	PhaseB     Phase = "b"
+	PhaseC     Phase = "c"
+	PhaseTotal Phase = "total"
+)
+
+// phases lists the per-phase channels in report order.
+var phases = []Phase{PhaseA, PhaseB, PhaseC}
+
+// Sample is the energy measured on one phase during one interval.
+type Sample struct {
+	// Time is the start of the interval.
+	Time time.Time `json:"ts"`
+
+	// Phase is the measured channel.
+	Phase Phase `json:"phase"`
+
+	// Import is the energy drawn from the grid in watt-hours.
+	Import float64 `json:"import"`
+
+	// Export is the energy returned to the grid in watt-hours.
+	Export float64 `json:"export"`
+
+	// Period is the interval length in seconds.
+	Period int `json:"period"`
+
+	// Filled marks samples synthesized by FillGaps.
+	Filled bool `json:"filled,omitempty"`
+}
+
+// Net returns imported minus exported energy in watt-hours.
+func (s *Sample) Net() float64 {
+	return s.Import - s.Export
+}
+
+// End returns the end of the interval.
+func (s *Sample) End() time.Time {
+	return s.Time.Add(time.Duration(s.Period) * time.Second)
+}
+
+// Page is one GetData response converted to samples.
+type Page struct {
+	// Samples are ordered by time, then phase.
+	Samples []Sample
+
+	// NextTS is the next_record_ts reported by the device, or 0 if all
+	// data up to the requested end was returned.
+	NextTS int64
+}
+
+// rawGetData is the GetData response of EMData and EM1Data. Values are
+// objects in older firmware and arrays matching Keys in newer firmware.
+type rawGetData struct {
+	Keys   []string   `json:"keys"`
+	Data   []rawBlock `json:"data"`
+	NextTS int64      `json:"next_record_ts"`
+}
+
+type rawBlock struct {
+	Values []json.RawMessage `json:"values"`
+	TS     int64             `json:"ts"`
+	Period int               `json:"period"`
+}
+
+// ParseGetData parses an EMData.GetData or EM1Data.GetData result.
+//
+// Both the keyed object form and the array form (values in the order of
+// "keys") are accepted. Per-phase energy comes from the a_/b_/c_ energy
+// keys; when only active power is available, energy is derived from the
+// average power over the period.
+func ParseGetData(data []byte) (*Page, error) {
+	var raw rawGetData
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse GetData result: %w", err)
+	}
+
+	page := &Page{NextTS: raw.NextTS}
+	for _, block := range raw.Data {
+		period := block.Period
+		if period <= 0 {
+			period = DefaultPeriod
+		}
+		for i, v := range block.Values {
+			row, err := parseValues(v, raw.Keys)
+			if err != nil {
+				return nil, fmt.Errorf("block %d value %d: %w", block.TS, i, err)
+			}
+			ts := time.Unix(block.TS+int64(i*period), 0).UTC()
+			page.Samples = append(page.Samples, rowSamples(ts, period, row)...)
+		}
+	}
+	sortSamples(page.Samples)
+	return page, nil
+}
+
+// FromEMData converts a typed EMData.GetData result, such as the output of
+// gen1 components.EMeterHistoryToEMData, to samples.
+func FromEMData(result *components.EMDataGetDataResult) ([]Sample, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	page, err := ParseGetData(data)
+	if err != nil {
+		return nil, err
+	}
+	return page.Samples, nil
+}
+
+// FromEM1Data converts a typed EM1Data.GetData result to samples.
+func FromEM1Data(result *components.EM1DataGetDataResult) ([]Sample, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	page, err := ParseGetData(data)
+	if err != nil {
+		return nil, err
+	}
+	return page.Samples, nil
+}
+
+// parseValues reads one value entry into a key → number map. Non-numeric
+// and null entries are skipped.
+func parseValues(v json.RawMessage, keys []string) (map[string]float64, error) {
+	row := make(map[string]float64)
+	trimmed := strings.TrimSpace(string(v))
+	if strings.HasPrefix(trimmed, "[") {
+		if len(keys) == 0 {
+			return nil, ErrNoKeys
+		}
+		var values []*float64
+		if err := json.Unmarshal(v, &values); err != nil {
+			return nil, err
+		}
+		for i, f := range values {
+			if f != nil && i < len(keys) {
+				row[keys[i]] = *f
+			}
+		}
+		return row, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(v, &fields); err != nil {
+		return nil, err
+	}
+	for k, raw := range fields {
+		var f float64
+		if json.Unmarshal(raw, &f) == nil {
+			row[k] = f
+		}
+	}
+	return row, nil
+}
+
+// rowSamples converts one interval's keyed values to per-phase samples.
+func rowSamples(ts time.Time, period int, row map[string]float64) []Sample {
+	var samples []Sample
+	var sumImport, sumExport float64
+	for _, p := range phases {
+		prefix := string(p) + "_"
+		imp, exp, ok := rowEnergy(row, period,
+			[]string{prefix + "total_act_energy", prefix + "act_energy"},
+			[]string{prefix + "total_act_ret_energy", prefix + "act_ret_energy"},
+			[]string{prefix + "act_power", prefix + "avg_act_power"})
+		if !ok {
+			continue
+		}
+		sumImport += imp
+		sumExport += exp
+		samples = append(samples, Sample{Time: ts, Phase: p, Import: imp, Export: exp, Period: period})
+	}
+
+	imp, exp, ok := rowEnergy(row, period,
+		[]string{"total_act_energy", "act_energy"},
+		[]string{"total_act_ret_energy", "act_ret_energy"},
+		[]string{"total_act_power", "act_power"})
+	if !ok && len(samples) > 0 {
+		imp, exp, ok = sumImport, sumExport, true
+	}
+	if ok {
+		samples = append(samples, Sample{Time: ts, Phase: PhaseTotal, Import: imp, Export: exp, Period: period})
+	}
+	return samples
+}
+
+// rowEnergy returns the first present import/export energy keys, falling
+// back to energy derived from average power.
+func rowEnergy(row map[string]float64, period int, importKeys, exportKeys, powerKeys []string) (imp, exp float64, ok bool) {
+	imp, hasImport := firstKey(row, importKeys)
+	exp, hasExport := firstKey(row, exportKeys)
+	if hasImport || hasExport {
+		return imp, exp, true
+	}
+	power, hasPower := firstKey(row, powerKeys)
+	if !hasPower {
+		return 0, 0, false
+	}
+	wh := power * float64(period) / 3600
+	if wh < 0 {
+		return 0, -wh, true
+	}
+	return wh, 0, true
+}
+
+func firstKey(row map[string]float64, keys []string) (float64, bool) {
+	for _, k := range keys {
+		if v, ok := row[k]; ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// ParseCSV parses the data.csv download of EMData or EM1Data (see
+// GetDataCSVURL). The file must include the key header (add_keys=true);
+// the first column is the Unix timestamp of each interval.
+//
+// The period is taken from the smallest step between rows, or
+// DefaultPeriod for single-row files.
+func ParseCSV(r io.Reader) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	if len(header) < 2 || isNumber(header[0]) {
+		return nil, ErrNoKeys
+	}
+
+	type csvRow struct {
+		values map[string]float64
+		ts     int64
+	}
+	parsed := make([]csvRow, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		ts, err := strconv.ParseInt(strings.TrimSpace(row[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid timestamp %q", i+1, row[0])
+		}
+		values := make(map[string]float64, len(header)-1)
+		for col := 1; col < len(row) && col < len(header); col++ {
+			s := strings.TrimSpace(row[col])
+			if s == "" {
+				continue
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid value %q for %s", i+1, s, header[col])
+			}
+			values[strings.TrimSpace(header[col])] = f
+		}
+		parsed = append(parsed, csvRow{ts: ts, values: values})
+	}
+
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].ts < parsed[j].ts })
+	period := int64(0)
+	for i := 1; i < len(parsed); i++ {
+		if step := parsed[i].ts - parsed[i-1].ts; step > 0 && (period == 0 || step < period) {
+			period = step
+		}
+	}
+	if period == 0 {
+		period = DefaultPeriod
+	}
+
+	var samples []Sample
+	for _, row := range parsed {
+		samples = append(samples, rowSamples(time.Unix(row.ts, 0).UTC(), int(period), row.values)...)
+	}
+	sortSamples(samples)
+	return samples, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
+
+// phaseOrder sorts phases a, b, c, total.
+func phaseOrder(p Phase) int {
+	switch p {
+	case PhaseA:
+		return 0
+	case PhaseB:
+		return 1
+	case PhaseC:
+		return 2
+	case PhaseTotal:
+		return 3
+	}
+	return 4
+}
+
+// sortSamples orders samples by time, then phase.
+func sortSamples(samples []Sample) {
+	sort.SliceStable(samples, func(i, j int) bool {
+		if !samples[i].Time.Equal(samples[j].Time) {
+			return samples[i].Time.Before(samples[j].Time)
+		}
+		return phaseOrder(samples[i].Phase) < phaseOrder(samples[j].Phase)
+	})
+}
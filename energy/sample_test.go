@@ -0,0 +1,155 @@
+package energy
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	gen1components "github.com/tj-smith47/shelly-go/gen1/components"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func find(t *testing.T, samples []Sample, ts int64, phase Phase) Sample {
+	t.Helper()
+	for _, s := range samples {
+		if s.Time.Unix() == ts && s.Phase == phase {
+			return s
+		}
+	}
+	t.Fatalf("no %s sample at %d in %+v", phase, ts, samples)
+	return Sample{}
+}
+
+func TestParseGetData(t *testing.T) {
+	tests := []struct {
+		check func(t *testing.T, page *Page)
+		name  string
+		data  string
+		want  int
+	}{
+		{
+			name: "array form",
+			data: `{"keys":["a_total_act_energy","a_total_act_ret_energy","b_total_act_energy","b_total_act_ret_energy","c_total_act_energy","c_total_act_ret_energy"],
+				"data":[{"ts":1700000000,"period":60,"values":[[1.5,0,2,0,0,0.5],[1,0,null,0,0,0]]}],
+				"next_record_ts":1700000120}`,
+			want: 8,
+			check: func(t *testing.T, page *Page) {
+				if page.NextTS != 1700000120 {
+					t.Errorf("NextTS = %d", page.NextTS)
+				}
+				total := find(t, page.Samples, 1700000000, PhaseTotal)
+				if !approx(total.Import, 3.5) || !approx(total.Export, 0.5) || !approx(total.Net(), 3) {
+					t.Errorf("total = %+v", total)
+				}
+				c := find(t, page.Samples, 1700000000, PhaseC)
+				if c.Export != 0.5 || c.Period != 60 {
+					t.Errorf("phase c = %+v", c)
+				}
+				// null values are skipped, export alone is enough for a sample.
+				b := find(t, page.Samples, 1700000060, PhaseB)
+				if b.Import != 0 {
+					t.Errorf("phase b = %+v", b)
+				}
+			},
+		},
+		{
+			name: "object form with power only",
+			data: `{"data":[{"ts":1700000000,"period":60,"values":[
+				{"a_act_power":600,"b_act_power":-120,"total_act_power":480}]}]}`,
+			want: 3,
+			check: func(t *testing.T, page *Page) {
+				a := find(t, page.Samples, 1700000000, PhaseA)
+				if !approx(a.Import, 10) || a.Export != 0 {
+					t.Errorf("phase a = %+v", a)
+				}
+				b := find(t, page.Samples, 1700000000, PhaseB)
+				if !approx(b.Export, 2) || b.Import != 0 {
+					t.Errorf("phase b = %+v", b)
+				}
+				if got := find(t, page.Samples, 1700000000, PhaseTotal); !approx(got.Import, 8) {
+					t.Errorf("total = %+v", got)
+				}
+			},
+		},
+		{
+			name: "em1data",
+			data: `{"data":[{"ts":1700000000,"period":300,"values":[{"act_energy":12.5,"act_ret_energy":0,"act_power":150}]}]}`,
+			want: 1,
+			check: func(t *testing.T, page *Page) {
+				s := page.Samples[0]
+				if s.Phase != PhaseTotal || s.Import != 12.5 || s.Period != 300 {
+					t.Errorf("sample = %+v", s)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ParseGetData([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseGetData() error = %v", err)
+			}
+			if len(page.Samples) != tt.want {
+				t.Fatalf("got %d samples, want %d: %+v", len(page.Samples), tt.want, page.Samples)
+			}
+			tt.check(t, page)
+		})
+	}
+
+	if _, err := ParseGetData([]byte(`{"data":[{"ts":1,"values":[[1,2]]}]}`)); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("array without keys error = %v, want ErrNoKeys", err)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	data := `timestamp,a_total_act_energy,a_total_act_ret_energy,total_act_energy,total_act_ret_energy
+1700000120,1,0,1,0
+1700000000,2,0.5,2,0.5
+1700000060,,,,
+`
+	samples, err := ParseCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if len(samples) != 4 {
+		t.Fatalf("got %d samples, want 4: %+v", len(samples), samples)
+	}
+	first := samples[0]
+	if first.Time.Unix() != 1700000000 || first.Phase != PhaseA || first.Export != 0.5 || first.Period != 60 {
+		t.Errorf("first = %+v", first)
+	}
+
+	if _, err := ParseCSV(strings.NewReader("1700000000,1,0\n")); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("headerless error = %v, want ErrNoKeys", err)
+	}
+	if _, err := ParseCSV(strings.NewReader("timestamp,a_total_act_energy\nnope,1\n")); err == nil {
+		t.Error("expected error for invalid timestamp")
+	}
+}
+
+func TestFromEMData(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := gen1components.EMeterHistoryToEMData([]gen1components.EMeterCSVRecord{
+		{Time: start, ActiveEnergy: 10, ReturnedEnergy: 0},
+		{Time: start.Add(time.Minute), ActiveEnergy: 0, ReturnedEnergy: 4},
+	})
+
+	samples, err := FromEMData(history)
+	if err != nil {
+		t.Fatalf("FromEMData() error = %v", err)
+	}
+	total := find(t, samples, start.Unix(), PhaseTotal)
+	if total.Import != 10 {
+		t.Errorf("total = %+v", total)
+	}
+	total = find(t, samples, start.Unix()+60, PhaseTotal)
+	if total.Export != 4 {
+		t.Errorf("total = %+v", total)
+	}
+}
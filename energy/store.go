@@ -0,0 +1,284 @@
+package energy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store persists samples and sync cursors per series.
+//
+// A series is one meter channel of one device, e.g. "shellypro3em-abc/emdata:0"
+// (see SeriesKey). Appending a sample for an interval and phase that is
+// already stored replaces it, so re-syncing an overlapping range is safe.
+type Store interface {
+	// Append stores samples for a series.
+	Append(series string, samples []Sample) error
+
+	// Samples returns the stored samples of a series that start in
+	// [from, to), ordered by time and phase. A zero from or to is unbounded.
+	Samples(series string, from, to time.Time) ([]Sample, error)
+
+	// Cursor returns the end of the last synced interval, or the zero time
+	// if the series has never been synced.
+	Cursor(series string) (time.Time, error)
+
+	// SetCursor records the end of the last synced interval.
+	SetCursor(series string, t time.Time) error
+}
+
+// SeriesKey returns the store key for a component of a device.
+func SeriesKey(deviceID, component string) string {
+	return deviceID + "/" + component
+}
+
+// sampleKey identifies a sample within a series.
+type sampleKey struct {
+	phase Phase
+	ts    int64
+}
+
+// MemoryStore is an in-memory Store.
+type MemoryStore struct {
+	series  map[string]map[sampleKey]Sample
+	cursors map[string]time.Time
+	mu      sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		series:  make(map[string]map[sampleKey]Sample),
+		cursors: make(map[string]time.Time),
+	}
+}
+
+// Append implements Store.
+func (m *MemoryStore) Append(series string, samples []Sample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appendLocked(series, samples)
+	return nil
+}
+
+func (m *MemoryStore) appendLocked(series string, samples []Sample) {
+	stored, ok := m.series[series]
+	if !ok {
+		stored = make(map[sampleKey]Sample)
+		m.series[series] = stored
+	}
+	for _, s := range samples {
+		stored[sampleKey{phase: s.Phase, ts: s.Time.Unix()}] = s
+	}
+}
+
+// Samples implements Store.
+func (m *MemoryStore) Samples(series string, from, to time.Time) ([]Sample, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []Sample
+	for _, s := range m.series[series] {
+		if !from.IsZero() && s.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !s.Time.Before(to) {
+			continue
+		}
+		out = append(out, s)
+	}
+	sortSamples(out)
+	return out, nil
+}
+
+// Cursor implements Store.
+func (m *MemoryStore) Cursor(series string) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cursors[series], nil
+}
+
+// SetCursor implements Store.
+func (m *MemoryStore) SetCursor(series string, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cursors[series] = t
+	return nil
+}
+
+// FileStore is a Store that keeps each series in a directory as an
+// append-only JSON lines file plus a cursor file.
+//
+// Samples are appended before the cursor is advanced, so an interrupted
+// sync at worst re-downloads one page; duplicates are resolved on load. A
+// partially written last line is ignored.
+type FileStore struct {
+	mem    *MemoryStore
+	loaded map[string]bool
+	torn   map[string]bool
+	dir    string
+	mu     sync.Mutex
+}
+
+// NewFileStore creates a file store in dir, creating the directory if
+// needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &FileStore{
+		mem:    NewMemoryStore(),
+		loaded: make(map[string]bool),
+		torn:   make(map[string]bool),
+		dir:    dir,
+	}, nil
+}
+
+// fileName maps a series key to a safe file name.
+func fileName(series string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, series)
+}
+
+func (f *FileStore) samplesPath(series string) string {
+	return filepath.Join(f.dir, fileName(series)+".jsonl")
+}
+
+func (f *FileStore) cursorPath(series string) string {
+	return filepath.Join(f.dir, fileName(series)+".cursor")
+}
+
+// load reads a series from disk once.
+func (f *FileStore) load(series string) error {
+	if f.loaded[series] {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.samplesPath(series))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read series: %w", err)
+	}
+	var samples []Sample
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var s Sample
+		if json.Unmarshal(line, &s) == nil {
+			samples = append(samples, s)
+		}
+	}
+	f.mem.mu.Lock()
+	f.mem.appendLocked(series, samples)
+	f.mem.mu.Unlock()
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		f.torn[series] = true
+	}
+
+	data, err = os.ReadFile(f.cursorPath(series))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read cursor: %w", err)
+	default:
+		var t time.Time
+		if err := t.UnmarshalText([]byte(strings.TrimSpace(string(data)))); err != nil {
+			return fmt.Errorf("failed to parse cursor: %w", err)
+		}
+		_ = f.mem.SetCursor(series, t) //nolint:errcheck // MemoryStore never fails
+	}
+
+	f.loaded[series] = true
+	return nil
+}
+
+// Append implements Store.
+func (f *FileStore) Append(series string, samples []Sample) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(series); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.samplesPath(series), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open series: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	if f.torn[series] {
+		// Terminate a line cut short by an interrupted write.
+		w.WriteByte('\n') //nolint:errcheck // Checked by Flush
+	}
+	for i := range samples {
+		line, err := json.Marshal(&samples[i])
+		if err != nil {
+			file.Close()
+			return err
+		}
+		w.Write(line)     //nolint:errcheck // Checked by Flush
+		w.WriteByte('\n') //nolint:errcheck // Checked by Flush
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write series: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write series: %w", err)
+	}
+	f.torn[series] = false
+
+	return f.mem.Append(series, samples)
+}
+
+// Samples implements Store.
+func (f *FileStore) Samples(series string, from, to time.Time) ([]Sample, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(series); err != nil {
+		return nil, err
+	}
+	return f.mem.Samples(series, from, to)
+}
+
+// Cursor implements Store.
+func (f *FileStore) Cursor(series string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(series); err != nil {
+		return time.Time{}, err
+	}
+	return f.mem.Cursor(series)
+}
+
+// SetCursor implements Store. The cursor file is replaced atomically.
+func (f *FileStore) SetCursor(series string, t time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(series); err != nil {
+		return err
+	}
+
+	text, err := t.MarshalText()
+	if err != nil {
+		return err
+	}
+	path := f.cursorPath(series)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(text, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	return f.mem.SetCursor(series, t)
+}
@@ -0,0 +1,192 @@
+package energy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+// fakeMeter serves minutes [first, last) of EM1Data history, at most
+// pageSize values per GetData call.
+type fakeMeter struct {
+	failAt   int
+	requests []int64
+	first    int64
+	last     int64
+	pageSize int
+}
+
+func (m *fakeMeter) client() *rpc.Client {
+	return rpc.NewClient(&mockTransport{callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+		var p struct {
+			TS *int64 `json:"ts"`
+		}
+		_ = json.Unmarshal(req.GetParams(), &p)
+		from := m.first
+		if p.TS != nil && *p.TS > from {
+			from = *p.TS
+		}
+		m.requests = append(m.requests, from)
+		if m.failAt > 0 && len(m.requests) == m.failAt {
+			return nil, errors.New("connection reset")
+		}
+
+		var values [][]float64
+		ts := from
+		for ; ts < m.last && len(values) < m.pageSize; ts += 60 {
+			values = append(values, []float64{float64(ts-m.first)/60 + 1, 0})
+		}
+		result := map[string]any{
+			"keys": []string{"total_act_energy", "total_act_ret_energy"},
+			"data": []any{},
+		}
+		if len(values) > 0 {
+			result["data"] = []any{map[string]any{"ts": from, "period": 60, "values": values}}
+		}
+		if ts < m.last {
+			result["next_record_ts"] = ts
+		}
+		return jsonrpcResponse(result)
+	}})
+}
+
+func TestSyncer_SyncResume(t *testing.T) {
+	const first = 1700000040
+	meter := &fakeMeter{first: first, last: first + 10*60, pageSize: 4, failAt: 2}
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncer := NewSyncer(store)
+	em := components.NewEM1Data(meter.client(), 0)
+	series := SeriesKey("shellypmmini", "em1data:0")
+
+	// The second page fails: the first page stays stored.
+	result, err := syncer.SyncEM1Data(context.Background(), series, em)
+	if err == nil {
+		t.Fatal("expected error from interrupted sync")
+	}
+	if result.Samples != 4 || result.Cursor.Unix() != first+4*60 {
+		t.Errorf("interrupted result = %+v", result)
+	}
+
+	// Resume continues at the cursor.
+	meter.failAt = 0
+	result, err = syncer.SyncEM1Data(context.Background(), series, em)
+	if err != nil {
+		t.Fatalf("SyncEM1Data() error = %v", err)
+	}
+	if result.Samples != 6 || result.Pages != 2 || result.From.Unix() != first+4*60 {
+		t.Errorf("resumed result = %+v", result)
+	}
+
+	// Nothing new: one call, nothing stored.
+	result, err = syncer.SyncEM1Data(context.Background(), series, em)
+	if err != nil || result.Samples != 0 || result.Pages != 1 {
+		t.Errorf("up-to-date result = %+v, %v", result, err)
+	}
+
+	samples, err := store.Samples(series, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 10 {
+		t.Fatalf("stored %d samples, want 10", len(samples))
+	}
+	for i, s := range samples {
+		if s.Import != float64(i+1) || s.Time.Unix() != first+int64(i*60) {
+			t.Errorf("sample %d = %+v", i, s)
+		}
+	}
+}
+
+func TestFileStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0).UTC()
+	series := "dev/emdata:0"
+	samples := []Sample{
+		{Time: start, Phase: PhaseA, Import: 1, Period: 60},
+		{Time: start, Phase: PhaseA, Import: 2, Period: 60},
+		{Time: start.Add(time.Minute), Phase: PhaseA, Import: 3, Period: 60},
+	}
+	if err := store.Append(series, samples); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetCursor(series, start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a write cut short by a crash.
+	path := filepath.Join(dir, "dev_emdata_0.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"ts":"2023-11-14T22:15:00Z","pha`)
+	f.Close()
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := reopened.Cursor(series)
+	if err != nil || !cursor.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Cursor() = %v, %v", cursor, err)
+	}
+	if err := reopened.Append(series, []Sample{{Time: start.Add(2 * time.Minute), Phase: PhaseA, Import: 4, Period: 60}}); err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := NewFileStore(dir)
+	got, err := again.Samples(series, start, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Import != 2 || got[2].Import != 4 {
+		t.Errorf("Samples() = %+v", got)
+	}
+	if got, _ := again.Samples(series, start.Add(time.Minute), start.Add(2*time.Minute)); len(got) != 1 {
+		t.Errorf("ranged Samples() = %+v", got)
+	}
+}
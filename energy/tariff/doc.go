@@ -0,0 +1,43 @@
+// Package tariff prices energy with electricity tariffs.
+//
+// A Tariff has an import schedule and an optional export (feed-in)
+// schedule. Schedules are flat, time-of-use with weekday and holiday
+// rules, or tiered by consumption per billing period. Tariffs are defined
+// in JSON or YAML:
+//
+//	name: Residential TOU
+//	currency: EUR
+//	timezone: Europe/Berlin
+//	holidays: ["2025-12-25", "2025-12-26"]
+//	standing_charge: 0.45
+//	import:
+//	  type: tou
+//	  rate: 0.28            # outside all periods
+//	  periods:
+//	    - {name: peak, days: [weekdays], from: "07:00", to: "21:00", rate: 0.38}
+//	    - {name: holiday, days: [holiday], from: "00:00", to: "00:00", rate: 0.22}
+//	export:
+//	  rate: 0.08
+//
+// # Readings
+//
+// Energy comes in as energy.Sample intervals, either from an energy.Store
+// or from running counters. Reading snapshots are taken from Switch, PM1,
+// EMData and EM1Data statuses (FromSwitch, FromPM1, FromEMData,
+// FromEM1Data) and Gen1 emeters (FromEMeters); FromReadings turns
+// successive snapshots into samples and tolerates counter resets.
+//
+// # Reports
+//
+//	t, err := tariff.LoadFile("tariff.yaml")
+//	report, err := t.Report([]tariff.Meter{
+//	    {Name: "heat pump", Circuit: "hvac", Samples: heatPump},
+//	    {Name: "boiler", Circuit: "hvac", Samples: boiler},
+//	    {Name: "kitchen", Samples: kitchen},
+//	}, tariff.ReportOptions{From: start, To: end})
+//
+// Reports have a line per device, a line per circuit and a total with
+// standing charges. For solar installs, Solar balances a grid meter
+// against a production meter and reports self-consumption, autarky and
+// net import/export.
+package tariff
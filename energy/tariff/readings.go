@@ -0,0 +1,152 @@
+package tariff
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	gen1components "github.com/tj-smith47/shelly-go/gen1/components"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// Reading is a snapshot of running energy counters.
+type Reading struct {
+	// Time is when the counters were read.
+	Time time.Time
+
+	// Phase is the measured channel.
+	Phase energy.Phase
+
+	// Import and Export are the running totals in watt-hours.
+	Import float64
+	Export float64
+}
+
+// FromSwitch reads the counters of a Switch status.
+func FromSwitch(ts time.Time, status *components.SwitchStatus) []Reading {
+	if status.AEnergy == nil {
+		return nil
+	}
+	return []Reading{{Time: ts, Phase: energy.PhaseTotal, Import: status.AEnergy.Total}}
+}
+
+// FromPM1 reads the counters of a PM1 status.
+func FromPM1(ts time.Time, status *components.PM1Status) []Reading {
+	if status.AEnergy == nil {
+		return nil
+	}
+	r := Reading{Time: ts, Phase: energy.PhaseTotal, Import: status.AEnergy.Total}
+	if status.RetAEnergy != nil {
+		r.Export = status.RetAEnergy.Total
+	}
+	return []Reading{r}
+}
+
+// FromEMData reads the per-phase and total counters of the EMData status
+// that accompanies an EM component.
+func FromEMData(ts time.Time, status *components.EMDataStatus) []Reading {
+	var readings []Reading
+	add := func(phase energy.Phase, imp, exp *float64) {
+		if imp == nil && exp == nil {
+			return
+		}
+		r := Reading{Time: ts, Phase: phase}
+		if imp != nil {
+			r.Import = *imp
+		}
+		if exp != nil {
+			r.Export = *exp
+		}
+		readings = append(readings, r)
+	}
+	add(energy.PhaseA, status.ATotalActEnergy, status.ATotalActRetEnergy)
+	add(energy.PhaseB, status.BTotalActEnergy, status.BTotalActRetEnergy)
+	add(energy.PhaseC, status.CTotalActEnergy, status.CTotalActRetEnergy)
+	add(energy.PhaseTotal, status.TotalAct, status.TotalActRet)
+	return readings
+}
+
+// FromEM1Data reads the counters of the EM1Data status that accompanies
+// an EM1 component.
+func FromEM1Data(ts time.Time, status *components.EM1DataStatus) []Reading {
+	if status.TotalActEnergy == nil && status.TotalActRetEnergy == nil {
+		return nil
+	}
+	r := Reading{Time: ts, Phase: energy.PhaseTotal}
+	if status.TotalActEnergy != nil {
+		r.Import = *status.TotalActEnergy
+	}
+	if status.TotalActRetEnergy != nil {
+		r.Export = *status.TotalActRetEnergy
+	}
+	return []Reading{r}
+}
+
+// FromEMeters reads the totals of Gen1 emeters. The statuses map to phases
+// A, B and C in order (a Shelly EM's channels, or a 3EM's phases); a total
+// reading is added for more than one emeter.
+func FromEMeters(ts time.Time, statuses ...gen1components.EMeterStatus) []Reading {
+	phases := []energy.Phase{energy.PhaseA, energy.PhaseB, energy.PhaseC}
+	if len(statuses) == 1 {
+		return []Reading{{Time: ts, Phase: energy.PhaseTotal, Import: statuses[0].Total, Export: statuses[0].TotalReturned}}
+	}
+
+	var readings []Reading
+	total := Reading{Time: ts, Phase: energy.PhaseTotal}
+	for i, s := range statuses {
+		if i >= len(phases) {
+			break
+		}
+		readings = append(readings, Reading{Time: ts, Phase: phases[i], Import: s.Total, Export: s.TotalReturned})
+		total.Import += s.Total
+		total.Export += s.TotalReturned
+	}
+	if len(readings) > 0 {
+		readings = append(readings, total)
+	}
+	return readings
+}
+
+// FromReadings converts successive counter readings into interval
+// samples, one per pair of consecutive readings of a phase. A counter
+// that went backwards was reset; its new value is counted as the energy
+// since the reset.
+func FromReadings(readings []Reading) []energy.Sample {
+	byPhase := make(map[energy.Phase][]Reading)
+	for _, r := range readings {
+		byPhase[r.Phase] = append(byPhase[r.Phase], r)
+	}
+
+	var samples []energy.Sample
+	for phase, list := range byPhase {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+		for i := 1; i < len(list); i++ {
+			prev, cur := list[i-1], list[i]
+			period := int(cur.Time.Sub(prev.Time) / time.Second)
+			if period <= 0 {
+				continue
+			}
+			samples = append(samples, energy.Sample{
+				Time:   prev.Time,
+				Phase:  phase,
+				Import: counterDelta(prev.Import, cur.Import),
+				Export: counterDelta(prev.Export, cur.Export),
+				Period: period,
+			})
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		if !samples[i].Time.Equal(samples[j].Time) {
+			return samples[i].Time.Before(samples[j].Time)
+		}
+		return samples[i].Phase < samples[j].Phase
+	})
+	return samples
+}
+
+func counterDelta(prev, cur float64) float64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
@@ -0,0 +1,349 @@
+package tariff
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+)
+
+// Usage is the energy and cost of one period or tier.
+type Usage struct {
+	// Name is the period or tier name.
+	Name string `json:"name"`
+
+	// Energy is in kWh.
+	Energy float64 `json:"energy_kwh"`
+
+	// Cost is the import cost.
+	Cost float64 `json:"cost"`
+}
+
+// Line is the energy and cost of one device or circuit.
+type Line struct {
+	// Name is the device or circuit name.
+	Name string `json:"name"`
+
+	// Periods breaks down import by time-of-use period or tier.
+	Periods []Usage `json:"periods,omitempty"`
+
+	// Import and Export are in kWh; Net is Import minus Export.
+	Import float64 `json:"import_kwh"`
+	Export float64 `json:"export_kwh"`
+	Net    float64 `json:"net_kwh"`
+
+	// ImportCost is the charge for imported energy.
+	ImportCost float64 `json:"import_cost"`
+
+	// ExportCredit is the feed-in credit for exported energy.
+	ExportCredit float64 `json:"export_credit"`
+
+	// Cost is ImportCost minus ExportCredit, plus standing charges on the
+	// report total.
+	Cost float64 `json:"cost"`
+}
+
+// Meter is the measured energy of one device channel.
+type Meter struct {
+	// Name identifies the device in reports.
+	Name string
+
+	// Circuit groups meters into circuit lines; empty for none.
+	Circuit string
+
+	// Samples are the measured intervals, e.g. from an energy.Store or
+	// FromReadings.
+	Samples []energy.Sample
+}
+
+// ReportOptions selects the reported range and netting.
+type ReportOptions struct {
+	// From and To limit samples to those starting in [From, To). Zero
+	// values are unbounded; the range then follows the samples.
+	From time.Time
+	To   time.Time
+
+	// NetPhases nets import and export across phases per interval, as
+	// phase-balancing (3EM "net") meters bill. Otherwise total-phase
+	// samples are used when present, and per-phase samples are summed
+	// without netting.
+	NetPhases bool
+}
+
+// Report is a cost report for a set of meters.
+type Report struct {
+	// From and To is the reported range.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// Tariff and Currency describe the applied tariff.
+	Tariff   string `json:"tariff"`
+	Currency string `json:"currency,omitempty"`
+
+	// Devices has one line per meter.
+	Devices []Line `json:"devices"`
+
+	// Circuits has one line per circuit, priced from the merged samples of
+	// its meters.
+	Circuits []Line `json:"circuits,omitempty"`
+
+	// Total sums the device lines and adds standing charges.
+	Total Line `json:"total"`
+
+	// StandingCharge is the fixed charge for Days calendar days.
+	StandingCharge float64 `json:"standing_charge,omitempty"`
+	Days           int     `json:"days"`
+}
+
+// Report prices meters with the tariff.
+//
+// Tiered schedules count consumption per line: each device and circuit
+// starts its billing period in the first tier.
+func (t *Tariff) Report(meters []Meter, opts ReportOptions) (*Report, error) {
+	if err := t.ensure(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{Tariff: t.Name, Currency: t.Currency, From: opts.From, To: opts.To}
+	circuits := make(map[string][]energy.Sample)
+	var circuitOrder []string
+	for _, m := range meters {
+		samples := selectSamples(m.Samples, &opts)
+		updateRange(report, samples)
+		report.Devices = append(report.Devices, t.price(m.Name, samples))
+
+		if m.Circuit == "" {
+			continue
+		}
+		if _, ok := circuits[m.Circuit]; !ok {
+			circuitOrder = append(circuitOrder, m.Circuit)
+		}
+		circuits[m.Circuit] = append(circuits[m.Circuit], samples...)
+	}
+	for _, name := range circuitOrder {
+		samples := circuits[name]
+		sortByTime(samples)
+		report.Circuits = append(report.Circuits, t.price(name, samples))
+	}
+
+	report.Total = sumLines("total", report.Devices)
+	if t.StandingCharge != 0 && !report.From.IsZero() && report.To.After(report.From) {
+		report.Days = calendarDays(report.From, report.To, t.Location())
+		report.StandingCharge = float64(report.Days) * t.StandingCharge
+		report.Total.Cost += report.StandingCharge
+	}
+	return report, nil
+}
+
+// Cost prices a single sample series.
+func (t *Tariff) Cost(name string, samples []energy.Sample) (Line, error) {
+	if err := t.ensure(); err != nil {
+		return Line{}, err
+	}
+	samples = append([]energy.Sample(nil), samples...)
+	sortByTime(samples)
+	return t.price(name, samples), nil
+}
+
+// price computes a line from time-ordered samples.
+func (t *Tariff) price(name string, samples []energy.Sample) Line {
+	line := Line{Name: name}
+	periods := make(map[string]*Usage)
+	var order []string
+	usage := func(n string) *Usage {
+		u, ok := periods[n]
+		if !ok {
+			u = &Usage{Name: n}
+			periods[n] = u
+			order = append(order, n)
+		}
+		return u
+	}
+
+	tiered := make(map[int64]float64)
+	loc := t.Location()
+	for i := range samples {
+		s := &samples[i]
+		local := s.Time.In(loc)
+		holiday := t.IsHoliday(s.Time)
+
+		if imp := s.Import / 1000; imp > 0 {
+			line.Import += imp
+			if t.Import.Kind == Tiered {
+				start := t.Import.billingStart(local).Unix()
+				tiered[start] = priceTiers(t.Import.Tiers, tiered[start], imp, func(n string, kwh, rate float64) {
+					u := usage(n)
+					u.Energy += kwh
+					u.Cost += kwh * rate
+					line.ImportCost += kwh * rate
+				})
+			} else {
+				n, rate := t.Import.at(local, holiday)
+				u := usage(n)
+				u.Energy += imp
+				u.Cost += imp * rate
+				line.ImportCost += imp * rate
+			}
+		}
+
+		if exp := s.Export / 1000; exp > 0 {
+			line.Export += exp
+			if t.Export != nil {
+				_, rate := t.Export.at(local, holiday)
+				line.ExportCredit += exp * rate
+			}
+		}
+	}
+
+	for _, n := range order {
+		line.Periods = append(line.Periods, *periods[n])
+	}
+	line.Net = line.Import - line.Export
+	line.Cost = line.ImportCost - line.ExportCredit
+	return line
+}
+
+// priceTiers charges kwh on top of used kWh and returns the new usage.
+func priceTiers(tiers []Tier, used, kwh float64, charge func(name string, kwh, rate float64)) float64 {
+	for i, tier := range tiers {
+		if kwh <= 0 {
+			break
+		}
+		if tier.UpTo != 0 && used >= tier.UpTo {
+			continue
+		}
+		part := kwh
+		if tier.UpTo != 0 {
+			part = min(kwh, tier.UpTo-used)
+		}
+		charge(tierName(tiers, i), part, tier.Rate)
+		used += part
+		kwh -= part
+	}
+	return used
+}
+
+// selectSamples filters samples to the report range and picks the
+// channels to price.
+func selectSamples(samples []energy.Sample, opts *ReportOptions) []energy.Sample {
+	var inRange []energy.Sample
+	hasTotal, hasPhases := false, false
+	for _, s := range samples {
+		if !opts.From.IsZero() && s.Time.Before(opts.From) {
+			continue
+		}
+		if !opts.To.IsZero() && !s.Time.Before(opts.To) {
+			continue
+		}
+		inRange = append(inRange, s)
+		if s.Phase == energy.PhaseTotal {
+			hasTotal = true
+		} else {
+			hasPhases = true
+		}
+	}
+
+	var out []energy.Sample
+	switch {
+	case opts.NetPhases && hasPhases:
+		out = netPhases(inRange)
+	case hasTotal:
+		for _, s := range inRange {
+			if s.Phase == energy.PhaseTotal {
+				out = append(out, s)
+			}
+		}
+	default:
+		out = inRange
+	}
+	sortByTime(out)
+	return out
+}
+
+// netPhases nets the per-phase samples of each interval into one total
+// sample.
+func netPhases(samples []energy.Sample) []energy.Sample {
+	type interval struct {
+		sample energy.Sample
+		net    float64
+	}
+	byTime := make(map[int64]*interval)
+	var order []int64
+	for _, s := range samples {
+		if s.Phase == energy.PhaseTotal {
+			continue
+		}
+		ts := s.Time.Unix()
+		iv, ok := byTime[ts]
+		if !ok {
+			iv = &interval{sample: energy.Sample{Time: s.Time, Phase: energy.PhaseTotal, Period: s.Period}}
+			byTime[ts] = iv
+			order = append(order, ts)
+		}
+		iv.net += s.Import - s.Export
+		iv.sample.Filled = iv.sample.Filled || s.Filled
+	}
+
+	out := make([]energy.Sample, 0, len(order))
+	for _, ts := range order {
+		iv := byTime[ts]
+		if iv.net >= 0 {
+			iv.sample.Import = iv.net
+		} else {
+			iv.sample.Export = -iv.net
+		}
+		out = append(out, iv.sample)
+	}
+	return out
+}
+
+// updateRange widens an unbounded report range to cover samples.
+func updateRange(report *Report, samples []energy.Sample) {
+	for i := range samples {
+		s := &samples[i]
+		if report.From.IsZero() || s.Time.Before(report.From) {
+			report.From = s.Time
+		}
+		if end := s.End(); end.After(report.To) {
+			report.To = end
+		}
+	}
+}
+
+// sumLines adds up lines, merging period breakdowns by name.
+func sumLines(name string, lines []Line) Line {
+	total := Line{Name: name}
+	index := make(map[string]int)
+	for _, l := range lines {
+		total.Import += l.Import
+		total.Export += l.Export
+		total.ImportCost += l.ImportCost
+		total.ExportCredit += l.ExportCredit
+		for _, u := range l.Periods {
+			i, ok := index[u.Name]
+			if !ok {
+				i = len(total.Periods)
+				index[u.Name] = i
+				total.Periods = append(total.Periods, Usage{Name: u.Name})
+			}
+			total.Periods[i].Energy += u.Energy
+			total.Periods[i].Cost += u.Cost
+		}
+	}
+	total.Net = total.Import - total.Export
+	total.Cost = total.ImportCost - total.ExportCredit
+	return total
+}
+
+// calendarDays counts the local calendar days touched by [from, to).
+func calendarDays(from, to time.Time, loc *time.Location) int {
+	a := from.In(loc)
+	b := to.Add(-time.Nanosecond).In(loc)
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours()/24) + 1
+}
+
+func sortByTime(samples []energy.Sample) {
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+}
@@ -0,0 +1,212 @@
+package tariff
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/energy"
+	gen1components "github.com/tj-smith47/shelly-go/gen1/components"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// hourly returns hourly samples of whPerHour starting at start.
+func hourly(start time.Time, hours int, phase energy.Phase, imp, exp float64) []energy.Sample {
+	samples := make([]energy.Sample, hours)
+	for i := range samples {
+		samples[i] = energy.Sample{
+			Time:   start.Add(time.Duration(i) * time.Hour),
+			Phase:  phase,
+			Import: imp,
+			Export: exp,
+			Period: 3600,
+		}
+	}
+	return samples
+}
+
+func TestReport(t *testing.T) {
+	tariff, err := ParseJSON([]byte(`{
+		"name": "TOU", "currency": "EUR", "standing_charge": 0.5,
+		"import": {"type": "tou", "rate": 0.2, "periods": [{"name": "peak", "from": "08:00", "to": "20:00", "rate": 0.4}]},
+		"export": {"rate": 0.1}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two days, 1 kWh per hour on each device.
+	start := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	meters := []Meter{
+		{Name: "heat pump", Circuit: "hvac", Samples: hourly(start, 48, energy.PhaseTotal, 1000, 0)},
+		{Name: "boiler", Circuit: "hvac", Samples: hourly(start, 48, energy.PhaseTotal, 1000, 0)},
+		{Name: "pv", Samples: hourly(start, 48, energy.PhaseTotal, 0, 500)},
+	}
+
+	report, err := tariff.Report(meters, ReportOptions{})
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if report.Days != 2 || !approx(report.StandingCharge, 1) {
+		t.Errorf("days = %d, standing = %v", report.Days, report.StandingCharge)
+	}
+	if !report.From.Equal(start) || !report.To.Equal(start.Add(48*time.Hour)) {
+		t.Errorf("range = %v - %v", report.From, report.To)
+	}
+
+	heat := report.Devices[0]
+	// 24 peak hours at 0.4 and 24 standard hours at 0.2.
+	if heat.Import != 48 || !approx(heat.ImportCost, 14.4) || len(heat.Periods) != 2 {
+		t.Errorf("heat pump = %+v", heat)
+	}
+	pv := report.Devices[2]
+	if pv.Export != 24 || !approx(pv.ExportCredit, 2.4) || !approx(pv.Cost, -2.4) || pv.Net != -24 {
+		t.Errorf("pv = %+v", pv)
+	}
+	if len(report.Circuits) != 1 || report.Circuits[0].Name != "hvac" || !approx(report.Circuits[0].ImportCost, 28.8) {
+		t.Errorf("circuits = %+v", report.Circuits)
+	}
+	if !approx(report.Total.Cost, 28.8-2.4+1) || report.Total.Import != 96 {
+		t.Errorf("total = %+v", report.Total)
+	}
+
+	// A bounded range limits samples and standing charges.
+	day2 := start.Add(24 * time.Hour)
+	report, _ = tariff.Report(meters[:1], ReportOptions{From: day2, To: day2.Add(12 * time.Hour)})
+	if report.Days != 1 || report.Devices[0].Import != 12 {
+		t.Errorf("ranged report = %+v", report)
+	}
+}
+
+func TestReport_Tiered(t *testing.T) {
+	tariff, err := ParseYAML([]byte(`
+name: Tiered
+import:
+  type: tiered
+  billing_day: 15
+  tiers:
+    - {name: base, up_to: 10, rate: 0.1}
+    - {rate: 0.3}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 kWh per hour: 16 kWh before the billing day, 16 kWh after.
+	start := time.Date(2025, 3, 14, 20, 0, 0, 0, time.UTC)
+	line, err := tariff.Cost("house", hourly(start, 8, energy.PhaseTotal, 4000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each billing period: 10 kWh at 0.1 + 6 kWh at 0.3 = 2.8.
+	if line.Import != 32 || !approx(line.ImportCost, 5.6) {
+		t.Errorf("line = %+v", line)
+	}
+	if len(line.Periods) != 2 || line.Periods[0].Name != "base" || line.Periods[0].Energy != 20 || line.Periods[1].Name != "tier 2" {
+		t.Errorf("periods = %+v", line.Periods)
+	}
+}
+
+func TestReport_NetPhases(t *testing.T) {
+	tariff := &Tariff{Import: Rates{Rate: 0.3}, Export: &Rates{Rate: 0.1}}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	samples := append(hourly(start, 1, energy.PhaseA, 2000, 0), hourly(start, 1, energy.PhaseB, 0, 3000)...)
+
+	gross, err := tariff.Report([]Meter{{Name: "3em", Samples: samples}}, ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gross.Devices[0].Import != 2 || gross.Devices[0].Export != 3 {
+		t.Errorf("gross = %+v", gross.Devices[0])
+	}
+	net, _ := tariff.Report([]Meter{{Name: "3em", Samples: samples}}, ReportOptions{NetPhases: true})
+	if net.Devices[0].Import != 0 || net.Devices[0].Export != 1 || !approx(net.Devices[0].Cost, -0.1) {
+		t.Errorf("net = %+v", net.Devices[0])
+	}
+}
+
+func TestSolar(t *testing.T) {
+	tariff := &Tariff{Import: Rates{Rate: 0.3}, Export: &Rates{Rate: 0.1}}
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	// Hour 1: produce 3 kWh, export 1 kWh. Hour 2: produce nothing, import 2 kWh.
+	grid := []energy.Sample{
+		{Time: start, Phase: energy.PhaseA, Export: 1000, Period: 3600},
+		{Time: start.Add(time.Hour), Phase: energy.PhaseA, Import: 2000, Period: 3600},
+	}
+	production := []energy.Sample{
+		{Time: start, Phase: energy.PhaseTotal, Import: 3000, Period: 3600},
+		{Time: start.Add(time.Hour), Phase: energy.PhaseTotal, Period: 3600},
+	}
+
+	report, err := tariff.Solar(grid, production, ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Production != 3 || report.SelfConsumption != 2 || report.Consumption != 4 {
+		t.Errorf("report = %+v", report)
+	}
+	if !approx(report.SelfConsumptionRate, 2.0/3) || !approx(report.Autarky, 0.5) || !approx(report.Savings, 0.6) {
+		t.Errorf("rates = %+v", report)
+	}
+	if report.Grid.Import != 2 || report.Grid.Export != 1 || !approx(report.Grid.Cost, 0.5) {
+		t.Errorf("grid = %+v", report.Grid)
+	}
+}
+
+func TestReadings(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	t2 := t1.Add(time.Hour)
+
+	var readings []Reading
+	readings = append(readings, FromSwitch(t0, &components.SwitchStatus{AEnergy: &components.EnergyCounters{Total: 1000}})...)
+	readings = append(readings, FromSwitch(t1, &components.SwitchStatus{AEnergy: &components.EnergyCounters{Total: 1500}})...)
+	// Counter reset between t1 and t2.
+	readings = append(readings, FromSwitch(t2, &components.SwitchStatus{AEnergy: &components.EnergyCounters{Total: 200}})...)
+
+	samples := FromReadings(readings)
+	if len(samples) != 2 || samples[0].Import != 500 || samples[1].Import != 200 || samples[0].Period != 3600 {
+		t.Errorf("samples = %+v", samples)
+	}
+
+	if got := FromSwitch(t0, &components.SwitchStatus{}); got != nil {
+		t.Errorf("FromSwitch(no counters) = %+v", got)
+	}
+
+	ret := 40.0
+	pm := FromPM1(t0, &components.PM1Status{
+		AEnergy:    &components.PM1EnergyCounters{Total: 10},
+		RetAEnergy: &components.PM1EnergyCounters{Total: ret},
+	})
+	if len(pm) != 1 || pm[0].Export != 40 {
+		t.Errorf("FromPM1() = %+v", pm)
+	}
+
+	a, total := 100.0, 300.0
+	em := FromEMData(t0, &components.EMDataStatus{ATotalActEnergy: &a, TotalAct: &total, TotalActRet: &ret})
+	if len(em) != 2 || em[0].Phase != energy.PhaseA || em[1].Phase != energy.PhaseTotal || em[1].Export != 40 {
+		t.Errorf("FromEMData() = %+v", em)
+	}
+
+	em1 := FromEM1Data(t0, &components.EM1DataStatus{TotalActEnergy: &a})
+	if len(em1) != 1 || em1[0].Import != 100 {
+		t.Errorf("FromEM1Data() = %+v", em1)
+	}
+
+	gen1 := FromEMeters(t0,
+		gen1components.EMeterStatus{Total: 10, TotalReturned: 1},
+		gen1components.EMeterStatus{Total: 20},
+		gen1components.EMeterStatus{Total: 30, TotalReturned: 2},
+	)
+	if len(gen1) != 4 || gen1[3].Phase != energy.PhaseTotal || gen1[3].Import != 60 || gen1[3].Export != 3 {
+		t.Errorf("FromEMeters() = %+v", gen1)
+	}
+	if single := FromEMeters(t0, gen1components.EMeterStatus{Total: 5}); len(single) != 1 || single[0].Phase != energy.PhaseTotal {
+		t.Errorf("FromEMeters(single) = %+v", single)
+	}
+}
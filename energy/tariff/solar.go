@@ -0,0 +1,79 @@
+package tariff
+
+import (
+	"github.com/tj-smith47/shelly-go/energy"
+)
+
+// SolarReport is the net-metering balance of a solar install.
+type SolarReport struct {
+	// Grid is the priced grid connection, netted across phases.
+	Grid Line `json:"grid"`
+
+	// Production is the solar yield in kWh.
+	Production float64 `json:"production_kwh"`
+
+	// SelfConsumption is production used on site in kWh.
+	SelfConsumption float64 `json:"self_consumption_kwh"`
+
+	// Consumption is total on-site use (grid import plus self-consumption)
+	// in kWh.
+	Consumption float64 `json:"consumption_kwh"`
+
+	// SelfConsumptionRate is SelfConsumption / Production.
+	SelfConsumptionRate float64 `json:"self_consumption_rate"`
+
+	// Autarky is SelfConsumption / Consumption, the share of demand
+	// covered by solar.
+	Autarky float64 `json:"autarky"`
+
+	// Savings is the import cost avoided by self-consumption.
+	Savings float64 `json:"savings"`
+}
+
+// Solar reports the balance of a grid meter (e.g. a Pro 3EM at the
+// service entrance) and a production meter (e.g. a PM or Switch on the
+// inverter output).
+//
+// Grid intervals are netted across phases. Self-consumption is computed
+// per interval as production minus grid export, so both meters should
+// report the same interval starts (e.g. both aggregated to minutes); a
+// production interval without a matching grid interval counts as fully
+// self-consumed.
+func (t *Tariff) Solar(grid, production []energy.Sample, opts ReportOptions) (*SolarReport, error) {
+	if err := t.ensure(); err != nil {
+		return nil, err
+	}
+
+	netOpts := opts
+	netOpts.NetPhases = true
+	gridSamples := selectSamples(grid, &netOpts)
+	report := &SolarReport{Grid: t.price("grid", gridSamples)}
+
+	exported := make(map[int64]float64, len(gridSamples))
+	for _, s := range gridSamples {
+		exported[s.Time.Unix()] += s.Export / 1000
+	}
+
+	for _, s := range selectSamples(production, &opts) {
+		// Production meters count either way depending on how they are wired.
+		produced := max(s.Import, s.Export) / 1000
+		if produced <= 0 {
+			continue
+		}
+		report.Production += produced
+
+		self := max(produced-exported[s.Time.Unix()], 0)
+		report.SelfConsumption += self
+		_, rate, _ := t.ImportRate(s.Time) //nolint:errcheck // Validated above
+		report.Savings += self * rate
+	}
+
+	report.Consumption = report.Grid.Import + report.SelfConsumption
+	if report.Production > 0 {
+		report.SelfConsumptionRate = report.SelfConsumption / report.Production
+	}
+	if report.Consumption > 0 {
+		report.Autarky = report.SelfConsumption / report.Consumption
+	}
+	return report, nil
+}
@@ -0,0 +1,396 @@
+package tariff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidTariff is returned for tariff definitions that fail validation.
+var ErrInvalidTariff = errors.New("invalid tariff")
+
+// Kind selects how a rate schedule prices energy.
+type Kind string
+
+// Rate schedule kinds.
+const (
+	// Flat charges one rate at all times.
+	Flat Kind = "flat"
+
+	// TimeOfUse charges the rate of the first matching period and Rate
+	// otherwise.
+	TimeOfUse Kind = "tou"
+
+	// Tiered charges by cumulative energy within the billing period.
+	Tiered Kind = "tiered"
+)
+
+// Tariff is an electricity tariff with import rates and optional export
+// (feed-in) rates. Rates are per kWh in Currency.
+type Tariff struct {
+	// Export is the feed-in schedule; nil means exported energy earns
+	// nothing.
+	Export *Rates `json:"export,omitempty" yaml:"export,omitempty"`
+
+	loc      *time.Location
+	holidays map[string]bool
+
+	// Name is a display name.
+	Name string `json:"name" yaml:"name"`
+
+	// Currency is a display currency code.
+	Currency string `json:"currency,omitempty" yaml:"currency,omitempty"`
+
+	// Timezone is the IANA zone in which periods, holidays and billing
+	// periods are evaluated (UTC if empty).
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+
+	// Holidays are dates (YYYY-MM-DD) on which only periods listing
+	// "holiday" apply.
+	Holidays []string `json:"holidays,omitempty" yaml:"holidays,omitempty"`
+
+	// Import is the consumption schedule.
+	Import Rates `json:"import" yaml:"import"`
+
+	// StandingCharge is a fixed charge per calendar day.
+	StandingCharge float64 `json:"standing_charge,omitempty" yaml:"standing_charge,omitempty"`
+
+	compiled bool
+}
+
+// Rates is a rate schedule.
+type Rates struct {
+	// Kind selects the pricing model (Flat if empty).
+	Kind Kind `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Periods are the time-of-use windows, first match wins.
+	Periods []Period `json:"periods,omitempty" yaml:"periods,omitempty"`
+
+	// Tiers are consumption blocks in ascending order.
+	Tiers []Tier `json:"tiers,omitempty" yaml:"tiers,omitempty"`
+
+	// Rate is the flat rate, or the time-of-use rate outside all periods.
+	Rate float64 `json:"rate,omitempty" yaml:"rate,omitempty"`
+
+	// BillingDay is the day of month (1-28) tiered billing periods start;
+	// 1 if zero.
+	BillingDay int `json:"billing_day,omitempty" yaml:"billing_day,omitempty"`
+}
+
+// Period is a time-of-use window.
+type Period struct {
+	// Name labels the period in reports, e.g. "peak".
+	Name string `json:"name" yaml:"name"`
+
+	// From and To are "HH:MM" local times. To may be earlier than From for
+	// windows crossing midnight; equal times cover the whole day.
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+
+	// Days are "mon".."sun", "weekdays", "weekend" or "holiday". Empty
+	// means every non-holiday day.
+	Days []string `json:"days,omitempty" yaml:"days,omitempty"`
+
+	// Rate is the price per kWh.
+	Rate float64 `json:"rate" yaml:"rate"`
+
+	from, to int
+	days     uint8
+	holiday  bool
+}
+
+// Tier is a consumption block.
+type Tier struct {
+	// Name labels the tier in reports; "tier N" if empty.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// UpTo is the cumulative kWh per billing period this tier ends at;
+	// zero for the last, unbounded tier.
+	UpTo float64 `json:"up_to,omitempty" yaml:"up_to,omitempty"`
+
+	// Rate is the price per kWh.
+	Rate float64 `json:"rate" yaml:"rate"`
+}
+
+// ParseJSON parses and validates a JSON tariff definition.
+func ParseJSON(data []byte) (*Tariff, error) {
+	var t Tariff
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse tariff: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ParseYAML parses and validates a YAML tariff definition.
+func ParseYAML(data []byte) (*Tariff, error) {
+	var t Tariff
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse tariff: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// LoadFile reads a tariff from a .json, .yaml or .yml file.
+func LoadFile(path string) (*Tariff, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tariff: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	default:
+		return ParseJSON(data)
+	}
+}
+
+// Validate checks the definition and prepares it for use. Tariffs from
+// ParseJSON, ParseYAML and LoadFile are already validated; tariffs built
+// in code are validated on first use.
+func (t *Tariff) Validate() error {
+	loc := time.UTC
+	if t.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(t.Timezone); err != nil {
+			return fmt.Errorf("%w: timezone %q: %v", ErrInvalidTariff, t.Timezone, err)
+		}
+	}
+
+	holidays := make(map[string]bool, len(t.Holidays))
+	for _, h := range t.Holidays {
+		if _, err := time.Parse(time.DateOnly, h); err != nil {
+			return fmt.Errorf("%w: holiday %q is not YYYY-MM-DD", ErrInvalidTariff, h)
+		}
+		holidays[h] = true
+	}
+
+	if err := t.Import.compile(); err != nil {
+		return fmt.Errorf("%w: import: %v", ErrInvalidTariff, err)
+	}
+	if t.Export != nil {
+		if t.Export.Kind == Tiered {
+			return fmt.Errorf("%w: export: tiered feed-in is not supported", ErrInvalidTariff)
+		}
+		if err := t.Export.compile(); err != nil {
+			return fmt.Errorf("%w: export: %v", ErrInvalidTariff, err)
+		}
+	}
+
+	t.loc = loc
+	t.holidays = holidays
+	t.compiled = true
+	return nil
+}
+
+// ensure validates the tariff on first use.
+func (t *Tariff) ensure() error {
+	if t.compiled {
+		return nil
+	}
+	return t.Validate()
+}
+
+// Location returns the tariff time zone.
+func (t *Tariff) Location() *time.Location {
+	if t.loc == nil {
+		return time.UTC
+	}
+	return t.loc
+}
+
+// IsHoliday reports whether the local date of ts is a holiday.
+func (t *Tariff) IsHoliday(ts time.Time) bool {
+	return t.holidays[ts.In(t.Location()).Format(time.DateOnly)]
+}
+
+// ImportRate returns the import period name and rate at ts. Tiered
+// schedules report the first tier.
+func (t *Tariff) ImportRate(ts time.Time) (string, float64, error) {
+	if err := t.ensure(); err != nil {
+		return "", 0, err
+	}
+	name, rate := t.Import.at(ts.In(t.Location()), t.IsHoliday(ts))
+	return name, rate, nil
+}
+
+// ExportRate returns the feed-in period name and rate at ts; zero without
+// an export schedule.
+func (t *Tariff) ExportRate(ts time.Time) (string, float64, error) {
+	if err := t.ensure(); err != nil {
+		return "", 0, err
+	}
+	if t.Export == nil {
+		return "", 0, nil
+	}
+	name, rate := t.Export.at(ts.In(t.Location()), t.IsHoliday(ts))
+	return name, rate, nil
+}
+
+// dayBits maps day names to weekday bits.
+var dayBits = map[string]uint8{
+	"sun":      1 << time.Sunday,
+	"mon":      1 << time.Monday,
+	"tue":      1 << time.Tuesday,
+	"wed":      1 << time.Wednesday,
+	"thu":      1 << time.Thursday,
+	"fri":      1 << time.Friday,
+	"sat":      1 << time.Saturday,
+	"weekdays": 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday,
+	"weekend":  1<<time.Saturday | 1<<time.Sunday,
+}
+
+const allDays = 0x7F
+
+// compile validates a rate schedule.
+func (r *Rates) compile() error {
+	if r.Kind == "" {
+		r.Kind = Flat
+	}
+	switch r.Kind {
+	case Flat:
+	case TimeOfUse:
+		if len(r.Periods) == 0 {
+			return errors.New("time-of-use schedule has no periods")
+		}
+		for i := range r.Periods {
+			if err := r.Periods[i].compile(); err != nil {
+				return fmt.Errorf("period %d: %w", i, err)
+			}
+		}
+	case Tiered:
+		if len(r.Tiers) == 0 {
+			return errors.New("tiered schedule has no tiers")
+		}
+		last := 0.0
+		for i, tier := range r.Tiers {
+			if tier.UpTo == 0 && i != len(r.Tiers)-1 {
+				return fmt.Errorf("tier %d: only the last tier may be unbounded", i)
+			}
+			if tier.UpTo != 0 && tier.UpTo <= last {
+				return fmt.Errorf("tier %d: up_to must increase", i)
+			}
+			last = tier.UpTo
+		}
+		if r.BillingDay < 0 || r.BillingDay > 28 {
+			return fmt.Errorf("billing_day %d out of range 1-28", r.BillingDay)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", r.Kind)
+	}
+	return nil
+}
+
+func (p *Period) compile() error {
+	var err error
+	if p.from, err = parseClock(p.From); err != nil {
+		return err
+	}
+	if p.to, err = parseClock(p.To); err != nil {
+		return err
+	}
+	p.days, p.holiday = 0, false
+	for _, d := range p.Days {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "holiday" || d == "holidays" {
+			p.holiday = true
+			continue
+		}
+		bits, ok := dayBits[d]
+		if !ok {
+			return fmt.Errorf("unknown day %q", d)
+		}
+		p.days |= bits
+	}
+	if len(p.Days) == 0 {
+		p.days = allDays
+	}
+	return nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// matches reports whether the period covers local time ts.
+func (p *Period) matches(ts time.Time, holiday bool) bool {
+	minute := ts.Hour()*60 + ts.Minute()
+	day := ts.Weekday()
+	if p.from > p.to && minute < p.to {
+		// After midnight in a window that started the previous day.
+		day = (day + 6) % 7
+	}
+	if holiday {
+		if !p.holiday {
+			return false
+		}
+	} else if p.days&(1<<day) == 0 {
+		return false
+	}
+
+	switch {
+	case p.from == p.to:
+		return true
+	case p.from < p.to:
+		return minute >= p.from && minute < p.to
+	default:
+		return minute >= p.from || minute < p.to
+	}
+}
+
+// at returns the period name and rate at local time ts.
+func (r *Rates) at(ts time.Time, holiday bool) (string, float64) {
+	switch r.Kind {
+	case TimeOfUse:
+		for i := range r.Periods {
+			if r.Periods[i].matches(ts, holiday) {
+				return r.Periods[i].Name, r.Periods[i].Rate
+			}
+		}
+		return "standard", r.Rate
+	case Tiered:
+		return tierName(r.Tiers, 0), r.Tiers[0].Rate
+	default:
+		return "flat", r.Rate
+	}
+}
+
+func tierName(tiers []Tier, i int) string {
+	if tiers[i].Name != "" {
+		return tiers[i].Name
+	}
+	return "tier " + strconv.Itoa(i+1)
+}
+
+// billingStart returns the start of the billing period containing local
+// time ts.
+func (r *Rates) billingStart(ts time.Time) time.Time {
+	day := r.BillingDay
+	if day == 0 {
+		day = 1
+	}
+	start := time.Date(ts.Year(), ts.Month(), day, 0, 0, 0, 0, ts.Location())
+	if ts.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
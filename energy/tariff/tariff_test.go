@@ -0,0 +1,135 @@
+package tariff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const touYAML = `
+name: Residential TOU
+currency: EUR
+timezone: Europe/Berlin
+holidays: ["2025-12-25"]
+standing_charge: 0.5
+import:
+  type: tou
+  rate: 0.20
+  periods:
+    - {name: peak, days: [weekdays], from: "07:00", to: "21:00", rate: 0.40}
+    - {name: night, from: "22:00", to: "06:00", rate: 0.10}
+    - {name: holiday, days: [holiday], from: "00:00", to: "00:00", rate: 0.15}
+export:
+  rate: 0.08
+`
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+	return loc
+}
+
+func TestTimeOfUse(t *testing.T) {
+	loc := mustLocation(t, "Europe/Berlin")
+	tariff, err := ParseYAML([]byte(touYAML))
+	if err != nil {
+		t.Fatalf("ParseYAML() error = %v", err)
+	}
+
+	tests := []struct {
+		time     time.Time
+		wantName string
+		wantRate float64
+	}{
+		{time.Date(2025, 12, 22, 8, 0, 0, 0, loc), "peak", 0.40},           // Monday
+		{time.Date(2025, 12, 22, 21, 30, 0, 0, loc), "standard", 0.20},     // Monday evening
+		{time.Date(2025, 12, 22, 23, 0, 0, 0, loc), "night", 0.10},         // Monday night
+		{time.Date(2025, 12, 23, 5, 59, 0, 0, loc), "night", 0.10},         // after midnight
+		{time.Date(2025, 12, 20, 8, 0, 0, 0, loc), "standard", 0.20},       // Saturday
+		{time.Date(2025, 12, 25, 8, 0, 0, 0, loc), "holiday", 0.15},        // Thursday holiday
+		{time.Date(2025, 12, 25, 7, 0, 0, 0, time.UTC), "holiday", 0.15},   // same instant in UTC
+		{time.Date(2025, 12, 24, 23, 30, 0, 0, time.UTC), "holiday", 0.15}, // 00:30 local
+		{time.Date(2025, 12, 26, 12, 0, 0, 0, loc).In(time.UTC), "peak", 0.40},
+	}
+	for _, tt := range tests {
+		name, rate, err := tariff.ImportRate(tt.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != tt.wantName || rate != tt.wantRate {
+			t.Errorf("ImportRate(%v) = %s %v, want %s %v", tt.time, name, rate, tt.wantName, tt.wantRate)
+		}
+	}
+
+	if _, rate, _ := tariff.ExportRate(time.Now()); rate != 0.08 {
+		t.Errorf("ExportRate() = %v, want 0.08", rate)
+	}
+}
+
+func TestParseAndValidate(t *testing.T) {
+	flat, err := ParseJSON([]byte(`{"name":"Flat","import":{"rate":0.3}}`))
+	if err != nil {
+		t.Fatalf("ParseJSON() error = %v", err)
+	}
+	if flat.Import.Kind != Flat || flat.Location() != time.UTC {
+		t.Errorf("flat = %+v", flat)
+	}
+	if _, rate, _ := flat.ExportRate(time.Now()); rate != 0 {
+		t.Errorf("ExportRate() without export = %v", rate)
+	}
+
+	invalid := []string{
+		`{"import":{"type":"tou"}}`,
+		`{"import":{"type":"tou","periods":[{"from":"7","to":"09:00"}]}}`,
+		`{"import":{"type":"tou","periods":[{"from":"07:00","to":"09:00","days":["someday"]}]}}`,
+		`{"import":{"type":"tiered","tiers":[{"rate":0.1},{"up_to":100,"rate":0.2}]}}`,
+		`{"import":{"type":"tiered","tiers":[{"up_to":100,"rate":0.1},{"up_to":50,"rate":0.2}]}}`,
+		`{"import":{"type":"dynamic"}}`,
+		`{"timezone":"Mars/Olympus","import":{"rate":0.3}}`,
+		`{"holidays":["25.12.2025"],"import":{"rate":0.3}}`,
+		`{"import":{"rate":0.3},"export":{"type":"tiered","tiers":[{"rate":0.1}]}}`,
+	}
+	for _, def := range invalid {
+		if _, err := ParseJSON([]byte(def)); !errors.Is(err, ErrInvalidTariff) {
+			t.Errorf("ParseJSON(%s) error = %v, want ErrInvalidTariff", def, err)
+		}
+	}
+	if _, err := ParseJSON([]byte(`{`)); err == nil {
+		t.Error("expected syntax error")
+	}
+
+	// Tariffs built in code validate on first use.
+	coded := &Tariff{Import: Rates{Kind: TimeOfUse}}
+	if _, _, err := coded.ImportRate(time.Now()); !errors.Is(err, ErrInvalidTariff) {
+		t.Errorf("ImportRate() error = %v, want ErrInvalidTariff", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "tariff.yml")
+	jsonPath := filepath.Join(dir, "tariff.json")
+	if err := os.WriteFile(yamlPath, []byte(touYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jsonPath, []byte(`{"name":"Flat","import":{"rate":0.3}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tou, err := LoadFile(yamlPath)
+	if err != nil || tou.Name != "Residential TOU" || len(tou.Import.Periods) != 3 {
+		t.Errorf("LoadFile(yaml) = %+v, %v", tou, err)
+	}
+	flat, err := LoadFile(jsonPath)
+	if err != nil || flat.Import.Rate != 0.3 {
+		t.Errorf("LoadFile(json) = %+v, %v", flat, err)
+	}
+	if _, err := LoadFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,84 @@
+package events
+
+import "time"
+
+// Appliance event types, emitted when appliance detection recognizes an
+// appliance switching on or off in a meter's power readings.
+const (
+	// EventTypeApplianceOn indicates an appliance switched on.
+	EventTypeApplianceOn EventType = "appliance_on"
+
+	// EventTypeApplianceOff indicates an appliance switched off.
+	EventTypeApplianceOff EventType = "appliance_off"
+)
+
+// ApplianceEvent represents an appliance switching on or off. DeviceID
+// returns the ID of the meter that measured it.
+type ApplianceEvent struct {
+	BaseEvent
+
+	// Appliance is the label of the recognized appliance.
+	Appliance string `json:"appliance"`
+
+	// Phase is the measured phase ("a", "b", "c" or "total").
+	Phase string `json:"phase,omitempty"`
+
+	// Power is the size of the power step in watts.
+	Power float64 `json:"power"`
+
+	// Confidence is how closely the step matched the appliance signature,
+	// from 0 to 1.
+	Confidence float64 `json:"confidence"`
+}
+
+// NewApplianceEvent creates a new appliance on or off event.
+func NewApplianceEvent(deviceID, appliance string, on bool, power float64) *ApplianceEvent {
+	eventType := EventTypeApplianceOff
+	if on {
+		eventType = EventTypeApplianceOn
+	}
+	return &ApplianceEvent{
+		BaseEvent: BaseEvent{
+			eventType: eventType,
+			deviceID:  deviceID,
+			timestamp: time.Now(),
+			source:    EventSourceLocal,
+		},
+		Appliance: appliance,
+		Power:     power,
+	}
+}
+
+// On reports whether the appliance switched on.
+func (e *ApplianceEvent) On() bool {
+	return e.eventType == EventTypeApplianceOn
+}
+
+// WithPhase sets the measured phase.
+func (e *ApplianceEvent) WithPhase(phase string) *ApplianceEvent {
+	e.Phase = phase
+	return e
+}
+
+// WithConfidence sets the match confidence.
+func (e *ApplianceEvent) WithConfidence(confidence float64) *ApplianceEvent {
+	e.Confidence = confidence
+	return e
+}
+
+// WithTimestamp sets when the switching was measured.
+func (e *ApplianceEvent) WithTimestamp(ts time.Time) *ApplianceEvent {
+	e.timestamp = ts
+	return e
+}
+
+// WithSource sets the event source.
+func (e *ApplianceEvent) WithSource(source EventSource) *ApplianceEvent {
+	e.source = source
+	return e
+}
+
+// ApplianceEvents returns a filter matching appliance on and off events.
+func ApplianceEvents() Filter {
+	return WithEventTypes(EventTypeApplianceOn, EventTypeApplianceOff)
+}
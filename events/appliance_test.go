@@ -0,0 +1,33 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplianceEvents(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 7, 0, 0, 0, time.UTC)
+	on := NewApplianceEvent("shellypro3em-1", "kettle", true, 2100).
+		WithPhase("a").
+		WithConfidence(0.9).
+		WithTimestamp(ts)
+	off := NewApplianceEvent("shellypro3em-1", "kettle", false, 2080).WithSource(EventSourceWebSocket)
+
+	if on.Type() != EventTypeApplianceOn || !on.On() || off.Type() != EventTypeApplianceOff || off.On() {
+		t.Errorf("types = %v, %v", on.Type(), off.Type())
+	}
+	if !on.Timestamp().Equal(ts) || on.Phase != "a" || on.Confidence != 0.9 || on.DeviceID() != "shellypro3em-1" {
+		t.Errorf("on = %+v", on)
+	}
+	if off.Source() != EventSourceWebSocket || off.Appliance != "kettle" || off.Power != 2080 {
+		t.Errorf("off = %+v", off)
+	}
+
+	filter := ApplianceEvents()
+	if !filter(on) || !filter(off) {
+		t.Error("ApplianceEvents() filter should match")
+	}
+	if filter(NewDeviceOnlineEvent("dev")) {
+		t.Error("ApplianceEvents() filter should not match other events")
+	}
+}
@@ -0,0 +1,166 @@
+package events
+
+import "time"
+
+// BLU sensor event types, emitted for Shelly BLU devices seen through
+// Gen2+ BLE gateways.
+const (
+	// EventTypeBLUButton indicates a BLU button press.
+	EventTypeBLUButton EventType = "blu_button"
+
+	// EventTypeBLUMotion indicates a BLU motion state change.
+	EventTypeBLUMotion EventType = "blu_motion"
+
+	// EventTypeBLUWindow indicates a BLU door/window state change.
+	EventTypeBLUWindow EventType = "blu_window"
+
+	// EventTypeBLUBatteryLow indicates a BLU device battery dropped below
+	// the low threshold.
+	EventTypeBLUBatteryLow EventType = "blu_battery_low"
+)
+
+// BLUEvent contains the fields shared by BLU sensor events. DeviceID
+// returns the BLU device MAC address.
+type BLUEvent struct {
+	BaseEvent
+
+	// Gateway is the ID of the gateway that reported the event.
+	Gateway string `json:"gateway"`
+
+	// Name is the BLU device name configured on the gateway.
+	Name string `json:"name,omitempty"`
+}
+
+func newBLUEvent(eventType EventType, mac, gateway string) BLUEvent {
+	return BLUEvent{
+		BaseEvent: BaseEvent{
+			eventType: eventType,
+			deviceID:  mac,
+			timestamp: time.Now(),
+			source:    EventSourceLocal,
+		},
+		Gateway: gateway,
+	}
+}
+
+// BLUButtonEvent represents a BLU button press.
+type BLUButtonEvent struct {
+	// Event is the press type in BTHome naming ("press", "double_press",
+	// "triple_press", "long_press", ...).
+	Event string `json:"event"`
+	BLUEvent
+
+	// Button is the button index for multi-button devices.
+	Button int `json:"button"`
+}
+
+// NewBLUButtonEvent creates a new BLU button event.
+func NewBLUButtonEvent(mac, gateway, event string, button int) *BLUButtonEvent {
+	return &BLUButtonEvent{
+		BLUEvent: newBLUEvent(EventTypeBLUButton, mac, gateway),
+		Event:    event,
+		Button:   button,
+	}
+}
+
+// WithName sets the device name.
+func (e *BLUButtonEvent) WithName(name string) *BLUButtonEvent {
+	e.Name = name
+	return e
+}
+
+// WithSource sets the event source.
+func (e *BLUButtonEvent) WithSource(source EventSource) *BLUButtonEvent {
+	e.source = source
+	return e
+}
+
+// BLUMotionEvent represents a BLU motion sensor state change.
+type BLUMotionEvent struct {
+	BLUEvent
+
+	// Motion is true when motion is detected.
+	Motion bool `json:"motion"`
+}
+
+// NewBLUMotionEvent creates a new BLU motion event.
+func NewBLUMotionEvent(mac, gateway string, motion bool) *BLUMotionEvent {
+	return &BLUMotionEvent{
+		BLUEvent: newBLUEvent(EventTypeBLUMotion, mac, gateway),
+		Motion:   motion,
+	}
+}
+
+// WithName sets the device name.
+func (e *BLUMotionEvent) WithName(name string) *BLUMotionEvent {
+	e.Name = name
+	return e
+}
+
+// WithSource sets the event source.
+func (e *BLUMotionEvent) WithSource(source EventSource) *BLUMotionEvent {
+	e.source = source
+	return e
+}
+
+// BLUWindowEvent represents a BLU door/window sensor state change.
+type BLUWindowEvent struct {
+	BLUEvent
+
+	// Open is true when the door or window is open.
+	Open bool `json:"open"`
+}
+
+// NewBLUWindowEvent creates a new BLU window event.
+func NewBLUWindowEvent(mac, gateway string, open bool) *BLUWindowEvent {
+	return &BLUWindowEvent{
+		BLUEvent: newBLUEvent(EventTypeBLUWindow, mac, gateway),
+		Open:     open,
+	}
+}
+
+// WithName sets the device name.
+func (e *BLUWindowEvent) WithName(name string) *BLUWindowEvent {
+	e.Name = name
+	return e
+}
+
+// WithSource sets the event source.
+func (e *BLUWindowEvent) WithSource(source EventSource) *BLUWindowEvent {
+	e.source = source
+	return e
+}
+
+// BLUBatteryLowEvent indicates a BLU device battery is low.
+type BLUBatteryLowEvent struct {
+	BLUEvent
+
+	// Battery is the battery level in percent, or -1 if the device only
+	// reported a battery low flag.
+	Battery int `json:"battery"`
+}
+
+// NewBLUBatteryLowEvent creates a new BLU battery low event.
+func NewBLUBatteryLowEvent(mac, gateway string, battery int) *BLUBatteryLowEvent {
+	return &BLUBatteryLowEvent{
+		BLUEvent: newBLUEvent(EventTypeBLUBatteryLow, mac, gateway),
+		Battery:  battery,
+	}
+}
+
+// WithName sets the device name.
+func (e *BLUBatteryLowEvent) WithName(name string) *BLUBatteryLowEvent {
+	e.Name = name
+	return e
+}
+
+// WithSource sets the event source.
+func (e *BLUBatteryLowEvent) WithSource(source EventSource) *BLUBatteryLowEvent {
+	e.source = source
+	return e
+}
+
+// BLUEvents returns a filter matching all BLU sensor events.
+func BLUEvents() Filter {
+	return WithEventTypes(EventTypeBLUButton, EventTypeBLUMotion, EventTypeBLUWindow, EventTypeBLUBatteryLow)
+}
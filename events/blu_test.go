@@ -0,0 +1,45 @@
+package events
+
+import "testing"
+
+func TestBLUEvents(t *testing.T) {
+	const mac = "7C:C6:B6:00:00:01"
+
+	tests := []struct {
+		event    Event
+		name     string
+		wantType EventType
+	}{
+		{name: "button", event: NewBLUButtonEvent(mac, "gw", "press", 0).WithName("Button"), wantType: EventTypeBLUButton},
+		{name: "motion", event: NewBLUMotionEvent(mac, "gw", true).WithName("Hall"), wantType: EventTypeBLUMotion},
+		{name: "window", event: NewBLUWindowEvent(mac, "gw", true), wantType: EventTypeBLUWindow},
+		{name: "battery low", event: NewBLUBatteryLowEvent(mac, "gw", 12), wantType: EventTypeBLUBatteryLow},
+	}
+
+	filter := BLUEvents()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.event.Type() != tt.wantType {
+				t.Errorf("Type() = %v, want %v", tt.event.Type(), tt.wantType)
+			}
+			if tt.event.DeviceID() != mac {
+				t.Errorf("DeviceID() = %v, want %v", tt.event.DeviceID(), mac)
+			}
+			if tt.event.Source() != EventSourceLocal {
+				t.Errorf("Source() = %v, want %v", tt.event.Source(), EventSourceLocal)
+			}
+			if !filter(tt.event) {
+				t.Error("BLUEvents() filter should match")
+			}
+		})
+	}
+
+	if filter(NewNotifyEvent("dev", "input:0", InputEventSinglePush)) {
+		t.Error("BLUEvents() filter should not match input events")
+	}
+
+	button := NewBLUButtonEvent(mac, "gw", "long_press", 1).WithSource(EventSourceWebSocket)
+	if button.Source() != EventSourceWebSocket || button.Button != 1 || button.Gateway != "gw" {
+		t.Errorf("button = %+v", button)
+	}
+}
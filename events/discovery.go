@@ -0,0 +1,50 @@
+package events
+
+import (
+	"time"
+)
+
+// EventTypeDeviceAddressChanged indicates a device moved to a new IP
+// address.
+const EventTypeDeviceAddressChanged EventType = "device_address_changed"
+
+// EventSourceDiscovery indicates the event came from network discovery.
+const EventSourceDiscovery EventSource = "discovery"
+
+// DeviceAddressChangedEvent indicates a known device was seen at a new
+// address, e.g. after a DHCP lease change.
+type DeviceAddressChangedEvent struct {
+	BaseEvent
+
+	// Address is the device's new IP address.
+	Address string `json:"address"`
+
+	// PreviousAddress is the address the device was last seen at.
+	PreviousAddress string `json:"previous_address"`
+}
+
+// NewDeviceAddressChangedEvent creates a new address changed event.
+func NewDeviceAddressChangedEvent(deviceID, previous, address string) *DeviceAddressChangedEvent {
+	return &DeviceAddressChangedEvent{
+		BaseEvent: BaseEvent{
+			eventType: EventTypeDeviceAddressChanged,
+			deviceID:  deviceID,
+			timestamp: time.Now(),
+			source:    EventSourceDiscovery,
+		},
+		Address:         address,
+		PreviousAddress: previous,
+	}
+}
+
+// WithSource sets the event source.
+func (e *DeviceAddressChangedEvent) WithSource(source EventSource) *DeviceAddressChangedEvent {
+	e.source = source
+	return e
+}
+
+// DeviceAddressChangedEvents returns a filter matching address changed
+// events.
+func DeviceAddressChangedEvents() Filter {
+	return WithEventTypes(EventTypeDeviceAddressChanged)
+}
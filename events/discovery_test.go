@@ -0,0 +1,25 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestDeviceAddressChangedEvent(t *testing.T) {
+	e := NewDeviceAddressChangedEvent("shellyplus1-a8032ab12345", "192.168.1.20", "192.168.1.31")
+
+	if e.Type() != EventTypeDeviceAddressChanged || e.Source() != EventSourceDiscovery ||
+		e.DeviceID() != "shellyplus1-a8032ab12345" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.PreviousAddress != "192.168.1.20" || e.Address != "192.168.1.31" {
+		t.Errorf("addresses = %s -> %s", e.PreviousAddress, e.Address)
+	}
+	if e.WithSource(EventSourceLocal).Source() != EventSourceLocal {
+		t.Error("WithSource() not applied")
+	}
+
+	filter := DeviceAddressChangedEvents()
+	if !filter(e) || filter(NewDeviceOnlineEvent("dev")) {
+		t.Error("DeviceAddressChangedEvents() filter mismatch")
+	}
+}
@@ -28,8 +28,12 @@
 //   - NotifyEvent: Input/button events (single_push, double_push, etc.)
 //   - DeviceOnlineEvent: Device came online
 //   - DeviceOfflineEvent: Device went offline
+//   - DeviceAddressChangedEvent: Device moved to a new IP address (see
+//     discovery.Watcher)
 //   - UpdateAvailableEvent: Firmware update available
 //   - ScriptEvent: Script output event
+//   - BLUButtonEvent, BLUMotionEvent, BLUWindowEvent, BLUBatteryLowEvent:
+//     BLU sensor events (see the blu package)
 //
 // Each event type provides typed access to event data:
 //
@@ -70,6 +70,9 @@ const (
 
 	// EventSourceMQTT indicates the event came from MQTT.
 	EventSourceMQTT EventSource = "mqtt"
+
+	// EventSourceZWave indicates the event came from a Z-Wave network.
+	EventSourceZWave EventSource = "zwave"
 )
 
 // BaseEvent provides common fields for all events.
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventTypeZWaveValue indicates a Z-Wave node reported a changed value.
+const EventTypeZWaveValue EventType = "zwave_value"
+
+// ZWaveValueEvent represents a value update from a Z-Wave node, such as a
+// Shelly Wave switch changing state or reporting power. DeviceID returns
+// the node's device ID as assigned by the Z-Wave client.
+type ZWaveValueEvent struct {
+	// Property is the value's property, e.g. "currentValue" or a
+	// configuration parameter number.
+	Property string `json:"property"`
+
+	// PropertyKey distinguishes sub-values, e.g. the meter scale; empty if
+	// the value has none.
+	PropertyKey string `json:"property_key,omitempty"`
+
+	// CommandClassName is the command class name, e.g. "Binary Switch".
+	CommandClassName string `json:"command_class_name,omitempty"`
+
+	// NewValue and PrevValue are the raw JSON values.
+	NewValue  json.RawMessage `json:"new_value"`
+	PrevValue json.RawMessage `json:"prev_value,omitempty"`
+
+	BaseEvent
+
+	// NodeID is the Z-Wave node ID.
+	NodeID int `json:"node_id"`
+
+	// CommandClass is the command class number, e.g. 37 for Binary Switch.
+	CommandClass int `json:"command_class"`
+
+	// Endpoint is the multi-channel endpoint; 0 for the root device.
+	Endpoint int `json:"endpoint"`
+}
+
+// NewZWaveValueEvent creates a new Z-Wave value event.
+func NewZWaveValueEvent(deviceID string, nodeID, commandClass int, property string, value json.RawMessage) *ZWaveValueEvent {
+	return &ZWaveValueEvent{
+		BaseEvent: BaseEvent{
+			eventType: EventTypeZWaveValue,
+			deviceID:  deviceID,
+			timestamp: time.Now(),
+			source:    EventSourceZWave,
+		},
+		NodeID:       nodeID,
+		CommandClass: commandClass,
+		Property:     property,
+		NewValue:     value,
+	}
+}
+
+// WithEndpoint sets the multi-channel endpoint.
+func (e *ZWaveValueEvent) WithEndpoint(endpoint int) *ZWaveValueEvent {
+	e.Endpoint = endpoint
+	return e
+}
+
+// WithPropertyKey sets the property key.
+func (e *ZWaveValueEvent) WithPropertyKey(key string) *ZWaveValueEvent {
+	e.PropertyKey = key
+	return e
+}
+
+// WithCommandClassName sets the command class name.
+func (e *ZWaveValueEvent) WithCommandClassName(name string) *ZWaveValueEvent {
+	e.CommandClassName = name
+	return e
+}
+
+// WithPrevValue sets the previous value.
+func (e *ZWaveValueEvent) WithPrevValue(value json.RawMessage) *ZWaveValueEvent {
+	e.PrevValue = value
+	return e
+}
+
+// WithTimestamp sets when the value changed.
+func (e *ZWaveValueEvent) WithTimestamp(ts time.Time) *ZWaveValueEvent {
+	e.timestamp = ts
+	return e
+}
+
+// ZWaveValueEvents returns a filter matching Z-Wave value events.
+func ZWaveValueEvents() Filter {
+	return WithEventTypes(EventTypeZWaveValue)
+}
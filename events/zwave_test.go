@@ -0,0 +1,33 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestZWaveValueEvent(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 7, 0, 0, 0, time.UTC)
+	e := NewZWaveValueEvent("zwave-c0ffee01-5", 5, 37, "currentValue", json.RawMessage(`true`)).
+		WithEndpoint(1).
+		WithPropertyKey("65537").
+		WithCommandClassName("Binary Switch").
+		WithPrevValue(json.RawMessage(`false`)).
+		WithTimestamp(ts)
+
+	if e.Type() != EventTypeZWaveValue || e.Source() != EventSourceZWave || e.DeviceID() != "zwave-c0ffee01-5" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.NodeID != 5 || e.CommandClass != 37 || e.Endpoint != 1 || e.Property != "currentValue" ||
+		e.PropertyKey != "65537" || e.CommandClassName != "Binary Switch" || !e.Timestamp().Equal(ts) {
+		t.Errorf("fields = %+v", e)
+	}
+	if string(e.NewValue) != "true" || string(e.PrevValue) != "false" {
+		t.Errorf("values = %s, %s", e.NewValue, e.PrevValue)
+	}
+
+	filter := ZWaveValueEvents()
+	if !filter(e) || filter(NewDeviceOnlineEvent("dev")) {
+		t.Error("ZWaveValueEvents() filter mismatch")
+	}
+}
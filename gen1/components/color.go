@@ -245,6 +245,33 @@ func (c *Color) TurnOnWithRGB(ctx context.Context, red, green, blue, gain int) e
 	return nil
 }
 
+// TurnOnWithRGBW turns on with specific RGBW values, fading over the
+// transition time.
+//
+// Parameters:
+//   - red, green, blue, white: RGBW values (0-255)
+//   - gain: Brightness (0-100)
+//   - transitionMs: Transition time in milliseconds (0-5000)
+func (c *Color) TurnOnWithRGBW(ctx context.Context, red, green, blue, white, gain, transitionMs int) error {
+	if red < 0 || red > 255 || green < 0 || green > 255 || blue < 0 || blue > 255 || white < 0 || white > 255 {
+		return fmt.Errorf("RGBW values must be 0-255")
+	}
+	if gain < 0 || gain > 100 {
+		return fmt.Errorf("gain must be 0-100, got %d", gain)
+	}
+	if transitionMs < 0 || transitionMs > 5000 {
+		return fmt.Errorf("transition must be 0-5000 ms, got %d", transitionMs)
+	}
+
+	path := fmt.Sprintf("/color/%d?turn=on&red=%d&green=%d&blue=%d&white=%d&gain=%d&transition=%d",
+		c.id, red, green, blue, white, gain, transitionMs)
+	_, err := restCall(ctx, c.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to turn on with RGBW: %w", err)
+	}
+	return nil
+}
+
 // TurnOnForDuration turns the light on for a specified duration.
 //
 // Parameters:
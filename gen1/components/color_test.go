@@ -570,6 +570,33 @@ func TestColorTurnOnWithRGBError(t *testing.T) {
 	}
 }
 
+func TestColorTurnOnWithRGBW(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/color/0?turn=on&red=255&green=100&blue=0&white=40&gain=80&transition=500", map[string]bool{"ison": true})
+
+	color := NewColor(mt, 0)
+	ctx := context.Background()
+
+	if err := color.TurnOnWithRGBW(ctx, 255, 100, 0, 40, 80, 500); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name                 string
+		r, g, b, w, gain, ms int
+	}{
+		{"white 256", 0, 0, 0, 256, 50, 0},
+		{"gain 101", 0, 0, 0, 0, 101, 0},
+		{"transition -1", 0, 0, 0, 0, 50, -1},
+		{"transition 5001", 0, 0, 0, 0, 50, 5001},
+	}
+	for _, tt := range tests {
+		if err := color.TurnOnWithRGBW(ctx, tt.r, tt.g, tt.b, tt.w, tt.gain, tt.ms); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
 // TestColorTurnOnForDurationError tests TurnOnForDuration error handling.
 func TestColorTurnOnForDurationError(t *testing.T) {
 	mt := newMockTransport()
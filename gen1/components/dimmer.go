@@ -0,0 +1,235 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// DimmerEdgeMode is the phase-cut dimming mode of a Shelly Dimmer.
+//
+// The value is sent to the device as the pulse_mode setting.
+type DimmerEdgeMode int
+
+const (
+	// DimmerTrailingEdge uses trailing-edge (reverse phase) dimming.
+	// Suitable for most LED drivers and electronic transformers.
+	DimmerTrailingEdge DimmerEdgeMode = 1
+
+	// DimmerLeadingEdge uses leading-edge (forward phase) dimming.
+	// Suitable for magnetic (wire-wound) transformers.
+	DimmerLeadingEdge DimmerEdgeMode = 2
+)
+
+// String returns the human readable edge mode name.
+func (m DimmerEdgeMode) String() string {
+	switch m {
+	case DimmerTrailingEdge:
+		return "trailing"
+	case DimmerLeadingEdge:
+		return "leading"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
+// Dimmer provides access to Shelly Dimmer / Dimmer 2 specific settings.
+//
+// On/off and brightness control for a dimmer go through the Light
+// component; Dimmer covers the load calibration, warm-up and phase-cut
+// edge settings that only dimmers have.
+type Dimmer struct {
+	transport transport.Transport
+	id        int
+}
+
+// NewDimmer creates a new Dimmer accessor.
+//
+// Parameters:
+//   - t: The transport to use for API calls
+//   - id: The light channel index (0 for Dimmer/Dimmer 2)
+func NewDimmer(t transport.Transport, id int) *Dimmer {
+	return &Dimmer{
+		transport: t,
+		id:        id,
+	}
+}
+
+// ID returns the light channel index.
+func (d *Dimmer) ID() int {
+	return d.id
+}
+
+// DimmerSettings contains dimmer-specific device settings.
+type DimmerSettings struct {
+	// Calibrated indicates whether load calibration has been completed.
+	Calibrated bool `json:"calibrated"`
+
+	// PulseMode is the phase-cut edge mode (see DimmerEdgeMode).
+	PulseMode DimmerEdgeMode `json:"pulse_mode,omitempty"`
+
+	// Transition is the default on/off transition time in milliseconds.
+	Transition int `json:"transition,omitempty"`
+
+	// FadeRate is the brightness change rate for button dimming (1-5).
+	FadeRate int `json:"fade_rate,omitempty"`
+
+	// MinBrightness is the lowest brightness used when dimming.
+	MinBrightness int `json:"min_brightness,omitempty"`
+
+	// ZeroCrossDebounce is the zero-cross detection debounce in microseconds.
+	ZeroCrossDebounce int `json:"zcross_debounce,omitempty"`
+}
+
+// DimmerWarmup contains warm-up settings for a dimmer channel.
+//
+// When enabled the dimmer turns on at Brightness for Time milliseconds
+// before settling at the requested level. This helps lamps that do not
+// start reliably at low brightness.
+type DimmerWarmup struct {
+	// Brightness is the warm-up brightness (0-100, 0 = disabled).
+	Brightness int `json:"warmup_brightness"`
+
+	// Time is the warm-up duration in milliseconds.
+	Time int `json:"warmup_time"`
+}
+
+// GetSettings retrieves dimmer-specific device settings.
+func (d *Dimmer) GetSettings(ctx context.Context) (*DimmerSettings, error) {
+	resp, err := restCall(ctx, d.transport, "/settings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dimmer settings: %w", err)
+	}
+
+	var settings DimmerSettings
+	if err := json.Unmarshal(resp, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse dimmer settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// IsCalibrated reports whether load calibration has been completed.
+func (d *Dimmer) IsCalibrated(ctx context.Context) (bool, error) {
+	settings, err := d.GetSettings(ctx)
+	if err != nil {
+		return false, err
+	}
+	return settings.Calibrated, nil
+}
+
+// Calibrate starts load calibration.
+//
+// The light will flicker for a few seconds while the dimmer measures the
+// connected load. Poll IsCalibrated to detect completion.
+func (d *Dimmer) Calibrate(ctx context.Context) error {
+	_, err := restCall(ctx, d.transport, "/calibrate")
+	if err != nil {
+		return fmt.Errorf("failed to start calibration: %w", err)
+	}
+	return nil
+}
+
+// GetEdgeMode returns the current phase-cut edge mode.
+func (d *Dimmer) GetEdgeMode(ctx context.Context) (DimmerEdgeMode, error) {
+	settings, err := d.GetSettings(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return settings.PulseMode, nil
+}
+
+// SetEdgeMode sets the phase-cut edge mode.
+//
+// Changing the edge mode invalidates the current calibration on most
+// firmware versions; run Calibrate afterwards.
+func (d *Dimmer) SetEdgeMode(ctx context.Context, mode DimmerEdgeMode) error {
+	if mode != DimmerTrailingEdge && mode != DimmerLeadingEdge {
+		return fmt.Errorf("invalid edge mode: %d", mode)
+	}
+
+	path := fmt.Sprintf("/settings?pulse_mode=%d", mode)
+	_, err := restCall(ctx, d.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to set edge mode: %w", err)
+	}
+	return nil
+}
+
+// SetFadeRate sets the brightness change rate used for button dimming.
+//
+// Parameters:
+//   - rate: Fade rate (1 = slowest, 5 = fastest)
+func (d *Dimmer) SetFadeRate(ctx context.Context, rate int) error {
+	if rate < 1 || rate > 5 {
+		return fmt.Errorf("fade rate must be 1-5, got %d", rate)
+	}
+
+	path := fmt.Sprintf("/settings?fade_rate=%d", rate)
+	_, err := restCall(ctx, d.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to set fade rate: %w", err)
+	}
+	return nil
+}
+
+// SetTransition sets the default on/off transition time.
+//
+// Parameters:
+//   - ms: Transition time in milliseconds (0-5000)
+func (d *Dimmer) SetTransition(ctx context.Context, ms int) error {
+	if ms < 0 || ms > 5000 {
+		return fmt.Errorf("transition must be 0-5000ms, got %d", ms)
+	}
+
+	path := fmt.Sprintf("/settings?transition=%d", ms)
+	_, err := restCall(ctx, d.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to set transition: %w", err)
+	}
+	return nil
+}
+
+// GetWarmup retrieves the warm-up settings for this channel.
+func (d *Dimmer) GetWarmup(ctx context.Context) (*DimmerWarmup, error) {
+	path := fmt.Sprintf("/settings/light/%d", d.id)
+	resp, err := restCall(ctx, d.transport, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get warmup settings: %w", err)
+	}
+
+	var warmup DimmerWarmup
+	if err := json.Unmarshal(resp, &warmup); err != nil {
+		return nil, fmt.Errorf("failed to parse warmup settings: %w", err)
+	}
+
+	return &warmup, nil
+}
+
+// SetWarmup configures warm-up for this channel.
+//
+// Parameters:
+//   - brightness: Warm-up brightness (0-100, 0 disables warm-up)
+//   - timeMs: Warm-up duration in milliseconds
+func (d *Dimmer) SetWarmup(ctx context.Context, brightness, timeMs int) error {
+	if brightness < 0 || brightness > 100 {
+		return fmt.Errorf("warmup brightness must be 0-100, got %d", brightness)
+	}
+	if timeMs < 0 {
+		return fmt.Errorf("warmup time must not be negative, got %d", timeMs)
+	}
+
+	path := fmt.Sprintf("/settings/light/%d?warmup_brightness=%d&warmup_time=%d", d.id, brightness, timeMs)
+	_, err := restCall(ctx, d.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to set warmup: %w", err)
+	}
+	return nil
+}
+
+// DisableWarmup turns warm-up off for this channel.
+func (d *Dimmer) DisableWarmup(ctx context.Context) error {
+	return d.SetWarmup(ctx, 0, 0)
+}
@@ -0,0 +1,203 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestNewDimmer tests dimmer creation.
+func TestNewDimmer(t *testing.T) {
+	mt := newMockTransport()
+	dimmer := NewDimmer(mt, 0)
+
+	if dimmer == nil {
+		t.Fatal("expected dimmer to be created")
+	}
+
+	if dimmer.ID() != 0 {
+		t.Errorf("expected ID 0, got %d", dimmer.ID())
+	}
+}
+
+// TestDimmerGetSettings tests dimmer settings retrieval.
+func TestDimmerGetSettings(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/settings", map[string]any{
+		"calibrated":      true,
+		"pulse_mode":      2,
+		"transition":      1000,
+		"fade_rate":       3,
+		"min_brightness":  5,
+		"zcross_debounce": 200,
+	})
+
+	dimmer := NewDimmer(mt, 0)
+	ctx := context.Background()
+
+	settings, err := dimmer.GetSettings(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !settings.Calibrated {
+		t.Error("expected calibrated")
+	}
+	if settings.PulseMode != DimmerLeadingEdge {
+		t.Errorf("expected leading edge, got %v", settings.PulseMode)
+	}
+	if settings.Transition != 1000 || settings.FadeRate != 3 || settings.ZeroCrossDebounce != 200 {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+
+	mode, err := dimmer.GetEdgeMode(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mode != DimmerLeadingEdge {
+		t.Errorf("expected leading edge, got %v", mode)
+	}
+
+	calibrated, err := dimmer.IsCalibrated(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !calibrated {
+		t.Error("expected calibrated")
+	}
+}
+
+// TestDimmerCalibrate tests starting calibration.
+func TestDimmerCalibrate(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/calibrate", map[string]any{})
+
+	dimmer := NewDimmer(mt, 0)
+	if err := dimmer.Calibrate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestDimmerSetEdgeMode tests edge mode control.
+func TestDimmerSetEdgeMode(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/settings?pulse_mode=1", map[string]any{})
+	mt.SetResponse("/settings?pulse_mode=2", map[string]any{})
+
+	dimmer := NewDimmer(mt, 0)
+	ctx := context.Background()
+
+	if err := dimmer.SetEdgeMode(ctx, DimmerTrailingEdge); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dimmer.SetEdgeMode(ctx, DimmerLeadingEdge); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dimmer.SetEdgeMode(ctx, DimmerEdgeMode(7)); err == nil {
+		t.Error("expected error for invalid edge mode")
+	}
+}
+
+// TestDimmerEdgeModeString tests edge mode names.
+func TestDimmerEdgeModeString(t *testing.T) {
+	tests := []struct {
+		want string
+		mode DimmerEdgeMode
+	}{
+		{"trailing", DimmerTrailingEdge},
+		{"leading", DimmerLeadingEdge},
+		{"unknown(0)", DimmerEdgeMode(0)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.mode.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+// TestDimmerSetFadeRateAndTransition tests fade rate and transition control.
+func TestDimmerSetFadeRateAndTransition(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/settings?fade_rate=4", map[string]any{})
+	mt.SetResponse("/settings?transition=2500", map[string]any{})
+
+	dimmer := NewDimmer(mt, 0)
+	ctx := context.Background()
+
+	if err := dimmer.SetFadeRate(ctx, 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dimmer.SetFadeRate(ctx, 0); err == nil {
+		t.Error("expected error for fade rate 0")
+	}
+	if err := dimmer.SetTransition(ctx, 2500); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dimmer.SetTransition(ctx, 6000); err == nil {
+		t.Error("expected error for transition 6000")
+	}
+}
+
+// TestDimmerWarmup tests warm-up control.
+func TestDimmerWarmup(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/settings/light/0", &DimmerWarmup{Brightness: 40, Time: 500})
+	mt.SetResponse("/settings/light/0?warmup_brightness=40&warmup_time=500", map[string]any{})
+	mt.SetResponse("/settings/light/0?warmup_brightness=0&warmup_time=0", map[string]any{})
+
+	dimmer := NewDimmer(mt, 0)
+	ctx := context.Background()
+
+	warmup, err := dimmer.GetWarmup(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if warmup.Brightness != 40 || warmup.Time != 500 {
+		t.Errorf("unexpected warmup: %+v", warmup)
+	}
+
+	if err := dimmer.SetWarmup(ctx, 40, 500); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dimmer.DisableWarmup(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dimmer.SetWarmup(ctx, 101, 500); err == nil {
+		t.Error("expected error for brightness 101")
+	}
+	if err := dimmer.SetWarmup(ctx, 50, -1); err == nil {
+		t.Error("expected error for negative time")
+	}
+}
+
+// TestDimmerErrors tests dimmer error handling.
+func TestDimmerErrors(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetError("/settings", errors.New("offline"))
+	mt.SetError("/calibrate", errors.New("offline"))
+	mt.SetError("/settings/light/0", errors.New("offline"))
+	mt.SetError("/settings?pulse_mode=1", errors.New("offline"))
+
+	dimmer := NewDimmer(mt, 0)
+	ctx := context.Background()
+
+	if _, err := dimmer.GetSettings(ctx); err == nil {
+		t.Error("expected GetSettings error")
+	}
+	if _, err := dimmer.IsCalibrated(ctx); err == nil {
+		t.Error("expected IsCalibrated error")
+	}
+	if _, err := dimmer.GetEdgeMode(ctx); err == nil {
+		t.Error("expected GetEdgeMode error")
+	}
+	if err := dimmer.Calibrate(ctx); err == nil {
+		t.Error("expected Calibrate error")
+	}
+	if _, err := dimmer.GetWarmup(ctx); err == nil {
+		t.Error("expected GetWarmup error")
+	}
+	if err := dimmer.SetEdgeMode(ctx, DimmerTrailingEdge); err == nil {
+		t.Error("expected SetEdgeMode error")
+	}
+}
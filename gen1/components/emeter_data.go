@@ -0,0 +1,303 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gen2components "github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// EMeterCSVTimeLayout is the timestamp layout used in the first column of
+// Gen1 em_data.csv files. Timestamps are always UTC.
+const EMeterCSVTimeLayout = "2006-01-02 15:04"
+
+// EMeterCSVPeriod is the interval in seconds covered by each em_data.csv row.
+const EMeterCSVPeriod = 60
+
+// ErrEMeterCSVEmpty is returned when an em_data.csv file has no data rows.
+var ErrEMeterCSVEmpty = errors.New("emeter csv contains no data rows")
+
+// EMeterCSVRecord is a single row of Gen1 em_data.csv history.
+//
+// Each row covers one minute. Energy values are the energy measured during
+// that minute, not running totals.
+type EMeterCSVRecord struct {
+	// Time is the start of the interval (UTC).
+	Time time.Time `json:"time"`
+
+	// ActiveEnergy is consumed energy during the interval in watt-hours.
+	ActiveEnergy float64 `json:"active_energy"`
+
+	// ReturnedEnergy is returned energy during the interval in watt-hours.
+	ReturnedEnergy float64 `json:"returned_energy"`
+
+	// MinVoltage is the lowest voltage seen during the interval.
+	MinVoltage float64 `json:"min_voltage"`
+
+	// MaxVoltage is the highest voltage seen during the interval.
+	MaxVoltage float64 `json:"max_voltage"`
+}
+
+// AveragePower returns the average net active power over the interval in
+// watts. Negative values indicate power being returned to the grid.
+func (r *EMeterCSVRecord) AveragePower() float64 {
+	return (r.ActiveEnergy - r.ReturnedEnergy) * 3600.0 / EMeterCSVPeriod
+}
+
+// AverageVoltage returns the midpoint of the interval's voltage range.
+func (r *EMeterCSVRecord) AverageVoltage() float64 {
+	return (r.MinVoltage + r.MaxVoltage) / 2
+}
+
+// GetDataCSV downloads the raw em_data.csv history for this emeter.
+//
+// Shelly EM and 3EM devices keep per-minute energy history in flash and
+// serve it as CSV. Use ParseEMeterCSV to decode the result, or GetHistory
+// to do both in one step.
+func (e *EMeter) GetDataCSV(ctx context.Context) ([]byte, error) {
+	path := fmt.Sprintf("/emeter/%d/em_data.csv", e.id)
+	resp, err := restCall(ctx, e.transport, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emeter csv: %w", err)
+	}
+	return resp, nil
+}
+
+// GetHistory downloads and parses the em_data.csv history for this emeter.
+func (e *EMeter) GetHistory(ctx context.Context) ([]EMeterCSVRecord, error) {
+	data, err := e.GetDataCSV(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := ParseEMeterCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse emeter csv: %w", err)
+	}
+	return records, nil
+}
+
+// ParseEMeterCSV parses Gen1 em_data.csv content.
+//
+// The expected header is:
+//
+//	Date/time UTC,Active energy Wh,Returned energy Wh,Min V,Max V
+//
+// Columns are matched by header name so files with reordered or extra
+// columns still parse. Files without a header are read positionally.
+// Rows are returned in file order.
+func ParseEMeterCSV(r io.Reader) ([]EMeterCSVRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cols := emeterCSVColumns{timeCol: 0, activeCol: 1, returnedCol: 2, minVCol: 3, maxVCol: 4}
+	if len(rows) > 0 && !isEMeterCSVTimestamp(rows[0][0]) {
+		cols = parseEMeterCSVHeader(rows[0])
+		rows = rows[1:]
+	}
+
+	records := make([]EMeterCSVRecord, 0, len(rows))
+	for i, row := range rows {
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		rec, err := cols.parseRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) == 0 {
+		return nil, ErrEMeterCSVEmpty
+	}
+	return records, nil
+}
+
+// emeterCSVColumns maps em_data.csv fields to column indexes (-1 = absent).
+type emeterCSVColumns struct {
+	timeCol     int
+	activeCol   int
+	returnedCol int
+	minVCol     int
+	maxVCol     int
+}
+
+func parseEMeterCSVHeader(header []string) emeterCSVColumns {
+	cols := emeterCSVColumns{timeCol: -1, activeCol: -1, returnedCol: -1, minVCol: -1, maxVCol: -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case strings.Contains(name, "date") || strings.Contains(name, "time"):
+			cols.timeCol = i
+		case strings.Contains(name, "returned"):
+			cols.returnedCol = i
+		case strings.Contains(name, "active") || strings.Contains(name, "energy"):
+			cols.activeCol = i
+		case strings.HasPrefix(name, "min"):
+			cols.minVCol = i
+		case strings.HasPrefix(name, "max"):
+			cols.maxVCol = i
+		}
+	}
+	return cols
+}
+
+func (c emeterCSVColumns) parseRow(row []string) (EMeterCSVRecord, error) {
+	var rec EMeterCSVRecord
+
+	if c.timeCol < 0 || c.timeCol >= len(row) {
+		return rec, errors.New("missing timestamp column")
+	}
+	ts, err := time.ParseInLocation(EMeterCSVTimeLayout, strings.TrimSpace(row[c.timeCol]), time.UTC)
+	if err != nil {
+		return rec, fmt.Errorf("invalid timestamp %q: %w", row[c.timeCol], err)
+	}
+	rec.Time = ts
+
+	fields := []struct {
+		dst *float64
+		col int
+	}{
+		{&rec.ActiveEnergy, c.activeCol},
+		{&rec.ReturnedEnergy, c.returnedCol},
+		{&rec.MinVoltage, c.minVCol},
+		{&rec.MaxVoltage, c.maxVCol},
+	}
+	for _, f := range fields {
+		if f.col < 0 || f.col >= len(row) {
+			continue
+		}
+		s := strings.TrimSpace(row[f.col])
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return rec, fmt.Errorf("invalid value %q: %w", s, err)
+		}
+		*f.dst = v
+	}
+
+	return rec, nil
+}
+
+func isEMeterCSVTimestamp(s string) bool {
+	_, err := time.Parse(EMeterCSVTimeLayout, strings.TrimSpace(s))
+	return err == nil
+}
+
+// EMeterHistoryToEMData converts Gen1 em_data.csv history into the record
+// shape returned by the Gen2 EMData.GetData RPC.
+//
+// Each argument is the history of one emeter channel; the first maps to
+// phase A, the second to phase B and the third to phase C. Rows are aligned
+// by timestamp. Consecutive minutes are grouped into a single block, and a
+// gap in the history starts a new block, mirroring how Gen2 devices report
+// interrupted recordings.
+//
+// Gen1 history does not include current, so current fields are left at zero.
+// Active power is the average net power over each minute and voltage is the
+// midpoint of the recorded min/max range.
+//
+// Example:
+//
+//	phaseA, _ := device.EMeter(0).GetHistory(ctx)
+//	phaseB, _ := device.EMeter(1).GetHistory(ctx)
+//	phaseC, _ := device.EMeter(2).GetHistory(ctx)
+//	data := components.EMeterHistoryToEMData(phaseA, phaseB, phaseC)
+func EMeterHistoryToEMData(phases ...[]EMeterCSVRecord) *gen2components.EMDataGetDataResult {
+	if len(phases) > 3 {
+		phases = phases[:3]
+	}
+
+	byTime := make(map[int64]*gen2components.EMDataValues)
+	var timestamps []int64
+
+	for phase, records := range phases {
+		for i := range records {
+			rec := &records[i]
+			ts := rec.Time.Unix()
+			values, ok := byTime[ts]
+			if !ok {
+				values = &gen2components.EMDataValues{}
+				byTime[ts] = values
+				timestamps = append(timestamps, ts)
+			}
+			applyEMeterPhase(values, phase, rec)
+		}
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	result := &gen2components.EMDataGetDataResult{
+		Data: []gen2components.EMDataBlock{},
+		Keys: emDataKeys,
+	}
+
+	var block *gen2components.EMDataBlock
+	var lastTS int64
+	for _, ts := range timestamps {
+		if block == nil || ts-lastTS != EMeterCSVPeriod {
+			result.Data = append(result.Data, gen2components.EMDataBlock{
+				TS:     ts,
+				Period: EMeterCSVPeriod,
+			})
+			block = &result.Data[len(result.Data)-1]
+		}
+		block.Values = append(block.Values, *byTime[ts])
+		lastTS = ts
+	}
+
+	return result
+}
+
+// emDataKeys lists the populated EMData value keys in Gen2 order.
+var emDataKeys = []string{
+	"a_voltage", "a_act_power", "b_voltage", "b_act_power",
+	"c_voltage", "c_act_power", "total_act_power",
+	"total_act_energy", "total_act_ret_energy",
+}
+
+func applyEMeterPhase(v *gen2components.EMDataValues, phase int, rec *EMeterCSVRecord) {
+	power := rec.AveragePower()
+	voltage := rec.AverageVoltage()
+
+	switch phase {
+	case 0:
+		v.AVoltage = voltage
+		v.AActivePower = power
+	case 1:
+		v.BVoltage = voltage
+		v.BActivePower = power
+	case 2:
+		v.CVoltage = voltage
+		v.CActivePower = power
+	}
+	v.TotalActivePower += power
+
+	act := rec.ActiveEnergy
+	ret := rec.ReturnedEnergy
+	if v.TotalActEnergy != nil {
+		act += *v.TotalActEnergy
+	}
+	if v.TotalActRetEnergy != nil {
+		ret += *v.TotalActRetEnergy
+	}
+	v.TotalActEnergy = &act
+	v.TotalActRetEnergy = &ret
+}
@@ -0,0 +1,208 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testEMeterCSV = `Date/time UTC,Active energy Wh,Returned energy Wh,Min V,Max V
+2024-01-15 10:00,5.000,0.000,229.0,231.0
+2024-01-15 10:01,6.000,1.000,228.0,232.0
+2024-01-15 10:03,2.500,0.000,230.0,230.0
+`
+
+// TestParseEMeterCSV tests parsing em_data.csv with a header.
+func TestParseEMeterCSV(t *testing.T) {
+	records, err := ParseEMeterCSV(strings.NewReader(testEMeterCSV))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	want := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+	if !records[1].Time.Equal(want) {
+		t.Errorf("expected time %v, got %v", want, records[1].Time)
+	}
+	if records[1].ActiveEnergy != 6 {
+		t.Errorf("expected active energy 6, got %f", records[1].ActiveEnergy)
+	}
+	if records[1].ReturnedEnergy != 1 {
+		t.Errorf("expected returned energy 1, got %f", records[1].ReturnedEnergy)
+	}
+	if records[1].MinVoltage != 228 || records[1].MaxVoltage != 232 {
+		t.Errorf("unexpected voltage range %f-%f", records[1].MinVoltage, records[1].MaxVoltage)
+	}
+	if got := records[1].AveragePower(); got != 300 {
+		t.Errorf("expected average power 300, got %f", got)
+	}
+	if got := records[1].AverageVoltage(); got != 230 {
+		t.Errorf("expected average voltage 230, got %f", got)
+	}
+}
+
+// TestParseEMeterCSVReorderedColumns tests header-based column matching.
+func TestParseEMeterCSVReorderedColumns(t *testing.T) {
+	data := "Max V,Min V,Returned energy Wh,Active energy Wh,Date/time UTC\n240,220,0.5,3.0,2024-01-15 10:00\n"
+
+	records, err := ParseEMeterCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := records[0]
+	if rec.ActiveEnergy != 3 || rec.ReturnedEnergy != 0.5 || rec.MinVoltage != 220 || rec.MaxVoltage != 240 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+// TestParseEMeterCSVNoHeader tests positional parsing.
+func TestParseEMeterCSVNoHeader(t *testing.T) {
+	records, err := ParseEMeterCSV(strings.NewReader("2024-01-15 10:00,1.5,0,229,231\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(records) != 1 || records[0].ActiveEnergy != 1.5 {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
+
+// TestParseEMeterCSVErrors tests invalid CSV handling.
+func TestParseEMeterCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"header only", "Date/time UTC,Active energy Wh,Returned energy Wh,Min V,Max V\n"},
+		{"bad timestamp", "Date/time UTC,Active energy Wh\nyesterday,1.0\n"},
+		{"bad value", "2024-01-15 10:00,abc,0,229,231\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseEMeterCSV(strings.NewReader(tt.data)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if _, err := ParseEMeterCSV(strings.NewReader("")); !errors.Is(err, ErrEMeterCSVEmpty) {
+		t.Errorf("expected ErrEMeterCSVEmpty, got %v", err)
+	}
+}
+
+// TestEMeterGetHistory tests downloading and parsing em_data.csv.
+func TestEMeterGetHistory(t *testing.T) {
+	mt := newMockTransport()
+	mt.responses["/emeter/1/em_data.csv"] = json.RawMessage(testEMeterCSV)
+
+	emeter := NewEMeter(mt, 1)
+	ctx := context.Background()
+
+	records, err := emeter.GetHistory(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+
+	calls := mt.GetCalls()
+	if len(calls) != 1 || calls[0] != "/emeter/1/em_data.csv" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+// TestEMeterGetHistoryError tests GetHistory error handling.
+func TestEMeterGetHistoryError(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetError("/emeter/0/em_data.csv", errors.New("offline"))
+
+	emeter := NewEMeter(mt, 0)
+	ctx := context.Background()
+
+	if _, err := emeter.GetHistory(ctx); err == nil {
+		t.Fatal("expected error")
+	}
+
+	mt.responses["/emeter/0/em_data.csv"] = json.RawMessage("garbage\n")
+	delete(mt.errors, "/emeter/0/em_data.csv")
+	if _, err := emeter.GetHistory(ctx); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
+
+// TestEMeterHistoryToEMData tests conversion to the Gen2 EMData shape.
+func TestEMeterHistoryToEMData(t *testing.T) {
+	phaseA, err := ParseEMeterCSV(strings.NewReader(testEMeterCSV))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	phaseB := []EMeterCSVRecord{
+		{Time: phaseA[0].Time, ActiveEnergy: 1, MinVoltage: 230, MaxVoltage: 230},
+	}
+
+	result := EMeterHistoryToEMData(phaseA, phaseB)
+
+	// 10:00-10:01 are contiguous, 10:03 follows a gap.
+	if len(result.Data) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(result.Data))
+	}
+
+	first := result.Data[0]
+	if first.Period != EMeterCSVPeriod {
+		t.Errorf("expected period %d, got %d", EMeterCSVPeriod, first.Period)
+	}
+	if first.TS != phaseA[0].Time.Unix() {
+		t.Errorf("expected ts %d, got %d", phaseA[0].Time.Unix(), first.TS)
+	}
+	if len(first.Values) != 2 {
+		t.Fatalf("expected 2 values in first block, got %d", len(first.Values))
+	}
+
+	v := first.Values[0]
+	if v.AActivePower != 300 {
+		t.Errorf("expected a_act_power 300, got %f", v.AActivePower)
+	}
+	if v.BActivePower != 60 {
+		t.Errorf("expected b_act_power 60, got %f", v.BActivePower)
+	}
+	if v.TotalActivePower != 360 {
+		t.Errorf("expected total_act_power 360, got %f", v.TotalActivePower)
+	}
+	if v.AVoltage != 230 || v.BVoltage != 230 {
+		t.Errorf("unexpected voltages a=%f b=%f", v.AVoltage, v.BVoltage)
+	}
+	if v.TotalActEnergy == nil || *v.TotalActEnergy != 6 {
+		t.Errorf("expected total_act_energy 6, got %v", v.TotalActEnergy)
+	}
+
+	second := first.Values[1]
+	if second.AActivePower != 300 {
+		t.Errorf("expected net a_act_power 300 for returned-energy row, got %f", second.AActivePower)
+	}
+	if second.TotalActRetEnergy == nil || *second.TotalActRetEnergy != 1 {
+		t.Errorf("expected total_act_ret_energy 1, got %v", second.TotalActRetEnergy)
+	}
+
+	if len(result.Data[1].Values) != 1 {
+		t.Errorf("expected 1 value in second block, got %d", len(result.Data[1].Values))
+	}
+}
+
+// TestEMeterHistoryToEMDataEmpty tests conversion of empty history.
+func TestEMeterHistoryToEMDataEmpty(t *testing.T) {
+	result := EMeterHistoryToEMData()
+	if result == nil || len(result.Data) != 0 {
+		t.Errorf("expected empty result, got %+v", result)
+	}
+}
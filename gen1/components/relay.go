@@ -323,6 +323,27 @@ func (r *Relay) AddScheduleRule(ctx context.Context, rule string) error {
 	return nil
 }
 
+// SetScheduleRules replaces all schedule rules in a single request.
+//
+// Parameters:
+//   - rules: Schedule rules in the format accepted by AddScheduleRule
+func (r *Relay) SetScheduleRules(ctx context.Context, rules []string) error {
+	if rules == nil {
+		rules = []string{}
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rules: %w", err)
+	}
+
+	path := fmt.Sprintf("/settings/relay/%d?schedule_rules=%s", r.id, string(rulesJSON))
+	_, err = restCall(ctx, r.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to set schedule rules: %w", err)
+	}
+	return nil
+}
+
 // ClearScheduleRules removes all schedule rules.
 func (r *Relay) ClearScheduleRules(ctx context.Context) error {
 	path := fmt.Sprintf("/settings/relay/%d?schedule_rules=[]", r.id)
@@ -453,6 +453,23 @@ func TestRelayClearScheduleRules(t *testing.T) {
 	}
 }
 
+// TestRelaySetScheduleRules tests replacing schedule rules.
+func TestRelaySetScheduleRules(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/settings/relay/1?schedule_rules=[\"0200-2-on\",\"0500-2-off\"]", map[string]bool{"ok": true})
+	mt.SetResponse("/settings/relay/1?schedule_rules=[]", map[string]bool{"ok": true})
+
+	relay := NewRelay(mt, 1)
+	ctx := context.Background()
+
+	if err := relay.SetScheduleRules(ctx, []string{"0200-2-on", "0500-2-off"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := relay.SetScheduleRules(ctx, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // TestRelayMultipleIDs tests relays with different IDs.
 func TestRelayMultipleIDs(t *testing.T) {
 	mt := newMockTransport()
@@ -202,6 +202,32 @@ func (w *White) TurnOnWithColorTemp(ctx context.Context, temp, brightness int) e
 	return nil
 }
 
+// TurnOnWithTransition turns on with a specific color temperature and
+// brightness, fading over the transition time.
+//
+// Parameters:
+//   - temp: Color temperature in Kelvin (0 keeps the current temperature)
+//   - brightness: Brightness level (0-100)
+//   - transitionMs: Transition time in milliseconds (0-5000)
+func (w *White) TurnOnWithTransition(ctx context.Context, temp, brightness, transitionMs int) error {
+	if brightness < 0 || brightness > 100 {
+		return fmt.Errorf("brightness must be 0-100, got %d", brightness)
+	}
+	if transitionMs < 0 || transitionMs > 5000 {
+		return fmt.Errorf("transition must be 0-5000 ms, got %d", transitionMs)
+	}
+
+	path := fmt.Sprintf("/white/%d?turn=on&brightness=%d&transition=%d", w.id, brightness, transitionMs)
+	if temp > 0 {
+		path += fmt.Sprintf("&temp=%d", temp)
+	}
+	_, err := restCall(ctx, w.transport, path)
+	if err != nil {
+		return fmt.Errorf("failed to turn on with transition: %w", err)
+	}
+	return nil
+}
+
 // TurnOnForDuration turns the white channel on for a specified duration.
 //
 // Parameters:
@@ -227,6 +227,28 @@ func TestWhiteTurnOnWithColorTempInvalid(t *testing.T) {
 	}
 }
 
+func TestWhiteTurnOnWithTransition(t *testing.T) {
+	mt := newMockTransport()
+	mt.SetResponse("/white/0?turn=on&brightness=60&transition=1500&temp=2700", map[string]bool{"ison": true})
+	mt.SetResponse("/white/0?turn=on&brightness=20&transition=0", map[string]bool{"ison": true})
+
+	white := NewWhite(mt, 0)
+	ctx := context.Background()
+
+	if err := white.TurnOnWithTransition(ctx, 2700, 60, 1500); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := white.TurnOnWithTransition(ctx, 0, 20, 0); err != nil {
+		t.Fatalf("unexpected error without temp: %v", err)
+	}
+	if err := white.TurnOnWithTransition(ctx, 2700, 101, 0); err == nil {
+		t.Error("expected error for brightness 101")
+	}
+	if err := white.TurnOnWithTransition(ctx, 2700, 50, 6000); err == nil {
+		t.Error("expected error for transition 6000")
+	}
+}
+
 // TestWhiteTurnOnForDuration tests timed on.
 func TestWhiteTurnOnForDuration(t *testing.T) {
 	mt := newMockTransport()
@@ -195,6 +195,19 @@ func (d *Device) Light(id int) *components.Light {
 	return components.NewLight(d.transport, id)
 }
 
+// Dimmer returns a Dimmer settings accessor for Shelly Dimmer / Dimmer 2.
+//
+// Parameters:
+//   - id: Light index (0-based)
+//
+// Example:
+//
+//	dimmer := device.Dimmer(0)
+//	err := dimmer.SetEdgeMode(ctx, components.DimmerLeadingEdge)
+func (d *Device) Dimmer(id int) *components.Dimmer {
+	return components.NewDimmer(d.transport, id)
+}
+
 // Color returns a Color (RGBW) component accessor.
 //
 // Parameters:
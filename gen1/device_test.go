@@ -552,6 +552,15 @@ func TestComponentAccessors(t *testing.T) {
 		t.Errorf("expected light ID 0, got %d", light.ID())
 	}
 
+	// Test Dimmer accessor
+	dimmer := device.Dimmer(0)
+	if dimmer == nil {
+		t.Error("expected dimmer accessor")
+	}
+	if dimmer.ID() != 0 {
+		t.Errorf("expected dimmer ID 0, got %d", dimmer.ID())
+	}
+
 	// Test Color accessor
 	color := device.Color(0)
 	if color == nil {
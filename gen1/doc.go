@@ -38,6 +38,8 @@
 //   - /white/{id} - White channel control
 //   - /meter/{id} - Power meter readings
 //   - /emeter/{id} - Energy meter readings
+//   - /emeter/{id}/em_data.csv - Per-minute energy history (EM, 3EM)
+//   - /calibrate - Load calibration (Dimmer, Dimmer 2)
 //
 // # Usage
 //
@@ -79,10 +79,13 @@ type EM1DataConfig struct {
 // EM1DataStatus represents the current status of an EM1Data component.
 //
 // It contains information about the data collection state, including the
-// last record ID and total number of available records.
+// last record ID and total number of available records, and the running
+// energy counters in watt-hours.
 type EM1DataStatus struct {
-	LastRecordID     *int `json:"last_record_id,omitempty"`
-	AvailableRecords *int `json:"available_records,omitempty"`
+	LastRecordID      *int     `json:"last_record_id,omitempty"`
+	AvailableRecords  *int     `json:"available_records,omitempty"`
+	TotalActEnergy    *float64 `json:"total_act_energy,omitempty"`
+	TotalActRetEnergy *float64 `json:"total_act_ret_energy,omitempty"`
 	types.RawFields
 	Errors []string `json:"errors,omitempty"`
 	ID     int      `json:"id"`
@@ -63,10 +63,19 @@ func NewEMData(client *rpc.Client, id int) *EMData {
 // EMDataStatus represents the current status of an EMData component.
 //
 // It contains information about the data collection state, including the
-// last record ID and total number of available records.
+// last record ID and total number of available records, and the running
+// energy counters in watt-hours.
 type EMDataStatus struct {
-	LastRecordID     *int `json:"last_record_id,omitempty"`
-	AvailableRecords *int `json:"available_records,omitempty"`
+	LastRecordID       *int     `json:"last_record_id,omitempty"`
+	AvailableRecords   *int     `json:"available_records,omitempty"`
+	ATotalActEnergy    *float64 `json:"a_total_act_energy,omitempty"`
+	ATotalActRetEnergy *float64 `json:"a_total_act_ret_energy,omitempty"`
+	BTotalActEnergy    *float64 `json:"b_total_act_energy,omitempty"`
+	BTotalActRetEnergy *float64 `json:"b_total_act_ret_energy,omitempty"`
+	CTotalActEnergy    *float64 `json:"c_total_act_energy,omitempty"`
+	CTotalActRetEnergy *float64 `json:"c_total_act_ret_energy,omitempty"`
+	TotalAct           *float64 `json:"total_act,omitempty"`
+	TotalActRet        *float64 `json:"total_act_ret,omitempty"`
 	types.RawFields
 	Errors []string `json:"errors,omitempty"`
 	ID     int      `json:"id"`
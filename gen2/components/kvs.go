@@ -23,6 +23,7 @@ const kvsComponentType = "kvs"
 //   - Value size: up to 256 bytes (strings)
 //
 // Note: KVS is commonly used by scripts to persist state between reboots.
+// For typed values, namespaces and etag-safe updates, see KVSStore.
 //
 // Example:
 //
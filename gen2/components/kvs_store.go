@@ -0,0 +1,727 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/types"
+)
+
+const (
+	// KVSMaxKeyLength is the maximum KVS key length in bytes.
+	KVSMaxKeyLength = 42
+
+	// KVSDefaultMaxValueSize is the default maximum encoded value size in bytes.
+	KVSDefaultMaxValueSize = 256
+
+	// KVSNamespaceSeparator separates the namespace from the key name.
+	KVSNamespaceSeparator = ":"
+
+	// KVSDefaultMaxRetries is the default number of CompareAndSwap attempts.
+	KVSDefaultMaxRetries = 5
+
+	// KVSDefaultWatchInterval is the default polling interval for Watch.
+	KVSDefaultWatchInterval = 5 * time.Second
+
+	// KVSDefaultWatchEvent is the script event name that triggers an
+	// immediate Watch refresh, e.g. Shelly.emitEvent("kvs_changed", {}).
+	KVSDefaultWatchEvent = "kvs_changed"
+)
+
+// KVS store errors.
+var (
+	// ErrKVSConflict indicates the key was modified since its etag was read.
+	ErrKVSConflict = errors.New("kvs: etag mismatch")
+
+	// ErrKVSKeyTooLong indicates the namespaced key exceeds KVSMaxKeyLength.
+	ErrKVSKeyTooLong = errors.New("kvs: key too long")
+
+	// ErrKVSValueTooLarge indicates the encoded value exceeds the size limit.
+	ErrKVSValueTooLarge = errors.New("kvs: value too large")
+
+	// ErrKVSFull indicates the device has no free KVS entries.
+	ErrKVSFull = errors.New("kvs: entry limit reached")
+
+	// ErrKVSCodecType indicates a codec set with WithKVSCodec encodes a
+	// different type than the store's.
+	ErrKVSCodecType = errors.New("kvs: codec type mismatch")
+)
+
+// KVSCodec converts between typed values and KVS values.
+//
+// Encode returns a value that is sent as the "value" parameter of KVS.Set;
+// Decode receives the value as returned by KVS.Get or KVS.GetMany.
+type KVSCodec[T any] interface {
+	Encode(value T) (any, error)
+	Decode(value any) (T, error)
+}
+
+// JSONStringCodec stores values as JSON-encoded strings.
+//
+// Strings are accepted by every firmware version, so this is the default
+// codec. Scripts read the value with JSON.parse.
+type JSONStringCodec[T any] struct{}
+
+// Encode marshals value to a JSON string.
+func (JSONStringCodec[T]) Encode(value T) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Decode unmarshals a JSON string.
+func (JSONStringCodec[T]) Decode(value any) (T, error) {
+	var out T
+	s, ok := value.(string)
+	if !ok {
+		return out, fmt.Errorf("kvs: expected string value, got %T", value)
+	}
+	err := json.Unmarshal([]byte(s), &out)
+	return out, err
+}
+
+// JSONValueCodec stores values as native JSON values.
+//
+// Use it for scalars (numbers, booleans, strings) that scripts read
+// directly, or for objects on firmware that accepts them.
+type JSONValueCodec[T any] struct{}
+
+// Encode returns the value as raw JSON.
+func (JSONValueCodec[T]) Encode(value T) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+// Decode converts a decoded JSON value into T.
+func (JSONValueCodec[T]) Decode(value any) (T, error) {
+	var out T
+	data, err := json.Marshal(value)
+	if err != nil {
+		return out, err
+	}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// KVSStoreOption configures a KVSStore.
+type KVSStoreOption func(*kvsStoreConfig)
+
+type kvsStoreConfig struct {
+	codec        any
+	maxValueSize int
+	maxEntries   int
+	maxRetries   int
+}
+
+// WithKVSCodec sets the value codec. T must be the store's value type;
+// NewKVSStore returns ErrKVSCodecType otherwise.
+func WithKVSCodec[T any](codec KVSCodec[T]) KVSStoreOption {
+	return func(c *kvsStoreConfig) {
+		c.codec = codec
+	}
+}
+
+// WithKVSMaxValueSize sets the maximum encoded value size in bytes.
+func WithKVSMaxValueSize(size int) KVSStoreOption {
+	return func(c *kvsStoreConfig) {
+		c.maxValueSize = size
+	}
+}
+
+// WithKVSMaxEntries rejects new keys once the device holds this many
+// entries. Use profiles.Limits.MaxKVSEntries for the target device.
+func WithKVSMaxEntries(entries int) KVSStoreOption {
+	return func(c *kvsStoreConfig) {
+		c.maxEntries = entries
+	}
+}
+
+// WithKVSMaxRetries sets the number of CompareAndSwap attempts.
+func WithKVSMaxRetries(retries int) KVSStoreOption {
+	return func(c *kvsStoreConfig) {
+		if retries > 0 {
+			c.maxRetries = retries
+		}
+	}
+}
+
+// KVSEntry is a typed key-value pair.
+type KVSEntry[T any] struct {
+	Value T `json:"value"`
+
+	// Key is the key name without the namespace prefix.
+	Key string `json:"key"`
+
+	// Etag identifies the stored revision for SetIfMatch.
+	Etag string `json:"etag,omitempty"`
+}
+
+// KVSChangeType describes a change reported by Watch.
+type KVSChangeType string
+
+const (
+	// KVSChangeSet indicates a key was created or modified.
+	KVSChangeSet KVSChangeType = "set"
+
+	// KVSChangeDelete indicates a key was removed.
+	KVSChangeDelete KVSChangeType = "delete"
+)
+
+// KVSChange is a change observed by Watch.
+type KVSChange[T any] struct {
+	Value T
+
+	// Err is set when the new value could not be decoded.
+	Err error
+
+	// Type is the kind of change.
+	Type KVSChangeType
+
+	// Key is the key name without the namespace prefix.
+	Key string
+
+	// Etag is the new etag (empty for deletes).
+	Etag string
+}
+
+// KVSWatchOptions configures Watch.
+type KVSWatchOptions struct {
+	// Event is the script event name that triggers an immediate refresh.
+	// Defaults to KVSDefaultWatchEvent.
+	Event string
+
+	// Interval is the GetMany polling interval.
+	// Defaults to KVSDefaultWatchInterval.
+	Interval time.Duration
+
+	// DisablePolling only refreshes when the script event is received.
+	// The client must use a transport that delivers notifications.
+	DisablePolling bool
+}
+
+// KVSStore is a typed, namespaced view of the KVS component.
+//
+// Keys are stored on the device as "<namespace>:<name>" so several
+// applications and scripts can share one device without collisions.
+// Values are encoded with a KVSCodec (JSON strings by default) and checked
+// against the device limits before they are sent.
+//
+// Example:
+//
+//	type Config struct {
+//	    Target float64 `json:"target"`
+//	    Mode   string  `json:"mode"`
+//	}
+//
+//	store, err := components.NewKVSStore[Config](components.NewKVS(client), "thermo")
+//	if err != nil {
+//	    return err
+//	}
+//	_, err = store.CompareAndSwap(ctx, "config", func(cur Config, exists bool) (Config, error) {
+//	    cur.Target += 0.5
+//	    return cur, nil
+//	})
+type KVSStore[T any] struct {
+	kvs          *KVS
+	codec        KVSCodec[T]
+	watchers     map[*kvsWatcher]struct{}
+	namespace    string
+	maxValueSize int
+	maxEntries   int
+	maxRetries   int
+	watchMu      sync.Mutex
+	dispatching  bool
+}
+
+// kvsWatcher is an active Watch waiting for its script event.
+type kvsWatcher struct {
+	trigger chan struct{}
+	event   string
+}
+
+// NewKVSStore creates a typed store over kvs. An empty namespace uses
+// unprefixed keys. It fails with ErrKVSCodecType if the codec set with
+// WithKVSCodec is not a KVSCodec[T].
+func NewKVSStore[T any](kvs *KVS, namespace string, opts ...KVSStoreOption) (*KVSStore[T], error) {
+	cfg := &kvsStoreConfig{
+		maxValueSize: KVSDefaultMaxValueSize,
+		maxRetries:   KVSDefaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var codec KVSCodec[T] = JSONStringCodec[T]{}
+	if cfg.codec != nil {
+		c, ok := cfg.codec.(KVSCodec[T])
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrKVSCodecType, cfg.codec)
+		}
+		codec = c
+	}
+
+	return &KVSStore[T]{
+		kvs:          kvs,
+		codec:        codec,
+		namespace:    namespace,
+		maxValueSize: cfg.maxValueSize,
+		maxEntries:   cfg.maxEntries,
+		maxRetries:   cfg.maxRetries,
+	}, nil
+}
+
+// Namespace returns the store namespace.
+func (s *KVSStore[T]) Namespace() string {
+	return s.namespace
+}
+
+// Key returns the device key for name.
+func (s *KVSStore[T]) Key(name string) string {
+	if s.namespace == "" {
+		return name
+	}
+	return s.namespace + KVSNamespaceSeparator + name
+}
+
+// name strips the namespace prefix from a device key.
+func (s *KVSStore[T]) name(key string) (string, bool) {
+	if s.namespace == "" {
+		return key, true
+	}
+	return strings.CutPrefix(key, s.namespace+KVSNamespaceSeparator)
+}
+
+// pattern returns the GetMany match pattern for the namespace.
+func (s *KVSStore[T]) pattern() string {
+	if s.namespace == "" {
+		return "*"
+	}
+	return s.namespace + KVSNamespaceSeparator + "*"
+}
+
+// Get retrieves and decodes a value. A missing key returns an error
+// matching types.ErrNotFound.
+func (s *KVSStore[T]) Get(ctx context.Context, name string) (*KVSEntry[T], error) {
+	key, err := s.checkKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.kvs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.codec.Decode(resp.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return &KVSEntry[T]{Key: name, Value: value, Etag: resp.Etag}, nil
+}
+
+// Set stores a value unconditionally and returns the new etag.
+func (s *KVSStore[T]) Set(ctx context.Context, name string, value T) (string, error) {
+	key, encoded, err := s.prepare(ctx, name, value, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.kvs.Set(ctx, key, encoded)
+	if err != nil {
+		return "", err
+	}
+	return resp.Etag, nil
+}
+
+// SetIfMatch stores a value only if the key's current etag equals etag.
+// It returns ErrKVSConflict if the key was modified in the meantime.
+func (s *KVSStore[T]) SetIfMatch(ctx context.Context, name string, value T, etag string) (string, error) {
+	key, encoded, err := s.prepare(ctx, name, value, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.kvs.SetWithEtag(ctx, key, encoded, etag)
+	if err != nil {
+		if isKVSConflict(err) {
+			return "", fmt.Errorf("%w: %s", ErrKVSConflict, key)
+		}
+		return "", err
+	}
+	return resp.Etag, nil
+}
+
+// CompareAndSwap atomically updates a value with a read-modify-write loop.
+//
+// update receives the current value (the zero value and exists=false if
+// the key is missing) and returns the new value. The write uses
+// SetWithEtag, so if another client or script changes the key in between,
+// the value is re-read and update is called again, up to the configured
+// number of retries. ErrKVSConflict is returned when all attempts conflict.
+//
+// Creating a missing key cannot be made conditional on the device; if two
+// writers create the same key concurrently, the last write wins.
+func (s *KVSStore[T]) CompareAndSwap(
+	ctx context.Context, name string, update func(current T, exists bool) (T, error),
+) (*KVSEntry[T], error) {
+	for attempt := 0; attempt < s.maxRetries; attempt++ {
+		current, err := s.Get(ctx, name)
+		exists := err == nil
+		if err != nil && !errors.Is(err, types.ErrNotFound) {
+			return nil, err
+		}
+
+		var value T
+		if exists {
+			value = current.Value
+		}
+		next, err := update(value, exists)
+		if err != nil {
+			return nil, err
+		}
+
+		var etag string
+		if exists {
+			etag, err = s.SetIfMatch(ctx, name, next, current.Etag)
+		} else {
+			etag, err = s.Set(ctx, name, next)
+		}
+		if errors.Is(err, ErrKVSConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &KVSEntry[T]{Key: name, Value: next, Etag: etag}, nil
+	}
+	return nil, fmt.Errorf("%w: %s after %d attempts", ErrKVSConflict, s.Key(name), s.maxRetries)
+}
+
+// Delete removes a key.
+func (s *KVSStore[T]) Delete(ctx context.Context, name string) error {
+	key, err := s.checkKey(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.kvs.Delete(ctx, key)
+	return err
+}
+
+// Keys returns the key names in the namespace, sorted.
+func (s *KVSStore[T]) Keys(ctx context.Context) ([]string, error) {
+	resp, err := s.kvs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, key := range resp.Keys {
+		if name, ok := s.name(key); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// All retrieves every entry in the namespace, keyed by name.
+//
+// Entries that fail to decode are skipped and reported in the returned
+// error; the map still contains all decodable entries.
+func (s *KVSStore[T]) All(ctx context.Context) (map[string]KVSEntry[T], error) {
+	resp, err := s.kvs.GetMany(ctx, s.pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]KVSEntry[T], len(resp.Items))
+	var errs []error
+	for _, item := range resp.Items {
+		name, ok := s.name(item.Key)
+		if !ok {
+			continue
+		}
+		value, err := s.codec.Decode(item.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to decode %s: %w", item.Key, err))
+			continue
+		}
+		entries[name] = KVSEntry[T]{Key: name, Value: value, Etag: itemEtag(item)}
+	}
+	return entries, errors.Join(errs...)
+}
+
+// Watch reports changes to keys in the namespace, including changes made
+// by scripts on the device.
+//
+// Changes are detected by diffing GetMany snapshots. A snapshot is taken
+// every opts.Interval and whenever a script emits opts.Event
+// (Shelly.emitEvent("kvs_changed", {}) by default), so scripts can push
+// their updates without waiting for the next poll. The initial snapshot
+// is taken before Watch returns and is not reported.
+//
+// The channel is closed when ctx is canceled. Refresh errors are skipped;
+// the next refresh retries. All watches of a store share one NotifyEvent
+// handler on the client, so Watch may be called repeatedly.
+func (s *KVSStore[T]) Watch(ctx context.Context, opts *KVSWatchOptions) (<-chan KVSChange[T], error) {
+	if opts == nil {
+		opts = &KVSWatchOptions{}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = KVSDefaultWatchInterval
+	}
+	event := opts.Event
+	if event == "" {
+		event = KVSDefaultWatchEvent
+	}
+
+	prev, err := s.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &kvsWatcher{trigger: make(chan struct{}, 1), event: event}
+	s.addWatcher(w)
+
+	changes := make(chan KVSChange[T], 16)
+	go func() {
+		defer close(changes)
+		defer s.removeWatcher(w)
+
+		var tick <-chan time.Time
+		if !opts.DisablePolling {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			case <-w.trigger:
+			}
+
+			next, err := s.snapshot(ctx)
+			if err != nil {
+				continue
+			}
+			for _, change := range s.diff(prev, next) {
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+			prev = next
+		}
+	}()
+
+	return changes, nil
+}
+
+// addWatcher adds w to the watchers triggered by script events. The
+// store registers a single NotifyEvent handler on first use, so repeated
+// Watch calls don't add handlers to the client.
+func (s *KVSStore[T]) addWatcher(w *kvsWatcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if s.watchers == nil {
+		s.watchers = make(map[*kvsWatcher]struct{})
+	}
+	s.watchers[w] = struct{}{}
+	if !s.dispatching {
+		s.dispatching = true
+		s.kvs.Client().OnNotificationMethod("NotifyEvent", s.dispatch)
+	}
+}
+
+func (s *KVSStore[T]) removeWatcher(w *kvsWatcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	delete(s.watchers, w)
+}
+
+// dispatch triggers the watchers whose script event is in params.
+func (s *KVSStore[T]) dispatch(params json.RawMessage) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for w := range s.watchers {
+		if hasScriptEvent(params, w.event) {
+			select {
+			case w.trigger <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// snapshot returns the raw items in the namespace keyed by name.
+func (s *KVSStore[T]) snapshot(ctx context.Context) (map[string]KVSItem, error) {
+	resp, err := s.kvs.GetMany(ctx, s.pattern())
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]KVSItem, len(resp.Items))
+	for _, item := range resp.Items {
+		if name, ok := s.name(item.Key); ok {
+			items[name] = item
+		}
+	}
+	return items, nil
+}
+
+// diff compares two snapshots and returns the changes in key order.
+func (s *KVSStore[T]) diff(prev, next map[string]KVSItem) []KVSChange[T] {
+	var changes []KVSChange[T]
+	for name, item := range next {
+		old, existed := prev[name]
+		if existed && itemFingerprint(old) == itemFingerprint(item) {
+			continue
+		}
+		value, err := s.codec.Decode(item.Value)
+		changes = append(changes, KVSChange[T]{
+			Type: KVSChangeSet, Key: name, Value: value, Etag: itemEtag(item), Err: err,
+		})
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			changes = append(changes, KVSChange[T]{Type: KVSChangeDelete, Key: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// checkKey validates name and returns the device key.
+func (s *KVSStore[T]) checkKey(name string) (string, error) {
+	key := s.Key(name)
+	if name == "" || len(key) > KVSMaxKeyLength {
+		return "", fmt.Errorf("%w: %q (%d > %d bytes)", ErrKVSKeyTooLong, key, len(key), KVSMaxKeyLength)
+	}
+	return key, nil
+}
+
+// prepare validates and encodes a value for writing. checkEntries enables
+// the entry limit check for writes that may create a key.
+func (s *KVSStore[T]) prepare(ctx context.Context, name string, value T, checkEntries bool) (string, any, error) {
+	key, err := s.checkKey(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	encoded, err := s.codec.Encode(value)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if s.maxValueSize > 0 {
+		if size := encodedSize(encoded); size > s.maxValueSize {
+			return "", nil, fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrKVSValueTooLarge, key, size, s.maxValueSize)
+		}
+	}
+
+	if checkEntries && s.maxEntries > 0 {
+		list, err := s.kvs.List(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		exists := false
+		for _, k := range list.Keys {
+			if k == key {
+				exists = true
+				break
+			}
+		}
+		if !exists && len(list.Keys) >= s.maxEntries {
+			return "", nil, fmt.Errorf("%w: %d entries", ErrKVSFull, len(list.Keys))
+		}
+	}
+
+	return key, encoded, nil
+}
+
+// encodedSize returns the stored size of an encoded value: the length of
+// a string, or of the JSON encoding for anything else.
+func encodedSize(value any) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case json.RawMessage:
+		return len(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		return len(data)
+	}
+}
+
+// itemEtag returns the etag of a GetMany item, if present.
+func itemEtag(item KVSItem) string {
+	if item.Etag == nil {
+		return ""
+	}
+	return *item.Etag
+}
+
+// itemFingerprint identifies an item revision. Older firmware omits etags
+// from GetMany, so the encoded value is used as a fallback.
+func itemFingerprint(item KVSItem) string {
+	if etag := itemEtag(item); etag != "" {
+		return etag
+	}
+	data, err := json.Marshal(item.Value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// isKVSConflict reports whether a KVS.Set error is an etag mismatch.
+func isKVSConflict(err error) bool {
+	var rpcErr *rpc.ErrorObject
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return rpcErr.Code == types.ErrCodeFailedPrecondition ||
+		strings.Contains(strings.ToLower(rpcErr.Message), "etag")
+}
+
+// hasScriptEvent reports whether NotifyEvent params contain a script
+// event with the given name.
+func hasScriptEvent(params json.RawMessage, name string) bool {
+	var p struct {
+		Events []struct {
+			Component string `json:"component"`
+			Event     string `json:"event"`
+		} `json:"events"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return false
+	}
+	for _, e := range p.Events {
+		if e.Event == name && strings.HasPrefix(e.Component, "script:") {
+			return true
+		}
+	}
+	return false
+}
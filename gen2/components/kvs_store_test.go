@@ -0,0 +1,502 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+	"github.com/tj-smith47/shelly-go/types"
+)
+
+type kvsStoreTestConfig struct {
+	Mode   string  `json:"mode"`
+	Target float64 `json:"target"`
+}
+
+// fakeKVS simulates the KVS.* RPC methods of a device.
+type fakeKVS struct {
+	values map[string]json.RawMessage
+	etags  map[string]string
+	// beforeSet runs before each KVS.Set and can simulate concurrent writers.
+	beforeSet func()
+	calls     map[string]int
+	mu        sync.Mutex
+	rev       int
+}
+
+func newFakeKVS() *fakeKVS {
+	return &fakeKVS{
+		values: make(map[string]json.RawMessage),
+		etags:  make(map[string]string),
+		calls:  make(map[string]int),
+	}
+}
+
+func (f *fakeKVS) client() *rpc.Client {
+	return rpc.NewClient(&mockTransport{callFunc: f.call})
+}
+
+func (f *fakeKVS) put(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rev++
+	f.values[key] = json.RawMessage(value)
+	f.etags[key] = fmt.Sprintf("etag-%d", f.rev)
+}
+
+func (f *fakeKVS) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func kvsError(code int, msg string) (json.RawMessage, error) {
+	return json.RawMessage(fmt.Sprintf(`{"id":1,"error":{"code":%d,"message":%q}}`, code, msg)), nil
+}
+
+func (f *fakeKVS) call(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	var params struct {
+		Value json.RawMessage `json:"value"`
+		Etag  *string         `json:"etag"`
+		Key   string          `json:"key"`
+		Match string          `json:"match"`
+	}
+	if p := req.GetParams(); len(p) > 0 {
+		if err := json.Unmarshal(p, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	method := req.GetMethod()
+	if method == "KVS.Set" && f.beforeSet != nil {
+		f.beforeSet()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+
+	switch method {
+	case "KVS.Get":
+		value, ok := f.values[params.Key]
+		if !ok {
+			return kvsError(types.ErrCodeNotFound, "key not found")
+		}
+		return jsonrpcResponse(fmt.Sprintf(`{"value":%s,"etag":%q}`, value, f.etags[params.Key]))
+	case "KVS.Set":
+		if params.Etag != nil && f.etags[params.Key] != *params.Etag {
+			return kvsError(types.ErrCodeFailedPrecondition, "etag mismatch")
+		}
+		f.rev++
+		f.values[params.Key] = params.Value
+		f.etags[params.Key] = fmt.Sprintf("etag-%d", f.rev)
+		return jsonrpcResponse(fmt.Sprintf(`{"etag":%q,"rev":%d}`, f.etags[params.Key], f.rev))
+	case "KVS.Delete":
+		if _, ok := f.values[params.Key]; !ok {
+			return kvsError(types.ErrCodeNotFound, "key not found")
+		}
+		delete(f.values, params.Key)
+		delete(f.etags, params.Key)
+		f.rev++
+		return jsonrpcResponse(fmt.Sprintf(`{"rev":%d}`, f.rev))
+	case "KVS.List":
+		keys := f.sortedKeys()
+		data, _ := json.Marshal(keys)
+		return jsonrpcResponse(fmt.Sprintf(`{"keys":%s,"rev":%d}`, data, f.rev))
+	case "KVS.GetMany":
+		prefix := strings.TrimSuffix(params.Match, "*")
+		items := []string{}
+		for _, key := range f.sortedKeys() {
+			if strings.HasPrefix(key, prefix) {
+				items = append(items, fmt.Sprintf(`{"key":%q,"value":%s,"etag":%q}`, key, f.values[key], f.etags[key]))
+			}
+		}
+		return jsonrpcResponse(fmt.Sprintf(`{"items":[%s]}`, strings.Join(items, ",")))
+	}
+	return kvsError(types.ErrCodeMethodNotFound, "no handler for "+method)
+}
+
+func (f *fakeKVS) sortedKeys() []string {
+	keys := make([]string, 0, len(f.values))
+	for k := range f.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newTestKVSStore[T any](t *testing.T, kvs *KVS, namespace string, opts ...KVSStoreOption) *KVSStore[T] {
+	t.Helper()
+	store, err := NewKVSStore[T](kvs, namespace, opts...)
+	if err != nil {
+		t.Fatalf("NewKVSStore() error = %v", err)
+	}
+	return store
+}
+
+func TestNewKVSStore_CodecType(t *testing.T) {
+	_, err := NewKVSStore[string](NewKVS(newFakeKVS().client()), "app", WithKVSCodec(JSONValueCodec[int]{}))
+	if !errors.Is(err, ErrKVSCodecType) {
+		t.Errorf("NewKVSStore() error = %v, want ErrKVSCodecType", err)
+	}
+}
+
+func TestKVSStore_SetGet(t *testing.T) {
+	dev := newFakeKVS()
+	store := newTestKVSStore[kvsStoreTestConfig](t, NewKVS(dev.client()), "thermo")
+	ctx := context.Background()
+
+	want := kvsStoreTestConfig{Mode: "heat", Target: 21.5}
+	etag, err := store.Set(ctx, "config", want)
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if etag == "" {
+		t.Error("Set() returned empty etag")
+	}
+
+	raw := string(dev.values["thermo:config"])
+	if raw != `"{\"mode\":\"heat\",\"target\":21.5}"` {
+		t.Errorf("stored value = %s", raw)
+	}
+
+	got, err := store.Get(ctx, "config")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Value != want || got.Key != "config" || got.Etag != etag {
+		t.Errorf("Get() = %+v", got)
+	}
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKVSStore_ValueCodec(t *testing.T) {
+	dev := newFakeKVS()
+	store := newTestKVSStore[int](t, NewKVS(dev.client()), "", WithKVSCodec(JSONValueCodec[int]{}))
+	ctx := context.Background()
+
+	if _, err := store.Set(ctx, "counter", 42); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if string(dev.values["counter"]) != "42" {
+		t.Errorf("stored value = %s, want 42", dev.values["counter"])
+	}
+	got, err := store.Get(ctx, "counter")
+	if err != nil || got.Value != 42 {
+		t.Errorf("Get() = %+v, %v", got, err)
+	}
+}
+
+func TestKVSStore_Limits(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		wantErr error
+		store   func(c *rpc.Client) *KVSStore[string]
+		name    string
+		key     string
+		value   string
+	}{
+		{
+			name:    "key too long",
+			store:   func(c *rpc.Client) *KVSStore[string] { return newTestKVSStore[string](t, NewKVS(c), "app") },
+			key:     strings.Repeat("k", 40),
+			wantErr: ErrKVSKeyTooLong,
+		},
+		{
+			name:    "empty key",
+			store:   func(c *rpc.Client) *KVSStore[string] { return newTestKVSStore[string](t, NewKVS(c), "app") },
+			wantErr: ErrKVSKeyTooLong,
+		},
+		{
+			name: "value too large",
+			store: func(c *rpc.Client) *KVSStore[string] {
+				return newTestKVSStore[string](t, NewKVS(c), "app", WithKVSMaxValueSize(8))
+			},
+			key:     "k",
+			value:   "123456789",
+			wantErr: ErrKVSValueTooLarge,
+		},
+		{
+			name: "store full",
+			store: func(c *rpc.Client) *KVSStore[string] {
+				return newTestKVSStore[string](t, NewKVS(c), "app", WithKVSMaxEntries(2))
+			},
+			key:     "new",
+			wantErr: ErrKVSFull,
+		},
+		{
+			name: "overwrite when full",
+			store: func(c *rpc.Client) *KVSStore[string] {
+				return newTestKVSStore[string](t, NewKVS(c), "app", WithKVSMaxEntries(2))
+			},
+			key: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := newFakeKVS()
+			dev.put("app:a", `"x"`)
+			dev.put("other", `"y"`)
+
+			_, err := tt.store(dev.client()).Set(ctx, tt.key, tt.value)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Set() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Set() error = %v, want %v", err, tt.wantErr)
+			}
+			if dev.count("KVS.Set") != 0 {
+				t.Error("rejected value should not be sent")
+			}
+		})
+	}
+}
+
+func TestKVSStore_SetIfMatch(t *testing.T) {
+	dev := newFakeKVS()
+	dev.put("app:mode", `"\"eco\""`)
+	store := newTestKVSStore[string](t, NewKVS(dev.client()), "app")
+	ctx := context.Background()
+
+	current, err := store.Get(ctx, "mode")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if _, err := store.SetIfMatch(ctx, "mode", "comfort", "stale"); !errors.Is(err, ErrKVSConflict) {
+		t.Errorf("SetIfMatch(stale) error = %v, want ErrKVSConflict", err)
+	}
+	if _, err := store.SetIfMatch(ctx, "mode", "comfort", current.Etag); err != nil {
+		t.Errorf("SetIfMatch() error = %v", err)
+	}
+}
+
+func TestKVSStore_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates missing key", func(t *testing.T) {
+		dev := newFakeKVS()
+		store := newTestKVSStore[int](t, NewKVS(dev.client()), "app")
+
+		entry, err := store.CompareAndSwap(ctx, "count", func(cur int, exists bool) (int, error) {
+			if exists {
+				t.Error("exists = true for missing key")
+			}
+			return cur + 1, nil
+		})
+		if err != nil {
+			t.Fatalf("CompareAndSwap() error = %v", err)
+		}
+		if entry.Value != 1 {
+			t.Errorf("Value = %d, want 1", entry.Value)
+		}
+	})
+
+	t.Run("retries on conflict", func(t *testing.T) {
+		dev := newFakeKVS()
+		dev.put("app:count", `"10"`)
+		store := newTestKVSStore[int](t, NewKVS(dev.client()), "app")
+
+		// A script bumps the counter before our first write.
+		sets := 0
+		dev.beforeSet = func() {
+			sets++
+			if sets == 1 {
+				dev.put("app:count", `"20"`)
+			}
+		}
+
+		calls := 0
+		entry, err := store.CompareAndSwap(ctx, "count", func(cur int, _ bool) (int, error) {
+			calls++
+			return cur + 1, nil
+		})
+		if err != nil {
+			t.Fatalf("CompareAndSwap() error = %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("update called %d times, want 2", calls)
+		}
+		if entry.Value != 21 || string(dev.values["app:count"]) != `"21"` {
+			t.Errorf("Value = %d, stored %s, want 21", entry.Value, dev.values["app:count"])
+		}
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		dev := newFakeKVS()
+		dev.put("app:count", `"1"`)
+		dev.beforeSet = func() { dev.put("app:count", `"1"`) }
+		store := newTestKVSStore[int](t, NewKVS(dev.client()), "app", WithKVSMaxRetries(3))
+
+		_, err := store.CompareAndSwap(ctx, "count", func(cur int, _ bool) (int, error) {
+			return cur + 1, nil
+		})
+		if !errors.Is(err, ErrKVSConflict) {
+			t.Errorf("error = %v, want ErrKVSConflict", err)
+		}
+		if dev.count("KVS.Set") != 3 {
+			t.Errorf("KVS.Set calls = %d, want 3", dev.count("KVS.Set"))
+		}
+	})
+
+	t.Run("update error aborts", func(t *testing.T) {
+		dev := newFakeKVS()
+		store := newTestKVSStore[int](t, NewKVS(dev.client()), "app")
+		boom := errors.New("boom")
+
+		_, err := store.CompareAndSwap(ctx, "count", func(int, bool) (int, error) {
+			return 0, boom
+		})
+		if !errors.Is(err, boom) {
+			t.Errorf("error = %v, want %v", err, boom)
+		}
+	})
+}
+
+func TestKVSStore_KeysAllDelete(t *testing.T) {
+	dev := newFakeKVS()
+	dev.put("app:b", `"2"`)
+	dev.put("app:a", `"1"`)
+	dev.put("app:bad", `7`)
+	dev.put("other:c", `"3"`)
+	store := newTestKVSStore[int](t, NewKVS(dev.client()), "app")
+	ctx := context.Background()
+
+	keys, err := store.Keys(ctx)
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	if strings.Join(keys, ",") != "a,b,bad" {
+		t.Errorf("Keys() = %v", keys)
+	}
+
+	all, err := store.All(ctx)
+	if err == nil {
+		t.Error("All() should report the undecodable entry")
+	}
+	if len(all) != 2 || all["a"].Value != 1 || all["b"].Value != 2 {
+		t.Errorf("All() = %+v", all)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := dev.values["app:a"]; ok {
+		t.Error("key was not deleted")
+	}
+}
+
+func TestKVSStore_Watch(t *testing.T) {
+	dev := newFakeKVS()
+	dev.put("app:mode", `"\"eco\""`)
+	dev.put("app:old", `"\"x\""`)
+	client := dev.client()
+	store := newTestKVSStore[string](t, NewKVS(client), "app")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := store.Watch(ctx, &KVSWatchOptions{DisablePolling: true})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// A script changes state and announces it.
+	dev.put("app:mode", `"\"comfort\""`)
+	dev.put("app:new", `"\"y\""`)
+	dev.put("other:key", `"\"z\""`)
+	dev.mu.Lock()
+	delete(dev.values, "app:old")
+	dev.mu.Unlock()
+
+	notify := `{"method":"NotifyEvent","params":{"ts":1,"events":[{"component":"script:1","id":1,"event":"kvs_changed"}]}}`
+	if err := client.NotificationRouter().RouteRaw([]byte(notify)); err != nil {
+		t.Fatalf("RouteRaw() error = %v", err)
+	}
+
+	want := []KVSChange[string]{
+		{Type: KVSChangeSet, Key: "mode", Value: "comfort"},
+		{Type: KVSChangeSet, Key: "new", Value: "y"},
+		{Type: KVSChangeDelete, Key: "old"},
+	}
+	for _, w := range want {
+		select {
+		case got := <-changes:
+			if got.Type != w.Type || got.Key != w.Key || got.Value != w.Value || got.Err != nil {
+				t.Errorf("change = %+v, want %+v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", w)
+		}
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestKVSStore_WatchSharesHandler(t *testing.T) {
+	dev := newFakeKVS()
+	client := dev.client()
+	store := newTestKVSStore[string](t, NewKVS(client), "app")
+
+	for range 5 {
+		ctx, cancel := context.WithCancel(context.Background())
+		changes, err := store.Watch(ctx, &KVSWatchOptions{DisablePolling: true})
+		if err != nil {
+			t.Fatalf("Watch() error = %v", err)
+		}
+		cancel()
+		for range changes {
+		}
+	}
+
+	if n := client.NotificationRouter().MethodHandlerCount("NotifyEvent"); n != 1 {
+		t.Errorf("NotifyEvent handlers = %d, want 1", n)
+	}
+	store.watchMu.Lock()
+	defer store.watchMu.Unlock()
+	if len(store.watchers) != 0 {
+		t.Errorf("watchers = %d after cancel, want 0", len(store.watchers))
+	}
+}
+
+func TestKVSStore_WatchPolling(t *testing.T) {
+	dev := newFakeKVS()
+	store := newTestKVSStore[int](t, NewKVS(dev.client()), "app", WithKVSCodec(JSONValueCodec[int]{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := store.Watch(ctx, &KVSWatchOptions{Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	dev.put("app:count", `5`)
+
+	select {
+	case got := <-changes:
+		if got.Type != KVSChangeSet || got.Key != "count" || got.Value != 5 {
+			t.Errorf("change = %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+}
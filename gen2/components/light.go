@@ -73,6 +73,7 @@ type LightStatus struct {
 	APower             *float64           `json:"apower,omitempty"`
 	Voltage            *float64           `json:"voltage,omitempty"`
 	Current            *float64           `json:"current,omitempty"`
+	AEnergy            *EnergyCounters    `json:"aenergy,omitempty"`
 	types.RawFields
 	Source string   `json:"source"`
 	Errors []string `json:"errors,omitempty"`
@@ -21,9 +21,10 @@ const scheduleComponentType = "schedule"
 //   - Maximum 20 schedules per device
 //
 // Timespec format:
-//   - Similar to cron: "ss mm hh DD WW" (seconds, minutes, hours, day of month, weekday)
+//   - Similar to cron: "ss mm hh DD MM WW" (seconds, minutes, hours, day of month, month, weekday)
 //   - Supports wildcards (*), ranges (1-5), lists (1,3,5), and steps (0-59/10)
 //   - Special values: @sunrise, @sunset with optional offset (+/-minutes)
+//   - See the timespec package to validate specs and compute fire times
 //
 // Example:
 //
@@ -150,7 +151,7 @@ func (s *Schedule) List(ctx context.Context) (*ScheduleListResponse, error) {
 //
 //	result, err := schedule.Create(ctx, &ScheduleCreateRequest{
 //	    Enable:   true,
-//	    Timespec: "0 0 8 * *",
+//	    Timespec: "0 0 8 * * *",
 //	    Calls: []ScheduleCall{
 //	        {Method: "Switch.Set", Params: map[string]any{"id": 0, "on": true}},
 //	    },
@@ -201,7 +202,7 @@ func (s *Schedule) Create(ctx context.Context, req *ScheduleCreateRequest) (*Sch
 //
 //	_, err := schedule.Update(ctx, &ScheduleUpdateRequest{
 //	    ID:       1,
-//	    Timespec: ptr("0 0 9 * *"),
+//	    Timespec: ptr("0 0 9 * * *"),
 //	})
 func (s *Schedule) Update(ctx context.Context, req *ScheduleUpdateRequest) (*ScheduleUpdateResponse, error) {
 	params := map[string]any{
@@ -2,6 +2,11 @@ module github.com/tj-smith47/shelly-go
 
 go 1.25.5
 
+require (
+	golang.org/x/net v0.45.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	dario.cat/mergo v1.0.2 // indirect
 	github.com/Azure/go-ansiterm v0.0.0-20210617225240-d185dfc1b5a1 // indirect
@@ -69,9 +74,7 @@ require (
 	go.uber.org/atomic v1.11.0 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/exp v0.0.0-20241204233417-43b7b7cde48d // indirect
-	golang.org/x/net v0.45.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.37.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	tinygo.org/x/bluetooth v0.13.0 // indirect
 )
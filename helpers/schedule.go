@@ -10,6 +10,7 @@ import (
 
 	"github.com/tj-smith47/shelly-go/factory"
 	"github.com/tj-smith47/shelly-go/gen2"
+	"github.com/tj-smith47/shelly-go/timespec"
 	"github.com/tj-smith47/shelly-go/types"
 )
 
@@ -146,6 +147,7 @@ type ScheduleEntry struct {
 	Action  Action       `json:"action"`
 	Time    ScheduleTime `json:"time"`
 	ID      int          `json:"id,omitempty"`
+	Channel int          `json:"channel,omitempty"` // Switch or Light ID the action targets (Gen2)
 	Enabled bool         `json:"enabled"`
 }
 
@@ -256,33 +258,36 @@ func CreateSchedule(ctx context.Context, dev *factory.Gen2Device, entry *Schedul
 		return 0, types.ErrNilDevice
 	}
 
-	// Build timespec in cron format: MM HH * * D1,D2,...
-	dayList := make([]string, len(entry.Days))
+	// Build timespec in Gen2 format: ss mm hh DD MM WW
+	weekdays := make([]time.Weekday, len(entry.Days))
 	for i, d := range entry.Days {
-		dayList[i] = strconv.Itoa(int(d))
+		weekdays[i] = time.Weekday(d)
+	}
+	spec, err := timespec.At(entry.Time.Hour, entry.Time.Minute).Weekdays(weekdays...).Build()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build timespec: %w", err)
 	}
-	timespec := fmt.Sprintf("%d %d * * %s", entry.Time.Minute, entry.Time.Hour, strings.Join(dayList, ","))
 
 	// Build calls based on action
 	var calls []map[string]any
 	switch entry.Action.Type {
 	case ActionTypeSet:
 		calls = []map[string]any{
-			{"method": "Switch.Set", "params": map[string]any{"id": 0, "on": entry.Action.On}},
+			{"method": "Switch.Set", "params": map[string]any{"id": entry.Channel, "on": entry.Action.On}},
 		}
 	case ActionTypeToggle:
 		calls = []map[string]any{
-			{"method": "Switch.Toggle", "params": map[string]any{"id": 0}},
+			{"method": "Switch.Toggle", "params": map[string]any{"id": entry.Channel}},
 		}
 	case ActionTypeBrightness:
 		calls = []map[string]any{
-			{"method": "Light.Set", "params": map[string]any{"id": 0, "brightness": entry.Action.Brightness}},
+			{"method": "Light.Set", "params": map[string]any{"id": entry.Channel, "brightness": entry.Action.Brightness}},
 		}
 	}
 
 	params := map[string]any{
 		"enable":   entry.Enabled,
-		"timespec": timespec,
+		"timespec": spec.String(),
 		"calls":    calls,
 	}
 
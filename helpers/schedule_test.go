@@ -286,8 +286,16 @@ func TestCreateSchedule(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("set action", func(t *testing.T) {
+		var timespec string
 		dev := createMockGen2DeviceWithTransport(func(method string, params any) (json.RawMessage, error) {
 			if method == "Schedule.Create" {
+				var p struct {
+					Timespec string `json:"timespec"`
+				}
+				if raw, ok := params.(json.RawMessage); ok {
+					_ = json.Unmarshal(raw, &p)
+				}
+				timespec = p.Timespec
 				resp := `{"jsonrpc":"2.0","id":1,"result":{"id":123}}`
 				return json.RawMessage(resp), nil
 			}
@@ -308,6 +316,9 @@ func TestCreateSchedule(t *testing.T) {
 		if id != 123 {
 			t.Errorf("CreateSchedule() id = %v, want 123", id)
 		}
+		if timespec != "0 30 6 * * 1,2,3,4,5" {
+			t.Errorf("timespec = %q, want %q", timespec, "0 30 6 * * 1,2,3,4,5")
+		}
 	})
 
 	t.Run("toggle action", func(t *testing.T) {
@@ -360,6 +371,40 @@ func TestCreateSchedule(t *testing.T) {
 		}
 	})
 
+	t.Run("channel", func(t *testing.T) {
+		var calls []struct {
+			Params map[string]any `json:"params"`
+		}
+		dev := createMockGen2DeviceWithTransport(func(method string, params any) (json.RawMessage, error) {
+			if method == "Schedule.Create" {
+				var p struct {
+					Calls json.RawMessage `json:"calls"`
+				}
+				if raw, ok := params.(json.RawMessage); ok {
+					_ = json.Unmarshal(raw, &p)
+				}
+				_ = json.Unmarshal(p.Calls, &calls)
+				return json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":{"id":126}}`), nil
+			}
+			return nil, types.ErrRPCMethod
+		})
+
+		entry := &ScheduleEntry{
+			Time:    ScheduleTime{Hour: 2, Minute: 0},
+			Days:    Weekdays{Tuesday},
+			Action:  ActionSet(false),
+			Channel: 1,
+			Enabled: true,
+		}
+
+		if _, err := CreateSchedule(ctx, dev, entry); err != nil {
+			t.Fatalf("CreateSchedule() error: %v", err)
+		}
+		if len(calls) != 1 || calls[0].Params["id"] != float64(1) || calls[0].Params["on"] != false {
+			t.Errorf("calls = %+v, want Switch.Set on channel 1", calls)
+		}
+	})
+
 	t.Run("nil device", func(t *testing.T) {
 		dev := &factory.Gen2Device{Device: nil}
 		entry := &ScheduleEntry{
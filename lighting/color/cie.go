@@ -0,0 +1,121 @@
+package color
+
+import "math"
+
+// XY is a CIE 1931 chromaticity.
+type XY struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// D65 is the chromaticity of the sRGB white point.
+var D65 = XY{X: 0.3127, Y: 0.3290}
+
+// Kelvin limits of KelvinXY.
+const (
+	MinKelvin = 1667
+	MaxKelvin = 25000
+)
+
+// RGB returns the brightest sRGB color with the chromaticity, clipped to
+// the sRGB gamut.
+func (c XY) RGB() RGB {
+	return SRGB.Drive(c).Encode()
+}
+
+// Kelvin returns the correlated color temperature of the chromaticity
+// (McCamy's approximation), which is meaningful near the Planckian locus.
+func (c XY) Kelvin() float64 {
+	n := (c.X - 0.3320) / (c.Y - 0.1858)
+	return -449*n*n*n + 3525*n*n - 6823.3*n + 5520.33
+}
+
+// Distance returns the Euclidean distance between two chromaticities.
+func (c XY) Distance(o XY) float64 {
+	return math.Hypot(c.X-o.X, c.Y-o.Y)
+}
+
+// KelvinXY returns the chromaticity of a black body at the color
+// temperature, clamped to MinKelvin-MaxKelvin (Kim et al. cubic spline).
+func KelvinXY(kelvin float64) XY {
+	t := min(max(kelvin, MinKelvin), MaxKelvin)
+	t2, t3 := t*t, t*t*t
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+	x2, x3 := x*x, x*x*x
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+	return XY{X: x, Y: y}
+}
+
+// KelvinToMired converts a color temperature to mireds (micro reciprocal
+// degrees), the unit of Zigbee and Matter color temperature.
+func KelvinToMired(kelvin float64) float64 {
+	if kelvin <= 0 {
+		return 0
+	}
+	return 1e6 / kelvin
+}
+
+// MiredToKelvin converts mireds to a color temperature.
+func MiredToKelvin(mired float64) float64 {
+	if mired <= 0 {
+		return 0
+	}
+	return 1e6 / mired
+}
+
+// xyz is a CIE XYZ tristimulus value.
+type xyz [3]float64
+
+func (c XY) xyz(luminance float64) xyz {
+	if c.Y <= 0 {
+		return xyz{}
+	}
+	return xyz{c.X / c.Y * luminance, luminance, (1 - c.X - c.Y) / c.Y * luminance}
+}
+
+func (v xyz) xy() XY {
+	sum := v[0] + v[1] + v[2]
+	if sum <= 0 {
+		return D65
+	}
+	return XY{X: v[0] / sum, Y: v[1] / sum}
+}
+
+// matrix is a 3x3 matrix.
+type matrix [3][3]float64
+
+func (m *matrix) mul(v [3]float64) [3]float64 {
+	var out [3]float64
+	for i := range 3 {
+		out[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return out
+}
+
+func (m *matrix) inverse() matrix {
+	a := m
+	det := a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) -
+		a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) +
+		a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+	if det == 0 {
+		return matrix{}
+	}
+	return matrix{
+		{(a[1][1]*a[2][2] - a[1][2]*a[2][1]) / det, (a[0][2]*a[2][1] - a[0][1]*a[2][2]) / det, (a[0][1]*a[1][2] - a[0][2]*a[1][1]) / det},
+		{(a[1][2]*a[2][0] - a[1][0]*a[2][2]) / det, (a[0][0]*a[2][2] - a[0][2]*a[2][0]) / det, (a[0][2]*a[1][0] - a[0][0]*a[1][2]) / det},
+		{(a[1][0]*a[2][1] - a[1][1]*a[2][0]) / det, (a[0][1]*a[2][0] - a[0][0]*a[2][1]) / det, (a[0][0]*a[1][1] - a[0][1]*a[1][0]) / det},
+	}
+}
@@ -0,0 +1,228 @@
+package color
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidColor is returned for a color string that can't be parsed.
+var ErrInvalidColor = errors.New("color: invalid color")
+
+// RGB is a color with red, green and blue components from 0 to 1.
+//
+// Colors from users, such as hex codes and HSV, are gamma-encoded sRGB.
+// Device drive levels returned by Gamut are linear.
+type RGB struct {
+	R float64 `json:"r"`
+	G float64 `json:"g"`
+	B float64 `json:"b"`
+}
+
+// FromInts converts 0-255 components, as in the rgb field of Gen2 RGB and
+// RGBW statuses, to an RGB color. Missing components are zero.
+func FromInts(rgb []int) RGB {
+	var c [3]float64
+	for i := 0; i < len(rgb) && i < 3; i++ {
+		c[i] = clamp01(float64(rgb[i]) / 255)
+	}
+	return RGB{R: c[0], G: c[1], B: c[2]}
+}
+
+// ParseHex parses a "#rrggbb", "rrggbb" or "#rgb" color.
+func ParseHex(s string) (RGB, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, fmt.Errorf("%w: %q", ErrInvalidColor, s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("%w: %q", ErrInvalidColor, s)
+	}
+	return FromInts([]int{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}), nil
+}
+
+// Hex returns the color as "#rrggbb".
+func (c RGB) Hex() string {
+	rgb := c.Ints()
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+// Ints returns the components as 0-255 values, as taken by the rgb
+// parameter of RGB.Set and RGBW.Set.
+func (c RGB) Ints() []int {
+	return []int{to255(c.R), to255(c.G), to255(c.B)}
+}
+
+// Max returns the largest component.
+func (c RGB) Max() float64 {
+	return max(c.R, c.G, c.B)
+}
+
+// Normalize scales the color so its largest component is 1. Black stays
+// black.
+func (c RGB) Normalize() RGB {
+	m := c.Max()
+	if m <= 0 {
+		return RGB{}
+	}
+	return RGB{R: c.R / m, G: c.G / m, B: c.B / m}
+}
+
+// Linear decodes gamma-encoded sRGB to linear light.
+func (c RGB) Linear() RGB {
+	return RGB{R: linear(c.R), G: linear(c.G), B: linear(c.B)}
+}
+
+// Encode encodes linear light as gamma-encoded sRGB.
+func (c RGB) Encode() RGB {
+	return RGB{R: encode(c.R), G: encode(c.G), B: encode(c.B)}
+}
+
+// XY returns the chromaticity of an sRGB color. Black has the D65 white
+// point.
+func (c RGB) XY() XY {
+	return SRGB.XY(c.Linear())
+}
+
+// HSV returns the color in hue, saturation and value.
+func (c RGB) HSV() HSV {
+	r, g, b := clamp01(c.R), clamp01(c.G), clamp01(c.B)
+	hi := max(r, g, b)
+	d := hi - min(r, g, b)
+	hsv := HSV{V: hi}
+	if hi > 0 {
+		hsv.S = d / hi
+	}
+	if d == 0 {
+		return hsv
+	}
+	switch hi {
+	case r:
+		hsv.H = math.Mod((g-b)/d, 6)
+	case g:
+		hsv.H = (b-r)/d + 2
+	default:
+		hsv.H = (r-g)/d + 4
+	}
+	hsv.H *= 60
+	if hsv.H < 0 {
+		hsv.H += 360
+	}
+	return hsv
+}
+
+// HSV is a color in hue (degrees, 0-360), saturation and value (0-1).
+type HSV struct {
+	H float64 `json:"h"`
+	S float64 `json:"s"`
+	V float64 `json:"v"`
+}
+
+// RGB converts the color to sRGB.
+func (c HSV) RGB() RGB {
+	h := math.Mod(c.H, 360)
+	if h < 0 {
+		h += 360
+	}
+	s, v := clamp01(c.S), clamp01(c.V)
+	chroma := v * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = chroma, x
+	case h < 120:
+		r, g = x, chroma
+	case h < 180:
+		g, b = chroma, x
+	case h < 240:
+		g, b = x, chroma
+	case h < 300:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	m := v - chroma
+	return RGB{R: r + m, G: g + m, B: b + m}
+}
+
+// RGBW is a color with a separate white channel, all from 0 to 1.
+type RGBW struct {
+	R float64 `json:"r"`
+	G float64 `json:"g"`
+	B float64 `json:"b"`
+	W float64 `json:"w"`
+}
+
+// Ints returns the color and white channels as 0-255 values, as taken by
+// RGBW.Set and the Gen1 Color component.
+func (c RGBW) Ints() (rgb []int, white int) {
+	return []int{to255(c.R), to255(c.G), to255(c.B)}, to255(c.W)
+}
+
+// ExtractWhite moves the part of a linear color that the white channel can
+// produce onto it. white is the white LED's color as a linear mix of the
+// color channels with its largest component 1, e.g. Gamut.WhiteRGB. The
+// result is scaled so its largest channel equals the largest component of
+// c, keeping the brightness of saturated colors.
+func ExtractWhite(c, white RGB) RGBW {
+	k := math.Inf(1)
+	for _, pair := range [][2]float64{{c.R, white.R}, {c.G, white.G}, {c.B, white.B}} {
+		if pair[1] > 0 {
+			k = min(k, pair[0]/pair[1])
+		}
+	}
+	if math.IsInf(k, 1) || k <= 0 {
+		return RGBW{R: c.R, G: c.G, B: c.B}
+	}
+	out := RGBW{
+		R: max(c.R-k*white.R, 0),
+		G: max(c.G-k*white.G, 0),
+		B: max(c.B-k*white.B, 0),
+		W: k,
+	}
+	if m := max(out.R, out.G, out.B, out.W); m > 0 {
+		scale := c.Max() / m
+		out.R *= scale
+		out.G *= scale
+		out.B *= scale
+		out.W *= scale
+	}
+	return out
+}
+
+// RGB mixes the white channel back into a linear color; white is the white
+// LED's color as passed to ExtractWhite.
+func (c RGBW) RGB(white RGB) RGB {
+	return RGB{R: c.R + c.W*white.R, G: c.G + c.W*white.G, B: c.B + c.W*white.B}
+}
+
+func linear(v float64) float64 {
+	v = clamp01(v)
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func encode(v float64) float64 {
+	v = clamp01(v)
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func clamp01(v float64) float64 {
+	return min(max(v, 0), 1)
+}
+
+func to255(v float64) int {
+	return int(math.Round(clamp01(v) * 255))
+}
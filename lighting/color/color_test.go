@@ -0,0 +1,285 @@
+package color
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tj-smith47/shelly-go/profiles"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{in: "#ff8000", want: []int{255, 128, 0}},
+		{in: "00FF7f", want: []int{0, 255, 127}},
+		{in: "#f80", want: []int{255, 136, 0}},
+		{in: "#ff80", wantErr: true},
+		{in: "#gg0000", wantErr: true},
+	}
+	for _, tt := range tests {
+		c, err := ParseHex(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidColor) {
+				t.Errorf("ParseHex(%q) error = %v", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || !equalInts(c.Ints(), tt.want) {
+			t.Errorf("ParseHex(%q) = %v, %v, want %v", tt.in, c.Ints(), err, tt.want)
+		}
+	}
+	if c, _ := ParseHex("#1a2b3c"); c.Hex() != "#1a2b3c" {
+		t.Errorf("Hex() = %s", c.Hex())
+	}
+}
+
+func TestHSV(t *testing.T) {
+	tests := []struct {
+		hsv  HSV
+		want []int
+	}{
+		{HSV{H: 0, S: 1, V: 1}, []int{255, 0, 0}},
+		{HSV{H: 120, S: 1, V: 1}, []int{0, 255, 0}},
+		{HSV{H: 240, S: 1, V: 0.5}, []int{0, 0, 128}},
+		{HSV{H: 30, S: 1, V: 1}, []int{255, 128, 0}},
+		{HSV{H: 300, S: 0.5, V: 1}, []int{255, 128, 255}},
+		{HSV{H: -60, S: 1, V: 1}, []int{255, 0, 255}},
+		{HSV{H: 200, S: 0, V: 1}, []int{255, 255, 255}},
+	}
+	for _, tt := range tests {
+		rgb := tt.hsv.RGB()
+		if !equalInts(rgb.Ints(), tt.want) {
+			t.Errorf("%+v.RGB() = %v, want %v", tt.hsv, rgb.Ints(), tt.want)
+		}
+		back := rgb.HSV()
+		if tt.hsv.S > 0 && !near(math.Mod(back.H-tt.hsv.H+360, 360), 0, 0.5) {
+			t.Errorf("%+v round trip hue = %v", tt.hsv, back.H)
+		}
+	}
+}
+
+func TestXY(t *testing.T) {
+	tests := []struct {
+		name string
+		rgb  RGB
+		want XY
+	}{
+		{"red", RGB{R: 1}, SRGB.Red},
+		{"green", RGB{G: 1}, SRGB.Green},
+		{"blue", RGB{B: 1}, SRGB.Blue},
+		{"white", RGB{R: 1, G: 1, B: 1}, D65},
+		{"gray", RGB{R: 0.5, G: 0.5, B: 0.5}, D65},
+	}
+	for _, tt := range tests {
+		got := tt.rgb.XY()
+		if got.Distance(tt.want) > 1e-4 {
+			t.Errorf("%s: XY() = %+v, want %+v", tt.name, got, tt.want)
+		}
+		back := got.RGB()
+		if want := tt.rgb.Normalize(); !near(back.R, want.R, 1e-3) || !near(back.G, want.G, 1e-3) || !near(back.B, want.B, 1e-3) {
+			t.Errorf("%s: RGB() = %+v, want %+v", tt.name, back, want)
+		}
+	}
+}
+
+func TestKelvin(t *testing.T) {
+	tests := []struct {
+		kelvin float64
+		want   XY
+	}{
+		{2700, XY{X: 0.4599, Y: 0.4106}},
+		{4000, XY{X: 0.3805, Y: 0.3768}},
+		{6500, XY{X: 0.3135, Y: 0.3237}},
+	}
+	for _, tt := range tests {
+		got := KelvinXY(tt.kelvin)
+		if got.Distance(tt.want) > 2e-3 {
+			t.Errorf("KelvinXY(%v) = %+v, want %+v", tt.kelvin, got, tt.want)
+		}
+		if k := got.Kelvin(); !near(k, tt.kelvin, tt.kelvin*0.01) {
+			t.Errorf("Kelvin() = %v, want %v", k, tt.kelvin)
+		}
+	}
+	if KelvinXY(500) != KelvinXY(MinKelvin) {
+		t.Error("KelvinXY() doesn't clamp")
+	}
+	if m := KelvinToMired(4000); m != 250 || MiredToKelvin(m) != 4000 {
+		t.Errorf("mireds = %v", m)
+	}
+	if KelvinToMired(0) != 0 || MiredToKelvin(0) != 0 {
+		t.Error("zero conversions")
+	}
+}
+
+func TestGamut_Clamp(t *testing.T) {
+	g := SRGB
+	inside := XY{X: 0.4, Y: 0.4}
+	if !g.Contains(inside) || g.Clamp(inside) != inside {
+		t.Errorf("Clamp(inside) = %+v", g.Clamp(inside))
+	}
+	// Spectral green is far outside sRGB; it lands on the red-green edge.
+	got := g.Clamp(XY{X: 0.2, Y: 0.75})
+	if g.Contains(XY{X: 0.2, Y: 0.75}) || got.Distance(SRGB.Green) > 0.05 {
+		t.Errorf("Clamp(outside) = %+v", got)
+	}
+	if !g.Contains(got) {
+		t.Errorf("clamped %+v not contained", got)
+	}
+	// Beyond a vertex it clamps to the vertex.
+	if got := g.Clamp(XY{X: 0.7, Y: 0.28}); got.Distance(SRGB.Red) > 1e-9 {
+		t.Errorf("Clamp(past red) = %+v", got)
+	}
+
+	var white Gamut
+	if white.Clamp(XY{X: 0.7, Y: 0.3}) != (XY{X: 0.7, Y: 0.3}) || white.Drive(D65) != (RGB{}) {
+		t.Error("colorless gamut")
+	}
+
+	cct := Gamut{MinKelvin: 2700, MaxKelvin: 6500}
+	if cct.ClampKelvin(2000) != 2700 || cct.ClampKelvin(9000) != 6500 || cct.ClampKelvin(4000) != 4000 {
+		t.Error("ClampKelvin()")
+	}
+	if white.ClampKelvin(9000) != 9000 {
+		t.Error("ClampKelvin() without range")
+	}
+}
+
+func TestGamut_Drive(t *testing.T) {
+	g := SRGB
+	d := g.Drive(D65)
+	if !near(d.R, 1, 1e-6) || !near(d.G, 1, 1e-6) || !near(d.B, 1, 1e-6) {
+		t.Errorf("Drive(D65) = %+v", d)
+	}
+	if xy := g.XY(g.Drive(XY{X: 0.5, Y: 0.4})); xy.Distance(XY{X: 0.5, Y: 0.4}) > 1e-6 {
+		t.Errorf("round trip = %+v", xy)
+	}
+	// Out-of-gamut colors drive the clamped color.
+	d = g.Drive(XY{X: 0.75, Y: 0.25})
+	if d.R != 1 || d.G > 1e-6 || d.B > 1e-6 {
+		t.Errorf("Drive(deep red) = %+v", d)
+	}
+}
+
+func TestExtractWhite(t *testing.T) {
+	white := RGB{R: 1, G: 0.8, B: 0.6}
+	tests := []struct {
+		name string
+		in   RGB
+		want RGBW
+	}{
+		{"white led color", RGB{R: 1, G: 0.8, B: 0.6}, RGBW{W: 1}},
+		{"saturated", RGB{R: 1}, RGBW{R: 1}},
+		// 0.5 of the white LED is left over red 0.5 and green 0.1.
+		{"pastel", RGB{R: 1, G: 0.5, B: 0.3}, RGBW{R: 1, G: 0.2, W: 1}},
+		{"no white led", RGB{R: 1, G: 1, B: 1}, RGBW{R: 1, G: 1, B: 1}},
+	}
+	for _, tt := range tests {
+		w := white
+		if tt.name == "no white led" {
+			w = RGB{}
+		}
+		got := ExtractWhite(tt.in, w)
+		if !near(got.R, tt.want.R, 1e-9) || !near(got.G, tt.want.G, 1e-9) || !near(got.B, tt.want.B, 1e-9) || !near(got.W, tt.want.W, 1e-9) {
+			t.Errorf("%s: ExtractWhite() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Mixing back gives the same chromaticity.
+	in := RGB{R: 1, G: 0.5, B: 0.3}
+	back := ExtractWhite(in, white).RGB(white)
+	if SRGB.XY(back).Distance(SRGB.XY(in)) > 1e-9 {
+		t.Errorf("mix back = %+v", back)
+	}
+
+	rgb, w := (RGBW{R: 1, G: 0.5, W: 0.2}).Ints()
+	if !equalInts(rgb, []int{255, 128, 0}) || w != 51 {
+		t.Errorf("Ints() = %v, %v", rgb, w)
+	}
+}
+
+func TestGamut_RGBW(t *testing.T) {
+	g := SRGB
+	g.White = KelvinXY(4000)
+
+	// The white channel's own color is all white.
+	got := g.RGBW(g.White)
+	if got.W != 1 || got.R > 1e-6 || got.G > 1e-6 || got.B > 1e-6 {
+		t.Errorf("RGBW(white) = %+v", got)
+	}
+	// Saturated colors use no white.
+	if got := g.RGBW(SRGB.Blue); got.W != 0 || got.B != 1 {
+		t.Errorf("RGBW(blue) = %+v", got)
+	}
+	if got := SRGB.RGBW(D65); got.W != 0 || got.R != 1 {
+		t.Errorf("RGBW without white = %+v", got)
+	}
+}
+
+func TestForProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   *profiles.Profile
+		color     bool
+		white     bool
+		minKelvin float64
+	}{
+		{name: "nil"},
+		{
+			name: "rgbw",
+			profile: &profiles.Profile{
+				Model:        "SNDC-0D4P10WW",
+				Components:   profiles.Components{RGBChannels: 1, WhiteChannels: 1},
+				Capabilities: profiles.Capabilities{ColorSupport: true, ColorTemperature: true},
+			},
+			color: true, white: true, minKelvin: DefaultMinKelvin,
+		},
+		{
+			name: "bulb",
+			profile: &profiles.Profile{
+				Model:        "SHBLB-1",
+				Components:   profiles.Components{RGBChannels: 1, WhiteChannels: 1},
+				Capabilities: profiles.Capabilities{ColorSupport: true, ColorTemperature: true},
+			},
+			color: true, white: true, minKelvin: 3000,
+		},
+		{
+			name: "tunable white",
+			profile: &profiles.Profile{
+				Model:        "SHBDUO-1",
+				Components:   profiles.Components{WhiteChannels: 1},
+				Capabilities: profiles.Capabilities{ColorTemperature: true},
+			},
+			minKelvin: DefaultMinKelvin,
+		},
+		{
+			name:    "dimmer",
+			profile: &profiles.Profile{Model: "SHDM-2", Capabilities: profiles.Capabilities{DimmingSupport: true}},
+		},
+	}
+	for _, tt := range tests {
+		g := ForProfile(tt.profile)
+		if g.HasColor() != tt.color || g.HasWhite() != tt.white || g.MinKelvin != tt.minKelvin {
+			t.Errorf("%s: ForProfile() = %+v", tt.name, g)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
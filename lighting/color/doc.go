@@ -0,0 +1,27 @@
+// Package color converts between the color models of smart lights and the
+// raw channel values Shelly devices take.
+//
+// Gen2 Light, RGB and RGBW components and Gen1 Color and White components
+// take raw rgb, white, brightness and color temperature values. This
+// package converts colors between sRGB (RGB, hex codes), HSV, CIE 1931
+// chromaticity (XY) and color temperature in Kelvin or mireds, and maps
+// them onto a light's channels:
+//
+//	c, _ := color.ParseHex("#ff8000")
+//	gamut := color.ForProfile(profile)
+//	levels := gamut.RGBW(c.XY())
+//	rgb, white := levels.Ints()
+//
+// # Gamuts
+//
+// A Gamut describes a light's color channels, white channel and tunable
+// white range. Chromaticities outside the color triangle are clamped to
+// its closest edge, and color temperatures to the tunable white range.
+// ForProfile derives the gamut from a device profile.
+//
+// # White Extraction
+//
+// For RGBW lights, ExtractWhite and Gamut.RGBW move the part of a color
+// the white channel can produce onto it, which is brighter and more
+// efficient than mixing white from the color channels.
+package color
@@ -0,0 +1,195 @@
+package color
+
+import (
+	"math"
+
+	"github.com/tj-smith47/shelly-go/profiles"
+)
+
+// Gamut describes the colors a light can produce: the chromaticities of
+// its red, green and blue channels, of its white channel and its tunable
+// white range. Zero values mean the light lacks the feature.
+//
+// The color channels are assumed to be balanced to D65 at equal drive
+// levels, and the white channel at full drive to match the color channels'
+// mix of the same chromaticity.
+type Gamut struct {
+	// Red, Green and Blue are the chromaticities of the color channels.
+	Red   XY `json:"red"`
+	Green XY `json:"green"`
+	Blue  XY `json:"blue"`
+
+	// White is the chromaticity of the white channel.
+	White XY `json:"white"`
+
+	// MinKelvin and MaxKelvin delimit the tunable white range.
+	MinKelvin float64 `json:"min_kelvin,omitempty"`
+	MaxKelvin float64 `json:"max_kelvin,omitempty"`
+}
+
+// SRGB is the gamut of sRGB (Rec. 709) primaries, the default for color
+// lights.
+var SRGB = Gamut{
+	Red:   XY{X: 0.64, Y: 0.33},
+	Green: XY{X: 0.30, Y: 0.60},
+	Blue:  XY{X: 0.15, Y: 0.06},
+}
+
+// Default tunable white range for lights without a known one.
+const (
+	DefaultMinKelvin = 2700
+	DefaultMaxKelvin = 6500
+)
+
+// DefaultWhiteKelvin is the assumed color temperature of a white channel,
+// e.g. of an RGBW strip.
+const DefaultWhiteKelvin = 4000
+
+// kelvinRanges holds the tunable white range of models that differ from
+// the default.
+var kelvinRanges = map[string][2]float64{
+	"SHBLB-1": {3000, 6500},
+	"SHCB-1":  {3000, 6500},
+}
+
+// ForProfile returns the gamut of a device model from its capabilities:
+// sRGB primaries for color lights, a neutral white channel for RGBW
+// lights and the tunable white range for color temperature lights.
+func ForProfile(p *profiles.Profile) Gamut {
+	var g Gamut
+	if p == nil {
+		return g
+	}
+	if p.Capabilities.ColorSupport {
+		g = SRGB
+		if p.Components.WhiteChannels > 0 {
+			g.White = KelvinXY(DefaultWhiteKelvin)
+		}
+	}
+	if p.Capabilities.ColorTemperature {
+		g.MinKelvin, g.MaxKelvin = DefaultMinKelvin, DefaultMaxKelvin
+		if r, ok := kelvinRanges[p.Model]; ok {
+			g.MinKelvin, g.MaxKelvin = r[0], r[1]
+		}
+	}
+	return g
+}
+
+// HasColor reports whether the gamut has color channels.
+func (g *Gamut) HasColor() bool {
+	return g.Red != (XY{}) && g.Green != (XY{}) && g.Blue != (XY{})
+}
+
+// HasWhite reports whether the gamut has a white channel.
+func (g *Gamut) HasWhite() bool {
+	return g.White != (XY{})
+}
+
+// HasKelvin reports whether the gamut has a tunable white range.
+func (g *Gamut) HasKelvin() bool {
+	return g.MaxKelvin > 0
+}
+
+// ClampKelvin limits a color temperature to the tunable white range. A
+// gamut without one returns kelvin unchanged.
+func (g *Gamut) ClampKelvin(kelvin float64) float64 {
+	if !g.HasKelvin() {
+		return kelvin
+	}
+	return min(max(kelvin, g.MinKelvin), g.MaxKelvin)
+}
+
+// Contains reports whether the chromaticity is inside the color triangle.
+func (g *Gamut) Contains(c XY) bool {
+	d1 := cross(g.Red, g.Green, c)
+	d2 := cross(g.Green, g.Blue, c)
+	d3 := cross(g.Blue, g.Red, c)
+	neg := d1 < 0 || d2 < 0 || d3 < 0
+	pos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(neg && pos)
+}
+
+// Clamp returns the chromaticity if it is inside the color triangle, or
+// the closest point on its edge. A gamut without color channels returns c
+// unchanged.
+func (g *Gamut) Clamp(c XY) XY {
+	if !g.HasColor() || g.Contains(c) {
+		return c
+	}
+	best, bestDist := c, math.Inf(1)
+	for _, edge := range [][2]XY{{g.Red, g.Green}, {g.Green, g.Blue}, {g.Blue, g.Red}} {
+		p := closest(edge[0], edge[1], c)
+		if d := p.Distance(c); d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best
+}
+
+// Drive returns the linear color channel levels that produce the
+// chromaticity, clamped to the gamut, with the largest level 1.
+func (g *Gamut) Drive(c XY) RGB {
+	if !g.HasColor() {
+		return RGB{}
+	}
+	m := g.matrix()
+	inv := m.inverse()
+	v := inv.mul(g.Clamp(c).xyz(1))
+	return RGB{R: max(v[0], 0), G: max(v[1], 0), B: max(v[2], 0)}.Normalize()
+}
+
+// XY returns the chromaticity of linear color channel levels.
+func (g *Gamut) XY(c RGB) XY {
+	m := g.matrix()
+	return xyz(m.mul([3]float64{c.R, c.G, c.B})).xy()
+}
+
+// WhiteRGB returns the white channel's color as color channel levels, for
+// ExtractWhite.
+func (g *Gamut) WhiteRGB() RGB {
+	if !g.HasWhite() {
+		return RGB{}
+	}
+	return g.Drive(g.White)
+}
+
+// RGBW returns the channel levels that produce the chromaticity, using the
+// white channel for the part of the color it can produce.
+func (g *Gamut) RGBW(c XY) RGBW {
+	d := g.Drive(c)
+	if !g.HasWhite() {
+		return RGBW{R: d.R, G: d.G, B: d.B}
+	}
+	return ExtractWhite(d, g.WhiteRGB())
+}
+
+// matrix returns the linear RGB to XYZ matrix of the primaries, balanced
+// to D65.
+func (g *Gamut) matrix() matrix {
+	r, gr, b := g.Red.xyz(1), g.Green.xyz(1), g.Blue.xyz(1)
+	p := matrix{
+		{r[0], gr[0], b[0]},
+		{r[1], gr[1], b[1]},
+		{r[2], gr[2], b[2]},
+	}
+	inv := p.inverse()
+	s := inv.mul(D65.xyz(1))
+	for i := range 3 {
+		for j := range 3 {
+			p[i][j] *= s[j]
+		}
+	}
+	return p
+}
+
+func cross(a, b, c XY) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// closest returns the point on segment a-b closest to c.
+func closest(a, b, c XY) XY {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := ((c.X-a.X)*dx + (c.Y-a.Y)*dy) / (dx*dx + dy*dy)
+	t = min(max(t, 0), 1)
+	return XY{X: a.X + t*dx, Y: a.Y + t*dy}
+}
@@ -0,0 +1,32 @@
+// Package effects runs fades, color loops and circadian curves across
+// Shelly lights.
+//
+// An Engine renders an Effect on any number of lights concurrently.
+// Lights whose firmware supports transition_duration are handed the
+// fading: a Fade is sent as one transition, and other effects as updates
+// one step ahead with a transition of one step, so the device interpolates
+// between them. Lights without transitions are stepped client-side.
+// Requests to each device are rate limited, and updates that wouldn't
+// change a light are skipped.
+//
+//	engine := effects.NewEngine(effects.WithErrorHandler(func(err error) { log.Print(err) }))
+//	lights := []effects.Light{
+//	    effects.Gen2RGBW(components.NewRGBW(strip.Client(), 0)),
+//	    effects.Gen1Color(gen1components.NewColor(rgbw2Transport, 0)),
+//	}
+//	err := engine.Run(ctx, &effects.ColorLoop{Period: time.Minute, Brightness: 60, Spread: true}, lights...)
+//
+// # Effects
+//
+// Fade changes lights linearly between two states, ColorLoop cycles
+// through the hues and Circadian follows a daily color temperature and
+// brightness curve. Custom effects implement Effect or use EffectFunc.
+//
+// # Lights
+//
+// Adapters drive Gen2 Light, RGB and RGBW components and Gen1 Color and
+// White components. Colors are mapped onto each light's channels with its
+// color.Gamut; RGBW lights produce whites on the white channel. Options
+// set the gamut (e.g. color.ForProfile), the rate limit and transition
+// support for older firmware.
+package effects
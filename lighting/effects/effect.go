@@ -0,0 +1,175 @@
+package effects
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/lighting/color"
+)
+
+// Frame is the point in time an effect is rendered for.
+type Frame struct {
+	// Start is when the effect started.
+	Start time.Time
+
+	// Time is the time to render.
+	Time time.Time
+
+	// Index is the light's position among the Count lights running the
+	// effect, for effects that spread over several lights.
+	Index int
+	Count int
+}
+
+// Elapsed returns the time since the effect started.
+func (f Frame) Elapsed() time.Duration {
+	return f.Time.Sub(f.Start)
+}
+
+// Effect computes light states over time.
+type Effect interface {
+	// At returns the state at the frame's time, and whether the effect has
+	// finished by then.
+	At(f Frame) (s State, done bool)
+}
+
+// EffectFunc adapts a function to an Effect.
+type EffectFunc func(f Frame) (State, bool)
+
+// At calls fn.
+func (fn EffectFunc) At(f Frame) (State, bool) {
+	return fn(f)
+}
+
+// spanner is implemented by effects that change linearly over a known
+// duration, so a single device transition can render them.
+type spanner interface {
+	span() time.Duration
+}
+
+// Fade changes lights linearly from one state to another.
+type Fade struct {
+	From     State
+	To       State
+	Duration time.Duration
+}
+
+// At returns the mix of From and To at the frame's time.
+func (e *Fade) At(f Frame) (State, bool) {
+	if e.Duration <= 0 || f.Elapsed() >= e.Duration {
+		return e.To, true
+	}
+	return Mix(e.From, e.To, float64(f.Elapsed())/float64(e.Duration)), false
+}
+
+func (e *Fade) span() time.Duration {
+	return e.Duration
+}
+
+// ColorLoop cycles lights through the hues.
+type ColorLoop struct {
+	// Period is the time of one cycle.
+	Period time.Duration
+
+	// Saturation is the color saturation from 0 to 1; zero is fully
+	// saturated.
+	Saturation float64
+
+	// Brightness is the brightness from 0 to 100.
+	Brightness float64
+
+	// Cycles is the number of cycles to run; zero runs until canceled.
+	Cycles int
+
+	// Spread offsets the lights' hues evenly around the color wheel.
+	Spread bool
+}
+
+// At returns the color at the frame's time.
+func (e *ColorLoop) At(f Frame) (State, bool) {
+	period := e.Period
+	if period <= 0 {
+		period = time.Minute
+	}
+	turns := float64(f.Elapsed()) / float64(period)
+	done := e.Cycles > 0 && turns >= float64(e.Cycles)
+	if done {
+		turns = float64(e.Cycles)
+	}
+	if e.Spread && f.Count > 1 {
+		turns += float64(f.Index) / float64(f.Count)
+	}
+	sat := e.Saturation
+	if sat <= 0 {
+		sat = 1
+	}
+	xy := color.HSV{H: math.Mod(turns, 1) * 360, S: sat, V: 1}.RGB().XY()
+	return State{On: true, Brightness: e.Brightness, Color: &xy}, done
+}
+
+// CurvePoint is a point of a circadian curve.
+type CurvePoint struct {
+	// At is the time of day as the offset from midnight.
+	At time.Duration `json:"at"`
+
+	// Kelvin is the color temperature.
+	Kelvin float64 `json:"kelvin"`
+
+	// Brightness is the brightness from 0 to 100.
+	Brightness float64 `json:"brightness"`
+}
+
+// DefaultCurve is a circadian curve from warm, dim nights to cool, bright
+// middays.
+var DefaultCurve = []CurvePoint{
+	{At: 0, Kelvin: 2200, Brightness: 10},
+	{At: 6 * time.Hour, Kelvin: 2700, Brightness: 40},
+	{At: 9 * time.Hour, Kelvin: 4500, Brightness: 90},
+	{At: 13 * time.Hour, Kelvin: 5500, Brightness: 100},
+	{At: 18 * time.Hour, Kelvin: 3500, Brightness: 80},
+	{At: 21 * time.Hour, Kelvin: 2700, Brightness: 40},
+	{At: 23 * time.Hour, Kelvin: 2200, Brightness: 15},
+}
+
+// Circadian follows a daily curve of color temperature and brightness,
+// interpolating between points in mireds. It runs until canceled.
+type Circadian struct {
+	// Location is the time zone of the curve; nil is time.Local.
+	Location *time.Location
+
+	// Curve is the daily curve; empty uses DefaultCurve.
+	Curve []CurvePoint
+}
+
+// At returns the curve's state at the frame's time of day.
+func (e *Circadian) At(f Frame) (State, bool) {
+	curve := e.Curve
+	if len(curve) == 0 {
+		curve = DefaultCurve
+	}
+	curve = append([]CurvePoint(nil), curve...)
+	sort.Slice(curve, func(i, j int) bool { return curve[i].At < curve[j].At })
+
+	loc := e.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := f.Time.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	tod := local.Sub(midnight)
+
+	// The points around tod, wrapping around midnight.
+	const day = 24 * time.Hour
+	i := sort.Search(len(curve), func(i int) bool { return curve[i].At > tod })
+	prev, next := curve[(i+len(curve)-1)%len(curve)], curve[i%len(curve)]
+	span := (next.At - prev.At + day) % day
+	var frac float64
+	if span > 0 {
+		frac = float64((tod-prev.At+day)%day) / float64(span)
+	}
+
+	a := State{On: true, Kelvin: prev.Kelvin, Brightness: prev.Brightness}
+	b := State{On: true, Kelvin: next.Kelvin, Brightness: next.Brightness}
+	return Mix(a, b, frac), false
+}
@@ -0,0 +1,146 @@
+package effects
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/lighting/color"
+)
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func xy(x, y float64) *color.XY {
+	return &color.XY{X: x, Y: y}
+}
+
+func TestMix(t *testing.T) {
+	red, blue := xy(0.64, 0.33), xy(0.15, 0.06)
+	tests := []struct {
+		name       string
+		a, b       State
+		f          float64
+		brightness float64
+		kelvin     float64
+		color      *color.XY
+		on         bool
+	}{
+		{name: "brightness", a: State{On: true, Brightness: 20}, b: State{On: true, Brightness: 80}, f: 0.5, brightness: 50, on: true},
+		{name: "fade in", a: State{Brightness: 70}, b: State{On: true, Brightness: 80}, f: 0.25, brightness: 20, on: true},
+		{name: "fade out", a: State{On: true, Brightness: 80}, b: State{}, f: 0.75, brightness: 20, on: true},
+		{name: "fade out end", a: State{On: true, Brightness: 80}, b: State{}, f: 1},
+		// 2000 K = 500 mireds and 5000 K = 200 mireds; halfway is 350.
+		{name: "kelvin", a: State{On: true, Kelvin: 2000}, b: State{On: true, Kelvin: 5000}, f: 0.5, kelvin: 1e6 / 350, on: true},
+		{name: "kelvin from unset", a: State{On: true}, b: State{On: true, Kelvin: 3000}, f: 0.5, kelvin: 3000, on: true},
+		{name: "color", a: State{On: true, Color: red}, b: State{On: true, Color: blue}, f: 0.5, color: xy(0.395, 0.195), on: true},
+		{name: "color from unset", a: State{On: true}, b: State{On: true, Color: blue}, f: 0.5, color: blue, on: true},
+		{name: "kelvin to color", a: State{On: true, Kelvin: 6500}, b: State{On: true, Color: red}, f: 0, kelvin: 6500, on: true},
+	}
+	for _, tt := range tests {
+		got := Mix(tt.a, tt.b, tt.f)
+		if got.On != tt.on || !near(got.Brightness, tt.brightness, 1e-9) || !near(got.Kelvin, tt.kelvin, 1e-6) {
+			t.Errorf("%s: Mix() = %+v", tt.name, got)
+		}
+		if (got.Color == nil) != (tt.color == nil) || (got.Color != nil && got.Color.Distance(*tt.color) > 1e-9) {
+			t.Errorf("%s: color = %+v, want %+v", tt.name, got.Color, tt.color)
+		}
+	}
+
+	// Kelvin mixes with a color through its chromaticity.
+	got := Mix(State{On: true, Kelvin: 6500}, State{On: true, Color: red}, 0.5)
+	want := color.KelvinXY(6500)
+	if got.Color == nil || !near(got.Color.X, (want.X+0.64)/2, 1e-9) {
+		t.Errorf("kelvin to color = %+v", got.Color)
+	}
+}
+
+func TestFade(t *testing.T) {
+	fade := &Fade{From: State{On: true, Brightness: 0}, To: State{On: true, Brightness: 100}, Duration: 10 * time.Second}
+	tests := []struct {
+		elapsed    time.Duration
+		brightness float64
+		done       bool
+	}{
+		{0, 0, false},
+		{2500 * time.Millisecond, 25, false},
+		{10 * time.Second, 100, true},
+		{time.Minute, 100, true},
+	}
+	for _, tt := range tests {
+		s, done := fade.At(Frame{Start: t0, Time: t0.Add(tt.elapsed)})
+		if !near(s.Brightness, tt.brightness, 1e-9) || done != tt.done {
+			t.Errorf("At(%v) = %+v, %v", tt.elapsed, s, done)
+		}
+	}
+	if s, done := (&Fade{To: State{On: true, Brightness: 5}}).At(Frame{Start: t0, Time: t0}); !done || s.Brightness != 5 {
+		t.Errorf("zero duration = %+v, %v", s, done)
+	}
+}
+
+func TestColorLoop(t *testing.T) {
+	loop := &ColorLoop{Period: 6 * time.Second, Brightness: 60, Cycles: 2, Spread: true}
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		index   int
+		want    color.RGB
+		done    bool
+	}{
+		{name: "start", want: color.RGB{R: 1}},
+		{name: "third", elapsed: 2 * time.Second, want: color.RGB{G: 1}},
+		{name: "spread", index: 2, want: color.RGB{B: 1}},
+		{name: "done", elapsed: 13 * time.Second, want: color.RGB{R: 1}, done: true},
+	}
+	for _, tt := range tests {
+		s, done := loop.At(Frame{Start: t0, Time: t0.Add(tt.elapsed), Index: tt.index, Count: 3})
+		if done != tt.done || s.Brightness != 60 || !s.On || s.Color.Distance(tt.want.XY()) > 1e-6 {
+			t.Errorf("%s: At() = %+v (%+v), %v", tt.name, s, s.Color, done)
+		}
+	}
+
+	pastel := &ColorLoop{Period: time.Second, Saturation: 0.5}
+	s, done := pastel.At(Frame{Start: t0, Time: t0.Add(time.Hour)})
+	if done || s.Color.Distance((color.HSV{S: 0.5, V: 1}).RGB().XY()) > 1e-6 {
+		t.Errorf("pastel = %+v, %v", s.Color, done)
+	}
+}
+
+func TestCircadian(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*3600)
+	c := &Circadian{
+		Location: loc,
+		Curve: []CurvePoint{
+			{At: 20 * time.Hour, Kelvin: 2000, Brightness: 20},
+			{At: 8 * time.Hour, Kelvin: 5000, Brightness: 100},
+		},
+	}
+	tests := []struct {
+		name       string
+		local      string
+		kelvin     float64
+		brightness float64
+	}{
+		{name: "point", local: "08:00", kelvin: 5000, brightness: 100},
+		{name: "day", local: "14:00", kelvin: 1e6 / 350, brightness: 60},
+		// Wraps around midnight: 02:00 is halfway from 20:00 to 08:00.
+		{name: "night", local: "02:00", kelvin: 1e6 / 350, brightness: 60},
+		{name: "evening", local: "23:00", kelvin: 1e6 / 425, brightness: 40},
+	}
+	for _, tt := range tests {
+		clock, _ := time.Parse("15:04", tt.local)
+		ts := time.Date(2025, 6, 1, clock.Hour(), clock.Minute(), 0, 0, loc).UTC()
+		s, done := c.At(Frame{Start: t0, Time: ts})
+		if done || !s.On || !near(s.Kelvin, tt.kelvin, 1e-6) || !near(s.Brightness, tt.brightness, 1e-9) {
+			t.Errorf("%s: At() = %+v, %v", tt.name, s, done)
+		}
+	}
+
+	s, _ := (&Circadian{Location: time.UTC}).At(Frame{Time: time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC)})
+	if s.Kelvin != 5500 || s.Brightness != 100 {
+		t.Errorf("default curve = %+v", s)
+	}
+}
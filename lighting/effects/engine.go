@@ -0,0 +1,164 @@
+package effects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultStep is the interval between updates to lights that fade to each
+// update themselves.
+const DefaultStep = time.Second
+
+// minInterval bounds the update rate of lights without a rate limit.
+const minInterval = 20 * time.Millisecond
+
+// Option configures an Engine.
+type Option func(*Engine)
+
+// WithStep sets the interval between updates to lights with device
+// transitions. Shorter steps follow fast effects more closely at the cost
+// of more requests.
+func WithStep(d time.Duration) Option {
+	return func(e *Engine) {
+		if d > 0 {
+			e.step = d
+		}
+	}
+}
+
+// WithErrorHandler sets a function called with every failed light update.
+// Failed updates are retried with the next step.
+func WithErrorHandler(fn func(error)) Option {
+	return func(e *Engine) {
+		e.onError = fn
+	}
+}
+
+// Engine runs effects across lights.
+//
+// Lights whose firmware supports transitions are sent the effect's state
+// one step ahead with a transition of one step, so the device fades
+// between updates; fades are sent as a single transition, split only by
+// the device's maximum transition. Other lights are stepped client-side as
+// fast as their rate limit allows. Updates that wouldn't change a light
+// are skipped.
+//
+// Requests to one device are spaced by its minimum interval, also across
+// concurrent Run calls of the same engine.
+type Engine struct {
+	onError func(error)
+	next    map[string]time.Time
+	step    time.Duration
+	mu      sync.Mutex
+}
+
+// NewEngine creates an effects engine.
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{step: DefaultStep, next: make(map[string]time.Time)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Run runs the effect on the lights until it finishes or ctx is canceled.
+// It returns ctx.Err() when canceled, or the first error of each light
+// whose last update failed.
+func (e *Engine) Run(ctx context.Context, effect Effect, lights ...Light) error {
+	start := time.Now()
+	errs := make([]error, len(lights))
+	var wg sync.WaitGroup
+	for i, light := range lights {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = e.run(ctx, effect, light, Frame{Start: start, Index: i, Count: len(lights)})
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Engine) run(ctx context.Context, effect Effect, light Light, frame Frame) error {
+	caps := light.Caps()
+	interval := max(caps.MinInterval, minInterval)
+
+	var last stateKey
+	var lastErr error
+	sent := false
+	for {
+		now := time.Now()
+		step, transition := interval, time.Duration(0)
+		frame.Time = now
+		if caps.Transition {
+			step = max(e.step, interval)
+			if s, ok := effect.(spanner); ok {
+				step = max(s.span()-now.Sub(frame.Start), 0)
+			}
+			if caps.MaxTransition > 0 {
+				step = min(step, caps.MaxTransition)
+			}
+			frame.Time = now.Add(step)
+			transition = step
+		}
+
+		state, done := effect.At(frame)
+		if key := state.key(); !sent || key != last || lastErr != nil {
+			if err := e.wait(ctx, caps.Device, interval); err != nil {
+				return err
+			}
+			lastErr = light.Set(ctx, state, transition)
+			if lastErr != nil {
+				lastErr = fmt.Errorf("failed to update light: %w", lastErr)
+				if e.onError != nil && ctx.Err() == nil {
+					e.onError(lastErr)
+				}
+			}
+			last, sent = key, true
+		}
+
+		if done {
+			// Finish once the light has reached the final state.
+			if err := sleep(ctx, time.Until(now.Add(transition))); err != nil {
+				return err
+			}
+			return lastErr
+		}
+		if err := sleep(ctx, time.Until(now.Add(max(step, interval)))); err != nil {
+			return err
+		}
+	}
+}
+
+// wait reserves the device's next request slot and waits for it.
+func (e *Engine) wait(ctx context.Context, device string, interval time.Duration) error {
+	e.mu.Lock()
+	now := time.Now()
+	at := now
+	if next := e.next[device]; next.After(now) {
+		at = next
+	}
+	e.next[device] = at.Add(interval)
+	e.mu.Unlock()
+	return sleep(ctx, at.Sub(now))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
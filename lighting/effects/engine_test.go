@@ -0,0 +1,194 @@
+package effects
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type call struct {
+	at         time.Time
+	state      State
+	transition time.Duration
+}
+
+// fakeLight records updates.
+type fakeLight struct {
+	err   func(n int) error
+	calls []call
+	caps  Caps
+	mu    sync.Mutex
+}
+
+func (l *fakeLight) Caps() Caps {
+	return l.caps
+}
+
+func (l *fakeLight) Set(_ context.Context, s State, transition time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call{at: time.Now(), state: s, transition: transition})
+	if l.err != nil {
+		return l.err(len(l.calls))
+	}
+	return nil
+}
+
+func (l *fakeLight) recorded() []call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]call(nil), l.calls...)
+}
+
+var (
+	off  = State{}
+	full = State{On: true, Brightness: 100}
+)
+
+func TestEngine_DeviceTransition(t *testing.T) {
+	light := &fakeLight{caps: Caps{Device: "a", Transition: true}}
+	start := time.Now()
+	err := NewEngine().Run(context.Background(), &Fade{From: off, To: full, Duration: 150 * time.Millisecond}, light)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := light.recorded()
+	if len(calls) != 1 || calls[0].state != full || calls[0].transition < 140*time.Millisecond {
+		t.Fatalf("calls = %+v", calls)
+	}
+	// Run returns once the transition is over.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
+
+func TestEngine_MaxTransition(t *testing.T) {
+	light := &fakeLight{caps: Caps{Device: "a", Transition: true, MaxTransition: 60 * time.Millisecond}}
+	err := NewEngine().Run(context.Background(), &Fade{From: off, To: full, Duration: 150 * time.Millisecond}, light)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := light.recorded()
+	if len(calls) != 3 {
+		t.Fatalf("calls = %+v", calls)
+	}
+	// Each update targets the state at the end of its transition.
+	if calls[0].transition != 60*time.Millisecond || !near(calls[0].state.Brightness, 40, 2) {
+		t.Errorf("first = %+v", calls[0])
+	}
+	if last := calls[2]; last.state != full || last.transition > 40*time.Millisecond {
+		t.Errorf("last = %+v", last)
+	}
+}
+
+func TestEngine_ClientSteps(t *testing.T) {
+	light := &fakeLight{caps: Caps{Device: "a", MinInterval: 20 * time.Millisecond}}
+	err := NewEngine().Run(context.Background(), &Fade{From: off, To: full, Duration: 200 * time.Millisecond}, light)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := light.recorded()
+	if len(calls) < 5 || calls[len(calls)-1].state != full {
+		t.Fatalf("calls = %+v", calls)
+	}
+	for i, c := range calls {
+		if c.transition != 0 {
+			t.Errorf("call %d has transition %v", i, c.transition)
+		}
+		if i > 0 && c.state.Brightness < calls[i-1].state.Brightness {
+			t.Errorf("brightness decreased at %d", i)
+		}
+	}
+}
+
+func TestEngine_Periodic(t *testing.T) {
+	// Transition lights get one step ahead with a one-step transition.
+	light := &fakeLight{caps: Caps{Device: "a", Transition: true}}
+	loop := &ColorLoop{Period: 100 * time.Millisecond, Cycles: 1, Brightness: 50}
+	if err := NewEngine(WithStep(25*time.Millisecond)).Run(context.Background(), loop, light); err != nil {
+		t.Fatal(err)
+	}
+	calls := light.recorded()
+	if len(calls) < 3 || len(calls) > 6 {
+		t.Fatalf("calls = %d", len(calls))
+	}
+	for _, c := range calls {
+		if c.transition != 25*time.Millisecond {
+			t.Errorf("transition = %v", c.transition)
+		}
+	}
+}
+
+func TestEngine_SharedDeviceRateLimit(t *testing.T) {
+	caps := Caps{Device: "rgbw2", MinInterval: 30 * time.Millisecond}
+	a, b := &fakeLight{caps: caps}, &fakeLight{caps: caps}
+	loop := &ColorLoop{Period: 150 * time.Millisecond, Cycles: 1, Brightness: 50, Spread: true}
+	if err := NewEngine().Run(context.Background(), loop, a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	var times []time.Time
+	for _, c := range append(a.recorded(), b.recorded()...) {
+		times = append(times, c.at)
+	}
+	for i := range times {
+		for j := i + 1; j < len(times); j++ {
+			if d := times[i].Sub(times[j]).Abs(); d < 25*time.Millisecond {
+				t.Fatalf("requests %v apart", d)
+			}
+		}
+	}
+}
+
+func TestEngine_SkipsUnchanged(t *testing.T) {
+	light := &fakeLight{caps: Caps{Device: "a"}}
+	constant := EffectFunc(func(f Frame) (State, bool) {
+		return State{On: true, Brightness: 42.2}, f.Elapsed() >= 100*time.Millisecond
+	})
+	if err := NewEngine().Run(context.Background(), constant, light); err != nil {
+		t.Fatal(err)
+	}
+	if calls := light.recorded(); len(calls) != 1 {
+		t.Errorf("calls = %d", len(calls))
+	}
+}
+
+func TestEngine_Errors(t *testing.T) {
+	var reported atomic.Int32
+	engine := NewEngine(WithErrorHandler(func(error) { reported.Add(1) }))
+	constant := EffectFunc(func(f Frame) (State, bool) {
+		return full, f.Elapsed() >= 100*time.Millisecond
+	})
+
+	// A failed update is retried with the next step.
+	flaky := &fakeLight{caps: Caps{Device: "a", MinInterval: 20 * time.Millisecond}, err: func(n int) error {
+		if n == 1 {
+			return errors.New("timeout")
+		}
+		return nil
+	}}
+	if err := engine.Run(context.Background(), constant, flaky); err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+	if calls := flaky.recorded(); len(calls) != 2 || reported.Load() != 1 {
+		t.Errorf("calls = %d, reported = %d", len(calls), reported.Load())
+	}
+
+	broken := &fakeLight{caps: Caps{Device: "b", MinInterval: 20 * time.Millisecond}, err: func(int) error { return errors.New("offline") }}
+	if err := engine.Run(context.Background(), constant, broken); err == nil {
+		t.Error("Run() = nil for a failing light")
+	}
+}
+
+func TestEngine_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	light := &fakeLight{caps: Caps{Device: "a", Transition: true}}
+	err := NewEngine().Run(ctx, &Circadian{}, light)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() = %v", err)
+	}
+}
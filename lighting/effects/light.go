@@ -0,0 +1,304 @@
+package effects
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	gen1components "github.com/tj-smith47/shelly-go/gen1/components"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/lighting/color"
+)
+
+// Default minimum intervals between requests to one device.
+const (
+	DefaultGen2Interval = 200 * time.Millisecond
+	DefaultGen1Interval = 500 * time.Millisecond
+)
+
+// gen1MaxTransition is the longest transition Gen1 firmware accepts.
+const gen1MaxTransition = 5 * time.Second
+
+// Caps describes how a light can be driven.
+type Caps struct {
+	// Gamut is the light's color gamut.
+	Gamut color.Gamut
+
+	// Device identifies the device for rate limiting: lights with the same
+	// Device share its request budget.
+	Device string
+
+	// MinInterval is the minimum interval between requests to the device.
+	MinInterval time.Duration
+
+	// MaxTransition is the longest transition the device accepts; zero is
+	// unlimited.
+	MaxTransition time.Duration
+
+	// Transition reports whether the firmware fades to new values itself.
+	Transition bool
+}
+
+// Light is a light an Engine drives.
+type Light interface {
+	// Caps returns the light's capabilities.
+	Caps() Caps
+
+	// Set changes the light to the state, fading over transition if the
+	// light supports transitions.
+	Set(ctx context.Context, s State, transition time.Duration) error
+}
+
+// LightOption configures a light adapter.
+type LightOption func(*Caps)
+
+// WithGamut sets the light's gamut, e.g. from color.ForProfile.
+func WithGamut(g color.Gamut) LightOption {
+	return func(c *Caps) {
+		c.Gamut = g
+	}
+}
+
+// WithDevice sets the rate limiting key, to share one device's request
+// budget between several adapters.
+func WithDevice(device string) LightOption {
+	return func(c *Caps) {
+		c.Device = device
+	}
+}
+
+// WithMinInterval sets the minimum interval between requests to the
+// device.
+func WithMinInterval(d time.Duration) LightOption {
+	return func(c *Caps) {
+		c.MinInterval = d
+	}
+}
+
+// WithoutTransition makes the engine step the light client-side, for
+// firmware without transition support.
+func WithoutTransition() LightOption {
+	return func(c *Caps) {
+		c.Transition = false
+	}
+}
+
+func newCaps(caps Caps, opts []LightOption) Caps {
+	for _, opt := range opts {
+		opt(&caps)
+	}
+	return caps
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func brightness(s *State) int {
+	return int(math.Round(min(max(s.Brightness, 0), 100)))
+}
+
+// Gen2Light drives a dimmable Gen2 Light component. Colors are ignored.
+// Light.Set takes whole seconds, so transitions are rounded up.
+func Gen2Light(l *components.Light, opts ...LightOption) Light {
+	return &gen2Light{
+		light: l,
+		caps: newCaps(Caps{
+			Device:      fmt.Sprintf("%p", l.Client()),
+			MinInterval: DefaultGen2Interval,
+			Transition:  true,
+		}, opts),
+	}
+}
+
+type gen2Light struct {
+	light *components.Light
+	caps  Caps
+}
+
+func (l *gen2Light) Caps() Caps {
+	return l.caps
+}
+
+func (l *gen2Light) Set(ctx context.Context, s State, transition time.Duration) error {
+	params := &components.LightSetParams{On: ptr(s.On)}
+	if s.On {
+		params.Brightness = ptr(brightness(&s))
+	}
+	if transition > 0 && l.caps.Transition {
+		params.TransitionDuration = ptr(int(math.Ceil(transition.Seconds())))
+	}
+	_, err := l.light.Set(ctx, params)
+	return err
+}
+
+// Gen2RGB drives a Gen2 RGB component. The default gamut is color.SRGB.
+func Gen2RGB(r *components.RGB, opts ...LightOption) Light {
+	return &gen2RGB{
+		rgb: r,
+		caps: newCaps(Caps{
+			Gamut:       color.SRGB,
+			Device:      fmt.Sprintf("%p", r.Client()),
+			MinInterval: DefaultGen2Interval,
+			Transition:  true,
+		}, opts),
+	}
+}
+
+type gen2RGB struct {
+	rgb  *components.RGB
+	caps Caps
+}
+
+func (l *gen2RGB) Caps() Caps {
+	return l.caps
+}
+
+func (l *gen2RGB) Set(ctx context.Context, s State, transition time.Duration) error {
+	params := &components.RGBSetParams{On: ptr(s.On)}
+	if s.On {
+		params.Brightness = ptr(brightness(&s))
+		if xy, ok := s.XY(); ok {
+			params.RGB = l.caps.Gamut.Drive(xy).Ints()
+		}
+	}
+	if transition > 0 && l.caps.Transition {
+		params.TransitionDuration = ptr(transition.Seconds())
+	}
+	_, err := l.rgb.Set(ctx, params)
+	return err
+}
+
+// Gen2RGBW drives a Gen2 RGBW component, producing whites on the white
+// channel. The default gamut is color.SRGB with a white channel of
+// color.DefaultWhiteKelvin.
+func Gen2RGBW(r *components.RGBW, opts ...LightOption) Light {
+	gamut := color.SRGB
+	gamut.White = color.KelvinXY(color.DefaultWhiteKelvin)
+	return &gen2RGBW{
+		rgbw: r,
+		caps: newCaps(Caps{
+			Gamut:       gamut,
+			Device:      fmt.Sprintf("%p", r.Client()),
+			MinInterval: DefaultGen2Interval,
+			Transition:  true,
+		}, opts),
+	}
+}
+
+type gen2RGBW struct {
+	rgbw *components.RGBW
+	caps Caps
+}
+
+func (l *gen2RGBW) Caps() Caps {
+	return l.caps
+}
+
+func (l *gen2RGBW) Set(ctx context.Context, s State, transition time.Duration) error {
+	params := &components.RGBWSetParams{On: ptr(s.On)}
+	if s.On {
+		params.Brightness = ptr(brightness(&s))
+		if xy, ok := s.XY(); ok {
+			rgb, white := l.caps.Gamut.RGBW(xy).Ints()
+			params.RGB, params.White = rgb, ptr(white)
+		}
+	}
+	if transition > 0 && l.caps.Transition {
+		params.TransitionDuration = ptr(transition.Seconds())
+	}
+	_, err := l.rgbw.Set(ctx, params)
+	return err
+}
+
+// Gen1Color drives a Gen1 Color component (RGBW2, Bulb) in color mode.
+// The default gamut is color.SRGB with a white channel of
+// color.DefaultWhiteKelvin. A state without color shows the white
+// channel. Transitions are limited to 5 seconds.
+//
+// Each adapter is rate limited on its own; use WithDevice to share a
+// device's budget between several channels.
+func Gen1Color(c *gen1components.Color, opts ...LightOption) Light {
+	gamut := color.SRGB
+	gamut.White = color.KelvinXY(color.DefaultWhiteKelvin)
+	return &gen1Color{
+		color: c,
+		caps: newCaps(Caps{
+			Gamut:         gamut,
+			Device:        fmt.Sprintf("%p", c),
+			MinInterval:   DefaultGen1Interval,
+			MaxTransition: gen1MaxTransition,
+			Transition:    true,
+		}, opts),
+	}
+}
+
+type gen1Color struct {
+	color *gen1components.Color
+	caps  Caps
+}
+
+func (l *gen1Color) Caps() Caps {
+	return l.caps
+}
+
+func (l *gen1Color) Set(ctx context.Context, s State, transition time.Duration) error {
+	if !s.On {
+		return l.color.TurnOff(ctx)
+	}
+	levels := color.RGBW{W: 1}
+	if xy, ok := s.XY(); ok {
+		levels = l.caps.Gamut.RGBW(xy)
+	}
+	rgb, white := levels.Ints()
+	return l.color.TurnOnWithRGBW(ctx, rgb[0], rgb[1], rgb[2], white, brightness(&s), gen1Transition(&l.caps, transition))
+}
+
+// Gen1White drives a Gen1 White component (Duo, Bulb in white mode). The
+// default gamut is the color.DefaultMinKelvin-DefaultMaxKelvin range;
+// colors are shown as their color temperature. Transitions are limited to
+// 5 seconds.
+func Gen1White(w *gen1components.White, opts ...LightOption) Light {
+	return &gen1White{
+		white: w,
+		caps: newCaps(Caps{
+			Gamut:         color.Gamut{MinKelvin: color.DefaultMinKelvin, MaxKelvin: color.DefaultMaxKelvin},
+			Device:        fmt.Sprintf("%p", w),
+			MinInterval:   DefaultGen1Interval,
+			MaxTransition: gen1MaxTransition,
+			Transition:    true,
+		}, opts),
+	}
+}
+
+type gen1White struct {
+	white *gen1components.White
+	caps  Caps
+}
+
+func (l *gen1White) Caps() Caps {
+	return l.caps
+}
+
+func (l *gen1White) Set(ctx context.Context, s State, transition time.Duration) error {
+	if !s.On {
+		return l.white.TurnOff(ctx)
+	}
+	kelvin := s.Kelvin
+	if s.Color != nil {
+		kelvin = s.Color.Kelvin()
+	}
+	temp := 0
+	if kelvin > 0 {
+		temp = int(math.Round(l.caps.Gamut.ClampKelvin(kelvin)))
+	}
+	return l.white.TurnOnWithTransition(ctx, temp, brightness(&s), gen1Transition(&l.caps, transition))
+}
+
+func gen1Transition(caps *Caps, transition time.Duration) int {
+	if !caps.Transition {
+		return 0
+	}
+	return int(min(transition, gen1MaxTransition).Milliseconds())
+}
@@ -0,0 +1,203 @@
+package effects
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	gen1components "github.com/tj-smith47/shelly-go/gen1/components"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/lighting/color"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing. It records
+// the method (or Gen1 path) and parameters of each call.
+type mockTransport struct {
+	methods []string
+	params  []map[string]any
+}
+
+func (m *mockTransport) Call(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	m.methods = append(m.methods, req.GetMethod())
+	var p map[string]any
+	_ = json.Unmarshal(req.GetParams(), &p)
+	m.params = append(m.params, p)
+	return jsonrpcResponse(map[string]any{"was_on": false})
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+func TestGen2Adapters(t *testing.T) {
+	orange := color.RGB{R: 1, G: 0.5}.XY()
+	tests := []struct {
+		name       string
+		light      func(*rpc.Client) Light
+		state      State
+		transition time.Duration
+		method     string
+		want       map[string]any
+	}{
+		{
+			name:       "light",
+			light:      func(c *rpc.Client) Light { return Gen2Light(components.NewLight(c, 0)) },
+			state:      State{On: true, Brightness: 42.4, Kelvin: 3000},
+			transition: 1500 * time.Millisecond,
+			method:     "Light.Set",
+			want:       map[string]any{"id": 0.0, "on": true, "brightness": 42.0, "transition_duration": 2.0},
+		},
+		{
+			name:   "light off",
+			light:  func(c *rpc.Client) Light { return Gen2Light(components.NewLight(c, 1)) },
+			state:  State{Brightness: 50},
+			method: "Light.Set",
+			want:   map[string]any{"id": 1.0, "on": false},
+		},
+		{
+			name:       "rgb",
+			light:      func(c *rpc.Client) Light { return Gen2RGB(components.NewRGB(c, 0)) },
+			state:      State{On: true, Brightness: 80, Color: &orange},
+			transition: 500 * time.Millisecond,
+			method:     "RGB.Set",
+			want:       map[string]any{"id": 0.0, "on": true, "brightness": 80.0, "rgb": []any{255.0, 55.0, 0.0}, "transition_duration": 0.5},
+		},
+		{
+			name:       "rgb without transition",
+			light:      func(c *rpc.Client) Light { return Gen2RGB(components.NewRGB(c, 0), WithoutTransition()) },
+			state:      State{On: true, Brightness: 80},
+			transition: time.Second,
+			method:     "RGB.Set",
+			want:       map[string]any{"id": 0.0, "on": true, "brightness": 80.0},
+		},
+		{
+			name:   "rgbw white",
+			light:  func(c *rpc.Client) Light { return Gen2RGBW(components.NewRGBW(c, 0)) },
+			state:  State{On: true, Brightness: 100, Kelvin: color.DefaultWhiteKelvin},
+			method: "RGBW.Set",
+			want:   map[string]any{"id": 0.0, "on": true, "brightness": 100.0, "rgb": []any{0.0, 0.0, 0.0}, "white": 255.0},
+		},
+		{
+			name:   "rgbw saturated",
+			light:  func(c *rpc.Client) Light { return Gen2RGBW(components.NewRGBW(c, 0)) },
+			state:  State{On: true, Brightness: 100, Color: &color.SRGB.Blue},
+			method: "RGBW.Set",
+			want:   map[string]any{"id": 0.0, "on": true, "brightness": 100.0, "rgb": []any{0.0, 0.0, 255.0}, "white": 0.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockTransport{}
+			light := tt.light(rpc.NewClient(mock))
+			if err := light.Set(context.Background(), tt.state, tt.transition); err != nil {
+				t.Fatal(err)
+			}
+			if len(mock.methods) != 1 || mock.methods[0] != tt.method {
+				t.Fatalf("methods = %v", mock.methods)
+			}
+			got, _ := json.Marshal(mock.params[0])
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("params = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGen2Adapters_Caps(t *testing.T) {
+	client := rpc.NewClient(&mockTransport{})
+	a := Gen2RGB(components.NewRGB(client, 0)).Caps()
+	b := Gen2Light(components.NewLight(client, 1), WithMinInterval(time.Second)).Caps()
+	if a.Device != b.Device || !a.Transition || a.MinInterval != DefaultGen2Interval || b.MinInterval != time.Second {
+		t.Errorf("caps = %+v, %+v", a, b)
+	}
+	g := color.Gamut{MinKelvin: 3000, MaxKelvin: 5000}
+	if c := Gen2RGBW(components.NewRGBW(client, 0), WithGamut(g), WithDevice("x")).Caps(); c.Gamut != g || c.Device != "x" {
+		t.Errorf("caps = %+v", c)
+	}
+}
+
+func TestGen1Adapters(t *testing.T) {
+	tests := []struct {
+		name       string
+		light      func(transport.Transport) Light
+		state      State
+		transition time.Duration
+		want       string
+	}{
+		{
+			name:       "color",
+			light:      func(tr transport.Transport) Light { return Gen1Color(gen1components.NewColor(tr, 0)) },
+			state:      State{On: true, Brightness: 60, Color: &color.SRGB.Red},
+			transition: 800 * time.Millisecond,
+			want:       "/color/0?turn=on&red=255&green=0&blue=0&white=0&gain=60&transition=800",
+		},
+		{
+			name:  "color without color",
+			light: func(tr transport.Transport) Light { return Gen1Color(gen1components.NewColor(tr, 0)) },
+			state: State{On: true, Brightness: 30},
+			want:  "/color/0?turn=on&red=0&green=0&blue=0&white=255&gain=30&transition=0",
+		},
+		{
+			name:       "color long transition",
+			light:      func(tr transport.Transport) Light { return Gen1Color(gen1components.NewColor(tr, 1)) },
+			state:      State{On: true, Brightness: 30},
+			transition: time.Minute,
+			want:       "/color/1?turn=on&red=0&green=0&blue=0&white=255&gain=30&transition=5000",
+		},
+		{
+			name:  "color off",
+			light: func(tr transport.Transport) Light { return Gen1Color(gen1components.NewColor(tr, 0)) },
+			state: State{},
+			want:  "/color/0?turn=off",
+		},
+		{
+			name:       "white clamps kelvin",
+			light:      func(tr transport.Transport) Light { return Gen1White(gen1components.NewWhite(tr, 0)) },
+			state:      State{On: true, Brightness: 20, Kelvin: 2000},
+			transition: time.Second,
+			want:       "/white/0?turn=on&brightness=20&transition=1000&temp=2700",
+		},
+		{
+			name:  "white from color",
+			light: func(tr transport.Transport) Light { return Gen1White(gen1components.NewWhite(tr, 0)) },
+			state: State{On: true, Brightness: 20, Color: &color.D65},
+			want:  "/white/0?turn=on&brightness=20&transition=0&temp=6500",
+		},
+		{
+			name: "white keeps temp",
+			light: func(tr transport.Transport) Light {
+				return Gen1White(gen1components.NewWhite(tr, 0), WithoutTransition())
+			},
+			state: State{On: true, Brightness: 20},
+			want:  "/white/0?turn=on&brightness=20&transition=0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockTransport{}
+			if err := tt.light(mock).Set(context.Background(), tt.state, tt.transition); err != nil {
+				t.Fatal(err)
+			}
+			if len(mock.methods) != 1 || mock.methods[0] != tt.want {
+				t.Errorf("calls = %v, want %s", mock.methods, tt.want)
+			}
+		})
+	}
+}
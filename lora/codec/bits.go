@@ -0,0 +1,43 @@
+package codec
+
+// bitWriter appends values most significant bit first.
+type bitWriter struct {
+	buf  []byte
+	used uint // bits used in the last byte, 0-7; 0 means a new byte is needed
+}
+
+func (w *bitWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.used == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>uint(i)&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> w.used
+		}
+		w.used = (w.used + 1) % 8
+	}
+}
+
+// bitReader reads values most significant bit first.
+type bitReader struct {
+	buf []byte
+	pos int // bit position
+}
+
+// remaining returns the number of unread bits.
+func (r *bitReader) remaining() int {
+	return len(r.buf)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (uint64, bool) {
+	if n > r.remaining() {
+		return 0, false
+	}
+	var v uint64
+	for range n {
+		bit := r.buf[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v, true
+}
@@ -0,0 +1,154 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/tj-smith47/shelly-go/lora"
+)
+
+// Option configures a Codec.
+type Option func(*Codec)
+
+// WithErrorHandler sets a callback for routed messages that fail to
+// decode.
+func WithErrorHandler(fn func(msg *lora.RoutedMessage, err error)) Option {
+	return func(c *Codec) {
+		c.onError = fn
+	}
+}
+
+// Codec encodes and decodes messages of a set of schemas, identified by
+// the message type in the first byte.
+type Codec struct {
+	byType  map[uint8]*Schema
+	byGo    map[reflect.Type]*Schema
+	onError func(msg *lora.RoutedMessage, err error)
+	mu      sync.RWMutex
+}
+
+// NewCodec creates a codec for the given schemas.
+func NewCodec(schemas []*Schema, opts ...Option) (*Codec, error) {
+	c := &Codec{
+		byType: make(map[uint8]*Schema),
+		byGo:   make(map[reflect.Type]*Schema),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.Register(schemas...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Register adds schemas. Each message type and Go type may be registered
+// once.
+func (c *Codec) Register(schemas ...*Schema) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range schemas {
+		if old, ok := c.byType[s.Type]; ok {
+			return fmt.Errorf("%w: 0x%02x for %s and %s", ErrDuplicateType, s.Type, old.Name, s.Name)
+		}
+		if old, ok := c.byGo[s.typ]; ok {
+			return fmt.Errorf("%w: %s as 0x%02x and 0x%02x", ErrDuplicateType, s.typ, old.Type, s.Type)
+		}
+		c.byType[s.Type] = s
+		c.byGo[s.typ] = s
+	}
+	return nil
+}
+
+// Schema returns the schema of a message type.
+func (c *Codec) Schema(typ uint8) (*Schema, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.byType[typ]
+	return s, ok
+}
+
+// Schemas returns the registered schemas ordered by message type.
+func (c *Codec) Schemas() []*Schema {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	schemas := make([]*Schema, 0, len(c.byType))
+	for _, s := range c.byType {
+		schemas = append(schemas, s)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Type < schemas[j].Type })
+	return schemas
+}
+
+// Encode encodes v, a value or pointer of a registered struct type.
+//
+// Example:
+//
+//	data, err := c.Encode(&PumpStatus{Mode: "auto", Running: true, Pressure: 2.35})
+//	err = radio.SendRaw(ctx, data)
+func (c *Codec) Encode(v any) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	c.mu.RLock()
+	s, ok := c.byGo[t]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnknownType, v)
+	}
+	return s.Encode(v)
+}
+
+// Decode decodes a message into a new value of its schema's struct type
+// and returns a pointer to it.
+func (c *Codec) Decode(data []byte) (any, error) {
+	if len(data) < HeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrShortPayload, len(data))
+	}
+	s, ok := c.Schema(data[0])
+	if !ok {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrUnknownType, data[0])
+	}
+	v := reflect.New(s.typ).Interface()
+	if err := s.Decode(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Handle registers fn on router for messages of T's schema. Messages that
+// fail to decode go to the codec's error handler.
+//
+// Example:
+//
+//	codec.Handle(c, router, func(msg *lora.RoutedMessage, status *PumpStatus) {
+//	    fmt.Printf("%s: %s, %.2f bar\n", msg.FromDevice, status.Mode, status.Pressure)
+//	})
+func Handle[T any](c *Codec, router *lora.MessageRouter, fn func(msg *lora.RoutedMessage, v *T)) error {
+	t := reflect.TypeFor[T]()
+	c.mu.RLock()
+	s, ok := c.byGo[t]
+	c.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownType, t)
+	}
+
+	router.Handle(func(msg *lora.RoutedMessage) {
+		v := new(T)
+		if err := s.Decode(msg.Data, v); err != nil {
+			if c.onError != nil {
+				c.onError(msg, err)
+			}
+			return
+		}
+		fn(msg, v)
+	}, &lora.MessageFilter{Custom: func(msg *lora.RoutedMessage) bool {
+		return len(msg.Data) >= HeaderSize && msg.Data[0] == s.Type
+	}})
+	return nil
+}
@@ -0,0 +1,265 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/tj-smith47/shelly-go/lora"
+)
+
+type pumpStatus struct {
+	Mode     string
+	Pressure float64
+	FlowRate float64
+	Running  bool
+}
+
+type pumpStatusV1 struct {
+	Mode     string
+	Pressure float64
+	Running  bool
+}
+
+type reading struct {
+	Offset  int8
+	Battery uint8
+	Alarm   int
+	Temp    float32
+	Counter uint32
+}
+
+var (
+	statusV2 = MustSchema[pumpStatus](0x10, 2,
+		Enum("Mode", "off", "auto", "manual"),
+		Bool("Running"),
+		Float("Pressure", 10, 0.01, 0),
+		Float("FlowRate", 12, 0.1, 0).AddedIn(2))
+
+	statusV1 = MustSchema[pumpStatusV1](0x10, 1,
+		Enum("Mode", "off", "auto", "manual"),
+		Bool("Running"),
+		Float("Pressure", 10, 0.01, 0))
+
+	readingSchema = MustSchema[reading](0x20, 1,
+		Float("Temp", 11, 0.1, -40),
+		Int("Offset", 5),
+		Uint("Battery", 7).Clamped(),
+		Enum("Alarm", "none", "low", "high", "fault", "leak").WithBits(4),
+		Uint("Counter", 20))
+)
+
+func TestSchema_Encode(t *testing.T) {
+	data, err := statusV2.Encode(pumpStatus{Mode: "manual", Running: true, Pressure: 2.35, FlowRate: 12.5})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	// 10 | 1 | 0011101011 (235) | 000001111101 (125) | pad
+	want := []byte{0x10, 0x02, 0b10100111, 0b01011000, 0b00111110, 0b10000000}
+	if !bytes.Equal(data, want) || statusV2.Size() != len(want) {
+		t.Errorf("Encode() = %08b, want %08b (size %d)", data, want, statusV2.Size())
+	}
+
+	var got pumpStatus
+	if err := statusV2.Decode(data, &got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Mode != "manual" || !got.Running || math.Abs(got.Pressure-2.35) > 1e-9 || math.Abs(got.FlowRate-12.5) > 1e-9 {
+		t.Errorf("Decode() = %+v", got)
+	}
+}
+
+func TestSchema_RoundTrip(t *testing.T) {
+	tests := []struct {
+		in   reading
+		want reading
+		name string
+	}{
+		{name: "typical", in: reading{Temp: 21.4, Offset: -3, Battery: 87, Alarm: 2, Counter: 1000},
+			want: reading{Temp: 21.4, Offset: -3, Battery: 87, Alarm: 2, Counter: 1000}},
+		{name: "limits", in: reading{Temp: -40, Offset: -16, Battery: 127, Alarm: 4, Counter: 1<<20 - 1},
+			want: reading{Temp: -40, Offset: -16, Battery: 127, Alarm: 4, Counter: 1<<20 - 1}},
+		{name: "clamped", in: reading{Temp: 164.7, Offset: 15, Battery: 200},
+			want: reading{Temp: 164.7, Offset: 15, Battery: 127}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readingSchema.Encode(&tt.in)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if len(data) != 8 {
+				t.Errorf("len = %d, want 8", len(data))
+			}
+			var got reading
+			if err := readingSchema.Decode(data, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if math.Abs(float64(got.Temp-tt.want.Temp)) > 0.01 {
+				t.Errorf("Temp = %v, want %v", got.Temp, tt.want.Temp)
+			}
+			got.Temp = tt.want.Temp
+			if got != tt.want {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_EncodeErrors(t *testing.T) {
+	tests := []struct {
+		in   any
+		want error
+		name string
+	}{
+		{name: "float range", in: reading{Temp: -41}, want: ErrOutOfRange},
+		{name: "int range", in: reading{Offset: 16}, want: ErrOutOfRange},
+		{name: "uint range", in: reading{Counter: 1 << 20}, want: ErrOutOfRange},
+		{name: "enum index", in: reading{Alarm: 5}, want: ErrOutOfRange},
+		{name: "wrong type", in: pumpStatus{}, want: ErrUnknownType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readingSchema.Encode(tt.in); !errors.Is(err, tt.want) {
+				t.Errorf("Encode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := statusV2.Encode(pumpStatus{Mode: "boost"}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Encode(unknown enum) error = %v", err)
+	}
+}
+
+func TestSchema_Versioning(t *testing.T) {
+	// An old node decodes a new message and ignores the new field
+	data, err := statusV2.Encode(pumpStatus{Mode: "auto", Running: true, Pressure: 1.5, FlowRate: 40})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var old pumpStatusV1
+	if err := statusV1.Decode(data, &old); err != nil {
+		t.Fatalf("old Decode() error = %v", err)
+	}
+	if old != (pumpStatusV1{Mode: "auto", Running: true, Pressure: 1.5}) {
+		t.Errorf("old Decode() = %+v", old)
+	}
+
+	// A new node decodes an old message and leaves the new field zero
+	data, err = statusV1.Encode(old)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if len(data) != 4 {
+		t.Errorf("v1 len = %d, want 4", len(data))
+	}
+	got := pumpStatus{FlowRate: 99}
+	if err := statusV2.Decode(data, &got); err != nil {
+		t.Fatalf("new Decode() error = %v", err)
+	}
+	if got != (pumpStatus{Mode: "auto", Running: true, Pressure: 1.5}) {
+		t.Errorf("new Decode() = %+v", got)
+	}
+
+	// A truncated message of the claimed version is rejected
+	data, _ = statusV2.Encode(pumpStatus{Mode: "off"})
+	if err := statusV2.Decode(data[:4], &got); !errors.Is(err, ErrShortPayload) {
+		t.Errorf("Decode(truncated) error = %v", err)
+	}
+}
+
+func TestNewSchema_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []Field
+	}{
+		{name: "missing field", fields: []Field{Bool("Missing")}},
+		{name: "kind mismatch", fields: []Field{Bool("Mode")}},
+		{name: "zero width", fields: []Field{Uint("Pressure", 0)}},
+		{name: "float scale", fields: []Field{Float("Pressure", 8, 0, 0)}},
+		{name: "enum width", fields: []Field{Enum("Mode", "a", "b", "c").WithBits(1)}},
+		{name: "future field", fields: []Field{Bool("Running").AddedIn(3)}},
+		{name: "out of order", fields: []Field{Bool("Running").AddedIn(2), Float("Pressure", 8, 1, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSchema[pumpStatus](1, 2, tt.fields...); !errors.Is(err, ErrInvalidSchema) {
+				t.Errorf("NewSchema() error = %v, want ErrInvalidSchema", err)
+			}
+		})
+	}
+	if _, err := NewSchema[int](1, 1); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("NewSchema[int]() error = %v", err)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	c, err := NewCodec([]*Schema{statusV2, readingSchema})
+	if err != nil {
+		t.Fatalf("NewCodec() error = %v", err)
+	}
+	if err := c.Register(statusV1); !errors.Is(err, ErrDuplicateType) {
+		t.Errorf("Register(duplicate) error = %v", err)
+	}
+
+	in := &reading{Temp: 3.5, Battery: 50, Counter: 7}
+	data, err := c.Encode(in)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	v, err := c.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got, ok := v.(*reading); !ok || !reflect.DeepEqual(got, in) {
+		t.Errorf("Decode() = %#v", v)
+	}
+
+	if _, err := c.Encode(pumpStatusV1{}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Encode(unregistered) error = %v", err)
+	}
+	if _, err := c.Decode([]byte{0x99, 1}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Decode(unknown) error = %v", err)
+	}
+	if got := c.Schemas(); len(got) != 2 || got[0] != statusV2 {
+		t.Errorf("Schemas() = %v", got)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	var decodeErrs []error
+	c, err := NewCodec([]*Schema{statusV2, readingSchema},
+		WithErrorHandler(func(_ *lora.RoutedMessage, err error) { decodeErrs = append(decodeErrs, err) }))
+	if err != nil {
+		t.Fatalf("NewCodec() error = %v", err)
+	}
+	router := lora.NewMessageRouter()
+
+	var statuses []*pumpStatus
+	var readings []*reading
+	if err := Handle(c, router, func(_ *lora.RoutedMessage, v *pumpStatus) { statuses = append(statuses, v) }); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if err := Handle(c, router, func(_ *lora.RoutedMessage, v *reading) { readings = append(readings, v) }); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if err := Handle(c, router, func(*lora.RoutedMessage, *pumpStatusV1) {}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Handle(unregistered) error = %v", err)
+	}
+
+	data, _ := c.Encode(&pumpStatus{Mode: "off"})
+	if n, _ := router.Route(&lora.RoutedMessage{FromDevice: "0002", Data: data}); n != 1 {
+		t.Errorf("Route() handlers = %d, want 1", n)
+	}
+	data, _ = c.Encode(&reading{Battery: 9})
+	router.Route(&lora.RoutedMessage{Data: data})            //nolint:errcheck // Router is running
+	router.Route(&lora.RoutedMessage{Data: []byte{0x20, 1}}) //nolint:errcheck // Router is running
+
+	if len(statuses) != 1 || statuses[0].Mode != "off" || len(readings) != 1 || readings[0].Battery != 9 {
+		t.Errorf("statuses = %v, readings = %v", statuses, readings)
+	}
+	if len(decodeErrs) != 1 || !errors.Is(decodeErrs[0], ErrShortPayload) {
+		t.Errorf("decode errors = %v", decodeErrs)
+	}
+}
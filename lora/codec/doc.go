@@ -0,0 +1,52 @@
+// Package codec encodes structured LoRa messages as compact bit-packed
+// payloads.
+//
+// LoRa frames are small and slow to send, so JSON or even fixed-width
+// binary structs waste airtime. A Schema declares once how each field of a
+// Go struct is packed: integers of any width, floats scaled to a fixed
+// resolution, enums as value indexes, and booleans as single bits.
+//
+// # Declaring Schemas
+//
+//	type PumpStatus struct {
+//	    Mode     string
+//	    Pressure float64
+//	    FlowRate float64
+//	    Running  bool
+//	}
+//
+//	var pumpStatus = codec.MustSchema[PumpStatus](0x10, 2,
+//	    codec.Enum("Mode", "off", "auto", "manual"), // 2 bits
+//	    codec.Bool("Running"),                       // 1 bit
+//	    codec.Float("Pressure", 10, 0.01, 0),        // 0-10.23 bar
+//	    codec.Float("FlowRate", 12, 0.1, 0).AddedIn(2))
+//
+// The status above encodes to 6 bytes: a 2-byte header with the message
+// type and schema version, then 25 bits of fields.
+//
+// # Encoding and Routing
+//
+// A Codec holds the schemas of an application:
+//
+//	c, err := codec.NewCodec([]*codec.Schema{pumpStatus, pumpCommand})
+//
+//	data, err := c.Encode(&PumpStatus{Mode: "auto", Running: true, Pressure: 2.4})
+//	err = radio.SendRaw(ctx, data) // or a lora/link Link for confirmed delivery
+//
+// On the receiving side, Handle registers typed handlers on a
+// lora.MessageRouter, matched by message type:
+//
+//	codec.Handle(c, router, func(msg *lora.RoutedMessage, s *PumpStatus) {
+//	    fmt.Printf("%s: %s at %.2f bar\n", msg.FromDevice, s.Mode, s.Pressure)
+//	})
+//
+// # Versioning
+//
+// Nodes in the field are rarely updated at the same time. Each message
+// carries its schema version, and schemas evolve only by appending
+// fields marked with AddedIn and by appending enum values within the
+// field's width. An older node decodes the fields it knows and ignores the
+// rest; a newer node leaves fields the message lacks at their zero value.
+// Fields are never removed or reordered; retire a field by no longer
+// setting it.
+package codec
@@ -0,0 +1,488 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"slices"
+)
+
+// HeaderSize is the size of the message header: message type and schema
+// version.
+const HeaderSize = 2
+
+// Errors returned by schemas and the codec.
+var (
+	// ErrInvalidSchema indicates a schema that doesn't match its Go type
+	// or breaks the versioning rules.
+	ErrInvalidSchema = errors.New("invalid schema")
+
+	// ErrOutOfRange indicates a value that doesn't fit its field.
+	ErrOutOfRange = errors.New("value out of range")
+
+	// ErrShortPayload indicates a payload that ends before its fields.
+	ErrShortPayload = errors.New("payload too short")
+
+	// ErrUnknownType indicates a message type or Go type without a
+	// registered schema.
+	ErrUnknownType = errors.New("unknown message type")
+
+	// ErrDuplicateType indicates a message type or Go type registered
+	// twice.
+	ErrDuplicateType = errors.New("message type already registered")
+)
+
+// Kind is the encoding of a field.
+type Kind int
+
+// Field kinds.
+const (
+	// KindUint is an unsigned integer.
+	KindUint Kind = iota
+
+	// KindInt is a two's complement signed integer.
+	KindInt
+
+	// KindFloat is a float stored as an unsigned count of Scale steps
+	// above Min.
+	KindFloat
+
+	// KindEnum is the index of a value in Values.
+	KindEnum
+
+	// KindBool is a single bit.
+	KindBool
+)
+
+// String returns the kind name.
+func (k Kind) String() string {
+	switch k {
+	case KindUint:
+		return "uint"
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindEnum:
+		return "enum"
+	case KindBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Field describes how a struct field is encoded.
+type Field struct {
+	// Name is the Go struct field name.
+	Name string
+
+	// Values are the enum values, in wire order.
+	Values []string
+
+	// Scale is the resolution of a float, e.g. 0.1.
+	Scale float64
+
+	// Min is the smallest float value, encoded as 0.
+	Min float64
+
+	// Kind is the field encoding.
+	Kind Kind
+
+	// Bits is the field width.
+	Bits int
+
+	// Since is the schema version the field was added in. 0 and 1 both
+	// mean the first version.
+	Since uint8
+
+	// Clamp stores out of range values as the nearest representable value
+	// instead of failing.
+	Clamp bool
+}
+
+// Uint declares an unsigned integer field of the given width.
+func Uint(name string, width int) Field {
+	return Field{Name: name, Kind: KindUint, Bits: width}
+}
+
+// Int declares a signed integer field of the given width.
+func Int(name string, width int) Field {
+	return Field{Name: name, Kind: KindInt, Bits: width}
+}
+
+// Float declares a scaled float field covering min up to
+// min + scale*(2^width - 1) in steps of scale.
+//
+// Example:
+//
+//	codec.Float("Temperature", 11, 0.1, -40) // -40.0 to 164.7 °C
+func Float(name string, width int, scale, minimum float64) Field {
+	return Field{Name: name, Kind: KindFloat, Bits: width, Scale: scale, Min: minimum}
+}
+
+// Enum declares a field holding one of values, as the smallest number of
+// bits that fits every index. The Go field may be a string or an integer
+// index. Values may be appended in later versions while the width holds.
+func Enum(name string, values ...string) Field {
+	return Field{Name: name, Kind: KindEnum, Bits: max(1, bits.Len(uint(len(values)-1))), Values: values}
+}
+
+// Bool declares a one bit field.
+func Bool(name string) Field {
+	return Field{Name: name, Kind: KindBool, Bits: 1}
+}
+
+// AddedIn returns the field marked as added in schema version v.
+func (f Field) AddedIn(v uint8) Field {
+	f.Since = v
+	return f
+}
+
+// Clamped returns the field set to clamp out of range values.
+func (f Field) Clamped() Field {
+	f.Clamp = true
+	return f
+}
+
+// WithBits returns the field with a different width, e.g. to reserve room
+// for enum values added later.
+func (f Field) WithBits(width int) Field {
+	f.Bits = width
+	return f
+}
+
+func (f *Field) since() uint8 {
+	return max(f.Since, 1)
+}
+
+// Schema describes the wire format of one message type.
+//
+// Fields are packed in order, most significant bit first, after a header
+// of the message type and schema version, and the last byte is padded with
+// zeros. To keep nodes running older or newer firmware interoperating,
+// new fields are only ever appended, marked with AddedIn: a decoder leaves
+// fields newer than the message at their zero value and ignores fields
+// newer than its schema.
+type Schema struct {
+	typ     reflect.Type
+	Name    string
+	fields  []Field
+	index   [][]int
+	Type    uint8
+	Version uint8
+}
+
+// NewSchema declares the wire format of message type typ for the Go
+// struct T.
+//
+// Example:
+//
+//	type PumpStatus struct {
+//	    Mode      string
+//	    Pressure  float64
+//	    Running   bool
+//	    FlowRate  float64
+//	}
+//
+//	schema, err := codec.NewSchema[PumpStatus](0x10, 2,
+//	    codec.Enum("Mode", "off", "auto", "manual"),
+//	    codec.Bool("Running"),
+//	    codec.Float("Pressure", 10, 0.01, 0),
+//	    codec.Float("FlowRate", 12, 0.1, 0).AddedIn(2))
+func NewSchema[T any](typ, version uint8, fields ...Field) (*Schema, error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not a struct", ErrInvalidSchema, t)
+	}
+	s := &Schema{typ: t, Name: t.Name(), Type: typ, Version: max(version, 1), fields: slices.Clone(fields)}
+
+	var last uint8
+	for i := range s.fields {
+		f := &s.fields[i]
+		if err := f.validate(t); err != nil {
+			return nil, err
+		}
+		if f.since() > s.Version {
+			return nil, fmt.Errorf("%w: field %s added in version %d after schema version %d",
+				ErrInvalidSchema, f.Name, f.since(), s.Version)
+		}
+		if f.since() < last {
+			return nil, fmt.Errorf("%w: field %s of version %d follows a version %d field",
+				ErrInvalidSchema, f.Name, f.since(), last)
+		}
+		last = f.since()
+		sf, _ := t.FieldByName(f.Name)
+		s.index = append(s.index, sf.Index)
+	}
+	return s, nil
+}
+
+// MustSchema is like NewSchema but panics on error. It is meant for
+// package level schema declarations.
+func MustSchema[T any](typ, version uint8, fields ...Field) *Schema {
+	s, err := NewSchema[T](typ, version, fields...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// validate checks the field against the struct type.
+func (f *Field) validate(t reflect.Type) error {
+	sf, ok := t.FieldByName(f.Name)
+	if !ok || !sf.IsExported() {
+		return fmt.Errorf("%w: %s has no exported field %s", ErrInvalidSchema, t, f.Name)
+	}
+	if f.Bits < 1 || f.Bits > 64 {
+		return fmt.Errorf("%w: field %s width %d not in 1-64", ErrInvalidSchema, f.Name, f.Bits)
+	}
+
+	k := sf.Type.Kind()
+	var compatible bool
+	switch f.Kind {
+	case KindUint, KindInt:
+		compatible = isInt(k) || isUint(k)
+	case KindFloat:
+		compatible = k == reflect.Float32 || k == reflect.Float64
+		if !(f.Scale > 0) || f.Bits > 53 {
+			return fmt.Errorf("%w: float field %s needs a positive scale and at most 53 bits", ErrInvalidSchema, f.Name)
+		}
+	case KindEnum:
+		compatible = k == reflect.String || isInt(k) || isUint(k)
+		if len(f.Values) == 0 || len(f.Values) > 1<<min(f.Bits, 16) {
+			return fmt.Errorf("%w: enum field %s has %d values for %d bits", ErrInvalidSchema, f.Name, len(f.Values), f.Bits)
+		}
+	case KindBool:
+		compatible = k == reflect.Bool && f.Bits == 1
+	}
+	if !compatible {
+		return fmt.Errorf("%w: field %s of type %s can't be encoded as %s/%d", ErrInvalidSchema, f.Name, sf.Type, f.Kind, f.Bits)
+	}
+	return nil
+}
+
+// Fields returns the fields of the schema.
+func (s *Schema) Fields() []Field {
+	return slices.Clone(s.fields)
+}
+
+// GoType returns the struct type the schema encodes.
+func (s *Schema) GoType() reflect.Type {
+	return s.typ
+}
+
+// Size returns the encoded size in bytes of a message, header included.
+func (s *Schema) Size() int {
+	n := 0
+	for i := range s.fields {
+		n += s.fields[i].Bits
+	}
+	return HeaderSize + (n+7)/8
+}
+
+// Encode encodes v, a T or *T, as a message of the schema's current
+// version.
+func (s *Schema) Encode(v any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() || rv.Type() != s.typ {
+		return nil, fmt.Errorf("%w: %T for schema %s", ErrUnknownType, v, s.Name)
+	}
+
+	w := bitWriter{buf: make([]byte, HeaderSize, s.Size())}
+	w.buf[0], w.buf[1] = s.Type, s.Version
+	for i := range s.fields {
+		f := &s.fields[i]
+		raw, err := f.encode(rv.FieldByIndex(s.index[i]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s.%s: %w", s.Name, f.Name, err)
+		}
+		w.write(raw, f.Bits)
+	}
+	return w.buf, nil
+}
+
+// Decode decodes a message into out, a *T. Fields the message's version
+// lacks keep their zero value.
+func (s *Schema) Decode(data []byte, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Type() != s.typ {
+		return fmt.Errorf("%w: %T for schema %s", ErrUnknownType, out, s.Name)
+	}
+	if len(data) < HeaderSize {
+		return fmt.Errorf("%w: %d bytes", ErrShortPayload, len(data))
+	}
+	if data[0] != s.Type {
+		return fmt.Errorf("%w: 0x%02x for schema %s", ErrUnknownType, data[0], s.Name)
+	}
+	version := data[1]
+
+	rv = rv.Elem()
+	rv.SetZero()
+	r := bitReader{buf: data[HeaderSize:]}
+	for i := range s.fields {
+		f := &s.fields[i]
+		if f.since() > version {
+			break
+		}
+		raw, ok := r.read(f.Bits)
+		if !ok {
+			return fmt.Errorf("%w: %s.%s of version %d", ErrShortPayload, s.Name, f.Name, version)
+		}
+		if err := f.decode(raw, rv.FieldByIndex(s.index[i])); err != nil {
+			return fmt.Errorf("failed to decode %s.%s: %w", s.Name, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// encode converts a struct field value to its raw bits.
+//
+//nolint:gocyclo,cyclop // One case per field kind and Go kind
+func (f *Field) encode(v reflect.Value) (uint64, error) {
+	maxRaw := uint64(math.MaxUint64)
+	if f.Bits < 64 {
+		maxRaw = 1<<f.Bits - 1
+	}
+
+	switch f.Kind {
+	case KindBool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+
+	case KindUint:
+		var n uint64
+		if isInt(v.Kind()) {
+			i := v.Int()
+			if i < 0 {
+				if !f.Clamp {
+					return 0, fmt.Errorf("%w: %d", ErrOutOfRange, i)
+				}
+				i = 0
+			}
+			n = uint64(i)
+		} else {
+			n = v.Uint()
+		}
+		if n > maxRaw {
+			if !f.Clamp {
+				return 0, fmt.Errorf("%w: %d exceeds %d bits", ErrOutOfRange, n, f.Bits)
+			}
+			n = maxRaw
+		}
+		return n, nil
+
+	case KindInt:
+		lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+		if f.Bits < 64 {
+			lo, hi = -1<<(f.Bits-1), 1<<(f.Bits-1)-1
+		}
+		var i int64
+		if isInt(v.Kind()) {
+			i = v.Int()
+		} else {
+			u := v.Uint()
+			i = int64(min(u, uint64(math.MaxInt64))) //nolint:gosec // Clamped to MaxInt64
+		}
+		if i < lo || i > hi {
+			if !f.Clamp {
+				return 0, fmt.Errorf("%w: %d exceeds %d bits", ErrOutOfRange, i, f.Bits)
+			}
+			i = min(max(i, lo), hi)
+		}
+		return uint64(i) & maxRaw, nil
+
+	case KindFloat:
+		x := v.Float()
+		if math.IsNaN(x) {
+			return 0, fmt.Errorf("%w: NaN", ErrOutOfRange)
+		}
+		steps := math.Round((x - f.Min) / f.Scale)
+		if steps < 0 || steps > float64(maxRaw) {
+			if !f.Clamp {
+				return 0, fmt.Errorf("%w: %g not in %g to %g", ErrOutOfRange, x, f.Min, f.Min+f.Scale*float64(maxRaw))
+			}
+			steps = min(max(steps, 0), float64(maxRaw))
+		}
+		return uint64(steps), nil
+
+	case KindEnum:
+		var idx int
+		switch {
+		case v.Kind() == reflect.String:
+			idx = slices.Index(f.Values, v.String())
+			if idx < 0 {
+				return 0, fmt.Errorf("%w: unknown value %q", ErrOutOfRange, v.String())
+			}
+		case isInt(v.Kind()):
+			idx = int(v.Int())
+		default:
+			idx = int(min(v.Uint(), uint64(math.MaxInt))) //nolint:gosec // Clamped to MaxInt
+		}
+		if idx < 0 || idx >= len(f.Values) {
+			return 0, fmt.Errorf("%w: index %d of %d values", ErrOutOfRange, idx, len(f.Values))
+		}
+		return uint64(idx), nil
+	}
+	return 0, fmt.Errorf("%w: kind %s", ErrInvalidSchema, f.Kind)
+}
+
+// decode stores raw bits in a struct field.
+func (f *Field) decode(raw uint64, v reflect.Value) error {
+	switch f.Kind {
+	case KindBool:
+		v.SetBool(raw != 0)
+
+	case KindUint:
+		return setInteger(v, raw, false)
+
+	case KindInt:
+		if f.Bits < 64 && raw&(1<<(f.Bits-1)) != 0 {
+			raw |= math.MaxUint64 << f.Bits // sign extend
+		}
+		return setInteger(v, raw, true)
+
+	case KindFloat:
+		v.SetFloat(f.Min + float64(raw)*f.Scale)
+
+	case KindEnum:
+		if v.Kind() != reflect.String {
+			return setInteger(v, raw, false)
+		}
+		if raw >= uint64(len(f.Values)) {
+			// A value added in a newer version than this schema
+			return fmt.Errorf("%w: index %d of %d values", ErrOutOfRange, raw, len(f.Values))
+		}
+		v.SetString(f.Values[raw])
+	}
+	return nil
+}
+
+// setInteger stores an integer in an int or uint field of any size.
+func setInteger(v reflect.Value, raw uint64, signed bool) error {
+	if isInt(v.Kind()) {
+		i := int64(raw) //nolint:gosec // Two's complement reinterpretation
+		if !signed && i < 0 || v.OverflowInt(i) {
+			return fmt.Errorf("%w: %d overflows %s", ErrOutOfRange, raw, v.Type())
+		}
+		v.SetInt(i)
+		return nil
+	}
+	if signed && int64(raw) < 0 || v.OverflowUint(raw) { //nolint:gosec // Sign check
+		return fmt.Errorf("%w: %d overflows %s", ErrOutOfRange, int64(raw), v.Type()) //nolint:gosec // Sign check
+	}
+	v.SetUint(raw)
+	return nil
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
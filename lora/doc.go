@@ -81,6 +81,14 @@
 //	    }
 //	})
 //
+// # Reliable Messaging
+//
+// SendBytes transmits each packet once without confirmation. The
+// lora/link package adds acknowledgements, retransmission, fragmentation
+// and duplicate suppression on top, delivering messages to a
+// MessageRouter. The lora/codec package packs structured messages into
+// compact payloads from declared schemas and routes them by message type.
+//
 // # Configuration Parameters
 //
 // The LoRa configuration includes radio parameters:
@@ -0,0 +1,83 @@
+package link
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Radio parameter defaults.
+const (
+	DefaultSpreadingFactor = 7
+	DefaultBandwidth       = 125000
+	DefaultPreamble        = 8
+
+	// DefaultDutyCycle is the 1% limit of most EU868 sub-bands.
+	DefaultDutyCycle = 0.01
+)
+
+// RadioParams are the modulation parameters used to compute time on air.
+type RadioParams struct {
+	// SpreadingFactor is 7-12.
+	SpreadingFactor int
+
+	// Bandwidth is in Hz, e.g. 125000.
+	Bandwidth int
+
+	// Preamble is the preamble length in symbols.
+	Preamble int
+}
+
+// Airtime returns the time on air of a LoRa packet with n payload bytes,
+// explicit header, CRC and 4/5 coding rate, per Semtech AN1200.13.
+func (p RadioParams) Airtime(n int) time.Duration {
+	sf := p.SpreadingFactor
+	if sf == 0 {
+		sf = DefaultSpreadingFactor
+	}
+	bw := p.Bandwidth
+	if bw == 0 {
+		bw = DefaultBandwidth
+	}
+	preamble := p.Preamble
+	if preamble == 0 {
+		preamble = DefaultPreamble
+	}
+
+	symbol := math.Exp2(float64(sf)) / float64(bw)
+	lowDataRate := 0
+	if symbol > 0.016 {
+		lowDataRate = 1
+	}
+	bits := 8*n - 4*sf + 28 + 16
+	symbols := 8 + max(int(math.Ceil(float64(bits)/float64(4*(sf-2*lowDataRate))))*5, 0)
+	seconds := (float64(preamble)+4.25)*symbol + float64(symbols)*symbol
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// dutyCycle spaces transmissions so the share of time on air stays
+// within a limit: after a transmission of airtime t, the next one starts
+// no earlier than t/ratio after it began.
+type dutyCycle struct {
+	next  time.Time
+	ratio float64
+	mu    sync.Mutex
+}
+
+// reserve books a transmission of the given airtime starting no earlier
+// than notBefore, and returns its start time.
+func (d *dutyCycle) reserve(notBefore time.Time, airtime time.Duration) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	start := notBefore
+	if d.next.After(start) {
+		start = d.next
+	}
+	if d.ratio > 0 && d.ratio < 1 {
+		d.next = start.Add(time.Duration(float64(airtime) / d.ratio))
+	} else {
+		d.next = start.Add(airtime)
+	}
+	return start
+}
@@ -0,0 +1,53 @@
+// Package link provides a reliable link layer over the Shelly LoRa Add-On.
+//
+// lora.LoRa.SendRaw transmits a packet once, with no way to know whether
+// it arrived. Link adds what confirmed commands need:
+//   - 16-bit node addresses and a sequence number per message
+//   - Acknowledgements and retransmission with exponential backoff
+//   - Transmissions spaced to stay within the regional duty cycle
+//   - Fragmentation of payloads larger than one frame and reassembly
+//   - Suppression of retransmitted copies already delivered
+//   - Per-peer statistics and signal quality in a lora.DeviceRegistry
+//
+// # Usage
+//
+// Both ends run a Link with their own address and receive frames from the
+// NotifyEvent notifications of their LoRa component:
+//
+//	radio := lora.NewLoRa(client, 100)
+//	router := lora.NewMessageRouter()
+//	router.Registry = lora.NewDeviceRegistry()
+//
+//	l := link.NewLink(radio, 0x0001,
+//	    link.WithRouter(router),
+//	    link.WithDutyCycle(0.01),
+//	    link.WithRadioParams(link.RadioParams{SpreadingFactor: 9, Bandwidth: 125000}))
+//	l.Subscribe(client, radio.ID())
+//
+//	router.HandleDevice("0002", func(msg *lora.RoutedMessage) {
+//	    fmt.Printf("pump controller: %s\n", msg.Data)
+//	})
+//
+//	if err := l.Send(ctx, 0x0002, []byte("pump on")); errors.Is(err, link.ErrNoAck) {
+//	    log.Println("pump controller unreachable")
+//	}
+//
+// Devices are identified in the router and registry by their address as
+// four hex digits, e.g. "0002".
+//
+// # Frames
+//
+// Each frame has an 8-byte header (see Frame) followed by up to MTU-8
+// payload bytes; a message spans at most MaxFragments frames. Each
+// fragment is acknowledged separately, so a lost frame costs one
+// retransmission rather than the whole message. Data that isn't a link
+// frame makes Receive return ErrInvalidFrame, so other LoRa traffic can
+// be handled separately.
+//
+// # Duty Cycle
+//
+// After a transmission, the next one waits until the share of time on air
+// is within the limit, computed from RadioParams. The default of 1% and
+// SF7/125 kHz matches most EU868 sub-bands; set the parameters to the
+// radio's configuration for an accurate limit.
+package link
@@ -0,0 +1,131 @@
+package link
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Frame layout constants.
+const (
+	// Version is the link protocol version carried in every frame.
+	Version = 1
+
+	// HeaderSize is the size of the frame header in bytes.
+	HeaderSize = 8
+
+	// MaxFragments is the most fragments a message can be split into.
+	MaxFragments = 16
+
+	// Broadcast is the destination address of frames for every node.
+	// Broadcast frames are never acknowledged.
+	Broadcast Address = 0xffff
+)
+
+// FrameType identifies data and acknowledgement frames.
+type FrameType uint8
+
+// Frame types.
+const (
+	FrameData FrameType = 0
+	FrameAck  FrameType = 1
+)
+
+// Frame flags.
+const (
+	// FlagAckRequest asks the receiver to acknowledge the frame.
+	FlagAckRequest uint8 = 0x01
+)
+
+// Errors returned when decoding frames.
+var (
+	// ErrInvalidFrame indicates data that isn't a link frame, such as
+	// traffic from other LoRa senders.
+	ErrInvalidFrame = errors.New("invalid link frame")
+
+	// ErrUnsupportedVersion indicates a frame from a newer protocol
+	// version.
+	ErrUnsupportedVersion = errors.New("unsupported link protocol version")
+)
+
+// Address is a 16-bit link node address.
+type Address uint16
+
+// String returns the address as four hex digits, e.g. "00a1". This is the
+// device ID used in lora.RoutedMessage and lora.DeviceRegistry.
+func (a Address) String() string {
+	return fmt.Sprintf("%04x", uint16(a))
+}
+
+// Frame is a single link layer frame.
+//
+// The header is 8 bytes:
+//
+//	0     version (high nibble), frame type (low nibble)
+//	1     flags
+//	2-3   source address, big endian
+//	4-5   destination address, big endian
+//	6     sequence number
+//	7     fragment index (high nibble), fragment count - 1 (low nibble)
+//
+// Acknowledgements echo the sequence number and fragment index of the
+// frame they acknowledge and carry no payload.
+type Frame struct {
+	Payload  []byte
+	Src      Address
+	Dst      Address
+	Type     FrameType
+	Flags    uint8
+	Seq      uint8
+	Fragment uint8
+	Count    uint8
+}
+
+// MarshalBinary encodes the frame.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	if f.Count == 0 || f.Count > MaxFragments || f.Fragment >= f.Count {
+		return nil, fmt.Errorf("%w: fragment %d of %d", ErrInvalidFrame, f.Fragment, f.Count)
+	}
+	data := make([]byte, HeaderSize, HeaderSize+len(f.Payload))
+	data[0] = Version<<4 | byte(f.Type)&0x0f
+	data[1] = f.Flags
+	binary.BigEndian.PutUint16(data[2:], uint16(f.Src))
+	binary.BigEndian.PutUint16(data[4:], uint16(f.Dst))
+	data[6] = f.Seq
+	data[7] = f.Fragment<<4 | (f.Count-1)&0x0f
+	return append(data, f.Payload...), nil
+}
+
+// UnmarshalBinary decodes a frame. The payload aliases data.
+func (f *Frame) UnmarshalBinary(data []byte) error {
+	if len(data) < HeaderSize {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidFrame, len(data))
+	}
+	if v := data[0] >> 4; v != Version {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, v)
+	}
+	*f = Frame{
+		Type:     FrameType(data[0] & 0x0f),
+		Flags:    data[1],
+		Src:      Address(binary.BigEndian.Uint16(data[2:])),
+		Dst:      Address(binary.BigEndian.Uint16(data[4:])),
+		Seq:      data[6],
+		Fragment: data[7] >> 4,
+		Count:    data[7]&0x0f + 1,
+	}
+	if f.Type != FrameData && f.Type != FrameAck {
+		return fmt.Errorf("%w: type %d", ErrInvalidFrame, f.Type)
+	}
+	if f.Fragment >= f.Count {
+		return fmt.Errorf("%w: fragment %d of %d", ErrInvalidFrame, f.Fragment, f.Count)
+	}
+	if len(data) > HeaderSize {
+		f.Payload = data[HeaderSize:]
+	}
+	return nil
+}
+
+// AckRequested reports whether the sender wants an acknowledgement.
+func (f *Frame) AckRequested() bool {
+	return f.Flags&FlagAckRequest != 0
+}
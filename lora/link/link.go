@@ -0,0 +1,630 @@
+package link
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/lora"
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+// Link defaults.
+const (
+	// DefaultMTU is the largest frame sent, header included. 51 bytes fit
+	// every data rate in EU868 and US915.
+	DefaultMTU = 51
+
+	// DefaultAckTimeout is how long to wait for an acknowledgement. It
+	// covers the time on air at slow data rates and the notification
+	// round trip through both devices.
+	DefaultAckTimeout = 3 * time.Second
+
+	// DefaultRetries is how often an unacknowledged frame is resent.
+	DefaultRetries = 3
+
+	// DefaultBackoff is the base delay before the first retransmission.
+	// It doubles with each further attempt.
+	DefaultBackoff = time.Second
+
+	// DefaultReassemblyTimeout is how long fragments of an incomplete
+	// message are kept.
+	DefaultReassemblyTimeout = time.Minute
+
+	// DefaultDuplicateWindow is how long delivered messages are
+	// remembered to suppress retransmitted copies.
+	DefaultDuplicateWindow = 2 * time.Minute
+)
+
+// Errors returned by the link.
+var (
+	// ErrNoAck indicates a frame wasn't acknowledged after all retries.
+	ErrNoAck = errors.New("no acknowledgement from peer")
+
+	// ErrPayloadTooLarge indicates a payload needs more than MaxFragments
+	// frames.
+	ErrPayloadTooLarge = errors.New("payload too large")
+)
+
+// Radio sends frames and reports the signal quality of the last received
+// packet. *lora.LoRa implements Radio.
+type Radio interface {
+	SendRaw(ctx context.Context, data []byte) error
+	GetLastRSSI(ctx context.Context) (int, error)
+	GetLastSNR(ctx context.Context) (float64, error)
+}
+
+// Stats are link statistics for one peer.
+type Stats struct {
+	// LastSeen is when a frame from the peer was last received.
+	LastSeen time.Time
+
+	// Sent counts data frames sent to the peer, retransmissions included.
+	Sent int
+
+	// Retransmissions counts data frames sent again for lack of an
+	// acknowledgement.
+	Retransmissions int
+
+	// Acked counts acknowledged data frames.
+	Acked int
+
+	// Failed counts frames that were never acknowledged.
+	Failed int
+
+	// Received counts frames received from the peer.
+	Received int
+
+	// Duplicates counts received data frames that were already delivered.
+	Duplicates int
+
+	// RoundTrip is the time from the last acknowledged transmission to its
+	// acknowledgement.
+	RoundTrip time.Duration
+
+	// LastRSSI is the signal strength of the peer's last frame in dBm.
+	LastRSSI int
+
+	// LastSNR is the signal-to-noise ratio of the peer's last frame in dB.
+	LastSNR float64
+}
+
+// Option configures a Link.
+type Option func(*Link)
+
+// WithRouter delivers received messages to router.
+func WithRouter(router *lora.MessageRouter) Option {
+	return func(l *Link) {
+		l.router = router
+	}
+}
+
+// WithRegistry updates registry with the last seen time and signal
+// quality of peers, registering unknown peers. Without it, the router's
+// registry is used, if any.
+func WithRegistry(registry *lora.DeviceRegistry) Option {
+	return func(l *Link) {
+		l.registry = registry
+	}
+}
+
+// WithMTU sets the largest frame size, header included.
+func WithMTU(mtu int) Option {
+	return func(l *Link) {
+		if mtu > HeaderSize {
+			l.mtu = mtu
+		}
+	}
+}
+
+// WithAckTimeout sets how long to wait for each acknowledgement.
+func WithAckTimeout(d time.Duration) Option {
+	return func(l *Link) {
+		if d > 0 {
+			l.ackTimeout = d
+		}
+	}
+}
+
+// WithRetries sets how often an unacknowledged frame is resent.
+func WithRetries(n int) Option {
+	return func(l *Link) {
+		if n >= 0 {
+			l.retries = n
+		}
+	}
+}
+
+// WithBackoff sets the base delay before a retransmission.
+func WithBackoff(d time.Duration) Option {
+	return func(l *Link) {
+		if d >= 0 {
+			l.backoff = d
+		}
+	}
+}
+
+// WithDutyCycle sets the share of time the radio may transmit, e.g. 0.01
+// for 1%. A ratio of 1 or more disables the limit.
+func WithDutyCycle(ratio float64) Option {
+	return func(l *Link) {
+		if ratio > 0 {
+			l.duty.ratio = ratio
+		}
+	}
+}
+
+// WithRadioParams sets the modulation parameters used to compute time on
+// air for the duty cycle limit.
+func WithRadioParams(params RadioParams) Option {
+	return func(l *Link) {
+		l.params = params
+	}
+}
+
+// WithReassemblyTimeout sets how long fragments of an incomplete message
+// are kept.
+func WithReassemblyTimeout(d time.Duration) Option {
+	return func(l *Link) {
+		if d > 0 {
+			l.reassemblyTimeout = d
+		}
+	}
+}
+
+// WithDuplicateWindow sets how long delivered messages are remembered.
+func WithDuplicateWindow(d time.Duration) Option {
+	return func(l *Link) {
+		if d > 0 {
+			l.duplicateWindow = d
+		}
+	}
+}
+
+type ackKey struct {
+	peer     Address
+	seq      uint8
+	fragment uint8
+}
+
+type messageKey struct {
+	peer Address
+	seq  uint8
+}
+
+type reassembly struct {
+	started   time.Time
+	fragments [][]byte
+	received  int
+}
+
+// Link is a reliable link layer over a LoRa radio.
+//
+// Messages are split into frames of at most the MTU. Each frame sent with
+// Send is acknowledged by the receiver and retransmitted with exponential
+// backoff until it is, spacing transmissions to stay within the duty
+// cycle. Receivers reassemble fragments, drop retransmitted copies and
+// deliver each message once to a lora.MessageRouter.
+type Link struct {
+	radio             Radio
+	router            *lora.MessageRouter
+	registry          *lora.DeviceRegistry
+	pending           map[ackKey]chan struct{}
+	partial           map[messageKey]*reassembly
+	delivered         map[messageKey]time.Time
+	stats             map[Address]*Stats
+	params            RadioParams
+	duty              dutyCycle
+	mtu               int
+	retries           int
+	ackTimeout        time.Duration
+	backoff           time.Duration
+	reassemblyTimeout time.Duration
+	duplicateWindow   time.Duration
+	sendMu            sync.Mutex
+	mu                sync.Mutex
+	addr              Address
+	seq               uint8
+}
+
+// NewLink creates a link for the node with the given address, sending
+// through radio.
+//
+// Example:
+//
+//	radio := lora.NewLoRa(client, 100)
+//	router := lora.NewMessageRouter()
+//	l := link.NewLink(radio, 0x0001, link.WithRouter(router))
+//	l.Subscribe(client, radio.ID())
+//
+//	err := l.Send(ctx, 0x0002, []byte("pump on"))
+func NewLink(radio Radio, addr Address, opts ...Option) *Link {
+	l := &Link{
+		radio:             radio,
+		addr:              addr,
+		pending:           make(map[ackKey]chan struct{}),
+		partial:           make(map[messageKey]*reassembly),
+		delivered:         make(map[messageKey]time.Time),
+		stats:             make(map[Address]*Stats),
+		duty:              dutyCycle{ratio: DefaultDutyCycle},
+		mtu:               DefaultMTU,
+		retries:           DefaultRetries,
+		ackTimeout:        DefaultAckTimeout,
+		backoff:           DefaultBackoff,
+		reassemblyTimeout: DefaultReassemblyTimeout,
+		duplicateWindow:   DefaultDuplicateWindow,
+		seq:               uint8(rand.N(256)), //nolint:gosec // Sequence numbers needn't be unpredictable
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Address returns the link's node address.
+func (l *Link) Address() Address {
+	return l.addr
+}
+
+// Send sends payload to dst and waits until every fragment is
+// acknowledged. It returns an error wrapping ErrNoAck if a fragment isn't
+// acknowledged after all retries. Messages to Broadcast are sent without
+// acknowledgement.
+//
+// After a confirmed send, the peer's signal quality is read from the
+// radio, since its acknowledgement was the last packet received.
+func (l *Link) Send(ctx context.Context, dst Address, payload []byte) error {
+	return l.send(ctx, dst, payload, dst != Broadcast)
+}
+
+// SendUnconfirmed sends payload to dst without waiting for
+// acknowledgements.
+func (l *Link) SendUnconfirmed(ctx context.Context, dst Address, payload []byte) error {
+	return l.send(ctx, dst, payload, false)
+}
+
+func (l *Link) send(ctx context.Context, dst Address, payload []byte, confirmed bool) error {
+	chunk := l.mtu - HeaderSize
+	count := max(1, (len(payload)+chunk-1)/chunk)
+	if count > MaxFragments {
+		return fmt.Errorf("%w: %d bytes exceed %d", ErrPayloadTooLarge, len(payload), MaxFragments*chunk)
+	}
+
+	l.sendMu.Lock()
+	defer l.sendMu.Unlock()
+
+	l.mu.Lock()
+	seq := l.seq
+	l.seq++
+	l.mu.Unlock()
+
+	for i := range count {
+		f := &Frame{
+			Type:     FrameData,
+			Src:      l.addr,
+			Dst:      dst,
+			Seq:      seq,
+			Fragment: uint8(i), //nolint:gosec // count <= MaxFragments
+			Count:    uint8(count),
+			Payload:  payload[i*chunk : min((i+1)*chunk, len(payload))],
+		}
+		if !confirmed {
+			if _, err := l.transmit(ctx, f, time.Now()); err != nil {
+				return err
+			}
+			l.update(dst, func(s *Stats) { s.Sent++ })
+			continue
+		}
+		f.Flags = FlagAckRequest
+		if err := l.sendConfirmed(ctx, f); err != nil {
+			return err
+		}
+	}
+
+	if confirmed {
+		l.refreshQuality(ctx, dst)
+	}
+	return nil
+}
+
+// sendConfirmed sends a frame until it is acknowledged.
+func (l *Link) sendConfirmed(ctx context.Context, f *Frame) error {
+	key := ackKey{peer: f.Dst, seq: f.Seq, fragment: f.Fragment}
+	acked := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.pending[key] = acked
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.pending, key)
+		l.mu.Unlock()
+	}()
+
+	notBefore := time.Now()
+	for attempt := 0; attempt <= l.retries; attempt++ {
+		if attempt > 0 {
+			notBefore = time.Now().Add(l.backoffDelay(attempt))
+		}
+		start, err := l.transmit(ctx, f, notBefore)
+		if err != nil {
+			return err
+		}
+		l.update(f.Dst, func(s *Stats) {
+			s.Sent++
+			if attempt > 0 {
+				s.Retransmissions++
+			}
+		})
+
+		timer := time.NewTimer(l.ackTimeout)
+		select {
+		case <-acked:
+			timer.Stop()
+			l.update(f.Dst, func(s *Stats) {
+				s.Acked++
+				s.RoundTrip = time.Since(start)
+			})
+			return nil
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	l.update(f.Dst, func(s *Stats) { s.Failed++ })
+	return fmt.Errorf("%w: %s seq %d fragment %d/%d", ErrNoAck, f.Dst, f.Seq, f.Fragment+1, f.Count)
+}
+
+// backoffDelay returns the delay before the given retransmission:
+// the base backoff doubled per attempt, plus up to 50% jitter so nodes
+// that collided don't collide again.
+func (l *Link) backoffDelay(attempt int) time.Duration {
+	d := l.backoff << (attempt - 1)
+	if d <= 0 {
+		return 0
+	}
+	return d + rand.N(d/2+1) //nolint:gosec // Jitter needn't be unpredictable
+}
+
+// transmit sends a frame once the duty cycle allows, no earlier than
+// notBefore, and returns when the transmission started.
+func (l *Link) transmit(ctx context.Context, f *Frame, notBefore time.Time) (time.Time, error) {
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return time.Time{}, err
+	}
+	start := l.duty.reserve(notBefore, l.params.Airtime(len(data)))
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Time{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err := l.radio.SendRaw(ctx, data); err != nil {
+		return time.Time{}, fmt.Errorf("failed to send frame: %w", err)
+	}
+	return start, nil
+}
+
+// Subscribe receives frames from the NotifyEvent notifications of the
+// LoRa component with the given ID. The client's transport must deliver
+// notifications (WebSocket or MQTT).
+func (l *Link) Subscribe(client *rpc.Client, componentID int) {
+	component := fmt.Sprintf("lora:%d", componentID)
+	client.OnNotificationMethod("NotifyEvent", func(params json.RawMessage) {
+		var p struct {
+			Events []lora.Event `json:"events"`
+		}
+		if json.Unmarshal(params, &p) != nil {
+			return
+		}
+		for i := range p.Events {
+			if p.Events[i].Component == component && p.Events[i].Event == "lora" {
+				//nolint:errcheck // Frames from other senders are expected and ignored
+				l.HandleEvent(&p.Events[i])
+			}
+		}
+	})
+}
+
+// HandleEvent processes a LoRa receive event.
+func (l *Link) HandleEvent(event *lora.Event) error {
+	data, err := base64.StdEncoding.DecodeString(event.Info.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFrame, err)
+	}
+	return l.Receive(data, event.Info.RSSI, event.Info.SNR)
+}
+
+// Receive processes a received frame with its signal quality. Frames for
+// other nodes are ignored; data that isn't a link frame returns an error
+// wrapping ErrInvalidFrame.
+//
+// Acknowledgements are sent from a new goroutine, since Receive may run on
+// the transport's read loop that the send's response has to come through.
+func (l *Link) Receive(data []byte, rssi int, snr float64) error {
+	var f Frame
+	if err := f.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if f.Src == l.addr || (f.Dst != l.addr && f.Dst != Broadcast) {
+		return nil
+	}
+
+	l.update(f.Src, func(s *Stats) {
+		s.Received++
+		s.LastSeen = time.Now()
+		s.LastRSSI = rssi
+		s.LastSNR = snr
+	})
+	l.touch(f.Src, rssi, snr)
+
+	if f.Type == FrameAck {
+		l.mu.Lock()
+		acked := l.pending[ackKey{peer: f.Src, seq: f.Seq, fragment: f.Fragment}]
+		l.mu.Unlock()
+		if acked != nil {
+			select {
+			case acked <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	}
+
+	if f.AckRequested() && f.Dst != Broadcast {
+		ack := &Frame{Type: FrameAck, Src: l.addr, Dst: f.Src, Seq: f.Seq, Fragment: f.Fragment, Count: f.Count}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), l.ackTimeout)
+			defer cancel()
+			//nolint:errcheck // A lost acknowledgement makes the peer retransmit
+			l.transmit(ctx, ack, time.Now())
+		}()
+	}
+
+	payload, ok := l.reassemble(&f)
+	if !ok {
+		return nil
+	}
+	if l.router != nil {
+		msg := &lora.RoutedMessage{
+			FromDevice: f.Src.String(),
+			Data:       payload,
+			RSSI:       rssi,
+			SNR:        snr,
+			Timestamp:  float64(time.Now().UnixMilli()) / 1000,
+		}
+		if f.Dst != Broadcast {
+			msg.ToDevice = f.Dst.String()
+		}
+		if _, err := l.router.Route(msg); err != nil {
+			return fmt.Errorf("failed to route message: %w", err)
+		}
+	}
+	return nil
+}
+
+// reassemble adds a data frame to its message and returns the payload
+// once the message is complete. Frames of delivered messages and repeated
+// fragments count as duplicates.
+func (l *Link) reassemble(f *Frame) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.pruneLocked(now)
+
+	key := messageKey{peer: f.Src, seq: f.Seq}
+	if _, ok := l.delivered[key]; ok {
+		l.statsLocked(f.Src).Duplicates++
+		return nil, false
+	}
+
+	r, ok := l.partial[key]
+	if !ok || len(r.fragments) != int(f.Count) {
+		r = &reassembly{started: now, fragments: make([][]byte, f.Count)}
+		l.partial[key] = r
+	}
+	if r.fragments[f.Fragment] != nil {
+		l.statsLocked(f.Src).Duplicates++
+		return nil, false
+	}
+	r.fragments[f.Fragment] = append([]byte{}, f.Payload...)
+	r.received++
+	if r.received < len(r.fragments) {
+		return nil, false
+	}
+
+	delete(l.partial, key)
+	l.delivered[key] = now
+	var payload []byte
+	for _, frag := range r.fragments {
+		payload = append(payload, frag...)
+	}
+	return payload, true
+}
+
+// pruneLocked drops stale partial messages and delivery records.
+func (l *Link) pruneLocked(now time.Time) {
+	for key, r := range l.partial {
+		if now.Sub(r.started) > l.reassemblyTimeout {
+			delete(l.partial, key)
+		}
+	}
+	for key, at := range l.delivered {
+		if now.Sub(at) > l.duplicateWindow {
+			delete(l.delivered, key)
+		}
+	}
+}
+
+// refreshQuality reads the signal quality of the last received packet
+// from the radio and records it for peer.
+func (l *Link) refreshQuality(ctx context.Context, peer Address) {
+	rssi, err := l.radio.GetLastRSSI(ctx)
+	if err != nil {
+		return
+	}
+	snr, err := l.radio.GetLastSNR(ctx)
+	if err != nil {
+		return
+	}
+	l.update(peer, func(s *Stats) {
+		s.LastRSSI = rssi
+		s.LastSNR = snr
+	})
+	l.touch(peer, rssi, snr)
+}
+
+// touch records a peer in the device registry.
+func (l *Link) touch(peer Address, rssi int, snr float64) {
+	registry := l.registry
+	if registry == nil && l.router != nil {
+		registry = l.router.Registry
+	}
+	if registry == nil {
+		return
+	}
+	id := peer.String()
+	if _, err := registry.Get(id); errors.Is(err, lora.ErrDeviceNotFound) {
+		registry.RegisterOrUpdate(&lora.RegisteredDevice{DeviceID: id, Address: id})
+	}
+	//nolint:errcheck // The device was registered above
+	registry.UpdateLastSeen(id, rssi, snr)
+}
+
+// Stats returns a copy of the statistics for peer.
+func (l *Link) Stats(peer Address) (Stats, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.stats[peer]
+	if !ok {
+		return Stats{}, false
+	}
+	return *s, true
+}
+
+func (l *Link) update(peer Address, fn func(*Stats)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fn(l.statsLocked(peer))
+}
+
+func (l *Link) statsLocked(peer Address) *Stats {
+	s, ok := l.stats[peer]
+	if !ok {
+		s = &Stats{}
+		l.stats[peer] = s
+	}
+	return s
+}
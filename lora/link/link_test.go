@@ -0,0 +1,324 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/lora"
+)
+
+// air connects fake radios; every frame sent reaches all other radios
+// unless drop returns true for it.
+type air struct {
+	drop   func(f *Frame) bool
+	radios []*fakeRadio
+	mu     sync.Mutex
+}
+
+type fakeRadio struct {
+	air  *air
+	link *Link
+	sent []Frame
+}
+
+func (a *air) radio() *fakeRadio {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := &fakeRadio{air: a}
+	a.radios = append(a.radios, r)
+	return r
+}
+
+func (r *fakeRadio) SendRaw(_ context.Context, data []byte) error {
+	var f Frame
+	if err := f.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	r.air.mu.Lock()
+	r.sent = append(r.sent, f)
+	dropped := r.air.drop != nil && r.air.drop(&f)
+	var peers []*fakeRadio
+	for _, p := range r.air.radios {
+		if p != r && p.link != nil {
+			peers = append(peers, p)
+		}
+	}
+	r.air.mu.Unlock()
+
+	if !dropped {
+		for _, p := range peers {
+			go p.link.Receive(append([]byte{}, data...), -90, 6.5) //nolint:errcheck // Tests check delivery
+		}
+	}
+	return nil
+}
+
+func (r *fakeRadio) GetLastRSSI(context.Context) (int, error) {
+	return -87, nil
+}
+
+func (r *fakeRadio) GetLastSNR(context.Context) (float64, error) {
+	return 7.25, nil
+}
+
+// collector records routed messages.
+type collector struct {
+	got chan *lora.RoutedMessage
+	rtr *lora.MessageRouter
+	reg *lora.DeviceRegistry
+}
+
+func newCollector() *collector {
+	c := &collector{got: make(chan *lora.RoutedMessage, 16), rtr: lora.NewMessageRouter(), reg: lora.NewDeviceRegistry()}
+	c.rtr.Registry = c.reg
+	c.rtr.Handle(func(msg *lora.RoutedMessage) { c.got <- msg }, nil)
+	return c
+}
+
+func (c *collector) next(t *testing.T) *lora.RoutedMessage {
+	t.Helper()
+	select {
+	case msg := <-c.got:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message delivered")
+		return nil
+	}
+}
+
+func (c *collector) none(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-c.got:
+		t.Fatalf("unexpected message %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+var testOpts = []Option{
+	WithAckTimeout(50 * time.Millisecond), WithBackoff(time.Millisecond), WithDutyCycle(1), WithRetries(3),
+}
+
+func newPair(t *testing.T, a *air, opts ...Option) (sender *Link, receiver *Link, c *collector) {
+	t.Helper()
+	c = newCollector()
+	r1, r2 := a.radio(), a.radio()
+	sender = NewLink(r1, 0x0001, append(append([]Option{}, testOpts...), opts...)...)
+	receiver = NewLink(r2, 0x0002, append(append([]Option{}, testOpts...), append(opts, WithRouter(c.rtr))...)...)
+	a.mu.Lock()
+	r1.link, r2.link = sender, receiver
+	a.mu.Unlock()
+	return sender, receiver, c
+}
+
+func TestFrame(t *testing.T) {
+	f := Frame{Type: FrameData, Flags: FlagAckRequest, Src: 0x0102, Dst: Broadcast, Seq: 200,
+		Fragment: 2, Count: 5, Payload: []byte("abc")}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	want := []byte{0x10, 0x01, 0x01, 0x02, 0xff, 0xff, 200, 0x24, 'a', 'b', 'c'}
+	if !bytes.Equal(data, want) {
+		t.Errorf("MarshalBinary() = % x, want % x", data, want)
+	}
+	var got Frame
+	if err := got.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(got, f) {
+		t.Errorf("UnmarshalBinary() = %+v, %v", got, err)
+	}
+
+	tests := []struct {
+		want error
+		name string
+		data []byte
+	}{
+		{name: "short", data: []byte{0x10, 0}, want: ErrInvalidFrame},
+		{name: "version", data: []byte{0x20, 0, 0, 1, 0, 2, 0, 0}, want: ErrUnsupportedVersion},
+		{name: "type", data: []byte{0x15, 0, 0, 1, 0, 2, 0, 0}, want: ErrInvalidFrame},
+		{name: "fragment", data: []byte{0x10, 0, 0, 1, 0, 2, 0, 0x21}, want: ErrInvalidFrame},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := new(Frame).UnmarshalBinary(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("UnmarshalBinary() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAirtime(t *testing.T) {
+	tests := []struct {
+		params RadioParams
+		n      int
+		want   time.Duration
+	}{
+		{RadioParams{}, 13, 46336 * time.Microsecond},
+		{RadioParams{SpreadingFactor: 12, Bandwidth: 125000, Preamble: 8}, 10, 991232 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		got := tt.params.Airtime(tt.n)
+		if d := got - tt.want; d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("Airtime(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDutyCycle(t *testing.T) {
+	d := dutyCycle{ratio: 0.01}
+	now := time.Now()
+	if start := d.reserve(now, 10*time.Millisecond); !start.Equal(now) {
+		t.Errorf("first reserve() = %v, want now", start.Sub(now))
+	}
+	if start := d.reserve(now, 10*time.Millisecond); start.Sub(now) != time.Second {
+		t.Errorf("second reserve() = +%v, want +1s", start.Sub(now))
+	}
+	if start := d.reserve(now.Add(5*time.Second), 10*time.Millisecond); start.Sub(now) != 5*time.Second {
+		t.Errorf("later reserve() = +%v, want +5s", start.Sub(now))
+	}
+}
+
+func TestLink_SendFragmented(t *testing.T) {
+	a := &air{}
+	sender, _, c := newPair(t, a, WithMTU(20))
+	reg := lora.NewDeviceRegistry()
+	sender.registry = reg
+
+	payload := bytes.Repeat([]byte("0123456789"), 10)
+	if err := sender.Send(context.Background(), 0x0002, payload); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	msg := c.next(t)
+	if !bytes.Equal(msg.Data, payload) || msg.FromDevice != "0001" || msg.ToDevice != "0002" || msg.RSSI != -90 {
+		t.Errorf("message = %+v", msg)
+	}
+	c.none(t)
+
+	stats, ok := sender.Stats(0x0002)
+	if !ok || stats.Acked != 9 || stats.Sent != 9 || stats.Failed != 0 || stats.LastRSSI != -87 {
+		t.Errorf("sender stats = %+v", stats)
+	}
+	dev, err := reg.Get("0002")
+	if err != nil || dev.LastRSSI != -87 || dev.LastSNR != 7.25 || !dev.Online {
+		t.Errorf("registry device = %+v, %v", dev, err)
+	}
+	if dev, err := c.reg.Get("0001"); err != nil || dev.LastRSSI != -90 {
+		t.Errorf("receiver registry device = %+v, %v", dev, err)
+	}
+}
+
+func TestLink_Retransmit(t *testing.T) {
+	dropped := 0
+	a := &air{drop: func(f *Frame) bool {
+		if f.Type == FrameData && dropped < 2 {
+			dropped++
+			return true
+		}
+		return false
+	}}
+	sender, _, c := newPair(t, a)
+
+	if err := sender.Send(context.Background(), 0x0002, []byte("pump on")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if msg := c.next(t); string(msg.Data) != "pump on" {
+		t.Errorf("Data = %q", msg.Data)
+	}
+	if stats, _ := sender.Stats(0x0002); stats.Retransmissions != 2 || stats.Sent != 3 || stats.Acked != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestLink_LostAck(t *testing.T) {
+	dropped := false
+	a := &air{drop: func(f *Frame) bool {
+		if f.Type == FrameAck && !dropped {
+			dropped = true
+			return true
+		}
+		return false
+	}}
+	sender, receiver, c := newPair(t, a)
+
+	if err := sender.Send(context.Background(), 0x0002, []byte("valve close")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	c.next(t)
+	c.none(t)
+	if stats, _ := receiver.Stats(0x0001); stats.Duplicates != 1 || stats.Received != 2 {
+		t.Errorf("receiver stats = %+v", stats)
+	}
+}
+
+func TestLink_NoAck(t *testing.T) {
+	a := &air{}
+	sender := NewLink(a.radio(), 0x0001, testOpts...)
+
+	err := sender.Send(context.Background(), 0x0009, []byte("hello"))
+	if !errors.Is(err, ErrNoAck) {
+		t.Fatalf("Send() error = %v, want ErrNoAck", err)
+	}
+	if stats, _ := sender.Stats(0x0009); stats.Sent != 4 || stats.Failed != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sender.Send(ctx, 0x0009, []byte("hello")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Send(canceled) error = %v", err)
+	}
+}
+
+func TestLink_Broadcast(t *testing.T) {
+	a := &air{}
+	sender, _, c := newPair(t, a)
+
+	if err := sender.Send(context.Background(), Broadcast, []byte("sync")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if msg := c.next(t); msg.ToDevice != "" || string(msg.Data) != "sync" {
+		t.Errorf("message = %+v", msg)
+	}
+	for _, f := range a.radios[1].sent {
+		t.Errorf("receiver sent %+v for a broadcast", f)
+	}
+}
+
+func TestLink_PayloadTooLarge(t *testing.T) {
+	sender := NewLink(&fakeRadio{air: &air{}}, 1, WithMTU(10))
+	if err := sender.Send(context.Background(), 2, make([]byte, 33)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Send() error = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestLink_HandleEvent(t *testing.T) {
+	c := newCollector()
+	l := NewLink(&fakeRadio{air: &air{}}, 0x0002, WithRouter(c.rtr))
+
+	f := Frame{Type: FrameData, Src: 0x0007, Dst: 0x0002, Count: 1, Payload: []byte("level=42")}
+	data, _ := f.MarshalBinary()
+	event := &lora.Event{Component: "lora:100", Event: "lora",
+		Info: lora.ReceivedData{Data: base64.StdEncoding.EncodeToString(data), RSSI: -101, SNR: -3}}
+	if err := l.HandleEvent(event); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if msg := c.next(t); msg.FromDevice != "0007" || msg.RSSI != -101 {
+		t.Errorf("message = %+v", msg)
+	}
+
+	// Frames for other nodes are ignored, foreign data is rejected
+	f.Dst = 0x0003
+	data, _ = f.MarshalBinary()
+	if err := l.Receive(data, 0, 0); err != nil {
+		t.Errorf("Receive(other node) error = %v", err)
+	}
+	c.none(t)
+	if err := l.Receive([]byte("hello"), 0, 0); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Receive(foreign) error = %v", err)
+	}
+}
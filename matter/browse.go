@@ -0,0 +1,375 @@
+package matter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/tj-smith47/shelly-go/discovery"
+	"github.com/tj-smith47/shelly-go/profiles"
+)
+
+// CommissionableService is the DNS-SD service type of commissionable
+// Matter nodes.
+const CommissionableService = "_matterc._udp.local."
+
+// DefaultQueryInterval is how often a Browser repeats its query.
+const DefaultQueryInterval = time.Second
+
+// CommissionableNode is a Matter node advertising itself for
+// commissioning.
+type CommissionableNode struct {
+	// Device is the Shelly device the node belongs to, set by LinkDevices.
+	Device *discovery.DiscoveredDevice `json:"device,omitempty"`
+
+	// Profile is the device's profile, set by LinkDevices when the model
+	// is registered.
+	Profile *profiles.Profile `json:"profile,omitempty"`
+
+	// TXT holds all TXT record entries.
+	TXT map[string]string `json:"txt,omitempty"`
+
+	// Instance is the DNS-SD instance name.
+	Instance string `json:"instance"`
+
+	// Host is the target host name of the SRV record.
+	Host string `json:"host,omitempty"`
+
+	// DeviceName is the advertised name (DN); optional.
+	DeviceName string `json:"device_name,omitempty"`
+
+	// PairingInstruction is the advertised pairing instruction (PI).
+	PairingInstruction string `json:"pairing_instruction,omitempty"`
+
+	// Addresses are the node's IP addresses.
+	Addresses []net.IP `json:"addresses,omitempty"`
+
+	// Port is the Matter UDP port.
+	Port int `json:"port,omitempty"`
+
+	// DeviceType is the advertised Matter device type (DT).
+	DeviceType uint32 `json:"device_type,omitempty"`
+
+	// PairingHint is the advertised pairing hint bitmap (PH).
+	PairingHint uint16 `json:"pairing_hint,omitempty"`
+
+	// Discriminator is the 12-bit discriminator (D).
+	Discriminator uint16 `json:"discriminator"`
+
+	// VendorID and ProductID are the advertised IDs (VP); zero if not
+	// advertised.
+	VendorID  uint16 `json:"vendor_id,omitempty"`
+	ProductID uint16 `json:"product_id,omitempty"`
+
+	// CommissioningMode is 0 when not in commissioning mode, 1 in basic
+	// and 2 in enhanced commissioning mode (CM).
+	CommissioningMode uint8 `json:"commissioning_mode"`
+}
+
+// MatchesPayload reports whether the node is the device of a setup
+// payload: the discriminator matches and, if both advertise them, the
+// vendor and product ID.
+func (n *CommissionableNode) MatchesPayload(p *SetupPayload) bool {
+	if !p.MatchesDiscriminator(n.Discriminator) {
+		return false
+	}
+	if p.VendorID != 0 && n.VendorID != 0 && p.VendorID != n.VendorID {
+		return false
+	}
+	return p.ProductID == 0 || n.ProductID == 0 || p.ProductID == n.ProductID
+}
+
+// MAC returns the MAC address embedded in the node's host name, as 12
+// upper-case hex digits, or "" if it has none. Wi-Fi nodes, including
+// Shelly devices, typically use their MAC as host name.
+func (n *CommissionableNode) MAC() string {
+	label, _, _ := strings.Cut(n.Host, ".")
+	if i := strings.LastIndexByte(label, '-'); i >= 0 {
+		label = label[i+1:]
+	}
+	return hexMAC(label)
+}
+
+// LinkDevices links nodes to the Shelly devices found by discovery, by
+// the MAC address in the node's host name or a shared IP address, and
+// sets the device's profile if its model is registered.
+func LinkDevices(nodes []CommissionableNode, devices []discovery.DiscoveredDevice) {
+	for i := range nodes {
+		n := &nodes[i]
+		mac := n.MAC()
+		for j := range devices {
+			d := &devices[j]
+			if (mac == "" || deviceMAC(d) != mac) && !hasAddress(n.Addresses, d.Address) {
+				continue
+			}
+			n.Device = d
+			if p, ok := profiles.Get(d.Model); ok {
+				n.Profile = p
+			}
+			break
+		}
+	}
+}
+
+// deviceMAC returns a device's MAC from its MAC address or the suffix of
+// its ID, e.g. "shelly1g4-a0b1c2d3e4f5".
+func deviceMAC(d *discovery.DiscoveredDevice) string {
+	for _, s := range []string{d.MACAddress, d.ID} {
+		s = strings.NewReplacer(":", "", "-", "").Replace(s)
+		if len(s) >= 12 {
+			if mac := hexMAC(s[len(s)-12:]); mac != "" {
+				return mac
+			}
+		}
+	}
+	return ""
+}
+
+func hexMAC(s string) string {
+	if len(s) != 12 {
+		return ""
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return ""
+		}
+	}
+	return strings.ToUpper(s)
+}
+
+func hasAddress(addrs []net.IP, ip net.IP) bool {
+	for _, a := range addrs {
+		if ip != nil && a.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// BrowserOption configures a Browser.
+type BrowserOption func(*Browser)
+
+// WithResolver sends queries to a unicast DNS-SD resolver instead of the
+// mDNS multicast group.
+func WithResolver(addr *net.UDPAddr) BrowserOption {
+	return func(b *Browser) {
+		b.addr = addr
+	}
+}
+
+// WithQueryInterval sets how often the query is repeated while browsing.
+func WithQueryInterval(d time.Duration) BrowserOption {
+	return func(b *Browser) {
+		if d > 0 {
+			b.interval = d
+		}
+	}
+}
+
+// Browser finds commissionable Matter nodes with DNS-SD.
+type Browser struct {
+	addr     *net.UDPAddr
+	interval time.Duration
+}
+
+// NewBrowser creates a browser querying the mDNS multicast group.
+func NewBrowser(opts ...BrowserOption) *Browser {
+	b := &Browser{
+		addr:     &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353},
+		interval: DefaultQueryInterval,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Browse queries for commissionable nodes until ctx is done and returns
+// the nodes found, with records merged across responses.
+func (b *Browser) Browse(ctx context.Context) ([]CommissionableNode, error) {
+	query, err := buildQuery()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	defer conn.Close()
+
+	packets := make(chan []byte, 32)
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				close(packets)
+				return
+			}
+			select {
+			case packets <- append([]byte(nil), buf[:n]...):
+			default:
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	if _, err := conn.WriteToUDP(query, b.addr); err != nil {
+		return nil, fmt.Errorf("failed to send query: %w", err)
+	}
+
+	c := newCollector()
+	for {
+		select {
+		case <-ctx.Done():
+			return c.nodes(), nil
+		case <-ticker.C:
+			_, _ = conn.WriteToUDP(query, b.addr) //nolint:errcheck // Retried on the next tick
+		case data, ok := <-packets:
+			if !ok {
+				return c.nodes(), nil
+			}
+			c.add(data)
+		}
+	}
+}
+
+// Find browses until ctx is done and returns the nodes matching the setup
+// payload.
+func (b *Browser) Find(ctx context.Context, p *SetupPayload) ([]CommissionableNode, error) {
+	nodes, err := b.Browse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []CommissionableNode
+	for i := range nodes {
+		if nodes[i].MatchesPayload(p) {
+			out = append(out, nodes[i])
+		}
+	}
+	return out, nil
+}
+
+func buildQuery() ([]byte, error) {
+	name, err := dnsmessage.NewName(CommissionableService)
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	return msg.Pack()
+}
+
+// collector merges DNS-SD records from several responses.
+type collector struct {
+	instances map[string]*CommissionableNode
+	hosts     map[string][]net.IP
+	order     []string
+}
+
+func newCollector() *collector {
+	return &collector{instances: make(map[string]*CommissionableNode), hosts: make(map[string][]net.IP)}
+}
+
+// instance returns the node of a service instance name, or nil if the
+// name isn't a commissionable node instance.
+func (c *collector) instance(name string) *CommissionableNode {
+	key := strings.ToLower(name)
+	if !strings.HasSuffix(key, "."+CommissionableService) || strings.Contains(key, "._sub.") {
+		return nil
+	}
+	n, ok := c.instances[key]
+	if !ok {
+		n = &CommissionableNode{Instance: strings.TrimSuffix(name[:len(name)-len(CommissionableService)], ".")}
+		c.instances[key] = n
+		c.order = append(c.order, key)
+	}
+	return n
+}
+
+func (c *collector) add(data []byte) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(data); err != nil || !msg.Response {
+		return
+	}
+	for _, rr := range append(msg.Answers, msg.Additionals...) {
+		name := rr.Header.Name.String()
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			c.instance(body.PTR.String())
+		case *dnsmessage.SRVResource:
+			if n := c.instance(name); n != nil {
+				n.Host, n.Port = body.Target.String(), int(body.Port)
+			}
+		case *dnsmessage.TXTResource:
+			if n := c.instance(name); n != nil {
+				n.setTXT(body.TXT)
+			}
+		case *dnsmessage.AResource:
+			c.addHost(name, body.A[:])
+		case *dnsmessage.AAAAResource:
+			c.addHost(name, body.AAAA[:])
+		}
+	}
+}
+
+func (c *collector) addHost(host string, ip net.IP) {
+	key := strings.ToLower(host)
+	if !hasAddress(c.hosts[key], ip) {
+		c.hosts[key] = append(c.hosts[key], append(net.IP(nil), ip...))
+	}
+}
+
+func (c *collector) nodes() []CommissionableNode {
+	out := make([]CommissionableNode, 0, len(c.order))
+	for _, key := range c.order {
+		n := *c.instances[key]
+		n.Addresses = c.hosts[strings.ToLower(n.Host)]
+		out = append(out, n)
+	}
+	return out
+}
+
+// setTXT parses the commissionable node TXT keys.
+func (n *CommissionableNode) setTXT(entries []string) {
+	if n.TXT == nil {
+		n.TXT = make(map[string]string)
+	}
+	for _, entry := range entries {
+		key, value, _ := strings.Cut(entry, "=")
+		n.TXT[key] = value
+		switch key {
+		case "D":
+			n.Discriminator = uint16(parseUint(value, 12))
+		case "VP":
+			vid, pid, _ := strings.Cut(value, "+")
+			n.VendorID, n.ProductID = uint16(parseUint(vid, 16)), uint16(parseUint(pid, 16))
+		case "CM":
+			n.CommissioningMode = uint8(parseUint(value, 8))
+		case "DT":
+			n.DeviceType = uint32(parseUint(value, 32))
+		case "DN":
+			n.DeviceName = value
+		case "PH":
+			n.PairingHint = uint16(parseUint(value, 16))
+		case "PI":
+			n.PairingInstruction = value
+		}
+	}
+}
+
+// parseUint parses a decimal TXT value, zero if invalid.
+func parseUint(s string, bits int) uint64 {
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0
+	}
+	return v
+}
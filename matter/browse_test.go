@@ -0,0 +1,166 @@
+package matter
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/tj-smith47/shelly-go/discovery"
+	_ "github.com/tj-smith47/shelly-go/profiles/gen4"
+)
+
+// startResponder runs a stand-in mDNS responder answering every query
+// with the given packets.
+func startResponder(t *testing.T, packets ...[]byte) *net.UDPAddr {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if msg.Unpack(buf[:n]) != nil || len(msg.Questions) != 1 ||
+				msg.Questions[0].Name.String() != CommissionableService {
+				continue
+			}
+			for _, p := range packets {
+				_, _ = conn.WriteToUDP(p, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func buildResponse(t *testing.T, instance, hostname string, txt []string, ip net.IP) []byte {
+	t.Helper()
+	service := dnsmessage.MustNewName(CommissionableService)
+	name := dnsmessage.MustNewName(instance + "." + CommissionableService)
+	host := dnsmessage.MustNewName(hostname)
+	hdr := func(n dnsmessage.Name, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: n, Type: typ, Class: dnsmessage.ClassINET, TTL: 120}
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(b.StartAnswers())
+	must(b.PTRResource(hdr(service, dnsmessage.TypePTR), dnsmessage.PTRResource{PTR: name}))
+	subtype := dnsmessage.MustNewName("_L3840._sub." + CommissionableService)
+	must(b.PTRResource(hdr(subtype, dnsmessage.TypePTR), dnsmessage.PTRResource{PTR: name}))
+	must(b.StartAdditionals())
+	must(b.SRVResource(hdr(name, dnsmessage.TypeSRV), dnsmessage.SRVResource{Target: host, Port: 5540}))
+	must(b.TXTResource(hdr(name, dnsmessage.TypeTXT), dnsmessage.TXTResource{TXT: txt}))
+	var a [4]byte
+	copy(a[:], ip.To4())
+	must(b.AResource(hdr(host, dnsmessage.TypeA), dnsmessage.AResource{A: a}))
+	out, err := b.Finish()
+	must(err)
+	return out
+}
+
+func TestBrowser_Browse(t *testing.T) {
+	addr := startResponder(t,
+		buildResponse(t, "8F3C1A2B4D5E6F70", "A0B1C2D3E4F5.local.", []string{"D=3840", "VP=65521+32769", "CM=1", "DT=266", "DN=Plug", "PH=33"}, net.IPv4(192, 168, 1, 50)),
+		buildResponse(t, "0011223344556677", "esp32.local.", []string{"D=1024", "CM=2"}, net.IPv4(192, 168, 1, 51)),
+		[]byte("garbage"),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	b := NewBrowser(WithResolver(addr), WithQueryInterval(50*time.Millisecond))
+	nodes, err := b.Browse(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("Browse() returned %d nodes, want 2", len(nodes))
+	}
+
+	n := nodes[0]
+	if n.Instance != "8F3C1A2B4D5E6F70" || n.Host != "A0B1C2D3E4F5.local." || n.Port != 5540 ||
+		n.Discriminator != 3840 || n.VendorID != 0xFFF1 || n.ProductID != 0x8001 ||
+		n.CommissioningMode != 1 || n.DeviceType != 266 || n.DeviceName != "Plug" || n.PairingHint != 33 {
+		t.Errorf("node = %+v", n)
+	}
+	if len(n.Addresses) != 1 || !n.Addresses[0].Equal(net.IPv4(192, 168, 1, 50)) {
+		t.Errorf("addresses = %v", n.Addresses)
+	}
+	if n.MAC() != "A0B1C2D3E4F5" {
+		t.Errorf("MAC() = %q", n.MAC())
+	}
+
+	found, err := b.Find(ctx, testPayload())
+	if err != nil || len(found) != 0 {
+		t.Errorf("Find(done ctx) = %v, %v", found, err)
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel2()
+	found, err = b.Find(ctx2, testPayload())
+	if err != nil || len(found) != 1 || found[0].Instance != n.Instance {
+		t.Errorf("Find() = %v, %v", found, err)
+	}
+}
+
+func TestCommissionableNode_MatchesPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		node CommissionableNode
+		want bool
+	}{
+		{name: "match", node: CommissionableNode{Discriminator: 3840, VendorID: 0xFFF1, ProductID: 0x8001}, want: true},
+		{name: "no ids advertised", node: CommissionableNode{Discriminator: 3840}, want: true},
+		{name: "discriminator", node: CommissionableNode{Discriminator: 3841}},
+		{name: "vendor", node: CommissionableNode{Discriminator: 3840, VendorID: 0x1234}},
+		{name: "product", node: CommissionableNode{Discriminator: 3840, VendorID: 0xFFF1, ProductID: 1}},
+	}
+	for _, tt := range tests {
+		if got := tt.node.MatchesPayload(testPayload()); got != tt.want {
+			t.Errorf("%s: MatchesPayload() = %v", tt.name, got)
+		}
+	}
+
+	short := &SetupPayload{Discriminator: 15, ShortDiscriminator: true}
+	if n := (CommissionableNode{Discriminator: 3899}); !n.MatchesPayload(short) {
+		t.Error("short discriminator should match upper bits")
+	}
+}
+
+func TestLinkDevices(t *testing.T) {
+	nodes := []CommissionableNode{
+		{Instance: "by-mac", Host: "shelly1pmg4-a0b1c2d3e4f5.local."},
+		{Instance: "by-ip", Host: "esp.local.", Addresses: []net.IP{net.IPv4(192, 168, 1, 60)}},
+		{Instance: "unknown", Host: "FFFFFFFFFFFF.local."},
+	}
+	devices := []discovery.DiscoveredDevice{
+		{ID: "shelly1pmg4-a0b1c2d3e4f5", Model: "S4SW-001P16EU", Address: net.IPv4(192, 168, 1, 10)},
+		{ID: "other", MACAddress: "11:22:33:44:55:66", Model: "unregistered", Address: net.IPv4(192, 168, 1, 60)},
+	}
+	LinkDevices(nodes, devices)
+
+	if nodes[0].Device != &devices[0] {
+		t.Errorf("by-mac linked to %v", nodes[0].Device)
+	}
+	if nodes[0].Profile == nil || nodes[0].Profile.Model != "S4SW-001P16EU" {
+		t.Errorf("by-mac profile = %v", nodes[0].Profile)
+	}
+	if nodes[1].Device != &devices[1] || nodes[1].Profile != nil {
+		t.Errorf("by-ip = %v, %v", nodes[1].Device, nodes[1].Profile)
+	}
+	if nodes[2].Device != nil {
+		t.Errorf("unknown linked to %v", nodes[2].Device)
+	}
+}
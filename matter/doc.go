@@ -11,6 +11,8 @@
 //   - Commissioning status and code retrieval
 //   - Fabric (network) management
 //   - Factory reset for Matter data
+//   - Setup payload (QR code and manual pairing code) encoding and parsing
+//   - DNS-SD browsing for commissionable nodes
 //
 // # Supported Devices
 //
@@ -36,6 +38,35 @@
 //
 //  3. Use the pairing code in your Matter controller (Apple Home, Google Home, etc.)
 //
+// # Setup Payloads
+//
+// SetupPayload holds the onboarding data of a device: vendor and product
+// ID, discriminator, passcode, commissioning flow and discovery
+// capabilities. It converts to and from both "MT:" QR codes and 11 or
+// 21-digit manual pairing codes, which carry a Verhoeff check digit:
+//
+//	p, err := matter.ParseSetupCode("MT:-24J0AFN00KA0648G00")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	code, _ := p.ManualCode()
+//	fmt.Println(matter.FormatManualCode(code)) // 3497-011-2332
+//
+// Manual codes only carry the upper 4 bits of the discriminator; use
+// MatchesDiscriminator to compare. CommissioningInfo.Validate checks that
+// the codes reported by a device agree with each other.
+//
+// # Discovery
+//
+// Browser finds nodes advertising _matterc._udp, i.e. devices that are
+// open for commissioning. LinkDevices attaches them to the Shelly devices
+// found by the discovery package, matching by MAC or IP address:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+//	defer cancel()
+//	nodes, err := matter.NewBrowser().Find(ctx, p)
+//	matter.LinkDevices(nodes, devices)
+//
 // # Switching Protocols
 //
 // Gen4 devices can switch between Matter and Zigbee protocols:
@@ -0,0 +1,145 @@
+package matter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Manual pairing code lengths.
+const (
+	ManualCodeLength     = 11
+	LongManualCodeLength = 21
+)
+
+// ManualCode encodes the payload as a manual pairing code: 11 digits, or
+// 21 digits with the vendor and product ID for non-standard commissioning
+// flows, which require them.
+func (p *SetupPayload) ManualCode() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	long := p.Flow != FlowStandard
+	short := p.ShortDiscriminatorValue()
+
+	var sb strings.Builder
+	first := short >> 2
+	if long {
+		first |= 1 << 2
+	}
+	fmt.Fprintf(&sb, "%d%05d%04d", first, uint32(short&0x3)<<14|p.Passcode&0x3FFF, p.Passcode>>14)
+	if long {
+		fmt.Fprintf(&sb, "%05d%05d", p.VendorID, p.ProductID)
+	}
+	code := sb.String()
+	return code + string(verhoeffCheck(code)), nil
+}
+
+// ParseManualCode parses and validates an 11 or 21-digit manual pairing
+// code. Spaces and dashes are ignored. The payload has a short
+// discriminator.
+func ParseManualCode(code string) (*SetupPayload, error) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	if len(digits) != ManualCodeLength && len(digits) != LongManualCodeLength {
+		return nil, fmt.Errorf("%w: manual code must have %d or %d digits", ErrInvalidPayload, ManualCodeLength, LongManualCodeLength)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("%w: manual code must be digits", ErrInvalidPayload)
+		}
+	}
+	body := digits[:len(digits)-1]
+	if verhoeffCheck(body) != digits[len(digits)-1] {
+		return nil, fmt.Errorf("%w: wrong check digit", ErrInvalidPayload)
+	}
+
+	first := digits[0] - '0'
+	if first > 7 {
+		return nil, fmt.Errorf("%w: unsupported manual code version", ErrInvalidPayload)
+	}
+	long := first&(1<<2) != 0
+	if long != (len(digits) == LongManualCodeLength) {
+		return nil, fmt.Errorf("%w: manual code length doesn't match its vendor/product flag", ErrInvalidPayload)
+	}
+	chunk2, _ := strconv.ParseUint(digits[1:6], 10, 32)  //nolint:errcheck // Checked to be digits
+	chunk3, _ := strconv.ParseUint(digits[6:10], 10, 32) //nolint:errcheck // Checked to be digits
+	if chunk2 > 0xFFFF || chunk3 > 0x1FFF {
+		return nil, fmt.Errorf("%w: manual code out of range", ErrInvalidPayload)
+	}
+
+	p := &SetupPayload{
+		Discriminator:      uint16(first&0x3)<<2 | uint16(chunk2>>14),
+		ShortDiscriminator: true,
+		Passcode:           uint32(chunk3)<<14 | uint32(chunk2&0x3FFF),
+	}
+	if long {
+		vid, _ := strconv.ParseUint(digits[10:15], 10, 32) //nolint:errcheck // Checked to be digits
+		pid, _ := strconv.ParseUint(digits[15:20], 10, 32) //nolint:errcheck // Checked to be digits
+		if vid > 0xFFFF || pid > 0xFFFF {
+			return nil, fmt.Errorf("%w: vendor or product ID out of range", ErrInvalidPayload)
+		}
+		p.VendorID, p.ProductID = uint16(vid), uint16(pid)
+		// The flow isn't encoded; custom flows are the reason for a long
+		// code.
+		p.Flow = FlowCustom
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// FormatManualCode groups a manual code for printing: 4-3-4 digits, and
+// the vendor and product ID as 5-5 digits for 21-digit codes.
+func FormatManualCode(code string) string {
+	groups := []int{4, 3, 4, 5, 5}
+	var parts []string
+	for _, n := range groups {
+		if len(code) <= n {
+			break
+		}
+		parts = append(parts, code[:n])
+		code = code[n:]
+	}
+	if code != "" {
+		parts = append(parts, code)
+	}
+	return strings.Join(parts, "-")
+}
+
+// Verhoeff check digit tables.
+var (
+	verhoeffD = [10][10]byte{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]byte{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+	verhoeffInv = [10]byte{0, 4, 3, 2, 1, 5, 6, 7, 8, 9}
+)
+
+// verhoeffCheck returns the Verhoeff check digit of a digit string.
+func verhoeffCheck(digits string) byte {
+	var c byte
+	for i := range len(digits) {
+		d := digits[len(digits)-1-i] - '0'
+		c = verhoeffD[c][verhoeffP[(i+1)%8][d]]
+	}
+	return '0' + verhoeffInv[c]
+}
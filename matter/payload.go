@@ -0,0 +1,205 @@
+package matter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPayload is returned for a setup payload, QR code or manual
+// pairing code that is malformed or violates the Matter rules.
+var ErrInvalidPayload = errors.New("matter: invalid setup payload")
+
+// CommissioningFlow is how a device enters commissioning mode.
+type CommissioningFlow uint8
+
+const (
+	// FlowStandard devices are commissionable when powered on.
+	FlowStandard CommissioningFlow = 0
+
+	// FlowUserIntent devices need a user action, e.g. a button press.
+	FlowUserIntent CommissioningFlow = 1
+
+	// FlowCustom devices need vendor-specific steps.
+	FlowCustom CommissioningFlow = 2
+)
+
+// DiscoveryCapabilities is the bitmask of transports a device can be
+// discovered on for commissioning.
+type DiscoveryCapabilities uint8
+
+const (
+	// DiscoverySoftAP is discovery through a Wi-Fi soft access point.
+	DiscoverySoftAP DiscoveryCapabilities = 1 << 0
+
+	// DiscoveryBLE is discovery over Bluetooth LE.
+	DiscoveryBLE DiscoveryCapabilities = 1 << 1
+
+	// DiscoveryOnNetwork is discovery on the IP network (DNS-SD).
+	DiscoveryOnNetwork DiscoveryCapabilities = 1 << 2
+
+	// DiscoveryWiFiPAF is discovery through Wi-Fi Public Action Frames.
+	DiscoveryWiFiPAF DiscoveryCapabilities = 1 << 3
+)
+
+// Setup payload limits.
+const (
+	// MaxDiscriminator is the largest 12-bit discriminator.
+	MaxDiscriminator = 0xFFF
+
+	// MaxShortDiscriminator is the largest 4-bit short discriminator.
+	MaxShortDiscriminator = 0xF
+
+	// MaxPasscode is the largest valid setup passcode.
+	MaxPasscode = 99999998
+)
+
+// SetupPayload is the onboarding payload of a Matter device, as encoded
+// in its QR code and manual pairing code.
+type SetupPayload struct {
+	// Extra holds the optional TLV data following the QR code's fixed
+	// fields, such as a serial number; it is kept as is.
+	Extra []byte `json:"extra,omitempty"`
+
+	// Passcode is the setup PIN code (1-99999998).
+	Passcode uint32 `json:"passcode"`
+
+	// VendorID and ProductID identify the product; zero if unknown, as in
+	// 11-digit manual codes.
+	VendorID  uint16 `json:"vendor_id,omitempty"`
+	ProductID uint16 `json:"product_id,omitempty"`
+
+	// Discriminator distinguishes devices during discovery: 12 bits, or
+	// the upper 4 bits if ShortDiscriminator is set, as in manual codes.
+	Discriminator uint16 `json:"discriminator"`
+
+	// Version is the payload version; only 0 is defined.
+	Version uint8 `json:"version"`
+
+	// Flow is how the device enters commissioning mode.
+	Flow CommissioningFlow `json:"flow"`
+
+	// Capabilities lists the discovery transports; QR codes only.
+	Capabilities DiscoveryCapabilities `json:"capabilities,omitempty"`
+
+	// ShortDiscriminator reports that Discriminator holds only its upper
+	// 4 bits.
+	ShortDiscriminator bool `json:"short_discriminator,omitempty"`
+}
+
+// Validate checks the payload against the Matter rules.
+func (p *SetupPayload) Validate() error {
+	if p.Version != 0 {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidPayload, p.Version)
+	}
+	if p.Flow > FlowCustom {
+		return fmt.Errorf("%w: unknown commissioning flow %d", ErrInvalidPayload, p.Flow)
+	}
+	if p.Capabilities&^(DiscoverySoftAP|DiscoveryBLE|DiscoveryOnNetwork|DiscoveryWiFiPAF) != 0 {
+		return fmt.Errorf("%w: reserved discovery capabilities 0x%02x", ErrInvalidPayload, uint8(p.Capabilities))
+	}
+	limit := uint16(MaxDiscriminator)
+	if p.ShortDiscriminator {
+		limit = MaxShortDiscriminator
+	}
+	if p.Discriminator > limit {
+		return fmt.Errorf("%w: discriminator %d exceeds %d", ErrInvalidPayload, p.Discriminator, limit)
+	}
+	return ValidatePasscode(p.Passcode)
+}
+
+// ShortDiscriminatorValue returns the upper 4 bits of the discriminator,
+// as used in manual codes and the _S DNS-SD subtype.
+func (p *SetupPayload) ShortDiscriminatorValue() uint16 {
+	if p.ShortDiscriminator {
+		return p.Discriminator
+	}
+	return p.Discriminator >> 8
+}
+
+// MatchesDiscriminator reports whether a device's 12-bit discriminator
+// matches the payload's, comparing only the upper 4 bits of a short
+// discriminator.
+func (p *SetupPayload) MatchesDiscriminator(discriminator uint16) bool {
+	if p.ShortDiscriminator {
+		return discriminator>>8 == p.Discriminator
+	}
+	return discriminator == p.Discriminator
+}
+
+// ValidatePasscode checks a setup passcode: it must be 1-99999998 and not
+// one of the trivial codes 11111111-88888888, 12345678 and 87654321.
+func ValidatePasscode(passcode uint32) error {
+	if passcode == 0 || passcode > MaxPasscode {
+		return fmt.Errorf("%w: passcode must be 1-%d", ErrInvalidPayload, MaxPasscode)
+	}
+	switch passcode {
+	case 11111111, 22222222, 33333333, 44444444, 55555555, 66666666, 77777777, 88888888, 12345678, 87654321:
+		return fmt.Errorf("%w: trivial passcode %08d", ErrInvalidPayload, passcode)
+	}
+	return nil
+}
+
+// ParseSetupCode parses a QR code payload ("MT:...") or a manual pairing
+// code.
+func ParseSetupCode(code string) (*SetupPayload, error) {
+	if strings.HasPrefix(strings.TrimSpace(code), qrPrefix) {
+		return ParseQRCode(code)
+	}
+	return ParseManualCode(code)
+}
+
+// Payload parses the device's QR code, or its manual code if it has no QR
+// code.
+func (c *CommissioningInfo) Payload() (*SetupPayload, error) {
+	if c.QRCode != "" {
+		return ParseQRCode(c.QRCode)
+	}
+	if c.ManualCode != "" {
+		return ParseManualCode(c.ManualCode)
+	}
+	return nil, fmt.Errorf("%w: no setup code", ErrInvalidPayload)
+}
+
+// Validate checks that the QR code and manual code are valid and agree
+// with each other and with Discriminator and SetupPinCode, as printed on
+// a device label. Empty fields are not checked.
+func (c *CommissioningInfo) Validate() error {
+	var payloads []*SetupPayload
+	for _, code := range []struct {
+		parse func(string) (*SetupPayload, error)
+		value string
+		name  string
+	}{
+		{ParseQRCode, c.QRCode, "QR code"},
+		{ParseManualCode, c.ManualCode, "manual code"},
+	} {
+		if code.value == "" {
+			continue
+		}
+		p, err := code.parse(code.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", code.name, err)
+		}
+		payloads = append(payloads, p)
+	}
+
+	for _, p := range payloads {
+		if c.SetupPinCode != 0 && p.Passcode != uint32(c.SetupPinCode) {
+			return fmt.Errorf("%w: passcode %08d doesn't match setup PIN code %08d", ErrInvalidPayload, p.Passcode, c.SetupPinCode)
+		}
+		if c.Discriminator != 0 && !p.MatchesDiscriminator(uint16(c.Discriminator)) {
+			return fmt.Errorf("%w: discriminator doesn't match %d", ErrInvalidPayload, c.Discriminator)
+		}
+	}
+	if len(payloads) == 2 {
+		qr, manual := payloads[0], payloads[1]
+		if qr.Passcode != manual.Passcode || !manual.MatchesDiscriminator(qr.Discriminator) {
+			return fmt.Errorf("%w: QR code and manual code differ", ErrInvalidPayload)
+		}
+		if manual.VendorID != 0 && (manual.VendorID != qr.VendorID || manual.ProductID != qr.ProductID) {
+			return fmt.Errorf("%w: QR code and manual code differ in vendor or product", ErrInvalidPayload)
+		}
+	}
+	return nil
+}
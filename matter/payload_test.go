@@ -0,0 +1,257 @@
+package matter
+
+import (
+	"errors"
+	"testing"
+)
+
+// Reference payloads from the Matter SDK examples.
+const (
+	testQRCode     = "MT:-24J0AFN00KA0648G00"
+	testManualCode = "34970112332"
+)
+
+func testPayload() *SetupPayload {
+	return &SetupPayload{
+		VendorID:      0xFFF1,
+		ProductID:     0x8001,
+		Capabilities:  DiscoveryOnNetwork,
+		Discriminator: 3840,
+		Passcode:      20202021,
+	}
+}
+
+func TestSetupPayload_QRCode(t *testing.T) {
+	p := testPayload()
+	code, err := p.QRCode()
+	if err != nil || code != testQRCode {
+		t.Fatalf("QRCode() = %q, %v, want %q", code, err, testQRCode)
+	}
+
+	got, err := ParseQRCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VendorID != p.VendorID || got.ProductID != p.ProductID || got.Discriminator != 3840 ||
+		got.Passcode != p.Passcode || got.Capabilities != DiscoveryOnNetwork || got.Flow != FlowStandard {
+		t.Errorf("ParseQRCode() = %+v", got)
+	}
+
+	// Optional TLV data round trips.
+	p.Extra = []byte{0x15, 0x2c, 0x00, 0x03, 'S', 'N', '1', 0x18}
+	code, _ = p.QRCode()
+	if got, err := ParseQRCode(code); err != nil || string(got.Extra) != string(p.Extra) {
+		t.Errorf("extra = %v, %v", got, err)
+	}
+}
+
+func TestParseQRCode_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"no prefix", "-24J0AFN00KA0648G00"},
+		{"bad character", "MT:-24J0AFN00KA0648G0a"},
+		{"bad length", "MT:-24J0AFN00KA0648G0"},
+		{"too short", "MT:-24J0AFN00"},
+		{"multiple", testQRCode + "*-24J0AFN00KA0648G00"},
+		{"out of range chunk", "MT:....."},
+	}
+	for _, tt := range tests {
+		if _, err := ParseQRCode(tt.code); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+
+	payloads, err := ParseQRCodes(testQRCode + "*-24J0AFN00KA0648G00")
+	if err != nil || len(payloads) != 2 {
+		t.Errorf("ParseQRCodes() = %v, %v", payloads, err)
+	}
+}
+
+func TestSetupPayload_ManualCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload *SetupPayload
+		want    string
+	}{
+		{name: "standard", payload: testPayload(), want: testManualCode},
+		{
+			name:    "custom flow includes ids",
+			payload: &SetupPayload{VendorID: 0xFFF1, ProductID: 0x8001, Flow: FlowCustom, Discriminator: 3840, Passcode: 20202021},
+			want:    "749701123365521327694",
+		},
+		{
+			name:    "short discriminator",
+			payload: &SetupPayload{Discriminator: 15, ShortDiscriminator: true, Passcode: 20202021},
+			want:    testManualCode,
+		},
+	}
+	for _, tt := range tests {
+		code, err := tt.payload.ManualCode()
+		if err != nil || code != tt.want {
+			t.Errorf("%s: ManualCode() = %q, %v, want %q", tt.name, code, err, tt.want)
+			continue
+		}
+		got, err := ParseManualCode(code)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got.Passcode != tt.payload.Passcode || got.Discriminator != tt.payload.ShortDiscriminatorValue() ||
+			!got.ShortDiscriminator || got.VendorID != ifLong(tt.payload, tt.payload.VendorID) {
+			t.Errorf("%s: ParseManualCode() = %+v", tt.name, got)
+		}
+		if !got.MatchesDiscriminator(3840) || got.MatchesDiscriminator(3584) {
+			t.Errorf("%s: discriminator match", tt.name)
+		}
+	}
+}
+
+func ifLong(p *SetupPayload, v uint16) uint16 {
+	if p.Flow == FlowStandard {
+		return 0
+	}
+	return v
+}
+
+func TestParseManualCode_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"length", "3497011233"},
+		{"letters", "3497011233a"},
+		{"check digit", "34970112333"},
+		{"long flag without ids", "7497011233" + string(verhoeffCheck("7497011233"))},
+		{"ids without long flag", "349701123365521327694"},
+		{"version", "8497011233" + string(verhoeffCheck("8497011233"))},
+		{"trivial passcode", mustManual(t, 12345678)},
+	}
+	for _, tt := range tests {
+		if _, err := ParseManualCode(tt.code); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+
+	// Separators are ignored.
+	if p, err := ParseManualCode("3497-011-2332"); err != nil || p.Passcode != 20202021 {
+		t.Errorf("formatted = %+v, %v", p, err)
+	}
+}
+
+// mustManual builds a manual code without validating the passcode.
+func mustManual(t *testing.T, passcode uint32) string {
+	t.Helper()
+	body := "0" + pad(uint32(passcode&0x3FFF), 5) + pad(passcode>>14, 4)
+	return body + string(verhoeffCheck(body))
+}
+
+func pad(v uint32, n int) string {
+	s := ""
+	for range n {
+		s = string(rune('0'+v%10)) + s
+		v /= 10
+	}
+	return s
+}
+
+func TestVerhoeff(t *testing.T) {
+	// Classic example: 236 has check digit 3.
+	if c := verhoeffCheck("236"); c != '3' {
+		t.Errorf("verhoeffCheck(236) = %c", c)
+	}
+	// Every single-digit error and adjacent transposition is caught.
+	code := testManualCode
+	for i := range len(code) {
+		for d := byte('0'); d <= '9'; d++ {
+			if d == code[i] {
+				continue
+			}
+			bad := code[:i] + string(d) + code[i+1:]
+			if verhoeffCheck(bad[:10]) == bad[10] {
+				t.Errorf("error at %d not detected: %s", i, bad)
+			}
+		}
+		if i+1 < len(code) && code[i] != code[i+1] {
+			swapped := code[:i] + string(code[i+1]) + string(code[i]) + code[i+2:]
+			if verhoeffCheck(swapped[:10]) == swapped[10] {
+				t.Errorf("transposition at %d not detected", i)
+			}
+		}
+	}
+}
+
+func TestSetupPayload_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(p *SetupPayload)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(*SetupPayload) {}},
+		{name: "version", modify: func(p *SetupPayload) { p.Version = 1 }, wantErr: true},
+		{name: "flow", modify: func(p *SetupPayload) { p.Flow = 3 }, wantErr: true},
+		{name: "reserved capability", modify: func(p *SetupPayload) { p.Capabilities = 0x10 }, wantErr: true},
+		{name: "discriminator", modify: func(p *SetupPayload) { p.Discriminator = 4096 }, wantErr: true},
+		{name: "short discriminator", modify: func(p *SetupPayload) { p.ShortDiscriminator = true }, wantErr: true},
+		{name: "zero passcode", modify: func(p *SetupPayload) { p.Passcode = 0 }, wantErr: true},
+		{name: "large passcode", modify: func(p *SetupPayload) { p.Passcode = 99999999 }, wantErr: true},
+		{name: "trivial passcode", modify: func(p *SetupPayload) { p.Passcode = 87654321 }, wantErr: true},
+		{name: "max passcode", modify: func(p *SetupPayload) { p.Passcode = MaxPasscode }},
+	}
+	for _, tt := range tests {
+		p := testPayload()
+		tt.modify(p)
+		if err := p.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+		if _, err := p.QRCode(); (err != nil) != (tt.wantErr || p.ShortDiscriminator) {
+			t.Errorf("%s: QRCode() = %v", tt.name, err)
+		}
+	}
+}
+
+func TestCommissioningInfo_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    CommissioningInfo
+		wantErr bool
+	}{
+		{name: "consistent", info: CommissioningInfo{QRCode: testQRCode, ManualCode: testManualCode, Discriminator: 3840, SetupPinCode: 20202021}},
+		{name: "qr only", info: CommissioningInfo{QRCode: testQRCode}},
+		{name: "empty", info: CommissioningInfo{}},
+		{name: "bad qr", info: CommissioningInfo{QRCode: "MT:000"}, wantErr: true},
+		{name: "pin mismatch", info: CommissioningInfo{ManualCode: testManualCode, SetupPinCode: 20202022}, wantErr: true},
+		{name: "discriminator mismatch", info: CommissioningInfo{QRCode: testQRCode, Discriminator: 3841}, wantErr: true},
+		{name: "codes differ", info: CommissioningInfo{QRCode: testQRCode, ManualCode: mustManual(t, 20202022)}, wantErr: true},
+		{name: "ids differ", info: CommissioningInfo{QRCode: testQRCode, ManualCode: "749701123365521327702"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.info.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+
+	info := CommissioningInfo{ManualCode: testManualCode}
+	if p, err := info.Payload(); err != nil || p.Passcode != 20202021 {
+		t.Errorf("Payload() = %+v, %v", p, err)
+	}
+	if _, err := (&CommissioningInfo{}).Payload(); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Payload(empty) = %v", err)
+	}
+	if p, err := ParseSetupCode(" " + testQRCode); err != nil || p.VendorID != 0xFFF1 {
+		t.Errorf("ParseSetupCode() = %+v, %v", p, err)
+	}
+}
+
+func TestFormatManualCode(t *testing.T) {
+	tests := map[string]string{
+		testManualCode:          "3497-011-2332",
+		"749701123365521327694": "7497-011-2336-55213-27694",
+		"123":                   "123",
+	}
+	for in, want := range tests {
+		if got := FormatManualCode(in); got != want {
+			t.Errorf("FormatManualCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package matter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// qrPrefix starts every Matter QR code payload.
+const qrPrefix = "MT:"
+
+// base38Chars is the QR code alphabet.
+const base38Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-."
+
+// QR code bit fields, least significant first.
+const (
+	versionBits       = 3
+	vendorIDBits      = 16
+	productIDBits     = 16
+	flowBits          = 2
+	capabilitiesBits  = 8
+	discriminatorBits = 12
+	passcodeBits      = 27
+	paddingBits       = 4
+	payloadBytes      = (versionBits + vendorIDBits + productIDBits + flowBits +
+		capabilitiesBits + discriminatorBits + passcodeBits + paddingBits) / 8
+)
+
+// QRCode encodes the payload as a QR code string ("MT:...").
+func (p *SetupPayload) QRCode() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	if p.ShortDiscriminator {
+		return "", fmt.Errorf("%w: QR codes need the full discriminator", ErrInvalidPayload)
+	}
+
+	var w bitWriter
+	w.write(uint64(p.Version), versionBits)
+	w.write(uint64(p.VendorID), vendorIDBits)
+	w.write(uint64(p.ProductID), productIDBits)
+	w.write(uint64(p.Flow), flowBits)
+	w.write(uint64(p.Capabilities), capabilitiesBits)
+	w.write(uint64(p.Discriminator), discriminatorBits)
+	w.write(uint64(p.Passcode), passcodeBits)
+	w.write(0, paddingBits)
+	return qrPrefix + base38Encode(append(w.buf, p.Extra...)), nil
+}
+
+// ParseQRCode parses and validates a QR code payload ("MT:..."). Codes
+// holding several payloads separated by "*" are rejected; use
+// ParseQRCodes.
+func ParseQRCode(code string) (*SetupPayload, error) {
+	payloads, err := ParseQRCodes(code)
+	if err != nil {
+		return nil, err
+	}
+	if len(payloads) != 1 {
+		return nil, fmt.Errorf("%w: QR code holds %d payloads", ErrInvalidPayload, len(payloads))
+	}
+	return payloads[0], nil
+}
+
+// ParseQRCodes parses a QR code holding one or more payloads separated by
+// "*", as on multi-device packaging.
+func ParseQRCodes(code string) ([]*SetupPayload, error) {
+	code = strings.TrimSpace(code)
+	if !strings.HasPrefix(code, qrPrefix) {
+		return nil, fmt.Errorf("%w: QR code must start with %q", ErrInvalidPayload, qrPrefix)
+	}
+	var payloads []*SetupPayload
+	for _, part := range strings.Split(strings.TrimPrefix(code, qrPrefix), "*") {
+		p, err := parseQRPayload(part)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, nil
+}
+
+func parseQRPayload(s string) (*SetupPayload, error) {
+	data, err := base38Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < payloadBytes {
+		return nil, fmt.Errorf("%w: QR code payload too short", ErrInvalidPayload)
+	}
+
+	r := bitReader{buf: data[:payloadBytes]}
+	p := &SetupPayload{
+		Version:       uint8(r.read(versionBits)),
+		VendorID:      uint16(r.read(vendorIDBits)),
+		ProductID:     uint16(r.read(productIDBits)),
+		Flow:          CommissioningFlow(r.read(flowBits)),
+		Capabilities:  DiscoveryCapabilities(r.read(capabilitiesBits)),
+		Discriminator: uint16(r.read(discriminatorBits)),
+		Passcode:      uint32(r.read(passcodeBits)),
+	}
+	if r.read(paddingBits) != 0 {
+		return nil, fmt.Errorf("%w: nonzero padding", ErrInvalidPayload)
+	}
+	if len(data) > payloadBytes {
+		p.Extra = data[payloadBytes:]
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// bitWriter packs fields least significant bit first.
+type bitWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *bitWriter) write(v uint64, bits int) {
+	for i := range bits {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>i&1 == 1 {
+			w.buf[w.n/8] |= 1 << (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+// bitReader unpacks fields written by bitWriter.
+type bitReader struct {
+	buf []byte
+	n   int
+}
+
+func (r *bitReader) read(bits int) uint64 {
+	var v uint64
+	for i := range bits {
+		if r.buf[r.n/8]>>(r.n%8)&1 == 1 {
+			v |= 1 << i
+		}
+		r.n++
+	}
+	return v
+}
+
+// base38Encode encodes three bytes as five characters, and a trailing
+// two or one bytes as four or two characters.
+func base38Encode(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i += 3 {
+		n := min(3, len(data)-i)
+		var v uint32
+		for j := n - 1; j >= 0; j-- {
+			v = v<<8 | uint32(data[i+j])
+		}
+		for range [...]int{2, 4, 5}[n-1] {
+			sb.WriteByte(base38Chars[v%38])
+			v /= 38
+		}
+	}
+	return sb.String()
+}
+
+func base38Decode(s string) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(s); i += 5 {
+		chunk := s[i:min(i+5, len(s))]
+		var n int
+		switch len(chunk) {
+		case 5:
+			n = 3
+		case 4:
+			n = 2
+		case 2:
+			n = 1
+		default:
+			return nil, fmt.Errorf("%w: bad base38 length %d", ErrInvalidPayload, len(s))
+		}
+		var v uint64
+		for j := len(chunk) - 1; j >= 0; j-- {
+			d := strings.IndexByte(base38Chars, chunk[j])
+			if d < 0 {
+				return nil, fmt.Errorf("%w: bad base38 character %q", ErrInvalidPayload, chunk[j])
+			}
+			v = v*38 + uint64(d)
+		}
+		if v >= 1<<(8*n) {
+			return nil, fmt.Errorf("%w: base38 chunk out of range", ErrInvalidPayload)
+		}
+		for range n {
+			out = append(out, byte(v))
+			v >>= 8
+		}
+	}
+	return out, nil
+}
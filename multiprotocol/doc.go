@@ -0,0 +1,55 @@
+// Package multiprotocol switches Gen4 devices between radio protocol modes.
+//
+// Gen4 devices share one radio between WiFi, Bluetooth, Matter and Zigbee,
+// and not every combination is valid: Matter runs over WiFi and can't run
+// alongside Zigbee, and a device with neither WiFi nor Zigbee can't be
+// reached at all. Switching modes by hand means ordering several SetConfig
+// calls, waiting for Matter or a Zigbee network, and recovering when
+// something goes wrong. The Manager does this as one operation.
+//
+// # Modes
+//
+// A Mode is the set of enabled protocols. Common modes are predefined:
+//
+//	multiprotocol.ModeWiFi        // WiFi + BLE
+//	multiprotocol.ModeWiFiMatter  // WiFi + BLE + Matter
+//	multiprotocol.ModeWiFiZigbee  // WiFi + BLE + Zigbee
+//	multiprotocol.ModeZigbee      // Zigbee + BLE, no WiFi
+//
+// Mode.Validate rejects invalid combinations, and with a device profile
+// also rejects protocols the device lacks.
+//
+// # Changing Modes
+//
+//	mgr := multiprotocol.NewManager(client,
+//	    multiprotocol.WithJoinTimeout(3*time.Minute),
+//	    multiprotocol.WithProgress(func(step string, p float64) {
+//	        fmt.Printf("%3.0f%% %s\n", p*100, step)
+//	    }))
+//
+//	result, err := mgr.SetMode(ctx, multiprotocol.ModeWiFiZigbee)
+//	if err != nil {
+//	    if result != nil && result.RolledBack {
+//	        fmt.Println("restored previous mode:", result.From)
+//	    }
+//	    log.Fatal(err)
+//	}
+//	fmt.Println("joined PAN", result.Network.PANID)
+//
+// SetMode proceeds in this order:
+//
+//  1. Validate the target against the device's profile
+//  2. Back up the WiFi, BLE, Zigbee and Matter configs
+//  3. Disable Matter and Zigbee if the target drops them
+//  4. Enable BLE and WiFi if the target adds them
+//  5. Enable Matter and wait until it is commissionable
+//  6. Enable Zigbee and wait for it to rejoin, or start network steering
+//  7. Disable BLE if the target drops it
+//  8. Verify the device reports the target mode
+//  9. Disable WiFi if the target drops it
+//
+// If any of steps 3-8 fails, the backup is restored and the Result has
+// RolledBack set. WiFi is disabled only after verification, since the
+// device can't be reached over local RPC afterwards; the Result reports
+// this with Unreachable.
+package multiprotocol
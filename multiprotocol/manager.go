@@ -0,0 +1,503 @@
+package multiprotocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/matter"
+	"github.com/tj-smith47/shelly-go/profiles"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+// Default timeouts.
+const (
+	// DefaultJoinTimeout is how long to wait for a Zigbee rejoin or
+	// network steering.
+	DefaultJoinTimeout = 180 * time.Second
+
+	// DefaultSettleTimeout is how long to wait for Matter to become
+	// ready after enabling it.
+	DefaultSettleTimeout = 30 * time.Second
+
+	// DefaultPollInterval is how often status is polled while waiting.
+	DefaultPollInterval = 2 * time.Second
+
+	// DefaultRollbackTimeout bounds a rollback, which runs even when the
+	// mode change's context is done.
+	DefaultRollbackTimeout = 30 * time.Second
+)
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithProgress sets a callback for progress updates, called with a step
+// description and the overall progress from 0 to 1.
+func WithProgress(fn func(step string, progress float64)) Option {
+	return func(m *Manager) {
+		m.onProgress = fn
+	}
+}
+
+// WithJoinTimeout sets how long to wait for the device to join a Zigbee
+// network.
+func WithJoinTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.joinTimeout = d
+		}
+	}
+}
+
+// WithSettleTimeout sets how long to wait for Matter to become ready.
+func WithSettleTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.settleTimeout = d
+		}
+	}
+}
+
+// WithPollInterval sets how often status is polled while waiting.
+func WithPollInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.pollInterval = d
+		}
+	}
+}
+
+// Snapshot is a backup of a device's protocol configuration.
+type Snapshot struct {
+	TakenAt time.Time `json:"taken_at"`
+
+	// Configs holds the raw Wifi, BLE, Zigbee and Matter configs, keyed
+	// by component ("wifi", "ble", "zigbee", "matter").
+	Configs map[string]json.RawMessage `json:"configs"`
+
+	// Model is the device model.
+	Model string `json:"model,omitempty"`
+
+	// Mode is the mode the device was in.
+	Mode Mode `json:"mode"`
+}
+
+// Result describes a mode change.
+type Result struct {
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+
+	// Backup is the configuration before the change.
+	Backup *Snapshot `json:"backup,omitempty"`
+
+	// Network is the Zigbee network the device joined.
+	Network *zigbee.NetworkInfo `json:"network,omitempty"`
+
+	// RollbackError is set when the rollback after a failure failed too.
+	RollbackError error `json:"-"`
+
+	// Steps lists the changes applied, in order.
+	Steps []string `json:"steps"`
+
+	// Warnings lists non-fatal problems.
+	Warnings []string `json:"warnings,omitempty"`
+
+	From Mode `json:"from"`
+	To   Mode `json:"to"`
+
+	// RolledBack reports whether the change failed and the backup was
+	// restored.
+	RolledBack bool `json:"rolled_back"`
+
+	// Unreachable reports that WiFi was disabled, so the device no longer
+	// answers local RPC.
+	Unreachable bool `json:"unreachable"`
+}
+
+// Duration returns how long the mode change took.
+func (r *Result) Duration() time.Duration {
+	return r.CompletedAt.Sub(r.StartedAt)
+}
+
+// Manager moves a Gen4 device between protocol modes.
+//
+// A mode change backs up the protocol configuration, applies the changes
+// in an order that keeps the device reachable, waits for Matter or the
+// Zigbee network, verifies the result, and restores the backup if any step
+// fails. WiFi is disabled last, only after everything else is verified.
+type Manager struct {
+	client        *rpc.Client
+	zigbee        *zigbee.Zigbee
+	matter        *matter.Matter
+	onProgress    func(step string, progress float64)
+	joinTimeout   time.Duration
+	settleTimeout time.Duration
+	pollInterval  time.Duration
+	mu            sync.Mutex
+	inProgress    bool
+}
+
+// NewManager creates a mode manager for the device behind client.
+func NewManager(client *rpc.Client, opts ...Option) *Manager {
+	m := &Manager{
+		client:        client,
+		zigbee:        zigbee.NewZigbee(client),
+		matter:        matter.NewMatter(client),
+		joinTimeout:   DefaultJoinTimeout,
+		settleTimeout: DefaultSettleTimeout,
+		pollInterval:  DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// protocolConfig is the part of a component config this package changes.
+type protocolConfig struct {
+	Enable *bool `json:"enable,omitempty"`
+}
+
+type wifiConfig struct {
+	AP   *protocolConfig `json:"ap,omitempty"`
+	STA  *protocolConfig `json:"sta,omitempty"`
+	STA1 *protocolConfig `json:"sta1,omitempty"`
+}
+
+// Current reads the device's current mode.
+func (m *Manager) Current(ctx context.Context) (Mode, error) {
+	snap, err := m.Backup(ctx)
+	if err != nil {
+		return Mode{}, err
+	}
+	return snap.Mode, nil
+}
+
+// Backup reads the device's protocol configuration.
+func (m *Manager) Backup(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{TakenAt: time.Now(), Configs: make(map[string]json.RawMessage)}
+	for _, c := range []struct {
+		key    string
+		method string
+	}{
+		{"wifi", "Wifi.GetConfig"},
+		{"ble", "BLE.GetConfig"},
+		{"zigbee", "Zigbee.GetConfig"},
+		{"matter", "Matter.GetConfig"},
+	} {
+		raw, err := m.client.Call(ctx, c.method, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s config: %w", c.key, err)
+		}
+		snap.Configs[c.key] = raw
+	}
+
+	var wifi wifiConfig
+	if err := json.Unmarshal(snap.Configs["wifi"], &wifi); err != nil {
+		return nil, fmt.Errorf("failed to parse wifi config: %w", err)
+	}
+	snap.Mode.WiFi = enabled(wifi.STA) || enabled(wifi.STA1) || enabled(wifi.AP)
+	for key, on := range map[string]*bool{"ble": &snap.Mode.BLE, "zigbee": &snap.Mode.Zigbee, "matter": &snap.Mode.Matter} {
+		var c protocolConfig
+		if err := json.Unmarshal(snap.Configs[key], &c); err != nil {
+			return nil, fmt.Errorf("failed to parse %s config: %w", key, err)
+		}
+		*on = enabled(&c)
+	}
+	return snap, nil
+}
+
+// Restore restores the BLE, Zigbee and Matter configs of a snapshot,
+// disabling protocols before enabling others. WiFi is left unchanged.
+func (m *Manager) Restore(ctx context.Context, snap *Snapshot) error {
+	order := []struct {
+		key    string
+		method string
+		on     bool
+	}{
+		{"matter", "Matter.SetConfig", snap.Mode.Matter},
+		{"zigbee", "Zigbee.SetConfig", snap.Mode.Zigbee},
+		{"ble", "BLE.SetConfig", snap.Mode.BLE},
+	}
+	var errs []error
+	for _, pass := range []bool{false, true} {
+		for _, c := range order {
+			if c.on != pass || snap.Configs[c.key] == nil {
+				continue
+			}
+			if _, err := m.client.Call(ctx, c.method, map[string]any{"config": snap.Configs[c.key]}); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore %s config: %w", c.key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetMode moves the device to the target mode.
+//
+// On failure the backup is restored and the returned Result has
+// RolledBack set; the error wraps the cause. Moving to a mode without
+// WiFi leaves the device unreachable over local RPC.
+//
+// Example:
+//
+//	mgr := multiprotocol.NewManager(client,
+//	    multiprotocol.WithProgress(func(step string, p float64) {
+//	        fmt.Printf("%3.0f%% %s\n", p*100, step)
+//	    }))
+//	result, err := mgr.SetMode(ctx, multiprotocol.ModeWiFiMatter)
+//
+//nolint:gocyclo,cyclop // Mode changes are an ordered sequence of guarded steps
+func (m *Manager) SetMode(ctx context.Context, target Mode) (*Result, error) {
+	m.mu.Lock()
+	if m.inProgress {
+		m.mu.Unlock()
+		return nil, ErrChangeInProgress
+	}
+	m.inProgress = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inProgress = false
+		m.mu.Unlock()
+	}()
+
+	result := &Result{StartedAt: time.Now(), To: target, Steps: []string{}}
+	finish := func(err error) (*Result, error) {
+		result.CompletedAt = time.Now()
+		return result, err
+	}
+
+	m.reportProgress("Reading device info", 0.05)
+	profile, model, err := m.profile(ctx)
+	if err != nil {
+		return finish(err)
+	}
+	if err := target.Validate(profile); err != nil {
+		return finish(err)
+	}
+
+	m.reportProgress("Backing up configuration", 0.10)
+	snap, err := m.Backup(ctx)
+	if err != nil {
+		return finish(err)
+	}
+	snap.Model = model
+	result.Backup = snap
+	result.From = snap.Mode
+	current := snap.Mode
+
+	if err := m.apply(ctx, current, target, result); err != nil {
+		m.reportProgress("Rolling back", 0.90)
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultRollbackTimeout)
+		defer cancel()
+		result.RollbackError = m.Restore(rctx, snap)
+		result.RolledBack = true
+		return finish(err)
+	}
+
+	if !target.WiFi && current.WiFi {
+		m.reportProgress("Disabling WiFi", 0.95)
+		off := false
+		cfg := wifiConfig{AP: &protocolConfig{&off}, STA: &protocolConfig{&off}, STA1: &protocolConfig{&off}}
+		if _, err := m.client.Call(ctx, "Wifi.SetConfig", map[string]any{"config": cfg}); err != nil {
+			// The device may drop the connection before answering
+			result.Warnings = append(result.Warnings, fmt.Sprintf("no response disabling WiFi: %v", err))
+		}
+		result.Steps = append(result.Steps, "disable wifi")
+		result.Unreachable = true
+	}
+
+	m.reportProgress("Mode change complete", 1.0)
+	return finish(nil)
+}
+
+// apply makes the changes from current to target, except disabling WiFi,
+// and verifies them.
+func (m *Manager) apply(ctx context.Context, current, target Mode, result *Result) error {
+	step := func(name string, progress float64, fn func() error) error {
+		m.reportProgress(name, progress)
+		if err := fn(); err != nil {
+			return fmt.Errorf("failed to %s: %w", name, err)
+		}
+		result.Steps = append(result.Steps, name)
+		return nil
+	}
+
+	// Free the radio first, then enable new protocols, then disable BLE
+	// while WiFi still works.
+	if current.Matter && !target.Matter {
+		if err := step("disable matter", 0.20, func() error { return m.matter.Disable(ctx) }); err != nil {
+			return err
+		}
+	}
+	if current.Zigbee && !target.Zigbee {
+		if err := step("disable zigbee", 0.25, func() error { return m.zigbee.Disable(ctx) }); err != nil {
+			return err
+		}
+	}
+	if target.BLE && !current.BLE {
+		if err := step("enable ble", 0.30, func() error { return m.setBLE(ctx, true) }); err != nil {
+			return err
+		}
+	}
+	if target.WiFi && !current.WiFi {
+		if err := step("enable wifi", 0.35, func() error { return m.enableWiFi(ctx) }); err != nil {
+			return err
+		}
+	}
+	if target.Matter && !current.Matter {
+		if err := step("enable matter", 0.40, func() error { return m.matter.Enable(ctx) }); err != nil {
+			return err
+		}
+		if err := step("wait for matter", 0.45, func() error { return m.waitMatter(ctx) }); err != nil {
+			return err
+		}
+	}
+	if target.Zigbee {
+		if !current.Zigbee {
+			if err := step("enable zigbee", 0.40, func() error { return m.zigbee.Enable(ctx) }); err != nil {
+				return err
+			}
+		}
+		if err := step("join zigbee network", 0.45, func() error {
+			info, err := m.waitZigbee(ctx)
+			result.Network = info
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	if current.BLE && !target.BLE {
+		if err := step("disable ble", 0.80, func() error { return m.setBLE(ctx, false) }); err != nil {
+			return err
+		}
+	}
+
+	m.reportProgress("Verifying mode", 0.85)
+	got, err := m.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify mode: %w", err)
+	}
+	want := target
+	want.WiFi = target.WiFi || current.WiFi // WiFi is disabled after verification
+	if got != want {
+		return fmt.Errorf("%w: device reports %s, want %s", ErrVerifyFailed, got, want)
+	}
+	return nil
+}
+
+// waitMatter waits until Matter is commissionable or already
+// commissioned.
+func (m *Manager) waitMatter(ctx context.Context) error {
+	return m.poll(ctx, m.settleTimeout, ErrMatterTimeout, "Waiting for Matter", 0.45, 0.75, func() (bool, error) {
+		status, err := m.matter.GetStatus(ctx)
+		if err != nil {
+			return false, nil //nolint:nilerr // Matter may restart while enabling
+		}
+		return status.Commissionable || status.FabricsCount > 0, nil
+	})
+}
+
+// waitZigbee waits for the device to rejoin its network. If it hasn't
+// rejoined by the second check, network steering is started.
+func (m *Manager) waitZigbee(ctx context.Context) (*zigbee.NetworkInfo, error) {
+	var info *zigbee.NetworkInfo
+	checks := 0
+	steering := false
+	err := m.poll(ctx, m.joinTimeout, ErrJoinTimeout, "Waiting for Zigbee network", 0.45, 0.75, func() (bool, error) {
+		checks++
+		status, err := m.zigbee.GetStatus(ctx)
+		if err != nil {
+			return false, nil //nolint:nilerr // Transient errors while joining are expected
+		}
+		switch status.NetworkState {
+		case zigbee.NetworkStateJoined:
+			info = &zigbee.NetworkInfo{
+				PANID:            status.PANID,
+				Channel:          status.Channel,
+				CoordinatorEUI64: status.CoordinatorEUI64,
+			}
+			return true, nil
+		case zigbee.NetworkStateFailed:
+			return false, zigbee.ErrPairingFailed
+		case zigbee.NetworkStateSteering:
+			steering = true
+		default:
+			if !steering && checks > 1 {
+				steering = true
+				m.reportProgress("Starting Zigbee network steering", 0.50)
+				if err := m.zigbee.StartNetworkSteering(ctx); err != nil {
+					return false, err
+				}
+			}
+		}
+		return false, nil
+	})
+	return info, err
+}
+
+// poll calls check immediately and then every poll interval until it
+// reports done, fails, or timeout passes, reporting progress from lo to
+// hi as time passes.
+func (m *Manager) poll(ctx context.Context, timeout time.Duration, timeoutErr error, step string, lo, hi float64,
+	check func() (bool, error)) error {
+	start := time.Now()
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+		elapsed := time.Since(start)
+		if elapsed >= timeout {
+			return timeoutErr
+		}
+		m.reportProgress(step, lo+(hi-lo)*float64(elapsed)/float64(timeout))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) setBLE(ctx context.Context, on bool) error {
+	_, err := m.client.Call(ctx, "BLE.SetConfig", map[string]any{"config": protocolConfig{&on}})
+	return err
+}
+
+func (m *Manager) enableWiFi(ctx context.Context) error {
+	on := true
+	_, err := m.client.Call(ctx, "Wifi.SetConfig", map[string]any{"config": wifiConfig{STA: &protocolConfig{&on}}})
+	return err
+}
+
+// profile returns the device's registered profile, or nil, and its model.
+func (m *Manager) profile(ctx context.Context) (*profiles.Profile, string, error) {
+	raw, err := m.client.Call(ctx, "Shelly.GetDeviceInfo", nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get device info: %w", err)
+	}
+	var info profiles.DeviceInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, "", fmt.Errorf("failed to parse device info: %w", err)
+	}
+	return profiles.DetectFromDeviceInfo(&info).Profile, info.Model, nil
+}
+
+// reportProgress calls the progress callback if set.
+func (m *Manager) reportProgress(step string, progress float64) {
+	if m.onProgress != nil {
+		m.onProgress(step, progress)
+	}
+}
+
+func enabled(c *protocolConfig) bool {
+	return c != nil && c.Enable != nil && *c.Enable
+}
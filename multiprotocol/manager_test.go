@@ -0,0 +1,337 @@
+package multiprotocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/tj-smith47/shelly-go/profiles/gen3"
+	_ "github.com/tj-smith47/shelly-go/profiles/gen4"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	return m.callFunc(ctx, req)
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// fakeDevice simulates the protocol components of a Gen4 device.
+type fakeDevice struct {
+	fail         map[string]error
+	model        string
+	zigbeeState  string
+	calls        []string
+	mode         Mode
+	joinOnSteer  bool
+	steerStarted bool
+	mu           sync.Mutex
+}
+
+func newFakeDevice(mode Mode) *fakeDevice {
+	return &fakeDevice{model: "S4SW-001X16EU", mode: mode, fail: make(map[string]error), joinOnSteer: true}
+}
+
+func (d *fakeDevice) client() *rpc.Client {
+	return rpc.NewClient(&mockTransport{callFunc: d.call})
+}
+
+func (d *fakeDevice) call(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	method := req.GetMethod()
+	d.calls = append(d.calls, method)
+	if err := d.fail[method]; err != nil {
+		return nil, err
+	}
+
+	var result any
+	switch method {
+	case "Shelly.GetDeviceInfo":
+		result = map[string]any{"id": "shelly1pmg4-aabbcc", "model": d.model, "gen": 4}
+	case "Wifi.GetConfig":
+		result = map[string]any{
+			"ap":  map[string]any{"enable": false},
+			"sta": map[string]any{"enable": d.mode.WiFi, "ssid": "home"},
+		}
+	case "BLE.GetConfig":
+		result = map[string]any{"enable": d.mode.BLE, "rpc": map[string]any{"enable": true}}
+	case "Zigbee.GetConfig":
+		result = map[string]any{"enable": d.mode.Zigbee}
+	case "Matter.GetConfig":
+		result = map[string]any{"enable": d.mode.Matter}
+	case "Wifi.SetConfig":
+		d.mode.WiFi = d.enable(req, "sta")
+		result = map[string]any{"restart_required": false}
+	case "BLE.SetConfig":
+		d.mode.BLE = d.enable(req, "")
+		result = map[string]any{"restart_required": false}
+	case "Zigbee.SetConfig":
+		d.mode.Zigbee = d.enable(req, "")
+		if !d.mode.Zigbee {
+			d.zigbeeState = "disabled"
+		} else if d.zigbeeState == "disabled" || d.zigbeeState == "" {
+			d.zigbeeState = "initializing"
+		}
+		result = map[string]any{"restart_required": false}
+	case "Matter.SetConfig":
+		d.mode.Matter = d.enable(req, "")
+		result = map[string]any{"restart_required": false}
+	case "Zigbee.GetStatus":
+		result = map[string]any{"network_state": d.zigbeeState, "channel": 15, "pan_id": 0x1a62}
+		if d.zigbeeState == "joined" {
+			result = map[string]any{"network_state": "joined", "channel": 15, "pan_id": 0x1a62,
+				"coordinator_eui64": "00:12:4b:00:01:02:03:04"}
+		}
+	case "Zigbee.StartNetworkSteering":
+		d.steerStarted = true
+		d.zigbeeState = "steering"
+		if d.joinOnSteer {
+			d.zigbeeState = "joined"
+		}
+		result = map[string]any{}
+	case "Matter.GetStatus":
+		result = map[string]any{"commissionable": d.mode.Matter, "fabrics_count": 0}
+	default:
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "result": json.RawMessage(data)})
+}
+
+// enable returns config.enable, or config.<key>.enable with a key.
+func (d *fakeDevice) enable(req transport.RPCRequest, key string) bool {
+	data, err := json.Marshal(req.GetParams())
+	if err != nil {
+		return false
+	}
+	var params struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return false
+	}
+	raw := params.Config["enable"]
+	if key != "" {
+		var sub map[string]json.RawMessage
+		if err := json.Unmarshal(params.Config[key], &sub); err != nil {
+			return false
+		}
+		raw = sub["enable"]
+	}
+	return string(raw) == "true"
+}
+
+func (d *fakeDevice) called(method string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.calls {
+		if c == method {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestManager(d *fakeDevice, opts ...Option) *Manager {
+	opts = append([]Option{WithPollInterval(time.Millisecond), WithJoinTimeout(50 * time.Millisecond),
+		WithSettleTimeout(50 * time.Millisecond)}, opts...)
+	return NewManager(d.client(), opts...)
+}
+
+func TestMode_Validate(t *testing.T) {
+	tests := []struct {
+		want error
+		name string
+		mode Mode
+	}{
+		{name: "wifi", mode: ModeWiFi},
+		{name: "wifi matter", mode: ModeWiFiMatter},
+		{name: "wifi zigbee", mode: ModeWiFiZigbee},
+		{name: "zigbee", mode: ModeZigbee},
+		{name: "matter and zigbee", mode: Mode{WiFi: true, Matter: true, Zigbee: true}, want: ErrInvalidMode},
+		{name: "matter without wifi", mode: Mode{Matter: true, BLE: true}, want: ErrInvalidMode},
+		{name: "ble only", mode: Mode{BLE: true}, want: ErrInvalidMode},
+		{name: "none", mode: Mode{}, want: ErrInvalidMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mode.Validate(nil); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMode_String(t *testing.T) {
+	tests := []struct {
+		want string
+		mode Mode
+	}{
+		{"wifi+ble", ModeWiFi},
+		{"wifi+ble+matter", ModeWiFiMatter},
+		{"ble+zigbee", ModeZigbee},
+		{"none", Mode{}},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestManager_Current(t *testing.T) {
+	d := newFakeDevice(ModeWiFiMatter)
+	got, err := newTestManager(d).Current(context.Background())
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if got != ModeWiFiMatter {
+		t.Errorf("Current() = %s, want %s", got, ModeWiFiMatter)
+	}
+}
+
+func TestManager_SetMode_Matter(t *testing.T) {
+	d := newFakeDevice(ModeWiFiZigbee)
+	d.zigbeeState = "joined"
+
+	var progress []float64
+	m := newTestManager(d, WithProgress(func(_ string, p float64) {
+		progress = append(progress, p)
+	}))
+	result, err := m.SetMode(context.Background(), ModeWiFiMatter)
+	if err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	if d.mode != ModeWiFiMatter {
+		t.Errorf("device mode = %s, want %s", d.mode, ModeWiFiMatter)
+	}
+	want := []string{"disable zigbee", "enable matter", "wait for matter"}
+	if !reflect.DeepEqual(result.Steps, want) {
+		t.Errorf("Steps = %v, want %v", result.Steps, want)
+	}
+	if result.From != ModeWiFiZigbee || result.RolledBack || result.Unreachable || result.Backup == nil {
+		t.Errorf("Result = %+v", result)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 1.0 {
+		t.Errorf("progress = %v, want to end at 1", progress)
+	}
+}
+
+func TestManager_SetMode_ZigbeeOnly(t *testing.T) {
+	d := newFakeDevice(ModeWiFiMatter)
+	result, err := newTestManager(d).SetMode(context.Background(), ModeZigbee)
+	if err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	if d.mode != ModeZigbee {
+		t.Errorf("device mode = %s, want %s", d.mode, ModeZigbee)
+	}
+	want := []string{"disable matter", "enable zigbee", "join zigbee network", "disable wifi"}
+	if !reflect.DeepEqual(result.Steps, want) {
+		t.Errorf("Steps = %v, want %v", result.Steps, want)
+	}
+	if !d.steerStarted || result.Network == nil || result.Network.Channel != 15 {
+		t.Errorf("Network = %+v, steering = %v", result.Network, d.steerStarted)
+	}
+	if !result.Unreachable {
+		t.Error("Unreachable = false, want true")
+	}
+}
+
+func TestManager_SetMode_WiFiNoResponse(t *testing.T) {
+	d := newFakeDevice(ModeWiFiZigbee)
+	d.zigbeeState = "joined"
+	d.fail["Wifi.SetConfig"] = errors.New("connection reset")
+
+	result, err := newTestManager(d).SetMode(context.Background(), ModeZigbee)
+	if err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	if d.steerStarted {
+		t.Error("started steering although already joined")
+	}
+	if len(result.Warnings) != 1 || !result.Unreachable {
+		t.Errorf("Result = %+v", result)
+	}
+}
+
+func TestManager_SetMode_JoinTimeout(t *testing.T) {
+	d := newFakeDevice(ModeWiFiMatter)
+	d.joinOnSteer = false
+
+	result, err := newTestManager(d).SetMode(context.Background(), ModeZigbee)
+	if !errors.Is(err, ErrJoinTimeout) {
+		t.Fatalf("SetMode() error = %v, want ErrJoinTimeout", err)
+	}
+	if !result.RolledBack || result.RollbackError != nil {
+		t.Errorf("RolledBack = %v, RollbackError = %v", result.RolledBack, result.RollbackError)
+	}
+	if d.mode != ModeWiFiMatter {
+		t.Errorf("device mode = %s, want restored %s", d.mode, ModeWiFiMatter)
+	}
+}
+
+func TestManager_SetMode_StepFailure(t *testing.T) {
+	d := newFakeDevice(ModeWiFi)
+	d.fail["Matter.SetConfig"] = errors.New("busy")
+
+	result, err := newTestManager(d).SetMode(context.Background(), ModeWiFiMatter)
+	if err == nil || !result.RolledBack {
+		t.Fatalf("SetMode() = %+v, %v, want rolled back error", result, err)
+	}
+	if d.mode != ModeWiFi {
+		t.Errorf("device mode = %s, want %s", d.mode, ModeWiFi)
+	}
+}
+
+func TestManager_SetMode_Invalid(t *testing.T) {
+	tests := []struct {
+		want  error
+		name  string
+		model string
+		mode  Mode
+	}{
+		{name: "invalid", model: "S4SW-001X16EU", mode: Mode{BLE: true}, want: ErrInvalidMode},
+		{name: "unsupported", model: "S3SW-001X16EU", mode: ModeZigbee, want: ErrUnsupportedMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDevice(ModeWiFi)
+			d.model = tt.model
+			if _, err := newTestManager(d).SetMode(context.Background(), tt.mode); !errors.Is(err, tt.want) {
+				t.Fatalf("SetMode() error = %v, want %v", err, tt.want)
+			}
+			if d.called("Wifi.GetConfig") {
+				t.Error("backed up configuration for an invalid mode")
+			}
+		})
+	}
+}
+
+func TestManager_SetMode_InProgress(t *testing.T) {
+	d := newFakeDevice(ModeWiFi)
+	m := newTestManager(d)
+	m.inProgress = true
+	if _, err := m.SetMode(context.Background(), ModeWiFiMatter); !errors.Is(err, ErrChangeInProgress) {
+		t.Errorf("SetMode() error = %v, want ErrChangeInProgress", err)
+	}
+}
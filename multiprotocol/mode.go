@@ -0,0 +1,102 @@
+package multiprotocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tj-smith47/shelly-go/profiles"
+)
+
+// Errors returned by the mode manager.
+var (
+	// ErrInvalidMode indicates a combination of protocols the device
+	// can't run, or one that would leave it unreachable.
+	ErrInvalidMode = errors.New("invalid protocol mode")
+
+	// ErrUnsupportedMode indicates the device's profile lacks a protocol
+	// of the mode.
+	ErrUnsupportedMode = errors.New("protocol mode not supported by device")
+
+	// ErrChangeInProgress indicates another mode change is running.
+	ErrChangeInProgress = errors.New("mode change already in progress")
+
+	// ErrVerifyFailed indicates the device didn't reach the requested mode.
+	ErrVerifyFailed = errors.New("mode verification failed")
+
+	// ErrJoinTimeout indicates the device didn't join a Zigbee network in
+	// time.
+	ErrJoinTimeout = errors.New("timeout waiting for Zigbee network")
+
+	// ErrMatterTimeout indicates Matter didn't become ready in time.
+	ErrMatterTimeout = errors.New("timeout waiting for Matter")
+)
+
+// Mode is the set of radio protocols enabled on a device.
+type Mode struct {
+	// WiFi enables the WiFi station and access point, and with them the
+	// local RPC API.
+	WiFi bool `json:"wifi"`
+
+	// BLE enables Bluetooth.
+	BLE bool `json:"ble"`
+
+	// Matter enables Matter over WiFi.
+	Matter bool `json:"matter"`
+
+	// Zigbee enables Zigbee and joins a network.
+	Zigbee bool `json:"zigbee"`
+}
+
+// Common modes. Modes without WiFi keep BLE enabled as a recovery path.
+var (
+	ModeWiFi       = Mode{WiFi: true, BLE: true}
+	ModeWiFiMatter = Mode{WiFi: true, BLE: true, Matter: true}
+	ModeWiFiZigbee = Mode{WiFi: true, BLE: true, Zigbee: true}
+	ModeZigbee     = Mode{BLE: true, Zigbee: true}
+)
+
+// String returns the enabled protocols joined by "+", e.g.
+// "wifi+ble+matter", or "none".
+func (m Mode) String() string {
+	var parts []string
+	for _, p := range []struct {
+		name string
+		on   bool
+	}{{"wifi", m.WiFi}, {"ble", m.BLE}, {"matter", m.Matter}, {"zigbee", m.Zigbee}} {
+		if p.on {
+			parts = append(parts, p.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "+")
+}
+
+// Validate checks that the mode is consistent: Matter runs over WiFi and
+// shares the radio with Zigbee, and a device needs WiFi or Zigbee to stay
+// reachable. With a profile, it also checks the device supports each
+// protocol.
+func (m Mode) Validate(p *profiles.Profile) error {
+	switch {
+	case m.Matter && m.Zigbee:
+		return fmt.Errorf("%w: Matter and Zigbee are mutually exclusive", ErrInvalidMode)
+	case m.Matter && !m.WiFi:
+		return fmt.Errorf("%w: Matter requires WiFi", ErrInvalidMode)
+	case !m.WiFi && !m.Zigbee:
+		return fmt.Errorf("%w: device would be unreachable without WiFi or Zigbee", ErrInvalidMode)
+	}
+	if p == nil {
+		return nil
+	}
+	switch {
+	case m.Matter && !p.Protocols.Matter:
+		return fmt.Errorf("%w: %s has no Matter", ErrUnsupportedMode, p.Model)
+	case m.Zigbee && !p.Protocols.Zigbee:
+		return fmt.Errorf("%w: %s has no Zigbee", ErrUnsupportedMode, p.Model)
+	case m.BLE && !p.Protocols.BLE:
+		return fmt.Errorf("%w: %s has no BLE", ErrUnsupportedMode, p.Model)
+	}
+	return nil
+}
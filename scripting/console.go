@@ -0,0 +1,242 @@
+package scripting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+// DefaultConsoleBuffer is the default Console channel capacity.
+const DefaultConsoleBuffer = 256
+
+// debugScriptPattern extracts a script ID from a debug log line.
+var debugScriptPattern = regexp.MustCompile(`(?i)\bscript[ _:#]?(\d+)\b`)
+
+// ConsoleOption configures a Console.
+type ConsoleOption func(*Console)
+
+// WithConsoleBuffer sets the event channel capacity.
+func WithConsoleBuffer(size int) ConsoleOption {
+	return func(c *Console) {
+		if size > 0 {
+			c.buffer = size
+		}
+	}
+}
+
+// WithScriptFilter only emits output for the given script IDs.
+//
+// Debug log lines that cannot be attributed to a script are dropped when
+// a filter is set.
+func WithScriptFilter(ids ...int) ConsoleOption {
+	return func(c *Console) {
+		c.filter = make(map[int]bool, len(ids))
+		for _, id := range ids {
+			c.filter[id] = true
+		}
+	}
+}
+
+// Console streams script output from a device into a channel.
+//
+// Output is collected from:
+//   - NotifyEvent notifications whose component is "script:<id>", which
+//     covers Shelly.emitEvent calls and runtime error events
+//   - NotifyStatus notifications reporting script errors (e.g. "crashed")
+//   - Debug log lines passed to ReadDebugLog or WriteDebugLine
+//
+// Events are delivered without blocking; if the consumer falls behind and
+// the buffer fills up, new events are dropped and counted in Dropped.
+type Console struct {
+	client   *rpc.Client
+	events   chan *events.ScriptEvent
+	filter   map[int]bool
+	deviceID string
+	buffer   int
+	dropped  int
+	mu       sync.Mutex
+	closed   bool
+}
+
+// NewConsole creates a Console and subscribes to the client's notifications.
+//
+// The client must use a transport that delivers notifications (WebSocket or
+// MQTT) for NotifyEvent output to arrive; debug log lines can be fed in
+// with any transport.
+func NewConsole(client *rpc.Client, deviceID string, opts ...ConsoleOption) *Console {
+	c := &Console{
+		client:   client,
+		deviceID: deviceID,
+		buffer:   DefaultConsoleBuffer,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.events = make(chan *events.ScriptEvent, c.buffer)
+
+	if client != nil {
+		client.OnNotificationMethod("NotifyEvent", c.handleNotifyEvent)
+		client.OnNotificationMethod("NotifyStatus", c.handleNotifyStatus)
+	}
+	return c
+}
+
+// Events returns the channel of script output. It is closed by Close.
+func (c *Console) Events() <-chan *events.ScriptEvent {
+	return c.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (c *Console) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Close stops delivering events and closes the Events channel.
+//
+// Notification handlers stay registered on the client but become no-ops.
+func (c *Console) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.events)
+}
+
+// ReadDebugLog reads newline-delimited debug log lines from r until EOF,
+// an error, or context cancellation.
+//
+// Lines can be plain text (UDP debug log) or the JSON objects sent on the
+// ws://<device>/debug/log WebSocket.
+func (c *Console) ReadDebugLog(ctx context.Context, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.WriteDebugLine(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// WriteDebugLine feeds a single debug log line into the console.
+func (c *Console) WriteDebugLine(line []byte) {
+	text := strings.TrimSpace(string(line))
+	if text == "" {
+		return
+	}
+
+	var entry struct {
+		Data string  `json:"data"`
+		TS   float64 `json:"ts"`
+	}
+	if strings.HasPrefix(text, "{") && json.Unmarshal([]byte(text), &entry) == nil {
+		text = strings.TrimSpace(entry.Data)
+		if text == "" {
+			return
+		}
+	}
+
+	id := -1
+	if m := debugScriptPattern.FindStringSubmatch(text); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			id = n
+		}
+	}
+	c.emit(id, text, events.EventSourceLocal)
+}
+
+// handleNotifyEvent converts script component events into console output.
+func (c *Console) handleNotifyEvent(params json.RawMessage) {
+	var p struct {
+		Events []struct {
+			Component string          `json:"component"`
+			Event     string          `json:"event"`
+			Data      json.RawMessage `json:"data,omitempty"`
+			ID        int             `json:"id"`
+		} `json:"events"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+
+	for _, e := range p.Events {
+		id, ok := scriptComponentID(e.Component)
+		if !ok {
+			continue
+		}
+		output := e.Event
+		if len(e.Data) > 0 && string(e.Data) != "null" {
+			output = fmt.Sprintf("%s %s", e.Event, string(e.Data))
+		}
+		c.emit(id, output, events.EventSourceWebSocket)
+	}
+}
+
+// handleNotifyStatus reports script errors from status notifications.
+func (c *Console) handleNotifyStatus(params json.RawMessage) {
+	var p map[string]json.RawMessage
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+
+	for key, raw := range p {
+		id, ok := scriptComponentID(key)
+		if !ok {
+			continue
+		}
+		var status struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(raw, &status) != nil || len(status.Errors) == 0 {
+			continue
+		}
+		c.emit(id, "error: "+strings.Join(status.Errors, ", "), events.EventSourceWebSocket)
+	}
+}
+
+// emit delivers an event without blocking.
+func (c *Console) emit(scriptID int, output string, source events.EventSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	if c.filter != nil && !c.filter[scriptID] {
+		return
+	}
+
+	evt := events.NewScriptEvent(c.deviceID, scriptID, output).WithSource(source)
+	select {
+	case c.events <- evt:
+	default:
+		c.dropped++
+	}
+}
+
+// scriptComponentID parses "script:<id>" component keys.
+func scriptComponentID(component string) (int, bool) {
+	rest, ok := strings.CutPrefix(component, "script:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,155 @@
+package scripting
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+func newTestConsole(t *testing.T, opts ...ConsoleOption) (*Console, *rpc.Client) {
+	t.Helper()
+	client := rpc.NewClient(&mockTransport{})
+	console := NewConsole(client, "shellyplus1-test", opts...)
+	t.Cleanup(console.Close)
+	return console, client
+}
+
+func route(t *testing.T, client *rpc.Client, data string) {
+	t.Helper()
+	if err := client.NotificationRouter().RouteRaw([]byte(data)); err != nil {
+		t.Fatalf("RouteRaw() error = %v", err)
+	}
+}
+
+func next(t *testing.T, c *Console) *events.ScriptEvent {
+	t.Helper()
+	select {
+	case evt := <-c.Events():
+		return evt
+	default:
+		t.Fatal("expected an event")
+		return nil
+	}
+}
+
+func TestConsole_NotifyEvent(t *testing.T) {
+	console, client := newTestConsole(t)
+
+	route(t, client, `{"src":"shellyplus1-test","method":"NotifyEvent","params":{"ts":1.0,"events":[
+		{"component":"script:2","id":2,"event":"temp_alert","data":{"t":31.5}},
+		{"component":"input:0","id":0,"event":"single_push"}
+	]}}`)
+
+	evt := next(t, console)
+	if evt.ScriptID != 2 {
+		t.Errorf("ScriptID = %d, want 2", evt.ScriptID)
+	}
+	if evt.Output != `temp_alert {"t":31.5}` {
+		t.Errorf("Output = %q", evt.Output)
+	}
+	if evt.DeviceID() != "shellyplus1-test" {
+		t.Errorf("DeviceID() = %q", evt.DeviceID())
+	}
+	if evt.Source() != events.EventSourceWebSocket {
+		t.Errorf("Source() = %q", evt.Source())
+	}
+
+	select {
+	case evt := <-console.Events():
+		t.Errorf("unexpected event for non-script component: %+v", evt)
+	default:
+	}
+}
+
+func TestConsole_NotifyStatusErrors(t *testing.T) {
+	console, client := newTestConsole(t)
+
+	route(t, client, `{"method":"NotifyStatus","params":{"ts":1.0,"script:1":{"id":1,"running":false,"errors":["crashed"]}}}`)
+
+	evt := next(t, console)
+	if evt.ScriptID != 1 || evt.Output != "error: crashed" {
+		t.Errorf("unexpected event: id=%d output=%q", evt.ScriptID, evt.Output)
+	}
+}
+
+func TestConsole_DebugLog(t *testing.T) {
+	console, _ := newTestConsole(t)
+
+	log := strings.Join([]string{
+		`{"ts":1700000000.1,"level":2,"data":"script_3 hello from loop","fd":1}`,
+		`shelly_notification:163 Status change of switch:0`,
+		``,
+		`{"ts":1700000000.2,"level":2,"data":"","fd":1}`,
+	}, "\n")
+
+	if err := console.ReadDebugLog(context.Background(), strings.NewReader(log)); err != nil {
+		t.Fatalf("ReadDebugLog() error = %v", err)
+	}
+
+	first := next(t, console)
+	if first.ScriptID != 3 || first.Output != "script_3 hello from loop" {
+		t.Errorf("unexpected first event: id=%d output=%q", first.ScriptID, first.Output)
+	}
+	second := next(t, console)
+	if second.ScriptID != -1 {
+		t.Errorf("unattributed line ScriptID = %d, want -1", second.ScriptID)
+	}
+
+	select {
+	case evt := <-console.Events():
+		t.Errorf("unexpected event for empty line: %+v", evt)
+	default:
+	}
+}
+
+func TestConsole_Filter(t *testing.T) {
+	console, _ := newTestConsole(t, WithScriptFilter(1))
+
+	console.WriteDebugLine([]byte("script:2 ignored"))
+	console.WriteDebugLine([]byte("no script here"))
+	console.WriteDebugLine([]byte("script:1 kept"))
+
+	evt := next(t, console)
+	if evt.Output != "script:1 kept" {
+		t.Errorf("Output = %q", evt.Output)
+	}
+}
+
+func TestConsole_DropsWhenFull(t *testing.T) {
+	console, _ := newTestConsole(t, WithConsoleBuffer(1))
+
+	console.WriteDebugLine([]byte("one"))
+	console.WriteDebugLine([]byte("two"))
+
+	if console.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", console.Dropped())
+	}
+}
+
+func TestConsole_Close(t *testing.T) {
+	client := rpc.NewClient(&mockTransport{})
+	console := NewConsole(client, "dev")
+	console.Close()
+	console.Close()
+
+	// Output after close is ignored and must not panic.
+	console.WriteDebugLine([]byte("script:1 late"))
+	route(t, client, `{"method":"NotifyEvent","params":{"events":[{"component":"script:1","event":"x"}]}}`)
+
+	if _, ok := <-console.Events(); ok {
+		t.Error("Events() should be closed")
+	}
+}
+
+func TestConsole_ReadDebugLogCanceled(t *testing.T) {
+	console, _ := newTestConsole(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := console.ReadDebugLog(ctx, strings.NewReader("a\nb\n")); err == nil {
+		t.Error("expected context error")
+	}
+}
@@ -0,0 +1,64 @@
+// Package scripting provides deployment and monitoring tools for Gen2+ device scripts.
+//
+// The Script component exposes the raw RPC surface (PutCode, Start, Stop, ...).
+// This package builds on it to make scripts manageable as source files:
+//   - Sync a local directory of .js files to a device
+//   - Chunk large uploads to fit the device's per-call limit
+//   - Track deployed versions and content hashes in KVS
+//   - Restart only the scripts that actually changed
+//   - Report memory usage and errors from Script.GetStatus
+//   - Stream script output into a channel for a live console
+//
+// # Deploying Scripts
+//
+//	client := rpc.NewClient(transport)
+//	mgr := scripting.New(client)
+//
+//	// Sync every .js file in ./scripts; the file name (without .js)
+//	// becomes the script name on the device.
+//	result, err := mgr.SyncDir(ctx, "./scripts", nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, s := range result.Scripts {
+//	    fmt.Printf("%s: %s (v%d)\n", s.Name, s.Action, s.Version)
+//	}
+//
+// Scripts whose content hash matches the version recorded in KVS are left
+// untouched, so running SyncDir repeatedly is cheap and does not interrupt
+// running scripts.
+//
+// # Static Checks
+//
+// The lint subpackage checks scripts offline for syntax the device runtime
+// does not support, size limits and unknown Shelly.call methods. WithLint
+// runs it before every upload so bad scripts never reach the device:
+//
+//	mgr := scripting.New(client, scripting.WithLint(&lint.Options{
+//	    Limits: &profile.Limits,
+//	}))
+//
+// # Versions
+//
+// Each deployed script has a KVS entry (by default "scriptver:<name>")
+// holding a version counter and a short content hash:
+//
+//	v, err := mgr.Version(ctx, "thermostat")
+//	fmt.Printf("thermostat v%d (%s)\n", v.Version, v.Hash)
+//
+// # Live Console
+//
+// Console collects script output from NotifyEvent and NotifyStatus
+// notifications (for example Shelly.emitEvent calls and crash reports) and,
+// optionally, from the device debug log:
+//
+//	console := scripting.NewConsole(client, "shellyplus1-aabbcc")
+//	defer console.Close()
+//
+//	// Debug log lines from ws://<device>/debug/log or UDP can be fed in too.
+//	go console.ReadDebugLog(ctx, logStream)
+//
+//	for evt := range console.Events() {
+//	    fmt.Printf("[script %d] %s\n", evt.ScriptID, evt.Output)
+//	}
+package scripting
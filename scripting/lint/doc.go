@@ -0,0 +1,39 @@
+// Package lint statically checks Shelly scripts before they are uploaded.
+//
+// Shelly Gen2+ devices run scripts on a small Espruino-derived JavaScript
+// engine. Code that uses a language feature the engine does not implement,
+// or that is too large for the device, is only rejected when Script.Start
+// runs on the device. This package finds those problems offline:
+//   - Unsupported syntax: async/await, Promise, generators, regular
+//     expressions, modules, optional chaining, nullish coalescing,
+//     getters/setters, labeled statements, with, eval
+//   - Browser/Node APIs that do not exist (setTimeout, Map, Symbol, ...)
+//   - Script size against profiles.Limits.MaxScriptSize
+//   - Shelly.call methods missing from the device's Shelly.ListMethods
+//   - Unbalanced brackets and unterminated strings or comments
+//
+// # Usage
+//
+//	methods, err := lint.MethodsFromDevice(ctx, client)
+//	if err != nil {
+//	    return err
+//	}
+//
+//	result := lint.Check(code, &lint.Options{
+//	    Limits:  &profile.Limits,
+//	    Methods: methods,
+//	})
+//	for _, issue := range result.Issues {
+//	    fmt.Println(issue)
+//	}
+//	if result.HasErrors() {
+//	    return errors.New(result.Summary())
+//	}
+//
+// Rules can be turned off individually:
+//
+//	result := lint.Check(code, &lint.Options{Disable: []string{lint.RuleClass}})
+//
+// The scripting.Manager runs Check before every upload when configured
+// with scripting.WithLint, and tools/scriptlint wraps it for CI.
+package lint
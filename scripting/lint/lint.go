@@ -0,0 +1,403 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tj-smith47/shelly-go/gen2"
+	"github.com/tj-smith47/shelly-go/profiles"
+	"github.com/tj-smith47/shelly-go/rpc"
+)
+
+// Severity is the importance of an Issue.
+type Severity int
+
+const (
+	// SeverityWarning marks constructs that may work but are risky.
+	SeverityWarning Severity = iota
+
+	// SeverityError marks constructs that will fail on the device.
+	SeverityError
+)
+
+// String returns the severity name.
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Rule IDs reported in Issue.Rule.
+const (
+	RuleSyntax           = "syntax"
+	RuleSize             = "size"
+	RuleUnknownMethod    = "unknown-method"
+	RuleAsync            = "async"
+	RulePromise          = "promise"
+	RuleGenerator        = "generator"
+	RuleClass            = "class"
+	RuleRegex            = "regex"
+	RuleModule           = "module"
+	RuleTimers           = "timers"
+	RuleOptionalChaining = "optional-chaining"
+	RuleNullish          = "nullish-coalescing"
+	RuleEval             = "eval"
+	RuleAccessor         = "accessor"
+	RuleWith             = "with"
+	RuleLabel            = "label"
+	RuleBuiltin          = "builtin"
+	RuleSpread           = "spread"
+)
+
+// sizeWarningRatio is the fraction of MaxScriptSize that triggers a warning.
+const sizeWarningRatio = 0.9
+
+// Issue is a single finding.
+type Issue struct {
+	// Rule is the rule ID (one of the Rule* constants).
+	Rule string `json:"rule"`
+
+	// Message describes the problem.
+	Message string `json:"message"`
+
+	// Severity is the issue severity.
+	Severity Severity `json:"severity"`
+
+	// Line is the 1-based line number (0 for whole-file issues).
+	Line int `json:"line"`
+
+	// Column is the 1-based column number (0 for whole-file issues).
+	Column int `json:"column"`
+}
+
+// String formats the issue as "line:col: severity: message (rule)".
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", i.Line, i.Column, i.Severity, i.Message, i.Rule)
+}
+
+// Call is a Shelly.call invocation found in the script.
+type Call struct {
+	// Method is the RPC method name, empty if not a string literal.
+	Method string `json:"method"`
+
+	// Line is the 1-based line number.
+	Line int `json:"line"`
+
+	// Column is the 1-based column number.
+	Column int `json:"column"`
+}
+
+// Options configures a check.
+type Options struct {
+	// Limits supplies MaxScriptSize; nil skips the size check.
+	Limits *profiles.Limits
+
+	// Methods is the device's Shelly.ListMethods result. When set, every
+	// Shelly.call with a literal method name must appear in it.
+	Methods []string
+
+	// Disable lists rule IDs to skip.
+	Disable []string
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	// Issues are sorted by position.
+	Issues []Issue `json:"issues"`
+
+	// Calls lists every Shelly.call found.
+	Calls []Call `json:"calls"`
+
+	// Size is the script size in bytes.
+	Size int `json:"size"`
+}
+
+// HasErrors returns true if any issue has SeverityError.
+func (r *Result) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// Errors returns issues with SeverityError.
+func (r *Result) Errors() []Issue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns issues with SeverityWarning.
+func (r *Result) Warnings() []Issue {
+	return r.filter(SeverityWarning)
+}
+
+func (r *Result) filter(sev Severity) []Issue {
+	var out []Issue
+	for _, i := range r.Issues {
+		if i.Severity == sev {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Summary returns a one-line description of all errors.
+func (r *Result) Summary() string {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return "no errors"
+	}
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.String()
+	}
+	return fmt.Sprintf("%d script error(s): %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// unsupportedBuiltins are globals that do not exist in the Shelly runtime.
+var unsupportedBuiltins = map[string]bool{
+	"Map": true, "Set": true, "WeakMap": true, "WeakSet": true,
+	"Symbol": true, "Proxy": true, "Reflect": true,
+}
+
+// browserTimers are the standard timer functions replaced by Timer.set/clear.
+var browserTimers = map[string]bool{
+	"setTimeout": true, "setInterval": true, "clearTimeout": true, "clearInterval": true,
+}
+
+// loopKeywords may follow a statement label.
+var loopKeywords = map[string]bool{
+	"for": true, "while": true, "do": true,
+}
+
+// Check analyzes script source for constructs the Shelly runtime does not
+// support, size limit violations and calls to unavailable RPC methods.
+//
+// Check never executes the script. A nil opts runs only the language rules.
+func Check(code string, opts *Options) *Result {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	c := &checker{
+		disabled: make(map[string]bool, len(opts.Disable)),
+		result:   &Result{Size: len(code)},
+	}
+	for _, rule := range opts.Disable {
+		c.disabled[rule] = true
+	}
+
+	tokens, errs := tokenize(code)
+	for _, e := range errs {
+		c.add(RuleSyntax, SeverityError, e.line, e.col, e.msg)
+	}
+	c.tokens = tokens
+
+	c.checkSize(opts.Limits)
+	c.checkBrackets()
+	c.checkTokens()
+	c.checkMethods(opts.Methods)
+
+	sort.SliceStable(c.result.Issues, func(i, j int) bool {
+		a, b := c.result.Issues[i], c.result.Issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.result
+}
+
+// MethodsFromDevice fetches the RPC method list used for Options.Methods.
+func MethodsFromDevice(ctx context.Context, client *rpc.Client) ([]string, error) {
+	return gen2.NewShelly(client).ListMethods(ctx)
+}
+
+type checker struct {
+	disabled map[string]bool
+	result   *Result
+	tokens   []token
+}
+
+func (c *checker) add(rule string, sev Severity, line, col int, msg string) {
+	if c.disabled[rule] {
+		return
+	}
+	c.result.Issues = append(c.result.Issues, Issue{
+		Rule: rule, Severity: sev, Line: line, Column: col, Message: msg,
+	})
+}
+
+// at returns the token at index i, or an empty token if out of range.
+func (c *checker) at(i int) token {
+	if i < 0 || i >= len(c.tokens) {
+		return token{kind: -1}
+	}
+	return c.tokens[i]
+}
+
+func (c *checker) isPunct(i int, text string) bool {
+	t := c.at(i)
+	return t.kind == tokPunct && t.text == text
+}
+
+func (c *checker) isIdent(i int, text string) bool {
+	t := c.at(i)
+	return t.kind == tokIdent && t.text == text
+}
+
+func (c *checker) checkSize(limits *profiles.Limits) {
+	if limits == nil || limits.MaxScriptSize <= 0 {
+		return
+	}
+	size, limit := c.result.Size, limits.MaxScriptSize
+	switch {
+	case size > limit:
+		c.add(RuleSize, SeverityError, 0, 0,
+			fmt.Sprintf("script is %d bytes, device limit is %d", size, limit))
+	case float64(size) > float64(limit)*sizeWarningRatio:
+		c.add(RuleSize, SeverityWarning, 0, 0,
+			fmt.Sprintf("script is %d bytes, close to device limit of %d", size, limit))
+	}
+}
+
+func (c *checker) checkBrackets() {
+	pairs := map[string]string{")": "(", "]": "[", "}": "{"}
+	var stack []token
+	for _, t := range c.tokens {
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			stack = append(stack, t)
+		case ")", "]", "}":
+			if len(stack) == 0 || stack[len(stack)-1].text != pairs[t.text] {
+				c.add(RuleSyntax, SeverityError, t.line, t.col, fmt.Sprintf("unexpected %q", t.text))
+				return
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		t := stack[len(stack)-1]
+		c.add(RuleSyntax, SeverityError, t.line, t.col, fmt.Sprintf("unclosed %q", t.text))
+	}
+}
+
+//nolint:gocyclo // One flat switch over token-level rules reads best
+func (c *checker) checkTokens() {
+	for i, t := range c.tokens {
+		afterDot := c.isPunct(i-1, ".") || c.isPunct(i-1, "?.")
+
+		switch t.kind {
+		case tokRegex:
+			c.add(RuleRegex, SeverityError, t.line, t.col, "regular expressions are not supported")
+			continue
+		case tokPunct:
+			switch t.text {
+			case "?.":
+				c.add(RuleOptionalChaining, SeverityError, t.line, t.col, "optional chaining (?.) is not supported")
+			case "??":
+				c.add(RuleNullish, SeverityError, t.line, t.col, "nullish coalescing (??) is not supported")
+			case "...":
+				c.add(RuleSpread, SeverityWarning, t.line, t.col, "spread/rest syntax has limited support")
+			}
+			continue
+		case tokIdent:
+		default:
+			continue
+		}
+
+		if afterDot {
+			continue
+		}
+
+		switch {
+		case t.text == "async" && (c.isIdent(i+1, "function") || c.isPunct(i+1, "(") || c.at(i+1).kind == tokIdent):
+			c.add(RuleAsync, SeverityError, t.line, t.col, "async functions are not supported")
+		case t.text == "await":
+			c.add(RuleAsync, SeverityError, t.line, t.col, "await is not supported")
+		case t.text == "Promise":
+			c.add(RulePromise, SeverityError, t.line, t.col, "Promise is not available; use callbacks")
+		case t.text == "function" && c.isPunct(i+1, "*"):
+			c.add(RuleGenerator, SeverityError, t.line, t.col, "generator functions are not supported")
+		case t.text == "yield":
+			c.add(RuleGenerator, SeverityError, t.line, t.col, "yield is not supported")
+		case t.text == "class" && c.at(i+1).kind == tokIdent:
+			c.add(RuleClass, SeverityWarning, t.line, t.col, "class syntax has limited support; prefer constructor functions")
+		case (t.text == "import" || t.text == "export") && !c.isPunct(i+1, ":"):
+			c.add(RuleModule, SeverityError, t.line, t.col, "modules are not supported; scripts must be self-contained")
+		case t.text == "require" && c.isPunct(i+1, "("):
+			c.add(RuleModule, SeverityError, t.line, t.col, "require() is not supported; scripts must be self-contained")
+		case browserTimers[t.text] && c.isPunct(i+1, "("):
+			c.add(RuleTimers, SeverityError, t.line, t.col, t.text+" is not available; use Timer.set/Timer.clear")
+		case t.text == "eval" && c.isPunct(i+1, "("):
+			c.add(RuleEval, SeverityError, t.line, t.col, "eval is not supported")
+		case t.text == "new" && c.isIdent(i+1, "Function"):
+			c.add(RuleEval, SeverityError, t.line, t.col, "new Function is not supported")
+		case (t.text == "get" || t.text == "set") && c.at(i+1).kind == tokIdent && c.isPunct(i+2, "("):
+			c.add(RuleAccessor, SeverityError, t.line, t.col, "getters and setters are not supported")
+		case t.text == "with" && c.isPunct(i+1, "("):
+			c.add(RuleWith, SeverityError, t.line, t.col, "with statements are not supported")
+		case c.isLabel(i) && c.at(i+2).kind == tokIdent && loopKeywords[c.at(i+2).text]:
+			c.add(RuleLabel, SeverityError, t.line, t.col, "labeled statements are not supported")
+		case unsupportedBuiltins[t.text] && (c.isPunct(i+1, "(") || c.isPunct(i+1, ".") || c.isIdent(i-1, "new")):
+			c.add(RuleBuiltin, SeverityWarning, t.line, t.col, t.text+" is not available in the Shelly runtime")
+		}
+	}
+}
+
+// isLabel reports whether token i is a statement label: an identifier
+// followed by a colon at the start of a statement. Switch clauses such as
+// default: and case x: are not labels.
+func (c *checker) isLabel(i int) bool {
+	t := c.at(i)
+	if t.kind != tokIdent || t.text == "default" || t.text == "case" || c.isIdent(i-1, "case") {
+		return false
+	}
+	return c.isPunct(i+1, ":") && c.statementStart(i)
+}
+
+// statementStart reports whether token i begins a statement.
+func (c *checker) statementStart(i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := c.at(i - 1)
+	return prev.kind == tokPunct && (prev.text == ";" || prev.text == "{" || prev.text == "}")
+}
+
+func (c *checker) checkMethods(methods []string) {
+	available := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		available[strings.ToLower(m)] = true
+	}
+
+	for i := range c.tokens {
+		if !c.isIdent(i, "Shelly") || !c.isPunct(i+1, ".") || !c.isIdent(i+2, "call") || !c.isPunct(i+3, "(") {
+			continue
+		}
+		pos := c.at(i)
+		call := Call{Line: pos.line, Column: pos.col}
+		if arg := c.at(i + 4); arg.kind == tokString {
+			if name, err := unquote(arg.text); err == nil {
+				call.Method = name
+			}
+		}
+		c.result.Calls = append(c.result.Calls, call)
+
+		if len(methods) > 0 && call.Method != "" && !available[strings.ToLower(call.Method)] {
+			c.add(RuleUnknownMethod, SeverityError, pos.line, pos.col,
+				fmt.Sprintf("method %q is not available on this device", call.Method))
+		}
+	}
+}
+
+// unquote decodes a single- or double-quoted JavaScript string literal.
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' {
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(s)
+}
@@ -0,0 +1,246 @@
+package lint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tj-smith47/shelly-go/profiles"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+func jsonrpcResponse(result string) (json.RawMessage, error) {
+	return json.RawMessage(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":%s}`, result)), nil
+}
+
+func rules(r *Result) []string {
+	out := make([]string, 0, len(r.Issues))
+	for _, i := range r.Issues {
+		out = append(out, i.Rule)
+	}
+	return out
+}
+
+func TestCheck_Rules(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []string
+	}{
+		{"clean", "let x = 1;\nTimer.set(1000, true, function () { print(x); });", nil},
+		{"async function", "async function f() {}", []string{RuleAsync}},
+		{"async arrow", "let f = async () => 1;", []string{RuleAsync}},
+		{"await", "function f() { await g(); }", []string{RuleAsync}},
+		{"promise", "new Promise(function (r) { r(); });", []string{RulePromise}},
+		{"generator", "function* gen() { yield 1; }", []string{RuleGenerator, RuleGenerator}},
+		{"class", "class Foo {}", []string{RuleClass}},
+		{"regex literal", "let re = /ab+c/g;", []string{RuleRegex}},
+		{"division is not regex", "let a = b / c / d;", nil},
+		{"import", "import x from 'y';", []string{RuleModule}},
+		{"require", "let m = require('mod');", []string{RuleModule}},
+		{"setTimeout", "setTimeout(f, 10);", []string{RuleTimers}},
+		{"optional chaining", "let v = a?.b;", []string{RuleOptionalChaining}},
+		{"ternary with decimal", "let v = a?.5:1;", nil},
+		{"nullish", "let v = a ?? 1;", []string{RuleNullish}},
+		{"eval", "eval('1');", []string{RuleEval}},
+		{"new Function", "let f = new Function('return 1');", []string{RuleEval}},
+		{"getter", "let o = { get value() { return 1; } };", []string{RuleAccessor}},
+		{"with", "with (obj) { x = 1; }", []string{RuleWith}},
+		{"label", "outer: for (;;) { break outer; }", []string{RuleLabel}},
+		{"object key is not label", "let o = { for: 1 };", nil},
+		{"default clause is not label", "switch (x) { default: for (;;) { break; } }", nil},
+		{"case clause is not label", "switch (x) { case y: while (f()) {} }", nil},
+		{"builtin", "let m = new Map();", []string{RuleBuiltin}},
+		{"spread", "f(...args);", []string{RuleSpread}},
+		{"property names are ignored", "obj.setTimeout(1); obj.eval(2); obj.await;", nil},
+		{"strings are ignored", `let s = "async await ?. ?? setTimeout(";`, nil},
+		{"comments are ignored", "// setTimeout(f)\n/* eval('x') */ let x = 1;", nil},
+		{"template literals are ignored", "let s = `eval(${a + \"?.\"})`;", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(Check(tt.code, nil))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("rules = %v, want %v (issues: %+v)", got, tt.want, Check(tt.code, nil).Issues)
+			}
+		})
+	}
+}
+
+func TestCheck_Syntax(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		line int
+	}{
+		{"unclosed brace", "function f() {\n  return 1;\n", 1},
+		{"unexpected paren", "let x = 1);", 1},
+		{"mismatched", "let a = [1, 2);", 1},
+		{"unterminated string", "let a = 'abc;\nlet b = 1;", 1},
+		{"unterminated comment", "let a = 1;\n/* open", 2},
+		{"unterminated template", "let a = `abc", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Check(tt.code, nil)
+			if !result.HasErrors() {
+				t.Fatal("expected syntax error")
+			}
+			issue := result.Errors()[0]
+			if issue.Rule != RuleSyntax {
+				t.Errorf("Rule = %s, want %s", issue.Rule, RuleSyntax)
+			}
+			if issue.Line != tt.line {
+				t.Errorf("Line = %d, want %d", issue.Line, tt.line)
+			}
+		})
+	}
+}
+
+func TestCheck_Position(t *testing.T) {
+	result := Check("let a = 1;\n  let b = a ?? 2;", nil)
+	if len(result.Issues) != 1 {
+		t.Fatalf("got %d issues, want 1", len(result.Issues))
+	}
+	issue := result.Issues[0]
+	if issue.Line != 2 || issue.Column != 13 {
+		t.Errorf("position = %d:%d, want 2:13", issue.Line, issue.Column)
+	}
+	want := "2:13: error: nullish coalescing (??) is not supported (nullish-coalescing)"
+	if issue.String() != want {
+		t.Errorf("String() = %q, want %q", issue.String(), want)
+	}
+}
+
+func TestCheck_Size(t *testing.T) {
+	limits := &profiles.Limits{MaxScriptSize: 100}
+
+	tests := []struct {
+		name string
+		size int
+		want []Severity
+	}{
+		{"small", 50, nil},
+		{"near limit", 95, []Severity{SeverityWarning}},
+		{"over limit", 101, []Severity{SeverityError}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := strings.Repeat(";", tt.size)
+			result := Check(code, &Options{Limits: limits})
+			if result.Size != tt.size {
+				t.Errorf("Size = %d, want %d", result.Size, tt.size)
+			}
+			if len(result.Issues) != len(tt.want) {
+				t.Fatalf("got %d issues, want %d", len(result.Issues), len(tt.want))
+			}
+			for i, sev := range tt.want {
+				if result.Issues[i].Rule != RuleSize || result.Issues[i].Severity != sev {
+					t.Errorf("issue %d = %+v", i, result.Issues[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_Methods(t *testing.T) {
+	code := `
+Shelly.call("Switch.Set", {id: 0, on: true});
+Shelly.call('switch.toggle', {id: 0});
+Shelly.call("Cover.Open", {id: 0}, function () {});
+Shelly.call(method, {});
+`
+	result := Check(code, &Options{Methods: []string{"Switch.Set", "Switch.Toggle"}})
+
+	if len(result.Calls) != 4 {
+		t.Fatalf("got %d calls, want 4", len(result.Calls))
+	}
+	if result.Calls[1].Method != "switch.toggle" || result.Calls[3].Method != "" {
+		t.Errorf("unexpected calls: %+v", result.Calls)
+	}
+
+	errs := result.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1: %+v", len(errs), errs)
+	}
+	if errs[0].Rule != RuleUnknownMethod || errs[0].Line != 4 || !strings.Contains(errs[0].Message, "Cover.Open") {
+		t.Errorf("unexpected error: %+v", errs[0])
+	}
+
+	// Without a method list nothing is flagged.
+	if Check(code, nil).HasErrors() {
+		t.Error("expected no errors without Methods")
+	}
+}
+
+func TestCheck_Disable(t *testing.T) {
+	code := "let v = a ?? 1; class Foo {}"
+	result := Check(code, &Options{Disable: []string{RuleNullish, RuleClass}})
+	if len(result.Issues) != 0 {
+		t.Errorf("expected disabled rules to be skipped, got %+v", result.Issues)
+	}
+}
+
+func TestResult(t *testing.T) {
+	result := Check("let v = a ?? 1; class Foo {}", nil)
+
+	if !result.HasErrors() {
+		t.Error("HasErrors() = false")
+	}
+	if len(result.Errors()) != 1 || len(result.Warnings()) != 1 {
+		t.Errorf("Errors()=%d Warnings()=%d, want 1/1", len(result.Errors()), len(result.Warnings()))
+	}
+	if !strings.HasPrefix(result.Summary(), "1 script error(s): ") {
+		t.Errorf("Summary() = %q", result.Summary())
+	}
+	if Check("let x = 1;", nil).Summary() != "no errors" {
+		t.Error("expected no errors summary")
+	}
+}
+
+func TestSeverity_String(t *testing.T) {
+	if SeverityError.String() != "error" || SeverityWarning.String() != "warning" {
+		t.Errorf("unexpected strings: %s, %s", SeverityError, SeverityWarning)
+	}
+}
+
+func TestMethodsFromDevice(t *testing.T) {
+	mt := &mockTransport{
+		callFunc: func(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+			if req.GetMethod() != "Shelly.ListMethods" {
+				t.Errorf("method = %s, want Shelly.ListMethods", req.GetMethod())
+			}
+			return jsonrpcResponse(`{"methods":["Switch.Set","Shelly.GetStatus"]}`)
+		},
+	}
+
+	methods, err := MethodsFromDevice(context.Background(), rpc.NewClient(mt))
+	if err != nil {
+		t.Fatalf("MethodsFromDevice() error = %v", err)
+	}
+	if len(methods) != 2 || methods[0] != "Switch.Set" {
+		t.Errorf("methods = %v", methods)
+	}
+}
@@ -0,0 +1,262 @@
+package lint
+
+import (
+	"strings"
+)
+
+// tokenKind classifies a lexical token.
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokNumber
+	tokString
+	tokTemplate
+	tokRegex
+	tokPunct
+)
+
+// token is a single lexical token with its source position.
+type token struct {
+	text string
+	kind tokenKind
+	line int
+	col  int
+}
+
+// syntaxError is a lexer error with its source position.
+type syntaxError struct {
+	msg  string
+	line int
+	col  int
+}
+
+// punctuators lists multi-character punctuators, longest first.
+var punctuators = []string{
+	">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "**", "<<", ">>",
+}
+
+// regexPrefixKeywords are keywords after which a '/' starts a regex literal.
+var regexPrefixKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true,
+}
+
+// lexer is a small JavaScript tokenizer.
+//
+// It understands enough of the language to skip comments, strings, template
+// literals and regex literals correctly so the rule checks never match
+// text inside them.
+type lexer struct {
+	src    string
+	tokens []token
+	errs   []syntaxError
+	pos    int
+	line   int
+	col    int
+}
+
+// tokenize splits src into tokens.
+func tokenize(src string) ([]token, []syntaxError) {
+	l := &lexer{src: src, line: 1, col: 1}
+	l.run()
+	return l.tokens, l.errs
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+offset]
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.pos < len(l.src); i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) emit(kind tokenKind, start, line, col int) {
+	l.tokens = append(l.tokens, token{kind: kind, text: l.src[start:l.pos], line: line, col: col})
+}
+
+func (l *lexer) fail(msg string, line, col int) {
+	l.errs = append(l.errs, syntaxError{msg: msg, line: line, col: col})
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.src) {
+		c := l.peek(0)
+		start, line, col := l.pos, l.line, l.col
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			l.advance(1)
+		case c == '/' && l.peek(1) == '/':
+			for l.pos < len(l.src) && l.peek(0) != '\n' {
+				l.advance(1)
+			}
+		case c == '/' && l.peek(1) == '*':
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				l.fail("unterminated comment", line, col)
+				l.advance(len(l.src) - l.pos)
+				continue
+			}
+			l.advance(end + 4)
+		case c == '"' || c == '\'':
+			l.scanString(c)
+			l.emit(tokString, start, line, col)
+		case c == '`':
+			l.scanTemplate()
+			l.emit(tokTemplate, start, line, col)
+		case isIdentStart(c):
+			for l.pos < len(l.src) && isIdentPart(l.peek(0)) {
+				l.advance(1)
+			}
+			l.emit(tokIdent, start, line, col)
+		case isDigit(c) || (c == '.' && isDigit(l.peek(1))):
+			for l.pos < len(l.src) && (isIdentPart(l.peek(0)) || l.peek(0) == '.') {
+				l.advance(1)
+			}
+			l.emit(tokNumber, start, line, col)
+		case c == '/' && l.regexAllowed():
+			l.scanRegex()
+			l.emit(tokRegex, start, line, col)
+		default:
+			l.advance(l.punctLen())
+			l.emit(tokPunct, start, line, col)
+		}
+	}
+}
+
+// punctLen returns the length of the punctuator at the current position.
+func (l *lexer) punctLen() int {
+	rest := l.src[l.pos:]
+	for _, p := range punctuators {
+		if strings.HasPrefix(rest, p) {
+			// "?." followed by a digit is a ternary with a decimal number.
+			if p == "?." && len(rest) > 2 && isDigit(rest[2]) {
+				continue
+			}
+			return len(p)
+		}
+	}
+	return 1
+}
+
+// regexAllowed reports whether a '/' at the current position starts a
+// regex literal rather than a division operator.
+func (l *lexer) regexAllowed() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+	prev := l.tokens[len(l.tokens)-1]
+	switch prev.kind {
+	case tokIdent:
+		return regexPrefixKeywords[prev.text]
+	case tokNumber, tokString, tokTemplate, tokRegex:
+		return false
+	default:
+		return prev.text != ")" && prev.text != "]" && prev.text != "}" &&
+			prev.text != "++" && prev.text != "--"
+	}
+}
+
+func (l *lexer) scanString(quote byte) {
+	line, col := l.line, l.col
+	l.advance(1)
+	for l.pos < len(l.src) {
+		c := l.peek(0)
+		switch {
+		case c == '\\':
+			l.advance(2)
+		case c == quote:
+			l.advance(1)
+			return
+		case c == '\n':
+			l.fail("unterminated string", line, col)
+			return
+		default:
+			l.advance(1)
+		}
+	}
+	l.fail("unterminated string", line, col)
+}
+
+func (l *lexer) scanTemplate() {
+	line, col := l.line, l.col
+	l.advance(1)
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.peek(0)
+		switch {
+		case c == '\\':
+			l.advance(2)
+		case depth == 0 && c == '`':
+			l.advance(1)
+			return
+		case c == '$' && l.peek(1) == '{':
+			depth++
+			l.advance(2)
+		case depth > 0 && c == '}':
+			depth--
+			l.advance(1)
+		case depth > 0 && (c == '"' || c == '\''):
+			l.scanString(c)
+		default:
+			l.advance(1)
+		}
+	}
+	l.fail("unterminated template literal", line, col)
+}
+
+func (l *lexer) scanRegex() {
+	line, col := l.line, l.col
+	l.advance(1)
+	inClass := false
+	for l.pos < len(l.src) {
+		c := l.peek(0)
+		switch {
+		case c == '\\':
+			l.advance(2)
+			continue
+		case c == '\n':
+			l.fail("unterminated regular expression", line, col)
+			return
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			l.advance(1)
+			for l.pos < len(l.src) && isIdentPart(l.peek(0)) {
+				l.advance(1)
+			}
+			return
+		}
+		l.advance(1)
+	}
+	l.fail("unterminated regular expression", line, col)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,476 @@
+package scripting
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/scripting/lint"
+	"github.com/tj-smith47/shelly-go/types"
+)
+
+const (
+	// DefaultChunkSize is the default PutCode chunk size in bytes.
+	//
+	// Devices reject very large RPC frames; 1KB chunks are accepted by all
+	// Gen2+ firmware versions.
+	DefaultChunkSize = 1024
+
+	// DefaultKVSPrefix is the default KVS key prefix for version records.
+	DefaultKVSPrefix = "scriptver:"
+
+	// maxKVSKeyLength is the maximum KVS key length accepted by devices.
+	maxKVSKeyLength = 42
+
+	// hashLength is the number of hex characters kept from the SHA-256.
+	hashLength = 16
+)
+
+// Common errors.
+var (
+	// ErrScriptTooLarge indicates the script exceeds the configured size limit.
+	ErrScriptTooLarge = errors.New("script exceeds maximum size")
+
+	// ErrInvalidName indicates an empty or unusable script name.
+	ErrInvalidName = errors.New("invalid script name")
+
+	// ErrLintFailed indicates the static checker found errors in the script.
+	ErrLintFailed = errors.New("script failed static checks")
+)
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithChunkSize sets the maximum number of bytes sent per PutCode call.
+func WithChunkSize(size int) Option {
+	return func(m *Manager) {
+		if size > 0 {
+			m.chunkSize = size
+		}
+	}
+}
+
+// WithKVSPrefix sets the KVS key prefix used for version records.
+func WithKVSPrefix(prefix string) Option {
+	return func(m *Manager) {
+		m.kvsPrefix = prefix
+	}
+}
+
+// WithMaxScriptSize rejects scripts larger than size bytes before upload.
+// Use profiles.Limits.MaxScriptSize for the target device.
+func WithMaxScriptSize(size int) Option {
+	return func(m *Manager) {
+		m.maxScriptSize = size
+	}
+}
+
+// WithLint runs lint.Check on every script before upload.
+//
+// Scripts with lint errors are not uploaded and fail with ErrLintFailed;
+// all findings are reported in ScriptResult.Issues.
+func WithLint(opts *lint.Options) Option {
+	return func(m *Manager) {
+		if opts == nil {
+			opts = &lint.Options{}
+		}
+		m.lintOpts = opts
+	}
+}
+
+// Manager deploys and monitors scripts on a Gen2+ device.
+type Manager struct {
+	script        *components.Script
+	kvs           *components.KVS
+	now           func() time.Time
+	lintOpts      *lint.Options
+	kvsPrefix     string
+	chunkSize     int
+	maxScriptSize int
+}
+
+// New creates a new script Manager with the given RPC client.
+func New(client *rpc.Client, opts ...Option) *Manager {
+	m := &Manager{
+		script:    components.NewScript(client),
+		kvs:       components.NewKVS(client),
+		now:       time.Now,
+		kvsPrefix: DefaultKVSPrefix,
+		chunkSize: DefaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// LoadDir reads every .js file in dir as a Source.
+//
+// The script name is the file name without the .js extension. Files are
+// returned sorted by name. Subdirectories are not traversed.
+func LoadDir(dir string) ([]Source, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script directory: %w", err)
+	}
+
+	var sources []Source
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".js") {
+			continue
+		}
+		code, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		sources = append(sources, Source{
+			Name: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Code: string(code),
+		})
+	}
+
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+// Hash returns the short content hash used in version records.
+func Hash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// ChunkCode splits code into pieces of at most size bytes.
+//
+// Chunks never split a multi-byte UTF-8 sequence, so each chunk is valid
+// UTF-8 on its own and survives JSON encoding intact.
+func ChunkCode(code string, size int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if code == "" {
+		return []string{""}
+	}
+
+	chunks := make([]string, 0, len(code)/size+1)
+	for len(code) > 0 {
+		end := size
+		if end >= len(code) {
+			chunks = append(chunks, code)
+			break
+		}
+		for end > 0 && !utf8.RuneStart(code[end]) {
+			end--
+		}
+		if end == 0 {
+			// A single rune larger than size; send it whole.
+			_, end = utf8.DecodeRuneInString(code)
+		}
+		chunks = append(chunks, code[:end])
+		code = code[end:]
+	}
+	return chunks
+}
+
+// SyncDir loads all .js files from dir and syncs them to the device.
+func (m *Manager) SyncDir(ctx context.Context, dir string, opts *SyncOptions) (*SyncResult, error) {
+	sources, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return m.Sync(ctx, sources, opts)
+}
+
+// Sync deploys sources to the device.
+//
+// For each source the content hash is compared with the version recorded in
+// KVS. Unchanged scripts are skipped. Changed scripts are stopped if
+// running, uploaded in chunks, recorded in KVS and restarted.
+func (m *Manager) Sync(ctx context.Context, sources []Source, opts *SyncOptions) (*SyncResult, error) {
+	if opts == nil {
+		opts = DefaultSyncOptions()
+	}
+
+	list, err := m.script.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %w", err)
+	}
+
+	existing := make(map[string]components.ScriptListItem, len(list.Scripts))
+	for _, s := range list.Scripts {
+		if s.Name != nil {
+			existing[*s.Name] = s
+		}
+	}
+
+	result := &SyncResult{}
+	wanted := make(map[string]bool, len(sources))
+
+	for _, src := range sources {
+		wanted[src.Name] = true
+
+		var current *components.ScriptListItem
+		if s, ok := existing[src.Name]; ok {
+			current = &s
+		}
+
+		res := m.deploy(ctx, src, current, opts)
+		result.Scripts = append(result.Scripts, res)
+		if res.Action == ActionFailed && opts.StopOnError {
+			return result, fmt.Errorf("failed to deploy %s: %w", src.Name, res.Error)
+		}
+	}
+
+	if opts.Prune {
+		for _, s := range list.Scripts {
+			if s.Name == nil || wanted[*s.Name] {
+				continue
+			}
+			res, managed := m.prune(ctx, *s.Name, s.ID)
+			if !managed {
+				continue
+			}
+			result.Scripts = append(result.Scripts, res)
+			if res.Action == ActionFailed && opts.StopOnError {
+				return result, fmt.Errorf("failed to delete %s: %w", *s.Name, res.Error)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Deploy syncs a single script with default options.
+func (m *Manager) Deploy(ctx context.Context, name, code string) (*ScriptResult, error) {
+	result, err := m.Sync(ctx, []Source{{Name: name, Code: code}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := result.Scripts[0]
+	if res.Action == ActionFailed {
+		return &res, res.Error
+	}
+	return &res, nil
+}
+
+// deploy syncs one source against its current device state.
+func (m *Manager) deploy(
+	ctx context.Context, src Source, current *components.ScriptListItem, opts *SyncOptions,
+) ScriptResult {
+	res := ScriptResult{Name: src.Name, Hash: Hash(src.Code)}
+
+	fail := func(err error) ScriptResult {
+		res.Action = ActionFailed
+		res.Error = err
+		return res
+	}
+
+	if src.Name == "" {
+		return fail(ErrInvalidName)
+	}
+	if m.maxScriptSize > 0 && len(src.Code) > m.maxScriptSize {
+		return fail(fmt.Errorf("%w: %d > %d bytes", ErrScriptTooLarge, len(src.Code), m.maxScriptSize))
+	}
+	if m.lintOpts != nil {
+		checked := lint.Check(src.Code, m.lintOpts)
+		res.Issues = checked.Issues
+		if checked.HasErrors() {
+			return fail(fmt.Errorf("%w: %s", ErrLintFailed, checked.Summary()))
+		}
+	}
+
+	prev, err := m.Version(ctx, src.Name)
+	if err != nil && !errors.Is(err, types.ErrNotFound) {
+		return fail(err)
+	}
+
+	wasRunning := false
+	if current != nil {
+		res.ID = current.ID
+		wasRunning = current.Running
+		if prev != nil && prev.Hash == res.Hash && !opts.Force {
+			res.Action = ActionUnchanged
+			res.Version = prev.Version
+			if opts.Start && !current.Running {
+				if err := m.script.Start(ctx, res.ID); err != nil {
+					return fail(fmt.Errorf("failed to start script: %w", err))
+				}
+			}
+			res.Status = m.report(ctx, res.ID, src.Name, prev)
+			return res
+		}
+		res.Action = ActionUpdated
+	} else {
+		name := src.Name
+		created, err := m.script.Create(ctx, &name)
+		if err != nil {
+			return fail(fmt.Errorf("failed to create script: %w", err))
+		}
+		res.ID = created.ID
+		res.Action = ActionCreated
+	}
+
+	if wasRunning {
+		if err := m.script.Stop(ctx, res.ID); err != nil {
+			return fail(fmt.Errorf("failed to stop script: %w", err))
+		}
+	}
+
+	chunks := ChunkCode(src.Code, m.chunkSize)
+	for i, chunk := range chunks {
+		if err := m.script.PutCode(ctx, res.ID, chunk, i > 0); err != nil {
+			return fail(fmt.Errorf("failed to upload chunk %d/%d: %w", i+1, len(chunks), err))
+		}
+	}
+	res.Chunks = len(chunks)
+
+	version := &Version{
+		Hash:     res.Hash,
+		Version:  1,
+		Size:     len(src.Code),
+		Deployed: m.now().Unix(),
+	}
+	if prev != nil {
+		version.Version = prev.Version + 1
+	}
+	if _, err := m.kvs.Set(ctx, m.versionKey(src.Name), version); err != nil {
+		return fail(fmt.Errorf("failed to record version: %w", err))
+	}
+	res.Version = version.Version
+
+	if opts.Enable {
+		enable := true
+		if err := m.script.SetConfig(ctx, res.ID, &components.ScriptConfig{Enable: &enable}); err != nil {
+			return fail(fmt.Errorf("failed to enable script: %w", err))
+		}
+	}
+
+	if wasRunning || opts.Start {
+		if err := m.script.Start(ctx, res.ID); err != nil {
+			return fail(fmt.Errorf("failed to start script: %w", err))
+		}
+	}
+
+	res.Status = m.report(ctx, res.ID, src.Name, version)
+	return res
+}
+
+// prune deletes a script that has a version record. It reports whether the
+// script was managed by this Manager.
+func (m *Manager) prune(ctx context.Context, name string, id int) (ScriptResult, bool) {
+	res := ScriptResult{Name: name, ID: id, Action: ActionDeleted}
+
+	prev, err := m.Version(ctx, name)
+	if err != nil || prev == nil {
+		return res, false
+	}
+	res.Version = prev.Version
+	res.Hash = prev.Hash
+
+	//nolint:errcheck // Best-effort stop, Delete fails on running scripts anyway
+	m.script.Stop(ctx, id)
+	if err := m.script.Delete(ctx, id); err != nil {
+		res.Action = ActionFailed
+		res.Error = err
+		return res, true
+	}
+	if _, err := m.kvs.Delete(ctx, m.versionKey(name)); err != nil {
+		res.Action = ActionFailed
+		res.Error = fmt.Errorf("failed to delete version record: %w", err)
+	}
+	return res, true
+}
+
+// Version returns the deployment record for a script.
+//
+// Returns an error wrapping types.ErrNotFound if the script has never been
+// deployed by a Manager.
+func (m *Manager) Version(ctx context.Context, name string) (*Version, error) {
+	resp, err := m.kvs.Get(ctx, m.versionKey(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version of %s: %w", name, err)
+	}
+
+	data, err := json.Marshal(resp.Value)
+	if err != nil {
+		return nil, err
+	}
+	var v Version
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid version record for %s: %w", name, err)
+	}
+	return &v, nil
+}
+
+// Status returns the runtime report for every script on the device.
+func (m *Manager) Status(ctx context.Context) ([]Report, error) {
+	list, err := m.script.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %w", err)
+	}
+
+	reports := make([]Report, 0, len(list.Scripts))
+	for _, s := range list.Scripts {
+		name := ""
+		if s.Name != nil {
+			name = *s.Name
+		}
+
+		var version *Version
+		if name != "" {
+			version, _ = m.Version(ctx, name) //nolint:errcheck // Unmanaged scripts have no version
+		}
+
+		report := m.report(ctx, s.ID, name, version)
+		report.Enabled = s.Enable
+		report.Running = report.Running || s.Running
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// report builds a Report from Script.GetStatus. Status errors are ignored
+// so a deployment result is never lost because of a failed status read.
+func (m *Manager) report(ctx context.Context, id int, name string, version *Version) *Report {
+	report := &Report{ID: id, Name: name, Version: version}
+
+	status, err := m.script.GetStatus(ctx, id)
+	if err != nil {
+		return report
+	}
+
+	report.Running = status.Running
+	report.Errors = status.Errors
+	if status.MemUsage != nil {
+		report.MemUsage = *status.MemUsage
+	}
+	if status.MemPeak != nil {
+		report.MemPeak = *status.MemPeak
+	}
+	if status.MemFree != nil {
+		report.MemFree = *status.MemFree
+	}
+	return report
+}
+
+// versionKey returns the KVS key for a script's version record.
+//
+// Keys that would exceed the device key length limit fall back to a hash
+// of the script name.
+func (m *Manager) versionKey(name string) string {
+	key := m.kvsPrefix + name
+	if len(key) <= maxKVSKeyLength {
+		return key
+	}
+	return m.kvsPrefix + Hash(name)
+}
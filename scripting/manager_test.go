@@ -0,0 +1,515 @@
+package scripting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tj-smith47/shelly-go/rpc"
+	"github.com/tj-smith47/shelly-go/scripting/lint"
+	"github.com/tj-smith47/shelly-go/transport"
+)
+
+// mockTransport implements transport.Transport for testing.
+type mockTransport struct {
+	callFunc func(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error)
+}
+
+func (m *mockTransport) Call(ctx context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	if m.callFunc != nil {
+		return m.callFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+// jsonrpcResponse wraps a result in a JSON-RPC response envelope.
+func jsonrpcResponse(result any) (json.RawMessage, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(data),
+	})
+}
+
+// jsonrpcError returns a JSON-RPC error envelope.
+func jsonrpcError(code int, message string) (json.RawMessage, error) {
+	return json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"error":   map[string]any{"code": code, "message": message},
+	})
+}
+
+type fakeScript struct {
+	name    string
+	code    string
+	id      int
+	enable  bool
+	running bool
+}
+
+// fakeDevice simulates the Script and KVS RPC surface of a Gen2 device.
+type fakeDevice struct {
+	scripts map[int]*fakeScript
+	kvs     map[string]json.RawMessage
+	failOn  map[string]error
+	calls   []string
+	nextID  int
+	mu      sync.Mutex
+}
+
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{
+		scripts: make(map[int]*fakeScript),
+		kvs:     make(map[string]json.RawMessage),
+		failOn:  make(map[string]error),
+		nextID:  1,
+	}
+}
+
+func (d *fakeDevice) client() *rpc.Client {
+	return rpc.NewClient(&mockTransport{callFunc: d.call})
+}
+
+func (d *fakeDevice) count(method string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, c := range d.calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+//nolint:gocyclo // Test fake covering many RPC methods
+func (d *fakeDevice) call(_ context.Context, req transport.RPCRequest) (json.RawMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	method := req.GetMethod()
+	d.calls = append(d.calls, method)
+	if err, ok := d.failOn[method]; ok {
+		return nil, err
+	}
+
+	var p struct {
+		Config *struct {
+			Enable *bool `json:"enable"`
+		} `json:"config"`
+		Value  json.RawMessage `json:"value"`
+		Name   string          `json:"name"`
+		Code   string          `json:"code"`
+		Key    string          `json:"key"`
+		ID     int             `json:"id"`
+		Append bool            `json:"append"`
+	}
+	if params := req.GetParams(); len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+	}
+
+	switch method {
+	case "Script.List":
+		list := make([]map[string]any, 0, len(d.scripts))
+		for id := 1; id < d.nextID; id++ {
+			if s, ok := d.scripts[id]; ok {
+				list = append(list, map[string]any{"id": s.id, "name": s.name, "enable": s.enable, "running": s.running})
+			}
+		}
+		return jsonrpcResponse(map[string]any{"scripts": list})
+	case "Script.Create":
+		s := &fakeScript{id: d.nextID, name: p.Name}
+		d.scripts[s.id] = s
+		d.nextID++
+		return jsonrpcResponse(map[string]any{"id": s.id})
+	case "Script.PutCode":
+		s := d.scripts[p.ID]
+		if p.Append {
+			s.code += p.Code
+		} else {
+			s.code = p.Code
+		}
+		return jsonrpcResponse(map[string]any{"len": len(s.code)})
+	case "Script.Start":
+		d.scripts[p.ID].running = true
+		return jsonrpcResponse(map[string]any{"was_running": false})
+	case "Script.Stop":
+		d.scripts[p.ID].running = false
+		return jsonrpcResponse(map[string]any{"was_running": true})
+	case "Script.Delete":
+		delete(d.scripts, p.ID)
+		return jsonrpcResponse(nil)
+	case "Script.SetConfig":
+		if p.Config != nil && p.Config.Enable != nil {
+			d.scripts[p.ID].enable = *p.Config.Enable
+		}
+		return jsonrpcResponse(map[string]any{"restart_required": false})
+	case "Script.GetStatus":
+		s := d.scripts[p.ID]
+		return jsonrpcResponse(map[string]any{
+			"id": s.id, "running": s.running, "mem_usage": 1200, "mem_peak": 2400, "mem_free": 20000,
+		})
+	case "KVS.Get":
+		v, ok := d.kvs[p.Key]
+		if !ok {
+			return jsonrpcError(-105, "Argument 'key', value '"+p.Key+"' not found!")
+		}
+		return jsonrpcResponse(map[string]any{"value": v, "etag": "e"})
+	case "KVS.Set":
+		d.kvs[p.Key] = p.Value
+		return jsonrpcResponse(map[string]any{"etag": "e", "rev": 1})
+	case "KVS.Delete":
+		delete(d.kvs, p.Key)
+		return jsonrpcResponse(map[string]any{"rev": 2})
+	}
+	return jsonrpcError(-32601, "method not found")
+}
+
+func TestChunkCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		size       int
+		wantChunks int
+	}{
+		{"empty", "", 10, 1},
+		{"smaller than chunk", "let x = 1;", 100, 1},
+		{"exact multiple", strings.Repeat("a", 30), 10, 3},
+		{"remainder", strings.Repeat("a", 25), 10, 3},
+		{"default size", strings.Repeat("a", DefaultChunkSize+1), 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkCode(tt.code, tt.size)
+			if len(chunks) != tt.wantChunks {
+				t.Errorf("got %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+			if strings.Join(chunks, "") != tt.code {
+				t.Error("chunks do not reassemble to the original code")
+			}
+		})
+	}
+}
+
+func TestChunkCode_UTF8Boundaries(t *testing.T) {
+	code := strings.Repeat("print('héllo wörld ✓');\n", 50)
+
+	for _, size := range []int{1, 2, 3, 7, 64} {
+		chunks := ChunkCode(code, size)
+		for i, c := range chunks {
+			if !utf8.ValidString(c) {
+				t.Fatalf("size %d: chunk %d is not valid UTF-8", size, i)
+			}
+			if size >= utf8.UTFMax && len(c) > size {
+				t.Fatalf("size %d: chunk %d has %d bytes", size, i, len(c))
+			}
+		}
+		if strings.Join(chunks, "") != code {
+			t.Fatalf("size %d: chunks do not reassemble", size)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("a") == Hash("b") {
+		t.Error("different code should hash differently")
+	}
+	if len(Hash("a")) != hashLength {
+		t.Errorf("hash length = %d, want %d", len(Hash("a")), hashLength)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.js":      "print('b');",
+		"a.js":      "print('a');",
+		"notes.txt": "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.js"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if len(sources) != 2 {
+		t.Fatalf("got %d sources, want 2", len(sources))
+	}
+	if sources[0].Name != "a" || sources[1].Name != "b" {
+		t.Errorf("unexpected order: %s, %s", sources[0].Name, sources[1].Name)
+	}
+
+	if _, err := LoadDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestManager_Sync_CreateAndUnchanged(t *testing.T) {
+	dev := newFakeDevice()
+	mgr := New(dev.client(), WithChunkSize(8))
+	ctx := context.Background()
+
+	code := "let counter = 0; print(counter);"
+	result, err := mgr.Sync(ctx, []Source{{Name: "counter", Code: code}}, nil)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	res := result.Scripts[0]
+	if res.Action != ActionCreated {
+		t.Fatalf("Action = %s, want %s", res.Action, ActionCreated)
+	}
+	if res.Version != 1 {
+		t.Errorf("Version = %d, want 1", res.Version)
+	}
+	if res.Chunks != 4 {
+		t.Errorf("Chunks = %d, want 4", res.Chunks)
+	}
+	if res.Status == nil || res.Status.MemUsage != 1200 || !res.Status.Running {
+		t.Errorf("unexpected status: %+v", res.Status)
+	}
+
+	s := dev.scripts[res.ID]
+	if s.code != code {
+		t.Errorf("device code = %q, want %q", s.code, code)
+	}
+	if !s.enable || !s.running {
+		t.Error("script should be enabled and running")
+	}
+
+	// Second sync with the same code is a no-op.
+	putCalls := dev.count("Script.PutCode")
+	stopCalls := dev.count("Script.Stop")
+	result, err = mgr.Sync(ctx, []Source{{Name: "counter", Code: code}}, nil)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if result.Scripts[0].Action != ActionUnchanged {
+		t.Errorf("Action = %s, want %s", result.Scripts[0].Action, ActionUnchanged)
+	}
+	if dev.count("Script.PutCode") != putCalls || dev.count("Script.Stop") != stopCalls {
+		t.Error("unchanged script should not be uploaded or restarted")
+	}
+	if len(result.Changed()) != 0 {
+		t.Errorf("Changed() = %d, want 0", len(result.Changed()))
+	}
+}
+
+func TestManager_Sync_UpdateRestartsRunningScript(t *testing.T) {
+	dev := newFakeDevice()
+	mgr := New(dev.client())
+	ctx := context.Background()
+
+	if _, err := mgr.Deploy(ctx, "heater", "print(1);"); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	res, err := mgr.Deploy(ctx, "heater", "print(2);")
+	if err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+	if res.Action != ActionUpdated {
+		t.Errorf("Action = %s, want %s", res.Action, ActionUpdated)
+	}
+	if res.Version != 2 {
+		t.Errorf("Version = %d, want 2", res.Version)
+	}
+	if dev.count("Script.Stop") != 1 {
+		t.Errorf("Stop calls = %d, want 1", dev.count("Script.Stop"))
+	}
+	if !dev.scripts[res.ID].running {
+		t.Error("script should be running after update")
+	}
+
+	v, err := mgr.Version(ctx, "heater")
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if v.Version != 2 || v.Hash != Hash("print(2);") || v.Size != len("print(2);") {
+		t.Errorf("unexpected version: %+v", v)
+	}
+}
+
+func TestManager_Sync_NoStart(t *testing.T) {
+	dev := newFakeDevice()
+	mgr := New(dev.client())
+
+	result, err := mgr.Sync(context.Background(), []Source{{Name: "idle", Code: "1;"}}, &SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	s := dev.scripts[result.Scripts[0].ID]
+	if s.running || s.enable {
+		t.Error("script should be neither running nor enabled")
+	}
+}
+
+func TestManager_Sync_Prune(t *testing.T) {
+	dev := newFakeDevice()
+	mgr := New(dev.client())
+	ctx := context.Background()
+
+	if _, err := mgr.Sync(ctx, []Source{{Name: "keep", Code: "1;"}, {Name: "old", Code: "2;"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Unmanaged script without a version record must survive pruning.
+	dev.scripts[99] = &fakeScript{id: 99, name: "manual"}
+	dev.nextID = 100
+
+	result, err := mgr.Sync(ctx, []Source{{Name: "keep", Code: "1;"}}, &SyncOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	var deleted []string
+	for _, s := range result.Scripts {
+		if s.Action == ActionDeleted {
+			deleted = append(deleted, s.Name)
+		}
+	}
+	if len(deleted) != 1 || deleted[0] != "old" {
+		t.Errorf("deleted = %v, want [old]", deleted)
+	}
+	if _, ok := dev.scripts[99]; !ok {
+		t.Error("unmanaged script was deleted")
+	}
+	if _, ok := dev.kvs[DefaultKVSPrefix+"old"]; ok {
+		t.Error("version record of deleted script was not removed")
+	}
+}
+
+func TestManager_Sync_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("list fails", func(t *testing.T) {
+		dev := newFakeDevice()
+		dev.failOn["Script.List"] = errors.New("offline")
+		if _, err := New(dev.client()).Sync(ctx, []Source{{Name: "a", Code: "1;"}}, nil); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		dev := newFakeDevice()
+		mgr := New(dev.client(), WithMaxScriptSize(4))
+		result, err := mgr.Sync(ctx, []Source{{Name: "big", Code: "12345"}}, nil)
+		if err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		failed := result.Failed()
+		if len(failed) != 1 || !errors.Is(failed[0].Error, ErrScriptTooLarge) {
+			t.Errorf("expected ErrScriptTooLarge, got %+v", failed)
+		}
+	})
+
+	t.Run("lint errors", func(t *testing.T) {
+		dev := newFakeDevice()
+		mgr := New(dev.client(), WithLint(nil))
+		result, err := mgr.Sync(ctx, []Source{{Name: "bad", Code: "setTimeout(f, 10);"}}, nil)
+		if err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		failed := result.Failed()
+		if len(failed) != 1 || !errors.Is(failed[0].Error, ErrLintFailed) {
+			t.Fatalf("expected ErrLintFailed, got %+v", failed)
+		}
+		if len(failed[0].Issues) != 1 || failed[0].Issues[0].Rule != lint.RuleTimers {
+			t.Errorf("Issues = %+v", failed[0].Issues)
+		}
+		if dev.count("Script.Create") != 0 {
+			t.Error("script with lint errors should not be uploaded")
+		}
+	})
+
+	t.Run("stop on error", func(t *testing.T) {
+		dev := newFakeDevice()
+		dev.failOn["Script.PutCode"] = errors.New("too big")
+		mgr := New(dev.client())
+		opts := &SyncOptions{StopOnError: true}
+		result, err := mgr.Sync(ctx, []Source{{Name: "a", Code: "1;"}, {Name: "b", Code: "2;"}}, opts)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if len(result.Scripts) != 1 {
+			t.Errorf("got %d results, want 1", len(result.Scripts))
+		}
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		dev := newFakeDevice()
+		if _, err := New(dev.client()).Deploy(ctx, "", "1;"); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("expected ErrInvalidName, got %v", err)
+		}
+	})
+}
+
+func TestManager_Status(t *testing.T) {
+	dev := newFakeDevice()
+	mgr := New(dev.client())
+	ctx := context.Background()
+
+	if _, err := mgr.Deploy(ctx, "managed", "1;"); err != nil {
+		t.Fatal(err)
+	}
+	dev.scripts[5] = &fakeScript{id: 5, name: "manual"}
+	dev.nextID = 6
+
+	reports, err := mgr.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reports))
+	}
+	if reports[0].Version == nil || reports[0].Version.Version != 1 {
+		t.Errorf("managed script should have version 1: %+v", reports[0])
+	}
+	if !reports[0].Enabled || !reports[0].Running {
+		t.Error("managed script should be enabled and running")
+	}
+	if reports[1].Version != nil {
+		t.Error("unmanaged script should have no version")
+	}
+}
+
+func TestManager_VersionKey(t *testing.T) {
+	mgr := New(nil, WithKVSPrefix("v:"))
+
+	if got := mgr.versionKey("short"); got != "v:short" {
+		t.Errorf("versionKey() = %q, want %q", got, "v:short")
+	}
+
+	long := strings.Repeat("x", 60)
+	key := mgr.versionKey(long)
+	if len(key) > maxKVSKeyLength {
+		t.Errorf("key length %d exceeds limit", len(key))
+	}
+	if key != mgr.versionKey(long) {
+		t.Error("versionKey() should be stable")
+	}
+}
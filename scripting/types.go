@@ -0,0 +1,176 @@
+package scripting
+
+import (
+	"time"
+
+	"github.com/tj-smith47/shelly-go/scripting/lint"
+)
+
+// Source is a script to deploy.
+type Source struct {
+	// Name is the script name on the device.
+	Name string `json:"name"`
+
+	// Code is the script source.
+	Code string `json:"code"`
+}
+
+// Version is the deployment record stored in KVS for each managed script.
+type Version struct {
+	// Hash is a short hex-encoded SHA-256 of the deployed code.
+	Hash string `json:"h"`
+
+	// Version is incremented on every deployment that changes the code.
+	Version int `json:"v"`
+
+	// Size is the deployed code size in bytes.
+	Size int `json:"n"`
+
+	// Deployed is the Unix time of the deployment.
+	Deployed int64 `json:"t,omitempty"`
+}
+
+// DeployedAt returns the deployment time.
+func (v *Version) DeployedAt() time.Time {
+	return time.Unix(v.Deployed, 0)
+}
+
+// Action describes what a sync did with a script.
+type Action string
+
+const (
+	// ActionCreated means the script did not exist and was created.
+	ActionCreated Action = "created"
+
+	// ActionUpdated means the script code changed and was re-uploaded.
+	ActionUpdated Action = "updated"
+
+	// ActionUnchanged means the deployed code already matched.
+	ActionUnchanged Action = "unchanged"
+
+	// ActionDeleted means the script was removed (SyncOptions.Prune).
+	ActionDeleted Action = "deleted"
+
+	// ActionFailed means the deployment failed; see ScriptResult.Error.
+	ActionFailed Action = "failed"
+)
+
+// SyncOptions controls how scripts are deployed.
+type SyncOptions struct {
+	// Enable sets each deployed script to run on boot.
+	Enable bool
+
+	// Start starts scripts that are not running after deployment.
+	// Scripts that were running before an update are always restarted.
+	Start bool
+
+	// Force re-uploads scripts even when the hash matches.
+	Force bool
+
+	// Prune deletes managed scripts on the device that are not in the
+	// source set. Only scripts with a KVS version record are touched.
+	Prune bool
+
+	// StopOnError aborts the sync on the first failed script.
+	StopOnError bool
+}
+
+// DefaultSyncOptions returns options that enable and start every script.
+func DefaultSyncOptions() *SyncOptions {
+	return &SyncOptions{
+		Enable: true,
+		Start:  true,
+	}
+}
+
+// ScriptResult is the outcome of syncing one script.
+type ScriptResult struct {
+	// Error is set when Action is ActionFailed.
+	Error error `json:"-"`
+
+	// Status is the script status after deployment, if available.
+	Status *Report `json:"status,omitempty"`
+
+	// Name is the script name.
+	Name string `json:"name"`
+
+	// Action is what the sync did.
+	Action Action `json:"action"`
+
+	// Hash is the content hash of the deployed code.
+	Hash string `json:"hash,omitempty"`
+
+	// ID is the script ID on the device.
+	ID int `json:"id"`
+
+	// Version is the deployed version number.
+	Version int `json:"version"`
+
+	// Issues are static checker findings when the Manager uses WithLint.
+	Issues []lint.Issue `json:"issues,omitempty"`
+
+	// Chunks is the number of PutCode calls used for the upload.
+	Chunks int `json:"chunks,omitempty"`
+}
+
+// SyncResult is the outcome of a sync.
+type SyncResult struct {
+	Scripts []ScriptResult `json:"scripts"`
+}
+
+// Changed returns the results for scripts that were created or updated.
+func (r *SyncResult) Changed() []ScriptResult {
+	var changed []ScriptResult
+	for _, s := range r.Scripts {
+		if s.Action == ActionCreated || s.Action == ActionUpdated {
+			changed = append(changed, s)
+		}
+	}
+	return changed
+}
+
+// Failed returns the results for scripts that failed to deploy.
+func (r *SyncResult) Failed() []ScriptResult {
+	var failed []ScriptResult
+	for _, s := range r.Scripts {
+		if s.Action == ActionFailed {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
+// Report is the runtime state of a script.
+type Report struct {
+	// Version is the KVS deployment record, nil for unmanaged scripts.
+	Version *Version `json:"version,omitempty"`
+
+	// Name is the script name.
+	Name string `json:"name"`
+
+	// Errors lists runtime errors reported by the device (e.g. "crashed").
+	Errors []string `json:"errors,omitempty"`
+
+	// ID is the script ID.
+	ID int `json:"id"`
+
+	// MemUsage is the current memory usage in bytes.
+	MemUsage int `json:"mem_usage"`
+
+	// MemPeak is the peak memory usage in bytes.
+	MemPeak int `json:"mem_peak"`
+
+	// MemFree is the free script memory in bytes.
+	MemFree int `json:"mem_free"`
+
+	// Running indicates the script is running.
+	Running bool `json:"running"`
+
+	// Enabled indicates the script runs on boot.
+	Enabled bool `json:"enabled"`
+}
+
+// HasErrors returns true if the device reported runtime errors.
+func (r *Report) HasErrors() bool {
+	return len(r.Errors) > 0
+}
@@ -0,0 +1,159 @@
+package timespec
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Builder assembles a timespec from typed values.
+//
+// Example:
+//
+//	spec, err := timespec.At(7, 30).Weekdays(time.Monday, time.Friday).Build()
+//	// "0 30 7 * * 1,5"
+//
+//	spec, err := timespec.AtSunset(-30 * time.Minute).Months(time.December).Build()
+//	// "@sunset-30m * 12 *"
+type Builder struct {
+	err    error
+	sec    string
+	min    string
+	hour   string
+	day    string
+	month  string
+	wd     string
+	sun    SunEvent
+	offset time.Duration
+}
+
+func newBuilder() *Builder {
+	return &Builder{sec: "0", min: "0", hour: "0", day: "*", month: "*", wd: "*"}
+}
+
+// At starts a timespec firing daily at hour:minute:00.
+func At(hour, minute int) *Builder {
+	return AtSecond(hour, minute, 0)
+}
+
+// AtSecond starts a timespec firing daily at hour:minute:second.
+func AtSecond(hour, minute, second int) *Builder {
+	b := newBuilder()
+	b.hour = b.check(hour, fieldHour)
+	b.min = b.check(minute, fieldMinute)
+	b.sec = b.check(second, fieldSecond)
+	return b
+}
+
+// Every starts a timespec firing at a fixed interval aligned to the clock.
+//
+// The interval must evenly divide a minute, an hour or a day: 10s, 15m
+// and 6h are valid, 7m is not.
+func Every(interval time.Duration) *Builder {
+	b := newBuilder()
+	switch {
+	case interval <= 0 || interval%time.Second != 0:
+		b.err = fmt.Errorf("invalid interval %s", interval)
+	case interval < time.Minute && time.Minute%interval == 0:
+		b.sec = "*/" + strconv.Itoa(int(interval/time.Second))
+		b.min, b.hour = "*", "*"
+	case interval < time.Hour && interval%time.Minute == 0 && time.Hour%interval == 0:
+		b.min = "*/" + strconv.Itoa(int(interval/time.Minute))
+		b.hour = "*"
+	case interval < 24*time.Hour && interval%time.Hour == 0 && (24*time.Hour)%interval == 0:
+		b.hour = "*/" + strconv.Itoa(int(interval/time.Hour))
+	case interval == 24*time.Hour:
+	default:
+		b.err = fmt.Errorf("interval %s does not evenly divide a minute, hour or day", interval)
+	}
+	return b
+}
+
+// AtSunrise starts a timespec firing at sunrise plus offset.
+func AtSunrise(offset time.Duration) *Builder {
+	b := newBuilder()
+	b.sun, b.offset = Sunrise, offset
+	return b
+}
+
+// AtSunset starts a timespec firing at sunset plus offset.
+func AtSunset(offset time.Duration) *Builder {
+	b := newBuilder()
+	b.sun, b.offset = Sunset, offset
+	return b
+}
+
+// Weekdays restricts the timespec to the given days of the week.
+func (b *Builder) Weekdays(days ...time.Weekday) *Builder {
+	values := make([]int, len(days))
+	for i, d := range days {
+		values[i] = int(d)
+	}
+	b.wd = b.list(values, fieldWeekday)
+	return b
+}
+
+// Days restricts the timespec to the given days of the month.
+func (b *Builder) Days(days ...int) *Builder {
+	b.day = b.list(days, fieldDay)
+	return b
+}
+
+// Months restricts the timespec to the given months.
+func (b *Builder) Months(months ...time.Month) *Builder {
+	values := make([]int, len(months))
+	for i, m := range months {
+		values[i] = int(m)
+	}
+	b.month = b.list(values, fieldMonth)
+	return b
+}
+
+// String returns the timespec text, or "" if the builder has an error.
+func (b *Builder) String() string {
+	if b.err != nil {
+		return ""
+	}
+	dates := b.day + " " + b.month + " " + b.wd
+	if b.sun != SunNone {
+		return "@" + string(b.sun) + formatOffset(b.offset) + " " + dates
+	}
+	return b.sec + " " + b.min + " " + b.hour + " " + dates
+}
+
+// Build validates and parses the timespec.
+func (b *Builder) Build() (*Spec, error) {
+	if b.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, b.err)
+	}
+	return Parse(b.String())
+}
+
+// check records an error if v is out of range for kind.
+func (b *Builder) check(v int, kind fieldKind) string {
+	fb := bounds[kind]
+	if v < fb.min || v > fb.max {
+		b.err = fmt.Errorf("%s %d out of range %d-%d", fb.name, v, fb.min, fb.max)
+	}
+	return strconv.Itoa(v)
+}
+
+// list formats sorted, de-duplicated values as a comma-separated field.
+func (b *Builder) list(values []int, kind fieldKind) string {
+	if len(values) == 0 {
+		return "*"
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	parts := make([]string, 0, len(sorted))
+	for i, v := range sorted {
+		if i > 0 && v == sorted[i-1] {
+			continue
+		}
+		parts = append(parts, b.check(v, kind))
+	}
+	return strings.Join(parts, ",")
+}
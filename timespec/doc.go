@@ -0,0 +1,41 @@
+// Package timespec parses, validates and evaluates Shelly Gen2+ Schedule
+// timespecs.
+//
+// Schedule.Create accepts a cron-like timespec that the device evaluates
+// in its own timezone. This package understands the same syntax so that
+// invalid specs are caught before they are sent and so applications can
+// show when an automation will actually run.
+//
+// # Syntax
+//
+//	ss mm hh DD MM WW              seconds minutes hours day month weekday
+//	@sunrise[±offset] DD MM WW     solar event with optional offset
+//	@sunset[±offset] DD MM WW
+//
+// Fields accept "*", numbers, names (JAN-DEC, SUN-SAT), ranges ("MON-FRI"),
+// lists ("1,15") and steps ("*/10", "0-30/5"). Sun offsets are durations
+// ("+1h30m", "-45m") or plain minutes ("+30").
+//
+// # Next Fire Times
+//
+//	sysCfg, _ := components.NewSys(client).GetConfig(ctx)
+//	loc, err := timespec.LocationFromSys(sysCfg)
+//	if err != nil {
+//	    return err
+//	}
+//
+//	spec, err := timespec.Parse("@sunset-30m * * MON-FRI")
+//	if err != nil {
+//	    return err // invalid spec, don't call Schedule.Create
+//	}
+//	times, err := spec.NextN(time.Now(), 5, loc)
+//
+// Sunrise and sunset are computed offline from the coordinates in the
+// device's Sys location config.
+//
+// # Building Timespecs
+//
+//	timespec.At(7, 30).Weekdays(time.Monday, time.Tuesday).String() // "0 30 7 * * 1,2"
+//	timespec.Every(15 * time.Minute).String()                       // "0 */15 * * * *"
+//	timespec.AtSunrise(time.Hour).String()                          // "@sunrise+1h * * *"
+package timespec
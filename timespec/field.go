@@ -0,0 +1,174 @@
+package timespec
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// fieldKind identifies one of the six timespec fields.
+type fieldKind int
+
+const (
+	fieldSecond fieldKind = iota
+	fieldMinute
+	fieldHour
+	fieldDay
+	fieldMonth
+	fieldWeekday
+)
+
+// fieldBounds describes the valid range and names of a field.
+type fieldBounds struct {
+	names map[string]int
+	name  string
+	min   int
+	max   int
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var weekdayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+var bounds = [...]fieldBounds{
+	fieldSecond:  {name: "second", min: 0, max: 59},
+	fieldMinute:  {name: "minute", min: 0, max: 59},
+	fieldHour:    {name: "hour", min: 0, max: 23},
+	fieldDay:     {name: "day of month", min: 1, max: 31},
+	fieldMonth:   {name: "month", min: 1, max: 12, names: monthNames},
+	fieldWeekday: {name: "weekday", min: 0, max: 6, names: weekdayNames},
+}
+
+// field is the set of values a timespec field matches.
+type field struct {
+	// text is the field as written, for String.
+	text string
+
+	// mask has bit n set when value n matches.
+	mask uint64
+}
+
+// has reports whether value v matches the field.
+func (f field) has(v int) bool {
+	return v >= 0 && v < 64 && f.mask&(1<<uint(v)) != 0
+}
+
+// values returns the matching values in ascending order.
+func (f field) values() []int {
+	out := make([]int, 0, bits.OnesCount64(f.mask))
+	for m := f.mask; m != 0; m &= m - 1 {
+		out = append(out, bits.TrailingZeros64(m))
+	}
+	return out
+}
+
+// parseField parses a comma-separated list of values, ranges and steps.
+func parseField(text string, kind fieldKind) (field, error) {
+	b := bounds[kind]
+	f := field{text: text}
+
+	if text == "" {
+		return f, fmt.Errorf("empty %s field", b.name)
+	}
+	if text == "*" || text == "?" {
+		f.mask = rangeMask(b.min, b.max, 1)
+		return f, nil
+	}
+
+	for _, part := range strings.Split(text, ",") {
+		mask, err := parsePart(part, kind)
+		if err != nil {
+			return f, err
+		}
+		f.mask |= mask
+	}
+	return f, nil
+}
+
+// parsePart parses a single list element: "*", "n", "a-b", with an
+// optional "/step".
+func parsePart(part string, kind fieldKind) (uint64, error) {
+	b := bounds[kind]
+
+	rangeText, stepText, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepText)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid step %q in %s field", stepText, b.name)
+		}
+		step = n
+	}
+
+	lo, hi := b.min, b.max
+	switch {
+	case rangeText == "*":
+	case strings.Contains(rangeText, "-"):
+		loText, hiText, _ := strings.Cut(rangeText, "-")
+		var err error
+		if lo, err = parseValue(loText, kind); err != nil {
+			return 0, err
+		}
+		if hi, err = parseValue(hiText, kind); err != nil {
+			return 0, err
+		}
+		// A weekday range may end on Sunday as 0, as in FRI-SUN.
+		if kind == fieldWeekday && hi == 0 && lo > 0 {
+			hi = 7
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q in %s field", rangeText, b.name)
+		}
+	default:
+		v, err := parseValue(rangeText, kind)
+		if err != nil {
+			return 0, err
+		}
+		lo = v
+		if !hasStep || hi < v {
+			hi = v
+		}
+	}
+
+	mask := rangeMask(lo, hi, step)
+	if kind == fieldWeekday && mask&(1<<7) != 0 {
+		mask = mask&^(1<<7) | 1
+	}
+	return mask, nil
+}
+
+// parseValue parses a number or name and checks it against the bounds.
+// Weekday 7 is accepted as an alias for Sunday; parsePart folds it into 0
+// once ranges are expanded.
+func parseValue(text string, kind fieldKind) (int, error) {
+	b := bounds[kind]
+
+	if v, ok := b.names[strings.ToUpper(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", b.name, text)
+	}
+	if kind == fieldWeekday && v == 7 {
+		return 7, nil
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("%s %d out of range %d-%d", b.name, v, b.min, b.max)
+	}
+	return v, nil
+}
+
+func rangeMask(lo, hi, step int) uint64 {
+	var mask uint64
+	for v := lo; v <= hi; v += step {
+		mask |= 1 << uint(v)
+	}
+	return mask
+}
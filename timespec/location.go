@@ -0,0 +1,67 @@
+package timespec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+// Location is where a timespec is evaluated: the device timezone and,
+// for @sunrise/@sunset, its coordinates.
+type Location struct {
+	// TZ is the timezone. Nil means UTC.
+	TZ *time.Location
+
+	// Lat is the latitude in degrees.
+	Lat float64
+
+	// Lng is the longitude in degrees.
+	Lng float64
+
+	// HasCoordinates is true when Lat and Lng are set.
+	HasCoordinates bool
+}
+
+// NewLocation creates a Location from an IANA timezone name and coordinates.
+func NewLocation(tz string, lat, lng float64) (*Location, error) {
+	zone, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %q: %w", tz, err)
+	}
+	return &Location{TZ: zone, Lat: lat, Lng: lng, HasCoordinates: true}, nil
+}
+
+// LocationFromSys builds a Location from the device's Sys.GetConfig
+// location settings.
+//
+// The device evaluates schedules in its own timezone with its own
+// coordinates, so this gives the same fire times the device will use.
+// Missing coordinates are allowed; only sun timespecs will then fail.
+func LocationFromSys(cfg *components.SysConfig) (*Location, error) {
+	loc := &Location{TZ: time.UTC}
+	if cfg == nil || cfg.Location == nil {
+		return loc, nil
+	}
+
+	if tz := cfg.Location.TZ; tz != nil && *tz != "" {
+		zone, err := time.LoadLocation(*tz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone %q: %w", *tz, err)
+		}
+		loc.TZ = zone
+	}
+	if cfg.Location.Lat != nil && cfg.Location.Lng != nil {
+		loc.Lat, loc.Lng = *cfg.Location.Lat, *cfg.Location.Lng
+		loc.HasCoordinates = true
+	}
+	return loc, nil
+}
+
+// timezone returns TZ, defaulting to UTC.
+func (l *Location) timezone() *time.Location {
+	if l.TZ == nil {
+		return time.UTC
+	}
+	return l.TZ
+}
@@ -0,0 +1,70 @@
+package timespec
+
+import (
+	"math"
+	"time"
+)
+
+// Solar calculation constants (see the "sunrise equation").
+const (
+	julianUnixEpoch = 2440587.5 // Julian date of 1970-01-01T00:00Z
+	julian2000      = 2451545.0 // Julian date of 2000-01-01T12:00Z
+	earthTilt       = 23.4397   // axial tilt in degrees
+	sunAltitude     = -0.833    // altitude of the sun's upper limb at rise/set, incl. refraction
+	secondsPerDay   = 86400.0
+)
+
+// SunTimes computes sunrise and sunset for the calendar date of day at the
+// given coordinates. The returned times are in day's location.
+//
+// ok is false on days without a sunrise or sunset (polar day or night).
+// The result is accurate to about a minute, which matches what devices
+// compute from their own location settings.
+func SunTimes(day time.Time, lat, lng float64) (sunrise, sunset time.Time, ok bool) {
+	tz := day.Location()
+	noon := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, tz)
+	jd := float64(noon.Unix())/secondsPerDay + julianUnixEpoch
+
+	// Pick the solar noon nearest local noon. Rounding local noon alone
+	// lands on the wrong day where the UTC offset is far from the
+	// longitude's (e.g. UTC+13 or UTC+14).
+	n := math.Round(jd - julian2000 - 0.0008 + lng/360)
+	meanSolarNoon := n - lng/360
+
+	anomaly := normalizeDegrees(357.5291 + 0.98560028*meanSolarNoon)
+	m := rad(anomaly)
+	center := 1.9148*math.Sin(m) + 0.02*math.Sin(2*m) + 0.0003*math.Sin(3*m)
+	lambda := rad(normalizeDegrees(anomaly + center + 180 + 102.9372))
+
+	transit := julian2000 + meanSolarNoon + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*lambda)
+
+	sinDecl := math.Sin(lambda) * math.Sin(rad(earthTilt))
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	phi := rad(lat)
+	cosHourAngle := (math.Sin(rad(sunAltitude)) - math.Sin(phi)*sinDecl) / (math.Cos(phi) * cosDecl)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := deg(math.Acos(cosHourAngle))
+
+	sunrise = julianToTime(transit - hourAngle/360).In(tz)
+	sunset = julianToTime(transit + hourAngle/360).In(tz)
+	return sunrise, sunset, true
+}
+
+func julianToTime(jd float64) time.Time {
+	sec := (jd - julianUnixEpoch) * secondsPerDay
+	return time.Unix(0, int64(sec*float64(time.Second))).Truncate(time.Second)
+}
+
+func normalizeDegrees(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
+
+func rad(d float64) float64 { return d * math.Pi / 180 }
+
+func deg(r float64) float64 { return r * 180 / math.Pi }
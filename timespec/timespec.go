@@ -0,0 +1,283 @@
+package timespec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSearchYears bounds how far ahead Next searches for a fire time.
+const DefaultSearchYears = 5
+
+// Common errors.
+var (
+	// ErrInvalid indicates a timespec that does not parse.
+	ErrInvalid = errors.New("invalid timespec")
+
+	// ErrNoFireTime indicates a valid timespec that never fires within the
+	// search window (e.g. "0 0 0 30 2 *" or polar night for @sunrise).
+	ErrNoFireTime = errors.New("timespec never fires")
+
+	// ErrNoCoordinates indicates a @sunrise/@sunset timespec evaluated
+	// without a latitude and longitude.
+	ErrNoCoordinates = errors.New("sunrise/sunset requires location coordinates")
+)
+
+// SunEvent is the solar event a timespec is anchored to.
+type SunEvent string
+
+const (
+	// SunNone is a plain time-of-day timespec.
+	SunNone SunEvent = ""
+
+	// Sunrise anchors the timespec to local sunrise.
+	Sunrise SunEvent = "sunrise"
+
+	// Sunset anchors the timespec to local sunset.
+	Sunset SunEvent = "sunset"
+)
+
+// Spec is a parsed Shelly Gen2+ Schedule timespec.
+//
+// Two forms are supported:
+//
+//	ss mm hh DD MM WW                 e.g. "0 30 7 * * MON-FRI"
+//	@sunrise[±offset] DD MM WW        e.g. "@sunset-30m * * SAT,SUN"
+//
+// Fields accept "*", values, names (JAN-DEC, SUN-SAT), ranges ("1-5"),
+// lists ("1,3,5") and steps ("*/15", "0-30/10"). When both day-of-month
+// and weekday are restricted, a day must match both.
+type Spec struct {
+	sec, min, hour field
+	day, month, wd field
+
+	// Sun is the solar anchor, SunNone for plain times.
+	Sun SunEvent
+
+	// Offset is added to the sunrise/sunset time.
+	Offset time.Duration
+}
+
+// Parse parses a timespec.
+func Parse(text string) (*Spec, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalid)
+	}
+
+	s := &Spec{}
+	if strings.HasPrefix(fields[0], "@") {
+		if err := s.parseSun(fields[0]); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalid, text, err)
+		}
+		// "@sunrise" alone fires every day.
+		rest := fields[1:]
+		if len(rest) == 0 {
+			rest = []string{"*", "*", "*"}
+		}
+		if len(rest) != 3 {
+			return nil, fmt.Errorf("%w: %q: want @event DD MM WW", ErrInvalid, text)
+		}
+		fields = append([]string{"0", "0", "0"}, rest...)
+	} else if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: %q: want 6 fields (ss mm hh DD MM WW), got %d", ErrInvalid, text, len(fields))
+	}
+
+	targets := []*field{&s.sec, &s.min, &s.hour, &s.day, &s.month, &s.wd}
+	for i, target := range targets {
+		f, err := parseField(fields[i], fieldKind(i))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalid, text, err)
+		}
+		*target = f
+	}
+	return s, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(text string) *Spec {
+	s, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Validate reports whether text is a valid timespec.
+func Validate(text string) error {
+	_, err := Parse(text)
+	return err
+}
+
+// parseSun parses "@sunrise", "@sunset+1h30m", "@sunrise-45".
+// A bare number offset is in minutes.
+func (s *Spec) parseSun(token string) error {
+	body := strings.ToLower(token[1:])
+	idx := strings.IndexAny(body, "+-")
+	name, offset := body, ""
+	if idx >= 0 {
+		name, offset = body[:idx], body[idx:]
+	}
+
+	switch SunEvent(name) {
+	case Sunrise, Sunset:
+		s.Sun = SunEvent(name)
+	default:
+		return fmt.Errorf("unknown event %q", token)
+	}
+
+	if offset == "" {
+		return nil
+	}
+	sign := time.Duration(1)
+	if offset[0] == '-' {
+		sign = -1
+	}
+	value := offset[1:]
+	if n, err := strconv.Atoi(value); err == nil {
+		s.Offset = sign * time.Duration(n) * time.Minute
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid offset %q", offset)
+	}
+	s.Offset = sign * d
+	return nil
+}
+
+// String returns the timespec in canonical form.
+func (s *Spec) String() string {
+	dates := strings.Join([]string{s.day.text, s.month.text, s.wd.text}, " ")
+	if s.Sun == SunNone {
+		return strings.Join([]string{s.sec.text, s.min.text, s.hour.text, dates}, " ")
+	}
+	return "@" + string(s.Sun) + formatOffset(s.Offset) + " " + dates
+}
+
+// formatOffset formats a sun offset as "+1h30m", "-45m", "+10s".
+func formatOffset(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dh", h)
+	}
+	if m := (d % time.Hour) / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dm", m)
+	}
+	if sec := (d % time.Minute) / time.Second; sec > 0 {
+		fmt.Fprintf(&b, "%ds", sec)
+	}
+	return b.String()
+}
+
+// matchesDate reports whether the date matches the day, month and weekday fields.
+func (s *Spec) matchesDate(t time.Time) bool {
+	return s.month.has(int(t.Month())) && s.day.has(t.Day()) && s.wd.has(int(t.Weekday()))
+}
+
+// Next returns the first fire time strictly after after, evaluated in loc.
+//
+// loc supplies the timezone and, for @sunrise/@sunset, the coordinates.
+// A nil loc uses after's timezone and fails for sun timespecs.
+func (s *Spec) Next(after time.Time, loc *Location) (time.Time, error) {
+	times, err := s.NextN(after, 1, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return times[0], nil
+}
+
+// NextN returns the next n fire times strictly after after. It returns
+// nil if n is not positive.
+//
+// Fewer than n times are returned with ErrNoFireTime if the search window
+// (DefaultSearchYears) is exhausted.
+func (s *Spec) NextN(after time.Time, n int, loc *Location) ([]time.Time, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	if loc == nil {
+		loc = &Location{TZ: after.Location()}
+	}
+	if s.Sun != SunNone && !loc.HasCoordinates {
+		return nil, ErrNoCoordinates
+	}
+	tz := loc.timezone()
+
+	after = after.In(tz)
+	end := after.AddDate(DefaultSearchYears, 0, 0)
+	out := make([]time.Time, 0, n)
+
+	// Sun offsets can move a fire time onto the previous or next calendar
+	// day, so start one day early and filter by after.
+	start := after
+	if s.Sun != SunNone {
+		start = start.AddDate(0, 0, -1)
+	}
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, tz)
+
+	for ; len(out) < n && day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !s.matchesDate(day) {
+			continue
+		}
+		if s.Sun != SunNone {
+			if t, ok := s.sunTime(day, loc); ok && t.After(after) {
+				out = append(out, t)
+			}
+			continue
+		}
+		out = s.appendDayTimes(out, day, after, n)
+	}
+
+	if len(out) < n {
+		return out, fmt.Errorf("%w: %s within %d years", ErrNoFireTime, s, DefaultSearchYears)
+	}
+	return out, nil
+}
+
+// appendDayTimes appends the fire times on day that are after after.
+func (s *Spec) appendDayTimes(out []time.Time, day, after time.Time, n int) []time.Time {
+	y, m, d := day.Date()
+	for _, h := range s.hour.values() {
+		for _, mi := range s.min.values() {
+			for _, sec := range s.sec.values() {
+				t := time.Date(y, m, d, h, mi, sec, 0, day.Location())
+				// Skip wall-clock times that do not exist (DST spring forward).
+				if t.Hour() != h || t.Minute() != mi {
+					continue
+				}
+				if !t.After(after) {
+					continue
+				}
+				out = append(out, t)
+				if len(out) == n {
+					return out
+				}
+			}
+		}
+	}
+	return out
+}
+
+// sunTime returns the sunrise or sunset time plus offset on day.
+func (s *Spec) sunTime(day time.Time, loc *Location) (time.Time, bool) {
+	rise, set, ok := SunTimes(day, loc.Lat, loc.Lng)
+	if !ok {
+		return time.Time{}, false
+	}
+	t := rise
+	if s.Sun == Sunset {
+		t = set
+	}
+	return t.Add(s.Offset).In(day.Location()), true
+}
@@ -0,0 +1,337 @@
+package timespec
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{name: "daily", spec: "0 30 7 * * *", want: "0 30 7 * * *"},
+		{name: "extra whitespace", spec: "  0  30 7 * *   MON-FRI ", want: "0 30 7 * * MON-FRI"},
+		{name: "steps and lists", spec: "*/10 0-30/5 8,20 1-15 JAN,jul 0,7", want: "*/10 0-30/5 8,20 1-15 JAN,jul 0,7"},
+		{name: "sunrise", spec: "@sunrise", want: "@sunrise * * *"},
+		{name: "sunset offset", spec: "@sunset-30 * * SAT,SUN", want: "@sunset-30m * * SAT,SUN"},
+		{name: "sunrise duration offset", spec: "@SUNRISE+1h30m 1 * *", want: "@sunrise+1h30m 1 * *"},
+		{name: "weekday range to 7", spec: "0 0 8 * * 5-7", want: "0 0 8 * * 5-7"},
+		{name: "weekday range to SUN", spec: "0 0 8 * * FRI-SUN", want: "0 0 8 * * FRI-SUN"},
+		{name: "weekday range 0-7", spec: "0 0 8 * * 0-7", want: "0 0 8 * * 0-7"},
+		{name: "empty", spec: "", wantErr: true},
+		{name: "five fields", spec: "30 6 * * 1,2,3", wantErr: true},
+		{name: "second out of range", spec: "60 0 0 * * *", wantErr: true},
+		{name: "hour out of range", spec: "0 0 24 * * *", wantErr: true},
+		{name: "day zero", spec: "0 0 0 0 * *", wantErr: true},
+		{name: "bad name", spec: "0 0 0 * * FUN", wantErr: true},
+		{name: "reversed range", spec: "0 0 10-5 * * *", wantErr: true},
+		{name: "zero step", spec: "*/0 * * * * *", wantErr: true},
+		{name: "unknown event", spec: "@noon * * *", wantErr: true},
+		{name: "bad offset", spec: "@sunset+soon * * *", wantErr: true},
+		{name: "sun with wrong field count", spec: "@sunset * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse(tt.spec)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("Parse() error = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if spec.String() != tt.want {
+				t.Errorf("String() = %q, want %q", spec.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSpec_NextN(t *testing.T) {
+	utc := &Location{TZ: time.UTC}
+	after := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		name string
+		spec string
+		want []string
+	}{
+		{
+			name: "weekdays at 7:30",
+			spec: "0 30 7 * * MON-FRI",
+			want: []string{"2025-03-17T07:30:00Z", "2025-03-18T07:30:00Z"},
+		},
+		{
+			name: "every 20 minutes",
+			spec: "0 */20 * * * *",
+			want: []string{"2025-03-14T10:20:00Z", "2025-03-14T10:40:00Z", "2025-03-14T11:00:00Z"},
+		},
+		{
+			name: "seconds",
+			spec: "15,45 * * * * *",
+			want: []string{"2025-03-14T10:00:15Z", "2025-03-14T10:00:45Z", "2025-03-14T10:01:15Z"},
+		},
+		{
+			name: "day and weekday must both match",
+			spec: "0 0 12 13 * FRI",
+			want: []string{"2025-06-13T12:00:00Z", "2026-02-13T12:00:00Z"},
+		},
+		{
+			name: "leap day",
+			spec: "0 0 0 29 2 *",
+			want: []string{"2028-02-29T00:00:00Z"},
+		},
+		{
+			name: "weekday range ending on 7",
+			spec: "0 0 8 * * 5-7",
+			want: []string{"2025-03-15T08:00:00Z", "2025-03-16T08:00:00Z", "2025-03-21T08:00:00Z"},
+		},
+		{
+			name: "weekday range ending on SUN",
+			spec: "0 0 8 * * FRI-SUN",
+			want: []string{"2025-03-15T08:00:00Z", "2025-03-16T08:00:00Z", "2025-03-21T08:00:00Z"},
+		},
+		{
+			name: "weekday range 0-7",
+			spec: "0 0 8 * * 0-7",
+			want: []string{"2025-03-15T08:00:00Z", "2025-03-16T08:00:00Z", "2025-03-17T08:00:00Z"},
+		},
+		{
+			name: "exactly at after is excluded",
+			spec: "0 0 10 * * *",
+			want: []string{"2025-03-15T10:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MustParse(tt.spec).NextN(after, len(tt.want), utc)
+			if err != nil {
+				t.Fatalf("NextN() error = %v", err)
+			}
+			for i, w := range tt.want {
+				if got[i].Format(time.RFC3339) != w {
+					t.Errorf("time %d = %s, want %s", i, got[i].Format(time.RFC3339), w)
+				}
+			}
+		})
+	}
+}
+
+func TestSpec_NextNNonPositive(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		got, err := MustParse("0 0 8 * * *").NextN(time.Now(), n, nil)
+		if err != nil || got != nil {
+			t.Errorf("NextN(%d) = %v, %v; want nil, nil", n, got, err)
+		}
+	}
+}
+
+func TestSpec_NextNeverFires(t *testing.T) {
+	_, err := MustParse("0 0 0 30 2 *").Next(time.Now(), nil)
+	if !errors.Is(err, ErrNoFireTime) {
+		t.Errorf("error = %v, want ErrNoFireTime", err)
+	}
+}
+
+func TestSpec_NextTimezone(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	loc := &Location{TZ: berlin}
+
+	// 2025-03-30: clocks jump from 02:00 to 03:00, so 02:30 does not exist.
+	after := time.Date(2025, 3, 29, 12, 0, 0, 0, berlin)
+	got, err := MustParse("0 30 2 * * *").NextN(after, 2, loc)
+	if err != nil {
+		t.Fatalf("NextN() error = %v", err)
+	}
+	if got[0].Day() != 31 || got[0].Hour() != 2 || got[0].Minute() != 30 {
+		t.Errorf("first = %s, want 2025-03-31 02:30", got[0])
+	}
+	if got[0].Location() != berlin {
+		t.Errorf("location = %s, want Europe/Berlin", got[0].Location())
+	}
+
+	// The same spec evaluated in UTC fires at a different instant
+	// (Berlin is UTC+2 once summer time starts on the 30th).
+	inUTC, _ := MustParse("0 0 8 * * *").Next(after, &Location{TZ: time.UTC})
+	inBerlin, _ := MustParse("0 0 8 * * *").Next(after, loc)
+	if inUTC.Sub(inBerlin) != 2*time.Hour {
+		t.Errorf("UTC - Berlin = %s, want 2h", inUTC.Sub(inBerlin))
+	}
+}
+
+func TestSpec_NextSun(t *testing.T) {
+	london := mustLoad(t, "Europe/London")
+	loc := &Location{TZ: london, Lat: 51.5074, Lng: -0.1278, HasCoordinates: true}
+	after := time.Date(2024, 6, 20, 12, 0, 0, 0, london)
+
+	sunset, err := MustParse("@sunset").Next(after, loc)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	assertNear(t, "sunset", sunset, time.Date(2024, 6, 20, 21, 21, 0, 0, london))
+
+	before, err := MustParse("@sunset-1h30m").Next(after, loc)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if d := sunset.Sub(before); d < 89*time.Minute || d > 91*time.Minute {
+		t.Errorf("offset = %s, want ~1h30m", d)
+	}
+
+	// The next sunrise is tomorrow morning.
+	sunrise, err := MustParse("@sunrise").Next(after, loc)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	assertNear(t, "sunrise", sunrise, time.Date(2024, 6, 21, 4, 43, 0, 0, london))
+
+	if _, err := MustParse("@sunrise").Next(after, &Location{TZ: london}); !errors.Is(err, ErrNoCoordinates) {
+		t.Errorf("error = %v, want ErrNoCoordinates", err)
+	}
+
+	// Sun events keep their weekday in UTC+13.
+	auckland := mustLoad(t, "Pacific/Auckland")
+	aklLoc := &Location{TZ: auckland, Lat: -36.8485, Lng: 174.7633, HasCoordinates: true}
+	times, err := MustParse("@sunrise * * MON").NextN(time.Date(2024, 12, 18, 0, 0, 0, 0, auckland), 3, aklLoc)
+	if err != nil {
+		t.Fatalf("NextN() error = %v", err)
+	}
+	for _, tm := range times {
+		if tm.Weekday() != time.Monday {
+			t.Errorf("sunrise on Mondays = %s", tm)
+		}
+	}
+}
+
+func TestSunTimes(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+
+	rise, set, ok := SunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, ny), 40.7128, -74.0060)
+	if !ok {
+		t.Fatal("expected sunrise and sunset")
+	}
+	assertNear(t, "sunrise", rise, time.Date(2024, 12, 21, 7, 16, 0, 0, ny))
+	assertNear(t, "sunset", set, time.Date(2024, 12, 21, 16, 32, 0, 0, ny))
+
+	// Far-east offsets: the times are for the requested local date.
+	for _, tt := range []struct {
+		zone      string
+		lat, lng  float64
+		rise, set [2]int
+	}{
+		{"Pacific/Auckland", -36.8485, 174.7633, [2]int{5, 58}, [2]int{20, 40}},
+		{"Pacific/Kiritimati", 1.8721, -157.4278, [2]int{6, 27}, [2]int{18, 28}},
+	} {
+		loc := mustLoad(t, tt.zone)
+		rise, set, ok := SunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, loc), tt.lat, tt.lng)
+		if !ok {
+			t.Fatalf("%s: expected sunrise and sunset", tt.zone)
+		}
+		assertNear(t, tt.zone+" sunrise", rise, time.Date(2024, 12, 21, tt.rise[0], tt.rise[1], 0, 0, loc))
+		assertNear(t, tt.zone+" sunset", set, time.Date(2024, 12, 21, tt.set[0], tt.set[1], 0, 0, loc))
+	}
+
+	// Tromsø has no sunrise in December.
+	if _, _, ok := SunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 69.6492, 18.9553); ok {
+		t.Error("expected polar night")
+	}
+}
+
+func TestLocationFromSys(t *testing.T) {
+	tz, lat, lng := "Europe/Sofia", 42.6977, 23.3219
+	mustLoad(t, tz)
+
+	loc, err := LocationFromSys(&components.SysConfig{
+		Location: &components.SysLocationConfig{TZ: &tz, Lat: &lat, Lng: &lng},
+	})
+	if err != nil {
+		t.Fatalf("LocationFromSys() error = %v", err)
+	}
+	if loc.TZ.String() != tz || !loc.HasCoordinates || loc.Lat != lat || loc.Lng != lng {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+
+	empty, err := LocationFromSys(&components.SysConfig{})
+	if err != nil || empty.TZ != time.UTC || empty.HasCoordinates {
+		t.Errorf("empty config = %+v, %v", empty, err)
+	}
+
+	bad := "Nowhere/Land"
+	if _, err := LocationFromSys(&components.SysConfig{Location: &components.SysLocationConfig{TZ: &bad}}); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+		wantErr bool
+	}{
+		{name: "at", builder: At(7, 30), want: "0 30 7 * * *"},
+		{name: "at second", builder: AtSecond(23, 59, 30), want: "30 59 23 * * *"},
+		{
+			name:    "weekdays sorted and deduplicated",
+			builder: At(6, 0).Weekdays(time.Friday, time.Monday, time.Friday),
+			want:    "0 0 6 * * 1,5",
+		},
+		{name: "days and months", builder: At(0, 0).Days(1, 15).Months(time.December), want: "0 0 0 1,15 12 *"},
+		{name: "every 10s", builder: Every(10 * time.Second), want: "*/10 * * * * *"},
+		{name: "every 15m", builder: Every(15 * time.Minute), want: "0 */15 * * * *"},
+		{name: "every 6h", builder: Every(6 * time.Hour), want: "0 0 */6 * * *"},
+		{name: "every day", builder: Every(24 * time.Hour), want: "0 0 0 * * *"},
+		{name: "sunrise", builder: AtSunrise(time.Hour), want: "@sunrise+1h * * *"},
+		{name: "sunset", builder: AtSunset(-45 * time.Minute).Weekdays(time.Saturday), want: "@sunset-45m * * 6"},
+		{name: "bad hour", builder: At(25, 0), wantErr: true},
+		{name: "bad day", builder: At(0, 0).Days(32), wantErr: true},
+		{name: "uneven interval", builder: Every(7 * time.Minute), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := tt.builder.Build()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("Build() error = %v, want ErrInvalid", err)
+				}
+				if tt.builder.String() != "" {
+					t.Errorf("String() = %q, want empty", tt.builder.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if spec.String() != tt.want {
+				t.Errorf("String() = %q, want %q", spec.String(), tt.want)
+			}
+		})
+	}
+}
+
+func assertNear(t *testing.T, name string, got, want time.Time) {
+	t.Helper()
+	if d := got.Sub(want); d < -3*time.Minute || d > 3*time.Minute {
+		t.Errorf("%s = %s, want %s (±3m)", name, got, want)
+	}
+}
@@ -9,7 +9,8 @@
 //
 // By default, network-based methods (mDNS, CoIoT, HTTP probe) run.
 // BLE and WiFi scanning must be explicitly enabled as they require
-// special permissions and may take longer.
+// special permissions and may take longer. Interrupting the scan prints
+// the devices found so far.
 //
 // Usage:
 //
@@ -17,14 +18,18 @@
 //
 // Options:
 //
-//	-network string   Network CIDR to scan (default "192.168.1.0/24")
-//	-timeout duration Timeout per device (default 2s)
-//	-json             Output as JSON
-//	-mdns             Use mDNS discovery (default true, Gen2+)
-//	-coiot            Use CoIoT multicast discovery (default true, Gen1)
-//	-probe            HTTP probe all IPs (default true, finds all)
-//	-ble              Use BLE scanning (default false, requires bluetooth)
-//	-wifi             Scan for Shelly WiFi APs (default false, requires root)
+//	-network string      Comma-separated CIDRs, ranges or addresses to probe
+//	                     (default "192.168.1.0/24"), e.g. "10.0.0.0/24,10.0.1.10-50"
+//	-arp                 Probe hosts in the ARP table first (default true, Linux)
+//	-concurrency int     Addresses probed at once (default 50)
+//	-rate float          Max probes started per second (default 0, unlimited)
+//	-timeout duration    Timeout per device (default 2s)
+//	-json                Output as JSON
+//	-mdns                Use mDNS discovery (default true, Gen2+)
+//	-coiot               Use CoIoT multicast discovery (default true, Gen1)
+//	-probe               HTTP probe all IPs (default true, finds all)
+//	-ble                 Use BLE scanning (default false, requires bluetooth)
+//	-wifi                Scan for Shelly WiFi APs (default false, requires root)
 package main
 
 import (
@@ -32,12 +37,10 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"net"
-	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/tj-smith47/shelly-go/discovery"
@@ -104,8 +107,30 @@ type DiscoveredDevice struct {
 	AuthNeeded      bool   `json:"auth_needed,omitempty"`
 }
 
+// proberConfig holds the HTTP probe flags.
+type proberConfig struct {
+	rate        float64
+	timeout     time.Duration
+	concurrency int
+	arp         bool
+}
+
+// newProber creates a prober for targets, seeded from the ARP table if
+// seedARP is set and the -arp flag allows it.
+func (c *proberConfig) newProber(seedARP bool, targets ...string) *discovery.HTTPProber {
+	return discovery.NewHTTPProber(
+		discovery.WithTargets(targets...),
+		discovery.WithARPSeed(seedARP && c.arp),
+		discovery.WithConcurrency(c.concurrency),
+		discovery.WithRateLimit(c.rate),
+		discovery.WithProbeTimeout(c.timeout))
+}
+
 func main() {
-	network := flag.String("network", "192.168.1.0/24", "Network CIDR to scan")
+	network := flag.String("network", "192.168.1.0/24", "Comma-separated CIDRs, ranges or addresses to probe")
+	useARP := flag.Bool("arp", true, "Probe hosts in the ARP table first (Linux)")
+	concurrency := flag.Int("concurrency", 50, "Addresses probed at once")
+	rate := flag.Float64("rate", 0, "Max probes started per second (0 = unlimited)")
 	timeout := flag.Duration("timeout", 2*time.Second, "Timeout per device")
 	jsonOutput := flag.Bool("json", false, "Output as JSON")
 	useMDNS := flag.Bool("mdns", true, "Use mDNS discovery (Gen2+)")
@@ -115,7 +140,15 @@ func main() {
 	useWiFi := flag.Bool("wifi", false, "Scan for Shelly WiFi APs (may require root)")
 	flag.Parse()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+
+	probes := &proberConfig{
+		rate:        *rate,
+		timeout:     *timeout,
+		concurrency: *concurrency,
+		arp:         *useARP,
+	}
 
 	var devices []DiscoveredDevice
 
@@ -133,19 +166,21 @@ func main() {
 
 	if *useMDNS {
 		fmt.Fprintln(os.Stderr, "Scanning via mDNS (Gen2+)...")
-		mdnsDevices := discoverMDNS(ctx, *timeout)
+		mdnsDevices := discoverMulticast(ctx, discoveryMethodMDNS, *timeout*2, probes,
+			discovery.NewMDNSDiscoverer().DiscoverWithContext)
 		devices = append(devices, mdnsDevices...)
 	}
 
 	if *useCoIoT {
 		fmt.Fprintln(os.Stderr, "Scanning via CoIoT multicast (Gen1)...")
-		coiotDevices := discoverCoIoT(ctx, *timeout)
+		coiotDevices := discoverMulticast(ctx, discoveryMethodCoIoT, *timeout*2, probes,
+			discovery.NewCoIoTDiscoverer().DiscoverWithContext)
 		devices = append(devices, coiotDevices...)
 	}
 
 	if *useProbe {
 		fmt.Fprintln(os.Stderr, "Probing network range (fallback)...")
-		probeDevices := probeNetwork(ctx, *network, *timeout)
+		probeDevices := probeNetwork(ctx, strings.Split(*network, ","), probes)
 		devices = append(devices, probeDevices...)
 	}
 
@@ -162,6 +197,7 @@ func main() {
 
 	// Cancel before output - discovery is done
 	cancel()
+	stop()
 
 	if *jsonOutput {
 		enc := json.NewEncoder(os.Stdout)
@@ -189,21 +225,7 @@ func discoverBLE(ctx context.Context, timeout time.Duration) []DiscoveredDevice
 		fmt.Fprintf(os.Stderr, "BLE: Discovery error: %v\n", err)
 		return nil
 	}
-
-	devices := make([]DiscoveredDevice, 0, len(found))
-	for i := range found {
-		d := &found[i]
-		devices = append(devices, DiscoveredDevice{
-			IP:              "", // BLE devices don't have IP until provisioned
-			MAC:             d.MACAddress,
-			Model:           d.Model,
-			Name:            d.Name,
-			FWVersion:       d.Firmware,
-			DiscoveryMethod: discoveryMethodBLE,
-			Generation:      int(d.Generation),
-		})
-	}
-	return devices
+	return convertDevices(found, discoveryMethodBLE)
 }
 
 // discoverWiFiAPs uses the library's WiFi discoverer.
@@ -215,378 +237,122 @@ func discoverWiFiAPs(ctx context.Context) []DiscoveredDevice {
 		fmt.Fprintf(os.Stderr, "WiFi: Discovery error: %v\n", err)
 		return nil
 	}
-
-	devices := make([]DiscoveredDevice, 0, len(found))
-	for i := range found {
-		d := &found[i]
-		ip := ""
-		if d.Address != nil {
-			ip = d.Address.String()
-		}
-		devices = append(devices, DiscoveredDevice{
-			IP:              ip,
-			MAC:             d.MACAddress,
-			Model:           d.Model,
-			Name:            d.Name,
-			FWVersion:       d.Firmware,
-			DiscoveryMethod: discoveryMethodWiFi,
-			Generation:      int(d.Generation),
-		})
-	}
-	return devices
+	return convertDevices(found, discoveryMethodWiFi)
 }
 
-func discoverMDNS(ctx context.Context, timeout time.Duration) []DiscoveredDevice {
-	// Simple mDNS query for _shelly._tcp.local
-	// This is a simplified implementation - the library has a full one
-	var devices []DiscoveredDevice
-
-	// Create UDP socket for mDNS
-	addr, err := net.ResolveUDPAddr("udp4", "224.0.0.251:5353")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "mDNS resolve error: %v\n", err)
-		return devices
-	}
-
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+// discoverMulticast listens with a multicast discoverer for window, then
+// probes the responders over HTTP for full device info.
+func discoverMulticast(
+	ctx context.Context,
+	method string,
+	window time.Duration,
+	probes *proberConfig,
+	discover func(context.Context) ([]discovery.DiscoveredDevice, error),
+) []DiscoveredDevice {
+	listenCtx, cancel := context.WithTimeout(ctx, window)
+	found, err := discover(listenCtx)
+	cancel()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "mDNS listen error: %v\n", err)
-		return devices
-	}
-	defer conn.Close()
-
-	// Build mDNS query for _shelly._tcp.local
-	query := buildMDNSQuery("_shelly._tcp.local")
-	if _, err := conn.WriteTo(query, addr); err != nil {
-		fmt.Fprintf(os.Stderr, "mDNS write error: %v\n", err)
-		return devices
-	}
-
-	// Read responses
-	if err := conn.SetReadDeadline(time.Now().Add(timeout * 2)); err != nil {
-		fmt.Fprintf(os.Stderr, "mDNS deadline error: %v\n", err)
-		return devices
+		fmt.Fprintf(os.Stderr, "%s: Discovery error: %v\n", method, err)
+		return nil
 	}
 
-	seen := make(map[string]bool)
-	buf := make([]byte, 4096)
-	for {
-		n, remoteAddr, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			break // Timeout
-		}
-
-		ip := remoteAddr.IP.String()
-		if seen[ip] {
-			continue
-		}
-		seen[ip] = true
-
-		// Parse the response to extract service info
-		name := parseMDNSResponse(buf[:n])
-
-		// HTTP probe to get full device info
-		device := probeDevice(ctx, ip, timeout)
-		if device != nil {
-			if name != "" && device.Name == "" {
-				device.Name = name
-			}
-			device.DiscoveryMethod = discoveryMethodMDNS
-			devices = append(devices, *device)
+	var addresses []string
+	for i := range found {
+		if found[i].Address != nil {
+			addresses = append(addresses, found[i].Address.String())
 		}
 	}
-
-	return devices
-}
-
-func discoverCoIoT(ctx context.Context, timeout time.Duration) []DiscoveredDevice {
-	// CoIoT uses CoAP multicast on 224.0.1.187:5683 for Gen1 device discovery
-	var devices []DiscoveredDevice
-
-	addr, err := net.ResolveUDPAddr("udp4", "224.0.1.187:5683")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "CoIoT resolve error: %v\n", err)
-		return devices
+	if len(addresses) == 0 {
+		return convertDevices(found, method)
 	}
 
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	probed, err := probes.newProber(false, addresses...).DiscoverWithContext(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "CoIoT listen error: %v\n", err)
-		return devices
+		fmt.Fprintf(os.Stderr, "%s: Probe error: %v\n", method, err)
 	}
-	defer conn.Close()
-
-	// Send CoAP GET request for /cit/d (device description)
-	// CoAP header: Version=1, Type=NON, Code=GET, MsgID=random
-	coap := buildCoAPRequest()
-	if _, err := conn.WriteTo(coap, addr); err != nil {
-		fmt.Fprintf(os.Stderr, "CoIoT write error: %v\n", err)
-		return devices
-	}
-
-	if err := conn.SetReadDeadline(time.Now().Add(timeout * 2)); err != nil {
-		fmt.Fprintf(os.Stderr, "CoIoT deadline error: %v\n", err)
-		return devices
+	// Keep responders that didn't answer the probe with what they
+	// advertised.
+	answered := make(map[string]bool, len(probed))
+	for i := range probed {
+		answered[probed[i].Address.String()] = true
 	}
-
-	seen := make(map[string]bool)
-	buf := make([]byte, 4096)
-	for {
-		n, remoteAddr, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			break // Timeout
-		}
-
-		ip := remoteAddr.IP.String()
-		if seen[ip] {
-			continue
-		}
-		seen[ip] = true
-
-		// Parse CoAP response to check if it's a Shelly
-		if !isCoAPShellyResponse(buf[:n]) {
-			continue
-		}
-
-		// HTTP probe to get full device info
-		device := probeDevice(ctx, ip, timeout)
-		if device != nil {
-			device.DiscoveryMethod = discoveryMethodCoIoT
-			devices = append(devices, *device)
+	for i := range found {
+		if found[i].Address == nil || !answered[found[i].Address.String()] {
+			probed = append(probed, found[i])
 		}
 	}
-
-	return devices
-}
-
-// buildCoAPRequest builds a CoAP NON GET request for /cit/d.
-func buildCoAPRequest() []byte {
-	// CoAP Header: Ver=01, Type=01 (NON), TKL=0, Code=GET (0.01), MsgID=0x0001
-	// Uri-Path options: /cit/d
-	return []byte{
-		0x50, 0x01, // Header: NON GET
-		0x00, 0x01, // Message ID
-		0xB3,          // Uri-Path option: delta=11, length=3
-		'c', 'i', 't', // path segment "cit"
-		0x01, // Uri-Path option: delta=0, length=1
-		'd',  // path segment "d"
-	}
+	return convertDevices(probed, method)
 }
 
-// isCoAPShellyResponse checks if a CoAP response looks like a Shelly device.
-func isCoAPShellyResponse(data []byte) bool {
-	if len(data) < 4 {
-		return false
-	}
-	// Check for CoAP response (version 1, any type)
-	if (data[0] >> 6) != 1 {
-		return false
-	}
-	// Response should have code 2.05 (Content) = 0x45
-	if data[1] != 0x45 {
-		return false
+// probeNetwork sweeps the targets over HTTP, reporting devices as they
+// are found.
+func probeNetwork(ctx context.Context, targets []string, probes *proberConfig) []DiscoveredDevice {
+	ch, err := probes.newProber(true, targets...).Probe(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "HTTP: %v\n", err)
+		return nil
 	}
-	// Look for "shelly" or "SHSW" in payload
-	payload := string(data)
-	return strings.Contains(strings.ToLower(payload), "shelly") ||
-		strings.Contains(payload, "SHSW") ||
-		strings.Contains(payload, "SHDM") ||
-		strings.Contains(payload, "SHPLG") ||
-		strings.Contains(payload, "SHRGB")
-}
 
-func probeNetwork(ctx context.Context, cidr string, timeout time.Duration) []DiscoveredDevice {
-	ips := generateIPs(cidr)
 	var devices []DiscoveredDevice
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Limit concurrency
-	sem := make(chan struct{}, 50)
-
-	for _, ip := range ips {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(ip string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-
-			device := probeDevice(ctx, ip, timeout)
-			if device != nil {
-				device.DiscoveryMethod = discoveryMethodHTTP
-				mu.Lock()
-				devices = append(devices, *device)
-				mu.Unlock()
-			}
-		}(ip)
+	for d := range ch {
+		device := convertDevice(&d, discoveryMethodHTTP)
+		fmt.Fprintf(os.Stderr, "  found %s at %s\n", device.Model, device.IP)
+		devices = append(devices, device)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Probe interrupted; showing partial results.")
 	}
-
-	wg.Wait()
 	return devices
 }
 
-func probeDevice(ctx context.Context, ip string, timeout time.Duration) *DiscoveredDevice {
-	client := &http.Client{Timeout: timeout}
-
-	// Try Gen2+ endpoint first
-	gen2URL := fmt.Sprintf("http://%s/rpc/Shelly.GetDeviceInfo", ip)
-	reqCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, gen2URL, http.NoBody)
-	if err != nil {
-		return nil
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		// Try Gen1 endpoint
-		return probeGen1(ctx, client, ip, timeout)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		var info struct {
-			Name  string `json:"name"`
-			ID    string `json:"id"`
-			MAC   string `json:"mac"`
-			Model string `json:"model"`
-			FW    string `json:"fw_id"`
-			App   string `json:"app"`
-			Gen   int    `json:"gen"`
-			Auth  bool   `json:"auth_en"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&info); err == nil {
-			return &DiscoveredDevice{
-				IP:         ip,
-				MAC:        info.MAC,
-				Model:      info.Model,
-				App:        info.App,
-				Name:       info.Name,
-				Generation: info.Gen,
-				FWVersion:  info.FW,
-				AuthNeeded: info.Auth,
-			}
-		}
+func convertDevices(found []discovery.DiscoveredDevice, method string) []DiscoveredDevice {
+	devices := make([]DiscoveredDevice, 0, len(found))
+	for i := range found {
+		devices = append(devices, convertDevice(&found[i], method))
 	}
-
-	// Try Gen1 as fallback
-	return probeGen1(ctx, client, ip, timeout)
+	return devices
 }
 
-func probeGen1(ctx context.Context, client *http.Client, ip string, timeout time.Duration) *DiscoveredDevice {
-	gen1URL := fmt.Sprintf("http://%s/shelly", ip)
-	reqCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, gen1URL, http.NoBody)
-	if err != nil {
-		return nil
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		var info struct {
-			Type       string `json:"type"`
-			MAC        string `json:"mac"`
-			FW         string `json:"fw"`
-			Name       string `json:"name,omitempty"`
-			Auth       bool   `json:"auth"`
-			Discovable bool   `json:"discoverable"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&info); err == nil {
-			device := &DiscoveredDevice{
-				IP:         ip,
-				MAC:        info.MAC,
-				Model:      info.Type,
-				Name:       info.Name,
-				Generation: 1,
-				FWVersion:  info.FW,
-				AuthNeeded: info.Auth,
-			}
-
-			// Gen1 devices don't include name in /shelly response
-			// Fetch from /settings endpoint
-			if device.Name == "" {
-				device.Name = getGen1Name(ctx, client, ip, timeout)
-			}
-
-			return device
-		}
+// convertDevice converts a library result to the tool's output format.
+func convertDevice(d *discovery.DiscoveredDevice, method string) DiscoveredDevice {
+	ip := "" // BLE devices don't have IP until provisioned
+	if d.Address != nil {
+		ip = d.Address.String()
+	}
+	if d.Port != 0 && d.Port != 80 && ip != "" {
+		ip = fmt.Sprintf("%s:%d", ip, d.Port)
+	}
+	return DiscoveredDevice{
+		IP:              ip,
+		MAC:             d.MACAddress,
+		Model:           d.Model,
+		App:             appName(d.Raw),
+		Name:            d.Name,
+		FWVersion:       d.Firmware,
+		DiscoveryMethod: method,
+		Generation:      int(d.Generation),
+		AuthNeeded:      d.AuthRequired,
 	}
-
-	return nil
 }
 
-// getGen1Name fetches the device name from Gen1 /settings endpoint.
-func getGen1Name(ctx context.Context, client *http.Client, ip string, timeout time.Duration) string {
-	settingsURL := fmt.Sprintf("http://%s/settings", ip)
-	reqCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, settingsURL, http.NoBody)
-	if err != nil {
+// appName returns the "app" field of a Gen2+ /shelly response, the
+// friendly model name.
+func appName(raw any) string {
+	if raw == nil {
 		return ""
 	}
-
-	resp, err := client.Do(req)
+	data, err := json.Marshal(raw)
 	if err != nil {
 		return ""
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return ""
+	var info struct {
+		App string `json:"app"`
 	}
-
-	var settings map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+	if err := json.Unmarshal(data, &info); err != nil {
 		return ""
 	}
-
-	// Gen1 stores user-configured name at root level
-	if name, ok := settings["name"].(string); ok && name != "" {
-		return name
-	}
-
-	// Fall back to device hostname
-	if device, ok := settings["device"].(map[string]any); ok {
-		if hostname, ok := device["hostname"].(string); ok {
-			return hostname
-		}
-	}
-
-	return ""
-}
-
-func generateIPs(cidr string) []string {
-	var ips []string
-	baseIP, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return ips
-	}
-
-	for ip := baseIP.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-		// Skip network and broadcast addresses
-		if ip[len(ip)-1] != 0 && ip[len(ip)-1] != 255 {
-			ips = append(ips, ip.String())
-		}
-	}
-	return ips
-}
-
-func incrementIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
+	return info.App
 }
 
 func deduplicateDevices(devices []DiscoveredDevice) []DiscoveredDevice {
@@ -711,59 +477,3 @@ func formatModelDisplay(modelCode, app string, gen int) string {
 	}
 	return fmt.Sprintf("%s (%s)", friendlyName, modelCode)
 }
-
-// buildMDNSQuery builds a minimal DNS query packet.
-func buildMDNSQuery(name string) []byte {
-	// DNS header (12 bytes)
-	header := []byte{
-		0x00, 0x00, // Transaction ID
-		0x00, 0x00, // Flags (standard query)
-		0x00, 0x01, // Questions: 1
-		0x00, 0x00, // Answer RRs: 0
-		0x00, 0x00, // Authority RRs: 0
-		0x00, 0x00, // Additional RRs: 0
-	}
-
-	// Encode the name
-	parts := strings.Split(name, ".")
-	// Pre-allocate: each part needs 1 byte for length + part bytes + 1 null terminator
-	qnameLen := 1 // null terminator
-	for _, part := range parts {
-		qnameLen += 1 + len(part)
-	}
-	qname := make([]byte, 0, qnameLen)
-	for _, part := range parts {
-		qname = append(qname, byte(len(part)))
-		qname = append(qname, []byte(part)...)
-	}
-	qname = append(qname, 0x00) // Null terminator
-
-	// Question: PTR record (0x000c), IN class (0x0001)
-	question := []byte{0x00, 0x0c, 0x00, 0x01}
-
-	packet := make([]byte, 0, len(header)+len(qname)+len(question))
-	packet = append(packet, header...)
-	packet = append(packet, qname...)
-	packet = append(packet, question...)
-
-	return packet
-}
-
-// parseMDNSResponse extracts the service name from an mDNS response.
-func parseMDNSResponse(data []byte) string {
-	// Very basic parsing - just look for readable strings
-	for i := 0; i < len(data)-1; i++ {
-		if data[i] == 0 {
-			continue
-		}
-		// Look for length-prefixed strings that look like names
-		length := int(data[i])
-		if length > 0 && length < 64 && i+1+length <= len(data) {
-			s := string(data[i+1 : i+1+length])
-			if strings.HasPrefix(s, "shelly") || strings.HasPrefix(s, "Shelly") {
-				return s
-			}
-		}
-	}
-	return ""
-}
@@ -0,0 +1,133 @@
+// Offline static checker for Shelly scripts.
+//
+// This tool checks script files for constructs the Shelly runtime does not
+// support before they are uploaded, so it can run in CI without a device.
+//
+// Usage:
+//
+//	go run tools/scriptlint/main.go [options] file.js [file.js ...]
+//
+// Options:
+//
+//	-max-size int     Maximum script size in bytes (default 0, no limit)
+//	-methods string   File with one RPC method per line (or Shelly.ListMethods JSON)
+//	-disable string   Comma-separated rule IDs to skip
+//	-json             Output as JSON
+//	-strict           Treat warnings as errors
+//
+// The exit status is 1 if any file has errors, 2 on usage errors.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tj-smith47/shelly-go/profiles"
+	"github.com/tj-smith47/shelly-go/scripting/lint"
+)
+
+// fileResult is the JSON output for one file.
+type fileResult struct {
+	*lint.Result
+	File string `json:"file"`
+}
+
+func main() {
+	maxSize := flag.Int("max-size", 0, "Maximum script size in bytes (0 = no limit)")
+	methodsFile := flag.String("methods", "", "File with one RPC method per line (or Shelly.ListMethods JSON)")
+	disable := flag.String("disable", "", "Comma-separated rule IDs to skip")
+	jsonOutput := flag.Bool("json", false, "Output as JSON")
+	strict := flag.Bool("strict", false, "Treat warnings as errors")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: scriptlint [options] file.js [file.js ...]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	opts := &lint.Options{}
+	if *maxSize > 0 {
+		opts.Limits = &profiles.Limits{MaxScriptSize: *maxSize}
+	}
+	if *disable != "" {
+		opts.Disable = strings.Split(*disable, ",")
+	}
+	if *methodsFile != "" {
+		methods, err := loadMethods(*methodsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Methods = methods
+	}
+
+	failed := false
+	results := make([]fileResult, 0, flag.NArg())
+	for _, path := range flag.Args() {
+		code, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+
+		result := lint.Check(string(code), opts)
+		if result.HasErrors() || (*strict && len(result.Warnings()) > 0) {
+			failed = true
+		}
+		results = append(results, fileResult{File: path, Result: result})
+
+		if !*jsonOutput {
+			for _, issue := range result.Issues {
+				fmt.Printf("%s:%s\n", path, issue)
+			}
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// loadMethods reads a method list saved from Shelly.ListMethods, either as
+// the raw JSON response or as plain text with one method per line.
+func loadMethods(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var resp struct {
+			Methods []string `json:"methods"`
+		}
+		if err := json.Unmarshal(trimmed, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse methods file: %w", err)
+		}
+		return resp.Methods, nil
+	}
+
+	var methods []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			methods = append(methods, line)
+		}
+	}
+	return methods, scanner.Err()
+}
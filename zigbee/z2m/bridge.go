@@ -0,0 +1,420 @@
+package z2m
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+// DefaultBaseTopic is the Zigbee2MQTT base topic.
+const DefaultBaseTopic = "zigbee2mqtt"
+
+// ErrNotConnected is returned when publishing before Connect or after
+// Close.
+var ErrNotConnected = errors.New("bridge not connected")
+
+// Option configures a Bridge.
+type Option func(*Bridge)
+
+// WithBaseTopic sets the Zigbee2MQTT base topic.
+func WithBaseTopic(topic string) Option {
+	return func(b *Bridge) {
+		b.base = strings.TrimSuffix(topic, "/")
+	}
+}
+
+// WithCredentials sets the broker username and password.
+func WithCredentials(username, password string) Option {
+	return func(b *Bridge) {
+		b.opts.SetUsername(username)
+		b.opts.SetPassword(password)
+	}
+}
+
+// WithClientID sets the MQTT client ID.
+func WithClientID(id string) Option {
+	return func(b *Bridge) {
+		b.opts.SetClientID(id)
+	}
+}
+
+// WithMQTTClient uses an existing MQTT client instead of connecting to a
+// broker. The client must be connected; Close leaves it connected.
+func WithMQTTClient(client mqtt.Client) Option {
+	return func(b *Bridge) {
+		b.client = client
+		b.external = true
+	}
+}
+
+// WithEventBus publishes device state changes to bus as status change
+// events and availability as device online/offline events.
+func WithEventBus(bus *events.EventBus) Option {
+	return func(b *Bridge) {
+		b.bus = bus
+	}
+}
+
+// Bridge connects to the MQTT broker of a Zigbee2MQTT instance and exposes
+// the Shelly devices it manages.
+//
+// Shelly devices are recognized by IEEE address, registered with Register
+// or RegisterZigbee before or after pairing, or by their Zigbee2MQTT
+// manufacturer name.
+type Bridge struct {
+	client   mqtt.Client
+	opts     *mqtt.ClientOptions
+	bus      *events.EventBus
+	known    map[string]string
+	network  map[string]*bridgeDevice
+	devices  map[string]*Device
+	byName   map[string]*Device
+	changed  chan struct{}
+	base     string
+	mu       sync.RWMutex
+	external bool
+}
+
+// bridgeDevice is an entry of the bridge/devices topic.
+type bridgeDevice struct {
+	IEEEAddress  string `json:"ieee_address"`
+	FriendlyName string `json:"friendly_name"`
+	Type         string `json:"type"`
+	Manufacturer string `json:"manufacturer"`
+	ModelID      string `json:"model_id"`
+}
+
+// NewBridge creates a bridge for the broker, e.g. "tcp://localhost:1883".
+//
+// Example:
+//
+//	bridge := z2m.NewBridge("tcp://localhost:1883", z2m.WithEventBus(bus))
+//	bridge.Register("0x8c65a3fffe123456", "S4SW-001P16EU")
+//	if err := bridge.Connect(ctx); err != nil {
+//	    return err
+//	}
+//	defer bridge.Close()
+func NewBridge(broker string, opts ...Option) *Bridge {
+	b := &Bridge{
+		opts: mqtt.NewClientOptions().
+			AddBroker(broker).
+			SetClientID(fmt.Sprintf("shelly-go-z2m-%d", time.Now().UnixNano())).
+			SetAutoReconnect(true),
+		known:   make(map[string]string),
+		network: make(map[string]*bridgeDevice),
+		devices: make(map[string]*Device),
+		byName:  make(map[string]*Device),
+		changed: make(chan struct{}),
+		base:    DefaultBaseTopic,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Connect connects to the broker and subscribes to the Zigbee2MQTT
+// topics. The device list arrives asynchronously; use WaitForDevice to
+// wait for a device.
+func (b *Bridge) Connect(ctx context.Context) error {
+	if !b.external {
+		// Subscribing on connect also resubscribes after reconnects.
+		subscribed := make(chan error, 1)
+		b.opts.SetOnConnectHandler(func(c mqtt.Client) {
+			token := c.Subscribe(b.base+"/#", 0, b.handleMessage)
+			token.Wait()
+			select {
+			case subscribed <- token.Error():
+			default:
+			}
+		})
+		client := mqtt.NewClient(b.opts)
+		b.mu.Lock()
+		b.client = client
+		b.mu.Unlock()
+		// Don't leave an auto-reconnecting client behind on failure.
+		abort := func(err error) error {
+			b.mu.Lock()
+			if b.client == client {
+				b.client = nil
+			}
+			b.mu.Unlock()
+			client.Disconnect(0)
+			return err
+		}
+		if err := wait(ctx, client.Connect()); err != nil {
+			return abort(fmt.Errorf("failed to connect to broker: %w", err))
+		}
+		select {
+		case <-ctx.Done():
+			return abort(ctx.Err())
+		case err := <-subscribed:
+			if err != nil {
+				return abort(fmt.Errorf("failed to subscribe: %w", err))
+			}
+		}
+		return nil
+	}
+	if err := wait(ctx, b.client.Subscribe(b.base+"/#", 0, b.handleMessage)); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	return nil
+}
+
+// Close disconnects from the broker.
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	client := b.client
+	b.client = nil
+	b.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	if b.external {
+		client.Unsubscribe(b.base + "/#").Wait()
+		return nil
+	}
+	client.Disconnect(250)
+	return nil
+}
+
+// Register marks the device with the given IEEE address (EUI-64) as a
+// Shelly device of the given model.
+func (b *Bridge) Register(eui64, model string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.known[NormalizeEUI64(eui64)] = model
+	b.rebuild()
+}
+
+// RegisterZigbee registers a Shelly device by reading its EUI-64 over RPC,
+// typically right after zigbee.PairToNetwork. It returns the EUI-64.
+func (b *Bridge) RegisterZigbee(ctx context.Context, z *zigbee.Zigbee, model string) (string, error) {
+	eui64, err := z.GetEUI64(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get EUI64: %w", err)
+	}
+	if NormalizeEUI64(eui64) == "" {
+		return "", fmt.Errorf("invalid EUI64 %q", eui64)
+	}
+	b.Register(eui64, model)
+	return NormalizeEUI64(eui64), nil
+}
+
+// Devices returns the Shelly devices known to Zigbee2MQTT, ordered by
+// IEEE address.
+func (b *Bridge) Devices() []*Device {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]*Device, 0, len(b.devices))
+	for _, d := range b.devices {
+		out = append(out, d)
+	}
+	slices.SortFunc(out, func(a, b *Device) int { return strings.Compare(a.IEEEAddress, b.IEEEAddress) })
+	return out
+}
+
+// Device returns a Shelly device by IEEE address.
+func (b *Bridge) Device(eui64 string) (*Device, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	d, ok := b.devices[NormalizeEUI64(eui64)]
+	return d, ok
+}
+
+// WaitForDevice waits until the device with the given IEEE address is
+// known to Zigbee2MQTT, e.g. after pairing.
+func (b *Bridge) WaitForDevice(ctx context.Context, eui64 string) (*Device, error) {
+	for {
+		b.mu.RLock()
+		d, ok := b.devices[NormalizeEUI64(eui64)]
+		changed := b.changed
+		b.mu.RUnlock()
+		if ok {
+			return d, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// NormalizeEUI64 returns an EUI-64 in Zigbee2MQTT form ("0x" and 16
+// lower-case hex digits), accepting colon or dash separated forms. It
+// returns "" if s is not an EUI-64.
+func NormalizeEUI64(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "0x")
+	s = strings.NewReplacer(":", "", "-", "").Replace(s)
+	if len(s) != 16 {
+		return ""
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return ""
+		}
+	}
+	return "0x" + s
+}
+
+func (b *Bridge) handleMessage(_ mqtt.Client, msg mqtt.Message) {
+	topic := strings.TrimPrefix(msg.Topic(), b.base+"/")
+	switch {
+	case topic == "bridge/devices":
+		b.handleDevices(msg.Payload())
+	case strings.HasPrefix(topic, "bridge/"):
+	case strings.HasSuffix(topic, "/availability"):
+		b.handleAvailability(strings.TrimSuffix(topic, "/availability"), msg.Payload())
+	case strings.HasSuffix(topic, "/set"), strings.HasSuffix(topic, "/get"):
+	default:
+		b.handleState(topic, msg.Payload())
+	}
+}
+
+func (b *Bridge) handleDevices(payload []byte) {
+	var list []*bridgeDevice
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.network = make(map[string]*bridgeDevice, len(list))
+	for _, d := range list {
+		if ieee := NormalizeEUI64(d.IEEEAddress); ieee != "" && d.Type != "Coordinator" {
+			b.network[ieee] = d
+		}
+	}
+	b.rebuild()
+}
+
+// rebuild matches the network's devices against the registered ones. The
+// caller holds b.mu.
+func (b *Bridge) rebuild() {
+	devices := make(map[string]*Device)
+	for ieee, nd := range b.network {
+		model, ok := b.known[ieee]
+		if !ok && strings.Contains(strings.ToLower(nd.Manufacturer), "shelly") {
+			model, ok = nd.ModelID, true
+		}
+		if !ok {
+			continue
+		}
+		d, exists := b.devices[ieee]
+		if !exists || d.Model != model || d.FriendlyName != nd.FriendlyName {
+			d = &Device{
+				IEEEAddress:  ieee,
+				FriendlyName: nd.FriendlyName,
+				Model:        model,
+				Profile:      zigbee.GetDeviceProfile(model),
+				bridge:       b,
+				updated:      make(chan struct{}),
+			}
+		}
+		devices[ieee] = d
+	}
+
+	b.devices = devices
+	b.byName = make(map[string]*Device, len(devices))
+	for _, d := range devices {
+		b.byName[d.FriendlyName] = d
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *Bridge) handleAvailability(name string, payload []byte) {
+	b.mu.RLock()
+	d := b.byName[name]
+	b.mu.RUnlock()
+	if d == nil {
+		return
+	}
+
+	state := strings.TrimSpace(string(payload))
+	var msg struct {
+		State string `json:"state"`
+	}
+	if json.Unmarshal(payload, &msg) == nil {
+		state = msg.State
+	}
+	online := state == "online"
+
+	d.mu.Lock()
+	changed := d.available != online
+	d.available = online
+	d.mu.Unlock()
+
+	if b.bus == nil || !changed {
+		return
+	}
+	if online {
+		b.bus.Publish(events.NewDeviceOnlineEvent(d.IEEEAddress).WithSource(events.EventSourceMQTT))
+	} else {
+		b.bus.Publish(events.NewDeviceOfflineEvent(d.IEEEAddress).
+			WithReason("zigbee2mqtt reports offline").
+			WithSource(events.EventSourceMQTT))
+	}
+}
+
+func (b *Bridge) handleState(name string, payload []byte) {
+	b.mu.RLock()
+	d := b.byName[name]
+	b.mu.RUnlock()
+	if d == nil {
+		return
+	}
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.state = state
+	close(d.updated)
+	d.updated = make(chan struct{})
+	d.mu.Unlock()
+
+	if b.bus != nil {
+		d.publishStatus(b.bus)
+	}
+}
+
+func (b *Bridge) publish(ctx context.Context, d *Device, suffix string, payload map[string]any) error {
+	b.mu.RLock()
+	client := b.client
+	b.mu.RUnlock()
+	if client == nil {
+		return ErrNotConnected
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command: %w", err)
+	}
+	if err := wait(ctx, client.Publish(b.base+"/"+d.FriendlyName+suffix, 0, false, data)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", d.FriendlyName, err)
+	}
+	return nil
+}
+
+// wait waits for an MQTT token with context cancellation.
+func wait(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-token.Done():
+		return token.Error()
+	}
+}
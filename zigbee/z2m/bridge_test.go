@@ -0,0 +1,387 @@
+package z2m
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+)
+
+const testDevices = `[
+	{"ieee_address":"0x00124b0000000001","friendly_name":"Coordinator","type":"Coordinator"},
+	{"ieee_address":"0x8C65A3FFFE000001","friendly_name":"kitchen","type":"Router","manufacturer":"Espressif","model_id":"S3SW-001P16EU"},
+	{"ieee_address":"0x8c65a3fffe000002","friendly_name":"blinds","type":"Router","manufacturer":"Shelly","model_id":"S3SH-002P16EU"},
+	{"ieee_address":"0x8c65a3fffe000003","friendly_name":"hall","type":"Router","manufacturer":"Shelly","model_id":"S3DM-001P10EU"},
+	{"ieee_address":"0x0017880100000004","friendly_name":"bulb","type":"Router","manufacturer":"Signify","model_id":"LCT015"}
+]`
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func setup(t *testing.T, opts ...Option) (*testBroker, *Bridge) {
+	t.Helper()
+	broker := startBroker(t)
+	broker.Publish("zigbee2mqtt/bridge/devices", testDevices, true)
+
+	bridge := NewBridge(broker.URL(), opts...)
+	bridge.Register("8c:65:a3:ff:fe:00:00:01", "S3SW-001P16EU")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bridge.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { bridge.Close() })
+	if _, err := bridge.WaitForDevice(ctx, "0x8c65a3fffe000003"); err != nil {
+		t.Fatalf("WaitForDevice() error = %v", err)
+	}
+	return broker, bridge
+}
+
+// expectSet waits for a command published to a device's set topic.
+func expectSet(t *testing.T, broker *testBroker, name, want string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-broker.published:
+			if msg.topic != "zigbee2mqtt/"+name+"/set" {
+				continue
+			}
+			if string(msg.payload) != want {
+				t.Errorf("set payload = %s, want %s", msg.payload, want)
+			}
+			return
+		case <-timeout:
+			t.Fatalf("no command published to %s", name)
+		}
+	}
+}
+
+// waitState publishes a device state and waits until the bridge has it.
+func waitState(t *testing.T, broker *testBroker, d *Device, state string) {
+	t.Helper()
+	d.mu.Lock()
+	updated := d.updated
+	d.mu.Unlock()
+	broker.Publish("zigbee2mqtt/"+d.FriendlyName, state, false)
+	select {
+	case <-updated:
+	case <-time.After(2 * time.Second):
+		t.Fatal("state not received")
+	}
+}
+
+func TestNormalizeEUI64(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0x8c65a3fffe000001", "0x8c65a3fffe000001"},
+		{"8C:65:A3:FF:FE:00:00:01", "0x8c65a3fffe000001"},
+		{"8c-65-a3-ff-fe-00-00-01", "0x8c65a3fffe000001"},
+		{"0x8c65a3fffe0000", ""},
+		{"0x8c65a3fffe00000g", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeEUI64(tt.in); got != tt.want {
+			t.Errorf("NormalizeEUI64(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBridge_Devices(t *testing.T) {
+	_, bridge := setup(t)
+
+	devices := bridge.Devices()
+	if len(devices) != 3 {
+		t.Fatalf("Devices() = %d devices, want 3", len(devices))
+	}
+	tests := []struct {
+		ieee  string
+		name  string
+		model string
+		kind  ComponentKind
+	}{
+		{"0x8c65a3fffe000001", "kitchen", "S3SW-001P16EU", KindSwitch},
+		{"0x8c65a3fffe000002", "blinds", "S3SH-002P16EU", KindCover},
+		{"0x8c65a3fffe000003", "hall", "S3DM-001P10EU", KindLight},
+	}
+	for i, tt := range tests {
+		d := devices[i]
+		if d.IEEEAddress != tt.ieee || d.FriendlyName != tt.name || d.Model != tt.model || d.Kind() != tt.kind {
+			t.Errorf("device %d = %s %s %s %s, want %s %s %s %s", i,
+				d.IEEEAddress, d.FriendlyName, d.Model, d.Kind(), tt.ieee, tt.name, tt.model, tt.kind)
+		}
+	}
+	if _, ok := bridge.Device("0x0017880100000004"); ok {
+		t.Error("Device() found a non-Shelly device")
+	}
+}
+
+func TestBridge_RegisterAfterPairing(t *testing.T) {
+	_, bridge := setup(t)
+
+	bridge.Register("0x0017880100000004", "S3SW-001X8EU")
+	d, ok := bridge.Device("0x0017880100000004")
+	if !ok || d.Model != "S3SW-001X8EU" {
+		t.Fatalf("Device() = %v, %v after Register", d, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := bridge.WaitForDevice(ctx, "0x8c65a3fffe0000ff"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForDevice() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	broker, bridge := setup(t)
+	ctx := context.Background()
+	d, _ := bridge.Device("0x8c65a3fffe000001")
+	waitState(t, broker, d, `{"state":"ON","power":12.5,"voltage":230.1,"current":0.06,"energy":1.25}`)
+
+	sw := d.Switch(0)
+	status, err := sw.GetStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if !status.Output || *status.APower != 12.5 || *status.Voltage != 230.1 || status.AEnergy.Total != 1250 || status.Source != Source {
+		t.Errorf("GetStatus() = %+v", status)
+	}
+
+	result, err := sw.Set(ctx, &components.SwitchSetParams{On: ptr(false)})
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !result.WasOn {
+		t.Error("Set() WasOn = false, want true")
+	}
+	expectSet(t, broker, "kitchen", `{"state":"OFF"}`)
+
+	if _, err := sw.Toggle(ctx); err != nil {
+		t.Fatalf("Toggle() error = %v", err)
+	}
+	expectSet(t, broker, "kitchen", `{"state":"TOGGLE"}`)
+
+	if _, err := sw.Set(ctx, &components.SwitchSetParams{On: ptr(true), ToggleAfter: ptr(5.0)}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Set(toggle_after) error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestSwitch_MultiEndpoint(t *testing.T) {
+	broker, bridge := setup(t)
+	ctx := context.Background()
+	d, _ := bridge.Device("0x8c65a3fffe000001")
+	waitState(t, broker, d, `{"state_l1":"OFF","state_l2":"ON"}`)
+
+	status, err := d.Switch(1).GetStatus(ctx)
+	if err != nil || !status.Output || status.ID != 1 {
+		t.Fatalf("GetStatus() = %+v, %v", status, err)
+	}
+	if _, err := d.Switch(0).Set(ctx, &components.SwitchSetParams{On: ptr(true)}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	expectSet(t, broker, "kitchen", `{"state_l1":"ON"}`)
+}
+
+func TestDevice_Refresh(t *testing.T) {
+	broker, bridge := setup(t)
+	d, _ := bridge.Device("0x8c65a3fffe000001")
+
+	go func() {
+		for msg := range broker.published {
+			if msg.topic == "zigbee2mqtt/kitchen/get" {
+				broker.Publish("zigbee2mqtt/kitchen", `{"state":"ON"}`, false)
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	status, err := d.Switch(0).GetStatus(ctx)
+	if err != nil || !status.Output {
+		t.Fatalf("GetStatus() = %+v, %v", status, err)
+	}
+}
+
+func TestCover(t *testing.T) {
+	broker, bridge := setup(t)
+	ctx := context.Background()
+	d, _ := bridge.Device("0x8c65a3fffe000002")
+	cover := d.Cover(0)
+
+	tests := []struct {
+		state string
+		want  string
+		pos   int
+	}{
+		{`{"position":100,"moving":"STOP"}`, "open", 100},
+		{`{"position":0}`, "closed", 0},
+		{`{"position":40}`, "stopped", 40},
+		{`{"position":40,"moving":"UP"}`, "opening", 40},
+		{`{"position":40,"moving":"DOWN"}`, "closing", 40},
+	}
+	for _, tt := range tests {
+		waitState(t, broker, d, tt.state)
+		status, err := cover.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+		if status.State != tt.want || *status.CurrentPos != tt.pos {
+			t.Errorf("GetStatus(%s) = %s %d, want %s %d", tt.state, status.State, *status.CurrentPos, tt.want, tt.pos)
+		}
+	}
+
+	if err := cover.GoToPosition(ctx, 75); err != nil {
+		t.Fatalf("GoToPosition() error = %v", err)
+	}
+	expectSet(t, broker, "blinds", `{"position":75}`)
+	if err := cover.Open(ctx, nil); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	expectSet(t, broker, "blinds", `{"state":"OPEN"}`)
+	if err := cover.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	expectSet(t, broker, "blinds", `{"state":"STOP"}`)
+
+	if err := cover.GoToPosition(ctx, 101); err == nil {
+		t.Error("GoToPosition(101) error = nil")
+	}
+	if err := cover.Close(ctx, ptr(5.0)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Close(duration) error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestLight(t *testing.T) {
+	broker, bridge := setup(t)
+	ctx := context.Background()
+	d, _ := bridge.Device("0x8c65a3fffe000003")
+	waitState(t, broker, d, `{"state":"ON","brightness":127}`)
+
+	light := d.Light(0)
+	status, err := light.GetStatus(ctx)
+	if err != nil || !status.Output || *status.Brightness != 50 {
+		t.Fatalf("GetStatus() = %+v, %v", status, err)
+	}
+
+	result, err := light.Set(ctx, &components.LightSetParams{On: ptr(true), Brightness: ptr(100), TransitionDuration: ptr(2)})
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !*result.WasOn {
+		t.Error("Set() WasOn = false, want true")
+	}
+	expectSet(t, broker, "hall", `{"brightness":254,"state":"ON","transition":2}`)
+
+	if _, err := light.Set(ctx, &components.LightSetParams{Brightness: ptr(150)}); err == nil {
+		t.Error("Set(brightness 150) error = nil")
+	}
+}
+
+func TestBridge_Events(t *testing.T) {
+	bus := events.NewEventBus()
+	received := make(chan events.Event, 16)
+	bus.Subscribe(func(e events.Event) { received <- e })
+	broker, bridge := setup(t, WithEventBus(bus))
+	d, _ := bridge.Device("0x8c65a3fffe000002")
+
+	next := func() events.Event {
+		t.Helper()
+		select {
+		case e := <-received:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return nil
+		}
+	}
+
+	broker.Publish("zigbee2mqtt/blinds/availability", `{"state":"online"}`, false)
+	if e, ok := next().(*events.DeviceOnlineEvent); !ok || e.DeviceID() != d.IEEEAddress || e.Source() != events.EventSourceMQTT {
+		t.Errorf("event = %+v, want device online", e)
+	}
+
+	broker.Publish("zigbee2mqtt/blinds", `{"position":30,"moving":"DOWN"}`, false)
+	e, ok := next().(*events.StatusChangeEvent)
+	if !ok || e.Component != "cover:0" || e.DeviceID() != d.IEEEAddress {
+		t.Fatalf("event = %+v, want cover:0 status change", e)
+	}
+	var status components.CoverStatus
+	if err := json.Unmarshal(e.Status, &status); err != nil || status.State != "closing" || *status.CurrentPos != 30 {
+		t.Errorf("status = %+v, %v", status, err)
+	}
+
+	broker.Publish("zigbee2mqtt/blinds/availability", "offline", false)
+	if _, ok := next().(*events.DeviceOfflineEvent); !ok {
+		t.Error("want device offline event")
+	}
+	if d.Available() {
+		t.Error("Available() = true after offline")
+	}
+}
+
+func TestBridge_Closed(t *testing.T) {
+	_, bridge := setup(t)
+	d, _ := bridge.Device("0x8c65a3fffe000001")
+	bridge.Close()
+	if _, err := d.Switch(0).Toggle(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Toggle() error = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestBridge_ConnectTimeout(t *testing.T) {
+	// A broker that accepts CONNECT only after the caller gave up.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+		buf := make([]byte, 256)
+		_, _ = conn.Read(buf) // CONNECT
+		time.Sleep(200 * time.Millisecond)
+		_, _ = conn.Write([]byte{0x20, 0x02, 0x00, 0x00}) // CONNACK
+	}()
+
+	bridge := NewBridge("tcp://" + ln.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := bridge.Connect(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Connect() error = %v, want deadline exceeded", err)
+	}
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("client never connected")
+	}
+	defer conn.Close()
+
+	// The late connection is closed instead of kept alive.
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		t.Errorf("connection still open after Connect failed: %v", err)
+	}
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+	if bridge.client != nil {
+		t.Error("bridge kept the failed client")
+	}
+}
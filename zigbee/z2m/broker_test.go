@@ -0,0 +1,230 @@
+package z2m
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// message is a PUBLISH seen by the test broker.
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// testBroker is a minimal MQTT 3.1.1 broker standing in for Mosquitto:
+// QoS 0 delivery with retained messages and +/# wildcards.
+type testBroker struct {
+	ln        net.Listener
+	retained  map[string][]byte
+	clients   map[*brokerClient]struct{}
+	published chan message
+	mu        sync.Mutex
+}
+
+type brokerClient struct {
+	conn    net.Conn
+	filters []string
+	mu      sync.Mutex
+}
+
+func startBroker(t *testing.T) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b := &testBroker{
+		ln:        ln,
+		retained:  make(map[string][]byte),
+		clients:   make(map[*brokerClient]struct{}),
+		published: make(chan message, 64),
+	}
+	go b.accept()
+	t.Cleanup(func() {
+		ln.Close()
+		b.mu.Lock()
+		for c := range b.clients {
+			c.conn.Close()
+		}
+		b.mu.Unlock()
+	})
+	return b
+}
+
+func (b *testBroker) URL() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+// Publish publishes a message as if from another client, e.g.
+// Zigbee2MQTT.
+func (b *testBroker) Publish(topic, payload string, retain bool) {
+	b.route(topic, []byte(payload), retain)
+}
+
+func (b *testBroker) accept() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &brokerClient{conn: conn}
+		b.mu.Lock()
+		b.clients[c] = struct{}{}
+		b.mu.Unlock()
+		go b.serve(c)
+	}
+}
+
+func (b *testBroker) serve(c *brokerClient) {
+	defer func() {
+		c.conn.Close()
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+	r := bufio.NewReader(c.conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case 1: // CONNECT
+			c.write(0x20, []byte{0, 0})
+		case 3: // PUBLISH
+			topic, rest := readString(body)
+			if qos := (header >> 1) & 3; qos > 0 {
+				c.write(0x40, rest[:2])
+				rest = rest[2:]
+			}
+			b.published <- message{topic: topic, payload: rest}
+			b.route(topic, rest, header&1 == 1)
+		case 8: // SUBSCRIBE
+			id, rest := body[:2], body[2:]
+			var filters []string
+			for len(rest) > 0 {
+				var f string
+				f, rest = readString(rest)
+				filters = append(filters, f)
+				rest = rest[1:]
+			}
+			c.mu.Lock()
+			c.filters = append(c.filters, filters...)
+			c.mu.Unlock()
+			c.write(0x90, append(id, make([]byte, len(filters))...))
+			b.mu.Lock()
+			for topic, payload := range b.retained {
+				for _, f := range filters {
+					if match(f, topic) {
+						c.publish(topic, payload, true)
+						break
+					}
+				}
+			}
+			b.mu.Unlock()
+		case 10: // UNSUBSCRIBE
+			c.mu.Lock()
+			c.filters = nil
+			c.mu.Unlock()
+			c.write(0xb0, body[:2])
+		case 12: // PINGREQ
+			c.write(0xd0, nil)
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func (b *testBroker) route(topic string, payload []byte, retain bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if retain {
+		b.retained[topic] = payload
+	}
+	for c := range b.clients {
+		c.mu.Lock()
+		filters := c.filters
+		c.mu.Unlock()
+		for _, f := range filters {
+			if match(f, topic) {
+				c.publish(topic, payload, false)
+				break
+			}
+		}
+	}
+}
+
+func (c *brokerClient) publish(topic string, payload []byte, retain bool) {
+	header := byte(0x30)
+	if retain {
+		header |= 1
+	}
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+	body = append(body, topic...)
+	c.write(header, append(body, payload...))
+}
+
+func (c *brokerClient) write(header byte, body []byte) {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		packet = append(packet, d)
+		if n == 0 {
+			break
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.Write(append(packet, body...)) //nolint:errcheck // Client gone
+}
+
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	n, mult := 0, 1
+	for {
+		d, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n += int(d&0x7f) * mult
+		mult *= 128
+		if d&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func readString(b []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+// match reports whether a topic matches a filter with + and # wildcards.
+func match(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) || (f != "+" && f != ts[i]) {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
@@ -0,0 +1,434 @@
+package z2m
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tj-smith47/shelly-go/events"
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+// Source is the status source reported for state received from
+// Zigbee2MQTT.
+const Source = "zigbee2mqtt"
+
+// ErrUnsupported is returned for operations Zigbee2MQTT can't perform,
+// such as timed switching.
+var ErrUnsupported = errors.New("not supported over zigbee2mqtt")
+
+// ComponentKind is the Gen2 component type a device's state maps to.
+type ComponentKind string
+
+// Component kinds.
+const (
+	KindSwitch ComponentKind = "switch"
+	KindCover  ComponentKind = "cover"
+	KindLight  ComponentKind = "light"
+	KindNone   ComponentKind = ""
+)
+
+// Device is a Shelly device paired with the Zigbee2MQTT coordinator.
+type Device struct {
+	// Profile is the device's Zigbee profile, derived from its model.
+	Profile *zigbee.DeviceProfile
+
+	bridge  *Bridge
+	state   map[string]json.RawMessage
+	updated chan struct{}
+
+	// IEEEAddress is the device's EUI-64 in Zigbee2MQTT form.
+	IEEEAddress string
+
+	// FriendlyName is the Zigbee2MQTT name, used in topics.
+	FriendlyName string
+
+	// Model is the Shelly model code.
+	Model string
+
+	mu        sync.Mutex
+	available bool
+}
+
+// Kind returns the component type the device's state maps to, from its
+// Zigbee device type.
+func (d *Device) Kind() ComponentKind {
+	switch d.Profile.DeviceType {
+	case zigbee.DeviceTypeWindowCovering:
+		return KindCover
+	case zigbee.DeviceTypeLevelControllableOutput, zigbee.DeviceTypeOnOffLight,
+		zigbee.DeviceTypeDimmableLight, zigbee.DeviceTypeColorDimmableLight,
+		zigbee.DeviceTypeDimmerSwitch, zigbee.DeviceTypeColorDimmerSwitch:
+		return KindLight
+	case zigbee.DeviceTypeOnOffSwitch, zigbee.DeviceTypeOnOffLightSwitch:
+		return KindSwitch
+	default:
+		return KindNone
+	}
+}
+
+// Available reports whether Zigbee2MQTT last reported the device online.
+func (d *Device) Available() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.available
+}
+
+// State returns a copy of the last state published by Zigbee2MQTT, or nil
+// if none was received yet.
+func (d *Device) State() map[string]json.RawMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return maps.Clone(d.state)
+}
+
+// Refresh asks Zigbee2MQTT to read the device state and waits for the
+// update.
+func (d *Device) Refresh(ctx context.Context) error {
+	d.mu.Lock()
+	updated := d.updated
+	d.mu.Unlock()
+
+	if err := d.bridge.publish(ctx, d, "/get", map[string]any{"state": ""}); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-updated:
+		return nil
+	}
+}
+
+// Switch returns the device's switch component with the given ID.
+func (d *Device) Switch(id int) *Switch {
+	return &Switch{device: d, id: id}
+}
+
+// Cover returns the device's cover component with the given ID.
+func (d *Device) Cover(id int) *Cover {
+	return &Cover{device: d, id: id}
+}
+
+// Light returns the device's light component with the given ID.
+func (d *Device) Light(id int) *Light {
+	return &Light{device: d, id: id}
+}
+
+// key returns the state key of a component: the plain key for single
+// endpoint devices, or the "_l<n>" suffixed key for multi-endpoint
+// devices.
+func (d *Device) key(state map[string]json.RawMessage, name string, id int) string {
+	if _, ok := state[name+"_l1"]; ok || id > 0 {
+		return name + "_l" + strconv.Itoa(id+1)
+	}
+	return name
+}
+
+// componentIDs returns the IDs of the components present in the state.
+func componentIDs(state map[string]json.RawMessage) []int {
+	var ids []int
+	for k := range state {
+		if n, ok := strings.CutPrefix(k, "state_l"); ok {
+			if i, err := strconv.Atoi(n); err == nil && i > 0 {
+				ids = append(ids, i-1)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return []int{0}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// status returns the current state, refreshing it first if none was
+// received yet.
+func (d *Device) status(ctx context.Context) (map[string]json.RawMessage, error) {
+	if state := d.State(); state != nil {
+		return state, nil
+	}
+	if err := d.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+	return d.State(), nil
+}
+
+// publishStatus publishes the device's components as status change
+// events.
+func (d *Device) publishStatus(bus *events.EventBus) {
+	state := d.State()
+	kind := d.Kind()
+	if kind == KindNone {
+		return
+	}
+	for _, id := range componentIDs(state) {
+		var status any
+		switch kind {
+		case KindSwitch:
+			status = d.Switch(id).fromState(state)
+		case KindCover:
+			status = d.Cover(id).fromState(state)
+		case KindLight:
+			status = d.Light(id).fromState(state)
+		}
+		data, err := json.Marshal(status)
+		if err != nil {
+			continue
+		}
+		bus.Publish(events.NewStatusChangeEvent(d.IEEEAddress, fmt.Sprintf("%s:%d", kind, id), data).
+			WithSource(events.EventSourceMQTT))
+	}
+}
+
+// set publishes a command to the device.
+func (d *Device) set(ctx context.Context, payload map[string]any) error {
+	return d.bridge.publish(ctx, d, "/set", payload)
+}
+
+// Switch controls a switch output of a device over Zigbee2MQTT, mirroring
+// the Gen2 Switch component.
+type Switch struct {
+	device *Device
+	id     int
+}
+
+// Set turns the switch on or off and returns its previous state.
+func (s *Switch) Set(ctx context.Context, params *components.SwitchSetParams) (*components.SwitchSetResult, error) {
+	if params.ToggleAfter != nil {
+		return nil, fmt.Errorf("toggle_after: %w", ErrUnsupported)
+	}
+	if params.On == nil {
+		return nil, fmt.Errorf("on is required")
+	}
+	state := s.device.State()
+	key := s.device.key(state, "state", s.id)
+	if err := s.device.set(ctx, map[string]any{key: onOff(*params.On)}); err != nil {
+		return nil, err
+	}
+	return &components.SwitchSetResult{WasOn: stateOn(state, key)}, nil
+}
+
+// Toggle toggles the switch and returns its previous state.
+func (s *Switch) Toggle(ctx context.Context) (*components.SwitchToggleResult, error) {
+	state := s.device.State()
+	key := s.device.key(state, "state", s.id)
+	if err := s.device.set(ctx, map[string]any{key: "TOGGLE"}); err != nil {
+		return nil, err
+	}
+	return &components.SwitchToggleResult{WasOn: stateOn(state, key)}, nil
+}
+
+// GetStatus returns the switch status from the last reported state.
+func (s *Switch) GetStatus(ctx context.Context) (*components.SwitchStatus, error) {
+	state, err := s.device.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.fromState(state), nil
+}
+
+func (s *Switch) fromState(state map[string]json.RawMessage) *components.SwitchStatus {
+	status := &components.SwitchStatus{
+		ID:      s.id,
+		Source:  Source,
+		Output:  stateOn(state, s.device.key(state, "state", s.id)),
+		APower:  number(state, "power"),
+		Voltage: number(state, "voltage"),
+		Current: number(state, "current"),
+	}
+	if kwh := number(state, "energy"); kwh != nil {
+		status.AEnergy = &components.EnergyCounters{Total: *kwh * 1000}
+	}
+	return status
+}
+
+// Cover controls a cover of a device over Zigbee2MQTT, mirroring the Gen2
+// Cover component.
+type Cover struct {
+	device *Device
+	id     int
+}
+
+// Open opens the cover. Timed moves aren't supported; duration must be
+// nil.
+func (c *Cover) Open(ctx context.Context, duration *float64) error {
+	return c.move(ctx, "OPEN", duration)
+}
+
+// Close closes the cover. Timed moves aren't supported; duration must be
+// nil.
+func (c *Cover) Close(ctx context.Context, duration *float64) error {
+	return c.move(ctx, "CLOSE", duration)
+}
+
+// Stop stops the cover.
+func (c *Cover) Stop(ctx context.Context) error {
+	return c.move(ctx, "STOP", nil)
+}
+
+// GoToPosition moves the cover to a position, 0 (closed) to 100 (open).
+func (c *Cover) GoToPosition(ctx context.Context, pos int) error {
+	if pos < 0 || pos > 100 {
+		return fmt.Errorf("position must be 0-100, got %d", pos)
+	}
+	key := c.device.key(c.device.State(), "position", c.id)
+	return c.device.set(ctx, map[string]any{key: pos})
+}
+
+func (c *Cover) move(ctx context.Context, command string, duration *float64) error {
+	if duration != nil {
+		return fmt.Errorf("duration: %w", ErrUnsupported)
+	}
+	key := c.device.key(c.device.State(), "state", c.id)
+	return c.device.set(ctx, map[string]any{key: command})
+}
+
+// GetStatus returns the cover status from the last reported state.
+func (c *Cover) GetStatus(ctx context.Context) (*components.CoverStatus, error) {
+	state, err := c.device.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.fromState(state), nil
+}
+
+func (c *Cover) fromState(state map[string]json.RawMessage) *components.CoverStatus {
+	status := &components.CoverStatus{
+		ID:      c.id,
+		Source:  Source,
+		APower:  number(state, "power"),
+		Voltage: number(state, "voltage"),
+		Current: number(state, "current"),
+	}
+	if pos := number(state, c.device.key(state, "position", c.id)); pos != nil {
+		p := int(*pos)
+		status.CurrentPos = &p
+	}
+	switch str(state, c.device.key(state, "moving", c.id)) {
+	case "UP":
+		status.State = "opening"
+	case "DOWN":
+		status.State = "closing"
+	default:
+		switch {
+		case status.CurrentPos == nil:
+			status.State = "stopped"
+		case *status.CurrentPos >= 100:
+			status.State = "open"
+		case *status.CurrentPos <= 0:
+			status.State = "closed"
+		default:
+			status.State = "stopped"
+		}
+	}
+	return status
+}
+
+// Light controls a dimmable output of a device over Zigbee2MQTT, mirroring
+// the Gen2 Light component.
+type Light struct {
+	device *Device
+	id     int
+}
+
+// Set sets the light's output, brightness (0-100) and transition
+// duration (seconds) and returns its previous state.
+func (l *Light) Set(ctx context.Context, params *components.LightSetParams) (*components.LightSetResult, error) {
+	if params.ToggleAfter != nil {
+		return nil, fmt.Errorf("toggle_after: %w", ErrUnsupported)
+	}
+	state := l.device.State()
+	key := l.device.key(state, "state", l.id)
+	payload := make(map[string]any)
+	if params.On != nil {
+		payload[key] = onOff(*params.On)
+	}
+	if params.Brightness != nil {
+		if *params.Brightness < 0 || *params.Brightness > 100 {
+			return nil, fmt.Errorf("brightness must be 0-100, got %d", *params.Brightness)
+		}
+		payload[l.device.key(state, "brightness", l.id)] = (*params.Brightness*254 + 50) / 100
+	}
+	if params.TransitionDuration != nil {
+		payload["transition"] = *params.TransitionDuration
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("on or brightness is required")
+	}
+	if err := l.device.set(ctx, payload); err != nil {
+		return nil, err
+	}
+	wasOn := stateOn(state, key)
+	return &components.LightSetResult{WasOn: &wasOn}, nil
+}
+
+// Toggle toggles the light and returns its previous state.
+func (l *Light) Toggle(ctx context.Context) (*components.LightToggleResult, error) {
+	state := l.device.State()
+	key := l.device.key(state, "state", l.id)
+	if err := l.device.set(ctx, map[string]any{key: "TOGGLE"}); err != nil {
+		return nil, err
+	}
+	wasOn := stateOn(state, key)
+	return &components.LightToggleResult{WasOn: &wasOn}, nil
+}
+
+// GetStatus returns the light status from the last reported state.
+func (l *Light) GetStatus(ctx context.Context) (*components.LightStatus, error) {
+	state, err := l.device.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return l.fromState(state), nil
+}
+
+func (l *Light) fromState(state map[string]json.RawMessage) *components.LightStatus {
+	status := &components.LightStatus{
+		ID:      l.id,
+		Source:  Source,
+		Output:  stateOn(state, l.device.key(state, "state", l.id)),
+		APower:  number(state, "power"),
+		Voltage: number(state, "voltage"),
+		Current: number(state, "current"),
+	}
+	if b := number(state, l.device.key(state, "brightness", l.id)); b != nil {
+		pct := (int(*b)*100 + 127) / 254
+		status.Brightness = &pct
+	}
+	return status
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+func stateOn(state map[string]json.RawMessage, key string) bool {
+	return str(state, key) == "ON"
+}
+
+func str(state map[string]json.RawMessage, key string) string {
+	var s string
+	if err := json.Unmarshal(state[key], &s); err != nil {
+		return ""
+	}
+	return strings.ToUpper(s)
+}
+
+func number(state map[string]json.RawMessage, key string) *float64 {
+	var f float64
+	if err := json.Unmarshal(state[key], &f); err != nil {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,49 @@
+// Package z2m controls Shelly devices running in Zigbee mode through a
+// Zigbee2MQTT coordinator.
+//
+// Once a Gen4 device has joined a Zigbee network (see zigbee.PairToNetwork)
+// it is no longer reachable over its local RPC API; Zigbee2MQTT exposes it
+// on MQTT instead. A Bridge subscribes to the Zigbee2MQTT topics, finds the
+// Shelly devices in the coordinator's device list by IEEE address (EUI-64)
+// or manufacturer, and offers Switch, Cover and Light components with the
+// same methods and status types as the Gen2 components.
+//
+// # Basic Usage
+//
+//	bridge := z2m.NewBridge("tcp://localhost:1883")
+//	if err := bridge.Connect(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer bridge.Close()
+//
+//	// Register the device by its EUI-64, read over RPC before pairing
+//	eui64, err := bridge.RegisterZigbee(ctx, zigbee.NewZigbee(client), "S3SW-001P16EU")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	dev, err := bridge.WaitForDevice(ctx, eui64)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	_, err = dev.Switch(0).Set(ctx, &components.SwitchSetParams{On: ptr(true)})
+//
+// # Component Mapping
+//
+// The component type follows the device's Zigbee device type from
+// zigbee.GetDeviceProfile: window coverings map to Cover, dimmers and
+// lights to Light, and on/off devices to Switch. Multi-endpoint devices
+// use Zigbee2MQTT's "_l1", "_l2", ... suffixed keys, which map to
+// component IDs 0, 1, ...
+//
+// Light brightness is converted between the Gen2 range (0-100) and the
+// Zigbee range (0-254), and energy from kWh to Wh. Timed operations
+// (toggle_after, cover durations) return ErrUnsupported.
+//
+// # Events
+//
+// With WithEventBus, every state update is published as a status change
+// event for each component ("switch:0", "cover:0", ...) with the Gen2
+// status as payload, and availability changes as device online/offline
+// events. Events use the IEEE address as device ID and the MQTT source.
+package z2m
@@ -0,0 +1,103 @@
+package zcl
+
+import (
+	"fmt"
+
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+// Attribute identifiers used by the Gen2 mapping.
+const (
+	// On/Off cluster
+	AttrOnOff        uint16 = 0x0000
+	AttrStartUpOnOff uint16 = 0x4003
+
+	// Level Control cluster
+	AttrCurrentLevel uint16 = 0x0000
+	AttrOnLevel      uint16 = 0x0011
+
+	// Window Covering cluster
+	AttrLiftPercent100ths uint16 = 0x0003
+	AttrLiftPercentage    uint16 = 0x0008
+	AttrOperationalStatus uint16 = 0x000a
+
+	// Electrical Measurement cluster
+	AttrRMSVoltage          uint16 = 0x0505
+	AttrRMSCurrent          uint16 = 0x0508
+	AttrActivePower         uint16 = 0x050b
+	AttrPowerFactor         uint16 = 0x0510
+	AttrACVoltageMultiplier uint16 = 0x0600
+	AttrACVoltageDivisor    uint16 = 0x0601
+	AttrACCurrentMultiplier uint16 = 0x0602
+	AttrACCurrentDivisor    uint16 = 0x0603
+	AttrACPowerMultiplier   uint16 = 0x0604
+	AttrACPowerDivisor      uint16 = 0x0605
+
+	// Metering cluster
+	AttrSummationDelivered uint16 = 0x0000
+	AttrSummationReceived  uint16 = 0x0001
+	AttrUnitOfMeasure      uint16 = 0x0300
+	AttrMultiplier         uint16 = 0x0301
+	AttrDivisor            uint16 = 0x0302
+)
+
+// AttributeDef describes an attribute of a cluster.
+type AttributeDef struct {
+	Name       string
+	ID         uint16
+	Type       DataType
+	Readable   bool
+	Writable   bool
+	Reportable bool
+}
+
+// ClusterDef describes a cluster's attributes with their data types.
+type ClusterDef struct {
+	Name       string
+	Attributes []AttributeDef
+	ID         uint16
+}
+
+// Cluster returns the definition of a cluster from zigbee.ClusterMapping.
+func Cluster(id uint16) (*ClusterDef, error) {
+	capability := zigbee.GetClusterCapability(id)
+	if capability == nil {
+		return nil, fmt.Errorf("unknown cluster 0x%04x", id)
+	}
+	def := &ClusterDef{ID: id, Name: capability.ClusterName}
+	for _, a := range capability.Attributes {
+		t, err := ParseDataType(a.Type)
+		if err != nil {
+			return nil, fmt.Errorf("cluster 0x%04x attribute %s: %w", id, a.Name, err)
+		}
+		def.Attributes = append(def.Attributes, AttributeDef{
+			ID:         a.ID,
+			Name:       a.Name,
+			Type:       t,
+			Readable:   a.Readable,
+			Writable:   a.Writable,
+			Reportable: a.Reportable,
+		})
+	}
+	return def, nil
+}
+
+// Attribute returns the definition of an attribute by ID.
+func (c *ClusterDef) Attribute(id uint16) (*AttributeDef, bool) {
+	for i := range c.Attributes {
+		if c.Attributes[i].ID == id {
+			return &c.Attributes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Record returns an attribute record for a value, typed by the
+// attribute's definition.
+func (c *ClusterDef) Record(id uint16, value any) (AttributeRecord, error) {
+	a, ok := c.Attribute(id)
+	if !ok {
+		return AttributeRecord{}, fmt.Errorf("cluster %s has no attribute 0x%04x", c.Name, id)
+	}
+	return AttributeRecord{ID: id, Type: a.Type, Value: value}, nil
+}
@@ -0,0 +1,329 @@
+package zcl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors returned by the value codec.
+var (
+	// ErrUnsupportedType is returned for data types the codec can't encode
+	// or decode.
+	ErrUnsupportedType = errors.New("unsupported ZCL data type")
+
+	// ErrOutOfRange is returned when a value doesn't fit its data type.
+	ErrOutOfRange = errors.New("value out of range")
+
+	// ErrShortBuffer is returned when data ends before a value is complete.
+	ErrShortBuffer = errors.New("short buffer")
+)
+
+// DataType is a ZCL attribute data type identifier.
+type DataType uint8
+
+// ZCL data types.
+const (
+	TypeNoData      DataType = 0x00
+	TypeData8       DataType = 0x08
+	TypeData16      DataType = 0x09
+	TypeBool        DataType = 0x10
+	TypeBitmap8     DataType = 0x18
+	TypeBitmap16    DataType = 0x19
+	TypeBitmap32    DataType = 0x1b
+	TypeUint8       DataType = 0x20
+	TypeUint16      DataType = 0x21
+	TypeUint24      DataType = 0x22
+	TypeUint32      DataType = 0x23
+	TypeUint48      DataType = 0x25
+	TypeUint64      DataType = 0x27
+	TypeInt8        DataType = 0x28
+	TypeInt16       DataType = 0x29
+	TypeInt24       DataType = 0x2a
+	TypeInt32       DataType = 0x2b
+	TypeInt48       DataType = 0x2d
+	TypeInt64       DataType = 0x2f
+	TypeEnum8       DataType = 0x30
+	TypeEnum16      DataType = 0x31
+	TypeFloat32     DataType = 0x39
+	TypeFloat64     DataType = 0x3a
+	TypeOctetString DataType = 0x41
+	TypeCharString  DataType = 0x42
+)
+
+// dataTypeNames are the names used by zigbee.ClusterAttribute.Type.
+var dataTypeNames = map[DataType]string{
+	TypeNoData:      "nodata",
+	TypeData8:       "data8",
+	TypeData16:      "data16",
+	TypeBool:        "bool",
+	TypeBitmap8:     "bitmap8",
+	TypeBitmap16:    "bitmap16",
+	TypeBitmap32:    "bitmap32",
+	TypeUint8:       "uint8",
+	TypeUint16:      "uint16",
+	TypeUint24:      "uint24",
+	TypeUint32:      "uint32",
+	TypeUint48:      "uint48",
+	TypeUint64:      "uint64",
+	TypeInt8:        "int8",
+	TypeInt16:       "int16",
+	TypeInt24:       "int24",
+	TypeInt32:       "int32",
+	TypeInt48:       "int48",
+	TypeInt64:       "int64",
+	TypeEnum8:       "enum8",
+	TypeEnum16:      "enum16",
+	TypeFloat32:     "float32",
+	TypeFloat64:     "float64",
+	TypeOctetString: "octstr",
+	TypeCharString:  "string",
+}
+
+// ParseDataType returns the data type with the given name, e.g. "uint16"
+// or "enum8", as used in zigbee.ClusterAttribute.
+func ParseDataType(name string) (DataType, error) {
+	for t, n := range dataTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnsupportedType, name)
+}
+
+// String returns the data type name.
+func (t DataType) String() string {
+	if name, ok := dataTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+// Size returns the encoded size of a fixed-size data type in bytes, 0 for
+// length-prefixed strings, or -1 if the type is unsupported.
+func (t DataType) Size() int {
+	switch t {
+	case TypeNoData:
+		return 0
+	case TypeData8, TypeBool, TypeBitmap8, TypeUint8, TypeInt8, TypeEnum8:
+		return 1
+	case TypeData16, TypeBitmap16, TypeUint16, TypeInt16, TypeEnum16:
+		return 2
+	case TypeUint24, TypeInt24:
+		return 3
+	case TypeBitmap32, TypeUint32, TypeInt32, TypeFloat32:
+		return 4
+	case TypeUint48, TypeInt48:
+		return 6
+	case TypeUint64, TypeInt64, TypeFloat64:
+		return 8
+	case TypeOctetString, TypeCharString:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// IsSigned reports whether t is a signed integer type.
+func (t DataType) IsSigned() bool {
+	return t >= TypeInt8 && t <= TypeInt64
+}
+
+// AppendValue appends the little-endian encoding of v as type t to dst.
+//
+// Integer types accept any Go integer or an integral float; bool accepts
+// bool; float types accept any number; string types accept string or
+// []byte.
+func AppendValue(dst []byte, t DataType, v any) ([]byte, error) {
+	size := t.Size()
+	switch {
+	case size < 0:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	case t == TypeNoData:
+		return dst, nil
+	case t == TypeBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be bool, got %T", t, v)
+		}
+		if b {
+			return append(dst, 1), nil
+		}
+		return append(dst, 0), nil
+	case t == TypeFloat32 || t == TypeFloat64:
+		f, ok := ToFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a number, got %T", t, v)
+		}
+		if t == TypeFloat32 {
+			return binary.LittleEndian.AppendUint32(dst, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(dst, math.Float64bits(f)), nil
+	case t == TypeOctetString || t == TypeCharString:
+		var s []byte
+		switch v := v.(type) {
+		case string:
+			s = []byte(v)
+		case []byte:
+			s = v
+		default:
+			return nil, fmt.Errorf("%s value must be string or []byte, got %T", t, v)
+		}
+		if len(s) > 254 {
+			return nil, fmt.Errorf("%w: %s length %d", ErrOutOfRange, t, len(s))
+		}
+		return append(append(dst, byte(len(s))), s...), nil
+	}
+
+	bits := uint(size * 8)
+	var raw uint64
+	if t.IsSigned() {
+		n, ok := toInt(v)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be an integer, got %v", t, v)
+		}
+		if bits < 64 && (n < -1<<(bits-1) || n >= 1<<(bits-1)) {
+			return nil, fmt.Errorf("%w: %d for %s", ErrOutOfRange, n, t)
+		}
+		raw = uint64(n)
+	} else {
+		n, ok := toUint(v)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a non-negative integer, got %v", t, v)
+		}
+		if bits < 64 && n >= 1<<bits {
+			return nil, fmt.Errorf("%w: %d for %s", ErrOutOfRange, n, t)
+		}
+		raw = n
+	}
+	for i := range size {
+		dst = append(dst, byte(raw>>(8*i)))
+	}
+	return dst, nil
+}
+
+// DecodeValue decodes a value of type t from the start of b and returns
+// it with the number of bytes consumed.
+//
+// Unsigned integers, bitmaps and enums decode as uint64, signed integers
+// as int64, floats as float64, bool as bool, character strings as string
+// and octet strings as []byte.
+func DecodeValue(t DataType, b []byte) (any, int, error) {
+	size := t.Size()
+	switch {
+	case size < 0:
+		return nil, 0, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	case t == TypeOctetString || t == TypeCharString:
+		if len(b) < 1 {
+			return nil, 0, ErrShortBuffer
+		}
+		n := int(b[0])
+		if n == 0xff {
+			// Invalid (non-value) string
+			n = 0
+		}
+		if len(b) < 1+n {
+			return nil, 0, ErrShortBuffer
+		}
+		if t == TypeCharString {
+			return string(b[1 : 1+n]), 1 + n, nil
+		}
+		return append([]byte(nil), b[1:1+n]...), 1 + n, nil
+	case len(b) < size:
+		return nil, 0, ErrShortBuffer
+	}
+
+	var raw uint64
+	for i := range size {
+		raw |= uint64(b[i]) << (8 * i)
+	}
+	switch {
+	case t == TypeNoData:
+		return nil, 0, nil
+	case t == TypeBool:
+		return raw != 0, 1, nil
+	case t == TypeFloat32:
+		return float64(math.Float32frombits(uint32(raw))), 4, nil
+	case t == TypeFloat64:
+		return math.Float64frombits(raw), 8, nil
+	case t.IsSigned():
+		shift := uint(64 - size*8)
+		return int64(raw<<shift) >> shift, size, nil
+	default:
+		return raw, size, nil
+	}
+}
+
+// ToFloat converts a decoded or Go numeric value to float64; bool
+// converts to 0 or 1.
+func ToFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func toInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case bool:
+		return 0, false
+	}
+	f, ok := ToFloat(v)
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	if n, ok := v.(int64); ok {
+		return n, true
+	}
+	return int64(f), true
+}
+
+func toUint(v any) (uint64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return v, true
+	case int64:
+		return uint64(v), v >= 0
+	case bool:
+		return 0, false
+	}
+	f, ok := ToFloat(v)
+	if !ok || f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
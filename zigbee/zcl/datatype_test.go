@@ -0,0 +1,94 @@
+package zcl
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValueCodec(t *testing.T) {
+	tests := []struct {
+		in   any
+		want any
+		name string
+		data []byte
+		typ  DataType
+	}{
+		{name: "bool", typ: TypeBool, in: true, want: true, data: []byte{0x01}},
+		{name: "uint8", typ: TypeUint8, in: 200, want: uint64(200), data: []byte{0xc8}},
+		{name: "uint16", typ: TypeUint16, in: uint16(0x1234), want: uint64(0x1234), data: []byte{0x34, 0x12}},
+		{name: "uint24", typ: TypeUint24, in: 1000, want: uint64(1000), data: []byte{0xe8, 0x03, 0x00}},
+		{name: "uint48", typ: TypeUint48, in: int64(1) << 40, want: uint64(1) << 40, data: []byte{0, 0, 0, 0, 0, 1}},
+		{name: "int16 negative", typ: TypeInt16, in: -2, want: int64(-2), data: []byte{0xfe, 0xff}},
+		{name: "int24 negative", typ: TypeInt24, in: -100, want: int64(-100), data: []byte{0x9c, 0xff, 0xff}},
+		{name: "int8 from float", typ: TypeInt8, in: 95.0, want: int64(95), data: []byte{0x5f}},
+		{name: "enum8", typ: TypeEnum8, in: uint8(0xff), want: uint64(0xff), data: []byte{0xff}},
+		{name: "bitmap16", typ: TypeBitmap16, in: 0x0102, want: uint64(0x0102), data: []byte{0x02, 0x01}},
+		{name: "float32", typ: TypeFloat32, in: 1.5, want: 1.5, data: []byte{0, 0, 0xc0, 0x3f}},
+		{name: "string", typ: TypeCharString, in: "Shelly", want: "Shelly", data: []byte("\x06Shelly")},
+		{name: "octstr", typ: TypeOctetString, in: []byte{1, 2}, want: []byte{1, 2}, data: []byte{2, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := AppendValue(nil, tt.typ, tt.in)
+			if err != nil {
+				t.Fatalf("AppendValue() error = %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("AppendValue() = % x, want % x", data, tt.data)
+			}
+			got, n, err := DecodeValue(tt.typ, append(data, 0xaa))
+			if err != nil {
+				t.Fatalf("DecodeValue() error = %v", err)
+			}
+			if n != len(tt.data) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeValue() = %#v, %d, want %#v, %d", got, n, tt.want, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestAppendValue_Errors(t *testing.T) {
+	tests := []struct {
+		in   any
+		want error
+		name string
+		typ  DataType
+	}{
+		{name: "uint8 overflow", typ: TypeUint8, in: 256, want: ErrOutOfRange},
+		{name: "int16 overflow", typ: TypeInt16, in: 40000, want: ErrOutOfRange},
+		{name: "unknown type", typ: DataType(0xe0), in: 1, want: ErrUnsupportedType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := AppendValue(nil, tt.typ, tt.in); !errors.Is(err, tt.want) {
+				t.Errorf("AppendValue() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	for _, in := range []any{-1, 1.5, "1", true} {
+		if _, err := AppendValue(nil, TypeUint16, in); err == nil {
+			t.Errorf("AppendValue(uint16, %#v) error = nil", in)
+		}
+	}
+	if _, _, err := DecodeValue(TypeUint32, []byte{1, 2}); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("DecodeValue() error = %v, want ErrShortBuffer", err)
+	}
+}
+
+func TestParseDataType(t *testing.T) {
+	for typ, name := range dataTypeNames {
+		got, err := ParseDataType(name)
+		if err != nil || got != typ {
+			t.Errorf("ParseDataType(%q) = %v, %v, want %v", name, got, err, typ)
+		}
+		if typ.String() != name {
+			t.Errorf("String() = %q, want %q", typ.String(), name)
+		}
+	}
+	if _, err := ParseDataType("uint7"); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("ParseDataType(uint7) error = %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Package zcl implements the Zigbee Cluster Library attribute model and
+// maps Shelly Gen2 component status and config to ZCL clusters.
+//
+// # Data Model
+//
+// Cluster returns a cluster's attributes with their ZCL data types, built
+// from the descriptive tables in zigbee.ClusterMapping. AppendValue and
+// DecodeValue encode single attribute values; EncodeReport, DecodeReport
+// and the read response functions handle attribute record lists, and
+// Frame the ZCL frame header:
+//
+//	var f zcl.Frame
+//	if err := f.UnmarshalBinary(data); err != nil {
+//	    return err
+//	}
+//	if f.IsGlobal() && f.Command == zcl.CommandReportAttributes {
+//	    records, err := zcl.DecodeReport(f.Payload)
+//	    ...
+//	}
+//
+// # Gen2 Mapping
+//
+// The mapping functions translate in both directions between Gen2 types
+// and per-cluster Reports:
+//
+//	Switch.output         <-> On/Off OnOff
+//	Switch/Light initial_state <-> On/Off StartUpOnOff
+//	Light.brightness      <-> Level Control CurrentLevel (1-254)
+//	Light default_brightness <-> Level Control OnLevel
+//	Cover.current_pos     <-> Window Covering lift percentage (inverted)
+//	Cover.state           <-> Window Covering OperationalStatus
+//	apower/voltage/current/pf <-> Electrical Measurement, with divisors
+//	aenergy/ret_aenergy   <-> Metering summations
+//
+// For example, to report a switch status:
+//
+//	for _, r := range zcl.SwitchStatusToZCL(status) {
+//	    frame, err := zcl.NewReportFrame(seq, r.Records)
+//	    ...
+//	}
+//
+// Matter shares the cluster and attribute IDs of these clusters, so a
+// Matter bridge can reuse the mapping and encode the values with its own
+// TLV codec.
+package zcl
@@ -0,0 +1,201 @@
+package zcl
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Frame control bits.
+const (
+	// FrameTypeClusterSpecific marks a cluster-specific command; global
+	// (profile-wide) commands have the bit cleared.
+	FrameTypeClusterSpecific uint8 = 0x01
+
+	// FrameManufacturerSpecific marks a frame carrying a manufacturer
+	// code.
+	FrameManufacturerSpecific uint8 = 0x04
+
+	// FrameServerToClient marks a frame sent by the cluster server.
+	FrameServerToClient uint8 = 0x08
+
+	// FrameDisableDefaultResponse suppresses the default response.
+	FrameDisableDefaultResponse uint8 = 0x10
+)
+
+// Global command identifiers.
+const (
+	CommandReadAttributes          uint8 = 0x00
+	CommandReadAttributesResponse  uint8 = 0x01
+	CommandWriteAttributes         uint8 = 0x02
+	CommandWriteAttributesResponse uint8 = 0x04
+	CommandConfigureReporting      uint8 = 0x06
+	CommandReportAttributes        uint8 = 0x0a
+	CommandDefaultResponse         uint8 = 0x0b
+)
+
+// Status is a ZCL status code.
+type Status uint8
+
+// ZCL status codes.
+const (
+	StatusSuccess              Status = 0x00
+	StatusFailure              Status = 0x01
+	StatusUnsupportedAttribute Status = 0x86
+	StatusInvalidValue         Status = 0x87
+	StatusReadOnly             Status = 0x88
+	StatusInvalidDataType      Status = 0x8d
+)
+
+// Frame is a ZCL frame: header and command payload.
+type Frame struct {
+	Payload          []byte
+	ManufacturerCode uint16
+	FrameControl     uint8
+	Sequence         uint8
+	Command          uint8
+}
+
+// MarshalBinary encodes the frame.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	b := []byte{f.FrameControl}
+	if f.FrameControl&FrameManufacturerSpecific != 0 {
+		b = binary.LittleEndian.AppendUint16(b, f.ManufacturerCode)
+	}
+	b = append(b, f.Sequence, f.Command)
+	return append(b, f.Payload...), nil
+}
+
+// UnmarshalBinary decodes a frame.
+func (f *Frame) UnmarshalBinary(b []byte) error {
+	if len(b) < 3 {
+		return fmt.Errorf("frame: %w", ErrShortBuffer)
+	}
+	f.FrameControl = b[0]
+	b = b[1:]
+	f.ManufacturerCode = 0
+	if f.FrameControl&FrameManufacturerSpecific != 0 {
+		if len(b) < 4 {
+			return fmt.Errorf("frame: %w", ErrShortBuffer)
+		}
+		f.ManufacturerCode = binary.LittleEndian.Uint16(b)
+		b = b[2:]
+	}
+	f.Sequence, f.Command = b[0], b[1]
+	f.Payload = append([]byte(nil), b[2:]...)
+	return nil
+}
+
+// IsGlobal reports whether the frame carries a global command, such as an
+// attribute report.
+func (f *Frame) IsGlobal() bool {
+	return f.FrameControl&FrameTypeClusterSpecific == 0
+}
+
+// AttributeRecord is an attribute value in a read response, write or
+// report command.
+type AttributeRecord struct {
+	// Value is the decoded value; see DecodeValue for the Go types.
+	Value any
+
+	// ID is the attribute identifier.
+	ID uint16
+
+	// Type is the attribute's data type.
+	Type DataType
+
+	// Status is the read status; only used in read responses.
+	Status Status
+}
+
+// EncodeReport encodes records as a Report Attributes payload. Write
+// Attributes uses the same layout.
+func EncodeReport(records []AttributeRecord) ([]byte, error) {
+	var b []byte
+	for _, r := range records {
+		b = binary.LittleEndian.AppendUint16(b, r.ID)
+		b = append(b, byte(r.Type))
+		var err error
+		if b, err = AppendValue(b, r.Type, r.Value); err != nil {
+			return nil, fmt.Errorf("failed to encode attribute 0x%04x: %w", r.ID, err)
+		}
+	}
+	return b, nil
+}
+
+// DecodeReport decodes a Report Attributes or Write Attributes payload.
+func DecodeReport(b []byte) ([]AttributeRecord, error) {
+	var records []AttributeRecord
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, fmt.Errorf("attribute record: %w", ErrShortBuffer)
+		}
+		r := AttributeRecord{ID: binary.LittleEndian.Uint16(b), Type: DataType(b[2])}
+		v, n, err := DecodeValue(r.Type, b[3:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode attribute 0x%04x: %w", r.ID, err)
+		}
+		r.Value = v
+		records = append(records, r)
+		b = b[3+n:]
+	}
+	return records, nil
+}
+
+// EncodeReadResponse encodes records as a Read Attributes Response
+// payload. Records with a non-success status carry no value.
+func EncodeReadResponse(records []AttributeRecord) ([]byte, error) {
+	var b []byte
+	for _, r := range records {
+		b = binary.LittleEndian.AppendUint16(b, r.ID)
+		b = append(b, byte(r.Status))
+		if r.Status != StatusSuccess {
+			continue
+		}
+		b = append(b, byte(r.Type))
+		var err error
+		if b, err = AppendValue(b, r.Type, r.Value); err != nil {
+			return nil, fmt.Errorf("failed to encode attribute 0x%04x: %w", r.ID, err)
+		}
+	}
+	return b, nil
+}
+
+// DecodeReadResponse decodes a Read Attributes Response payload.
+func DecodeReadResponse(b []byte) ([]AttributeRecord, error) {
+	var records []AttributeRecord
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, fmt.Errorf("attribute record: %w", ErrShortBuffer)
+		}
+		r := AttributeRecord{ID: binary.LittleEndian.Uint16(b), Status: Status(b[2])}
+		b = b[3:]
+		if r.Status == StatusSuccess {
+			if len(b) < 1 {
+				return nil, fmt.Errorf("attribute record: %w", ErrShortBuffer)
+			}
+			r.Type = DataType(b[0])
+			v, n, err := DecodeValue(r.Type, b[1:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode attribute 0x%04x: %w", r.ID, err)
+			}
+			r.Value = v
+			b = b[1+n:]
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// NewReportFrame builds a server-to-client Report Attributes frame.
+func NewReportFrame(seq uint8, records []AttributeRecord) (*Frame, error) {
+	payload, err := EncodeReport(records)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{
+		FrameControl: FrameServerToClient | FrameDisableDefaultResponse,
+		Sequence:     seq,
+		Command:      CommandReportAttributes,
+		Payload:      payload,
+	}, nil
+}
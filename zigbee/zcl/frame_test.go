@@ -0,0 +1,110 @@
+package zcl
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+func TestFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		data  []byte
+	}{
+		{
+			name:  "report",
+			frame: Frame{FrameControl: 0x18, Sequence: 7, Command: CommandReportAttributes, Payload: []byte{0, 0, 0x10, 1}},
+			data:  []byte{0x18, 7, 0x0a, 0, 0, 0x10, 1},
+		},
+		{
+			name: "manufacturer specific",
+			frame: Frame{FrameControl: FrameTypeClusterSpecific | FrameManufacturerSpecific,
+				ManufacturerCode: 0x1490, Sequence: 1, Command: 0x02},
+			data: []byte{0x05, 0x90, 0x14, 1, 0x02},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.frame.MarshalBinary()
+			if err != nil || !bytes.Equal(data, tt.data) {
+				t.Fatalf("MarshalBinary() = % x, %v, want % x", data, err, tt.data)
+			}
+			var got Frame
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.frame) {
+				t.Errorf("UnmarshalBinary() = %+v, want %+v", got, tt.frame)
+			}
+		})
+	}
+
+	var f Frame
+	if err := f.UnmarshalBinary([]byte{0x04, 0x90, 0x14}); err == nil {
+		t.Error("UnmarshalBinary(short) error = nil")
+	}
+}
+
+func TestReport(t *testing.T) {
+	records := []AttributeRecord{
+		{ID: AttrOnOff, Type: TypeBool, Value: true},
+		{ID: AttrStartUpOnOff, Type: TypeEnum8, Value: uint64(StartUpPrevious)},
+	}
+	frame, err := NewReportFrame(3, records)
+	if err != nil {
+		t.Fatalf("NewReportFrame() error = %v", err)
+	}
+	want := []byte{0x00, 0x00, 0x10, 0x01, 0x03, 0x40, 0x30, 0xff}
+	if !bytes.Equal(frame.Payload, want) || !frame.IsGlobal() {
+		t.Fatalf("payload = % x, want % x", frame.Payload, want)
+	}
+	got, err := DecodeReport(frame.Payload)
+	if err != nil || !reflect.DeepEqual(got, records) {
+		t.Errorf("DecodeReport() = %+v, %v, want %+v", got, err, records)
+	}
+	if _, err := DecodeReport(want[:6]); err == nil {
+		t.Error("DecodeReport(truncated) error = nil")
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	records := []AttributeRecord{
+		{ID: AttrRMSVoltage, Type: TypeUint16, Value: uint64(2301)},
+		{ID: 0x0999, Status: StatusUnsupportedAttribute},
+		{ID: AttrActivePower, Type: TypeInt16, Value: int64(-15)},
+	}
+	data, err := EncodeReadResponse(records)
+	if err != nil {
+		t.Fatalf("EncodeReadResponse() error = %v", err)
+	}
+	got, err := DecodeReadResponse(data)
+	if err != nil || !reflect.DeepEqual(got, records) {
+		t.Errorf("DecodeReadResponse() = %+v, %v, want %+v", got, err, records)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	for id := range zigbee.ClusterMapping {
+		if _, err := Cluster(id); err != nil {
+			t.Errorf("Cluster(0x%04x) error = %v", id, err)
+		}
+	}
+
+	def, err := Cluster(zigbee.ClusterWindowCovering)
+	if err != nil {
+		t.Fatalf("Cluster() error = %v", err)
+	}
+	a, ok := def.Attribute(AttrLiftPercentage)
+	if !ok || a.Type != TypeUint8 || !a.Reportable {
+		t.Errorf("Attribute() = %+v, %v", a, ok)
+	}
+	if _, err := def.Record(0x0999, 1); err == nil {
+		t.Error("Record(unknown) error = nil")
+	}
+	if _, err := Cluster(0x1234); err == nil {
+		t.Error("Cluster(unknown) error = nil")
+	}
+}
@@ -0,0 +1,416 @@
+package zcl
+
+import (
+	"math"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+// Source is the status source set on Gen2 statuses built from ZCL
+// attributes.
+const Source = "zigbee"
+
+// Scaling used when encoding measurements. Decoding uses the multiplier
+// and divisor attributes of the report, so other devices' scaling is
+// handled too.
+const (
+	// VoltageDivisor reports RMS voltage in 0.1 V.
+	VoltageDivisor = 10
+
+	// CurrentDivisor reports RMS current in mA.
+	CurrentDivisor = 1000
+
+	// PowerDivisor reports active power in 0.1 W; powers beyond the int16
+	// range are reported in W with a divisor of 1.
+	PowerDivisor = 10
+
+	// EnergyDivisor reports summations in Wh with kWh as unit.
+	EnergyDivisor = 1000
+)
+
+// Operational status bits of the Window Covering cluster.
+const (
+	OperationalOpening uint8 = 0x05 // global and lift motor opening
+	OperationalClosing uint8 = 0x0a // global and lift motor closing
+)
+
+// StartUpOnOff values of the On/Off cluster.
+const (
+	StartUpOff      uint8 = 0x00
+	StartUpOn       uint8 = 0x01
+	StartUpToggle   uint8 = 0x02
+	StartUpPrevious uint8 = 0xff
+)
+
+// Report is a set of attribute values of one cluster, as carried by a
+// report or read response.
+type Report struct {
+	Records []AttributeRecord
+	Cluster uint16
+}
+
+// Value returns the value of an attribute in a set of reports.
+func Value(reports []Report, cluster, attr uint16) (any, bool) {
+	for _, r := range reports {
+		if r.Cluster != cluster {
+			continue
+		}
+		for _, rec := range r.Records {
+			if rec.ID == attr && rec.Status == StatusSuccess && rec.Value != nil {
+				return rec.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// number returns an attribute value as float64.
+func number(reports []Report, cluster, attr uint16) (float64, bool) {
+	v, ok := Value(reports, cluster, attr)
+	if !ok {
+		return 0, false
+	}
+	return ToFloat(v)
+}
+
+// scaled returns an attribute value times multiplier over divisor, with
+// ZCL's default of 1 for missing or zero scaling attributes.
+func scaled(reports []Report, cluster, attr, multAttr, divAttr uint16) (float64, bool) {
+	v, ok := number(reports, cluster, attr)
+	if !ok {
+		return 0, false
+	}
+	if m, ok := number(reports, cluster, multAttr); ok && m != 0 {
+		v *= m
+	}
+	if d, ok := number(reports, cluster, divAttr); ok && d != 0 {
+		v /= d
+	}
+	return v, true
+}
+
+// builder collects records per cluster, typed by zigbee.ClusterMapping.
+type builder struct {
+	reports []Report
+}
+
+func (b *builder) add(cluster, attr uint16, value any) {
+	def, err := Cluster(cluster)
+	if err != nil {
+		panic(err) // The mapping only uses clusters from zigbee.ClusterMapping
+	}
+	rec, err := def.Record(attr, value)
+	if err != nil {
+		panic(err)
+	}
+	for i := range b.reports {
+		if b.reports[i].Cluster == cluster {
+			b.reports[i].Records = append(b.reports[i].Records, rec)
+			return
+		}
+	}
+	b.reports = append(b.reports, Report{Cluster: cluster, Records: []AttributeRecord{rec}})
+}
+
+// electrical adds Electrical Measurement attributes for the measurements
+// that are set.
+func (b *builder) electrical(voltage, current, power, pf *float64) {
+	const c = zigbee.ClusterElectricalMeasurement
+	if voltage != nil {
+		b.add(c, AttrRMSVoltage, clamp(*voltage*VoltageDivisor, 0, math.MaxUint16))
+		b.add(c, AttrACVoltageMultiplier, 1)
+		b.add(c, AttrACVoltageDivisor, VoltageDivisor)
+	}
+	if current != nil {
+		b.add(c, AttrRMSCurrent, clamp(*current*CurrentDivisor, 0, math.MaxUint16))
+		b.add(c, AttrACCurrentMultiplier, 1)
+		b.add(c, AttrACCurrentDivisor, CurrentDivisor)
+	}
+	if power != nil {
+		div := PowerDivisor
+		if math.Abs(*power*PowerDivisor) > math.MaxInt16 {
+			div = 1
+		}
+		b.add(c, AttrActivePower, clamp(*power*float64(div), math.MinInt16, math.MaxInt16))
+		b.add(c, AttrACPowerMultiplier, 1)
+		b.add(c, AttrACPowerDivisor, div)
+	}
+	if pf != nil {
+		b.add(c, AttrPowerFactor, clamp(*pf*100, -100, 100))
+	}
+}
+
+// metering adds Metering summations in Wh; received may be nil.
+func (b *builder) metering(delivered float64, received *float64) {
+	const c = zigbee.ClusterMetering
+	b.add(c, AttrSummationDelivered, clamp(delivered, 0, 1<<48-1))
+	if received != nil {
+		b.add(c, AttrSummationReceived, clamp(*received, 0, 1<<48-1))
+	}
+	b.add(c, AttrUnitOfMeasure, 0) // kWh
+	b.add(c, AttrMultiplier, 1)
+	b.add(c, AttrDivisor, EnergyDivisor)
+}
+
+// electricalFromZCL returns voltage (V), current (A), active power (W) and
+// power factor (-1 to 1).
+func electricalFromZCL(reports []Report) (voltage, current, power, pf *float64) {
+	const c = zigbee.ClusterElectricalMeasurement
+	if v, ok := scaled(reports, c, AttrRMSVoltage, AttrACVoltageMultiplier, AttrACVoltageDivisor); ok {
+		voltage = &v
+	}
+	if v, ok := scaled(reports, c, AttrRMSCurrent, AttrACCurrentMultiplier, AttrACCurrentDivisor); ok {
+		current = &v
+	}
+	if v, ok := scaled(reports, c, AttrActivePower, AttrACPowerMultiplier, AttrACPowerDivisor); ok {
+		power = &v
+	}
+	if v, ok := number(reports, c, AttrPowerFactor); ok {
+		v /= 100
+		pf = &v
+	}
+	return voltage, current, power, pf
+}
+
+// energyFromZCL returns a Metering summation in Wh.
+func energyFromZCL(reports []Report, attr uint16) (float64, bool) {
+	kwh, ok := scaled(reports, zigbee.ClusterMetering, attr, AttrMultiplier, AttrDivisor)
+	return kwh * 1000, ok
+}
+
+// SwitchStatusToZCL maps a switch status to the On/Off cluster and, when
+// metered, the Electrical Measurement and Metering clusters.
+func SwitchStatusToZCL(s *components.SwitchStatus) []Report {
+	var b builder
+	b.add(zigbee.ClusterOnOff, AttrOnOff, s.Output)
+	b.electrical(s.Voltage, s.Current, s.APower, s.PF)
+	if s.AEnergy != nil {
+		b.metering(s.AEnergy.Total, nil)
+	}
+	return b.reports
+}
+
+// SwitchStatusFromZCL builds the status of switch id from ZCL attributes.
+func SwitchStatusFromZCL(id int, reports []Report) *components.SwitchStatus {
+	s := &components.SwitchStatus{ID: id, Source: Source}
+	if v, ok := number(reports, zigbee.ClusterOnOff, AttrOnOff); ok {
+		s.Output = v != 0
+	}
+	s.Voltage, s.Current, s.APower, s.PF = electricalFromZCL(reports)
+	if wh, ok := energyFromZCL(reports, AttrSummationDelivered); ok {
+		s.AEnergy = &components.EnergyCounters{Total: wh}
+	}
+	return s
+}
+
+// CoverStatusToZCL maps a cover status to the Window Covering cluster and,
+// when metered, the Electrical Measurement cluster.
+//
+// ZCL lift percentages count from fully open (0) to fully closed (100),
+// the inverse of the Gen2 position.
+func CoverStatusToZCL(s *components.CoverStatus) []Report {
+	const c = zigbee.ClusterWindowCovering
+	var b builder
+	if s.CurrentPos != nil {
+		lift := 100 - min(max(*s.CurrentPos, 0), 100)
+		b.add(c, AttrLiftPercentage, lift)
+		b.add(c, AttrLiftPercent100ths, lift*100)
+	}
+	var op uint8
+	switch s.State {
+	case "opening":
+		op = OperationalOpening
+	case "closing":
+		op = OperationalClosing
+	}
+	b.add(c, AttrOperationalStatus, op)
+	b.electrical(s.Voltage, s.Current, s.APower, s.PF)
+	return b.reports
+}
+
+// CoverStatusFromZCL builds the status of cover id from ZCL attributes.
+func CoverStatusFromZCL(id int, reports []Report) *components.CoverStatus {
+	const c = zigbee.ClusterWindowCovering
+	s := &components.CoverStatus{ID: id, Source: Source}
+	if lift, ok := number(reports, c, AttrLiftPercent100ths); ok {
+		pos := 100 - int(math.Round(lift/100))
+		s.CurrentPos = &pos
+	} else if lift, ok := number(reports, c, AttrLiftPercentage); ok {
+		pos := 100 - int(lift)
+		s.CurrentPos = &pos
+	}
+
+	op, _ := number(reports, c, AttrOperationalStatus)
+	switch {
+	case uint8(op)&0x03 == 0x01:
+		s.State = "opening"
+	case uint8(op)&0x03 == 0x02:
+		s.State = "closing"
+	case s.CurrentPos == nil:
+		s.State = "stopped"
+	case *s.CurrentPos >= 100:
+		s.State = "open"
+	case *s.CurrentPos <= 0:
+		s.State = "closed"
+	default:
+		s.State = "stopped"
+	}
+	s.Voltage, s.Current, s.APower, s.PF = electricalFromZCL(reports)
+	return s
+}
+
+// LightStatusToZCL maps a light status to the On/Off and Level Control
+// clusters and, when metered, the Electrical Measurement cluster.
+// Brightness 0-100 maps to levels 1-254.
+func LightStatusToZCL(s *components.LightStatus) []Report {
+	var b builder
+	b.add(zigbee.ClusterOnOff, AttrOnOff, s.Output)
+	if s.Brightness != nil {
+		b.add(zigbee.ClusterLevelControl, AttrCurrentLevel, brightnessToLevel(*s.Brightness))
+	}
+	b.electrical(s.Voltage, s.Current, s.APower, nil)
+	return b.reports
+}
+
+// LightStatusFromZCL builds the status of light id from ZCL attributes.
+func LightStatusFromZCL(id int, reports []Report) *components.LightStatus {
+	s := &components.LightStatus{ID: id, Source: Source}
+	if v, ok := number(reports, zigbee.ClusterOnOff, AttrOnOff); ok {
+		s.Output = v != 0
+	}
+	if level, ok := number(reports, zigbee.ClusterLevelControl, AttrCurrentLevel); ok {
+		brightness := levelToBrightness(level)
+		s.Brightness = &brightness
+	}
+	s.Voltage, s.Current, s.APower, _ = electricalFromZCL(reports)
+	return s
+}
+
+// PM1StatusToZCL maps a PM1 status to the Electrical Measurement and
+// Metering clusters; returned energy maps to the received summation.
+func PM1StatusToZCL(s *components.PM1Status) []Report {
+	var b builder
+	b.electrical(&s.Voltage, &s.Current, &s.APower, nil)
+	if s.AEnergy != nil {
+		var received *float64
+		if s.RetAEnergy != nil {
+			received = &s.RetAEnergy.Total
+		}
+		b.metering(s.AEnergy.Total, received)
+	}
+	return b.reports
+}
+
+// PM1StatusFromZCL builds the status of PM1 id from ZCL attributes.
+func PM1StatusFromZCL(id int, reports []Report) *components.PM1Status {
+	s := &components.PM1Status{ID: id}
+	voltage, current, power, _ := electricalFromZCL(reports)
+	if voltage != nil {
+		s.Voltage = *voltage
+	}
+	if current != nil {
+		s.Current = *current
+	}
+	if power != nil {
+		s.APower = *power
+	}
+	if wh, ok := energyFromZCL(reports, AttrSummationDelivered); ok {
+		s.AEnergy = &components.PM1EnergyCounters{Total: wh}
+	}
+	if wh, ok := energyFromZCL(reports, AttrSummationReceived); ok {
+		s.RetAEnergy = &components.PM1EnergyCounters{Total: wh}
+	}
+	return s
+}
+
+// SwitchConfigToZCL maps a switch's initial state to StartUpOnOff. The
+// "match_input" state has no ZCL equivalent and isn't mapped.
+func SwitchConfigToZCL(c *components.SwitchConfig) []Report {
+	var b builder
+	if c.InitialState != nil {
+		if v, ok := startUpOnOff(*c.InitialState); ok {
+			b.add(zigbee.ClusterOnOff, AttrStartUpOnOff, v)
+		}
+	}
+	return b.reports
+}
+
+// SwitchConfigFromZCL builds a switch config update from ZCL attributes;
+// fields without a ZCL value are nil.
+func SwitchConfigFromZCL(id int, reports []Report) *components.SwitchConfig {
+	c := &components.SwitchConfig{ID: id}
+	c.InitialState = initialState(reports)
+	return c
+}
+
+// LightConfigToZCL maps a light's initial state to StartUpOnOff and its
+// default brightness to OnLevel.
+func LightConfigToZCL(c *components.LightConfig) []Report {
+	var b builder
+	if c.InitialState != nil {
+		if v, ok := startUpOnOff(*c.InitialState); ok {
+			b.add(zigbee.ClusterOnOff, AttrStartUpOnOff, v)
+		}
+	}
+	if c.DefaultBrightness != nil {
+		b.add(zigbee.ClusterLevelControl, AttrOnLevel, brightnessToLevel(*c.DefaultBrightness))
+	}
+	return b.reports
+}
+
+// LightConfigFromZCL builds a light config update from ZCL attributes;
+// fields without a ZCL value are nil.
+func LightConfigFromZCL(id int, reports []Report) *components.LightConfig {
+	c := &components.LightConfig{ID: id}
+	c.InitialState = initialState(reports)
+	if level, ok := number(reports, zigbee.ClusterLevelControl, AttrOnLevel); ok && level != 0xff {
+		brightness := levelToBrightness(level)
+		c.DefaultBrightness = &brightness
+	}
+	return c
+}
+
+func startUpOnOff(state string) (uint8, bool) {
+	switch state {
+	case "off":
+		return StartUpOff, true
+	case "on":
+		return StartUpOn, true
+	case "restore_last":
+		return StartUpPrevious, true
+	default:
+		return 0, false
+	}
+}
+
+func initialState(reports []Report) *string {
+	v, ok := number(reports, zigbee.ClusterOnOff, AttrStartUpOnOff)
+	if !ok {
+		return nil
+	}
+	var state string
+	switch uint8(v) {
+	case StartUpOff:
+		state = "off"
+	case StartUpOn:
+		state = "on"
+	case StartUpPrevious:
+		state = "restore_last"
+	default:
+		return nil
+	}
+	return &state
+}
+
+func brightnessToLevel(brightness int) int {
+	return max(1, (min(max(brightness, 0), 100)*254+50)/100)
+}
+
+func levelToBrightness(level float64) int {
+	return int(math.Round(min(max(level, 0), 254) * 100 / 254))
+}
+
+func clamp(v, lo, hi float64) int64 {
+	return int64(math.Round(min(max(v, lo), hi)))
+}
@@ -0,0 +1,212 @@
+package zcl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tj-smith47/shelly-go/gen2/components"
+	"github.com/tj-smith47/shelly-go/zigbee"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// wire encodes and decodes reports as Report Attributes payloads, as a
+// bridge would.
+func wire(t *testing.T, reports []Report) []Report {
+	t.Helper()
+	out := make([]Report, 0, len(reports))
+	for _, r := range reports {
+		data, err := EncodeReport(r.Records)
+		if err != nil {
+			t.Fatalf("EncodeReport(0x%04x) error = %v", r.Cluster, err)
+		}
+		records, err := DecodeReport(data)
+		if err != nil {
+			t.Fatalf("DecodeReport(0x%04x) error = %v", r.Cluster, err)
+		}
+		out = append(out, Report{Cluster: r.Cluster, Records: records})
+	}
+	return out
+}
+
+func near(a *float64, b float64) bool {
+	return a != nil && math.Abs(*a-b) < 0.01
+}
+
+func TestSwitchStatus(t *testing.T) {
+	in := &components.SwitchStatus{
+		ID: 1, Output: true,
+		APower: ptr(1234.5), Voltage: ptr(230.4), Current: ptr(5.36), PF: ptr(0.97),
+		AEnergy: &components.EnergyCounters{Total: 12345.6},
+	}
+	reports := wire(t, SwitchStatusToZCL(in))
+
+	if v, ok := Value(reports, zigbee.ClusterOnOff, AttrOnOff); !ok || v != true {
+		t.Errorf("OnOff = %v, %v", v, ok)
+	}
+	if v, _ := Value(reports, zigbee.ClusterElectricalMeasurement, AttrActivePower); v != int64(12345) {
+		t.Errorf("ActivePower = %v, want 12345", v)
+	}
+
+	out := SwitchStatusFromZCL(1, reports)
+	if !out.Output || out.ID != 1 || out.Source != Source {
+		t.Errorf("SwitchStatusFromZCL() = %+v", out)
+	}
+	if !near(out.APower, 1234.5) || !near(out.Voltage, 230.4) || !near(out.Current, 5.36) || !near(out.PF, 0.97) {
+		t.Errorf("measurements = %v %v %v %v", *out.APower, *out.Voltage, *out.Current, *out.PF)
+	}
+	if out.AEnergy == nil || out.AEnergy.Total != 12346 {
+		t.Errorf("AEnergy = %+v, want 12346 Wh", out.AEnergy)
+	}
+
+	// Unmetered switches only map On/Off
+	if reports := SwitchStatusToZCL(&components.SwitchStatus{}); len(reports) != 1 {
+		t.Errorf("unmetered reports = %v", reports)
+	}
+}
+
+func TestActivePower_Range(t *testing.T) {
+	tests := []struct {
+		power float64
+		raw   int64
+		div   uint64
+	}{
+		{100.25, 1003, 10},
+		{-50, -500, 10},
+		{3680, 3680, 1},
+		{-4000, -4000, 1},
+	}
+	for _, tt := range tests {
+		reports := wire(t, SwitchStatusToZCL(&components.SwitchStatus{APower: ptr(tt.power)}))
+		raw, _ := Value(reports, zigbee.ClusterElectricalMeasurement, AttrActivePower)
+		div, _ := Value(reports, zigbee.ClusterElectricalMeasurement, AttrACPowerDivisor)
+		if raw != tt.raw || div != tt.div {
+			t.Errorf("power %v = %v / %v, want %v / %v", tt.power, raw, div, tt.raw, tt.div)
+		}
+	}
+}
+
+func TestCoverStatus(t *testing.T) {
+	tests := []struct {
+		state string
+		want  string
+		pos   int
+		lift  uint64
+		op    uint64
+	}{
+		{state: "open", pos: 100, lift: 0, op: 0, want: "open"},
+		{state: "closed", pos: 0, lift: 100, op: 0, want: "closed"},
+		{state: "stopped", pos: 30, lift: 70, op: 0, want: "stopped"},
+		{state: "opening", pos: 30, lift: 70, op: uint64(OperationalOpening), want: "opening"},
+		{state: "closing", pos: 60, lift: 40, op: uint64(OperationalClosing), want: "closing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			reports := wire(t, CoverStatusToZCL(&components.CoverStatus{State: tt.state, CurrentPos: ptr(tt.pos)}))
+			if v, _ := Value(reports, zigbee.ClusterWindowCovering, AttrLiftPercentage); v != tt.lift {
+				t.Errorf("lift = %v, want %v", v, tt.lift)
+			}
+			if v, _ := Value(reports, zigbee.ClusterWindowCovering, AttrOperationalStatus); v != tt.op {
+				t.Errorf("operational status = %v, want %v", v, tt.op)
+			}
+			out := CoverStatusFromZCL(0, reports)
+			if out.State != tt.want || *out.CurrentPos != tt.pos {
+				t.Errorf("CoverStatusFromZCL() = %s %d, want %s %d", out.State, *out.CurrentPos, tt.want, tt.pos)
+			}
+		})
+	}
+
+	// Devices reporting only the percentage
+	reports := []Report{{Cluster: zigbee.ClusterWindowCovering, Records: []AttributeRecord{
+		{ID: AttrLiftPercentage, Type: TypeUint8, Value: uint64(25)},
+	}}}
+	if out := CoverStatusFromZCL(0, reports); *out.CurrentPos != 75 || out.State != "stopped" {
+		t.Errorf("CoverStatusFromZCL() = %s %d", out.State, *out.CurrentPos)
+	}
+}
+
+func TestLightStatus(t *testing.T) {
+	tests := []struct {
+		brightness int
+		level      uint64
+	}{
+		{0, 1},
+		{1, 3},
+		{50, 127},
+		{100, 254},
+	}
+	for _, tt := range tests {
+		reports := wire(t, LightStatusToZCL(&components.LightStatus{Output: true, Brightness: ptr(tt.brightness)}))
+		if v, _ := Value(reports, zigbee.ClusterLevelControl, AttrCurrentLevel); v != tt.level {
+			t.Errorf("brightness %d level = %v, want %v", tt.brightness, v, tt.level)
+		}
+		out := LightStatusFromZCL(0, reports)
+		if !out.Output || (tt.brightness > 0 && *out.Brightness != tt.brightness) {
+			t.Errorf("LightStatusFromZCL() = %v %d, want %d", out.Output, *out.Brightness, tt.brightness)
+		}
+	}
+}
+
+func TestPM1Status(t *testing.T) {
+	in := &components.PM1Status{
+		ID: 0, Voltage: 229.9, Current: 0.45, APower: -98.2,
+		AEnergy:    &components.PM1EnergyCounters{Total: 500},
+		RetAEnergy: &components.PM1EnergyCounters{Total: 1200},
+	}
+	reports := wire(t, PM1StatusToZCL(in))
+	out := PM1StatusFromZCL(0, reports)
+	if !near(&out.Voltage, 229.9) || !near(&out.Current, 0.45) || !near(&out.APower, -98.2) {
+		t.Errorf("PM1StatusFromZCL() = %v V %v A %v W", out.Voltage, out.Current, out.APower)
+	}
+	if out.AEnergy.Total != 500 || out.RetAEnergy.Total != 1200 {
+		t.Errorf("energy = %v / %v", out.AEnergy.Total, out.RetAEnergy.Total)
+	}
+
+	// Other devices' scaling: power in mW, energy in Wh with kWh divisor
+	reports = []Report{
+		{Cluster: zigbee.ClusterElectricalMeasurement, Records: []AttributeRecord{
+			{ID: AttrActivePower, Type: TypeInt16, Value: int64(1500)},
+			{ID: AttrACPowerDivisor, Type: TypeUint16, Value: uint64(1000)},
+		}},
+		{Cluster: zigbee.ClusterMetering, Records: []AttributeRecord{
+			{ID: AttrSummationDelivered, Type: TypeUint48, Value: uint64(42)},
+		}},
+	}
+	out = PM1StatusFromZCL(0, reports)
+	if out.APower != 1.5 || out.AEnergy.Total != 42000 {
+		t.Errorf("PM1StatusFromZCL() = %v W %v Wh", out.APower, out.AEnergy.Total)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		state   string
+		startUp uint64
+		mapped  bool
+	}{
+		{"off", uint64(StartUpOff), true},
+		{"on", uint64(StartUpOn), true},
+		{"restore_last", uint64(StartUpPrevious), true},
+		{"match_input", 0, false},
+	}
+	for _, tt := range tests {
+		reports := wire(t, SwitchConfigToZCL(&components.SwitchConfig{InitialState: ptr(tt.state)}))
+		v, ok := Value(reports, zigbee.ClusterOnOff, AttrStartUpOnOff)
+		if ok != tt.mapped || (ok && v != tt.startUp) {
+			t.Errorf("%s StartUpOnOff = %v, %v", tt.state, v, ok)
+			continue
+		}
+		out := SwitchConfigFromZCL(0, reports)
+		if tt.mapped != (out.InitialState != nil) || (tt.mapped && *out.InitialState != tt.state) {
+			t.Errorf("SwitchConfigFromZCL() InitialState = %v, want %s", out.InitialState, tt.state)
+		}
+	}
+
+	reports := wire(t, LightConfigToZCL(&components.LightConfig{InitialState: ptr("on"), DefaultBrightness: ptr(40)}))
+	out := LightConfigFromZCL(0, reports)
+	if *out.InitialState != "on" || *out.DefaultBrightness != 40 {
+		t.Errorf("LightConfigFromZCL() = %s %d", *out.InitialState, *out.DefaultBrightness)
+	}
+}
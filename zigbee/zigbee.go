@@ -532,6 +532,8 @@ var ClusterMapping = map[uint16]ClusterCapability{
 				Reportable: false},
 			{ID: 0x4002, Name: "OffWaitTime", Type: "uint16", Readable: true, Writable: true,
 				Reportable: false},
+			{ID: 0x4003, Name: "StartUpOnOff", Type: "enum8", Readable: true, Writable: true,
+				Reportable: false},
 		},
 		Commands: []ClusterCommand{
 			{ID: 0x00, Name: "Off", Direction: "client_to_server"},
@@ -552,6 +554,8 @@ var ClusterMapping = map[uint16]ClusterCapability{
 				Writable: true, Reportable: false},
 			{ID: 0x0011, Name: "OnLevel", Type: "uint8", Readable: true, Writable: true,
 				Reportable: false},
+			{ID: 0x4000, Name: "StartUpCurrentLevel", Type: "uint8", Readable: true,
+				Writable: true, Reportable: false},
 		},
 		Commands: []ClusterCommand{
 			{ID: 0x00, Name: "MoveToLevel", Direction: "client_to_server"},
@@ -674,6 +678,18 @@ var ClusterMapping = map[uint16]ClusterCapability{
 				Reportable: true},
 			{ID: 0x0510, Name: "PowerFactor", Type: "int8", Readable: true, Writable: false,
 				Reportable: true},
+			{ID: 0x0600, Name: "ACVoltageMultiplier", Type: "uint16", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0601, Name: "ACVoltageDivisor", Type: "uint16", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0602, Name: "ACCurrentMultiplier", Type: "uint16", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0603, Name: "ACCurrentDivisor", Type: "uint16", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0604, Name: "ACPowerMultiplier", Type: "uint16", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0605, Name: "ACPowerDivisor", Type: "uint16", Readable: true,
+				Writable: false, Reportable: false},
 		},
 	},
 	ClusterMetering: {
@@ -685,6 +701,12 @@ var ClusterMapping = map[uint16]ClusterCapability{
 				Writable: false, Reportable: true},
 			{ID: 0x0001, Name: "CurrentSummationReceived", Type: "uint48", Readable: true,
 				Writable: false, Reportable: true},
+			{ID: 0x0300, Name: "UnitOfMeasure", Type: "enum8", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0301, Name: "Multiplier", Type: "uint24", Readable: true,
+				Writable: false, Reportable: false},
+			{ID: 0x0302, Name: "Divisor", Type: "uint24", Readable: true,
+				Writable: false, Reportable: false},
 			{ID: 0x0400, Name: "InstantaneousDemand", Type: "int24", Readable: true,
 				Writable: false, Reportable: true},
 		},
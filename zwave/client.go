@@ -0,0 +1,776 @@
+package zwave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/tj-smith47/shelly-go/events"
+)
+
+// DefaultSchemaVersion is the zwave-js-server API schema version the
+// client requests, or the server's maximum if that is lower.
+const DefaultSchemaVersion = 35
+
+// Command class numbers used by the client.
+const (
+	CommandClassBasic            = 0x20
+	CommandClassBinarySwitch     = 0x25
+	CommandClassMultilevelSwitch = 0x26
+	CommandClassMeter            = 0x32
+	CommandClassConfiguration    = 0x70
+)
+
+var (
+	// ErrClientClosed is returned for calls on a closed client or when the
+	// connection drops while a call is pending.
+	ErrClientClosed = errors.New("zwave client closed")
+
+	// ErrNodeNotFound is returned when a node ID is not in the network.
+	ErrNodeNotFound = errors.New("node not found")
+)
+
+// ServerError is an error result returned by zwave-js-server.
+type ServerError struct {
+	Code    string
+	Message string
+	Command string
+}
+
+// Error implements error.
+func (e *ServerError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s failed: %s: %s", e.Command, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Command, e.Code)
+}
+
+// ServerVersion is the version message zwave-js-server sends on connect.
+type ServerVersion struct {
+	DriverVersion    string `json:"driverVersion"`
+	ServerVersion    string `json:"serverVersion"`
+	HomeID           uint32 `json:"homeId"`
+	MinSchemaVersion int    `json:"minSchemaVersion"`
+	MaxSchemaVersion int    `json:"maxSchemaVersion"`
+}
+
+// NodeStatus is the reachability of a node as tracked by Z-Wave JS.
+type NodeStatus int
+
+// Node statuses.
+const (
+	NodeStatusUnknown NodeStatus = 0
+	NodeStatusAsleep  NodeStatus = 1
+	NodeStatusAwake   NodeStatus = 2
+	NodeStatusDead    NodeStatus = 3
+	NodeStatusAlive   NodeStatus = 4
+)
+
+// String returns the status name.
+func (s NodeStatus) String() string {
+	switch s {
+	case NodeStatusAsleep:
+		return "asleep"
+	case NodeStatusAwake:
+		return "awake"
+	case NodeStatusDead:
+		return "dead"
+	case NodeStatusAlive:
+		return "alive"
+	default:
+		return "unknown"
+	}
+}
+
+// Node is a node of the Z-Wave network as reported by zwave-js-server.
+type Node struct {
+	// Device is the Wave device matched by product ID, with the node's
+	// network information filled in; nil for other manufacturers' nodes
+	// and unknown products.
+	Device *Device `json:"-"`
+
+	// HighestSecurityClass is the highest granted security class
+	// (0 S2 Unauthenticated, 1 S2 Authenticated, 2 S2 Access Control,
+	// 7 S0); nil if the node is not secure or not yet interviewed.
+	HighestSecurityClass *int `json:"highestSecurityClass"`
+
+	Name            string     `json:"name"`
+	Location        string     `json:"location"`
+	FirmwareVersion string     `json:"firmwareVersion"`
+	NodeID          int        `json:"nodeId"`
+	Status          NodeStatus `json:"status"`
+	ManufacturerID  uint16     `json:"manufacturerId"`
+	ProductType     uint16     `json:"productType"`
+	ProductID       uint16     `json:"productId"`
+	Protocol        int        `json:"protocol"`
+	Ready           bool       `json:"ready"`
+	IsListening     bool       `json:"isListening"`
+	IsRouting       bool       `json:"isRouting"`
+	IsSecure        bool       `json:"isSecure"`
+}
+
+// Product returns the node's product identifiers.
+func (n *Node) Product() Product {
+	return Product{ManufacturerID: n.ManufacturerID, ProductType: n.ProductType, ProductID: n.ProductID}
+}
+
+// IsWave reports whether the node is a Shelly device.
+func (n *Node) IsWave() bool {
+	return n.ManufacturerID == ShellyManufacturerID
+}
+
+// SecurityLevel returns the node's security level.
+func (n *Node) SecurityLevel() SecurityLevel {
+	switch {
+	case n.HighestSecurityClass == nil || *n.HighestSecurityClass == 7:
+		return SecurityUnsecure
+	case *n.HighestSecurityClass == 0:
+		return SecurityS2Unauthenticated
+	default:
+		return SecurityS2Authenticated
+	}
+}
+
+// Topology returns the network the node was included into.
+func (n *Node) Topology() NetworkTopology {
+	if n.Protocol == 1 {
+		return TopologyLongRange
+	}
+	return TopologyMesh
+}
+
+// Info returns the node as a NodeInfo.
+func (n *Node) Info(homeID uint32) NodeInfo {
+	info := NodeInfo{
+		Name:        n.Name,
+		Security:    n.SecurityLevel(),
+		Topology:    n.Topology(),
+		NodeID:      n.NodeID,
+		HomeID:      homeID,
+		IsListening: n.IsListening,
+		IsRouting:   n.IsRouting,
+	}
+	if n.Device != nil {
+		info.Model = n.Device.Model()
+	}
+	return info
+}
+
+// ValueID addresses a value of a node.
+type ValueID struct {
+	// Property is the property name or, for configuration parameters, the
+	// parameter number.
+	Property any `json:"property"`
+
+	// PropertyKey selects a sub-value; nil if the value has none.
+	PropertyKey any `json:"propertyKey,omitempty"`
+
+	CommandClass int `json:"commandClass"`
+	Endpoint     int `json:"endpoint,omitempty"`
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithEventBus publishes value updates to bus as events.ZWaveValueEvent
+// and node dead/alive changes as device offline/online events.
+func WithEventBus(bus *events.EventBus) ClientOption {
+	return func(c *Client) {
+		c.bus = bus
+	}
+}
+
+// WithSchemaVersion sets the API schema version to request.
+func WithSchemaVersion(version int) ClientOption {
+	return func(c *Client) {
+		c.schema = version
+	}
+}
+
+// Client talks to a zwave-js-server instance over its WebSocket API.
+//
+// On connect the client negotiates the schema version and starts
+// listening, which loads the node list and subscribes to events. The node
+// list is kept current from node added/removed and status events.
+type Client struct {
+	conn    *websocket.Conn
+	bus     *events.EventBus
+	pending map[string]chan *serverMessage
+	nodes   map[int]*Node
+	done    chan struct{}
+	version ServerVersion
+	schema  int
+	nextID  uint64
+	writeMu sync.Mutex
+	mu      sync.RWMutex
+	closed  bool
+}
+
+type serverMessage struct {
+	Result    json.RawMessage `json:"result"`
+	Event     json.RawMessage `json:"event"`
+	Type      string          `json:"type"`
+	MessageID string          `json:"messageId"`
+	ErrorCode string          `json:"errorCode"`
+	Message   string          `json:"message"`
+	Success   bool            `json:"success"`
+}
+
+// Dial connects to a zwave-js-server, e.g. "ws://homeassistant:3000".
+//
+// Example:
+//
+//	bus := events.NewEventBus()
+//	client, err := zwave.Dial(ctx, "ws://localhost:3000", zwave.WithEventBus(bus))
+//	if err != nil {
+//	    return err
+//	}
+//	defer client.Close()
+//	for _, node := range client.WaveNodes() {
+//	    fmt.Println(node.NodeID, node.Device.Name())
+//	}
+func Dial(ctx context.Context, url string, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		pending: make(map[string]chan *serverMessage),
+		nodes:   make(map[int]*Node),
+		done:    make(chan struct{}),
+		schema:  DefaultSchemaVersion,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to zwave-js-server: %w", err)
+	}
+	c.conn = conn
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline) //nolint:errcheck // Best effort, the read fails anyway
+	}
+	if err := c.readVersion(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Time{}) //nolint:errcheck // Clearing the handshake deadline
+	go c.readLoop()
+
+	if err := c.start(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readVersion reads the version message the server sends on connect.
+func (c *Client) readVersion() error {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("failed to read server version: %w", err)
+	}
+	var msg serverMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "version" {
+		return fmt.Errorf("failed to read server version: unexpected message %q", data)
+	}
+	if err := json.Unmarshal(data, &c.version); err != nil {
+		return fmt.Errorf("failed to parse server version: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) start(ctx context.Context) error {
+	if c.version.MaxSchemaVersion < c.schema {
+		c.schema = c.version.MaxSchemaVersion
+	}
+	if c.schema < c.version.MinSchemaVersion {
+		return fmt.Errorf("server requires schema version %d or later", c.version.MinSchemaVersion)
+	}
+	if _, err := c.Call(ctx, "set_api_schema", map[string]any{"schemaVersion": c.schema}); err != nil {
+		return err
+	}
+
+	result, err := c.Call(ctx, "start_listening", nil)
+	if err != nil {
+		return err
+	}
+	var state struct {
+		State struct {
+			Controller struct {
+				HomeID uint32 `json:"homeId"`
+			} `json:"controller"`
+			Nodes []*Node `json:"nodes"`
+		} `json:"state"`
+	}
+	if err := json.Unmarshal(result, &state); err != nil {
+		return fmt.Errorf("failed to parse network state: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state.State.Controller.HomeID != 0 {
+		c.version.HomeID = state.State.Controller.HomeID
+	}
+	for _, n := range state.State.Nodes {
+		c.addNode(n)
+	}
+	return nil
+}
+
+// Version returns the server's version message.
+func (c *Client) Version() ServerVersion {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// SchemaVersion returns the negotiated API schema version.
+func (c *Client) SchemaVersion() int {
+	return c.schema
+}
+
+// HomeID returns the network's home ID.
+func (c *Client) HomeID() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version.HomeID
+}
+
+// DeviceID returns the device ID used for a node's events, e.g.
+// "zwave-c0ffee01-5".
+func (c *Client) DeviceID(nodeID int) string {
+	return fmt.Sprintf("zwave-%08x-%d", c.HomeID(), nodeID)
+}
+
+// Nodes returns all nodes ordered by node ID.
+func (c *Client) Nodes() []*Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*Node, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		node := *n
+		out = append(out, &node)
+	}
+	slices.SortFunc(out, func(a, b *Node) int { return a.NodeID - b.NodeID })
+	return out
+}
+
+// WaveNodes returns the Shelly Wave nodes ordered by node ID.
+func (c *Client) WaveNodes() []*Node {
+	var out []*Node
+	for _, n := range c.Nodes() {
+		if n.IsWave() {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Node returns a node by ID.
+func (c *Client) Node(nodeID int) (*Node, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	n, ok := c.nodes[nodeID]
+	if !ok {
+		return nil, false
+	}
+	node := *n
+	return &node, true
+}
+
+// NetworkInfo returns the network summary with all nodes.
+func (c *Client) NetworkInfo() *NetworkInfo {
+	nodes := c.Nodes()
+	info := &NetworkInfo{HomeID: c.HomeID(), NodeCount: len(nodes)}
+	for _, n := range nodes {
+		info.Nodes = append(info.Nodes, n.Info(info.HomeID))
+	}
+	return info
+}
+
+// addNode stores a node, matching it to a Wave profile. The caller holds
+// c.mu.
+func (c *Client) addNode(n *Node) {
+	if profile, ok := ProductProfile(n.Product()); ok {
+		d := NewDevice(profile)
+		d.NodeID = n.NodeID
+		d.HomeID = c.version.HomeID
+		d.Security = n.SecurityLevel()
+		d.Topology = n.Topology()
+		n.Device = d
+	}
+	c.nodes[n.NodeID] = n
+}
+
+// Call sends a command and returns its result. Params are merged into the
+// command message.
+func (c *Client) Call(ctx context.Context, command string, params map[string]any) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	c.nextID++
+	id := strconv.FormatUint(c.nextID, 10)
+	ch := make(chan *serverMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	msg := make(map[string]any, len(params)+2)
+	maps.Copy(msg, params)
+	msg["messageId"] = id
+	msg["command"] = command
+
+	c.writeMu.Lock()
+	err := c.conn.WriteJSON(msg)
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", command, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrClientClosed
+	case resp := <-ch:
+		if !resp.Success {
+			return nil, &ServerError{Code: resp.ErrorCode, Message: resp.Message, Command: command}
+		}
+		return resp.Result, nil
+	}
+}
+
+// GetValue reads a value of a node.
+func (c *Client) GetValue(ctx context.Context, nodeID int, id ValueID) (json.RawMessage, error) {
+	result, err := c.Call(ctx, "node.get_value", map[string]any{"nodeId": nodeID, "valueId": id})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse node.get_value response: %w", err)
+	}
+	return resp.Value, nil
+}
+
+// SetValue writes a value of a node.
+//
+// Example:
+//
+//	// Turn on the relay of a Wave 1
+//	err := client.SetValue(ctx, 5, zwave.ValueID{
+//	    CommandClass: zwave.CommandClassBinarySwitch,
+//	    Property:     "targetValue",
+//	}, true)
+func (c *Client) SetValue(ctx context.Context, nodeID int, id ValueID, value any) error {
+	result, err := c.Call(ctx, "node.set_value", map[string]any{"nodeId": nodeID, "valueId": id, "value": value})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Success *bool `json:"success"`
+	}
+	if err := json.Unmarshal(result, &resp); err == nil && resp.Success != nil && !*resp.Success {
+		return fmt.Errorf("node %d rejected value %v", nodeID, id.Property)
+	}
+	return nil
+}
+
+// GetConfigParameter reads a configuration parameter of a node.
+func (c *Client) GetConfigParameter(ctx context.Context, nodeID int, param *ConfigurationParameter) (int, error) {
+	value, err := c.GetValue(ctx, nodeID, configValueID(param))
+	if err != nil {
+		return 0, err
+	}
+	var v int
+	if err := json.Unmarshal(value, &v); err != nil {
+		return 0, fmt.Errorf("failed to parse parameter %d value: %w", param.Number, err)
+	}
+	return v, nil
+}
+
+// SetConfigParameter validates and writes a configuration parameter of a
+// node.
+//
+// Example:
+//
+//	params := zwave.CommonConfigParameters()
+//	err := client.SetConfigParameter(ctx, 5, &params[1], 20) // Power report threshold
+func (c *Client) SetConfigParameter(ctx context.Context, nodeID int, param *ConfigurationParameter, value int) error {
+	if err := param.Validate(value); err != nil {
+		return err
+	}
+	if err := c.SetValue(ctx, nodeID, configValueID(param), value); err != nil {
+		return fmt.Errorf("failed to set parameter %d: %w", param.Number, err)
+	}
+	return nil
+}
+
+// ConfigParameters reads the current value of each parameter and returns
+// copies with CurrentValue set.
+func (c *Client) ConfigParameters(ctx context.Context, nodeID int, params []ConfigurationParameter) ([]ConfigurationParameter, error) {
+	out := make([]ConfigurationParameter, len(params))
+	for i := range params {
+		out[i] = params[i]
+		v, err := c.GetConfigParameter(ctx, nodeID, &params[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i].CurrentValue = &v
+	}
+	return out, nil
+}
+
+func configValueID(param *ConfigurationParameter) ValueID {
+	return ValueID{CommandClass: CommandClassConfiguration, Property: param.Number}
+}
+
+type associationTarget struct {
+	Endpoint *int `json:"endpoint,omitempty"`
+	NodeID   int  `json:"nodeId"`
+}
+
+// AssociationGroups returns a node's association groups with their
+// current members, ordered by group ID.
+func (c *Client) AssociationGroups(ctx context.Context, nodeID int) ([]AssociationGroup, error) {
+	params := map[string]any{"nodeId": nodeID}
+	result, err := c.Call(ctx, "controller.get_association_groups", params)
+	if err != nil {
+		return nil, err
+	}
+	var groups struct {
+		Groups map[string]struct {
+			Label    string `json:"label"`
+			MaxNodes int    `json:"maxNodes"`
+		} `json:"groups"`
+	}
+	if err := json.Unmarshal(result, &groups); err != nil {
+		return nil, fmt.Errorf("failed to parse association groups: %w", err)
+	}
+
+	result, err = c.Call(ctx, "controller.get_associations", params)
+	if err != nil {
+		return nil, err
+	}
+	var members struct {
+		Associations map[string][]associationTarget `json:"associations"`
+	}
+	if err := json.Unmarshal(result, &members); err != nil {
+		return nil, fmt.Errorf("failed to parse associations: %w", err)
+	}
+
+	out := make([]AssociationGroup, 0, len(groups.Groups))
+	for key, g := range groups.Groups {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		group := AssociationGroup{ID: id, Name: g.Label, MaxNodes: g.MaxNodes}
+		for _, m := range members.Associations[key] {
+			group.NodeIDs = append(group.NodeIDs, m.NodeID)
+		}
+		out = append(out, group)
+	}
+	slices.SortFunc(out, func(a, b AssociationGroup) int { return a.ID - b.ID })
+	return out, nil
+}
+
+// AddAssociation associates target nodes with a group of a node, checking
+// the group exists and has room.
+func (c *Client) AddAssociation(ctx context.Context, nodeID, groupID int, targets ...int) error {
+	groups, err := c.AssociationGroups(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(groups, func(g AssociationGroup) bool { return g.ID == groupID })
+	if i < 0 {
+		return fmt.Errorf("node %d has no association group %d", nodeID, groupID)
+	}
+	added := 0
+	for _, t := range targets {
+		if !slices.Contains(groups[i].NodeIDs, t) {
+			added++
+		}
+	}
+	if g := groups[i]; g.MaxNodes > 0 && len(g.NodeIDs)+added > g.MaxNodes {
+		return fmt.Errorf("association group %d of node %d holds at most %d nodes", groupID, nodeID, g.MaxNodes)
+	}
+	return c.changeAssociations(ctx, "controller.add_associations", nodeID, groupID, targets)
+}
+
+// RemoveAssociation removes target nodes from a group of a node.
+func (c *Client) RemoveAssociation(ctx context.Context, nodeID, groupID int, targets ...int) error {
+	return c.changeAssociations(ctx, "controller.remove_associations", nodeID, groupID, targets)
+}
+
+func (c *Client) changeAssociations(ctx context.Context, command string, nodeID, groupID int, targets []int) error {
+	associations := make([]associationTarget, len(targets))
+	for i, t := range targets {
+		associations[i] = associationTarget{NodeID: t}
+	}
+	_, err := c.Call(ctx, command, map[string]any{"nodeId": nodeID, "group": groupID, "associations": associations})
+	return err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	_ = c.conn.WriteMessage(websocket.CloseMessage, //nolint:errcheck // Closing anyway
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMu.Unlock()
+	return c.conn.Close()
+}
+
+func (c *Client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg serverMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "result":
+			c.mu.RLock()
+			ch := c.pending[msg.MessageID]
+			c.mu.RUnlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		case "event":
+			c.handleEvent(msg.Event)
+		}
+	}
+}
+
+type serverEvent struct {
+	Node   *Node           `json:"node"`
+	Source string          `json:"source"`
+	Event  string          `json:"event"`
+	Args   json.RawMessage `json:"args"`
+	NodeID int             `json:"nodeId"`
+}
+
+type valueArgs struct {
+	Property         any             `json:"property"`
+	PropertyKey      any             `json:"propertyKey"`
+	NewValue         json.RawMessage `json:"newValue"`
+	PrevValue        json.RawMessage `json:"prevValue"`
+	CommandClassName string          `json:"commandClassName"`
+	CommandClass     int             `json:"commandClass"`
+	Endpoint         int             `json:"endpoint"`
+}
+
+func (c *Client) handleEvent(data json.RawMessage) {
+	var e serverEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return
+	}
+
+	switch {
+	case e.Source == "controller" && e.Event == "node added" && e.Node != nil:
+		c.mu.Lock()
+		c.addNode(e.Node)
+		c.mu.Unlock()
+	case e.Source == "controller" && e.Event == "node removed" && e.Node != nil:
+		c.mu.Lock()
+		delete(c.nodes, e.Node.NodeID)
+		c.mu.Unlock()
+	case e.Source == "node" && e.Event == "value updated":
+		var args valueArgs
+		if err := json.Unmarshal(e.Args, &args); err != nil || c.bus == nil {
+			return
+		}
+		event := events.NewZWaveValueEvent(c.DeviceID(e.NodeID), e.NodeID, args.CommandClass, propertyString(args.Property), args.NewValue).
+			WithEndpoint(args.Endpoint).
+			WithCommandClassName(args.CommandClassName).
+			WithPrevValue(args.PrevValue)
+		if args.PropertyKey != nil {
+			event.WithPropertyKey(propertyString(args.PropertyKey))
+		}
+		c.bus.Publish(event)
+	case e.Source == "node":
+		c.setStatus(e.NodeID, e.Event)
+	}
+}
+
+// setStatus tracks node status events and publishes dead/alive changes.
+func (c *Client) setStatus(nodeID int, event string) {
+	status, ok := map[string]NodeStatus{
+		"sleep":   NodeStatusAsleep,
+		"wake up": NodeStatusAwake,
+		"dead":    NodeStatusDead,
+		"alive":   NodeStatusAlive,
+	}[event]
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	if n, ok := c.nodes[nodeID]; ok {
+		n.Status = status
+	}
+	c.mu.Unlock()
+
+	if c.bus == nil {
+		return
+	}
+	switch status {
+	case NodeStatusDead:
+		c.bus.Publish(events.NewDeviceOfflineEvent(c.DeviceID(nodeID)).
+			WithReason("node dead").
+			WithSource(events.EventSourceZWave))
+	case NodeStatusAlive:
+		c.bus.Publish(events.NewDeviceOnlineEvent(c.DeviceID(nodeID)).WithSource(events.EventSourceZWave))
+	}
+}
+
+// propertyString formats a property or property key, which are strings or
+// numbers.
+func propertyString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,387 @@
+package zwave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/tj-smith47/shelly-go/events"
+)
+
+const testHomeID = 0xC0FFEE01
+
+// fakeServer is a stand-in zwave-js-server that answers commands from a
+// handler and can push events.
+type fakeServer struct {
+	*httptest.Server
+	handler  func(cmd map[string]any) (any, *ServerError)
+	conn     *websocket.Conn
+	commands []map[string]any
+	connCh   chan struct{}
+	mu       sync.Mutex
+}
+
+func newFakeServer(t *testing.T, handler func(cmd map[string]any) (any, *ServerError)) *fakeServer {
+	t.Helper()
+	s := &fakeServer{handler: handler, connCh: make(chan struct{})}
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+		close(s.connCh)
+
+		s.send(map[string]any{
+			"type": "version", "driverVersion": "12.0.0", "serverVersion": "1.33.0",
+			"homeId": testHomeID, "minSchemaVersion": 0, "maxSchemaVersion": 33,
+		})
+		for {
+			var cmd map[string]any
+			if err := conn.ReadJSON(&cmd); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.commands = append(s.commands, cmd)
+			s.mu.Unlock()
+
+			result, serr := s.handle(cmd)
+			resp := map[string]any{"type": "result", "messageId": cmd["messageId"], "success": serr == nil}
+			if serr != nil {
+				resp["errorCode"], resp["message"] = serr.Code, serr.Message
+			} else {
+				resp["result"] = result
+			}
+			s.send(resp)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) handle(cmd map[string]any) (any, *ServerError) {
+	switch cmd["command"] {
+	case "set_api_schema":
+		return map[string]any{}, nil
+	case "start_listening":
+		return map[string]any{"state": map[string]any{
+			"controller": map[string]any{"homeId": testHomeID},
+			"nodes": []map[string]any{
+				{"nodeId": 1, "name": "Controller", "status": 4, "ready": true, "isListening": true},
+				{
+					"nodeId": 5, "name": "Kitchen", "status": 4, "ready": true, "isListening": true, "isRouting": true,
+					"isSecure": true, "highestSecurityClass": 1, "firmwareVersion": "12.1",
+					"manufacturerId": 0x0460, "productType": 0x0002, "productId": 0x0082,
+				},
+				{"nodeId": 7, "manufacturerId": 0x0086, "productType": 3, "productId": 9, "protocol": 1},
+			},
+		}}, nil
+	}
+	return s.handler(cmd)
+}
+
+func (s *fakeServer) send(msg any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.conn.WriteJSON(msg)
+}
+
+func (s *fakeServer) event(event map[string]any) {
+	s.send(map[string]any{"type": "event", "event": event})
+}
+
+func (s *fakeServer) lastCommand() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[len(s.commands)-1]
+}
+
+func (s *fakeServer) url() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func dialFake(t *testing.T, s *fakeServer, opts ...ClientOption) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, s.url(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestDial(t *testing.T) {
+	s := newFakeServer(t, nil)
+	c := dialFake(t, s)
+
+	if c.SchemaVersion() != 33 || c.Version().DriverVersion != "12.0.0" || c.HomeID() != testHomeID {
+		t.Errorf("version = %+v, schema %d", c.Version(), c.SchemaVersion())
+	}
+	nodes := c.Nodes()
+	if len(nodes) != 3 || nodes[0].NodeID != 1 || nodes[2].NodeID != 7 {
+		t.Fatalf("Nodes() = %v", nodes)
+	}
+
+	wave := c.WaveNodes()
+	if len(wave) != 1 || wave[0].NodeID != 5 {
+		t.Fatalf("WaveNodes() = %v", wave)
+	}
+	d := wave[0].Device
+	if d == nil || d.Model() != "SNSW-001P16ZW" || d.NodeID != 5 || d.HomeID != testHomeID ||
+		d.Security != SecurityS2Authenticated || d.Topology != TopologyMesh {
+		t.Errorf("Device = %+v", d)
+	}
+
+	other, ok := c.Node(7)
+	if !ok || other.Device != nil || other.Topology() != TopologyLongRange || other.SecurityLevel() != SecurityUnsecure {
+		t.Errorf("Node(7) = %+v", other)
+	}
+	if _, ok := c.Node(99); ok {
+		t.Error("Node(99) found")
+	}
+
+	info := c.NetworkInfo()
+	if info.NodeCount != 3 || info.HomeID != testHomeID || info.Nodes[1].Model != "SNSW-001P16ZW" || info.Nodes[1].Name != "Kitchen" {
+		t.Errorf("NetworkInfo() = %+v", info)
+	}
+	if id := c.DeviceID(5); id != "zwave-c0ffee01-5" {
+		t.Errorf("DeviceID() = %s", id)
+	}
+}
+
+func TestDial_SchemaTooOld(t *testing.T) {
+	s := newFakeServer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := Dial(ctx, s.url(), WithSchemaVersion(-1)); err == nil {
+		t.Error("expected schema error")
+	}
+}
+
+func TestClient_Values(t *testing.T) {
+	s := newFakeServer(t, func(cmd map[string]any) (any, *ServerError) {
+		switch cmd["command"] {
+		case "node.get_value":
+			return map[string]any{"value": 20}, nil
+		case "node.set_value":
+			if cmd["nodeId"] == float64(9) {
+				return map[string]any{"success": false}, nil
+			}
+			return map[string]any{"success": true}, nil
+		}
+		return nil, &ServerError{Code: "unknown_command"}
+	})
+	c := dialFake(t, s)
+	ctx := context.Background()
+
+	v, err := c.GetValue(ctx, 5, ValueID{CommandClass: CommandClassBinarySwitch, Property: "currentValue"})
+	if err != nil || string(v) != "20" {
+		t.Errorf("GetValue() = %s, %v", v, err)
+	}
+
+	params := CommonConfigParameters()
+	threshold := &params[1]
+	if err := c.SetConfigParameter(ctx, 5, threshold, 25); err != nil {
+		t.Fatal(err)
+	}
+	cmd := s.lastCommand()
+	valueID := cmd["valueId"].(map[string]any)
+	if cmd["command"] != "node.set_value" || cmd["value"] != float64(25) ||
+		valueID["commandClass"] != float64(CommandClassConfiguration) || valueID["property"] != float64(36) {
+		t.Errorf("set_value command = %v", cmd)
+	}
+
+	if err := c.SetConfigParameter(ctx, 5, threshold, 101); err == nil {
+		t.Error("expected validation error")
+	}
+	if s.lastCommand()["value"] != float64(25) {
+		t.Error("invalid value was sent")
+	}
+	if err := c.SetValue(ctx, 9, ValueID{CommandClass: CommandClassBinarySwitch, Property: "targetValue"}, true); err == nil {
+		t.Error("expected rejected set_value")
+	}
+
+	got, err := c.ConfigParameters(ctx, 5, params[:2])
+	if err != nil || len(got) != 2 || *got[1].CurrentValue != 20 || params[1].CurrentValue != nil {
+		t.Errorf("ConfigParameters() = %+v, %v", got, err)
+	}
+
+	var serr *ServerError
+	if _, err := c.Call(ctx, "node.ping", nil); !errors.As(err, &serr) || serr.Code != "unknown_command" || serr.Command != "node.ping" {
+		t.Errorf("Call() error = %v", err)
+	}
+}
+
+func TestClient_Associations(t *testing.T) {
+	s := newFakeServer(t, func(cmd map[string]any) (any, *ServerError) {
+		switch cmd["command"] {
+		case "controller.get_association_groups":
+			return map[string]any{"groups": map[string]any{
+				"2": map[string]any{"label": "Basic Set", "maxNodes": 2},
+				"1": map[string]any{"label": "Lifeline", "maxNodes": 1, "isLifeline": true},
+			}}, nil
+		case "controller.get_associations":
+			return map[string]any{"associations": map[string]any{
+				"1": []map[string]any{{"nodeId": 1}},
+				"2": []map[string]any{{"nodeId": 6}},
+			}}, nil
+		case "controller.add_associations", "controller.remove_associations":
+			return map[string]any{}, nil
+		}
+		return nil, &ServerError{Code: "unknown_command"}
+	})
+	c := dialFake(t, s)
+	ctx := context.Background()
+
+	groups, err := c.AssociationGroups(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].ID != 1 || groups[0].Name != "Lifeline" || groups[1].MaxNodes != 2 ||
+		len(groups[1].NodeIDs) != 1 || groups[1].NodeIDs[0] != 6 {
+		t.Errorf("AssociationGroups() = %+v", groups)
+	}
+
+	if err := c.AddAssociation(ctx, 5, 2, 8); err != nil {
+		t.Fatal(err)
+	}
+	cmd := s.lastCommand()
+	targets := cmd["associations"].([]any)
+	if cmd["command"] != "controller.add_associations" || cmd["group"] != float64(2) ||
+		len(targets) != 1 || targets[0].(map[string]any)["nodeId"] != float64(8) {
+		t.Errorf("add command = %v", cmd)
+	}
+
+	if err := c.AddAssociation(ctx, 5, 2, 8, 9); err == nil {
+		t.Error("expected full group error")
+	}
+	if err := c.AddAssociation(ctx, 5, 3, 8); err == nil {
+		t.Error("expected missing group error")
+	}
+	if err := c.RemoveAssociation(ctx, 5, 2, 6); err != nil || s.lastCommand()["command"] != "controller.remove_associations" {
+		t.Errorf("RemoveAssociation() = %v", err)
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	bus := events.NewEventBus()
+	received := make(chan events.Event, 10)
+	bus.Subscribe(func(e events.Event) { received <- e })
+
+	s := newFakeServer(t, nil)
+	c := dialFake(t, s, WithEventBus(bus))
+	<-s.connCh
+
+	next := func() events.Event {
+		t.Helper()
+		select {
+		case e := <-received:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return nil
+		}
+	}
+
+	s.event(map[string]any{
+		"source": "node", "event": "value updated", "nodeId": 5,
+		"args": map[string]any{
+			"commandClassName": "Meter", "commandClass": 50, "endpoint": 1,
+			"property": "value", "propertyKey": 66049, "newValue": 12.5, "prevValue": 10,
+		},
+	})
+	e, ok := next().(*events.ZWaveValueEvent)
+	if !ok || e.DeviceID() != "zwave-c0ffee01-5" || e.NodeID != 5 || e.CommandClass != 50 || e.Endpoint != 1 ||
+		e.Property != "value" || e.PropertyKey != "66049" || string(e.NewValue) != "12.5" || string(e.PrevValue) != "10" {
+		t.Errorf("value event = %+v", e)
+	}
+
+	s.event(map[string]any{"source": "node", "event": "dead", "nodeId": 5})
+	if off, ok := next().(*events.DeviceOfflineEvent); !ok || off.DeviceID() != "zwave-c0ffee01-5" || off.Source() != events.EventSourceZWave {
+		t.Errorf("offline event = %+v", off)
+	}
+	if n, _ := c.Node(5); n.Status != NodeStatusDead {
+		t.Errorf("status = %s", n.Status)
+	}
+	s.event(map[string]any{"source": "node", "event": "alive", "nodeId": 5})
+	if _, ok := next().(*events.DeviceOnlineEvent); !ok {
+		t.Error("expected online event")
+	}
+
+	s.event(map[string]any{"source": "controller", "event": "node added", "node": map[string]any{
+		"nodeId": 9, "manufacturerId": 0x0460, "productType": 0x0004, "productId": 0x0085,
+	}})
+	s.event(map[string]any{"source": "controller", "event": "node removed", "node": map[string]any{"nodeId": 7}})
+	// Wait for the events to be processed behind a round trip.
+	s.event(map[string]any{"source": "node", "event": "alive", "nodeId": 1})
+	next()
+
+	if n, ok := c.Node(9); !ok || n.Device == nil || n.Device.Model() != "SPSW-003XE16ZW" {
+		t.Errorf("added node = %+v", n)
+	}
+	if _, ok := c.Node(7); ok {
+		t.Error("removed node still present")
+	}
+}
+
+func TestClient_Closed(t *testing.T) {
+	var s *fakeServer
+	s = newFakeServer(t, func(map[string]any) (any, *ServerError) {
+		// Drop the connection instead of answering.
+		s.conn.Close()
+		return nil, nil
+	})
+	c := dialFake(t, s)
+
+	if _, err := c.Call(context.Background(), "node.ping", nil); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("pending call error = %v", err)
+	}
+	if _, err := c.Call(context.Background(), "node.ping", nil); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Call() after close = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
+
+func TestConfigurationParameter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   ConfigurationParameter
+		value   int
+		wantErr bool
+	}{
+		{name: "in range", param: ConfigurationParameter{Size: 1, MinValue: 0, MaxValue: 100}, value: 50},
+		{name: "below min", param: ConfigurationParameter{Size: 1, MinValue: 0, MaxValue: 100}, value: -1, wantErr: true},
+		{name: "above max", param: ConfigurationParameter{Size: 2, MinValue: 0, MaxValue: 32767}, value: 32768, wantErr: true},
+		{name: "bad size", param: ConfigurationParameter{Size: 3, MaxValue: 10}, value: 1, wantErr: true},
+		{name: "exceeds size", param: ConfigurationParameter{Size: 1, MinValue: 0, MaxValue: 1000}, value: 256, wantErr: true},
+		{name: "signed", param: ConfigurationParameter{Size: 1, MinValue: -128, MaxValue: 127}, value: -128},
+	}
+	for _, tt := range tests {
+		if err := tt.param.Validate(tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate(%d) = %v", tt.name, tt.value, err)
+		}
+	}
+}
+
+func TestValueID_JSON(t *testing.T) {
+	data, _ := json.Marshal(ValueID{CommandClass: CommandClassConfiguration, Property: 36})
+	if string(data) != `{"property":36,"commandClass":112}` {
+		t.Errorf("ValueID JSON = %s", data)
+	}
+}
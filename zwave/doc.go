@@ -26,8 +26,26 @@
 //
 // For Z-Wave-only operation (no IP connectivity), devices must be accessed
 // through their Z-Wave gateway. This package provides utilities for working
-// with Wave device profiles and capabilities, but actual device control
-// requires integration with your Z-Wave gateway's API.
+// with Wave device profiles and capabilities. For gateways running Z-Wave
+// JS (including Home Assistant), Client controls the devices through
+// zwave-js-server:
+//
+//	client, err := zwave.Dial(ctx, "ws://homeassistant:3000", zwave.WithEventBus(bus))
+//	if err != nil {
+//	    return err
+//	}
+//	defer client.Close()
+//
+//	for _, node := range client.WaveNodes() {
+//	    fmt.Println(node.NodeID, node.Device.Name(), node.Status)
+//	}
+//
+//	params := zwave.CommonConfigParameters()
+//	err = client.SetConfigParameter(ctx, 5, &params[1], 20)
+//	err = client.AddAssociation(ctx, 5, 2, 8)
+//
+// Value updates are published to the event bus as events.ZWaveValueEvent,
+// and nodes going dead or alive as device offline/online events.
 //
 // # Supported Gateways
 //
@@ -58,6 +76,24 @@
 // The DSK (Device Specific Key) PIN is printed on the device label
 // and required for S2 Authenticated inclusion.
 //
+// # SmartStart QR Codes
+//
+// ParseQRCode decodes the 90+ digit provisioning QR code printed on Wave
+// devices and their packaging: requested security keys, the DSK, device
+// class, and the manufacturer/product IDs, which ProductProfile maps to a
+// Wave profile. QRCode.Encode generates codes, and DSK.VerifyPIN checks a
+// 5-digit PIN entered by the user.
+//
+// A ProvisioningList turns a batch of scanned codes into a SmartStart
+// provisioning list that gateways such as Z-Wave JS can import, so a
+// whole shipment joins the network as soon as it is powered:
+//
+//	var list zwave.ProvisioningList
+//	if _, err := list.ReadQRCodes(scans, zwave.WithLocation("Site A")); err != nil {
+//	    return err
+//	}
+//	err := list.WriteJSON(out)
+//
 // # Network Topology
 //
 // Wave devices support two network types:
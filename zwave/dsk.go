@@ -0,0 +1,98 @@
+package zwave
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidDSK is returned when a DSK or DSK PIN is malformed.
+var ErrInvalidDSK = errors.New("invalid DSK")
+
+// DSKLength is the number of decimal digits in a DSK.
+const DSKLength = 40
+
+// DSK is a Z-Wave S2 Device Specific Key: 16 bytes, written as eight
+// 5-digit decimal blocks of 16 bits each, e.g.
+// "51525-35455-41424-34445-31323-33435-21222-32425".
+//
+// The first block is the PIN printed underlined on the device label and
+// entered during S2 Authenticated inclusion.
+type DSK [8]uint16
+
+// ParseDSK parses a DSK in its dashed form or as 40 digits; spaces are
+// ignored.
+func ParseDSK(s string) (DSK, error) {
+	var dsk DSK
+	digits := strings.NewReplacer("-", "", " ", "").Replace(s)
+	if len(digits) != DSKLength {
+		return dsk, fmt.Errorf("%w: want %d digits, got %d", ErrInvalidDSK, DSKLength, len(digits))
+	}
+	for i := range dsk {
+		block, err := ParsePIN(digits[i*5 : i*5+5])
+		if err != nil {
+			return dsk, err
+		}
+		dsk[i] = block
+	}
+	return dsk, nil
+}
+
+// ParsePIN parses a 5-digit DSK PIN (0-65535).
+func ParsePIN(s string) (uint16, error) {
+	if len(s) != 5 || !isDigits(s) {
+		return 0, fmt.Errorf("%w: PIN %q must be 5 digits", ErrInvalidDSK, s)
+	}
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: PIN %q exceeds 65535", ErrInvalidDSK, s)
+	}
+	return uint16(v), nil
+}
+
+// String returns the DSK in its dashed form.
+func (d DSK) String() string {
+	var b strings.Builder
+	for i, block := range d {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		fmt.Fprintf(&b, "%05d", block)
+	}
+	return b.String()
+}
+
+// PIN returns the 5-digit PIN, the first block of the DSK.
+func (d DSK) PIN() string {
+	return fmt.Sprintf("%05d", d[0])
+}
+
+// VerifyPIN reports whether pin is the DSK's PIN.
+func (d DSK) VerifyPIN(pin string) bool {
+	v, err := ParsePIN(strings.TrimSpace(pin))
+	return err == nil && v == d[0]
+}
+
+// Bytes returns the 16-byte key.
+func (d DSK) Bytes() []byte {
+	b := make([]byte, 0, 16)
+	for _, block := range d {
+		b = append(b, byte(block>>8), byte(block))
+	}
+	return b
+}
+
+// IsZero reports whether the DSK is unset.
+func (d DSK) IsZero() bool {
+	return d == DSK{}
+}
+
+func isDigits(s string) bool {
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
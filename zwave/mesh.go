@@ -1,5 +1,7 @@
 package zwave
 
+import "fmt"
+
 // NodeInfo contains information about a Z-Wave node in the network.
 type NodeInfo struct {
 	Model       string
@@ -83,6 +85,23 @@ type ConfigurationParameter struct {
 	MaxValue     int
 }
 
+// Validate checks that value can be written to the parameter: the size
+// must be 1, 2 or 4 bytes and the value within MinValue-MaxValue and the
+// range the size can hold.
+func (p *ConfigurationParameter) Validate(value int) error {
+	if p.Size != 1 && p.Size != 2 && p.Size != 4 {
+		return fmt.Errorf("parameter %d: invalid size %d, must be 1, 2 or 4", p.Number, p.Size)
+	}
+	if value < p.MinValue || value > p.MaxValue {
+		return fmt.Errorf("parameter %d: value %d out of range %d-%d", p.Number, value, p.MinValue, p.MaxValue)
+	}
+	bits := uint(p.Size * 8)
+	if int64(value) < -(1<<(bits-1)) || int64(value) >= 1<<bits {
+		return fmt.Errorf("parameter %d: value %d does not fit in %d bytes", p.Number, value, p.Size)
+	}
+	return nil
+}
+
 // CommonConfigParameters returns common configuration parameters for Wave switches.
 //
 // Note: Actual parameters vary by device model. Consult the device
@@ -0,0 +1,74 @@
+package zwave
+
+import (
+	"sync"
+
+	"github.com/tj-smith47/shelly-go/profiles"
+
+	// Registers the Wave profiles the product table refers to.
+	_ "github.com/tj-smith47/shelly-go/profiles/wave"
+)
+
+// ShellyManufacturerID is the Z-Wave manufacturer ID of Shelly.
+const ShellyManufacturerID uint16 = 0x0460
+
+var (
+	productsMu sync.RWMutex
+	products   = map[Product]string{
+		{ShellyManufacturerID, 0x0002, 0x0081}: "SNSW-001X16ZW",  // Wave 1
+		{ShellyManufacturerID, 0x0002, 0x0082}: "SNSW-001P16ZW",  // Wave 1PM
+		{ShellyManufacturerID, 0x0002, 0x0083}: "SNSW-002P16ZW",  // Wave 2PM
+		{ShellyManufacturerID, 0x0002, 0x0084}: "SNSW-102P16ZW",  // Wave Shutter
+		{ShellyManufacturerID, 0x0003, 0x0081}: "SNPL-00116USZW", // Wave Plug US
+		{ShellyManufacturerID, 0x0004, 0x0081}: "SPSW-001XE16ZW", // Wave Pro 1
+		{ShellyManufacturerID, 0x0004, 0x0082}: "SPSW-001PE16ZW", // Wave Pro 1PM
+		{ShellyManufacturerID, 0x0004, 0x0083}: "SPSW-002XE16ZW", // Wave Pro 2
+		{ShellyManufacturerID, 0x0004, 0x0084}: "SPSW-002PE16ZW", // Wave Pro 2PM
+		{ShellyManufacturerID, 0x0004, 0x0085}: "SPSW-003XE16ZW", // Wave Pro 3
+	}
+)
+
+// RegisterProduct maps a Z-Wave product to a device model, replacing any
+// existing mapping. Use it for models or firmware variants missing from
+// the built-in table.
+func RegisterProduct(p Product, model string) {
+	productsMu.Lock()
+	defer productsMu.Unlock()
+	products[p] = model
+}
+
+// ProductModel returns the device model of a Z-Wave product.
+func ProductModel(p Product) (string, bool) {
+	productsMu.RLock()
+	defer productsMu.RUnlock()
+	model, ok := products[p]
+	return model, ok
+}
+
+// ProductProfile returns the device profile of a Z-Wave product.
+//
+// Example:
+//
+//	code, _ := zwave.ParseQRCode(scanned)
+//	if profile, ok := zwave.ProductProfile(code.Product); ok {
+//	    fmt.Println(profile.Name)
+//	}
+func ProductProfile(p Product) (*profiles.Profile, bool) {
+	model, ok := ProductModel(p)
+	if !ok {
+		return nil, false
+	}
+	return profiles.Get(model)
+}
+
+// ModelProduct returns the Z-Wave product of a device model.
+func ModelProduct(model string) (Product, bool) {
+	productsMu.RLock()
+	defer productsMu.RUnlock()
+	for p, m := range products {
+		if m == model {
+			return p, true
+		}
+	}
+	return Product{}, false
+}
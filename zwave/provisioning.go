@@ -0,0 +1,195 @@
+package zwave
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProvisioningStatus is whether a gateway should include a provisioned
+// device when it requests inclusion.
+type ProvisioningStatus int
+
+const (
+	// ProvisioningActive lets the device join via SmartStart.
+	ProvisioningActive ProvisioningStatus = 0
+
+	// ProvisioningInactive keeps the entry but ignores inclusion requests.
+	ProvisioningInactive ProvisioningStatus = 1
+)
+
+// ProvisioningEntry is a SmartStart provisioning list entry, in the form
+// Z-Wave JS accepts for provisionSmartStartNode and its import files.
+//
+// Security classes and protocols are numbered as in Z-Wave JS: classes
+// are the bit positions of RequestedKeys, protocols are 0 for Z-Wave and
+// 1 for Long Range.
+type ProvisioningEntry struct {
+	DSK                      string             `json:"dsk"`
+	Name                     string             `json:"name,omitempty"`
+	Location                 string             `json:"location,omitempty"`
+	Model                    string             `json:"model,omitempty"`
+	ApplicationVersion       string             `json:"applicationVersion"`
+	SecurityClasses          []int              `json:"securityClasses"`
+	RequestedSecurityClasses []int              `json:"requestedSecurityClasses"`
+	SupportedProtocols       []int              `json:"supportedProtocols,omitempty"`
+	Protocol                 *int               `json:"protocol,omitempty"`
+	Status                   ProvisioningStatus `json:"status"`
+	Version                  QRVersion          `json:"version"`
+	Product
+	InstallerIconType   uint16 `json:"installerIconType"`
+	GenericDeviceClass  uint8  `json:"genericDeviceClass"`
+	SpecificDeviceClass uint8  `json:"specificDeviceClass"`
+}
+
+// EntryOption configures a provisioning entry.
+type EntryOption func(*ProvisioningEntry)
+
+// WithName sets the entry's node name.
+func WithName(name string) EntryOption {
+	return func(e *ProvisioningEntry) {
+		e.Name = name
+	}
+}
+
+// WithLocation sets the entry's node location.
+func WithLocation(location string) EntryOption {
+	return func(e *ProvisioningEntry) {
+		e.Location = location
+	}
+}
+
+// WithTopology selects the network the device is included into. Long
+// Range is only honored if the device supports it.
+func WithTopology(topology NetworkTopology) EntryOption {
+	return func(e *ProvisioningEntry) {
+		protocol := 0
+		if topology == TopologyLongRange {
+			protocol = 1
+		}
+		e.Protocol = &protocol
+	}
+}
+
+// WithStatus sets whether the entry is active.
+func WithStatus(status ProvisioningStatus) EntryOption {
+	return func(e *ProvisioningEntry) {
+		e.Status = status
+	}
+}
+
+// NewProvisioningEntry builds a provisioning entry from a QR code. All
+// requested security classes are granted.
+func NewProvisioningEntry(code *QRCode, opts ...EntryOption) (*ProvisioningEntry, error) {
+	if err := code.Validate(); err != nil {
+		return nil, err
+	}
+
+	classes := code.RequestedKeys.Classes()
+	e := &ProvisioningEntry{
+		DSK:                      code.DSK.String(),
+		Version:                  code.Version,
+		Product:                  code.Product,
+		ApplicationVersion:       code.AppVersion(),
+		SecurityClasses:          classes,
+		RequestedSecurityClasses: classes,
+		InstallerIconType:        code.InstallerIconType,
+		GenericDeviceClass:       code.GenericDeviceClass,
+		SpecificDeviceClass:      code.SpecificDeviceClass,
+	}
+	if e.SecurityClasses == nil {
+		e.SecurityClasses, e.RequestedSecurityClasses = []int{}, []int{}
+	}
+	if code.SupportedProtocols&ProtocolZWave != 0 {
+		e.SupportedProtocols = append(e.SupportedProtocols, 0)
+	}
+	if code.SupportedProtocols&ProtocolLongRange != 0 {
+		e.SupportedProtocols = append(e.SupportedProtocols, 1)
+	}
+	if model, ok := ProductModel(code.Product); ok {
+		e.Model = model
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.Protocol != nil && *e.Protocol == 1 && code.SupportedProtocols&ProtocolLongRange == 0 {
+		return nil, fmt.Errorf("device %s does not support Z-Wave Long Range", e.DSK)
+	}
+	return e, nil
+}
+
+// ProvisioningList collects SmartStart entries, keyed by DSK, for bulk
+// import into a gateway.
+//
+// Example:
+//
+//	var list zwave.ProvisioningList
+//	if _, err := list.ReadQRCodes(file); err != nil {
+//	    return err
+//	}
+//	return list.WriteJSON(os.Stdout)
+type ProvisioningList struct {
+	Entries []*ProvisioningEntry
+}
+
+// Add adds an entry for a QR code. An existing entry with the same DSK is
+// replaced.
+func (l *ProvisioningList) Add(code *QRCode, opts ...EntryOption) error {
+	e, err := NewProvisioningEntry(code, opts...)
+	if err != nil {
+		return err
+	}
+	for i, existing := range l.Entries {
+		if existing.DSK == e.DSK {
+			l.Entries[i] = e
+			return nil
+		}
+	}
+	l.Entries = append(l.Entries, e)
+	return nil
+}
+
+// ReadQRCodes adds one entry per line of r, as produced by a barcode
+// scanner. Blank lines and lines starting with '#' are skipped. It returns
+// the number of entries read; on error the entries before the failing
+// line have been added.
+func (l *ProvisioningList) ReadQRCodes(r io.Reader, opts ...EntryOption) (int, error) {
+	scanner := bufio.NewScanner(r)
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		code, err := ParseQRCode(text)
+		if err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := l.Add(code, opts...); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("failed to read QR codes: %w", err)
+	}
+	return n, nil
+}
+
+// WriteJSON writes the entries as a JSON array.
+func (l *ProvisioningList) WriteJSON(w io.Writer) error {
+	entries := l.Entries
+	if entries == nil {
+		entries = []*ProvisioningEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		return fmt.Errorf("failed to write provisioning list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package zwave
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func shellyCode(t *testing.T, pin string, protocols SupportedProtocols) string {
+	t.Helper()
+	dsk, err := ParseDSK(pin + "-35455-41424-34445-31323-33435-21222-32425")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &QRCode{
+		Version:            QRVersionSmartStart,
+		RequestedKeys:      KeyS2Unauthenticated | KeyS2Authenticated,
+		DSK:                dsk,
+		Product:            Product{ManufacturerID: ShellyManufacturerID, ProductType: 0x0002, ProductID: 0x0081},
+		ApplicationVersion: 0x0C01,
+		SupportedProtocols: protocols,
+	}
+	s, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewProvisioningEntry(t *testing.T) {
+	code, err := ParseQRCode(shellyCode(t, "11111", ProtocolZWave|ProtocolLongRange))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewProvisioningEntry(code, WithName("Kitchen"), WithLocation("Ground floor"),
+		WithTopology(TopologyLongRange), WithStatus(ProvisioningInactive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.DSK != code.DSK.String() || e.Model != "SNSW-001X16ZW" || e.ApplicationVersion != "12.1" ||
+		e.Name != "Kitchen" || e.Location != "Ground floor" || e.Status != ProvisioningInactive ||
+		e.Protocol == nil || *e.Protocol != 1 || len(e.SupportedProtocols) != 2 || len(e.SecurityClasses) != 2 {
+		t.Errorf("entry = %+v", e)
+	}
+
+	// Long Range is rejected for devices that don't advertise it.
+	code, _ = ParseQRCode(shellyCode(t, "22222", 0))
+	if _, err := NewProvisioningEntry(code, WithTopology(TopologyLongRange)); err == nil {
+		t.Error("expected error for Long Range without support")
+	}
+	if e, err := NewProvisioningEntry(code, WithTopology(TopologyMesh)); err != nil || *e.Protocol != 0 {
+		t.Errorf("mesh entry = %+v, %v", e, err)
+	}
+}
+
+func TestProvisioningList(t *testing.T) {
+	input := strings.Join([]string{
+		"# shipment 42",
+		shellyCode(t, "11111", 0),
+		"",
+		"  " + shellyCode(t, "22222", ProtocolZWave) + "  ",
+		shellyCode(t, "11111", 0),
+	}, "\n")
+
+	var list ProvisioningList
+	n, err := list.ReadQRCodes(strings.NewReader(input), WithLocation("Warehouse"))
+	if err != nil || n != 3 {
+		t.Fatalf("ReadQRCodes() = %d, %v", n, err)
+	}
+	if len(list.Entries) != 2 {
+		t.Fatalf("entries = %d, want duplicates merged to 2", len(list.Entries))
+	}
+
+	var buf bytes.Buffer
+	if err := list.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0]["dsk"] != list.Entries[0].DSK || out[0]["manufacturerId"] != float64(0x0460) ||
+		out[0]["location"] != "Warehouse" || out[1]["supportedProtocols"] == nil {
+		t.Errorf("JSON = %s", buf.String())
+	}
+
+	_, err = list.ReadQRCodes(strings.NewReader(shellyCode(t, "33333", 0) + "\n900100000"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") || len(list.Entries) != 3 {
+		t.Errorf("ReadQRCodes(bad) = %v, entries %d", err, len(list.Entries))
+	}
+
+	buf.Reset()
+	if err := (&ProvisioningList{}).WriteJSON(&buf); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("empty list = %q, %v", buf.String(), err)
+	}
+}
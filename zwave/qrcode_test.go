@@ -0,0 +1,196 @@
+package zwave
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Reference code from the Z-Wave JS test suite.
+const testQRCode = "900132782003515253545541424344453132333435212223242500100435301537022065520001000000300578"
+
+func TestParseQRCode(t *testing.T) {
+	c, err := ParseQRCode(testQRCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != QRVersionSmartStart || !c.IsSmartStart() {
+		t.Errorf("Version = %d", c.Version)
+	}
+	if c.RequestedKeys != KeyS2Unauthenticated|KeyS2Authenticated {
+		t.Errorf("RequestedKeys = %d", c.RequestedKeys)
+	}
+	if got := c.DSK.String(); got != "51525-35455-41424-34445-31323-33435-21222-32425" {
+		t.Errorf("DSK = %s", got)
+	}
+	if c.GenericDeviceClass != 0x11 || c.SpecificDeviceClass != 0x01 || c.InstallerIconType != 0x0601 {
+		t.Errorf("device class = %x/%x icon %x", c.GenericDeviceClass, c.SpecificDeviceClass, c.InstallerIconType)
+	}
+	want := Product{ManufacturerID: 0xFFF0, ProductType: 0x0064, ProductID: 0x0003}
+	if c.Product != want {
+		t.Errorf("Product = %s, want %s", c.Product, want)
+	}
+	if c.AppVersion() != "2.66" {
+		t.Errorf("AppVersion() = %s", c.AppVersion())
+	}
+	if got, _ := c.Encode(); got != testQRCode {
+		t.Errorf("Encode() = %s", got)
+	}
+}
+
+func TestQRCode_RoundTrip(t *testing.T) {
+	dsk, _ := ParseDSK("12345-23456-34567-45678-56789-01234-65535-00001")
+	c := &QRCode{
+		Version:                     QRVersionSmartStart,
+		RequestedKeys:               KeyS2Authenticated | KeyS0,
+		DSK:                         dsk,
+		GenericDeviceClass:          0x10,
+		SpecificDeviceClass:         0x01,
+		InstallerIconType:           0x0700,
+		Product:                     Product{ManufacturerID: ShellyManufacturerID, ProductType: 0x0002, ProductID: 0x0082},
+		ApplicationVersion:          0x0A03,
+		MaxInclusionRequestInterval: 10,
+		SupportedProtocols:          ProtocolZWave | ProtocolLongRange,
+		Extensions:                  []TLV{{Type: 0x03, Value: "00" + strings.Repeat("1", 40)}, {Type: 0x20, Value: "7"}},
+	}
+	s, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, "9001") || c.String() != s {
+		t.Errorf("Encode() = %s", s)
+	}
+	got, err := ParseQRCode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DSK != c.DSK || got.Product != c.Product || got.ApplicationVersion != c.ApplicationVersion ||
+		got.MaxInclusionRequestInterval != 10 || got.SupportedProtocols != c.SupportedProtocols ||
+		len(got.Extensions) != 2 || got.Extensions[1] != c.Extensions[1] {
+		t.Errorf("round trip = %+v", got)
+	}
+
+	profile, ok := got.Profile()
+	if !ok || profile.Model != "SNSW-001P16ZW" {
+		t.Errorf("Profile() = %v, %v", profile, ok)
+	}
+	d := got.Device()
+	if d.Model() != "SNSW-001P16ZW" || d.Security != SecurityS2Authenticated || d.DSK != dsk.String() {
+		t.Errorf("Device() = %+v", d)
+	}
+}
+
+func TestParseQRCode_Errors(t *testing.T) {
+	// body rebuilds a code with a valid checksum around the given digits.
+	body := func(b string) string {
+		return fmt.Sprintf("9001%05d%s", checksum(b), b)
+	}
+	valid := testQRCode[9:]
+	dsk := valid[3:43]
+	product := "00100435301537022065520001000000300578"
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"letters", strings.Replace(testQRCode, "9", "x", 2)},
+		{"short", testQRCode[:89]},
+		{"lead-in", "91" + testQRCode[2:]},
+		{"version", "9002" + testQRCode[4:]},
+		{"checksum", testQRCode[:4] + "32783" + testQRCode[9:]},
+		{"reserved keys", body("008" + dsk + product)},
+		{"dsk block", body("003" + "99999" + dsk[5:] + product)},
+		{"missing product id", body("003" + dsk + product[:14] + "0403" + "001" + strings.Repeat("0", 21))},
+		{"truncated block", body("003" + dsk + product + "08")},
+		{"block length", body("003" + dsk + product + "0402" + "01")},
+		{"critical unknown", body("003" + dsk + product + "6101" + "1")},
+		{"interval", body("003" + dsk + product + "0403" + "004")},
+		{"manufacturer range", body("003" + dsk + "0010043530153702209999900010000000300578")},
+	}
+	for _, tt := range tests {
+		if _, err := ParseQRCode(tt.code); !errors.Is(err, ErrInvalidQRCode) {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestDSK(t *testing.T) {
+	dsk, err := ParseDSK("51525 35455 41424 34445 31323 33435 21222 32425")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dsk.PIN() != "51525" || !dsk.VerifyPIN(" 51525") || dsk.VerifyPIN("51524") || dsk.IsZero() {
+		t.Errorf("PIN() = %s", dsk.PIN())
+	}
+	if b := dsk.Bytes(); len(b) != 16 || b[0] != 0xC9 || b[1] != 0x45 {
+		t.Errorf("Bytes() = %x", b)
+	}
+	if again, err := ParseDSK(strings.ReplaceAll(dsk.String(), "-", "")); err != nil || again != dsk {
+		t.Errorf("ParseDSK(digits) = %v, %v", again, err)
+	}
+
+	for _, s := range []string{"", "51525-35455", "51525-35455-41424-34445-31323-33435-21222-3242x", "65536" + strings.Repeat("0", 35)} {
+		if _, err := ParseDSK(s); !errors.Is(err, ErrInvalidDSK) {
+			t.Errorf("ParseDSK(%q) = %v", s, err)
+		}
+	}
+	for _, s := range []string{"1234", "123456", "65536", "1234a"} {
+		if _, err := ParsePIN(s); !errors.Is(err, ErrInvalidDSK) {
+			t.Errorf("ParsePIN(%q) = %v", s, err)
+		}
+	}
+	if pin, err := ParsePIN("00042"); err != nil || pin != 42 {
+		t.Errorf("ParsePIN(00042) = %d, %v", pin, err)
+	}
+}
+
+func TestRequestedKeys(t *testing.T) {
+	tests := []struct {
+		keys    RequestedKeys
+		level   SecurityLevel
+		classes []int
+	}{
+		{0, SecurityUnsecure, nil},
+		{KeyS0, SecurityUnsecure, []int{7}},
+		{KeyS2Unauthenticated, SecurityS2Unauthenticated, []int{0}},
+		{KeyS2Unauthenticated | KeyS2Authenticated, SecurityS2Authenticated, []int{0, 1}},
+		{KeyS2AccessControl, SecurityS2Authenticated, []int{2}},
+	}
+	for _, tt := range tests {
+		if got := tt.keys.SecurityLevel(); got != tt.level {
+			t.Errorf("%#x.SecurityLevel() = %s, want %s", uint8(tt.keys), got, tt.level)
+		}
+		if got := tt.keys.Classes(); len(got) != len(tt.classes) || (len(got) > 0 && got[len(got)-1] != tt.classes[len(tt.classes)-1]) {
+			t.Errorf("%#x.Classes() = %v, want %v", uint8(tt.keys), got, tt.classes)
+		}
+	}
+}
+
+func TestProducts(t *testing.T) {
+	p, ok := ModelProduct("SPSW-003XE16ZW")
+	if !ok || p.ManufacturerID != ShellyManufacturerID {
+		t.Fatalf("ModelProduct() = %v, %v", p, ok)
+	}
+	if model, ok := ProductModel(p); !ok || model != "SPSW-003XE16ZW" {
+		t.Errorf("ProductModel() = %s, %v", model, ok)
+	}
+	if profile, ok := ProductProfile(p); !ok || profile.Name != "Shelly Wave Pro 3" {
+		t.Errorf("ProductProfile() = %v, %v", profile, ok)
+	}
+
+	custom := Product{ManufacturerID: ShellyManufacturerID, ProductType: 0x7777, ProductID: 1}
+	if _, ok := ProductProfile(custom); ok {
+		t.Error("unknown product resolved")
+	}
+	RegisterProduct(custom, "SNSW-001X16ZW")
+	if profile, ok := ProductProfile(custom); !ok || profile.Name != "Shelly Wave 1" {
+		t.Errorf("registered ProductProfile() = %v, %v", profile, ok)
+	}
+	if _, ok := ModelProduct("unknown"); ok {
+		t.Error("ModelProduct(unknown) found")
+	}
+	if s := custom.String(); s != "0x0460:0x7777:0x0001" {
+		t.Errorf("String() = %s", s)
+	}
+}